@@ -0,0 +1,407 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package codec
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+)
+
+// packSizeMargin is the number of bytes reserved per operation for counter
+// and fee values which are only known after packing.
+const packSizeMargin = 16
+
+// DefaultRevealLimits are used for reveals added by BatchOptimizer unless
+// replaced with simulated limits.
+var DefaultRevealLimits = mavryk.Limits{
+	Fee:      1000,
+	GasLimit: 1000,
+}
+
+// headerSize returns the size of branch and signature in a serialized operation
+// group signed by source. Uses the largest signature size when the source
+// key type is unknown.
+func headerSize(source mavryk.Address) int {
+	sig := mavryk.SignatureTypeEd25519
+	if source.KeyType() == mavryk.KeyTypeBls12_381 || !source.IsEOA() {
+		sig = mavryk.SignatureTypeBls12_381
+	}
+	return mavryk.HashTypeBlock.Len + sig.Len()
+}
+
+// BatchOptimizer turns a list of manager operation intents into one or more
+// well-formed operation groups for a single source. It assigns sequential
+// counters, places a reveal at the front of the first group if required
+// (either from an explicit reveal intent or from the configured key) and
+// distributes the fee for header bytes (branch and signature) evenly across
+// all operations in a group. Each single operation must stay within the hard
+// gas limit per operation.
+//
+// Optimize keeps intents in order and optionally splits them into multiple
+// groups when a group exceeds the hard gas limit per block or the maximum
+// operation data length. Pack instead selects and rearranges independent
+// intents into as few groups as possible, e.g. for payout or airdrop engines.
+//
+// Intents should carry gas and storage limits (e.g. from simulation) because
+// these limits decide about splitting and minimum fees. Branch and signature
+// are left empty on the resulting groups.
+type BatchOptimizer struct {
+	Params       *mavryk.Params // protocol params, defaults to mavryk.DefaultParams
+	Source       mavryk.Address // source for all manager operations
+	Counter      int64          // current (last used) counter of source
+	Reveal       mavryk.Key     // optional public key, reveal is added when valid
+	RevealLimits mavryk.Limits  // limits for an added reveal, e.g. from simulation
+	Split        bool           // split into multiple groups instead of failing on limits
+	BlockGas     int64          // gas budget for all groups in Pack, defaults to the block gas limit
+	MaxGroups    int            // max number of groups in Pack, zero for no limit
+}
+
+// NewBatchOptimizer creates a new batch optimizer for source using params p.
+func NewBatchOptimizer(source mavryk.Address, counter int64, p *mavryk.Params) *BatchOptimizer {
+	if p == nil {
+		p = mavryk.DefaultParams
+	}
+	return &BatchOptimizer{
+		Params:       p,
+		Source:       source,
+		Counter:      counter,
+		RevealLimits: DefaultRevealLimits,
+	}
+}
+
+// WithReveal adds a reveal for key to the front of the first group.
+func (b *BatchOptimizer) WithReveal(key mavryk.Key) *BatchOptimizer {
+	b.Reveal = key
+	return b
+}
+
+// WithRevealLimits sets limits for the added reveal, e.g. from simulation.
+func (b *BatchOptimizer) WithRevealLimits(l mavryk.Limits) *BatchOptimizer {
+	b.RevealLimits = l
+	return b
+}
+
+// WithSplit enables or disables splitting groups on limit overflow.
+func (b *BatchOptimizer) WithSplit(split bool) *BatchOptimizer {
+	b.Split = split
+	return b
+}
+
+// WithBlockGas limits the total gas of all groups produced by Pack, e.g. to
+// leave space for other operations in a block.
+func (b *BatchOptimizer) WithBlockGas(gas int64) *BatchOptimizer {
+	b.BlockGas = gas
+	return b
+}
+
+// WithMaxGroups limits the number of groups produced by Pack.
+func (b *BatchOptimizer) WithMaxGroups(n int) *BatchOptimizer {
+	b.MaxGroups = n
+	return b
+}
+
+// prepare checks intents and returns the reveal (if any) and all other
+// manager operations with source set.
+func (b *BatchOptimizer) prepare(intents []Operation) (Operation, []Operation, error) {
+	if !b.Source.IsValid() {
+		return nil, nil, fmt.Errorf("tezos: missing batch source")
+	}
+	var reveal Operation
+	list := make([]Operation, 0, len(intents))
+	for i, v := range intents {
+		if v.GetCounter() < 0 {
+			return nil, nil, fmt.Errorf("tezos: intent #%d (%s) is not a manager operation", i, v.Kind())
+		}
+		v.WithSource(b.Source)
+		if v.Kind() == mavryk.OpTypeReveal {
+			if reveal != nil {
+				return nil, nil, fmt.Errorf("tezos: intent #%d is a duplicate reveal", i)
+			}
+			reveal = v
+			continue
+		}
+		list = append(list, v)
+	}
+	if reveal == nil && b.Reveal.IsValid() {
+		r := &Reveal{PublicKey: b.Reveal}
+		r.WithLimits(b.RevealLimits)
+		r.WithSource(b.Source)
+		reveal = r
+	}
+	if reveal == nil && len(list) == 0 {
+		return nil, nil, fmt.Errorf("tezos: empty batch")
+	}
+	return reveal, list, nil
+}
+
+// measure returns gas and size of op v plus margin bytes. Returns false when
+// v exceeds operation limits on its own.
+func measure(v Operation, header, margin int, buf *bytes.Buffer, p *mavryk.Params) (int64, int, bool) {
+	buf.Reset()
+	_ = v.EncodeBuffer(buf, p)
+	gas, size := v.Limits().GasLimit, buf.Len()+margin
+	if (p.HardGasLimitPerOperation > 0 && gas > p.HardGasLimitPerOperation) ||
+		(p.MaxOperationDataLength > 0 && size+header > p.MaxOperationDataLength) {
+		return 0, 0, false
+	}
+	return gas, size, true
+}
+
+// Optimize produces operation groups from intents. Returns an error when an
+// intent is not a manager operation, when intents contain more than one reveal,
+// when a single intent exceeds limits or when the batch exceeds limits and
+// splitting is disabled. An explicit reveal intent keeps its limits, is moved
+// to the front and takes precedence over the configured reveal key.
+func (b *BatchOptimizer) Optimize(intents []Operation) ([]*Op, error) {
+	p := b.params()
+	reveal, list, err := b.prepare(intents)
+	if err != nil {
+		return nil, err
+	}
+	if reveal != nil {
+		list = append([]Operation{reveal}, list...)
+	}
+
+	// split into groups
+	var (
+		groups   = make([][]Operation, 0, 1)
+		group    = make([]Operation, 0, len(list))
+		gas      int64
+		header   = headerSize(b.Source)
+		size     = header
+		buf      = bytes.NewBuffer(nil)
+		maxSize  = p.MaxOperationDataLength
+		groupGas = p.HardGasLimitPerBlock
+	)
+	for i, v := range list {
+		opGas, opSize, ok := measure(v, header, 0, buf, p)
+		if !ok {
+			return nil, fmt.Errorf("tezos: intent #%d (%s) exceeds operation limits", i, v.Kind())
+		}
+		overflow := (groupGas > 0 && gas+opGas > groupGas) || (maxSize > 0 && size+opSize > maxSize)
+		if overflow && len(group) > 0 {
+			if !b.Split {
+				return nil, fmt.Errorf("tezos: batch exceeds operation limits")
+			}
+			groups = append(groups, group)
+			group = make([]Operation, 0, len(list)-i)
+			gas, size = 0, header
+		}
+		group = append(group, v)
+		gas += opGas
+		size += opSize
+	}
+	groups = append(groups, group)
+	return b.finalize(groups, header, p), nil
+}
+
+// PackResult contains packed operation groups and all intents which did
+// not fit. Operations keep their intent order inside each group and groups
+// carry sequential counters, i.e. they must be injected in order.
+type PackResult struct {
+	Ops  []*Op
+	Rest []Operation
+	Gas  int64 // total gas limit of all groups
+}
+
+// Len returns the number of packed operations including an added reveal.
+func (r PackResult) Len() int {
+	var n int
+	for _, op := range r.Ops {
+		n += len(op.Contents)
+	}
+	return n
+}
+
+type packItem struct {
+	pos  int
+	op   Operation
+	gas  int64
+	size int
+}
+
+type packBin struct {
+	items []packItem
+	gas   int64
+	size  int
+}
+
+func (b *packBin) fits(v packItem, groupGas int64, maxSize int) bool {
+	return (groupGas <= 0 || b.gas+v.gas <= groupGas) && (maxSize <= 0 || b.size+v.size <= maxSize)
+}
+
+func (b *packBin) add(v packItem) {
+	b.items = append(b.items, v)
+	b.gas += v.gas
+	b.size += v.size
+}
+
+// Pack selects independent intents and distributes them into as few operation
+// groups as possible. Each group stays within the maximum operation data length
+// and the hard gas limit per block and all groups together stay within the
+// block gas budget. When not all intents fit, the packer prefers cheap
+// operations which maximizes the number of operations included per block.
+// A reveal is always placed at the front of the first group. Returns an error
+// under the same conditions as Optimize except for batch overflow.
+func (b *BatchOptimizer) Pack(intents []Operation) (*PackResult, error) {
+	p := b.params()
+	reveal, list, err := b.prepare(intents)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		groupGas = p.HardGasLimitPerBlock
+		maxSize  = p.MaxOperationDataLength
+		blockGas = b.BlockGas
+		header   = headerSize(b.Source)
+		buf      = bytes.NewBuffer(nil)
+		gas      int64
+		bins     = make([]*packBin, 0)
+	)
+	if blockGas <= 0 {
+		blockGas = p.HardGasLimitPerBlock
+	}
+
+	// the reveal opens the first group
+	if reveal != nil {
+		opGas, opSize, ok := measure(reveal, header, packSizeMargin, buf, p)
+		if !ok || (blockGas > 0 && opGas > blockGas) {
+			return nil, fmt.Errorf("tezos: reveal exceeds operation limits")
+		}
+		bins = append(bins, &packBin{size: header})
+		bins[0].add(packItem{pos: -1, op: reveal, gas: opGas, size: opSize})
+		gas = opGas
+	}
+
+	items := make([]packItem, len(list))
+	for i, v := range list {
+		opGas, opSize, ok := measure(v, header, packSizeMargin, buf, p)
+		if !ok {
+			return nil, fmt.Errorf("tezos: intent #%d (%s) exceeds operation limits", i, v.Kind())
+		}
+		items[i] = packItem{pos: i, op: v, gas: opGas, size: opSize}
+	}
+
+	// select the cheapest intents that fit into the block budget
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].gas == items[j].gas {
+			return items[i].size < items[j].size
+		}
+		return items[i].gas < items[j].gas
+	})
+	var (
+		sel  = make([]packItem, 0, len(items))
+		rest = make([]packItem, 0)
+	)
+	for _, v := range items {
+		if blockGas > 0 && gas+v.gas > blockGas {
+			rest = append(rest, v)
+			continue
+		}
+		sel = append(sel, v)
+		gas += v.gas
+	}
+
+	// first-fit decreasing into groups
+	for i := len(sel) - 1; i >= 0; i-- {
+		v := sel[i]
+		var placed bool
+		for _, bin := range bins {
+			if bin.fits(v, groupGas, maxSize) {
+				bin.add(v)
+				placed = true
+				break
+			}
+		}
+		if placed {
+			continue
+		}
+		if b.MaxGroups > 0 && len(bins) >= b.MaxGroups {
+			rest = append(rest, v)
+			continue
+		}
+		bin := &packBin{size: header}
+		bin.add(v)
+		bins = append(bins, bin)
+	}
+
+	// restore intent order, the reveal sorts first
+	res := &PackResult{
+		Rest: make([]Operation, len(rest)),
+	}
+	groups := make([][]Operation, 0, len(bins))
+	for _, bin := range bins {
+		sort.Slice(bin.items, func(k, l int) bool { return bin.items[k].pos < bin.items[l].pos })
+		g := make([]Operation, len(bin.items))
+		for k, v := range bin.items {
+			g[k] = v.op
+		}
+		groups = append(groups, g)
+		res.Gas += bin.gas
+	}
+	sort.Slice(rest, func(i, j int) bool { return rest[i].pos < rest[j].pos })
+	for i, v := range rest {
+		res.Rest[i] = v.op
+	}
+	res.Ops = b.finalize(groups, header, p)
+	return res, nil
+}
+
+func (b *BatchOptimizer) params() *mavryk.Params {
+	if b.Params == nil {
+		return mavryk.DefaultParams
+	}
+	return b.Params
+}
+
+// finalize assigns counters and fees and wraps groups into operations.
+func (b *BatchOptimizer) finalize(groups [][]Operation, header int, p *mavryk.Params) []*Op {
+	counter := b.Counter
+	ops := make([]*Op, 0, len(groups))
+	for _, g := range groups {
+		if len(g) == 0 {
+			continue
+		}
+		for _, v := range g {
+			counter++
+			v.WithCounter(counter)
+		}
+		distributeFees(g, header, p)
+		ops = append(ops, &Op{
+			Contents: g,
+			Params:   p,
+			TTL:      p.MaxOperationsTTL - 2,
+			Source:   b.Source,
+		})
+	}
+	b.Counter = counter
+	return ops
+}
+
+// distributeFees sets the fee of each operation in group to at least its
+// minimum fee plus an even share of the fee for header bytes.
+func distributeFees(group []Operation, header int, p *mavryk.Params) {
+	if len(group) == 0 {
+		return
+	}
+	n := int64(len(group))
+	headerFee := (int64(header)*minFeeByteNanoMav + 999) / 1000
+	share, rest := headerFee/n, headerFee%n
+	for i, v := range group {
+		extra := share
+		if i == 0 {
+			extra += rest
+		}
+		l := v.Limits()
+		var lastFee int64 = -1
+		for lastFee < l.Fee {
+			lastFee = l.Fee
+			l.Fee = max64(l.Fee, CalculateMinFee(v, l.GasLimit, false, p)+extra)
+			v.WithLimits(l)
+		}
+	}
+}
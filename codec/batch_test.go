@@ -0,0 +1,199 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package codec
+
+import (
+	"testing"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+)
+
+func TestBatchOptimizer(t *testing.T) {
+	src := mavryk.MustParseAddress("mv1XAPGKrKd4CRRHjTgDFLP1GEok7qXGVnvA")
+	key := mavryk.MustParseKey("edpkv45regue1bWtuHnCgLU8xWKLwa9qRqv4gimgJKro4LSc3C5VjV")
+	p := mavryk.DefaultParams.Clone()
+	p.HardGasLimitPerOperation = 5000
+	p.HardGasLimitPerBlock = 5000
+
+	intents := make([]Operation, 0)
+	for i := 0; i < 4; i++ {
+		tx := &Transaction{Destination: src, Amount: mavryk.N(i + 1)}
+		tx.WithLimits(mavryk.Limits{GasLimit: 1420})
+		intents = append(intents, tx)
+	}
+
+	// fails without split
+	if _, err := NewBatchOptimizer(src, 41, p).WithReveal(key).Optimize(intents); err == nil {
+		t.Fatalf("expected limit error")
+	}
+
+	ops, err := NewBatchOptimizer(src, 41, p).WithReveal(key).WithSplit(true).Optimize(intents)
+	if err != nil {
+		t.Fatalf("optimize failed: %v", err)
+	}
+	if len(ops) != 2 {
+		t.Fatalf("expected 2 groups, got %d", len(ops))
+	}
+	if ops[0].Contents[0].Kind() != mavryk.OpTypeReveal {
+		t.Errorf("expected reveal at front")
+	}
+	var counter int64 = 41
+	for _, op := range ops {
+		op.WithBranch(mavryk.MustParseBlockHash("BKnYk1T5a49bb8me4WfQeugyFnMEH9h8cm6jqvL3BxRwE23EVBJ"))
+		for _, v := range op.Contents {
+			counter++
+			if v.GetCounter() != counter {
+				t.Errorf("expected counter %d, got %d", counter, v.GetCounter())
+			}
+			if v.Limits().Fee < CalculateMinFee(v, v.Limits().GasLimit, false, p) {
+				t.Errorf("fee below minimum")
+			}
+		}
+		if errs := op.Validate(); len(errs) > 0 {
+			t.Errorf("unexpected validation errors: %v", errs)
+		}
+	}
+}
+
+func TestBatchOptimizerPack(t *testing.T) {
+	src := mavryk.MustParseAddress("mv1XAPGKrKd4CRRHjTgDFLP1GEok7qXGVnvA")
+	key := mavryk.MustParseKey("edpkv45regue1bWtuHnCgLU8xWKLwa9qRqv4gimgJKro4LSc3C5VjV")
+	p := mavryk.DefaultParams.Clone()
+	p.HardGasLimitPerOperation = 5000
+	p.HardGasLimitPerBlock = 12000
+
+	candidates := func() []Operation {
+		list := make([]Operation, 0)
+		for _, gas := range []int64{4000, 1000, 3000, 2000, 2500, 1500, 4500} {
+			tx := &Transaction{Destination: src, Amount: mavryk.N(gas)}
+			tx.WithLimits(mavryk.Limits{GasLimit: gas})
+			list = append(list, tx)
+		}
+		return list
+	}
+
+	intents := candidates()
+	res, err := NewBatchOptimizer(src, 41, p).Pack(intents)
+	if err != nil {
+		t.Fatalf("pack failed: %v", err)
+	}
+	// groups may use more gas than a single operation
+	if len(res.Ops) != 1 || res.Len() != 5 || len(res.Rest) != 2 || res.Gas != 10000 {
+		t.Fatalf("unexpected packing: groups=%d ops=%d rest=%d gas=%d", len(res.Ops), res.Len(), len(res.Rest), res.Gas)
+	}
+	if res.Rest[0] != intents[0] || res.Rest[1] != intents[6] {
+		t.Errorf("unexpected rest order")
+	}
+	var counter int64 = 41
+	for _, op := range res.Ops {
+		op.WithBranch(mavryk.MustParseBlockHash("BKnYk1T5a49bb8me4WfQeugyFnMEH9h8cm6jqvL3BxRwE23EVBJ"))
+		for _, v := range op.Contents {
+			counter++
+			if v.GetCounter() != counter {
+				t.Errorf("expected counter %d, got %d", counter, v.GetCounter())
+			}
+		}
+		if errs := op.Validate(); len(errs) > 0 {
+			t.Errorf("unexpected validation errors: %v", errs)
+		}
+	}
+
+	// reveal opens the first group and counts against the budget
+	res, err = NewBatchOptimizer(src, 0, p).WithReveal(key).WithRevealLimits(mavryk.Limits{GasLimit: 1000}).Pack(candidates())
+	if err != nil {
+		t.Fatalf("pack failed: %v", err)
+	}
+	if res.Ops[0].Contents[0].Kind() != mavryk.OpTypeReveal || res.Ops[0].Contents[0].GetCounter() != 1 {
+		t.Errorf("expected reveal at front")
+	}
+	if res.Gas != 11000 || res.Len() != 6 {
+		t.Errorf("unexpected packing with reveal: ops=%d gas=%d", res.Len(), res.Gas)
+	}
+
+	// group size splits groups
+	p2 := p.Clone()
+	p2.MaxOperationDataLength = 300 // header and 3 transactions
+	res, err = NewBatchOptimizer(src, 0, p2).Pack(candidates())
+	if err != nil {
+		t.Fatalf("pack failed: %v", err)
+	}
+	if len(res.Ops) < 2 || res.Len() != 5 {
+		t.Errorf("unexpected packing with small groups: groups=%d ops=%d", len(res.Ops), res.Len())
+	}
+
+	// limit groups
+	res, err = NewBatchOptimizer(src, 0, p2).WithMaxGroups(1).Pack(candidates())
+	if err != nil {
+		t.Fatalf("pack failed: %v", err)
+	}
+	if len(res.Ops) != 1 || res.Len()+len(res.Rest) != 7 || len(res.Rest) <= 2 {
+		t.Errorf("unexpected packing with max groups: groups=%d rest=%d", len(res.Ops), len(res.Rest))
+	}
+
+	// oversized candidate
+	tx := &Transaction{Destination: src}
+	tx.WithLimits(mavryk.Limits{GasLimit: 6000})
+	if _, err := NewBatchOptimizer(src, 0, p).Pack([]Operation{tx}); err == nil {
+		t.Errorf("expected limit error")
+	}
+}
+
+func TestBatchOptimizerExplicitReveal(t *testing.T) {
+	src := mavryk.MustParseAddress("mv1XAPGKrKd4CRRHjTgDFLP1GEok7qXGVnvA")
+	key := mavryk.MustParseKey("edpkv45regue1bWtuHnCgLU8xWKLwa9qRqv4gimgJKro4LSc3C5VjV")
+	tx := &Transaction{Destination: src, Amount: mavryk.N(1)}
+	tx.WithLimits(mavryk.Limits{GasLimit: 1420})
+	reveal := &Reveal{PublicKey: key}
+	reveal.WithLimits(mavryk.Limits{GasLimit: 171})
+
+	ops, err := NewBatchOptimizer(src, 0, nil).WithReveal(key).Optimize([]Operation{tx, reveal})
+	if err != nil {
+		t.Fatalf("optimize failed: %v", err)
+	}
+	if len(ops) != 1 || len(ops[0].Contents) != 2 {
+		t.Fatalf("expected 1 group with 2 ops, got %d groups", len(ops))
+	}
+	if ops[0].Contents[0] != reveal {
+		t.Errorf("expected explicit reveal at front")
+	}
+	if have := reveal.Limits().GasLimit; have != 171 {
+		t.Errorf("explicit reveal gas mismatch, want=171 have=%d", have)
+	}
+	if ops[0].Contents[1].GetCounter() != 2 {
+		t.Errorf("expected counter 2, got %d", ops[0].Contents[1].GetCounter())
+	}
+
+	// duplicate reveals
+	if _, err := NewBatchOptimizer(src, 0, nil).Optimize([]Operation{reveal, tx, &Reveal{PublicKey: key}}); err == nil {
+		t.Errorf("expected duplicate reveal error")
+	}
+
+	// configured reveal uses reveal limits
+	ops, err = NewBatchOptimizer(src, 0, nil).WithReveal(key).WithRevealLimits(mavryk.Limits{GasLimit: 200}).Optimize([]Operation{tx})
+	if err != nil {
+		t.Fatalf("optimize failed: %v", err)
+	}
+	if have := ops[0].Contents[0].Limits().GasLimit; have != 200 {
+		t.Errorf("reveal gas mismatch, want=200 have=%d", have)
+	}
+}
+
+func TestBatchHeaderSize(t *testing.T) {
+	for _, test := range []struct {
+		src  string
+		size int
+	}{
+		{"mv1XAPGKrKd4CRRHjTgDFLP1GEok7qXGVnvA", 96},
+		{"mv4VCVPHWd9rz1zua6iGm9SG6z8BmnST9pSE", 128},
+		{"", 128},
+	} {
+		var src mavryk.Address
+		if test.src != "" {
+			src = mavryk.MustParseAddress(test.src)
+		}
+		if have := headerSize(src); have != test.size {
+			t.Errorf("%s: header size mismatch, want=%d have=%d", test.src, test.size, have)
+		}
+	}
+}
@@ -6,6 +6,8 @@ package codec
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
+	"io"
 	"strconv"
 	"time"
 
@@ -30,6 +32,7 @@ type BlockHeader struct {
 	AiVote           mavryk.FeatureVote    `json:"adaptive_issuance_vote"`
 	Signature        mavryk.Signature      `json:"signature"`
 	ChainId          *mavryk.ChainIdHash   `json:"-"` // remote signer use only
+	Watermarks       *Watermarks           `json:"-"` // optional, custom signing watermarks
 }
 
 // Bytes serializes the block header into binary form. When no signature is set, the
@@ -45,7 +48,7 @@ func (h BlockHeader) Bytes() []byte {
 // This format is only used for signing.
 func (h BlockHeader) WatermarkedBytes() []byte {
 	buf := bytes.NewBuffer(nil)
-	buf.WriteByte(TenderbakeBlockWatermark)
+	buf.WriteByte(h.Watermarks.orDefault().Block)
 	buf.Write(h.ChainId.Bytes())
 	_ = h.EncodeBuffer(buf)
 	return buf.Bytes()
@@ -81,6 +84,15 @@ func (h *BlockHeader) Sign(key mavryk.PrivateKey) error {
 	return nil
 }
 
+// Round returns the block round which is stored as last fitness element
+// in Tenderbake headers or -1 when fitness is not in Tenderbake format.
+func (h BlockHeader) Round() int {
+	if len(h.Fitness) != 5 || len(h.Fitness[4]) != 4 {
+		return -1
+	}
+	return int(enc.Uint32(h.Fitness[4]))
+}
+
 // WithChainId sets chain_id for this block to id. Use this only for remote signing
 // of blocks as it creates an invalid binary encoding otherwise.
 func (h *BlockHeader) WithChainId(id mavryk.ChainIdHash) *BlockHeader {
@@ -89,6 +101,13 @@ func (h *BlockHeader) WithChainId(id mavryk.ChainIdHash) *BlockHeader {
 	return h
 }
 
+// WithWatermarks defines custom watermark bytes used for signing. If unset,
+// defaults to DefaultWatermarks.
+func (h *BlockHeader) WithWatermarks(w *Watermarks) *BlockHeader {
+	h.Watermarks = w
+	return h
+}
+
 // WithSignature adds an externally created signature to the block header. Converts
 // any non-generic signature first. No signature validation is performed, it is
 // assumed the signature is correct.
@@ -145,7 +164,48 @@ func (h BlockHeader) MarshalJSON() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// ShellBytes returns the binary encoded shell header part which is common
+// across all protocols.
+func (h BlockHeader) ShellBytes() []byte {
+	buf := bytes.NewBuffer(nil)
+	h.encodeShell(buf)
+	return buf.Bytes()
+}
+
+// ProtocolData returns the binary encoded protocol specific header part
+// including the signature when set.
+//
+// octez-codec describe alpha.block_header.protocol_data binary schema
+// +---------------------------------------+----------+-------------------------------------+
+// | Name                                  | Size     | Contents                            |
+// +=======================================+==========+=====================================+
+// | payload_hash                          | 32 bytes | bytes                               |
+// +---------------------------------------+----------+-------------------------------------+
+// | payload_round                         | 4 bytes  | signed 32-bit integer               |
+// +---------------------------------------+----------+-------------------------------------+
+// | proof_of_work_nonce                   | 8 bytes  | bytes                               |
+// +---------------------------------------+----------+-------------------------------------+
+// | ? presence of field "seed_nonce_hash" | 1 byte   | boolean (0 for false, 255 for true) |
+// +---------------------------------------+----------+-------------------------------------+
+// | seed_nonce_hash                       | 32 bytes | bytes                               |
+// +---------------------------------------+----------+-------------------------------------+
+// | per_block_votes                       | 1 byte   | signed 8-bit integer                |
+// +---------------------------------------+----------+-------------------------------------+
+// | signature                             | Variable | bytes                               |
+// +---------------------------------------+----------+-------------------------------------+
+func (h BlockHeader) ProtocolData() []byte {
+	buf := bytes.NewBuffer(nil)
+	h.encodeProtocolData(buf)
+	return buf.Bytes()
+}
+
 func (h *BlockHeader) EncodeBuffer(buf *bytes.Buffer) error {
+	h.encodeShell(buf)
+	h.encodeProtocolData(buf)
+	return nil
+}
+
+func (h BlockHeader) encodeShell(buf *bytes.Buffer) {
 	binary.Write(buf, enc, h.Level)
 	buf.WriteByte(h.Proto)
 	buf.Write(h.Predecessor.Bytes())
@@ -162,24 +222,34 @@ func (h *BlockHeader) EncodeBuffer(buf *bytes.Buffer) error {
 		buf.Write(v)
 	}
 	buf.Write(h.Context.Bytes())
+}
+
+func (h BlockHeader) encodeProtocolData(buf *bytes.Buffer) {
 	buf.Write(h.PayloadHash.Bytes())
 	binary.Write(buf, enc, uint32(h.PayloadRound))
-	buf.Write(h.ProofOfWorkNonce)
+	var nonce [8]byte
+	copy(nonce[:], h.ProofOfWorkNonce)
+	buf.Write(nonce[:])
 	if h.SeedNonceHash.IsValid() {
 		buf.WriteByte(0xff)
 		buf.Write(h.SeedNonceHash.Bytes())
 	} else {
 		buf.WriteByte(0x0)
 	}
-	// BROKEN: merging multiple vote flags is undocumented
-	buf.WriteByte(h.LbVote.Tag() | (h.AiVote.Tag() << 2))
+	buf.WriteByte(mavryk.EncodePerBlockVotes(h.LbVote, h.AiVote))
 	if h.Signature.IsValid() {
 		buf.Write(h.Signature.Data) // raw, no tag!
 	}
-	return nil
 }
 
 func (h *BlockHeader) DecodeBuffer(buf *bytes.Buffer) (err error) {
+	if err = h.decodeShell(buf); err != nil {
+		return
+	}
+	return h.decodeProtocolData(buf)
+}
+
+func (h *BlockHeader) decodeShell(buf *bytes.Buffer) (err error) {
 	h.Level, err = readInt32(buf.Next(4))
 	if err != nil {
 		return
@@ -209,6 +279,9 @@ func (h *BlockHeader) DecodeBuffer(buf *bytes.Buffer) (err error) {
 	if err != nil {
 		return
 	}
+	if l < 0 || int(l) > buf.Len() {
+		return io.ErrShortBuffer
+	}
 	h.Fitness = make([]mavryk.HexBytes, 0)
 	for l > 0 {
 		var n int32
@@ -216,24 +289,32 @@ func (h *BlockHeader) DecodeBuffer(buf *bytes.Buffer) (err error) {
 		if err != nil {
 			return
 		}
+		if n < 0 || n > l-4 {
+			return fmt.Errorf("tezos: invalid fitness element length %d", n)
+		}
 		b := make([]byte, int(n))
 		copy(b, buf.Next(int(n)))
 		h.Fitness = append(h.Fitness, b)
 		l -= n + 4
 	}
-	if err = h.Context.UnmarshalBinary(buf.Next(32)); err != nil {
-		return
-	}
+	return h.Context.UnmarshalBinary(buf.Next(32))
+}
+
+func (h *BlockHeader) decodeProtocolData(buf *bytes.Buffer) (err error) {
 	if err = h.PayloadHash.UnmarshalBinary(buf.Next(32)); err != nil {
 		return
 	}
+	var l int32
 	l, err = readInt32(buf.Next(4))
 	if err != nil {
 		return
 	}
 	h.PayloadRound = int(l)
+	if buf.Len() < 8 {
+		return io.ErrShortBuffer
+	}
 	h.ProofOfWorkNonce = make([]byte, 8)
-	copy(h.ProofOfWorkNonce[:], buf.Next(8))
+	copy(h.ProofOfWorkNonce, buf.Next(8))
 	var ok bool
 	ok, err = readBool(buf.Next(1))
 	if err != nil {
@@ -244,19 +325,18 @@ func (h *BlockHeader) DecodeBuffer(buf *bytes.Buffer) (err error) {
 			return
 		}
 	}
-	// BROKEN: merging multiple vote flags is undocumented
-	b := buf.Next(1)
-	if len(b) > 0 {
-		if err = h.LbVote.UnmarshalBinary([]byte{b[0] & 3}); err != nil {
-			return
-		}
-		if err = h.AiVote.UnmarshalBinary([]byte{(b[0] >> 2) & 3}); err != nil {
-			return
-		}
+	var b byte
+	b, err = readByte(buf.Next(1))
+	if err != nil {
+		return
 	}
-	// conditionally read signature
+	h.LbVote, h.AiVote, err = mavryk.DecodePerBlockVotes(b)
+	if err != nil {
+		return
+	}
+	// conditionally read signature (64 bytes, 96 bytes for BLS)
 	if buf.Len() > 0 {
-		err = h.Signature.UnmarshalBinary(buf.Next(64))
+		err = h.Signature.UnmarshalBinary(buf.Next(buf.Len()))
 		if err != nil {
 			return
 		}
@@ -273,3 +353,9 @@ func (h BlockHeader) MarshalBinary() ([]byte, error) {
 func (h *BlockHeader) UnmarshalBinary(data []byte) error {
 	return h.DecodeBuffer(bytes.NewBuffer(data))
 }
+
+// UnmarshalProtocolData decodes the protocol specific header part from data
+// and leaves shell header fields untouched.
+func (h *BlockHeader) UnmarshalProtocolData(data []byte) error {
+	return h.decodeProtocolData(bytes.NewBuffer(data))
+}
@@ -6,6 +6,7 @@ package codec
 import (
 	"bytes"
 	"testing"
+	"time"
 
 	"github.com/mavryk-network/mvgo/mavryk"
 )
@@ -179,3 +180,106 @@ func TestBlock(t *testing.T) {
 		}
 	}
 }
+
+func TestBlockVotes(t *testing.T) {
+	votes := []mavryk.FeatureVote{
+		mavryk.FeatureVoteOn,
+		mavryk.FeatureVoteOff,
+		mavryk.FeatureVotePass,
+	}
+	head := BlockHeader{
+		Level:            76,
+		Proto:            1,
+		Predecessor:      mavryk.MustParseBlockHash("BLB79vHaoWiyzYjc68zXWCQFB2snCY28reHR3w6bpvKwZqkZDTE"),
+		Timestamp:        asTime("2024-01-14T13:51:47Z"),
+		ValidationPass:   4,
+		OperationsHash:   mavryk.MustParseOpListListHash("LLob7XuR6DGQ2jQPurB7AgBGNFi19WukXyuHd1ncjyXGF13qaAZFc"),
+		Fitness:          []mavryk.HexBytes{asHex("02"), asHex("0000004c"), asHex(""), asHex("ffffffff"), asHex("00000000")},
+		Context:          mavryk.MustParseContextHash("CoUhsoi3yZqpNGCW1pgu4f7eX2kzbkgKdoekLCny4WtGYyUiH96s"),
+		PayloadHash:      mavryk.MustParsePayloadHash("vh2LCpkG49XP71LxG7kVc1ob1erR3FnD3jfHjGJa8caN2N7Jn9nx"),
+		ProofOfWorkNonce: asHex("7769d51b04000000"),
+		Signature:        mavryk.MustParseSignature("sigqKNyR7Xuo8TzuMSKA5HaL9XRVmozGM1brMm2ekUSpj14HCTE9zPszEvE6Vy1WEFHhpc4m1wsff4MGkXJQcNmhbALJa7bt"),
+	}
+	for _, lb := range votes {
+		for _, ai := range votes {
+			head.LbVote, head.AiVote = lb, ai
+			buf := head.Bytes()
+			if !bytes.Equal(buf, append(head.ShellBytes(), head.ProtocolData()...)) {
+				t.Errorf("%s/%s: shell and protocol data mismatch", lb, ai)
+			}
+			var bh BlockHeader
+			if err := bh.UnmarshalBinary(buf); err != nil {
+				t.Fatalf("%s/%s: decode failed: %v", lb, ai, err)
+			}
+			if bh.LbVote != lb || bh.AiVote != ai {
+				t.Errorf("%s/%s: vote mismatch have %s/%s", lb, ai, bh.LbVote, bh.AiVote)
+			}
+			if !bytes.Equal(bh.Bytes(), buf) {
+				t.Errorf("%s/%s: round trip mismatch", lb, ai)
+			}
+		}
+	}
+
+	// reject invalid vote values
+	for _, v := range []byte{0x03, 0x0c, 0x10} {
+		pd := head.ProtocolData()
+		pd[32+4+8+1] = v
+		var bh BlockHeader
+		if err := bh.UnmarshalProtocolData(pd); err == nil {
+			t.Errorf("vote 0x%02x: expected error", v)
+		}
+	}
+}
+
+func TestDoubleBakingEvidence(t *testing.T) {
+	key := mavryk.MustParsePrivateKey("edsk2uqQB9AY4FvioK2YMdfmyMrer5R8mGFyuaLLFfSRo8EoyNdht3")
+	chain := mavryk.MustParseChainIdHash("NetXdQprcVkpaWU")
+	bh1 := BlockHeader{
+		Level:            76,
+		Proto:            1,
+		Predecessor:      mavryk.MustParseBlockHash("BLB79vHaoWiyzYjc68zXWCQFB2snCY28reHR3w6bpvKwZqkZDTE"),
+		Timestamp:        asTime("2024-01-14T13:51:47Z"),
+		ValidationPass:   4,
+		OperationsHash:   mavryk.MustParseOpListListHash("LLob7XuR6DGQ2jQPurB7AgBGNFi19WukXyuHd1ncjyXGF13qaAZFc"),
+		Fitness:          []mavryk.HexBytes{asHex("02"), asHex("0000004c"), asHex(""), asHex("ffffffff"), asHex("00000000")},
+		Context:          mavryk.MustParseContextHash("CoUhsoi3yZqpNGCW1pgu4f7eX2kzbkgKdoekLCny4WtGYyUiH96s"),
+		PayloadHash:      mavryk.MustParsePayloadHash("vh2LCpkG49XP71LxG7kVc1ob1erR3FnD3jfHjGJa8caN2N7Jn9nx"),
+		ProofOfWorkNonce: asHex("7769d51b04000000"),
+		LbVote:           mavryk.FeatureVotePass,
+		AiVote:           mavryk.FeatureVotePass,
+	}
+	bh2 := bh1
+	bh2.Timestamp = bh1.Timestamp.Add(time.Second)
+
+	if _, err := NewDoubleBakingEvidence(bh1, bh2); err == nil {
+		t.Errorf("expected error on unsigned headers")
+	}
+	if err := bh1.WithChainId(chain).Sign(key); err != nil {
+		t.Fatal(err)
+	}
+	if err := bh2.WithChainId(chain).Sign(key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewDoubleBakingEvidence(bh1, bh1); err == nil {
+		t.Errorf("expected error on identical headers")
+	}
+
+	op, err := NewDoubleBakingEvidence(bh1, bh2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, err := op.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dec DoubleBakingEvidence
+	if err := dec.UnmarshalBinary(buf); err != nil {
+		t.Fatal(err)
+	}
+	if dec.Bh1.Hash() != bh1.Hash() || dec.Bh2.Hash() != bh2.Hash() {
+		t.Errorf("header hash mismatch after round trip")
+	}
+	if err := dec.UnmarshalBinary(buf[:len(buf)-10]); err == nil {
+		t.Errorf("expected error on truncated data")
+	}
+}
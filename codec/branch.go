@@ -0,0 +1,55 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package codec
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+)
+
+// BranchResolver resolves the hash of block head~offset which is used as
+// branch for operations that define a TTL instead of an explicit branch.
+type BranchResolver interface {
+	ResolveBranch(ctx context.Context, offset int64) (mavryk.BlockHash, error)
+}
+
+// WithBranchResolver sets a resolver that is used by ResolveBranch to look up
+// the branch from TTL. Serialization and signing never resolve the branch on
+// their own, call ResolveBranch or complete the operation with an RPC client.
+func (o *Op) WithBranchResolver(r BranchResolver) *Op {
+	o.Resolver = r
+	return o
+}
+
+// ResolveBranch sets the branch to block head~N where N is derived from the
+// operation's TTL. This is a noop when a branch is already set. Fails when
+// no branch resolver is defined.
+func (o *Op) ResolveBranch(ctx context.Context) error {
+	if o.Branch.IsValid() {
+		return nil
+	}
+	if o.Resolver == nil {
+		return fmt.Errorf("tezos: missing branch")
+	}
+	p := o.Params
+	if p == nil {
+		p = mavryk.DefaultParams
+	}
+	ttl := o.TTL
+	if ttl <= 0 {
+		ttl = p.MaxOperationsTTL - 2
+	}
+	ofs := p.MaxOperationsTTL - ttl
+	if ofs < 0 {
+		ofs = 0
+	}
+	hash, err := o.Resolver.ResolveBranch(ctx, ofs)
+	if err != nil {
+		return fmt.Errorf("tezos: resolving branch: %w", err)
+	}
+	o.Branch = hash
+	return nil
+}
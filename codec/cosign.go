@@ -0,0 +1,274 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package codec
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+	"github.com/mavryk-network/mvgo/micheline"
+)
+
+// CosignState is the state of a signature collection session.
+type CosignState byte
+
+const (
+	CosignStateProposed  CosignState = iota // no signature collected yet
+	CosignStatePartial                      // some signatures, threshold not reached
+	CosignStateReady                        // threshold reached, can be finalized
+	CosignStateFinalized                    // signatures were released
+	CosignStateCancelled                    // session was abandoned
+)
+
+func (s CosignState) String() string {
+	switch s {
+	case CosignStateProposed:
+		return "proposed"
+	case CosignStatePartial:
+		return "partial"
+	case CosignStateReady:
+		return "ready"
+	case CosignStateFinalized:
+		return "finalized"
+	case CosignStateCancelled:
+		return "cancelled"
+	default:
+		return "invalid"
+	}
+}
+
+func (s CosignState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *CosignState) UnmarshalText(data []byte) error {
+	for v := CosignStateProposed; v <= CosignStateCancelled; v++ {
+		if v.String() == string(data) {
+			*s = v
+			return nil
+		}
+	}
+	return fmt.Errorf("tezos: invalid cosign state %q", string(data))
+}
+
+// IsDone returns true when the session accepts no more signatures.
+func (s CosignState) IsDone() bool {
+	return s == CosignStateFinalized || s == CosignStateCancelled
+}
+
+// CosignProposal asks a group of signers to sign the same payload, either a
+// forged operation or PACKed Michelson data. Watermarked contains the signed
+// bytes and Summary describes them for review. At least Threshold of Signers
+// must sign before the session is ready.
+type CosignProposal struct {
+	SigningRequest
+	Signers   []mavryk.Key `json:"signers"`
+	Threshold int          `json:"threshold"`
+}
+
+// PartialSignature is a detached signature by a single signer over the
+// digest of a proposal.
+type PartialSignature struct {
+	Digest    mavryk.HexBytes  `json:"digest"`
+	Signer    mavryk.Key       `json:"signer"`
+	Signature mavryk.Signature `json:"signature"`
+}
+
+// CosignSession collects detached signatures for a proposal. Sessions are
+// JSON serializable and do not depend on a transport, so coordinators can
+// persist them and exchange proposals and partial signatures by any means.
+//
+// A session moves from proposed to partial to ready as valid signatures
+// arrive and ends when it is finalized or cancelled. Sessions are not safe
+// for concurrent use.
+type CosignSession struct {
+	Proposal   CosignProposal     `json:"proposal"`
+	Signatures []PartialSignature `json:"signatures"`
+	State      CosignState        `json:"state"`
+}
+
+// NewCosignSession creates a session which collects signatures for operation
+// o from at least threshold of signers.
+func NewCosignSession(o *Op, threshold int, signers ...mavryk.Key) (*CosignSession, error) {
+	req, err := o.SigningRequest()
+	if err != nil {
+		return nil, err
+	}
+	return newCosignSession(req, threshold, signers)
+}
+
+// NewCosignPayloadSession creates a session which collects signatures for
+// Michelson data from at least threshold of signers. Signers sign the PACKed
+// data, which is what multisig contracts verify with CHECK_SIGNATURE. For the
+// generic multisig contract data is Pair(Pair(chain_id, contract), Pair(counter,
+// action)).
+func NewCosignPayloadSession(data micheline.Prim, threshold int, signers ...mavryk.Key) (*CosignSession, error) {
+	if !data.IsValid() {
+		return nil, fmt.Errorf("tezos: invalid cosign payload")
+	}
+	buf := data.Pack()
+	d := mavryk.Digest(buf)
+	req := &SigningRequest{
+		Watermarked: buf,
+		Digest:      d[:],
+		Summary:     []string{data.Dump()},
+	}
+	return newCosignSession(req, threshold, signers)
+}
+
+func newCosignSession(req *SigningRequest, threshold int, signers []mavryk.Key) (*CosignSession, error) {
+	if len(signers) == 0 {
+		return nil, fmt.Errorf("tezos: cosign session without signers")
+	}
+	if threshold <= 0 || threshold > len(signers) {
+		return nil, fmt.Errorf("tezos: invalid cosign threshold %d of %d", threshold, len(signers))
+	}
+	for i, k := range signers {
+		if !k.IsValid() {
+			return nil, fmt.Errorf("tezos: invalid cosign signer key at position %d", i)
+		}
+		for _, k2 := range signers[:i] {
+			if k.IsEqual(k2) {
+				return nil, fmt.Errorf("tezos: duplicate cosign signer %s", k.Address())
+			}
+		}
+	}
+	return &CosignSession{
+		Proposal: CosignProposal{
+			SigningRequest: *req,
+			Signers:        signers,
+			Threshold:      threshold,
+		},
+		Signatures: make([]PartialSignature, 0, threshold),
+		State:      CosignStateProposed,
+	}, nil
+}
+
+// Sign creates a partial signature over the proposal digest with key sk.
+// It fails when the digest or summary do not match the signed bytes, so a
+// coordinator cannot show signers a different payload than they sign. The
+// signature is not added to the session, signers send it back to the
+// coordinator which calls Add.
+func (p CosignProposal) Sign(sk mavryk.PrivateKey) (*PartialSignature, error) {
+	pk := sk.Public()
+	if !p.IsSigner(pk) {
+		return nil, fmt.Errorf("tezos: %s is not a cosign signer", pk.Address())
+	}
+	if err := p.Verify(); err != nil {
+		return nil, err
+	}
+	sig, err := sk.Sign(p.Digest)
+	if err != nil {
+		return nil, err
+	}
+	return &PartialSignature{
+		Digest:    p.Digest,
+		Signer:    pk,
+		Signature: sig,
+	}, nil
+}
+
+// IsSigner returns true when key k may sign the proposal.
+func (p CosignProposal) IsSigner(k mavryk.Key) bool {
+	return p.signerIndex(k) >= 0
+}
+
+func (p CosignProposal) signerIndex(k mavryk.Key) int {
+	for i, v := range p.Signers {
+		if v.IsEqual(k) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Add verifies partial signature sig and adds it to the session. Signatures
+// from unknown signers, over a different digest or by signers who already
+// signed are rejected. Signatures that arrive after the threshold was reached
+// are kept until the session is finalized.
+func (s *CosignSession) Add(sig PartialSignature) error {
+	if s.State.IsDone() {
+		return fmt.Errorf("tezos: cosign session is %s", s.State)
+	}
+	if !s.Proposal.IsSigner(sig.Signer) {
+		return fmt.Errorf("tezos: %s is not a cosign signer", sig.Signer.Address())
+	}
+	if !bytes.Equal(sig.Digest, s.Proposal.Digest) {
+		return fmt.Errorf("tezos: partial signature by %s is for a different operation", sig.Signer.Address())
+	}
+	if s.HasSigned(sig.Signer) {
+		return fmt.Errorf("tezos: duplicate signature by %s", sig.Signer.Address())
+	}
+	if err := sig.Signer.Verify(s.Proposal.Digest, sig.Signature); err != nil {
+		return fmt.Errorf("tezos: invalid signature by %s: %w", sig.Signer.Address(), err)
+	}
+	s.Signatures = append(s.Signatures, sig)
+	if len(s.Signatures) >= s.Proposal.Threshold {
+		s.State = CosignStateReady
+	} else {
+		s.State = CosignStatePartial
+	}
+	return nil
+}
+
+// HasSigned returns true when the session contains a signature by key k.
+func (s *CosignSession) HasSigned(k mavryk.Key) bool {
+	for _, v := range s.Signatures {
+		if v.Signer.IsEqual(k) {
+			return true
+		}
+	}
+	return false
+}
+
+// Missing returns the signers who have not signed yet.
+func (s *CosignSession) Missing() []mavryk.Key {
+	res := make([]mavryk.Key, 0, len(s.Proposal.Signers))
+	for _, k := range s.Proposal.Signers {
+		if !s.HasSigned(k) {
+			res = append(res, k)
+		}
+	}
+	return res
+}
+
+// IsReady returns true when the threshold is reached and the session can be
+// finalized.
+func (s *CosignSession) IsReady() bool {
+	return s.State == CosignStateReady
+}
+
+// Cancel abandons the session. Cancelled sessions accept no signatures and
+// cannot be finalized.
+func (s *CosignSession) Cancel() {
+	if s.State != CosignStateFinalized {
+		s.State = CosignStateCancelled
+	}
+}
+
+// Finalize returns the collected signatures in signer order. Signature slots
+// of signers who did not sign are empty. For payload sessions this is the
+// signature list multisig contracts expect next to the signed action.
+//
+// For operation sessions o must be the proposed operation and unchanged since
+// the session was created. The protocol only accepts a signature by the
+// operation source, so when the source signed its signature is attached to o.
+func (s *CosignSession) Finalize(o *Op) ([]mavryk.Signature, error) {
+	if s.State != CosignStateReady {
+		return nil, fmt.Errorf("tezos: cosign session is %s", s.State)
+	}
+	if o != nil && !bytes.Equal(o.Digest(), s.Proposal.Digest) {
+		return nil, fmt.Errorf("tezos: operation changed after cosign proposal was created")
+	}
+	sigs := make([]mavryk.Signature, len(s.Proposal.Signers))
+	for _, v := range s.Signatures {
+		sigs[s.Proposal.signerIndex(v.Signer)] = v.Signature
+		if o != nil && v.Signer.Address().Equal(o.Source) {
+			o.WithSignature(v.Signature)
+		}
+	}
+	s.State = CosignStateFinalized
+	return sigs, nil
+}
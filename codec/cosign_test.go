@@ -0,0 +1,175 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package codec
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+	"github.com/mavryk-network/mvgo/micheline"
+)
+
+func TestCosignSession(t *testing.T) {
+	keys := make([]mavryk.PrivateKey, 3)
+	pubs := make([]mavryk.Key, 3)
+	for i := range keys {
+		sk, err := mavryk.GenerateKey(mavryk.KeyTypeEd25519)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[i], pubs[i] = sk, sk.Public()
+	}
+	op := NewOp().
+		WithBranch(mavryk.MustParseBlockHash("BKnYk1T5a49bb8me4WfQeugyFnMEH9h8cm6jqvL3BxRwE23EVBJ")).
+		WithContents(&FailingNoop{Arbitrary: "Hello World!"})
+
+	if _, err := NewCosignSession(op, 3, pubs[0], pubs[0], pubs[1]); err == nil {
+		t.Errorf("expected duplicate signer error")
+	}
+	if _, err := NewCosignSession(op, 4, pubs...); err == nil {
+		t.Errorf("expected threshold error")
+	}
+	s, err := NewCosignSession(op, 2, pubs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// signers receive the proposal over some transport
+	buf, err := json.Marshal(s.Proposal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var prop CosignProposal
+	if err := json.Unmarshal(buf, &prop); err != nil {
+		t.Fatal(err)
+	}
+	other, _ := mavryk.GenerateKey(mavryk.KeyTypeEd25519)
+	if _, err := prop.Sign(other); err == nil {
+		t.Errorf("expected unknown signer error")
+	}
+
+	// signers reject summaries which do not describe the signed bytes
+	fake := prop
+	fake.Summary = []string{"transaction amount=1 destination=" + pubs[0].Address().String()}
+	if _, err := fake.Sign(keys[2]); err == nil {
+		t.Errorf("expected summary mismatch error")
+	}
+	sig2, err := prop.Sign(keys[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add(*sig2); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add(*sig2); err == nil {
+		t.Errorf("expected duplicate signature error")
+	}
+	if s.State != CosignStatePartial || len(s.Missing()) != 2 {
+		t.Errorf("unexpected state %s missing=%d", s.State, len(s.Missing()))
+	}
+	if _, err := s.Finalize(op); err == nil {
+		t.Errorf("expected finalize error below threshold")
+	}
+
+	// a signature by the wrong key is rejected
+	sig0, _ := prop.Sign(keys[0])
+	bad := *sig0
+	bad.Signer = pubs[1]
+	if err := s.Add(bad); err == nil {
+		t.Errorf("expected invalid signature error")
+	}
+	if err := s.Add(*sig0); err != nil {
+		t.Fatal(err)
+	}
+	if !s.IsReady() {
+		t.Fatalf("expected ready state, got %s", s.State)
+	}
+
+	// session survives a JSON roundtrip
+	buf, err = json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s2 CosignSession
+	if err := json.Unmarshal(buf, &s2); err != nil {
+		t.Fatal(err)
+	}
+	sigs, err := s2.Finalize(op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sigs) != 3 || !sigs[0].Equal(sig0.Signature) || sigs[1].IsValid() || !sigs[2].Equal(sig2.Signature) {
+		t.Errorf("unexpected signatures %v", sigs)
+	}
+	if s2.State != CosignStateFinalized {
+		t.Errorf("expected finalized state, got %s", s2.State)
+	}
+	if err := s2.Add(*sig0); err == nil {
+		t.Errorf("expected error on finalized session")
+	}
+}
+
+func TestCosignPayloadSession(t *testing.T) {
+	keys := make([]mavryk.PrivateKey, 3)
+	pubs := make([]mavryk.Key, 3)
+	for i := range keys {
+		sk, err := mavryk.GenerateKey(mavryk.KeyTypeEd25519)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[i], pubs[i] = sk, sk.Public()
+	}
+
+	// generic multisig payload Pair(Pair(chain_id, self), Pair(counter, action))
+	data := micheline.NewPair(
+		micheline.NewPair(
+			micheline.NewBytes(mavryk.Mainnet.Bytes()),
+			micheline.NewBytes(mavryk.MustParseAddress("KT1Puc9St8wdNoGtLiD2WXaHbWU7styaxYhD").EncodePadded()),
+		),
+		micheline.NewPair(micheline.NewInt64(7), micheline.NewCode(micheline.D_UNIT)),
+	)
+	s, err := NewCosignPayloadSession(data, 2, pubs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prop := s.Proposal
+
+	// signers reject tampered payloads
+	fake := prop
+	fake.Watermarked = micheline.NewInt64(8).Pack()
+	if _, err := fake.Sign(keys[0]); err == nil {
+		t.Errorf("expected digest mismatch error")
+	}
+	fake = prop
+	fake.Summary = []string{micheline.NewInt64(8).Dump()}
+	if _, err := fake.Sign(keys[0]); err == nil {
+		t.Errorf("expected summary mismatch error")
+	}
+
+	for _, i := range []int{0, 2} {
+		sig, err := prop.Sign(keys[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Add(*sig); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sigs, err := s.Finalize(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// signatures verify against the packed data like CHECK_SIGNATURE
+	d := mavryk.Digest(data.Pack())
+	if len(sigs) != 3 || sigs[1].IsValid() {
+		t.Fatalf("unexpected signatures %v", sigs)
+	}
+	for _, i := range []int{0, 2} {
+		if err := pubs[i].Verify(d[:], sigs[i]); err != nil {
+			t.Errorf("signature %d: %v", i, err)
+		}
+	}
+}
@@ -6,12 +6,20 @@ package codec
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
 	"strconv"
 
 	"github.com/mavryk-network/mvgo/mavryk"
 )
 
-// DalAttestation represents "dal_attestation" operation
+// dalAttestationVersion is the first protocol version which embeds DAL
+// content into attestations (attestation_with_dal), supports
+// dal_entrapment_evidence and drops the standalone dal_attestation operation.
+const dalAttestationVersion = 19
+
+// DalAttestation represents "dal_attestation" operation. This is the draft
+// format used before v019. Later protocols attach DAL content to attestations,
+// see TenderbakeEndorsement.
 type DalAttestation struct {
 	Simple
 	Attestor    mavryk.Address `json:"attestor"`
@@ -39,6 +47,9 @@ func (o DalAttestation) MarshalJSON() ([]byte, error) {
 }
 
 func (o DalAttestation) EncodeBuffer(buf *bytes.Buffer, p *mavryk.Params) error {
+	if p.Version >= dalAttestationVersion {
+		return fmt.Errorf("tezos: dal_attestation is unsupported since protocol v%03d, use attestation_with_dal", dalAttestationVersion)
+	}
 	buf.WriteByte(o.Kind().TagVersion(p.OperationTagsVersion))
 	buf.Write(o.Attestor.Encode())
 	buf.Write(o.Attestation.Bytes())
@@ -0,0 +1,175 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+)
+
+// Dal_entrapment_evidence (tag 24)
+// ================================
+
+// | Name                  | Size                 | Contents                |
+// +=======================+======================+=========================+
+// | Tag                   | 1 byte               | unsigned 8-bit integer  |
+// | # bytes in next field | 4 bytes              | unsigned 30-bit integer |
+// | attestation           | Variable             | $inlined_consensus_op   |
+// | consensus_slot        | 2 bytes              | unsigned 16-bit integer |
+// | slot_index            | 1 byte               | unsigned 8-bit integer  |
+// | shard_with_proof      | Determined from data | $X_0                    |
+
+// X_0
+// ***
+
+// | Name                  | Size     | Contents                |
+// +=======================+==========+=========================+
+// | index                 | 4 bytes  | signed 31-bit integer   |
+// | # bytes in next field | 4 bytes  | unsigned 30-bit integer |
+// | share                 | Variable | sequence of 32 bytes    |
+// | proof                 | 48 bytes | bytes                   |
+
+const (
+	dalShareElemSize  = 32
+	dalShardProofSize = 48
+)
+
+// DalShard is a single DAL shard.
+type DalShard struct {
+	Index int32             `json:"index"`
+	Share []mavryk.HexBytes `json:"share"`
+}
+
+// DalShardWithProof is a DAL shard together with its KZG proof.
+type DalShardWithProof struct {
+	Shard DalShard        `json:"shard"`
+	Proof mavryk.HexBytes `json:"proof"`
+}
+
+// DalEntrapmentEvidence represents "dal_entrapment_evidence" operation which
+// denounces a baker who attested a DAL slot containing a trap shard. It is
+// part of the final DAL format and requires protocol v019+ like
+// attestation_with_dal.
+type DalEntrapmentEvidence struct {
+	Simple
+	Attestation    TenderbakeInlinedEndorsement `json:"attestation"`
+	ConsensusSlot  uint16                       `json:"consensus_slot"`
+	SlotIndex      byte                         `json:"slot_index"`
+	ShardWithProof DalShardWithProof            `json:"shard_with_proof"`
+}
+
+func (o DalEntrapmentEvidence) Kind() mavryk.OpType {
+	return mavryk.OpTypeDalEntrapmentEvidence
+}
+
+func (o DalEntrapmentEvidence) MarshalJSON() ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	buf.WriteByte('{')
+	buf.WriteString(`"kind":`)
+	buf.WriteString(strconv.Quote(o.Kind().String()))
+	buf.WriteString(`,"attestation":`)
+	b, _ := o.Attestation.MarshalJSON()
+	buf.Write(b)
+	buf.WriteString(`,"consensus_slot":`)
+	buf.WriteString(strconv.Itoa(int(o.ConsensusSlot)))
+	buf.WriteString(`,"slot_index":`)
+	buf.WriteString(strconv.Itoa(int(o.SlotIndex)))
+	buf.WriteString(`,"shard_with_proof":{"shard":{"index":`)
+	buf.WriteString(strconv.Itoa(int(o.ShardWithProof.Shard.Index)))
+	buf.WriteString(`,"share":[`)
+	for i, v := range o.ShardWithProof.Shard.Share {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(strconv.Quote(v.String()))
+	}
+	buf.WriteString(`]},"proof":`)
+	buf.WriteString(strconv.Quote(o.ShardWithProof.Proof.String()))
+	buf.WriteString("}}")
+	return buf.Bytes(), nil
+}
+
+func (o DalEntrapmentEvidence) EncodeBuffer(buf *bytes.Buffer, p *mavryk.Params) error {
+	if p.Version < dalAttestationVersion {
+		return fmt.Errorf("tezos: dal_entrapment_evidence requires protocol v%03d+", dalAttestationVersion)
+	}
+	if l := len(o.ShardWithProof.Proof); l != dalShardProofSize {
+		return fmt.Errorf("tezos: invalid DAL shard proof length %d", l)
+	}
+	buf.WriteByte(o.Kind().TagVersion(p.OperationTagsVersion))
+	b2 := bytes.NewBuffer(nil)
+	if err := o.Attestation.EncodeBuffer(b2, p); err != nil {
+		return err
+	}
+	if err := writeBytesWithLen(buf, b2.Bytes()); err != nil {
+		return err
+	}
+	binary.Write(buf, enc, o.ConsensusSlot)
+	buf.WriteByte(o.SlotIndex)
+	binary.Write(buf, enc, o.ShardWithProof.Shard.Index)
+	binary.Write(buf, enc, uint32(len(o.ShardWithProof.Shard.Share)*dalShareElemSize))
+	for _, v := range o.ShardWithProof.Shard.Share {
+		if len(v) != dalShareElemSize {
+			return fmt.Errorf("tezos: invalid DAL share element length %d", len(v))
+		}
+		buf.Write(v)
+	}
+	buf.Write(o.ShardWithProof.Proof)
+	return nil
+}
+
+func (o *DalEntrapmentEvidence) DecodeBuffer(buf *bytes.Buffer, p *mavryk.Params) (err error) {
+	if err = ensureTagAndSize(buf, o.Kind(), p.OperationTagsVersion); err != nil {
+		return
+	}
+	var data mavryk.HexBytes
+	if data, err = readBytesWithLen(buf); err != nil {
+		return
+	}
+	if err = o.Attestation.DecodeBuffer(bytes.NewBuffer(data), p); err != nil {
+		return
+	}
+	var slot int16
+	if slot, err = readInt16(buf.Next(2)); err != nil {
+		return
+	}
+	o.ConsensusSlot = uint16(slot)
+	if o.SlotIndex, err = readByte(buf.Next(1)); err != nil {
+		return
+	}
+	if o.ShardWithProof.Shard.Index, err = readInt32(buf.Next(4)); err != nil {
+		return
+	}
+	var l uint32
+	if l, err = readUint32(buf.Next(4)); err != nil {
+		return
+	}
+	if l%dalShareElemSize != 0 || int(l) > buf.Len() {
+		return fmt.Errorf("tezos: invalid DAL share length %d", l)
+	}
+	o.ShardWithProof.Shard.Share = make([]mavryk.HexBytes, l/dalShareElemSize)
+	for i := range o.ShardWithProof.Shard.Share {
+		o.ShardWithProof.Shard.Share[i] = mavryk.HexBytes(append([]byte(nil), buf.Next(dalShareElemSize)...))
+	}
+	if buf.Len() < dalShardProofSize {
+		return io.ErrShortBuffer
+	}
+	o.ShardWithProof.Proof = mavryk.HexBytes(append([]byte(nil), buf.Next(dalShardProofSize)...))
+	return
+}
+
+func (o DalEntrapmentEvidence) MarshalBinary() ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	err := o.EncodeBuffer(buf, mavryk.DefaultParams)
+	return buf.Bytes(), err
+}
+
+func (o *DalEntrapmentEvidence) UnmarshalBinary(data []byte) error {
+	return o.DecodeBuffer(bytes.NewBuffer(data), mavryk.DefaultParams)
+}
@@ -6,6 +6,8 @@ package codec
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
+	"io"
 	"strconv"
 
 	"github.com/mavryk-network/mvgo/mavryk"
@@ -18,6 +20,28 @@ type DoubleBakingEvidence struct {
 	Bh2 BlockHeader `json:"bh2"`
 }
 
+// NewDoubleBakingEvidence creates a double baking evidence operation from two
+// conflicting signed block headers. Both headers must be signed and have
+// the same level and round, but a different block hash.
+func NewDoubleBakingEvidence(bh1, bh2 BlockHeader) (*DoubleBakingEvidence, error) {
+	if !bh1.Signature.IsValid() || !bh2.Signature.IsValid() {
+		return nil, fmt.Errorf("tezos: double baking evidence requires signed block headers")
+	}
+	if bh1.Level != bh2.Level {
+		return nil, fmt.Errorf("tezos: double baking evidence level mismatch %d != %d", bh1.Level, bh2.Level)
+	}
+	if r1, r2 := bh1.Round(), bh2.Round(); r1 != r2 {
+		return nil, fmt.Errorf("tezos: double baking evidence round mismatch %d != %d", r1, r2)
+	}
+	if bh1.Hash() == bh2.Hash() {
+		return nil, fmt.Errorf("tezos: double baking evidence requires different blocks")
+	}
+	return &DoubleBakingEvidence{
+		Bh1: bh1,
+		Bh2: bh2,
+	}, nil
+}
+
 func (o DoubleBakingEvidence) Kind() mavryk.OpType {
 	return mavryk.OpTypeDoubleBakingEvidence
 }
@@ -60,20 +84,18 @@ func (o *DoubleBakingEvidence) DecodeBuffer(buf *bytes.Buffer, p *mavryk.Params)
 	if err = ensureTagAndSize(buf, o.Kind(), p.OperationTagsVersion); err != nil {
 		return
 	}
-	var l int32
-	l, err = readInt32(buf.Next(4))
-	if err != nil {
-		return
-	}
-	if err = o.Bh1.DecodeBuffer(bytes.NewBuffer(buf.Next(int(l)))); err != nil {
-		return
-	}
-	l, err = readInt32(buf.Next(4))
-	if err != nil {
-		return
-	}
-	if err = o.Bh2.DecodeBuffer(bytes.NewBuffer(buf.Next(int(l)))); err != nil {
-		return
+	for _, bh := range []*BlockHeader{&o.Bh1, &o.Bh2} {
+		var l int32
+		l, err = readInt32(buf.Next(4))
+		if err != nil {
+			return
+		}
+		if l < 0 || int(l) > buf.Len() {
+			return io.ErrShortBuffer
+		}
+		if err = bh.DecodeBuffer(bytes.NewBuffer(buf.Next(int(l)))); err != nil {
+			return
+		}
 	}
 	return
 }
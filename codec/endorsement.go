@@ -6,6 +6,7 @@ package codec
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
 	"strconv"
 
 	"github.com/mavryk-network/mvgo/mavryk"
@@ -165,19 +166,31 @@ func (o *EndorsementWithSlot) UnmarshalBinary(data []byte) error {
 	return o.DecodeBuffer(bytes.NewBuffer(data), mavryk.DefaultParams)
 }
 
-// TenderbakeEndorsement represents tenderbake endorsement operation
+// TenderbakeEndorsement represents tenderbake endorsement operation. Since v019
+// attestations may carry DAL content in which case they are encoded as
+// attestation_with_dal.
 type TenderbakeEndorsement struct {
 	Simple
 	Slot             int16              `json:"slot"`
 	Level            int32              `json:"level"`
 	Round            int32              `json:"round"`
 	BlockPayloadHash mavryk.PayloadHash `json:"block_payload_hash"`
+	DalAttestation   *mavryk.Z          `json:"dal_attestation,omitempty"` // v019+
 }
 
 func (o TenderbakeEndorsement) Kind() mavryk.OpType {
+	if o.DalAttestation != nil {
+		return mavryk.OpTypeAttestationWithDal
+	}
 	return mavryk.OpTypeEndorsement
 }
 
+// WithDalAttestation sets the DAL attestation bitset of attested slots.
+func (o *TenderbakeEndorsement) WithDalAttestation(z mavryk.Z) *TenderbakeEndorsement {
+	o.DalAttestation = &z
+	return o
+}
+
 func (o TenderbakeEndorsement) MarshalJSON() ([]byte, error) {
 	buf := bytes.NewBuffer(nil)
 	buf.WriteByte('{')
@@ -191,20 +204,35 @@ func (o TenderbakeEndorsement) MarshalJSON() ([]byte, error) {
 	buf.WriteString(strconv.Itoa(int(o.Round)))
 	buf.WriteString(`,"block_payload_hash":`)
 	buf.WriteString(strconv.Quote(o.BlockPayloadHash.String()))
+	if o.DalAttestation != nil {
+		buf.WriteString(`,"dal_attestation":`)
+		buf.WriteString(strconv.Quote(o.DalAttestation.String()))
+	}
 	buf.WriteByte('}')
 	return buf.Bytes(), nil
 }
 
 func (o TenderbakeEndorsement) EncodeBuffer(buf *bytes.Buffer, p *mavryk.Params) error {
+	if o.DalAttestation != nil && p.Version < dalAttestationVersion {
+		return fmt.Errorf("tezos: attestation_with_dal requires protocol v%03d+", dalAttestationVersion)
+	}
 	buf.WriteByte(o.Kind().TagVersion(p.OperationTagsVersion))
 	binary.Write(buf, enc, o.Slot)
 	binary.Write(buf, enc, o.Level)
 	binary.Write(buf, enc, o.Round)
 	buf.Write(o.BlockPayloadHash.Bytes())
+	if o.DalAttestation != nil {
+		o.DalAttestation.EncodeBuffer(buf)
+	}
 	return nil
 }
 
 func (o *TenderbakeEndorsement) DecodeBuffer(buf *bytes.Buffer, p *mavryk.Params) (err error) {
+	// detect attestations with DAL content from tag
+	o.DalAttestation = nil
+	if b := buf.Bytes(); len(b) > 0 && b[0] == mavryk.OpTypeAttestationWithDal.TagVersion(p.OperationTagsVersion) {
+		o.DalAttestation = new(mavryk.Z)
+	}
 	if err = ensureTagAndSize(buf, o.Kind(), p.OperationTagsVersion); err != nil {
 		return
 	}
@@ -221,6 +249,12 @@ func (o *TenderbakeEndorsement) DecodeBuffer(buf *bytes.Buffer, p *mavryk.Params
 		return err
 	}
 	err = o.BlockPayloadHash.UnmarshalBinary(buf.Next(32))
+	if err != nil {
+		return err
+	}
+	if o.DalAttestation != nil {
+		err = o.DalAttestation.DecodeBuffer(buf)
+	}
 	return err
 }
 
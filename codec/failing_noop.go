@@ -6,6 +6,10 @@ package codec
 import (
 	"bytes"
 	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
 	"strconv"
 
 	"github.com/mavryk-network/mvgo/mavryk"
@@ -14,6 +18,11 @@ import (
 // FailingNoop represents "failing_noop" operations. Used for signing arbitrary messages
 // and guaranteed to be not included on-chain. This prevents an attack vector where a
 // message is crafted which looks like a regular transaction.
+//
+// Arbitrary may contain any binary data, not only UTF-8 text. Use WithData and
+// Data to work with raw byte payloads and WithPayload and Payload to embed a
+// list of length-prefixed binary fields. Like on the node, the JSON encoding
+// is always hex, also for text messages.
 type FailingNoop struct {
 	Simple
 	Arbitrary string `json:"arbitrary"`
@@ -23,21 +32,80 @@ func (o FailingNoop) Kind() mavryk.OpType {
 	return mavryk.OpTypeFailingNoop
 }
 
+// WithData sets a raw binary payload.
+func (o *FailingNoop) WithData(data []byte) *FailingNoop {
+	o.Arbitrary = string(data)
+	return o
+}
+
+// Data returns the raw binary payload.
+func (o FailingNoop) Data() []byte {
+	return []byte(o.Arbitrary)
+}
+
+// WithPayload sets a structured binary payload where each field is
+// prefixed with its length as 32-bit big-endian integer.
+func (o *FailingNoop) WithPayload(fields ...[]byte) *FailingNoop {
+	buf := bytes.NewBuffer(nil)
+	for _, v := range fields {
+		binary.Write(buf, enc, uint32(len(v)))
+		buf.Write(v)
+	}
+	o.Arbitrary = buf.String()
+	return o
+}
+
+// Payload decodes a structured binary payload created by WithPayload into
+// its list of fields.
+func (o FailingNoop) Payload() ([][]byte, error) {
+	buf := bytes.NewBufferString(o.Arbitrary)
+	fields := make([][]byte, 0)
+	for buf.Len() > 0 {
+		l, err := readUint32(buf.Next(4))
+		if err != nil {
+			return nil, err
+		}
+		if int(l) > buf.Len() {
+			return nil, io.ErrShortBuffer
+		}
+		val := make([]byte, l)
+		copy(val, buf.Next(int(l)))
+		fields = append(fields, val)
+	}
+	return fields, nil
+}
+
+// MarshalJSON hex encodes the payload like the node does. Earlier versions
+// wrote valid UTF-8 payloads as plain strings.
 func (o FailingNoop) MarshalJSON() ([]byte, error) {
 	buf := bytes.NewBuffer(nil)
 	buf.WriteByte('{')
 	buf.WriteString(`"kind":`)
 	buf.WriteString(strconv.Quote(o.Kind().String()))
 	buf.WriteString(`,"arbitrary":`)
-	buf.WriteString(strconv.Quote(o.Arbitrary))
+	buf.WriteString(strconv.Quote(hex.EncodeToString(o.Data())))
 	buf.WriteByte('}')
 	return buf.Bytes(), nil
 }
 
+func (o *FailingNoop) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Arbitrary string `json:"arbitrary"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	buf, err := hex.DecodeString(v.Arbitrary)
+	if err != nil {
+		return fmt.Errorf("tezos: invalid failing noop arbitrary: %v", err)
+	}
+	o.Arbitrary = string(buf)
+	return nil
+}
+
 func (o FailingNoop) EncodeBuffer(buf *bytes.Buffer, p *mavryk.Params) error {
 	buf.WriteByte(o.Kind().TagVersion(p.OperationTagsVersion))
-	// convert utf8 string to bytes
-	val := []byte(o.Arbitrary)
+	val := o.Data()
 	binary.Write(buf, enc, uint32(len(val)))
 	buf.Write(val)
 	return nil
@@ -51,6 +119,9 @@ func (o *FailingNoop) DecodeBuffer(buf *bytes.Buffer, p *mavryk.Params) (err err
 	if err != nil {
 		return err
 	}
+	if int(l) > buf.Len() {
+		return io.ErrShortBuffer
+	}
 	val := make([]byte, l)
 	copy(val, buf.Next(int(l)))
 	o.Arbitrary = string(val)
@@ -0,0 +1,87 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package codec
+
+import (
+	"fmt"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+)
+
+// FeeItem describes the fee paid by a single operation in a batch.
+type FeeItem struct {
+	Pos       int           // position in the batch
+	Kind      mavryk.OpType // operation type
+	Fee       int64         // fee set on the operation
+	MinFee    int64         // minimum fee for the operation without header bytes
+	HeaderFee int64         // minimum fee share for branch and signature bytes
+	Excess    int64         // fee above MinFee + HeaderFee, negative when underpaid
+}
+
+// Sponsor returns true when this operation pays for header bytes.
+func (i FeeItem) Sponsor() bool {
+	return i.HeaderFee > 0
+}
+
+// FeeReport breaks down the effective fee distribution of an operation batch.
+// Following the WithLimits convention, the first operation sponsors the fee
+// for header bytes (branch and signature).
+type FeeReport struct {
+	Items    []FeeItem // per operation fees
+	Fee      int64     // total fee set on all operations
+	MinFee   int64     // total minimum fee including header bytes
+	Overpaid int64     // fee paid above the minimum, zero if none
+	Warnings []string  // human readable notes on problematic fee distributions
+}
+
+// FeeReport returns a fee breakdown for all manager operations in the batch
+// and warns about distributions which underpay individual operations. Such
+// batches are accepted by nodes when the total fee is sufficient, but
+// WithLimits and WithMinFee raise each underpaying operation to its minimum
+// which makes the entire batch overpay.
+func (o *Op) FeeReport() *FeeReport {
+	p := o.Params
+	if p == nil {
+		p = mavryk.DefaultParams
+	}
+	r := &FeeReport{
+		Items: make([]FeeItem, 0, len(o.Contents)),
+	}
+	var deficit, surplus int64
+	for i, v := range o.Contents {
+		if v.GetCounter() < 0 {
+			continue
+		}
+		l := v.Limits()
+		item := FeeItem{
+			Pos:    i,
+			Kind:   v.Kind(),
+			Fee:    l.Fee,
+			MinFee: CalculateMinFee(v, l.GasLimit, false, p),
+		}
+		if i == 0 {
+			item.HeaderFee = CalculateMinFee(v, l.GasLimit, true, p) - item.MinFee
+		}
+		item.Excess = item.Fee - item.MinFee - item.HeaderFee
+		if item.Excess > 0 {
+			surplus += item.Excess
+		}
+		if item.Excess < 0 {
+			deficit -= item.Excess
+			r.Warnings = append(r.Warnings, fmt.Sprintf("op #%d (%s) underpays by %d, fee will be raised to %d",
+				i, item.Kind, -item.Excess, item.MinFee+item.HeaderFee))
+		}
+		r.Fee += item.Fee
+		r.MinFee += item.MinFee + item.HeaderFee
+		r.Items = append(r.Items, item)
+	}
+	if r.Fee > r.MinFee {
+		r.Overpaid = r.Fee - r.MinFee
+	}
+	if deficit > 0 && surplus > 0 {
+		r.Warnings = append(r.Warnings, fmt.Sprintf("sponsored fee %d does not count towards ops underpaying by %d, "+
+			"applying min fees will increase the total fee to %d", surplus, deficit, r.Fee+deficit))
+	}
+	return r
+}
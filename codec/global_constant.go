@@ -1,4 +1,4 @@
-// Copyright (c) 2020-2022 Blockwatch Data Inc.
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
 // Author: alex@blockwatch.cc
 
 package codec
@@ -6,6 +6,7 @@ package codec
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
 	"strconv"
 
 	"github.com/mavryk-network/mvgo/mavryk"
@@ -69,3 +70,70 @@ func (o RegisterGlobalConstant) MarshalBinary() ([]byte, error) {
 func (o *RegisterGlobalConstant) UnmarshalBinary(data []byte) error {
 	return o.DecodeBuffer(bytes.NewBuffer(data), mavryk.DefaultParams)
 }
+
+const (
+	// registerOverhead is an upper bound for the size of a register_global_constant
+	// operation without its value (tag, manager fields and value size).
+	registerOverhead = 128
+
+	// limitsMargin leaves room for fee and limits which are usually set
+	// after simulation and increase the operation size.
+	limitsMargin = 64
+)
+
+// ExtractConstants moves parts of origination scripts into global constants
+// when the operation exceeds the maximum operation data length. It returns a
+// copy of o whose scripts reference the constants and the constant values
+// which must be registered with register_global_constant operations in order
+// before the copy is sent. O itself is never modified. Returns o and no
+// constants when o fits into a single operation.
+//
+// The protocol only expands constants in script code, so operations with
+// oversized transaction parameters cannot be reduced and return an error.
+func (o *Op) ExtractConstants() (*Op, []micheline.Prim, error) {
+	p := o.Params
+	if p == nil {
+		p = mavryk.DefaultParams
+	}
+	if p.MaxOperationDataLength == 0 {
+		return o, nil, nil
+	}
+	header := headerSize(o.Source)
+	size := header + limitsMargin
+	buf := bytes.NewBuffer(nil)
+	for _, v := range o.Contents {
+		buf.Reset()
+		_ = v.EncodeBuffer(buf, p)
+		size += buf.Len()
+	}
+	excess := size - p.MaxOperationDataLength
+	if excess <= 0 {
+		return o, nil, nil
+	}
+	res := *o
+	res.Contents = make([]Operation, len(o.Contents))
+	prims := make([]*micheline.Prim, 0)
+	for i, v := range o.Contents {
+		orig, ok := v.(*Origination)
+		if !ok {
+			res.Contents[i] = v
+			continue
+		}
+		cp := *orig
+		cp.Script.Code.Param = orig.Script.Code.Param.Clone()
+		cp.Script.Code.Storage = orig.Script.Code.Storage.Clone()
+		cp.Script.Code.Code = orig.Script.Code.Code.Clone()
+		cp.Script.Code.View = orig.Script.Code.View.Clone()
+		c := &cp.Script.Code
+		prims = append(prims, &c.Param, &c.Storage, &c.Code, &c.View)
+		res.Contents[i] = &cp
+	}
+	if len(prims) == 0 {
+		return nil, nil, fmt.Errorf("tezos: operation size %d exceeds max %d and contains no script", size, p.MaxOperationDataLength)
+	}
+	consts, err := micheline.ExtractConstants(excess, p.MaxOperationDataLength-header-registerOverhead, prims...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &res, consts, nil
+}
@@ -0,0 +1,237 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package codec
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+)
+
+// LintSeverity classifies lint findings.
+type LintSeverity byte
+
+const (
+	LintInfo    LintSeverity = iota // hint, operation is likely valid
+	LintWarning                     // operation may be rejected or cost more than necessary
+	LintError                       // operation will be rejected
+)
+
+func (s LintSeverity) String() string {
+	switch s {
+	case LintInfo:
+		return "info"
+	case LintWarning:
+		return "warning"
+	case LintError:
+		return "error"
+	default:
+		return "invalid"
+	}
+}
+
+// Lint issue codes
+const (
+	LintMissingBranch    = "missing_branch"
+	LintEmptyContents    = "empty_contents"
+	LintMissingReveal    = "missing_reveal"
+	LintRevealPosition   = "reveal_position"
+	LintMixedSources     = "mixed_sources"
+	LintZeroCounter      = "zero_counter"
+	LintDuplicateCounter = "duplicate_counter"
+	LintCounterGap       = "counter_gap"
+	LintLowFee           = "low_fee"
+	LintZeroGasLimit     = "zero_gas_limit"
+	LintGasLimit         = "gas_limit"
+	LintZeroStorageLimit = "zero_storage_limit"
+	LintStorageLimit     = "storage_limit"
+	LintOversize         = "oversize"
+	LintEncoding         = "encoding"
+)
+
+// LintIssue is a single finding. Pos is the position of the offending
+// operation in the batch or -1 for issues affecting the entire operation.
+type LintIssue struct {
+	Pos      int           `json:"pos"`
+	Kind     mavryk.OpType `json:"kind"`
+	Severity LintSeverity  `json:"severity"`
+	Code     string        `json:"code"`
+	Message  string        `json:"message"`
+}
+
+func (i LintIssue) String() string {
+	if i.Pos < 0 {
+		return fmt.Sprintf("%s: %s", i.Severity, i.Message)
+	}
+	return fmt.Sprintf("%s: op #%d (%s): %s", i.Severity, i.Pos, i.Kind, i.Message)
+}
+
+// LintReport lists all findings of Op.Lint.
+type LintReport struct {
+	Issues []LintIssue `json:"issues"`
+}
+
+// HasErrors returns true when the report contains at least one error.
+func (r LintReport) HasErrors() bool {
+	for _, v := range r.Issues {
+		if v.Severity == LintError {
+			return true
+		}
+	}
+	return false
+}
+
+// Err returns an error summarizing all error findings or nil.
+func (r LintReport) Err() error {
+	msgs := make([]string, 0)
+	for _, v := range r.Issues {
+		if v.Severity == LintError {
+			msgs = append(msgs, v.String())
+		}
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("tezos: operation lint failed: %s", strings.Join(msgs, "; "))
+}
+
+func (r LintReport) String() string {
+	var b strings.Builder
+	for _, v := range r.Issues {
+		b.WriteString(v.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func (r *LintReport) add(pos int, kind mavryk.OpType, sev LintSeverity, code, format string, args ...interface{}) {
+	r.Issues = append(r.Issues, LintIssue{
+		Pos:      pos,
+		Kind:     kind,
+		Severity: sev,
+		Code:     code,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// Lint checks the operation for common mistakes before signing and returns
+// a report. Checks run against params or the operation's own params when
+// nil. Lint works offline and cannot know whether the source is revealed
+// on-chain, so a batch without reveal is reported as info only.
+func (o *Op) Lint(p *mavryk.Params) *LintReport {
+	if p == nil {
+		p = o.Params
+	}
+	if p == nil {
+		p = mavryk.DefaultParams
+	}
+	r := &LintReport{
+		Issues: make([]LintIssue, 0),
+	}
+	if !o.Branch.IsValid() && o.Resolver == nil {
+		r.add(-1, mavryk.OpTypeInvalid, LintError, LintMissingBranch, "missing branch")
+	}
+	if len(o.Contents) == 0 {
+		r.add(-1, mavryk.OpTypeInvalid, LintError, LintEmptyContents, "empty contents")
+		return r
+	}
+
+	var (
+		source      mavryk.Address
+		hasReveal   bool
+		numManager  int
+		lastCounter int64
+		counters    = make(map[int64]int)
+		size        int
+		fee, gas    int64
+	)
+	for i, v := range o.Contents {
+		kind := v.Kind()
+		buf := bytes.NewBuffer(nil)
+		if err := v.EncodeBuffer(buf, p); err != nil {
+			r.add(i, kind, LintError, LintEncoding, "encoding failed: %v", err)
+		}
+		size += buf.Len()
+		if p.MaxOperationDataLength > 0 && buf.Len() > p.MaxOperationDataLength {
+			r.add(i, kind, LintError, LintOversize, "encoded size %d exceeds max %d bytes",
+				buf.Len(), p.MaxOperationDataLength)
+		}
+
+		// skip non-manager ops
+		counter := v.GetCounter()
+		if counter < 0 {
+			continue
+		}
+
+		// reveal must be first and unique, all ops must have the same source
+		if kind == mavryk.OpTypeReveal {
+			if numManager > 0 || hasReveal {
+				r.add(i, kind, LintError, LintRevealPosition, "reveal must be the first operation in a batch")
+			}
+			hasReveal = true
+		}
+		numManager++
+		if m, ok := v.(interface{ GetSource() mavryk.Address }); ok {
+			src := m.GetSource()
+			if !source.IsValid() {
+				source = src
+			} else if src.IsValid() && !src.Equal(source) {
+				r.add(i, kind, LintError, LintMixedSources, "source %s differs from batch source %s", src, source)
+			}
+		}
+
+		// counters must be set, unique and consecutive
+		switch {
+		case counter == 0:
+			r.add(i, kind, LintError, LintZeroCounter, "counter is zero")
+		case counters[counter] > 0:
+			r.add(i, kind, LintError, LintDuplicateCounter, "counter %d already used by op #%d", counter, counters[counter]-1)
+		case lastCounter > 0 && counter != lastCounter+1:
+			r.add(i, kind, LintWarning, LintCounterGap, "counter %d does not follow %d", counter, lastCounter)
+		}
+		if counter > 0 {
+			if _, ok := counters[counter]; !ok {
+				counters[counter] = i + 1
+			}
+			lastCounter = counter
+		}
+
+		// limits
+		l := v.Limits()
+		if l.GasLimit == 0 {
+			r.add(i, kind, LintWarning, LintZeroGasLimit, "gas limit is zero")
+		} else if p.HardGasLimitPerOperation > 0 && l.GasLimit > p.HardGasLimitPerOperation {
+			r.add(i, kind, LintError, LintGasLimit, "gas limit %d exceeds max %d", l.GasLimit, p.HardGasLimitPerOperation)
+		}
+		if p.HardStorageLimitPerOperation > 0 && l.StorageLimit > p.HardStorageLimitPerOperation {
+			r.add(i, kind, LintError, LintStorageLimit, "storage limit %d exceeds max %d", l.StorageLimit, p.HardStorageLimitPerOperation)
+		}
+		if kind == mavryk.OpTypeOrigination && l.StorageLimit == 0 {
+			r.add(i, kind, LintError, LintZeroStorageLimit, "origination requires a storage limit")
+		}
+		fee += l.Fee
+		gas += l.GasLimit
+	}
+	if numManager > 0 && !hasReveal {
+		r.add(-1, mavryk.OpTypeInvalid, LintInfo, LintMissingReveal, "no reveal in batch, source must be revealed on-chain")
+	}
+	if !source.IsValid() {
+		source = o.Source
+	}
+	size += headerSize(source)
+
+	// bakers filter on the total fee of a group, not on individual operations
+	if numManager > 0 {
+		minFee := (minFeeFixedNanoMav + int64(size)*minFeeByteNanoMav + gas*minFeeGasNanoMav + 999) / 1000
+		if fee < minFee {
+			r.add(-1, mavryk.OpTypeInvalid, LintError, LintLowFee, "total fee %d below minimum %d", fee, minFee)
+		}
+	}
+	if p.MaxOperationDataLength > 0 && size > p.MaxOperationDataLength {
+		r.add(-1, mavryk.OpTypeInvalid, LintError, LintOversize, "operation size %d exceeds max %d bytes", size, p.MaxOperationDataLength)
+	}
+	return r
+}
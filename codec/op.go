@@ -7,6 +7,7 @@ import (
 	"bytes"
 	"encoding"
 	"encoding/binary"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"strconv"
@@ -50,13 +51,16 @@ type Operation interface {
 // operations, but is agnostic to the order/lifecycle in which data is added
 // or updated.
 type Op struct {
-	Branch    mavryk.BlockHash    `json:"branch"`    // used for TTL handling
-	Contents  []Operation         `json:"contents"`  // non-zero list of transactions
-	Signature mavryk.Signature    `json:"signature"` // added during the lifecycle
-	ChainId   *mavryk.ChainIdHash `json:"-"`         // optional, used for remote signing only
-	TTL       int64               `json:"-"`         // optional, specify TTL in blocks
-	Params    *mavryk.Params      `json:"-"`         // optional, define protocol to encode for
-	Source    mavryk.Address      `json:"-"`         // optional, used as manager/sender
+	Branch     mavryk.BlockHash    `json:"branch"`    // used for TTL handling
+	Contents   []Operation         `json:"contents"`  // non-zero list of transactions
+	Signature  mavryk.Signature    `json:"signature"` // added during the lifecycle
+	ChainId    *mavryk.ChainIdHash `json:"-"`         // optional, used for remote signing only
+	TTL        int64               `json:"-"`         // optional, specify TTL in blocks
+	Params     *mavryk.Params      `json:"-"`         // optional, define protocol to encode for
+	Source     mavryk.Address      `json:"-"`         // optional, used as manager/sender
+	Watermarks *Watermarks         `json:"-"`         // optional, custom signing watermarks
+	Resolver   BranchResolver      `json:"-"`         // optional, used to resolve branch from TTL
+	PairMode   micheline.PairMode  `json:"-"`         // optional, pair encoding for call params
 }
 
 // NewOp creates a new empty operation that uses default params and a
@@ -86,6 +90,27 @@ func (o *Op) WithParams(p *mavryk.Params) *Op {
 	return o
 }
 
+// WithWatermarks defines custom watermark bytes used for signing. If unset,
+// defaults to DefaultWatermarks.
+func (o *Op) WithWatermarks(w *Watermarks) *Op {
+	o.Watermarks = w
+	return o
+}
+
+// WithPairMode defines how Pair values in contract call parameters are encoded.
+// Parameters of already added and all future calls are normalized to mode.
+// Defaults to micheline.PairModeAsIs which keeps parameters unchanged.
+func (o *Op) WithPairMode(mode micheline.PairMode) *Op {
+	o.PairMode = mode
+	for _, v := range o.Contents {
+		if tx, ok := v.(*Transaction); ok && tx.Parameters != nil {
+			params := tx.Parameters.NormalizePairs(mode)
+			tx.Parameters = &params
+		}
+	}
+	return o
+}
+
 // WithContents adds a Tezos operation to the end of the contents list.
 func (o *Op) WithContents(op Operation) *Op {
 	o.Contents = append(o.Contents, op)
@@ -125,6 +150,7 @@ func (o *Op) WithTransfer(to mavryk.Address, amount int64) *Op {
 // WithCall adds a contract call transaction to the contents list.
 // Source must be defined via WithSource() before calling this function.
 func (o *Op) WithCall(to mavryk.Address, params micheline.Parameters) *Op {
+	params = params.NormalizePairs(o.PairMode)
 	o.Contents = append(o.Contents, &Transaction{
 		Manager: Manager{
 			Source:  o.Source,
@@ -139,6 +165,7 @@ func (o *Op) WithCall(to mavryk.Address, params micheline.Parameters) *Op {
 // WithCallExt adds a contract call with value transfer transaction to the contents list.
 // Source must be defined via WithSource() before calling this function.
 func (o *Op) WithCallExt(to mavryk.Address, params micheline.Parameters, amount int64) *Op {
+	params = params.NormalizePairs(o.PairMode)
 	o.Contents = append(o.Contents, &Transaction{
 		Manager: Manager{
 			Source:  o.Source,
@@ -306,10 +333,28 @@ func (o *Op) WithRegisterConstant(value micheline.Prim) *Op {
 	return o
 }
 
+// WithRefutation adds a smart rollup refutation game move against opponent to the
+// contents list. Use NewRefutationStart, NewRefutationDissection and
+// NewRefutationProof to construct moves.
+// Source must be defined via WithSource() before calling this function.
+func (o *Op) WithRefutation(rollup, opponent mavryk.Address, r SmartRollupRefutation) *Op {
+	o.Contents = append(o.Contents, &SmartRollupRefute{
+		Manager: Manager{
+			Source:  o.Source,
+			Counter: 0,
+		},
+		Rollup:     rollup,
+		Opponent:   opponent,
+		Refutation: r,
+	})
+	return o
+}
+
 // WithTTL sets a time-to-live for the operation in number of blocks. This may be
 // used as a convenience method instead of setting a branch directly, but requires
 // to use an autocomplete handler, wallet or custom function that fetches the hash
-// of block head~N as branch. Note that serialization will fail until a brach is set.
+// of block head~N as branch. Note that serialization will fail until a brach is set,
+// e.g. by calling ResolveBranch with a resolver defined by WithBranchResolver.
 func (o *Op) WithTTL(n int64) *Op {
 	if n > o.Params.MaxOperationsTTL {
 		n = o.Params.MaxOperationsTTL - 2 // Ithaca adjusted
@@ -443,24 +488,25 @@ func (o *Op) WatermarkedBytes() []byte {
 	if p == nil {
 		p = mavryk.DefaultParams
 	}
+	w := o.Watermarks.orDefault()
 	buf := bytes.NewBuffer(nil)
 	switch o.Contents[0].Kind() {
-	case mavryk.OpTypeEndorsement, mavryk.OpTypeEndorsementWithSlot:
+	case mavryk.OpTypeEndorsement, mavryk.OpTypeEndorsementWithSlot, mavryk.OpTypeAttestationWithDal:
 		if p.OperationTagsVersion < 2 {
 			buf.WriteByte(EmmyEndorsementWatermark)
 		} else {
-			buf.WriteByte(TenderbakeEndorsementWatermark)
+			buf.WriteByte(w.Endorsement)
 		}
 		if o.ChainId != nil {
 			buf.Write(o.ChainId.Bytes())
 		}
 	case mavryk.OpTypePreendorsement:
-		buf.WriteByte(TenderbakePreendorsementWatermark)
+		buf.WriteByte(w.Preendorsement)
 		if o.ChainId != nil {
 			buf.Write(o.ChainId.Bytes())
 		}
 	default:
-		buf.WriteByte(OperationWatermark)
+		buf.WriteByte(w.Operation)
 	}
 	buf.Write(o.Branch.Bytes())
 	for _, v := range o.Contents {
@@ -543,6 +589,87 @@ func (o *Op) MarshalJSON() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// RPCOp is a JSON marshaling mode for operations which produces the exact shape
+// expected by node RPC endpoints such as helpers/scripts/run_operation and
+// helpers/preapply/operations. Unsigned operations receive a zero signature
+// placeholder. Preapply requests additionally require the protocol hash.
+type RPCOp struct {
+	Op           *Op
+	WithProtocol bool
+}
+
+// RPC returns the operation in run_operation/simulate_operation JSON format.
+func (o *Op) RPC() RPCOp {
+	return RPCOp{Op: o}
+}
+
+// Preapply returns the operation in helpers/preapply/operations JSON format.
+// The protocol hash is taken from the operation's params.
+func (o *Op) Preapply() RPCOp {
+	return RPCOp{Op: o, WithProtocol: true}
+}
+
+func (r RPCOp) MarshalJSON() ([]byte, error) {
+	o := r.Op
+	if o == nil {
+		return []byte("null"), nil
+	}
+	buf := bytes.NewBuffer(nil)
+	buf.WriteByte('{')
+	if r.WithProtocol {
+		if o.Params == nil || !o.Params.Protocol.IsValid() {
+			return nil, fmt.Errorf("tezos: missing protocol for preapply")
+		}
+		buf.WriteString(`"protocol":`)
+		buf.WriteString(strconv.Quote(o.Params.Protocol.String()))
+		buf.WriteByte(',')
+	}
+	buf.WriteString(`"branch":`)
+	buf.WriteString(strconv.Quote(o.Branch.String()))
+	buf.WriteString(`,"contents":[`)
+	for i, op := range o.Contents {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		b, err := op.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(b)
+	}
+	buf.WriteByte(']')
+	if len(o.Contents) == 0 || o.Contents[0].Kind() != mavryk.OpTypeEndorsementWithSlot {
+		sig := o.Signature
+		if !sig.IsValid() {
+			sig = mavryk.ZeroSignature
+		}
+		buf.WriteString(`,"signature":`)
+		buf.WriteString(strconv.Quote(sig.String()))
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// MarshalHex serializes the operation into its hex encoded binary form. Fails
+// when branch or contents are empty.
+func (o *Op) MarshalHex() (string, error) {
+	buf := o.Bytes()
+	if buf == nil {
+		return "", fmt.Errorf("tezos: missing branch or empty operation contents")
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// DecodeOpHex decodes an operation from its hex encoded binary representation.
+// The encoded data may or may not contain a signature.
+func DecodeOpHex(s string) (*Op, error) {
+	buf, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("tezos: decoding hex operation: %v", err)
+	}
+	return DecodeOp(buf)
+}
+
 // DecodeOp decodes an operation from its binary representation. The encoded
 // data may or may not contain a signature.
 func DecodeOp(data []byte) (*Op, error) {
@@ -560,6 +687,7 @@ func DecodeOp(data []byte) (*Op, error) {
 	if err := o.Branch.UnmarshalBinary(buf.Next(32)); err != nil {
 		return nil, err
 	}
+loop:
 	for buf.Len() > 0 {
 		var op Operation
 		tag, _ := buf.ReadByte()
@@ -571,6 +699,8 @@ func DecodeOp(data []byte) (*Op, error) {
 			} else {
 				op = new(TenderbakeEndorsement)
 			}
+		case mavryk.OpTypeAttestationWithDal:
+			op = new(TenderbakeEndorsement)
 		case mavryk.OpTypePreendorsement:
 			op = new(TenderbakePreendorsement)
 		case mavryk.OpTypeEndorsementWithSlot:
@@ -625,9 +755,8 @@ func DecodeOp(data []byte) (*Op, error) {
 			op = new(SmartRollupCement)
 		case mavryk.OpTypeSmartRollupPublish:
 			op = new(SmartRollupPublish)
-		// TODO
-		// case mavryk.OpTypeSmartRollupRefute:
-		// 	op = new(SmartRollupRefute)
+		case mavryk.OpTypeSmartRollupRefute:
+			op = new(SmartRollupRefute)
 		case mavryk.OpTypeSmartRollupTimeout:
 			op = new(SmartRollupTimeout)
 		case mavryk.OpTypeSmartRollupExecuteOutboxMessage:
@@ -638,16 +767,28 @@ func DecodeOp(data []byte) (*Op, error) {
 			op = new(DalAttestation)
 		case mavryk.OpTypeDalPublishSlotHeader:
 			op = new(DalPublishSlotHeader)
+		case mavryk.OpTypeDalEntrapmentEvidence:
+			op = new(DalEntrapmentEvidence)
 
 		default:
 			// stop if rest looks like a signature
 			// FIXME: BLS sigs are 96 bytes, but accepting this here will
 			// collide with detecting valid operation types in a batch
 			if buf.Len() == 64 {
-				break
+				break loop
 			}
 			return nil, fmt.Errorf("tezos: unsupported operation tag %d", tag)
 		}
+		// the last 64 bytes may be a signature which starts with a valid tag
+		if buf.Len() == 64 {
+			probe := bytes.NewBuffer(buf.Bytes())
+			if op.DecodeBuffer(probe, mavryk.DefaultParams) != nil || probe.Len() > 0 {
+				break
+			}
+			buf.Next(64)
+			o.Contents = append(o.Contents, op)
+			continue
+		}
 		if err := op.DecodeBuffer(buf, mavryk.DefaultParams); err != nil {
 			return nil, err
 		}
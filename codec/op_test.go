@@ -5,9 +5,12 @@ package codec
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"regexp"
+	"strings"
 	"testing"
 	"time"
 
@@ -535,3 +538,805 @@ func TestOp(t *testing.T) {
 		}
 	}
 }
+
+func TestOpValidate(t *testing.T) {
+	src := mavryk.MustParseAddress("mv1XAPGKrKd4CRRHjTgDFLP1GEok7qXGVnvA")
+	op := NewOp().
+		WithBranch(mavryk.MustParseBlockHash("BKnYk1T5a49bb8me4WfQeugyFnMEH9h8cm6jqvL3BxRwE23EVBJ")).
+		WithSource(src).
+		WithTransfer(src, 1).
+		WithTransfer(src, 2)
+	op.Contents[0].WithCounter(10)
+	op.Contents[1].WithCounter(11)
+	op.Contents[0].WithLimits(mavryk.Limits{Fee: 1000, GasLimit: 1420})
+	op.Contents[1].WithLimits(mavryk.Limits{Fee: 1000, GasLimit: 1420})
+	if errs := op.Validate(); len(errs) > 0 {
+		t.Fatalf("unexpected validation errors: %v", errs)
+	}
+
+	// hex round trip
+	s, err := op.MarshalHex()
+	if err != nil {
+		t.Fatalf("hex encode failed: %v", err)
+	}
+	o2, err := DecodeOpHex(s)
+	if err != nil {
+		t.Fatalf("hex decode failed: %v", err)
+	}
+	if !bytes.Equal(o2.Bytes(), op.Bytes()) {
+		t.Errorf("hex round trip mismatch")
+	}
+
+	// broken counter sequence and gas limit
+	op.Contents[1].WithCounter(13)
+	op.Contents[1].WithLimits(mavryk.Limits{GasLimit: mavryk.DefaultParams.HardGasLimitPerOperation + 1})
+	if errs := op.Validate(); len(errs) != 2 {
+		t.Errorf("expected 2 validation errors, got %d: %v", len(errs), errs)
+	}
+}
+
+func TestFailingNoopPayload(t *testing.T) {
+	fields := [][]byte{{0x05, 0x01}, {}, []byte("Tezos Signed Message")}
+	op := new(FailingNoop).WithPayload(fields...)
+	buf, err := op.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dec FailingNoop
+	if err := dec.UnmarshalBinary(buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dec.Data(), op.Data()) {
+		t.Fatalf("data mismatch have=%x want=%x", dec.Data(), op.Data())
+	}
+	have, err := dec.Payload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(have) != len(fields) {
+		t.Fatalf("field count mismatch have=%d want=%d", len(have), len(fields))
+	}
+	for i := range fields {
+		if !bytes.Equal(have[i], fields[i]) {
+			t.Errorf("field %d mismatch have=%x want=%x", i, have[i], fields[i])
+		}
+	}
+	if _, err := new(FailingNoop).WithData([]byte{0, 0, 0, 9, 1}).Payload(); err == nil {
+		t.Errorf("expected error on truncated payload")
+	}
+}
+
+func TestFailingNoopJSON(t *testing.T) {
+	schema, err := OperationSchema(mavryk.OpTypeFailingNoop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range [][]byte{[]byte("Hello World!"), {0xff, 0x00, 0x05}} {
+		op := new(FailingNoop).WithData(data)
+		buf, err := json.Marshal(op)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := `"arbitrary":"` + hex.EncodeToString(data) + `"`; !strings.Contains(string(buf), want) {
+			t.Errorf("expected hex payload in %s", buf)
+		}
+		var v interface{}
+		if err := json.Unmarshal(buf, &v); err != nil {
+			t.Fatal(err)
+		}
+		if err := validateSchema(schema, schema, v, "failing_noop"); err != nil {
+			t.Error(err)
+		}
+		var dec FailingNoop
+		if err := json.Unmarshal(buf, &dec); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(dec.Data(), data) {
+			t.Errorf("data mismatch have=%x want=%x", dec.Data(), data)
+		}
+	}
+}
+
+func TestOpWatermarks(t *testing.T) {
+	op := NewOp().
+		WithBranch(mavryk.MustParseBlockHash("BKnYk1T5a49bb8me4WfQeugyFnMEH9h8cm6jqvL3BxRwE23EVBJ")).
+		WithContents(&FailingNoop{Arbitrary: "Hello World!"})
+	if have := op.WatermarkedBytes()[0]; have != OperationWatermark {
+		t.Errorf("default watermark mismatch have=%x want=%x", have, OperationWatermark)
+	}
+	w := DefaultWatermarks
+	w.Operation = 0x83
+	op.WithWatermarks(&w)
+	buf := op.WatermarkedBytes()
+	if buf[0] != 0x83 {
+		t.Errorf("custom watermark mismatch have=%x want=%x", buf[0], 0x83)
+	}
+	if !bytes.Equal(buf[1:], op.Bytes()) {
+		t.Errorf("watermarked payload mismatch")
+	}
+}
+
+type testBranchResolver struct {
+	offset int64
+}
+
+func (r *testBranchResolver) ResolveBranch(_ context.Context, offset int64) (mavryk.BlockHash, error) {
+	r.offset = offset
+	return mavryk.MustParseBlockHash("BKnYk1T5a49bb8me4WfQeugyFnMEH9h8cm6jqvL3BxRwE23EVBJ"), nil
+}
+
+func TestOpBranchResolver(t *testing.T) {
+	op := NewOp().WithContents(&FailingNoop{Arbitrary: "Hello World!"}).WithTTL(10)
+	if op.Bytes() != nil {
+		t.Fatalf("expected nil bytes without branch")
+	}
+	r := &testBranchResolver{}
+	op.WithBranchResolver(r)
+	if op.Bytes() != nil {
+		t.Fatalf("expected serialization not to resolve branch")
+	}
+	if err := op.ResolveBranch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if op.Bytes() == nil {
+		t.Fatalf("expected branch to be resolved")
+	}
+	if have, want := r.offset, op.Params.MaxOperationsTTL-10; have != want {
+		t.Errorf("offset mismatch have=%d want=%d", have, want)
+	}
+}
+
+func TestOpFeeReport(t *testing.T) {
+	src := mavryk.MustParseAddress("mv1XAPGKrKd4CRRHjTgDFLP1GEok7qXGVnvA")
+	op := NewOp().
+		WithBranch(mavryk.MustParseBlockHash("BKnYk1T5a49bb8me4WfQeugyFnMEH9h8cm6jqvL3BxRwE23EVBJ")).
+		WithSource(src).
+		WithTransfer(src, 1).
+		WithTransfer(src, 2)
+	for i, v := range op.Contents {
+		v.WithCounter(int64(i + 1))
+	}
+	op.WithLimits([]mavryk.Limits{{GasLimit: 1000}, {GasLimit: 1000}}, 0)
+	r := op.FeeReport()
+	if len(r.Items) != 2 || len(r.Warnings) != 0 || r.Overpaid != 0 || r.Fee != r.MinFee {
+		t.Fatalf("unexpected report for min fee batch: %#v", r)
+	}
+	if !r.Items[0].Sponsor() || r.Items[1].Sponsor() {
+		t.Errorf("expected first op to sponsor header fee")
+	}
+
+	// first op pays for all, second pays nothing
+	op.Contents[0].WithLimits(mavryk.Limits{GasLimit: 1000, Fee: r.Fee})
+	op.Contents[1].WithLimits(mavryk.Limits{GasLimit: 1000})
+	r = op.FeeReport()
+	if r.Items[1].Excess >= 0 || len(r.Warnings) != 2 {
+		t.Errorf("expected underpayment warnings, got %v", r.Warnings)
+	}
+}
+
+func TestUpdateConsensusKeyRoundTrip(t *testing.T) {
+	p := mavryk.DefaultParams
+	op := &UpdateConsensusKey{
+		Manager: Manager{
+			Source:  mavryk.MustParseAddress("mv1XAPGKrKd4CRRHjTgDFLP1GEok7qXGVnvA"),
+			Counter: 1,
+		},
+		PublicKey: mavryk.NewKey(mavryk.KeyTypeBls12_381, bytes.Repeat([]byte{0x01}, 48)),
+	}
+	buf := bytes.NewBuffer(nil)
+	if err := op.EncodeBuffer(buf, p); err != nil {
+		t.Fatal(err)
+	}
+	var dec UpdateConsensusKey
+	if err := dec.DecodeBuffer(bytes.NewBuffer(buf.Bytes()), p); err != nil {
+		t.Fatal(err)
+	}
+	if !dec.PublicKey.IsEqual(op.PublicKey) || !dec.Source.Equal(op.Source) || dec.Counter != op.Counter {
+		t.Errorf("round trip mismatch have=%s want=%s", dec.PublicKey, op.PublicKey)
+	}
+	have, err := json.Marshal(dec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := json.Marshal(op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(have, want) {
+		t.Errorf("json mismatch have=%s want=%s", have, want)
+	}
+}
+
+func TestUpdateConsensusKeyProof(t *testing.T) {
+	p := mavryk.DefaultParams.Clone()
+	p.Version = consensusKeyProofVersion
+	op := &UpdateConsensusKey{
+		Manager: Manager{
+			Source:  mavryk.MustParseAddress("mv1XAPGKrKd4CRRHjTgDFLP1GEok7qXGVnvA"),
+			Counter: 1,
+		},
+		PublicKey: mavryk.NewKey(mavryk.KeyTypeBls12_381, bytes.Repeat([]byte{0x01}, 48)),
+	}
+	op.WithProof(mavryk.Signature{Data: bytes.Repeat([]byte{0x02}, 96)})
+	buf := bytes.NewBuffer(nil)
+	if err := op.EncodeBuffer(buf, p); err != nil {
+		t.Fatal(err)
+	}
+	var dec UpdateConsensusKey
+	if err := dec.DecodeBuffer(bytes.NewBuffer(buf.Bytes()), p); err != nil {
+		t.Fatal(err)
+	}
+	if !dec.Proof.Equal(op.Proof) || !dec.PublicKey.IsEqual(op.PublicKey) {
+		t.Errorf("round trip mismatch have=%s want=%s", dec.Proof, op.Proof)
+	}
+
+	// operations without proof encode an absent flag
+	noproof := *op
+	noproof.Proof = mavryk.Signature{}
+	buf.Reset()
+	if err := noproof.EncodeBuffer(buf, p); err != nil {
+		t.Fatal(err)
+	}
+	dec = UpdateConsensusKey{}
+	if err := dec.DecodeBuffer(buf, p); err != nil || dec.Proof.IsValid() || buf.Len() > 0 {
+		t.Errorf("unexpected proof decode: %v", err)
+	}
+
+	// older protocols can't encode proofs
+	if err := op.EncodeBuffer(bytes.NewBuffer(nil), mavryk.DefaultParams); err == nil {
+		t.Error("expected error for proof on old protocol")
+	}
+
+	// proofs require BLS keys
+	op.PublicKey = mavryk.MustParseKey("edpkv45regue1bWtuHnCgLU8xWKLwa9qRqv4gimgJKro4LSc3C5VjV")
+	if err := op.EncodeBuffer(bytes.NewBuffer(nil), p); err == nil {
+		t.Error("expected error for proof with non-BLS key")
+	}
+}
+
+func TestOpSigningRequest(t *testing.T) {
+	sk := mavryk.MustParsePrivateKey("edsk4FTF78Qf1m2rykGpHqostAiq5gYW4YZEoGUSWBTJr2njsDHSnd")
+	op := NewOp().
+		WithBranch(mavryk.MustParseBlockHash("BKnYk1T5a49bb8me4WfQeugyFnMEH9h8cm6jqvL3BxRwE23EVBJ")).
+		WithSource(sk.Address()).
+		WithTransfer(sk.Address(), 1)
+	req, err := op.SigningRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(req.Summary) != 1 {
+		t.Fatalf("summary mismatch: %v", req.Summary)
+	}
+
+	// sign offline
+	sig, err := sk.Sign(req.Digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// wrong key
+	other, _ := mavryk.GenerateKey(mavryk.KeyTypeEd25519)
+	if err := op.ImportSignature(req, sig, other.Public()); err == nil {
+		t.Errorf("expected error on key mismatch")
+	}
+
+	// summary must describe the signed bytes
+	fake := *req
+	fake.Summary = []string{"transaction amount=0.000001 destination=" + other.Address().String()}
+	if err := fake.Verify(); err == nil {
+		t.Errorf("expected error on summary mismatch")
+	}
+	if err := op.ImportSignature(&fake, sig, sk.Public()); err == nil {
+		t.Errorf("expected import error on summary mismatch")
+	}
+	if err := req.Verify(); err != nil {
+		t.Errorf("verify: %v", err)
+	}
+
+	// changed operation
+	op.WithTransfer(sk.Address(), 2)
+	if err := op.ImportSignature(req, sig, sk.Public()); err == nil {
+		t.Errorf("expected error on changed operation")
+	}
+	op.Contents = op.Contents[:1]
+
+	if err := op.ImportSignature(req, sig, sk.Public()); err != nil {
+		t.Fatal(err)
+	}
+	if !op.Signature.Equal(sig) {
+		t.Errorf("signature not attached")
+	}
+}
+
+func TestOpPairMode(t *testing.T) {
+	src := mavryk.MustParseAddress("mv1XAPGKrKd4CRRHjTgDFLP1GEok7qXGVnvA")
+	nested := micheline.NewPair(micheline.NewInt64(1), micheline.NewPair(micheline.NewInt64(2), micheline.NewInt64(3)))
+	params := micheline.Parameters{Entrypoint: "default", Value: nested}
+
+	op := NewOp().WithSource(src).WithPairMode(micheline.PairModeComb).WithCall(src, params)
+	comb := op.Contents[0].(*Transaction).Parameters.Value
+	if comb.Type != micheline.PrimVariadicAnno || len(comb.Args) != 3 {
+		t.Fatalf("expected comb pair, got %s", comb.Dump())
+	}
+
+	op.WithPairMode(micheline.PairModeNested)
+	res := op.Contents[0].(*Transaction).Parameters.Value
+	buf1, _ := res.MarshalBinary()
+	buf2, _ := nested.MarshalBinary()
+	if !bytes.Equal(buf1, buf2) {
+		t.Errorf("nested mismatch: got %x, want %x", buf1, buf2)
+	}
+}
+
+func TestSmartRollupRefutation(t *testing.T) {
+	src := mavryk.MustParseAddress("mv1XAPGKrKd4CRRHjTgDFLP1GEok7qXGVnvA")
+	opp := mavryk.MustParseAddress("mv1949pcbqwGsHfUCaVmNVRu21Cd4SnbpvpP")
+	rollup := mavryk.MustParseAddress("sr1Fq8fPi2NjhWUXtcXBggbL6zFjZctGkmso")
+	hash := func(b byte) []byte { return bytes.Repeat([]byte{b}, 32) }
+
+	dissection, err := NewRefutationDissection(mavryk.NewZ(0), []SmartRollupTick{
+		{State: mavryk.NewSmartRollupStateHash(hash(3)), Tick: mavryk.NewZ(0)},
+		{State: mavryk.NewSmartRollupStateHash(hash(4)), Tick: mavryk.NewZ(100)},
+		{Tick: mavryk.NewZ(200)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewRefutationDissection(mavryk.NewZ(0), dissection.Step.Ticks[1:2]); err == nil {
+		t.Errorf("expected error for short dissection")
+	}
+
+	moves := []SmartRollupRefutation{
+		NewRefutationStart(mavryk.NewSmartRollupCommitHash(hash(1)), mavryk.NewSmartRollupCommitHash(hash(2))),
+		dissection,
+		NewRefutationProof(mavryk.NewZ(42), []byte{0xa, 0xb}, nil),
+		NewRefutationProof(mavryk.NewZ(42), []byte{0xa}, NewInboxInputProof(10, 2, []byte{0xc})),
+		NewRefutationProof(mavryk.NewZ(42), []byte{0xa}, NewRawDataInputProof([]byte{0xd})),
+		NewRefutationProof(mavryk.NewZ(42), []byte{0xa}, NewMetadataInputProof()),
+		NewRefutationProof(mavryk.NewZ(42), []byte{0xa}, NewFirstInputProof()),
+	}
+	for i, move := range moves {
+		op := NewOp().
+			WithBranch(mavryk.MustParseBlockHash("BKnYk1T5a49bb8me4WfQeugyFnMEH9h8cm6jqvL3BxRwE23EVBJ")).
+			WithSource(src).
+			WithRefutation(rollup, opp, move)
+		op.Contents[0].WithCounter(1)
+		buf := op.Bytes()
+		op2, err := DecodeOp(buf)
+		if err != nil {
+			t.Fatalf("move %d: decode: %v", i, err)
+		}
+		if !bytes.Equal(buf, op2.Bytes()) {
+			t.Errorf("move %d: binary roundtrip mismatch", i)
+		}
+		js1, err := json.Marshal(op)
+		if err != nil {
+			t.Fatalf("move %d: json: %v", i, err)
+		}
+		js2, _ := json.Marshal(op2)
+		if !bytes.Equal(js1, js2) {
+			t.Errorf("move %d: json mismatch\n%s\n%s", i, js1, js2)
+		}
+	}
+}
+
+func TestDalOperations(t *testing.T) {
+	branch := mavryk.MustParseBlockHash("BKnYk1T5a49bb8me4WfQeugyFnMEH9h8cm6jqvL3BxRwE23EVBJ")
+	att := &TenderbakeEndorsement{Slot: 1, Level: 100, Round: 2}
+	att.WithDalAttestation(mavryk.NewZ(5))
+	if att.Kind() != mavryk.OpTypeAttestationWithDal {
+		t.Fatalf("unexpected kind %s", att.Kind())
+	}
+
+	ev := &DalEntrapmentEvidence{
+		Attestation: TenderbakeInlinedEndorsement{
+			Branch:      branch,
+			Endorsement: *att,
+			Signature:   mavryk.NewSignature(mavryk.SignatureTypeGeneric, bytes.Repeat([]byte{1}, 64)),
+		},
+		ConsensusSlot: 7,
+		SlotIndex:     3,
+		ShardWithProof: DalShardWithProof{
+			Shard: DalShard{
+				Index: 12,
+				Share: []mavryk.HexBytes{bytes.Repeat([]byte{2}, 32), bytes.Repeat([]byte{3}, 32)},
+			},
+			Proof: bytes.Repeat([]byte{4}, 48),
+		},
+	}
+
+	// DAL content requires v019+
+	p := mavryk.DefaultParams.Clone()
+	p.Version = dalAttestationVersion - 1
+	for _, v := range []Operation{att, ev} {
+		if err := v.EncodeBuffer(bytes.NewBuffer(nil), p); err == nil {
+			t.Errorf("expected error for %s before v%03d", v.Kind(), dalAttestationVersion)
+		}
+	}
+
+	// round trip at the first protocol with DAL content
+	p = mavryk.DefaultParams.Clone().WithProtocol(mavryk.ProtoAlpha)
+	for _, v := range []Operation{att, ev} {
+		op := NewOp().WithParams(p).WithBranch(branch).WithContents(v)
+		buf := op.Bytes()
+		op2, err := DecodeOp(buf)
+		if err != nil {
+			t.Fatalf("%s: decode: %v", v.Kind(), err)
+		}
+		if op2.Contents[0].Kind() != v.Kind() {
+			t.Errorf("%s: decoded kind %s", v.Kind(), op2.Contents[0].Kind())
+		}
+		if !bytes.Equal(buf, op2.WithParams(p).Bytes()) {
+			t.Errorf("%s: binary roundtrip mismatch", v.Kind())
+		}
+		js1, _ := json.Marshal(op)
+		js2, _ := json.Marshal(op2)
+		if !bytes.Equal(js1, js2) {
+			t.Errorf("%s: json mismatch\n%s\n%s", v.Kind(), js1, js2)
+		}
+	}
+}
+
+func TestOpRPCJSON(t *testing.T) {
+	src := mavryk.MustParseAddress("mv1XAPGKrKd4CRRHjTgDFLP1GEok7qXGVnvA")
+	op := NewOp().
+		WithBranch(mavryk.MustParseBlockHash("BKnYk1T5a49bb8me4WfQeugyFnMEH9h8cm6jqvL3BxRwE23EVBJ")).
+		WithSource(src).
+		WithTransfer(src, 1)
+
+	type envelope struct {
+		Protocol  string            `json:"protocol"`
+		Signature string            `json:"signature"`
+		Contents  []json.RawMessage `json:"contents"`
+	}
+	var e envelope
+	buf, err := json.Marshal(op.RPC())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(buf, &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Protocol != "" || e.Signature != mavryk.ZeroSignature.String() || len(e.Contents) != 1 {
+		t.Errorf("unexpected run_operation json %s", buf)
+	}
+	if !bytes.Contains(e.Contents[0], []byte(`"amount":"1"`)) {
+		t.Errorf("expected string encoded amount in %s", e.Contents[0])
+	}
+
+	buf, err = json.Marshal(op.Preapply())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(buf, &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Protocol != op.Params.Protocol.String() {
+		t.Errorf("expected protocol in preapply json %s", buf)
+	}
+}
+
+func TestOpLint(t *testing.T) {
+	src := mavryk.MustParseAddress("mv1XAPGKrKd4CRRHjTgDFLP1GEok7qXGVnvA")
+	dst := mavryk.MustParseAddress("mv1949pcbqwGsHfUCaVmNVRu21Cd4SnbpvpP")
+	op := NewOp().
+		WithBranch(mavryk.MustParseBlockHash("BKnYk1T5a49bb8me4WfQeugyFnMEH9h8cm6jqvL3BxRwE23EVBJ")).
+		WithSource(src).
+		WithTransfer(dst, 1).
+		WithTransfer(dst, 2)
+	for i, v := range op.Contents {
+		v.WithCounter(int64(i + 1))
+	}
+	op.WithLimits([]mavryk.Limits{{GasLimit: 1000}, {GasLimit: 1000}}, 0)
+	r := op.Lint(nil)
+	if r.HasErrors() || r.Err() != nil {
+		t.Fatalf("unexpected errors: %s", r)
+	}
+	if len(r.Issues) != 1 || r.Issues[0].Code != LintMissingReveal {
+		t.Errorf("expected missing reveal info, got %s", r)
+	}
+
+	// duplicate counter, low fee, zero storage limit origination
+	op.Contents[1].WithCounter(1)
+	op.Contents[1].WithLimits(mavryk.Limits{GasLimit: 1000})
+	op.WithOrigination(asScript(`{"code": [{"args": [{"prim": "string"}],"prim": "parameter"},{"args": [{"prim": "string"}],"prim": "storage"},{"args": [[{"prim": "CAR"},{"args": [{"prim": "operation"}],"prim": "NIL"},{"prim": "PAIR"}]],"prim": "code"}],"storage": {"string": "hello"}}`))
+	op.Contents[2].WithCounter(3)
+	op.Contents[2].WithLimits(mavryk.Limits{GasLimit: 1000, Fee: 1000})
+	r = op.Lint(nil)
+	codes := make(map[string]int)
+	for _, v := range r.Issues {
+		codes[v.Code]++
+	}
+	for _, c := range []string{LintDuplicateCounter, LintZeroStorageLimit, LintCounterGap} {
+		if codes[c] == 0 {
+			t.Errorf("missing %s issue in report: %s", c, r)
+		}
+	}
+	if r.Err() == nil {
+		t.Errorf("expected lint error")
+	}
+
+	// fee is checked for the whole group, op #1 fee is covered by others
+	if codes[LintLowFee] > 0 {
+		t.Errorf("unexpected low fee issue in report: %s", r)
+	}
+	for _, v := range op.Contents {
+		l := v.Limits()
+		l.Fee = 1
+		v.WithLimits(l)
+	}
+	r = op.Lint(nil)
+	var found bool
+	for _, v := range r.Issues {
+		found = found || (v.Code == LintLowFee && v.Pos == -1)
+	}
+	if !found {
+		t.Errorf("missing group low fee issue in report: %s", r)
+	}
+}
+
+// validateSchema checks v against the subset of JSON schema used by OpSchema.
+func validateSchema(root, s *JSONSchema, v interface{}, path string) error {
+	if s.Ref != "" {
+		return validateSchema(root, root.Defs[strings.TrimPrefix(s.Ref, "#/$defs/")], v, path)
+	}
+	if len(s.OneOf) > 0 {
+		var n int
+		for _, alt := range s.OneOf {
+			if validateSchema(root, alt, v, path) == nil {
+				n++
+			}
+		}
+		if n != 1 {
+			return fmt.Errorf("%s: value matches %d alternatives", path, n)
+		}
+		return nil
+	}
+	switch s.Type {
+	case "object":
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected object", path)
+		}
+		for _, k := range s.Required {
+			if _, ok := m[k]; !ok && s.Properties != nil {
+				return fmt.Errorf("%s: missing required property %s", path, k)
+			}
+		}
+		for k, val := range m {
+			p, ok := s.Properties[k]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					return fmt.Errorf("%s: unexpected property %s", path, k)
+				}
+				continue
+			}
+			if err := validateSchema(root, p, val, path+"."+k); err != nil {
+				return err
+			}
+		}
+	case "array":
+		a, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected array", path)
+		}
+		for i, val := range a {
+			if s.Items == nil {
+				continue
+			}
+			if err := validateSchema(root, s.Items, val, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s: expected string", path)
+		}
+		if s.Const != "" && str != s.Const {
+			return fmt.Errorf("%s: expected %q, got %q", path, s.Const, str)
+		}
+		if s.Pattern != "" && !regexp.MustCompile(s.Pattern).MatchString(str) {
+			return fmt.Errorf("%s: %q does not match %s", path, str, s.Pattern)
+		}
+	case "integer":
+		if _, ok := v.(float64); !ok {
+			return fmt.Errorf("%s: expected integer", path)
+		}
+	}
+	return nil
+}
+
+func TestOpSchema(t *testing.T) {
+	src := mavryk.MustParseAddress("mv1XAPGKrKd4CRRHjTgDFLP1GEok7qXGVnvA")
+	dst := mavryk.MustParseAddress("mv1949pcbqwGsHfUCaVmNVRu21Cd4SnbpvpP")
+	op := NewOp().
+		WithBranch(mavryk.MustParseBlockHash("BKnYk1T5a49bb8me4WfQeugyFnMEH9h8cm6jqvL3BxRwE23EVBJ")).
+		WithSource(src).
+		WithTransfer(dst, 1).
+		WithDelegation(dst).
+		WithOrigination(asScript(`{"code": [{"args": [{"prim": "string"}],"prim": "parameter"},{"args": [{"prim": "string"}],"prim": "storage"},{"args": [[{"prim": "CAR"},{"args": [{"prim": "operation"}],"prim": "NIL"},{"prim": "PAIR"}]],"prim": "code"}],"storage": {"string": "hello"}}`)).
+		WithStake(1000)
+	buf, err := json.Marshal(op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v interface{}
+	if err := json.Unmarshal(buf, &v); err != nil {
+		t.Fatal(err)
+	}
+	schema := OpSchema()
+	if err := validateSchema(schema, schema, v, "op"); err != nil {
+		t.Error(err)
+	}
+
+	// schema must be valid JSON
+	if _, err := json.Marshal(schema); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OperationSchema(mavryk.OpTypeTransaction); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OperationSchema(mavryk.OpTypeInvalid); err == nil {
+		t.Error("expected error for invalid operation type")
+	}
+}
+
+func TestPaymentRequest(t *testing.T) {
+	dst := mavryk.MustParseAddress("mv1949pcbqwGsHfUCaVmNVRu21Cd4SnbpvpP")
+	kt1 := mavryk.MustParseAddress("KT1EMQxfYVvhTJTqMiVs2ho2dqjbYfYKk6BY")
+	params := micheline.NewPair(micheline.NewString("hello"), micheline.NewInt64(42))
+
+	tests := []PaymentRequest{
+		{Receiver: dst, Amount: 1000000},
+		{Receiver: kt1},
+		{Receiver: kt1, Amount: 5, Entrypoint: "mint", Params: &params},
+	}
+	for i, req := range tests {
+		uri, err := req.URI()
+		if err != nil {
+			t.Fatalf("case %d: %v", i, err)
+		}
+		if !strings.HasPrefix(uri, PaymentScheme+":"+req.Receiver.String()) {
+			t.Errorf("case %d: unexpected uri %s", i, uri)
+		}
+		req2, err := ParsePaymentRequest(uri)
+		if err != nil {
+			t.Fatalf("case %d: parse %s: %v", i, uri, err)
+		}
+		op, err := req2.Op()
+		if err != nil {
+			t.Fatalf("case %d: %v", i, err)
+		}
+		if got := op.Contents[0].Kind(); got != mavryk.OpTypeTransaction {
+			t.Errorf("case %d: unexpected op kind %s", i, got)
+		}
+		req3, err := NewPaymentRequest(op)
+		if err != nil {
+			t.Fatalf("case %d: %v", i, err)
+		}
+		if uri3 := req3.String(); uri3 != uri {
+			t.Errorf("case %d: round trip mismatch\n  want %s\n  got  %s", i, uri, uri3)
+		}
+	}
+
+	// calls require a contract
+	if _, err := (PaymentRequest{Receiver: dst, Entrypoint: "mint"}).URI(); err == nil {
+		t.Error("expected error for call to implicit account")
+	}
+	for _, s := range []string{
+		"tezos:mv1949pcbqwGsHfUCaVmNVRu21Cd4SnbpvpP",
+		"mavryk:invalid",
+		"mavryk:mv1949pcbqwGsHfUCaVmNVRu21Cd4SnbpvpP?amount=x",
+		"mavryk:KT1EMQxfYVvhTJTqMiVs2ho2dqjbYfYKk6BY?params=zz",
+	} {
+		if _, err := ParsePaymentRequest(s); err == nil {
+			t.Errorf("expected error for %q", s)
+		}
+	}
+}
+
+func TestDecodeSignedOp(t *testing.T) {
+	sk, err := mavryk.GenerateKey(mavryk.KeyTypeEd25519)
+	if err != nil {
+		t.Fatal(err)
+	}
+	op := NewOp().
+		WithBranch(mavryk.MustParseBlockHash("BKnYk1T5a49bb8me4WfQeugyFnMEH9h8cm6jqvL3BxRwE23EVBJ")).
+		WithSource(sk.Address()).
+		WithTransfer(mavryk.MustParseAddress("mv1C6KuPzs92LTENfXUPXq1oNxvaLjxJ9p8b"), 1000)
+	op.Contents[0].WithCounter(1)
+	if err := op.Sign(sk); err != nil {
+		t.Fatal(err)
+	}
+	buf := op.Bytes()
+	op2, err := DecodeOp(buf)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(op2.Contents) != 1 {
+		t.Fatalf("contents mismatch, want=1 have=%d", len(op2.Contents))
+	}
+	if !bytes.Equal(op2.Signature.Data, op.Signature.Data) {
+		t.Errorf("signature mismatch, want=%x have=%x", op.Signature.Data, op2.Signature.Data)
+	}
+	if !bytes.Equal(buf, op2.Bytes()) {
+		t.Errorf("binary roundtrip mismatch")
+	}
+}
+
+func TestDecodeSignedOpWithTagPrefix(t *testing.T) {
+	op := NewOp().
+		WithBranch(mavryk.MustParseBlockHash("BKnYk1T5a49bb8me4WfQeugyFnMEH9h8cm6jqvL3BxRwE23EVBJ")).
+		WithSource(mavryk.MustParseAddress("mv1RcVGZ46tRpYh2pnkSPiQA7r72LRpQpBUE")).
+		WithTransfer(mavryk.MustParseAddress("mv1C6KuPzs92LTENfXUPXq1oNxvaLjxJ9p8b"), 1000)
+	op.Contents[0].WithCounter(1)
+
+	// signatures may start with any byte including valid operation tags
+	for _, tag := range []mavryk.OpType{mavryk.OpTypeTransaction, mavryk.OpTypeSmartRollupRefute, mavryk.OpTypeReveal} {
+		sig := make([]byte, 64)
+		sig[0] = tag.TagVersion(op.Params.OperationTagsVersion)
+		buf := append(op.Bytes(), sig...)
+		op2, err := DecodeOp(buf)
+		if err != nil {
+			t.Fatalf("%s: decode: %v", tag, err)
+		}
+		if len(op2.Contents) != 1 {
+			t.Fatalf("%s: contents mismatch, want=1 have=%d", tag, len(op2.Contents))
+		}
+		if !bytes.Equal(op2.Signature.Data, sig) {
+			t.Errorf("%s: signature mismatch, want=%x have=%x", tag, sig, op2.Signature.Data)
+		}
+	}
+}
+
+func TestDecodeOpWithAmbiguousTail(t *testing.T) {
+	src := mavryk.MustParseAddress("mv1RcVGZ46tRpYh2pnkSPiQA7r72LRpQpBUE")
+	dst := mavryk.MustParseAddress("mv1C6KuPzs92LTENfXUPXq1oNxvaLjxJ9p8b")
+	op := NewOp().
+		WithBranch(mavryk.MustParseBlockHash("BKnYk1T5a49bb8me4WfQeugyFnMEH9h8cm6jqvL3BxRwE23EVBJ")).
+		WithSource(src).
+		WithTransfer(dst, 1000).
+		WithTransfer(dst, 1<<42)
+	op.Contents[0].WithCounter(1)
+	op.Contents[1].WithCounter(1 << 62)
+
+	// the last operation has signature size and could be read as either
+	buf := bytes.NewBuffer(nil)
+	if err := op.Contents[1].EncodeBuffer(buf, op.Params); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 64 {
+		t.Fatalf("test op size mismatch, want=64 have=%d", buf.Len())
+	}
+
+	// a complete operation is kept as operation
+	op2, err := DecodeOp(op.Bytes())
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(op2.Contents) != 2 {
+		t.Fatalf("contents mismatch, want=2 have=%d", len(op2.Contents))
+	}
+	if op2.Signature.IsValid() {
+		t.Errorf("unexpected signature %s", op2.Signature)
+	}
+	if have := op2.Contents[1].GetCounter(); have != 1<<62 {
+		t.Errorf("counter mismatch, want=%d have=%d", int64(1<<62), have)
+	}
+
+	// a partial operation is read as signature
+	sig := buf.Bytes()
+	sig[len(sig)-1] = 0xff
+	op.Contents = op.Contents[:1]
+	op3, err := DecodeOp(append(op.Bytes(), sig...))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(op3.Contents) != 1 {
+		t.Fatalf("contents mismatch, want=1 have=%d", len(op3.Contents))
+	}
+	if !bytes.Equal(op3.Signature.Data, sig) {
+		t.Errorf("signature mismatch, want=%x have=%x", sig, op3.Signature.Data)
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package codec
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+	"github.com/mavryk-network/mvgo/micheline"
+)
+
+// PaymentScheme is the URI scheme used for payment requests.
+const PaymentScheme = "mavryk"
+
+// PaymentRequest describes a transfer or contract call a wallet is asked to
+// sign, e.g. after scanning a QR code. Payment requests are encoded as compact
+// URIs of the form
+//
+//	mavryk:<address>?amount=<mumav>&entrypoint=<name>&params=<hex>
+//
+// where amount is in mumav, params is the hex encoded binary Micheline value
+// and all query arguments are optional.
+type PaymentRequest struct {
+	Receiver   mavryk.Address  // transfer receiver or called contract
+	Amount     int64           // amount in mumav
+	Entrypoint string          // optional entrypoint, default when empty
+	Params     *micheline.Prim // optional call parameters
+}
+
+// IsCall returns true when the request calls a contract entrypoint.
+func (r PaymentRequest) IsCall() bool {
+	return r.Params != nil || (r.Entrypoint != "" && r.Entrypoint != micheline.DEFAULT)
+}
+
+// Validate checks the request for consistency.
+func (r PaymentRequest) Validate() error {
+	if !r.Receiver.IsValid() {
+		return fmt.Errorf("tezos: invalid payment receiver")
+	}
+	if r.Amount < 0 {
+		return fmt.Errorf("tezos: negative payment amount")
+	}
+	if r.IsCall() && !r.Receiver.IsContract() {
+		return fmt.Errorf("tezos: payment call to non-contract %s", r.Receiver)
+	}
+	return nil
+}
+
+// URI returns the compact payment request URI.
+func (r PaymentRequest) URI() (string, error) {
+	if err := r.Validate(); err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	b.WriteString(PaymentScheme)
+	b.WriteByte(':')
+	b.WriteString(r.Receiver.String())
+	sep := byte('?')
+	arg := func(k, v string) {
+		b.WriteByte(sep)
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(url.QueryEscape(v))
+		sep = '&'
+	}
+	if r.Amount > 0 {
+		arg("amount", strconv.FormatInt(r.Amount, 10))
+	}
+	if r.Entrypoint != "" && r.Entrypoint != micheline.DEFAULT {
+		arg("entrypoint", r.Entrypoint)
+	}
+	if r.Params != nil {
+		buf, err := r.Params.MarshalBinary()
+		if err != nil {
+			return "", fmt.Errorf("tezos: encoding payment params: %w", err)
+		}
+		arg("params", hex.EncodeToString(buf))
+	}
+	return b.String(), nil
+}
+
+// String returns the payment request URI or an empty string when the
+// request is invalid.
+func (r PaymentRequest) String() string {
+	s, _ := r.URI()
+	return s
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (r PaymentRequest) MarshalText() ([]byte, error) {
+	s, err := r.URI()
+	return []byte(s), err
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (r *PaymentRequest) UnmarshalText(data []byte) error {
+	req, err := ParsePaymentRequest(string(data))
+	if err != nil {
+		return err
+	}
+	*r = req
+	return nil
+}
+
+// ParsePaymentRequest decodes a payment request URI.
+func ParsePaymentRequest(s string) (PaymentRequest, error) {
+	var r PaymentRequest
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, PaymentScheme+":") {
+		return r, fmt.Errorf("tezos: invalid payment request scheme")
+	}
+	addr, query, _ := strings.Cut(s[len(PaymentScheme)+1:], "?")
+	a, err := mavryk.ParseAddress(strings.TrimPrefix(addr, "//"))
+	if err != nil {
+		return r, fmt.Errorf("tezos: invalid payment receiver: %w", err)
+	}
+	r.Receiver = a
+	args, err := url.ParseQuery(query)
+	if err != nil {
+		return r, fmt.Errorf("tezos: invalid payment request: %w", err)
+	}
+	if v := args.Get("amount"); v != "" {
+		r.Amount, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return r, fmt.Errorf("tezos: invalid payment amount %q", v)
+		}
+	}
+	r.Entrypoint = args.Get("entrypoint")
+	if v := args.Get("params"); v != "" {
+		buf, err := hex.DecodeString(v)
+		if err != nil {
+			return r, fmt.Errorf("tezos: invalid payment params: %w", err)
+		}
+		p := micheline.Prim{}
+		if err := p.UnmarshalBinary(buf); err != nil {
+			return r, fmt.Errorf("tezos: invalid payment params: %w", err)
+		}
+		r.Params = &p
+	}
+	return r, r.Validate()
+}
+
+// Op returns an operation which executes the payment request. Source,
+// branch and limits must be set by the caller before signing.
+func (r PaymentRequest) Op() (*Op, error) {
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	op := NewOp()
+	if !r.IsCall() {
+		return op.WithTransfer(r.Receiver, r.Amount), nil
+	}
+	params := micheline.Parameters{
+		Entrypoint: r.Entrypoint,
+		Value:      micheline.Unit,
+	}
+	if params.Entrypoint == "" {
+		params.Entrypoint = micheline.DEFAULT
+	}
+	if r.Params != nil {
+		params.Value = *r.Params
+	}
+	return op.WithCallExt(r.Receiver, params, r.Amount), nil
+}
+
+// NewPaymentRequest returns a payment request for a single transfer or
+// contract call transaction in op.
+func NewPaymentRequest(op *Op) (PaymentRequest, error) {
+	var r PaymentRequest
+	if op == nil || len(op.Contents) != 1 {
+		return r, fmt.Errorf("tezos: payment request requires a single transaction")
+	}
+	tx, ok := op.Contents[0].(*Transaction)
+	if !ok {
+		return r, fmt.Errorf("tezos: payment request requires a transaction, got %s", op.Contents[0].Kind())
+	}
+	r.Receiver = tx.Destination
+	r.Amount = int64(tx.Amount)
+	if tx.Parameters != nil {
+		r.Entrypoint = tx.Parameters.Entrypoint
+		if r.Entrypoint == micheline.DEFAULT {
+			r.Entrypoint = ""
+		}
+		if v := tx.Parameters.Value; v.IsValid() && v.OpCode != micheline.D_UNIT {
+			r.Params = &v
+		}
+	}
+	return r, r.Validate()
+}
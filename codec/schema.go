@@ -0,0 +1,479 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package codec
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+)
+
+// JSONSchemaDraft is the JSON schema dialect used by generated schemas.
+const JSONSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// JSONSchema is the subset of JSON schema required to describe the JSON
+// encoding of operations produced by MarshalJSON.
+type JSONSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Id                   string                 `json:"$id,omitempty"`
+	Ref                  string                 `json:"$ref,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Const                string                 `json:"const,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	Minimum              *int64                 `json:"minimum,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties *bool                  `json:"additionalProperties,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	MinItems             *int                   `json:"minItems,omitempty"`
+	OneOf                []*JSONSchema          `json:"oneOf,omitempty"`
+	Defs                 map[string]*JSONSchema `json:"$defs,omitempty"`
+}
+
+// OperationSchema returns a JSON schema for the JSON encoding of a single
+// operation content of type typ. Schemas describe the current protocol.
+func OperationSchema(typ mavryk.OpType) (*JSONSchema, error) {
+	fn, ok := opSchemas[typ]
+	if !ok {
+		return nil, fmt.Errorf("tezos: no schema for operation type %q", typ)
+	}
+	s := fn()
+	s.Schema = JSONSchemaDraft
+	s.Title = typ.String()
+	return s, nil
+}
+
+// OpSchema returns a JSON schema for the JSON encoding of a full Op with
+// branch, contents and optional signature. Contents schemas are defined
+// in $defs keyed by operation kind.
+func OpSchema() *JSONSchema {
+	defs := make(map[string]*JSONSchema, len(opSchemas))
+	kinds := make([]string, 0, len(opSchemas))
+	for typ, fn := range opSchemas {
+		defs[typ.String()] = fn()
+		kinds = append(kinds, typ.String())
+	}
+	sort.Strings(kinds)
+	contents := make([]*JSONSchema, len(kinds))
+	for i, k := range kinds {
+		contents[i] = &JSONSchema{Ref: "#/$defs/" + k}
+	}
+	s := schemaObject(map[string]*JSONSchema{
+		"branch":    schemaHash(mavryk.HashTypeBlock),
+		"contents":  schemaArray(&JSONSchema{OneOf: contents}, 1),
+		"signature": schemaSignature(),
+	}, "branch", "contents")
+	s.Schema = JSONSchemaDraft
+	s.Title = "operation"
+	s.Defs = defs
+	return s
+}
+
+var opSchemas = map[mavryk.OpType]func() *JSONSchema{
+	mavryk.OpTypeActivateAccount: func() *JSONSchema {
+		return schemaOp(mavryk.OpTypeActivateAccount, false, map[string]*JSONSchema{
+			"pkh":    schemaAddress(),
+			"secret": schemaHex(),
+		})
+	},
+	mavryk.OpTypeBallot: func() *JSONSchema {
+		return schemaOp(mavryk.OpTypeBallot, false, map[string]*JSONSchema{
+			"source":   schemaAddress(),
+			"period":   schemaInt(),
+			"proposal": schemaHash(mavryk.HashTypeProtocol),
+			"ballot":   {Type: "string", Enum: []string{"yay", "nay", "pass"}},
+		})
+	},
+	mavryk.OpTypeProposals: func() *JSONSchema {
+		return schemaOp(mavryk.OpTypeProposals, false, map[string]*JSONSchema{
+			"source":    schemaAddress(),
+			"period":    schemaInt(),
+			"proposals": schemaArray(schemaHash(mavryk.HashTypeProtocol), 1),
+		})
+	},
+	mavryk.OpTypeSeedNonceRevelation: func() *JSONSchema {
+		return schemaOp(mavryk.OpTypeSeedNonceRevelation, false, map[string]*JSONSchema{
+			"level": schemaInt(),
+			"nonce": schemaHex(),
+		})
+	},
+	mavryk.OpTypeVdfRevelation: func() *JSONSchema {
+		return schemaOp(mavryk.OpTypeVdfRevelation, false, map[string]*JSONSchema{
+			"solution": schemaHex(),
+		})
+	},
+	mavryk.OpTypeFailingNoop: func() *JSONSchema {
+		return schemaOp(mavryk.OpTypeFailingNoop, false, map[string]*JSONSchema{
+			"arbitrary": schemaHex(),
+		})
+	},
+	mavryk.OpTypeEndorsement: func() *JSONSchema {
+		return schemaEndorsement(mavryk.OpTypeEndorsement)
+	},
+	mavryk.OpTypeAttestationWithDal: func() *JSONSchema {
+		return schemaEndorsement(mavryk.OpTypeAttestationWithDal)
+	},
+	mavryk.OpTypePreendorsement: func() *JSONSchema {
+		return schemaEndorsement(mavryk.OpTypePreendorsement)
+	},
+	mavryk.OpTypeDoubleEndorsementEvidence: func() *JSONSchema {
+		return schemaOp(mavryk.OpTypeDoubleEndorsementEvidence, false, map[string]*JSONSchema{
+			"op1": schemaInlinedEndorsement(mavryk.OpTypeEndorsement, mavryk.OpTypeAttestationWithDal),
+			"op2": schemaInlinedEndorsement(mavryk.OpTypeEndorsement, mavryk.OpTypeAttestationWithDal),
+		})
+	},
+	mavryk.OpTypeDoublePreendorsementEvidence: func() *JSONSchema {
+		return schemaOp(mavryk.OpTypeDoublePreendorsementEvidence, false, map[string]*JSONSchema{
+			"op1": schemaInlinedEndorsement(mavryk.OpTypePreendorsement),
+			"op2": schemaInlinedEndorsement(mavryk.OpTypePreendorsement),
+		})
+	},
+	mavryk.OpTypeDoubleBakingEvidence: func() *JSONSchema {
+		return schemaOp(mavryk.OpTypeDoubleBakingEvidence, false, map[string]*JSONSchema{
+			"bh1": schemaBlockHeader(),
+			"bh2": schemaBlockHeader(),
+		})
+	},
+	mavryk.OpTypeDalAttestation: func() *JSONSchema {
+		return schemaOp(mavryk.OpTypeDalAttestation, false, map[string]*JSONSchema{
+			"attestor":    schemaAddress(),
+			"attestation": schemaNumber(),
+			"level":       schemaInt(),
+		})
+	},
+	mavryk.OpTypeDalEntrapmentEvidence: func() *JSONSchema {
+		return schemaOp(mavryk.OpTypeDalEntrapmentEvidence, false, map[string]*JSONSchema{
+			"attestation":    schemaInlinedEndorsement(mavryk.OpTypeEndorsement, mavryk.OpTypeAttestationWithDal),
+			"consensus_slot": schemaInt(),
+			"slot_index":     schemaInt(),
+			"shard_with_proof": schemaObject(map[string]*JSONSchema{
+				"shard": schemaObject(map[string]*JSONSchema{
+					"index": schemaInt(),
+					"share": schemaArray(schemaHex(), 0),
+				}, "index", "share"),
+				"proof": schemaHex(),
+			}, "shard", "proof"),
+		})
+	},
+	mavryk.OpTypeDrainDelegate: func() *JSONSchema {
+		return schemaOp(mavryk.OpTypeDrainDelegate, false, map[string]*JSONSchema{
+			"consensus_key": schemaAddress(),
+			"delegate":      schemaAddress(),
+			"destination":   schemaAddress(),
+		})
+	},
+	mavryk.OpTypeReveal: func() *JSONSchema {
+		return schemaOp(mavryk.OpTypeReveal, true, map[string]*JSONSchema{
+			"public_key": schemaKey(),
+		})
+	},
+	mavryk.OpTypeTransaction: func() *JSONSchema {
+		s := schemaOp(mavryk.OpTypeTransaction, true, map[string]*JSONSchema{
+			"amount":      schemaNumber(),
+			"destination": schemaAddress(),
+			"parameters": schemaObject(map[string]*JSONSchema{
+				"entrypoint": {Type: "string"},
+				"value":      schemaMicheline(),
+			}, "entrypoint", "value"),
+		})
+		return s.optional("parameters")
+	},
+	mavryk.OpTypeOrigination: func() *JSONSchema {
+		s := schemaOp(mavryk.OpTypeOrigination, true, map[string]*JSONSchema{
+			"balance":  schemaNumber(),
+			"delegate": schemaAddress(),
+			"script": schemaObject(map[string]*JSONSchema{
+				"code":    schemaMicheline(),
+				"storage": schemaMicheline(),
+			}, "code", "storage"),
+		})
+		return s.optional("delegate")
+	},
+	mavryk.OpTypeDelegation: func() *JSONSchema {
+		s := schemaOp(mavryk.OpTypeDelegation, true, map[string]*JSONSchema{
+			"delegate": schemaAddress(),
+		})
+		return s.optional("delegate")
+	},
+	mavryk.OpTypeSetDepositsLimit: func() *JSONSchema {
+		s := schemaOp(mavryk.OpTypeSetDepositsLimit, true, map[string]*JSONSchema{
+			"limit": schemaNumber(),
+		})
+		return s.optional("limit")
+	},
+	mavryk.OpTypeRegisterConstant: func() *JSONSchema {
+		return schemaOp(mavryk.OpTypeRegisterConstant, true, map[string]*JSONSchema{
+			"value": schemaMicheline(),
+		})
+	},
+	mavryk.OpTypeIncreasePaidStorage: func() *JSONSchema {
+		return schemaOp(mavryk.OpTypeIncreasePaidStorage, true, map[string]*JSONSchema{
+			"amount":      schemaZ(),
+			"destination": schemaAddress(),
+		})
+	},
+	mavryk.OpTypeUpdateConsensusKey: func() *JSONSchema {
+		s := schemaOp(mavryk.OpTypeUpdateConsensusKey, true, map[string]*JSONSchema{
+			"pk":    schemaKey(),
+			"proof": schemaSignature(),
+		})
+		return s.optional("proof")
+	},
+	mavryk.OpTypeTransferTicket: func() *JSONSchema {
+		return schemaOp(mavryk.OpTypeTransferTicket, true, map[string]*JSONSchema{
+			"ticket_contents": schemaMicheline(),
+			"ticket_ty":       schemaMicheline(),
+			"ticketer":        schemaAddress(),
+			"amount":          schemaNumber(),
+			"destination":     schemaAddress(),
+			"entrypoint":      {Type: "string"},
+		})
+	},
+	mavryk.OpTypeDalPublishSlotHeader: func() *JSONSchema {
+		return schemaOp(mavryk.OpTypeDalPublishSlotHeader, true, map[string]*JSONSchema{
+			"slot_header": schemaObject(map[string]*JSONSchema{
+				"level":            schemaInt(),
+				"index":            schemaInt(),
+				"commitment":       {Type: "string"},
+				"commitment_proof": {Type: "string"},
+			}, "level", "index", "commitment", "commitment_proof"),
+		})
+	},
+	mavryk.OpTypeSmartRollupOriginate: func() *JSONSchema {
+		return schemaOp(mavryk.OpTypeSmartRollupOriginate, true, map[string]*JSONSchema{
+			"pvm_kind":          {Type: "string", Enum: []string{"arith", "wasm_2_0_0"}},
+			"kernel":            schemaHex(),
+			"origination_proof": schemaHex(),
+			"parameters_ty":     schemaMicheline(),
+		})
+	},
+	mavryk.OpTypeSmartRollupAddMessages: func() *JSONSchema {
+		return schemaOp(mavryk.OpTypeSmartRollupAddMessages, true, map[string]*JSONSchema{
+			"messages": schemaArray(schemaHex(), 0),
+		})
+	},
+	mavryk.OpTypeSmartRollupCement: func() *JSONSchema {
+		return schemaOp(mavryk.OpTypeSmartRollupCement, true, map[string]*JSONSchema{
+			"rollup": schemaAddress(),
+		})
+	},
+	mavryk.OpTypeSmartRollupPublish: func() *JSONSchema {
+		return schemaOp(mavryk.OpTypeSmartRollupPublish, true, map[string]*JSONSchema{
+			"rollup": schemaAddress(),
+			"commitment": schemaObject(map[string]*JSONSchema{
+				"compressed_state": schemaHash(mavryk.HashTypeSmartRollupStateHash),
+				"inbox_level":      schemaInt(),
+				"predecessor":      schemaHash(mavryk.HashTypeSmartRollupStateHash),
+				"number_of_ticks":  schemaNumber(),
+			}, "compressed_state", "inbox_level", "predecessor", "number_of_ticks"),
+		})
+	},
+	mavryk.OpTypeSmartRollupRefute: func() *JSONSchema {
+		return schemaOp(mavryk.OpTypeSmartRollupRefute, true, map[string]*JSONSchema{
+			"rollup":     schemaAddress(),
+			"opponent":   schemaAddress(),
+			"refutation": {Type: "object"},
+		})
+	},
+	mavryk.OpTypeSmartRollupTimeout: func() *JSONSchema {
+		return schemaOp(mavryk.OpTypeSmartRollupTimeout, true, map[string]*JSONSchema{
+			"rollup": schemaAddress(),
+			"stakers": schemaObject(map[string]*JSONSchema{
+				"alice": schemaAddress(),
+				"bob":   schemaAddress(),
+			}, "alice", "bob"),
+		})
+	},
+	mavryk.OpTypeSmartRollupExecuteOutboxMessage: func() *JSONSchema {
+		return schemaOp(mavryk.OpTypeSmartRollupExecuteOutboxMessage, true, map[string]*JSONSchema{
+			"rollup":              schemaAddress(),
+			"cemented_commitment": schemaHash(mavryk.HashTypeSmartRollupCommitHash),
+			"output_proof":        schemaHex(),
+		})
+	},
+	mavryk.OpTypeSmartRollupRecoverBond: func() *JSONSchema {
+		return schemaOp(mavryk.OpTypeSmartRollupRecoverBond, true, map[string]*JSONSchema{
+			"rollup": schemaAddress(),
+			"staker": schemaAddress(),
+		})
+	},
+}
+
+// schemaOp returns an object schema with a kind constant, optional manager
+// fields and props. All props are required unless removed with optional.
+func schemaOp(typ mavryk.OpType, manager bool, props map[string]*JSONSchema) *JSONSchema {
+	props["kind"] = &JSONSchema{Type: "string", Const: typ.String()}
+	if manager {
+		props["source"] = schemaAddress()
+		props["fee"] = schemaNumber()
+		props["counter"] = schemaNumber()
+		props["gas_limit"] = schemaNumber()
+		props["storage_limit"] = schemaNumber()
+	}
+	return schemaRecord(props)
+}
+
+// schemaRecord returns an object schema which requires all props.
+func schemaRecord(props map[string]*JSONSchema) *JSONSchema {
+	required := make([]string, 0, len(props))
+	for k := range props {
+		required = append(required, k)
+	}
+	return schemaObject(props, required...)
+}
+
+func (s *JSONSchema) optional(names ...string) *JSONSchema {
+	required := s.Required[:0]
+	for _, v := range s.Required {
+		var skip bool
+		for _, n := range names {
+			skip = skip || v == n
+		}
+		if !skip {
+			required = append(required, v)
+		}
+	}
+	s.Required = required
+	return s
+}
+
+func schemaObject(props map[string]*JSONSchema, required ...string) *JSONSchema {
+	sort.Strings(required)
+	no := false
+	return &JSONSchema{
+		Type:                 "object",
+		Properties:           props,
+		Required:             required,
+		AdditionalProperties: &no,
+	}
+}
+
+func schemaArray(items *JSONSchema, min int) *JSONSchema {
+	s := &JSONSchema{Type: "array", Items: items}
+	if min > 0 {
+		s.MinItems = &min
+	}
+	return s
+}
+
+func schemaEndorsement(typ mavryk.OpType) *JSONSchema {
+	props := map[string]*JSONSchema{
+		"slot":               schemaInt(),
+		"level":              schemaInt(),
+		"round":              schemaInt(),
+		"block_payload_hash": schemaHash(mavryk.HashTypeBlockPayload),
+	}
+	if typ == mavryk.OpTypeAttestationWithDal {
+		props["dal_attestation"] = schemaNumber()
+	}
+	return schemaOp(typ, false, props)
+}
+
+func schemaInlinedEndorsement(types ...mavryk.OpType) *JSONSchema {
+	ops := make([]*JSONSchema, len(types))
+	for i, typ := range types {
+		ops[i] = schemaEndorsement(typ)
+	}
+	op := ops[0]
+	if len(ops) > 1 {
+		op = &JSONSchema{OneOf: ops}
+	}
+	return schemaObject(map[string]*JSONSchema{
+		"branch":     schemaHash(mavryk.HashTypeBlock),
+		"operations": op,
+		"signature":  schemaSignature(),
+	}, "branch", "operations", "signature")
+}
+
+func schemaBlockHeader() *JSONSchema {
+	s := schemaRecord(map[string]*JSONSchema{
+		"level":                        schemaInt(),
+		"proto":                        schemaInt(),
+		"predecessor":                  schemaHash(mavryk.HashTypeBlock),
+		"timestamp":                    {Type: "string", Pattern: `^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}Z$`},
+		"validation_pass":              schemaInt(),
+		"operations_hash":              schemaHash(mavryk.HashTypeOperationListList),
+		"fitness":                      schemaArray(schemaHex(), 0),
+		"context":                      schemaHash(mavryk.HashTypeContext),
+		"payload_hash":                 schemaHash(mavryk.HashTypeBlockPayload),
+		"payload_round":                schemaInt(),
+		"proof_of_work_nonce":          schemaHex(),
+		"seed_nonce_hash":              schemaHash(mavryk.HashTypeNonce),
+		"liquidity_baking_toggle_vote": {Type: "string", Enum: []string{"on", "off", "pass"}},
+		"adaptive_issuance_vote":       {Type: "string", Enum: []string{"on", "off", "pass"}},
+		"signature":                    schemaSignature(),
+	})
+	return s.optional("seed_nonce_hash", "signature")
+}
+
+func schemaInt() *JSONSchema {
+	var zero int64
+	return &JSONSchema{Type: "integer", Minimum: &zero}
+}
+
+// schemaNumber is an unsigned arbitrary precision number encoded as string.
+func schemaNumber() *JSONSchema {
+	return &JSONSchema{Type: "string", Pattern: `^[0-9]+$`}
+}
+
+// schemaZ is a signed arbitrary precision number encoded as string.
+func schemaZ() *JSONSchema {
+	return &JSONSchema{Type: "string", Pattern: `^-?[0-9]+$`}
+}
+
+func schemaHex() *JSONSchema {
+	return &JSONSchema{Type: "string", Pattern: `^([0-9a-fA-F]{2})*$`}
+}
+
+func schemaMicheline() *JSONSchema {
+	return &JSONSchema{
+		Description: "Micheline expression",
+		OneOf:       []*JSONSchema{{Type: "object"}, {Type: "array"}},
+	}
+}
+
+func schemaHash(types ...mavryk.HashType) *JSONSchema {
+	alts := make([]string, len(types))
+	for i, t := range types {
+		alts[i] = t.B58Prefix + "[1-9A-HJ-NP-Za-km-z]{" + strconv.Itoa(t.B58Len-len(t.B58Prefix)) + "}"
+	}
+	return &JSONSchema{Type: "string", Pattern: "^(" + strings.Join(alts, "|") + ")$"}
+}
+
+func schemaAddress() *JSONSchema {
+	return schemaHash(
+		mavryk.HashTypePkhEd25519,
+		mavryk.HashTypePkhSecp256k1,
+		mavryk.HashTypePkhP256,
+		mavryk.HashTypePkhBls12_381,
+		mavryk.HashTypePkhNocurve,
+		mavryk.HashTypePkhBlinded,
+		mavryk.HashTypeSmartRollupAddress,
+	)
+}
+
+func schemaKey() *JSONSchema {
+	return schemaHash(
+		mavryk.HashTypePkEd25519,
+		mavryk.HashTypePkSecp256k1,
+		mavryk.HashTypePkP256,
+		mavryk.HashTypePkBls12_381,
+	)
+}
+
+func schemaSignature() *JSONSchema {
+	return schemaHash(
+		mavryk.HashTypeSigEd25519,
+		mavryk.HashTypeSigSecp256k1,
+		mavryk.HashTypeSigP256,
+		mavryk.HashTypeSigBls12_381,
+		mavryk.HashTypeSigGeneric,
+	)
+}
@@ -0,0 +1,162 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package codec
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+	"github.com/mavryk-network/mvgo/micheline"
+)
+
+// SigningRequest bundles everything an offline (air-gapped) device needs to
+// review and sign an operation. Requests are JSON serializable for transport.
+// Summary is informational only, devices should call Verify before showing
+// it to make sure it describes the signed bytes. The returned signature is
+// checked against the request with ImportSignature before it is attached to
+// the operation.
+type SigningRequest struct {
+	ChainId     *mavryk.ChainIdHash `json:"chain_id,omitempty"`
+	Source      mavryk.Address      `json:"source"`
+	Branch      mavryk.BlockHash    `json:"branch"`
+	Watermarked mavryk.HexBytes     `json:"watermarked"`
+	Digest      mavryk.HexBytes     `json:"digest"`
+	Summary     []string            `json:"summary"`
+}
+
+// SigningRequest creates a signing request for the operation. Branch and
+// contents must be set or resolvable.
+func (o *Op) SigningRequest() (*SigningRequest, error) {
+	buf := o.WatermarkedBytes()
+	if buf == nil {
+		return nil, fmt.Errorf("tezos: missing branch or empty operation contents")
+	}
+	d := mavryk.Digest(buf)
+	req := &SigningRequest{
+		ChainId:     o.ChainId,
+		Source:      o.Source,
+		Branch:      o.Branch,
+		Watermarked: buf,
+		Digest:      d[:],
+		Summary:     make([]string, len(o.Contents)),
+	}
+	for i, v := range o.Contents {
+		req.Summary[i] = summarize(v)
+	}
+	return req, nil
+}
+
+// Verify checks that the digest and summary match the watermarked bytes, so
+// a request cannot show a different payload than the one which is signed.
+func (r SigningRequest) Verify() error {
+	if d := mavryk.Digest(r.Watermarked); !bytes.Equal(d[:], r.Digest) {
+		return fmt.Errorf("tezos: signing request digest does not match payload")
+	}
+	summary, err := describePayload(r.Watermarked)
+	if err != nil {
+		return fmt.Errorf("tezos: signing request payload: %w", err)
+	}
+	if !equalStrings(summary, r.Summary) {
+		return fmt.Errorf("tezos: signing request summary does not match payload")
+	}
+	return nil
+}
+
+// ImportSignature checks that req is consistent, that the operation is
+// unchanged since req was created and that sig is a valid signature of the
+// request digest by key before attaching the signature to the operation.
+func (o *Op) ImportSignature(req *SigningRequest, sig mavryk.Signature, key mavryk.Key) error {
+	if req == nil {
+		return fmt.Errorf("tezos: missing signing request")
+	}
+	if !sig.IsValid() {
+		return fmt.Errorf("tezos: invalid signature")
+	}
+	if req.Source.IsValid() && !req.Source.Equal(key.Address()) {
+		return fmt.Errorf("tezos: signing key %s does not match source %s", key.Address(), req.Source)
+	}
+	if err := req.Verify(); err != nil {
+		return err
+	}
+	if !bytes.Equal(o.Digest(), req.Digest) {
+		return fmt.Errorf("tezos: operation changed after signing request was created")
+	}
+	if err := key.Verify(req.Digest, sig); err != nil {
+		return err
+	}
+	o.WithSignature(sig)
+	return nil
+}
+
+// describePayload returns the summary of signed bytes buf which contain
+// either PACKed Michelson data or a watermarked operation.
+func describePayload(buf []byte) ([]string, error) {
+	if len(buf) == 0 {
+		return nil, fmt.Errorf("empty payload")
+	}
+	if buf[0] == 0x5 {
+		var p micheline.Prim
+		if err := p.UnmarshalBinary(buf[1:]); err != nil {
+			return nil, err
+		}
+		return []string{p.Dump()}, nil
+	}
+	o, err := DecodeOp(buf[1:])
+	if err != nil {
+		return nil, err
+	}
+	res := make([]string, len(o.Contents))
+	for i, v := range o.Contents {
+		res[i] = summarize(v)
+	}
+	return res, nil
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// summarize returns a human readable one-line description of an operation.
+func summarize(op Operation) string {
+	var b strings.Builder
+	b.WriteString(op.Kind().String())
+	switch v := op.(type) {
+	case *Transaction:
+		fmt.Fprintf(&b, " amount=%s destination=%s", v.Amount, v.Destination)
+		if v.Parameters != nil {
+			fmt.Fprintf(&b, " entrypoint=%s", v.Parameters.Entrypoint)
+		}
+	case *Delegation:
+		if v.Delegate.IsValid() {
+			fmt.Fprintf(&b, " delegate=%s", v.Delegate)
+		} else {
+			b.WriteString(" undelegate")
+		}
+	case *Origination:
+		fmt.Fprintf(&b, " balance=%s", v.Balance)
+		if v.Delegate.IsValid() {
+			fmt.Fprintf(&b, " delegate=%s", v.Delegate)
+		}
+	case *Reveal:
+		fmt.Fprintf(&b, " key=%s", v.PublicKey)
+	case *FailingNoop:
+		fmt.Fprintf(&b, " arbitrary=%q", v.Arbitrary)
+	}
+	if op.GetCounter() >= 0 {
+		l := op.Limits()
+		fmt.Fprintf(&b, " fee=%d gas_limit=%d storage_limit=%d counter=%d",
+			l.Fee, l.GasLimit, l.StorageLimit, op.GetCounter())
+	}
+	return b.String()
+}
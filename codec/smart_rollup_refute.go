@@ -5,11 +5,13 @@ package codec
 
 import (
 	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
 
 	"github.com/mavryk-network/mvgo/mavryk"
-	// "encoding/binary"
-	// "io"
-	// "strconv"
 )
 
 // Smart_rollup_refute (tag 204)
@@ -136,6 +138,25 @@ type SmartRollupRefute struct {
 	Refutation SmartRollupRefutation `json:"refutation"`
 }
 
+// Refutation kinds
+const (
+	RefutationKindStart = "start"
+	RefutationKindMove  = "move"
+)
+
+// Input proof kinds
+const (
+	InputProofKindInbox  = "inbox_proof"
+	InputProofKindReveal = "reveal_proof"
+	InputProofKindFirst  = "first_input"
+)
+
+// Reveal proof kinds
+const (
+	RevealProofKindRawData  = "raw_data_proof"
+	RevealProofKindMetadata = "metadata_proof"
+)
+
 type SmartRollupRefutation struct {
 	Kind         string                       `json:"refutation_kind"`
 	PlayerHash   mavryk.SmartRollupCommitHash `json:"player_commitment_hash"`
@@ -144,26 +165,123 @@ type SmartRollupRefutation struct {
 	Step         SmartRollupRefuteStep        `json:"step"`
 }
 
+// SmartRollupRefuteStep is either a dissection (list of ticks) or a final
+// proof. Only one of both fields must be set.
 type SmartRollupRefuteStep struct {
 	Ticks []SmartRollupTick
 	Proof *SmartRollupProof
 }
 
 type SmartRollupProof struct {
-	PvmStep    mavryk.HexBytes       `json:"pvm_step"`
-	InputProof SmartRollupInputProof `json:"input_proof"`
+	PvmStep    mavryk.HexBytes        `json:"pvm_step"`
+	InputProof *SmartRollupInputProof `json:"input_proof,omitempty"`
 }
 
+// SmartRollupTick is a dissection section. State is optional and
+// omitted when zero.
 type SmartRollupTick struct {
 	State mavryk.SmartRollupStateHash `json:"state"`
 	Tick  mavryk.Z                    `json:"tick"`
 }
 
 type SmartRollupInputProof struct {
-	Kind    string          `json:"input_proof_kind"`
-	Level   int64           `json:"level"`
-	Counter mavryk.Z        `json:"message_counter"`
-	Proof   mavryk.HexBytes `json:"serialized_proof"`
+	Kind        string                  `json:"input_proof_kind"`
+	Level       int64                   `json:"level"`
+	Counter     mavryk.Z                `json:"message_counter"`
+	Proof       mavryk.HexBytes         `json:"serialized_proof"`
+	RevealProof *SmartRollupRevealProof `json:"reveal_proof,omitempty"`
+}
+
+type SmartRollupRevealProof struct {
+	Kind    string          `json:"reveal_proof_kind"`
+	RawData mavryk.HexBytes `json:"raw_data,omitempty"`
+}
+
+// NewRefutationStart creates a refutation that starts a game against an
+// opponent's commitment.
+func NewRefutationStart(player, opponent mavryk.SmartRollupCommitHash) SmartRollupRefutation {
+	return SmartRollupRefutation{
+		Kind:         RefutationKindStart,
+		PlayerHash:   player,
+		OpponentHash: opponent,
+	}
+}
+
+// NewRefutationDissection creates a move which dissects the section starting
+// at tick choice. Ticks are usually taken from the rollup node and must
+// contain at least two sections in strictly increasing tick order.
+func NewRefutationDissection(choice mavryk.Z, ticks []SmartRollupTick) (SmartRollupRefutation, error) {
+	if len(ticks) < 2 {
+		return SmartRollupRefutation{}, fmt.Errorf("tezos: dissection requires at least 2 ticks, got %d", len(ticks))
+	}
+	if !ticks[0].State.IsValid() {
+		return SmartRollupRefutation{}, fmt.Errorf("tezos: dissection must start with a state")
+	}
+	for i := 1; i < len(ticks); i++ {
+		if !ticks[i-1].Tick.IsLess(ticks[i].Tick) {
+			return SmartRollupRefutation{}, fmt.Errorf("tezos: dissection ticks must be strictly increasing at position %d", i)
+		}
+	}
+	return SmartRollupRefutation{
+		Kind:   RefutationKindMove,
+		Choice: choice,
+		Step: SmartRollupRefuteStep{
+			Ticks: ticks,
+		},
+	}, nil
+}
+
+// NewRefutationProof creates a final move with a proof for the single tick
+// at choice. Input is optional and only required when the tick reads input.
+func NewRefutationProof(choice mavryk.Z, pvmStep []byte, input *SmartRollupInputProof) SmartRollupRefutation {
+	return SmartRollupRefutation{
+		Kind:   RefutationKindMove,
+		Choice: choice,
+		Step: SmartRollupRefuteStep{
+			Proof: &SmartRollupProof{
+				PvmStep:    pvmStep,
+				InputProof: input,
+			},
+		},
+	}
+}
+
+// NewInboxInputProof creates an input proof for an inbox message.
+func NewInboxInputProof(level int64, counter int64, proof []byte) *SmartRollupInputProof {
+	return &SmartRollupInputProof{
+		Kind:    InputProofKindInbox,
+		Level:   level,
+		Counter: mavryk.NewZ(counter),
+		Proof:   proof,
+	}
+}
+
+// NewRawDataInputProof creates a reveal input proof for raw data.
+func NewRawDataInputProof(data []byte) *SmartRollupInputProof {
+	return &SmartRollupInputProof{
+		Kind: InputProofKindReveal,
+		RevealProof: &SmartRollupRevealProof{
+			Kind:    RevealProofKindRawData,
+			RawData: data,
+		},
+	}
+}
+
+// NewMetadataInputProof creates a reveal input proof for rollup metadata.
+func NewMetadataInputProof() *SmartRollupInputProof {
+	return &SmartRollupInputProof{
+		Kind: InputProofKindReveal,
+		RevealProof: &SmartRollupRevealProof{
+			Kind: RevealProofKindMetadata,
+		},
+	}
+}
+
+// NewFirstInputProof creates an input proof for the first input.
+func NewFirstInputProof() *SmartRollupInputProof {
+	return &SmartRollupInputProof{
+		Kind: InputProofKindFirst,
+	}
 }
 
 func (o SmartRollupRefute) Kind() mavryk.OpType {
@@ -172,15 +290,131 @@ func (o SmartRollupRefute) Kind() mavryk.OpType {
 
 func (o SmartRollupRefute) MarshalJSON() ([]byte, error) {
 	buf := bytes.NewBuffer(nil)
-	// TODO if needed
+	buf.WriteByte('{')
+	buf.WriteString(`"kind":`)
+	buf.WriteString(strconv.Quote(o.Kind().String()))
+	buf.WriteByte(',')
+	o.Manager.EncodeJSON(buf)
+	buf.WriteString(`,"rollup":`)
+	buf.WriteString(strconv.Quote(o.Rollup.String()))
+	buf.WriteString(`,"opponent":`)
+	buf.WriteString(strconv.Quote(o.Opponent.String()))
+	buf.WriteString(`,"refutation":`)
+	if err := o.Refutation.EncodeJSON(buf); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func (r SmartRollupRefutation) MarshalJSON() ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	err := r.EncodeJSON(buf)
+	return buf.Bytes(), err
+}
+
+func (r SmartRollupRefutation) EncodeJSON(buf *bytes.Buffer) error {
+	buf.WriteString(`{"refutation_kind":`)
+	buf.WriteString(strconv.Quote(r.Kind))
+	switch r.Kind {
+	case RefutationKindStart:
+		buf.WriteString(`,"player_commitment_hash":`)
+		buf.WriteString(strconv.Quote(r.PlayerHash.String()))
+		buf.WriteString(`,"opponent_commitment_hash":`)
+		buf.WriteString(strconv.Quote(r.OpponentHash.String()))
+	case RefutationKindMove:
+		buf.WriteString(`,"choice":`)
+		buf.WriteString(strconv.Quote(r.Choice.String()))
+		buf.WriteString(`,"step":`)
+		step, err := json.Marshal(r.Step)
+		if err != nil {
+			return err
+		}
+		buf.Write(step)
+	default:
+		return fmt.Errorf("tezos: invalid refutation kind %q", r.Kind)
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+func (s *SmartRollupRefuteStep) UnmarshalJSON(buf []byte) error {
+	if len(buf) == 0 {
+		return nil
+	}
+	switch buf[0] {
+	case '[':
+		s.Ticks = make([]SmartRollupTick, 0)
+		return json.Unmarshal(buf, &s.Ticks)
+	case '{':
+		s.Proof = &SmartRollupProof{}
+		return json.Unmarshal(buf, s.Proof)
+	default:
+		return fmt.Errorf("tezos: invalid refutation step %q", string(buf))
+	}
+}
+
+func (s SmartRollupRefuteStep) MarshalJSON() ([]byte, error) {
+	if s.Proof != nil {
+		return json.Marshal(s.Proof)
+	}
+	if s.Ticks == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(s.Ticks)
+}
+
+func (t SmartRollupTick) MarshalJSON() ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	buf.WriteByte('{')
+	if t.State.IsValid() {
+		buf.WriteString(`"state":`)
+		buf.WriteString(strconv.Quote(t.State.String()))
+		buf.WriteByte(',')
+	}
+	buf.WriteString(`"tick":`)
+	buf.WriteString(strconv.Quote(t.Tick.String()))
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func (p SmartRollupInputProof) MarshalJSON() ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	buf.WriteString(`{"input_proof_kind":`)
+	buf.WriteString(strconv.Quote(p.Kind))
+	switch p.Kind {
+	case InputProofKindInbox:
+		buf.WriteString(`,"level":`)
+		buf.WriteString(strconv.FormatInt(p.Level, 10))
+		buf.WriteString(`,"message_counter":`)
+		buf.WriteString(strconv.Quote(p.Counter.String()))
+		buf.WriteString(`,"serialized_proof":`)
+		buf.WriteString(strconv.Quote(p.Proof.String()))
+	case InputProofKindReveal:
+		if p.RevealProof == nil {
+			return nil, fmt.Errorf("tezos: missing reveal proof")
+		}
+		buf.WriteString(`,"reveal_proof":{"reveal_proof_kind":`)
+		buf.WriteString(strconv.Quote(p.RevealProof.Kind))
+		if p.RevealProof.Kind == RevealProofKindRawData {
+			buf.WriteString(`,"raw_data":`)
+			buf.WriteString(strconv.Quote(p.RevealProof.RawData.String()))
+		}
+		buf.WriteByte('}')
+	case InputProofKindFirst:
+	default:
+		return nil, fmt.Errorf("tezos: invalid input proof kind %q", p.Kind)
+	}
+	buf.WriteByte('}')
 	return buf.Bytes(), nil
 }
 
 func (o SmartRollupRefute) EncodeBuffer(buf *bytes.Buffer, p *mavryk.Params) error {
 	buf.WriteByte(o.Kind().TagVersion(p.OperationTagsVersion))
 	o.Manager.EncodeBuffer(buf, p)
-	// TODO if needed
-	return nil
+	buf.Write(o.Rollup.Hash()) // 20 byte only
+	buf.Write(o.Opponent.Encode())
+	return o.Refutation.EncodeBuffer(buf)
 }
 
 func (o *SmartRollupRefute) DecodeBuffer(buf *bytes.Buffer, p *mavryk.Params) (err error) {
@@ -190,8 +424,11 @@ func (o *SmartRollupRefute) DecodeBuffer(buf *bytes.Buffer, p *mavryk.Params) (e
 	if err = o.Manager.DecodeBuffer(buf, p); err != nil {
 		return
 	}
-	// TODO if needed
-	return
+	o.Rollup = mavryk.NewAddress(mavryk.AddressTypeSmartRollup, buf.Next(20))
+	if err = o.Opponent.Decode(buf.Next(21)); err != nil {
+		return
+	}
+	return o.Refutation.DecodeBuffer(buf)
 }
 
 func (o SmartRollupRefute) MarshalBinary() ([]byte, error) {
@@ -203,3 +440,194 @@ func (o SmartRollupRefute) MarshalBinary() ([]byte, error) {
 func (o *SmartRollupRefute) UnmarshalBinary(data []byte) error {
 	return o.DecodeBuffer(bytes.NewBuffer(data), mavryk.DefaultParams)
 }
+
+func (r SmartRollupRefutation) EncodeBuffer(buf *bytes.Buffer) error {
+	switch r.Kind {
+	case RefutationKindStart:
+		buf.WriteByte(0x0)
+		buf.Write(r.PlayerHash.Bytes())
+		buf.Write(r.OpponentHash.Bytes())
+	case RefutationKindMove:
+		buf.WriteByte(0x1)
+		r.Choice.EncodeBuffer(buf)
+		switch {
+		case r.Step.Proof != nil:
+			buf.WriteByte(0x1)
+			if err := r.Step.Proof.encodeBuffer(buf); err != nil {
+				return err
+			}
+		default:
+			buf.WriteByte(0x0)
+			ticks := bytes.NewBuffer(nil)
+			for _, v := range r.Step.Ticks {
+				if v.State.IsValid() {
+					ticks.WriteByte(0xff)
+					ticks.Write(v.State.Bytes())
+				} else {
+					ticks.WriteByte(0x0)
+				}
+				v.Tick.EncodeBuffer(ticks)
+			}
+			if err := writeBytesWithLen(buf, ticks.Bytes()); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("tezos: invalid refutation kind %q", r.Kind)
+	}
+	return nil
+}
+
+func (r *SmartRollupRefutation) DecodeBuffer(buf *bytes.Buffer) (err error) {
+	var tag byte
+	if tag, err = readByte(buf.Next(1)); err != nil {
+		return
+	}
+	switch tag {
+	case 0x0:
+		r.Kind = RefutationKindStart
+		if buf.Len() < 64 {
+			return io.ErrShortBuffer
+		}
+		r.PlayerHash = mavryk.NewSmartRollupCommitHash(buf.Next(32))
+		r.OpponentHash = mavryk.NewSmartRollupCommitHash(buf.Next(32))
+	case 0x1:
+		r.Kind = RefutationKindMove
+		if err = r.Choice.DecodeBuffer(buf); err != nil {
+			return
+		}
+		if tag, err = readByte(buf.Next(1)); err != nil {
+			return
+		}
+		var data mavryk.HexBytes
+		if data, err = readBytesWithLen(buf); err != nil {
+			return
+		}
+		switch tag {
+		case 0x0:
+			ticks := bytes.NewBuffer(data)
+			r.Step.Ticks = make([]SmartRollupTick, 0)
+			for ticks.Len() > 0 {
+				var (
+					t  SmartRollupTick
+					ok bool
+				)
+				if ok, err = readBool(ticks.Next(1)); err != nil {
+					return
+				}
+				if ok {
+					if ticks.Len() < 32 {
+						return io.ErrShortBuffer
+					}
+					t.State = mavryk.NewSmartRollupStateHash(ticks.Next(32))
+				}
+				if err = t.Tick.DecodeBuffer(ticks); err != nil {
+					return
+				}
+				r.Step.Ticks = append(r.Step.Ticks, t)
+			}
+		case 0x1:
+			r.Step.Proof = &SmartRollupProof{PvmStep: data}
+			err = r.Step.Proof.decodeInput(buf)
+		default:
+			return fmt.Errorf("tezos: invalid refutation step tag %d", tag)
+		}
+	default:
+		return fmt.Errorf("tezos: invalid refutation tag %d", tag)
+	}
+	return
+}
+
+func (p SmartRollupProof) encodeBuffer(buf *bytes.Buffer) error {
+	if err := writeBytesWithLen(buf, p.PvmStep); err != nil {
+		return err
+	}
+	if p.InputProof == nil {
+		buf.WriteByte(0x0)
+		return nil
+	}
+	buf.WriteByte(0xff)
+	in := p.InputProof
+	switch in.Kind {
+	case InputProofKindInbox:
+		buf.WriteByte(0x0)
+		binary.Write(buf, enc, int32(in.Level))
+		in.Counter.EncodeBuffer(buf)
+		return writeBytesWithLen(buf, in.Proof)
+	case InputProofKindReveal:
+		buf.WriteByte(0x1)
+		if in.RevealProof == nil {
+			return fmt.Errorf("tezos: missing reveal proof")
+		}
+		switch in.RevealProof.Kind {
+		case RevealProofKindRawData:
+			buf.WriteByte(0x0)
+			binary.Write(buf, enc, uint16(len(in.RevealProof.RawData)))
+			buf.Write(in.RevealProof.RawData)
+		case RevealProofKindMetadata:
+			buf.WriteByte(0x1)
+		default:
+			return fmt.Errorf("tezos: invalid reveal proof kind %q", in.RevealProof.Kind)
+		}
+	case InputProofKindFirst:
+		buf.WriteByte(0x2)
+	default:
+		return fmt.Errorf("tezos: invalid input proof kind %q", in.Kind)
+	}
+	return nil
+}
+
+func (p *SmartRollupProof) decodeInput(buf *bytes.Buffer) (err error) {
+	var (
+		ok  bool
+		tag byte
+	)
+	if ok, err = readBool(buf.Next(1)); err != nil || !ok {
+		return
+	}
+	if tag, err = readByte(buf.Next(1)); err != nil {
+		return
+	}
+	in := &SmartRollupInputProof{}
+	switch tag {
+	case 0x0:
+		in.Kind = InputProofKindInbox
+		var level int32
+		if level, err = readInt32(buf.Next(4)); err != nil {
+			return
+		}
+		in.Level = int64(level)
+		if err = in.Counter.DecodeBuffer(buf); err != nil {
+			return
+		}
+		if in.Proof, err = readBytesWithLen(buf); err != nil {
+			return
+		}
+	case 0x1:
+		in.Kind = InputProofKindReveal
+		if tag, err = readByte(buf.Next(1)); err != nil {
+			return
+		}
+		switch tag {
+		case 0x0:
+			in.RevealProof = &SmartRollupRevealProof{Kind: RevealProofKindRawData}
+			if buf.Len() < 2 {
+				return io.ErrShortBuffer
+			}
+			l := int(enc.Uint16(buf.Next(2)))
+			if err = in.RevealProof.RawData.ReadBytes(buf, l); err != nil {
+				return
+			}
+		case 0x1:
+			in.RevealProof = &SmartRollupRevealProof{Kind: RevealProofKindMetadata}
+		default:
+			return fmt.Errorf("tezos: invalid reveal proof tag %d", tag)
+		}
+	case 0x2:
+		in.Kind = InputProofKindFirst
+	default:
+		return fmt.Errorf("tezos: invalid input proof tag %d", tag)
+	}
+	p.InputProof = in
+	return
+}
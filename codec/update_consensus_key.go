@@ -5,16 +5,33 @@ package codec
 
 import (
 	"bytes"
+	"fmt"
 	"strconv"
 
 	"github.com/mavryk-network/mvgo/mavryk"
 )
 
-// UpdateConsensusKey represents "update_consensus_key" operation
+// consensusKeyProofVersion is the first protocol version which encodes an
+// optional BLS proof of possession in update_consensus_key operations.
+const consensusKeyProofVersion = 22
+
+// UpdateConsensusKey represents "update_consensus_key" operation. When rotating
+// to a BLS (mv4) consensus key, newer protocols require a proof of possession
+// which is a BLS signature of the public key by the consensus key itself.
+// Proofs are encoded from protocol v022 on, older protocols reject them.
 type UpdateConsensusKey struct {
 	Manager
-	Amount    mavryk.Z   `json:"amount"`
-	PublicKey mavryk.Key `json:"pk"`
+	Amount    mavryk.Z         `json:"amount"`
+	PublicKey mavryk.Key       `json:"pk"`
+	Proof     mavryk.Signature `json:"proof,omitempty"` // optional, BLS keys only
+}
+
+// WithProof adds a BLS proof of possession for the consensus key.
+func (o *UpdateConsensusKey) WithProof(sig mavryk.Signature) *UpdateConsensusKey {
+	sig = sig.Clone()
+	sig.Type = mavryk.SignatureTypeBls12_381
+	o.Proof = sig
+	return o
 }
 
 func (o UpdateConsensusKey) Kind() mavryk.OpType {
@@ -30,6 +47,10 @@ func (o UpdateConsensusKey) MarshalJSON() ([]byte, error) {
 	o.Manager.EncodeJSON(buf)
 	buf.WriteString(`,"pk":`)
 	buf.WriteString(strconv.Quote(o.PublicKey.String()))
+	if o.Proof.IsValid() {
+		buf.WriteString(`,"proof":`)
+		buf.WriteString(strconv.Quote(o.Proof.String()))
+	}
 	buf.WriteByte('}')
 	return buf.Bytes(), nil
 }
@@ -38,6 +59,21 @@ func (o UpdateConsensusKey) EncodeBuffer(buf *bytes.Buffer, p *mavryk.Params) er
 	buf.WriteByte(o.Kind().TagVersion(p.OperationTagsVersion))
 	o.Manager.EncodeBuffer(buf, p)
 	buf.Write(o.PublicKey.Bytes())
+	if p.Version < consensusKeyProofVersion {
+		if o.Proof.IsValid() {
+			return fmt.Errorf("tezos: consensus key proof requires protocol v%03d+", consensusKeyProofVersion)
+		}
+		return nil
+	}
+	if o.Proof.IsValid() {
+		if o.PublicKey.Type != mavryk.KeyTypeBls12_381 {
+			return fmt.Errorf("tezos: consensus key proof requires a BLS key")
+		}
+		buf.WriteByte(0xff)
+		buf.Write(o.Proof.Data) // raw, without type
+	} else {
+		buf.WriteByte(0x0)
+	}
 	return nil
 }
 
@@ -51,6 +87,19 @@ func (o *UpdateConsensusKey) DecodeBuffer(buf *bytes.Buffer, p *mavryk.Params) (
 	if err = o.PublicKey.DecodeBuffer(buf); err != nil {
 		return
 	}
+	if p.Version >= consensusKeyProofVersion {
+		var ok bool
+		ok, err = readBool(buf.Next(1))
+		if err != nil {
+			return
+		}
+		if ok {
+			if err = o.Proof.UnmarshalBinary(buf.Next(96)); err != nil {
+				return
+			}
+			o.Proof.Type = mavryk.SignatureTypeBls12_381
+		}
+	}
 	return
 }
 
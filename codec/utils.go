@@ -1,4 +1,4 @@
-// Copyright (c) 2020-2022 Blockwatch Data Inc.
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
 // Author: alex@blockwatch.cc
 
 package codec
@@ -133,6 +133,11 @@ func readBytesWithLen(buf *bytes.Buffer) (b mavryk.HexBytes, err error) {
 	if err != nil {
 		return
 	}
+	// don't allocate more than the buffer can provide
+	if int64(l) > int64(buf.Len()) {
+		err = io.ErrShortBuffer
+		return
+	}
 	err = b.ReadBytes(buf, int(l))
 	return
 }
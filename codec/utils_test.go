@@ -0,0 +1,33 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package codec
+
+import (
+	"bytes"
+	"io"
+	"runtime"
+	"testing"
+)
+
+func TestReadBytesWithLen(t *testing.T) {
+	b, err := readBytesWithLen(bytes.NewBuffer([]byte{0, 0, 0, 2, 0xa, 0xb, 0xc}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(b, []byte{0xa, 0xb}) {
+		t.Errorf("bytes mismatch, want=0a0b have=%x", []byte(b))
+	}
+
+	// a length prefix beyond the buffer end must fail without allocating
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err = readBytesWithLen(bytes.NewBuffer([]byte{0x7f, 0xff, 0xff, 0xff, 0xa}))
+	runtime.ReadMemStats(&after)
+	if err != io.ErrShortBuffer {
+		t.Errorf("want short buffer error, have %v", err)
+	}
+	if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+		t.Errorf("allocated %d bytes for a truncated buffer", n)
+	}
+}
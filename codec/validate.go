@@ -0,0 +1,137 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package codec
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+)
+
+// ValidationError describes a single structural problem found by Op.Validate.
+type ValidationError struct {
+	Pos  int           // position in contents list, -1 when the problem concerns the group
+	Kind mavryk.OpType // operation kind at Pos
+	Msg  string        // human readable problem description
+}
+
+func (e ValidationError) Error() string {
+	if e.Pos < 0 {
+		return "tezos: " + e.Msg
+	}
+	return fmt.Sprintf("tezos: op #%d (%s): %s", e.Pos, e.Kind, e.Msg)
+}
+
+// sourcer is implemented by all manager operations.
+type sourcer interface {
+	GetSource() mavryk.Address
+}
+
+// Validate performs structural checks on the operation before it is handed
+// to a signer. It checks for a valid branch, consistent operation tags under
+// the configured protocol params, a consistent validation pass, a single
+// source and monotonically increasing counters for manager operations and
+// gas, storage and size limits. Returns a list of all problems found or nil
+// when the operation looks well formed.
+func (o *Op) Validate() []error {
+	var errs []error
+	fail := func(pos int, kind mavryk.OpType, format string, args ...any) {
+		errs = append(errs, ValidationError{
+			Pos:  pos,
+			Kind: kind,
+			Msg:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	p := o.Params
+	if p == nil {
+		p = mavryk.DefaultParams
+	}
+
+	if !o.Branch.IsValid() {
+		fail(-1, mavryk.OpTypeInvalid, "missing branch")
+	}
+	if len(o.Contents) == 0 {
+		fail(-1, mavryk.OpTypeInvalid, "empty operation contents")
+		return errs
+	}
+
+	var (
+		pass        = o.Contents[0].Kind().ListId()
+		lastCounter int64
+		source      mavryk.Address
+		gasSum      int64
+		buf         = bytes.NewBuffer(nil)
+	)
+	for i, v := range o.Contents {
+		kind := v.Kind()
+
+		// tag consistency
+		tag := kind.TagVersion(p.OperationTagsVersion)
+		if tag == 255 {
+			fail(i, kind, "unsupported by protocol tags version %d", p.OperationTagsVersion)
+		} else {
+			buf.Reset()
+			if err := v.EncodeBuffer(buf, p); err != nil {
+				fail(i, kind, "encoding failed: %v", err)
+			} else if b := buf.Bytes(); len(b) == 0 || b[0] != tag {
+				fail(i, kind, "encoded tag does not match expected tag %d", tag)
+			}
+		}
+
+		// validation pass consistency
+		switch id := kind.ListId(); {
+		case kind == mavryk.OpTypeFailingNoop && len(o.Contents) > 1:
+			fail(i, kind, "failing noop cannot be batched")
+		case id != pass:
+			fail(i, kind, "validation pass %d does not match group validation pass %d", id, pass)
+		}
+
+		// manager operations only
+		counter := v.GetCounter()
+		if counter < 0 {
+			continue
+		}
+		if s, ok := v.(sourcer); ok {
+			switch {
+			case !s.GetSource().IsValid():
+				fail(i, kind, "missing source")
+			case !source.IsValid():
+				source = s.GetSource()
+			case !source.Equal(s.GetSource()):
+				fail(i, kind, "source %s differs from group source %s", s.GetSource(), source)
+			}
+		}
+		switch {
+		case counter == 0:
+			fail(i, kind, "missing counter")
+		case lastCounter > 0 && counter != lastCounter+1:
+			fail(i, kind, "counter %d does not follow previous counter %d", counter, lastCounter)
+		}
+		lastCounter = counter
+
+		l := v.Limits()
+		if l.Fee < 0 || l.GasLimit < 0 || l.StorageLimit < 0 {
+			fail(i, kind, "negative limits")
+		}
+		if l.GasLimit > p.HardGasLimitPerOperation {
+			fail(i, kind, "gas limit %d exceeds max %d", l.GasLimit, p.HardGasLimitPerOperation)
+		}
+		if l.StorageLimit > p.HardStorageLimitPerOperation {
+			fail(i, kind, "storage limit %d exceeds max %d", l.StorageLimit, p.HardStorageLimitPerOperation)
+		}
+		gasSum += l.GasLimit
+	}
+
+	if p.HardGasLimitPerBlock > 0 && gasSum > p.HardGasLimitPerBlock {
+		fail(-1, mavryk.OpTypeInvalid, "total gas limit %d exceeds block max %d", gasSum, p.HardGasLimitPerBlock)
+	}
+	if o.Branch.IsValid() && p.MaxOperationDataLength > 0 {
+		if sz := len(o.Bytes()); sz > p.MaxOperationDataLength {
+			fail(-1, mavryk.OpTypeInvalid, "operation size %d exceeds max %d", sz, p.MaxOperationDataLength)
+		}
+	}
+	return errs
+}
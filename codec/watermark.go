@@ -0,0 +1,30 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package codec
+
+// Watermarks defines the domain separation bytes which are prepended to
+// operations and block headers before signing. Forks and private deployments
+// may use different values than Tezos mainnet.
+type Watermarks struct {
+	Operation      byte
+	Block          byte
+	Preendorsement byte
+	Endorsement    byte
+}
+
+// DefaultWatermarks are the watermarks used by Tenderbake networks.
+var DefaultWatermarks = Watermarks{
+	Operation:      OperationWatermark,
+	Block:          TenderbakeBlockWatermark,
+	Preendorsement: TenderbakePreendorsementWatermark,
+	Endorsement:    TenderbakeEndorsementWatermark,
+}
+
+// orDefault returns w or the default watermarks when w is nil.
+func (w *Watermarks) orDefault() *Watermarks {
+	if w == nil {
+		return &DefaultWatermarks
+	}
+	return w
+}
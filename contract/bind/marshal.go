@@ -59,6 +59,9 @@ func MarshalPrim(v any, optimized bool) (micheline.Prim, error) {
 		}
 		return micheline.NewString(t.String()), nil
 	case mavryk.ChainIdHash:
+		if optimized {
+			return micheline.NewBytes(t.Bytes()), nil
+		}
 		return micheline.NewString(t.String()), nil
 	}
 
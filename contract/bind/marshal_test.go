@@ -0,0 +1,51 @@
+package bind
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+	"github.com/mavryk-network/mvgo/micheline"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarshalPrimRandom(t *testing.T) {
+	cases := map[string]struct {
+		typ micheline.Prim
+		dst any
+	}{
+		"int":         {typ: micheline.NewCode(micheline.T_INT), dst: (*big.Int)(nil)},
+		"nat":         {typ: micheline.NewCode(micheline.T_NAT), dst: (*big.Int)(nil)},
+		"string":      {typ: micheline.NewCode(micheline.T_STRING), dst: ""},
+		"bytes":       {typ: micheline.NewCode(micheline.T_BYTES), dst: []byte{}},
+		"bool":        {typ: micheline.NewCode(micheline.T_BOOL), dst: false},
+		"timestamp":   {typ: micheline.NewCode(micheline.T_TIMESTAMP), dst: time.Time{}},
+		"address":     {typ: micheline.NewCode(micheline.T_ADDRESS), dst: mavryk.Address{}},
+		"key":         {typ: micheline.NewCode(micheline.T_KEY), dst: mavryk.Key{}},
+		"signature":   {typ: micheline.NewCode(micheline.T_SIGNATURE), dst: mavryk.Signature{}},
+		"chain_id":    {typ: micheline.NewCode(micheline.T_CHAIN_ID), dst: mavryk.ChainIdHash{}},
+		"int list":    {typ: micheline.NewCode(micheline.T_LIST, micheline.NewCode(micheline.T_INT)), dst: []*big.Int{}},
+		"address set": {typ: micheline.NewSetType(micheline.NewCode(micheline.T_ADDRESS)), dst: []mavryk.Address{}},
+	}
+
+	for _, optimized := range []bool{false, true} {
+		g := micheline.NewGenerator(1)
+		g.Optimized = optimized
+		for name, c := range cases {
+			t.Run(name, func(t *testing.T) {
+				vals, err := g.Values(micheline.NewType(c.typ), 50)
+				require.NoError(t, err)
+				for _, v := range vals {
+					dst := reflect.New(reflect.TypeOf(c.dst))
+					require.NoError(t, UnmarshalPrim(v, dst.Interface()), v.Dump())
+					p, err := MarshalPrim(dst.Elem().Interface(), optimized)
+					require.NoError(t, err)
+					require.True(t, p.IsEqual(v), "want=%s have=%s", v.Dump(), p.Dump())
+				}
+			})
+		}
+	}
+}
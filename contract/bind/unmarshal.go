@@ -159,7 +159,7 @@ func unmarshalBytes(b []byte, v reflect.Value) error {
 		v.Set(reflect.ValueOf(b))
 	case tAddress:
 		var addr mavryk.Address
-		if err := addr.UnmarshalBinary(b); err != nil {
+		if err := addr.Decode(b); err != nil {
 			return errors.Wrapf(err, "failed to parse address: %v", b)
 		}
 		v.Set(reflect.ValueOf(addr))
@@ -63,3 +63,22 @@ type nestedUnmarshaler struct {
 func (u *nestedUnmarshaler) UnmarshalPrim(prim micheline.Prim) error {
 	return UnmarshalPrimPaths(prim, map[string]any{"l": &u.U, "r": &u.S})
 }
+
+func TestMarshalChainIdOptimized(t *testing.T) {
+	id := mavryk.MustParseChainIdHash("NetXnHfVqm9iesp")
+	p, err := MarshalPrim(id, true)
+	require.NoError(t, err)
+	require.Equal(t, micheline.PrimBytes, p.Type)
+	require.Equal(t, id.Bytes(), p.Bytes)
+
+	p, err = MarshalPrim(id, false)
+	require.NoError(t, err)
+	require.Equal(t, micheline.PrimString, p.Type)
+	require.Equal(t, id.String(), p.String)
+}
+
+func TestUnmarshalOptimizedAddress(t *testing.T) {
+	var addr mavryk.Address
+	require.NoError(t, UnmarshalPrim(micheline.NewBytes(testAddress.Encode()), &addr))
+	require.Equal(t, testAddress, addr)
+}
@@ -0,0 +1,358 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package contract
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+	"github.com/mavryk-network/mvgo/micheline"
+	"github.com/mavryk-network/mvgo/rpc"
+)
+
+// ConfigType is a hint how to decode a config value.
+type ConfigType byte
+
+const (
+	ConfigAuto    ConfigType = iota // decode by Michelson type
+	ConfigBytes                     // []byte
+	ConfigString                    // string, bytes are unpacked or read as UTF-8
+	ConfigNat                       // mavryk.Z, bytes are unpacked or read as decimal string
+	ConfigAddress                   // mavryk.Address, bytes are unpacked or read as binary address
+	ConfigBool                      // bool, bytes are unpacked or read as "true"/"false"
+)
+
+// ConfigValues maps config keys to decoded values.
+type ConfigValues map[string]any
+
+// ConfigCallback is called by ConfigReader.Watch when config values change.
+// Changed lists all added, updated and removed keys.
+type ConfigCallback func(ctx context.Context, vals ConfigValues, changed []string)
+
+// ConfigReader reads key-value config contracts which store settings in a
+// map or big_map with string keys and bytes, string or nat values. Plain
+// maps are read entirely. Big_map keys cannot be listed, so only keys with
+// a type hint are read from big_maps.
+type ConfigReader struct {
+	Field string                // storage field of the config map, empty selects the first string keyed map
+	Hints map[string]ConfigType // decoding hints per key
+
+	contract *Contract
+	raw      map[string][]byte // last seen values, used to detect changes
+	last     mavryk.BlockHash  // last block whose state is reflected in raw
+}
+
+func NewConfigReader(c *Contract) *ConfigReader {
+	return &ConfigReader{
+		Hints:    make(map[string]ConfigType),
+		contract: c,
+	}
+}
+
+// WithField selects the storage field which contains the config map.
+func (r *ConfigReader) WithField(name string) *ConfigReader {
+	r.Field = name
+	return r
+}
+
+// WithHint sets a decoding hint for key.
+func (r *ConfigReader) WithHint(key string, typ ConfigType) *ConfigReader {
+	r.Hints[key] = typ
+	return r
+}
+
+// Read returns config values from the contract's current storage.
+func (r *ConfigReader) Read(ctx context.Context) (ConfigValues, error) {
+	vals, _, err := r.read(ctx, rpc.Head)
+	return vals, err
+}
+
+// Watch reads config values and calls cb once with all values and again
+// whenever values change. Watch blocks until ctx is canceled. For each new
+// block Watch fetches manager operations and re-reads storage only when an
+// applied transaction calls the config contract, or when blocks were
+// skipped or reorganized and the previous state is unknown.
+func (r *ConfigReader) Watch(ctx context.Context, cb ConfigCallback) error {
+	vals, raw, err := r.read(ctx, rpc.Head)
+	if err != nil {
+		return err
+	}
+	r.raw = raw
+	r.last = mavryk.BlockHash{}
+	cb(ctx, vals, sortedKeys(raw))
+
+	cli := r.contract.rpc
+	heads := make(chan *rpc.BlockHeaderLogEntry, 16)
+	cli.BlockObserver.Listen(cli)
+	id := cli.BlockObserver.Subscribe(mavryk.ZeroOpHash, func(head *rpc.BlockHeaderLogEntry, _ int64, _ int, _ int, _ bool) bool {
+		// never block the observer, skip heads when busy
+		select {
+		case heads <- head:
+		default:
+		}
+		return false
+	})
+	defer cli.BlockObserver.Unsubscribe(id)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case head := <-heads:
+			vals, changed, err := r.update(ctx, head)
+			if err != nil {
+				cli.Log.Warnf("config %s: block %d: %v", r.contract.addr, head.Level, err)
+				continue
+			}
+			if len(changed) > 0 {
+				cb(ctx, vals, changed)
+			}
+		}
+	}
+}
+
+// update processes a new head and returns config values and changed keys
+// when storage was re-read and differs from the last seen state.
+func (r *ConfigReader) update(ctx context.Context, head *rpc.BlockHeaderLogEntry) (ConfigValues, []string, error) {
+	if r.last.IsValid() && r.last.Equal(head.Predecessor) {
+		ok, err := r.touched(ctx, head.Hash)
+		if err != nil {
+			r.last = mavryk.BlockHash{}
+			return nil, nil, err
+		}
+		if !ok {
+			r.last = head.Hash
+			return nil, nil, nil
+		}
+	}
+	vals, raw, err := r.read(ctx, head.Hash)
+	if err != nil {
+		r.last = mavryk.BlockHash{}
+		return nil, nil, err
+	}
+	changed := diffConfig(r.raw, raw)
+	r.raw = raw
+	r.last = head.Hash
+	return vals, changed, nil
+}
+
+// touched returns true when an applied manager operation in block id called
+// the config contract directly or as internal transaction.
+func (r *ConfigReader) touched(ctx context.Context, id rpc.BlockID) (bool, error) {
+	ops, err := r.contract.rpc.GetBlockOperationList(ctx, id, 3)
+	if err != nil {
+		return false, err
+	}
+	addr := r.contract.addr
+	for _, op := range ops {
+		for _, v := range op.Contents {
+			if !v.Result().IsSuccess() {
+				continue
+			}
+			if tx, ok := v.(*rpc.Transaction); ok && tx.Destination.Equal(addr) {
+				return true, nil
+			}
+			for _, in := range v.Meta().InternalResults {
+				if in.Result.IsSuccess() && in.Destination != nil && in.Destination.Equal(addr) {
+					return true, nil
+				}
+			}
+		}
+	}
+	return false, nil
+}
+
+func (r *ConfigReader) read(ctx context.Context, id rpc.BlockID) (ConfigValues, map[string][]byte, error) {
+	c := r.contract
+	if c.script == nil {
+		if err := c.Resolve(ctx); err != nil {
+			return nil, nil, err
+		}
+	}
+	store, err := c.rpc.GetContractStorage(ctx, c.addr, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	typ, val, ok := findConfigMap(c.script.Code.Storage, store, r.Field)
+	if !ok {
+		return nil, nil, fmt.Errorf("contract %s: no config map found", c.addr)
+	}
+
+	prims := make(map[string]micheline.Prim)
+	switch typ.OpCode {
+	case micheline.T_MAP:
+		for _, v := range val.Args {
+			if v.OpCode != micheline.D_ELT || len(v.Args) != 2 {
+				continue
+			}
+			prims[v.Args[0].String] = v.Args[1]
+		}
+	case micheline.T_BIG_MAP:
+		keyType := micheline.NewType(typ.Args[0])
+		for name := range r.Hints {
+			key, err := micheline.NewKey(keyType, micheline.NewString(name))
+			if err != nil {
+				return nil, nil, err
+			}
+			v, err := c.rpc.GetBigmapValue(ctx, val.Int.Int64(), key.Hash(), id)
+			if err != nil {
+				if rpc.ErrorStatus(err) == http.StatusNotFound {
+					continue
+				}
+				return nil, nil, err
+			}
+			prims[name] = v
+		}
+	}
+
+	vals := make(ConfigValues, len(prims))
+	raw := make(map[string][]byte, len(prims))
+	for name, p := range prims {
+		v, err := decodeConfigValue(p, r.Hints[name])
+		if err != nil {
+			return nil, nil, fmt.Errorf("config key %q: %w", name, err)
+		}
+		vals[name] = v
+		raw[name], _ = p.MarshalBinary()
+	}
+	return vals, raw, nil
+}
+
+// findConfigMap returns the type and value of the first map or big_map with
+// string keys in storage, or the one named field.
+func findConfigMap(typ, storage micheline.Prim, field string) (micheline.Prim, micheline.Prim, bool) {
+	var (
+		resTyp, resVal micheline.Prim
+		found          bool
+	)
+	stack := micheline.NewStack(storage)
+	_ = typ.Walk(func(p micheline.Prim) error {
+		if found {
+			return micheline.PrimSkip
+		}
+		val := stack.Pop()
+		switch p.OpCode {
+		case micheline.K_STORAGE:
+			stack.Push(val)
+			return nil
+
+		case micheline.T_PAIR:
+			switch {
+			case val.IsScalar() || val.LooksLikeContainer():
+				stack.Push(val)
+			default:
+				stack.Push(val.Args...)
+			}
+			return nil
+
+		case micheline.T_MAP, micheline.T_BIG_MAP:
+			if p.Args[0].OpCode != micheline.T_STRING {
+				return micheline.PrimSkip
+			}
+			if field != "" && p.GetVarAnnoAny() != field {
+				return micheline.PrimSkip
+			}
+			resTyp, resVal, found = p, val, true
+			return micheline.PrimSkip
+
+		default:
+			return micheline.PrimSkip
+		}
+	})
+	return resTyp, resVal, found
+}
+
+func decodeConfigValue(p micheline.Prim, typ ConfigType) (any, error) {
+	if typ != ConfigAuto && typ != ConfigBytes && p.Type == micheline.PrimBytes {
+		if up, err := p.Unpack(); err == nil {
+			p = up
+		}
+	}
+	switch typ {
+	case ConfigAuto:
+		switch p.Type {
+		case micheline.PrimString:
+			return p.String, nil
+		case micheline.PrimBytes:
+			return p.Bytes, nil
+		case micheline.PrimInt:
+			return mavryk.NewBigZ(p.Int), nil
+		default:
+			return p, nil
+		}
+	case ConfigBytes:
+		switch p.Type {
+		case micheline.PrimBytes:
+			return p.Bytes, nil
+		case micheline.PrimString:
+			return []byte(p.String), nil
+		}
+	case ConfigString:
+		switch p.Type {
+		case micheline.PrimString:
+			return p.String, nil
+		case micheline.PrimBytes:
+			return string(p.Bytes), nil
+		}
+	case ConfigNat:
+		switch p.Type {
+		case micheline.PrimInt:
+			return mavryk.NewBigZ(p.Int), nil
+		case micheline.PrimString:
+			return mavryk.ParseZ(p.String)
+		case micheline.PrimBytes:
+			return mavryk.ParseZ(string(p.Bytes))
+		}
+	case ConfigAddress:
+		switch p.Type {
+		case micheline.PrimString:
+			return mavryk.ParseAddress(p.String)
+		case micheline.PrimBytes:
+			var a mavryk.Address
+			err := a.Decode(p.Bytes)
+			return a, err
+		}
+	case ConfigBool:
+		switch {
+		case p.OpCode == micheline.D_TRUE:
+			return true, nil
+		case p.OpCode == micheline.D_FALSE:
+			return false, nil
+		case p.Type == micheline.PrimString:
+			return strconv.ParseBool(p.String)
+		case p.Type == micheline.PrimBytes:
+			return strconv.ParseBool(string(p.Bytes))
+		}
+	}
+	return nil, fmt.Errorf("cannot decode %s as config type %d", p.Type, typ)
+}
+
+func diffConfig(prev, next map[string][]byte) []string {
+	changed := make([]string, 0)
+	for _, k := range sortedKeys(next) {
+		if v, ok := prev[k]; !ok || !bytes.Equal(v, next[k]) {
+			changed = append(changed, k)
+		}
+	}
+	for _, k := range sortedKeys(prev) {
+		if _, ok := next[k]; !ok {
+			changed = append(changed, k)
+		}
+	}
+	return changed
+}
+
+func sortedKeys(m map[string][]byte) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
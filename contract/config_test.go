@@ -0,0 +1,221 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package contract
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+	"github.com/mavryk-network/mvgo/micheline"
+	"github.com/mavryk-network/mvgo/rpc"
+	"github.com/mavryk-network/mvgo/rpc/rpctest"
+)
+
+var testConfigContract = mavryk.MustParseAddress("KT1Puc9St8wdNoGtLiD2WXaHbWU7styaxYhD")
+
+func TestDecodeConfigValue(t *testing.T) {
+	packed := func(p micheline.Prim) micheline.Prim {
+		buf, err := p.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return micheline.NewBytes(append([]byte{0x5}, buf...))
+	}
+	tests := []struct {
+		name string
+		prim micheline.Prim
+		typ  ConfigType
+		want any
+	}{
+		{"auto_string", micheline.NewString("a"), ConfigAuto, "a"},
+		{"auto_bytes", micheline.NewBytes([]byte{1}), ConfigAuto, []byte{1}},
+		{"auto_int", micheline.NewInt64(5), ConfigAuto, mavryk.NewZ(5)},
+		{"bytes_string", micheline.NewString("ab"), ConfigBytes, []byte("ab")},
+		{"string_bytes", micheline.NewBytes([]byte("ab")), ConfigString, "ab"},
+		{"string_packed", packed(micheline.NewString("ab")), ConfigString, "ab"},
+		{"nat_int", micheline.NewInt64(7), ConfigNat, mavryk.NewZ(7)},
+		{"nat_string", micheline.NewString("7"), ConfigNat, mavryk.NewZ(7)},
+		{"nat_bytes", micheline.NewBytes([]byte("100")), ConfigNat, mavryk.NewZ(100)},
+		{"nat_packed", packed(micheline.NewInt64(100)), ConfigNat, mavryk.NewZ(100)},
+		{"bool_prim", micheline.NewCode(micheline.D_TRUE), ConfigBool, true},
+		{"bool_string", micheline.NewString("false"), ConfigBool, false},
+		{"bool_bytes", micheline.NewBytes([]byte("true")), ConfigBool, true},
+		{"bool_packed", packed(micheline.NewCode(micheline.D_FALSE)), ConfigBool, false},
+		{"address_string", micheline.NewString(testNftOwner.String()), ConfigAddress, testNftOwner},
+		{"address_bytes", micheline.NewBytes(testNftOwner.EncodePadded()), ConfigAddress, testNftOwner},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			have, err := decodeConfigValue(tt.prim, tt.typ)
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch want := tt.want.(type) {
+			case mavryk.Z:
+				if z, ok := have.(mavryk.Z); !ok || !z.Equal(want) {
+					t.Errorf("want %v, have %v", want, have)
+				}
+			case mavryk.Address:
+				if a, ok := have.(mavryk.Address); !ok || !a.Equal(want) {
+					t.Errorf("want %v, have %v", want, have)
+				}
+			default:
+				if !reflect.DeepEqual(have, want) {
+					t.Errorf("want %#v, have %#v", want, have)
+				}
+			}
+		})
+	}
+
+	if _, err := decodeConfigValue(micheline.NewBytes([]byte("x")), ConfigNat); err == nil {
+		t.Errorf("expected error for invalid nat bytes")
+	}
+}
+
+// testConfigNode serves a config contract whose storage is a string to
+// bytes map.
+type testConfigNode struct {
+	*rpctest.Node
+	mu      sync.Mutex
+	storage string
+	reads   int
+}
+
+func newTestConfigNode(t *testing.T) *testConfigNode {
+	t.Helper()
+	n := &testConfigNode{
+		Node:    rpctest.NewNode(nil),
+		storage: `[{"prim":"Elt","args":[{"string":"fee"},{"bytes":"313030"}]},{"prim":"Elt","args":[{"string":"paused"},{"bytes":"66616c7365"}]}]`,
+	}
+	code := `[{"prim":"parameter","args":[{"prim":"unit"}]},` +
+		`{"prim":"storage","args":[{"prim":"map","args":[{"prim":"string"},{"prim":"bytes"}],"annots":["%config"]}]},` +
+		`{"prim":"code","args":[[{"prim":"CDR"},{"prim":"NIL","args":[{"prim":"operation"}]},{"prim":"PAIR"}]]}]`
+	n.Handle(http.MethodPost, n.path("head")+"/script/normalized", func(w http.ResponseWriter, _ *http.Request) {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"code":` + code + `,"storage":` + n.storage + `}`))
+	})
+	n.serveStorage("head")
+	t.Cleanup(n.Close)
+	return n
+}
+
+func (n *testConfigNode) path(id string) string {
+	return "/chains/main/blocks/" + id + "/context/contracts/" + testConfigContract.String()
+}
+
+// serveStorage serves the contract storage at block id and counts reads.
+func (n *testConfigNode) serveStorage(id string) {
+	n.Handle(http.MethodGet, n.path(id)+"/storage", func(w http.ResponseWriter, _ *http.Request) {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		n.reads++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(n.storage))
+	})
+}
+
+// bake bakes a block which serves storage. When call is true the block
+// contains a transaction to the config contract.
+func (n *testConfigNode) bake(call bool) *rpc.BlockHeaderLogEntry {
+	b := n.Bake()
+	n.serveStorage(b.Hash.String())
+	if call {
+		n.Handle(http.MethodGet, "/chains/main/blocks/"+b.Hash.String()+"/operations/3", func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`[{"contents":[{"kind":"transaction","source":"` + testNftOwner.String() + `",` +
+				`"fee":"0","counter":"1","gas_limit":"0","storage_limit":"0","amount":"0",` +
+				`"destination":"` + testConfigContract.String() + `",` +
+				`"metadata":{"operation_result":{"status":"applied"}}}]}]`))
+		})
+	}
+	return b.LogEntry()
+}
+
+func (n *testConfigNode) setStorage(s string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.storage = s
+}
+
+func (n *testConfigNode) numReads() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.reads
+}
+
+func TestConfigReader(t *testing.T) {
+	node := newTestConfigNode(t)
+	cli, err := node.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	ctx := context.Background()
+
+	r := NewConfigReader(NewContract(testConfigContract, cli)).
+		WithField("config").
+		WithHint("fee", ConfigNat).
+		WithHint("paused", ConfigBool)
+	vals, err := r.Read(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fee, ok := vals["fee"].(mavryk.Z); !ok || fee.Int64() != 100 {
+		t.Errorf("bad fee %v", vals["fee"])
+	}
+	if paused, ok := vals["paused"].(bool); !ok || paused {
+		t.Errorf("bad paused %v", vals["paused"])
+	}
+
+	// the first block after start is always read
+	reads := node.numReads()
+	_, changed, err := r.update(ctx, node.bake(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"fee", "paused"}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("want changed %v, have %v", want, changed)
+	}
+	reads++
+
+	// blocks which do not call the contract are skipped
+	_, changed, err = r.update(ctx, node.bake(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changed) > 0 || node.numReads() != reads {
+		t.Errorf("unexpected read on untouched block, changed %v", changed)
+	}
+
+	// calls re-read storage
+	node.setStorage(`[{"prim":"Elt","args":[{"string":"fee"},{"bytes":"323030"}]},{"prim":"Elt","args":[{"string":"paused"},{"bytes":"66616c7365"}]}]`)
+	vals, changed, err = r.update(ctx, node.bake(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reads++
+	if want := []string{"fee"}; !reflect.DeepEqual(changed, want) || node.numReads() != reads {
+		t.Errorf("want changed %v, have %v", want, changed)
+	}
+	if fee, ok := vals["fee"].(mavryk.Z); !ok || fee.Int64() != 200 {
+		t.Errorf("bad fee %v", vals["fee"])
+	}
+
+	// gaps force a re-read
+	node.bake(false)
+	_, changed, err = r.update(ctx, node.bake(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reads++
+	if len(changed) > 0 || node.numReads() != reads {
+		t.Errorf("want re-read after gap, have %d reads, changed %v", node.numReads(), changed)
+	}
+}
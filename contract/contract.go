@@ -74,17 +74,44 @@ type Contract struct {
 	rpc    *rpc.Client       // the RPC client to use for queries and calls
 }
 
-func NewContract(addr mavryk.Address, cli *rpc.Client) *Contract {
-	return &Contract{
+// ContractOption configures a contract created with NewContract.
+type ContractOption func(*Contract)
+
+// WithScript sets a known script so that Resolve is not required.
+func WithScript(script *micheline.Script) ContractOption {
+	return func(c *Contract) {
+		c.script = script
+	}
+}
+
+// WithStorage sets a known storage value.
+func WithStorage(store *micheline.Prim) ContractOption {
+	return func(c *Contract) {
+		c.store = store
+	}
+}
+
+// WithMetadata sets known TZIP-16 metadata so that ResolveMetadata is not
+// required.
+func WithMetadata(meta *Tz16) ContractOption {
+	return func(c *Contract) {
+		c.meta = meta
+	}
+}
+
+func NewContract(addr mavryk.Address, cli *rpc.Client, opts ...ContractOption) *Contract {
+	c := &Contract{
 		addr: addr,
 		rpc:  cli,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
-func NewEmptyContract(cli *rpc.Client) *Contract {
-	return &Contract{
-		rpc: cli,
-	}
+func NewEmptyContract(cli *rpc.Client, opts ...ContractOption) *Contract {
+	return NewContract(mavryk.Address{}, cli, opts...)
 }
 
 func (c *Contract) Client() *rpc.Client {
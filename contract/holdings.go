@@ -0,0 +1,318 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package contract
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+	"github.com/mavryk-network/mvgo/micheline"
+	"github.com/mavryk-network/mvgo/rpc"
+)
+
+// NftHolding is a token owned by an address.
+type NftHolding struct {
+	Contract mavryk.Address `json:"contract"`
+	TokenId  mavryk.Z       `json:"token_id"`
+	Balance  mavryk.Z       `json:"balance"`
+	Metadata *TokenMetadata `json:"metadata,omitempty"`
+}
+
+// NftDiscoveryFunc returns candidate FA2 contracts for an owner, e.g. from
+// an indexer or a wallet's local history.
+type NftDiscoveryFunc func(ctx context.Context, owner mavryk.Address) ([]mavryk.Address, error)
+
+// NftScanOptions control how ScanNftHoldings enumerates tokens.
+type NftScanOptions struct {
+	Contracts    []mavryk.Address // FA2 contracts to scan
+	Discover     NftDiscoveryFunc // optional, adds contracts to scan
+	TokenIds     []mavryk.Z       // optional candidate token ids, derived from storage counters when empty
+	MaxTokens    int              // max number of derived token ids per contract (default 1000)
+	BatchSize    int              // max number of token ids per balance_of call (default 100)
+	WithMetadata bool             // resolve token metadata for holdings
+}
+
+// NftContractError is the error for a single contract that could not be
+// scanned.
+type NftContractError struct {
+	Contract mavryk.Address
+	Err      error
+}
+
+func (e NftContractError) Error() string {
+	return e.Contract.String() + ": " + e.Err.Error()
+}
+
+func (e NftContractError) Unwrap() error {
+	return e.Err
+}
+
+// NftScanError lists all contracts that could not be scanned.
+type NftScanError []NftContractError
+
+func (e NftScanError) Error() string {
+	msgs := make([]string, len(e))
+	for i, v := range e {
+		msgs[i] = v.Error()
+	}
+	return fmt.Sprintf("contract: scanning %d contract(s) failed: %s", len(e), strings.Join(msgs, "; "))
+}
+
+// storage fields which count minted tokens
+var nftCounterFields = []struct {
+	name      string
+	inclusive bool
+}{
+	{"next_token_id", false},
+	{"all_tokens", false},
+	{"token_counter", false},
+	{"last_token_id", true},
+}
+
+// ScanNftHoldings enumerates FA2 tokens owned by owner across contracts. For
+// contracts with a known NFT ledger schema (see DetectNftLedger) it lists all
+// ledger keys once and matches them against the key hashes of candidate token
+// ids, so only existing entries are fetched. When the node does not serve the
+// raw bigmap key list it looks up each candidate directly. Other contracts are
+// queried with batched balance_of calls. Contracts which are not FA2 are
+// skipped. Metadata is resolved on a best effort basis.
+//
+// A contract which fails to scan does not abort the scan. Holdings of all
+// other contracts are returned together with an NftScanError that lists the
+// failed contracts.
+func ScanNftHoldings(ctx context.Context, cli *rpc.Client, owner mavryk.Address, opts NftScanOptions) ([]NftHolding, error) {
+	contracts := opts.Contracts
+	if opts.Discover != nil {
+		more, err := opts.Discover(ctx, owner)
+		if err != nil {
+			return nil, err
+		}
+		contracts = append(append([]mavryk.Address{}, contracts...), more...)
+	}
+	if opts.MaxTokens <= 0 {
+		opts.MaxTokens = 1000
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	var (
+		seen = make(map[mavryk.Address]struct{})
+		res  = make([]NftHolding, 0)
+		errs NftScanError
+	)
+	for _, addr := range contracts {
+		if _, ok := seen[addr]; ok {
+			continue
+		}
+		seen[addr] = struct{}{}
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		c := NewContract(addr, cli)
+		if err := c.Resolve(ctx); err != nil {
+			errs = append(errs, NftContractError{addr, err})
+			continue
+		}
+		if !c.IsFA2() {
+			continue
+		}
+		h, err := scanNftContract(ctx, c, owner, opts)
+		if err != nil {
+			errs = append(errs, NftContractError{addr, err})
+			continue
+		}
+		res = append(res, h...)
+	}
+	if len(errs) > 0 {
+		return res, errs
+	}
+	return res, nil
+}
+
+func scanNftContract(ctx context.Context, c *Contract, owner mavryk.Address, opts NftScanOptions) ([]NftHolding, error) {
+	ids := opts.TokenIds
+	if len(ids) == 0 {
+		ids = candidateTokenIds(c, opts.MaxTokens)
+		if len(ids) == 0 {
+			return nil, fmt.Errorf("cannot determine token ids")
+		}
+	}
+	var (
+		res []NftHolding
+		err error
+	)
+	if ledger, ok := c.findNftLedger(); ok {
+		res, err = scanNftLedger(ctx, c, ledger, owner, ids)
+	} else {
+		res, err = scanNftBalances(ctx, c, owner, ids, opts.BatchSize)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if opts.WithMetadata {
+		for i := range res {
+			res[i].Metadata, _ = ResolveTokenMetadata(ctx, c, res[i].TokenId)
+		}
+	}
+	return res, nil
+}
+
+// candidateTokenIds derives a range of token ids from well-known storage
+// counters.
+func candidateTokenIds(c *Contract, max int) []mavryk.Z {
+	store := c.StorageValue()
+	for _, f := range nftCounterFields {
+		n, ok := store.GetInt64(f.name)
+		if !ok {
+			continue
+		}
+		if f.inclusive {
+			n++
+		}
+		if n > int64(max) {
+			n = int64(max)
+		}
+		ids := make([]mavryk.Z, 0, n)
+		for i := int64(0); i < n; i++ {
+			ids = append(ids, mavryk.NewZ(i))
+		}
+		return ids
+	}
+	return nil
+}
+
+// findNftLedger returns the contract's ledger bigmap if it matches a known
+// NFT ledger schema. Bigmaps named ledger are preferred.
+func (c *Contract) findNftLedger() (NftLedger, bool) {
+	var ledger NftLedger
+	ids := c.script.Bigmaps()
+	for name, typ := range c.script.BigmapTypes() {
+		if len(typ.Args) < 2 {
+			continue
+		}
+		schema := DetectNftLedger(typ.Args[0], typ.Args[1])
+		if !schema.IsValid() {
+			continue
+		}
+		id, ok := ids[name]
+		if !ok {
+			continue
+		}
+		if ledger.Schema.IsValid() && name != "ledger" {
+			continue
+		}
+		ledger = NftLedger{
+			Address: c.addr,
+			Schema:  schema,
+			Bigmap:  id,
+		}
+	}
+	return ledger, ledger.Schema.IsValid()
+}
+
+// nftLedgerKey returns the ledger key for owner and token id.
+func nftLedgerKey(schema NftLedgerSchema, owner mavryk.Address, id mavryk.Z) micheline.Prim {
+	switch schema {
+	case NftLedgerSchema1:
+		return micheline.NewPair(micheline.NewAddress(owner), micheline.NewNat(id.Big()))
+	case NftLedgerSchema2:
+		return micheline.NewNat(id.Big())
+	default:
+		return micheline.NewPair(micheline.NewNat(id.Big()), micheline.NewAddress(owner))
+	}
+}
+
+// listNftLedgerKeys returns the set of key hashes in the ledger bigmap. The
+// result is nil when the node does not serve raw context data.
+func listNftLedgerKeys(ctx context.Context, c *Contract, ledger NftLedger) (map[mavryk.ExprHash]struct{}, error) {
+	hashes, err := c.rpc.ListActiveBigmapKeys(ctx, ledger.Bigmap)
+	if err != nil {
+		switch rpc.ErrorStatus(err) {
+		case http.StatusNotFound, http.StatusForbidden:
+			return nil, nil
+		}
+		return nil, err
+	}
+	keys := make(map[mavryk.ExprHash]struct{}, len(hashes))
+	for _, h := range hashes {
+		keys[h] = struct{}{}
+	}
+	return keys, nil
+}
+
+func scanNftLedger(ctx context.Context, c *Contract, ledger NftLedger, owner mavryk.Address, ids []mavryk.Z) ([]NftHolding, error) {
+	keyType := micheline.NewType(nftLedgerSpecs[ledger.Schema].Args[0])
+	keys, err := listNftLedgerKeys(ctx, c, ledger)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]NftHolding, 0)
+	for _, id := range ids {
+		prim := nftLedgerKey(ledger.Schema, owner, id)
+		key, err := micheline.NewKey(keyType, prim)
+		if err != nil {
+			return nil, err
+		}
+		hash := key.Hash()
+		if keys != nil {
+			if _, ok := keys[hash]; !ok {
+				continue
+			}
+		}
+		val, err := c.rpc.GetActiveBigmapValue(ctx, ledger.Bigmap, hash)
+		if err != nil {
+			if rpc.ErrorStatus(err) == http.StatusNotFound {
+				continue
+			}
+			return nil, err
+		}
+		entry, err := ledger.DecodeEntry(micheline.NewPair(prim, val))
+		if err != nil {
+			return nil, err
+		}
+		if !entry.Owner.Equal(owner) || entry.Balance.IsZero() {
+			continue
+		}
+		res = append(res, NftHolding{
+			Contract: c.addr,
+			TokenId:  entry.TokenId,
+			Balance:  entry.Balance,
+		})
+	}
+	return res, nil
+}
+
+func scanNftBalances(ctx context.Context, c *Contract, owner mavryk.Address, ids []mavryk.Z, batch int) ([]NftHolding, error) {
+	token := c.AsFA2(0)
+	res := make([]NftHolding, 0)
+	for len(ids) > 0 {
+		n := batch
+		if n > len(ids) {
+			n = len(ids)
+		}
+		req := make([]FA2BalanceRequest, n)
+		for i, id := range ids[:n] {
+			req[i] = FA2BalanceRequest{Owner: owner, TokenId: id}
+		}
+		ids = ids[n:]
+		resp, err := token.GetBalances(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range resp {
+			if v.Balance.IsZero() {
+				continue
+			}
+			res = append(res, NftHolding{
+				Contract: c.addr,
+				TokenId:  v.Request.TokenId,
+				Balance:  v.Balance,
+			})
+		}
+	}
+	return res, nil
+}
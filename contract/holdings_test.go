@@ -0,0 +1,122 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package contract
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+	"github.com/mavryk-network/mvgo/micheline"
+	"github.com/mavryk-network/mvgo/rpc/rpctest"
+)
+
+var (
+	testNftContract = mavryk.MustParseAddress("KT1EMQxfYVvhTJTqMiVs2ho2dqjbYfYKk6BY")
+	testNftMissing  = mavryk.MustParseAddress("KT1Puc9St8wdNoGtLiD2WXaHbWU7styaxYhD")
+	testNftOwner    = mavryk.MustParseAddress("mv1C6KuPzs92LTENfXUPXq1oNxvaLjxJ9p8b")
+	testNftOther    = mavryk.MustParseAddress("mv1XAPGKrKd4CRRHjTgDFLP1GEok7qXGVnvA")
+)
+
+// serveNftContract serves an FA2 NFT contract whose ledger (bigmap 10) maps
+// token ids to owners. It returns a function counting ledger value requests.
+func serveNftContract(t *testing.T, node *rpctest.Node, owners map[int64]mavryk.Address, listKeys bool) func() int {
+	t.Helper()
+	code, err := os.ReadFile("../examples/tzcompose/token/fa2_nft.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	storage := `{"prim":"Pair","args":[{"prim":"Pair","args":[{"prim":"Pair","args":[{"int":"10"},{"int":"11"}]},{"int":"12"},[]]},{"int":"13"}]}`
+	base := "/chains/main/blocks/head/context/contracts/" + testNftContract.String()
+	node.Handle(http.MethodPost, base+"/script/normalized", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"code":` + string(code) + `,"storage":` + storage + `}`))
+	})
+	node.Handle(http.MethodGet, base+"/storage", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(storage))
+	})
+
+	var (
+		mu     sync.Mutex
+		n      int
+		hashes = make([]mavryk.ExprHash, 0)
+	)
+	for id, owner := range owners {
+		key, err := micheline.NewKey(micheline.NewType(micheline.NewCode(micheline.T_NAT)), micheline.NewInt64(id))
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashes = append(hashes, key.Hash())
+		val, _ := json.Marshal(micheline.NewString(owner.String()))
+		node.Handle(http.MethodGet, "/chains/main/blocks/head/context/big_maps/10/"+key.Hash().String(), func(w http.ResponseWriter, _ *http.Request) {
+			mu.Lock()
+			n++
+			mu.Unlock()
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(val)
+		})
+	}
+	if listKeys {
+		node.Handle(http.MethodGet, "/chains/main/blocks/head/context/raw/json/big_maps/index/10/contents", func(w http.ResponseWriter, _ *http.Request) {
+			buf, _ := json.Marshal(hashes)
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(buf)
+		})
+	}
+	return func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return n
+	}
+}
+
+func TestScanNftHoldings(t *testing.T) {
+	for _, listKeys := range []bool{true, false} {
+		t.Run("list_keys="+strconv.FormatBool(listKeys), func(t *testing.T) {
+			node := rpctest.NewNode(nil)
+			defer node.Close()
+			owners := map[int64]mavryk.Address{0: testNftOwner, 1: testNftOther, 3: testNftOwner}
+			requests := serveNftContract(t, node, owners, listKeys)
+			cli, err := node.Client()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer cli.Close()
+
+			res, err := ScanNftHoldings(context.Background(), cli, testNftOwner, NftScanOptions{
+				Contracts: []mavryk.Address{testNftMissing, testNftContract},
+				TokenIds:  []mavryk.Z{mavryk.NewZ(0), mavryk.NewZ(1), mavryk.NewZ(2), mavryk.NewZ(3), mavryk.NewZ(4)},
+			})
+
+			// the missing contract is reported, but does not abort the scan
+			var serr NftScanError
+			if !errors.As(err, &serr) {
+				t.Fatalf("want scan error, have %v", err)
+			}
+			if len(serr) != 1 || !serr[0].Contract.Equal(testNftMissing) {
+				t.Errorf("unexpected failed contracts: %v", serr)
+			}
+			if len(res) != 2 {
+				t.Fatalf("want 2 holdings, have %d", len(res))
+			}
+			for i, id := range []int64{0, 3} {
+				if !res[i].Contract.Equal(testNftContract) || res[i].TokenId.Int64() != id || res[i].Balance.Int64() != 1 {
+					t.Errorf("holding %d mismatch: %+v", i, res[i])
+				}
+			}
+
+			// with the key list only existing entries are fetched
+			if want, have := 3, requests(); listKeys && have != want {
+				t.Errorf("want %d value requests, have %d", want, have)
+			}
+		})
+	}
+}
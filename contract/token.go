@@ -214,6 +214,20 @@ func (t TokenMetadata) Raw() []byte {
 	return buf
 }
 
+// Amount wraps a raw token balance with the token's decimals.
+func (t TokenMetadata) Amount(v mavryk.Z) mavryk.TokenAmount {
+	return mavryk.NewTokenAmount(v, t.Decimals)
+}
+
+// ParseAmount converts a decimal string like "1.5" into raw token units.
+func (t TokenMetadata) ParseAmount(s string) (mavryk.Z, error) {
+	a, err := mavryk.ParseTokenAmount(s, t.Decimals)
+	if err != nil {
+		return mavryk.Z{}, err
+	}
+	return a.Value, nil
+}
+
 func ResolveTokenMetadata(ctx context.Context, contract *Contract, tokenid mavryk.Z) (*TokenMetadata, error) {
 	var (
 		store micheline.Prim
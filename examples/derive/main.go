@@ -0,0 +1,114 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+// Address derivation audit
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+	"github.com/mavryk-network/mvgo/rpc"
+
+	"github.com/echa/log"
+)
+
+var (
+	flags      = flag.NewFlagSet("derive", flag.ContinueOnError)
+	verbose    bool
+	node       string
+	passphrase string
+	count      int
+	offline    bool
+	showKeys   bool
+)
+
+func init() {
+	flags.Usage = func() {}
+	flags.BoolVar(&verbose, "v", false, "be verbose")
+	flags.StringVar(&node, "node", "https://rpc.tzpro.io", "Tezos node URL")
+	flags.StringVar(&passphrase, "passphrase", "", "mnemonic passphrase (may also use env TEZOS_MNEMONIC_PASSPHRASE)")
+	flags.IntVar(&count, "n", 5, "number of accounts per derivation scheme")
+	flags.BoolVar(&offline, "offline", false, "skip balance lookup")
+	flags.BoolVar(&showKeys, "keys", false, "print private keys")
+}
+
+func main() {
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			fmt.Println("Usage: derive [flags] <mnemonic words>")
+			fmt.Println("\nLists addresses derived from a mnemonic across curves and common")
+			fmt.Println("derivation paths together with their current balance.")
+			fmt.Println("\nFlags")
+			flags.PrintDefaults()
+			os.Exit(0)
+		}
+		fmt.Println("Error:", err)
+		return
+	}
+
+	if err := run(); err != nil {
+		fmt.Println("Error:", err)
+	}
+}
+
+func run() error {
+	if flags.NArg() < 1 {
+		return fmt.Errorf("Mnemonic required")
+	}
+	mnemonic := strings.Join(flags.Args(), " ")
+	if passphrase == "" {
+		passphrase = os.Getenv("TEZOS_MNEMONIC_PASSPHRASE")
+	}
+
+	switch {
+	case verbose:
+		log.SetLevel(log.LevelTrace)
+	default:
+		log.SetLevel(log.LevelWarn)
+	}
+	rpc.UseLogger(log.Log)
+
+	list, err := mavryk.DeriveAddresses(mavryk.MnemonicSeed(mnemonic, passphrase), count)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var c *rpc.Client
+	if !offline {
+		c, err = rpc.NewClient(node, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SCHEME\tPATH\tTYPE\tADDRESS\tBALANCE")
+	for _, v := range list {
+		path := "-"
+		if v.Path != nil {
+			path = v.Path.String()
+		}
+		balance := "-"
+		if c != nil {
+			bal, err := c.GetContractBalance(ctx, v.Address, rpc.Head)
+			if err != nil {
+				return fmt.Errorf("balance %s: %v", v.Address, err)
+			}
+			balance = bal.Decimals(6)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", v.Scheme, path, v.Key.Type, v.Address, balance)
+		if showKeys {
+			fmt.Fprintf(w, "\t\t\t%s\t\n", v.Key)
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,421 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+// End-to-end scenarios
+//
+// Each scenario sends real operations to a sandbox or testnet node, waits
+// for inclusion and asserts the expected on-chain effect. Scenarios double
+// as integration tests for codec, rpc, signer and contract packages.
+//
+// # Requirements
+//
+// - private key for a funded sandbox or testnet account
+// - a registered baker key for the vote scenario
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/mavryk-network/mvgo/codec"
+	"github.com/mavryk-network/mvgo/contract"
+	"github.com/mavryk-network/mvgo/mavryk"
+	"github.com/mavryk-network/mvgo/micheline"
+	"github.com/mavryk-network/mvgo/rpc"
+	"github.com/mavryk-network/mvgo/signer"
+
+	"github.com/echa/log"
+)
+
+var (
+	flags    = flag.NewFlagSet("scenarios", flag.ContinueOnError)
+	verbose  bool
+	node     string
+	key      string
+	confirm  int64
+	timeout  time.Duration
+	finalize bool
+)
+
+func init() {
+	flags.Usage = func() {}
+	flags.BoolVar(&verbose, "v", false, "be verbose")
+	flags.StringVar(&key, "key", os.Getenv("MVGO_PRIVATE_KEY"), "private key (default $MVGO_PRIVATE_KEY)")
+	flags.StringVar(&node, "node", "http://localhost:8732", "Tezos node URL")
+	flags.Int64Var(&confirm, "confirmations", 1, "confirmations to wait for")
+	flags.DurationVar(&timeout, "timeout", 5*time.Minute, "timeout per scenario")
+	flags.BoolVar(&finalize, "finalize", false, "finalize unstaked funds in stake scenario")
+}
+
+// scenario is a runnable example with assertions
+type scenario struct {
+	args  string
+	help  string
+	nargs int
+	run   func(ctx context.Context, env *env) error
+}
+
+var scenarios = map[string]scenario{
+	"transfer": {
+		args:  "<receiver> <amount>",
+		help:  "send tez and assert receiver balance",
+		nargs: 2,
+		run:   runTransfer,
+	},
+	"fa2-mint": {
+		args:  "<contract> <token_id> <amount> <params>",
+		help:  "call mint with Micheline JSON params and assert FA2 balance",
+		nargs: 4,
+		run:   runFA2Mint,
+	},
+	"rollup-deposit": {
+		args:  "<bridge> <amount> <params>",
+		help:  "call a rollup bridge deposit entrypoint and assert funds were locked",
+		nargs: 3,
+		run:   runRollupDeposit,
+	},
+	"vote": {
+		args:  "<yay|nay|pass>",
+		help:  "cast a ballot on the current proposal and assert it was counted",
+		nargs: 1,
+		run:   runVote,
+	},
+	"stake": {
+		args:  "<amount>",
+		help:  "stake, unstake and optionally finalize, asserting staked balance",
+		nargs: 1,
+		run:   runStake,
+	},
+}
+
+func main() {
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			fmt.Println("Usage: scenarios [flags] <scenario> [args]")
+			fmt.Println("\nFlags")
+			flags.PrintDefaults()
+			fmt.Println("\nScenarios")
+			names := make([]string, 0, len(scenarios))
+			for n := range scenarios {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+			for _, n := range names {
+				s := scenarios[n]
+				fmt.Printf("  %-15s %-40s %s\n", n, s.args, s.help)
+			}
+			os.Exit(0)
+		}
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+
+	if err := run(); err != nil {
+		fmt.Println("FAIL:", err)
+		os.Exit(1)
+	}
+	fmt.Println("PASS")
+}
+
+// env is shared by all scenarios
+type env struct {
+	c    *rpc.Client
+	sk   mavryk.PrivateKey
+	addr mavryk.Address
+	opts *rpc.CallOptions
+	args []string
+}
+
+func run() error {
+	if flags.NArg() < 1 {
+		return fmt.Errorf("Scenario required")
+	}
+	name := flags.Arg(0)
+	s, ok := scenarios[name]
+	if !ok {
+		return fmt.Errorf("Unknown scenario %q", name)
+	}
+	if flags.NArg()-1 < s.nargs {
+		return fmt.Errorf("Usage: %s %s", name, s.args)
+	}
+	if key == "" {
+		return fmt.Errorf("Key required")
+	}
+	sk, err := mavryk.ParsePrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("Invalid private key: %v", err)
+	}
+
+	switch {
+	case verbose:
+		log.SetLevel(log.LevelTrace)
+	default:
+		log.SetLevel(log.LevelWarn)
+	}
+	rpc.UseLogger(log.Log)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	c, err := rpc.NewClient(node, nil)
+	if err != nil {
+		return err
+	}
+	if err := c.Init(ctx); err != nil {
+		return err
+	}
+	c.Signer = signer.NewFromKey(sk)
+	c.Listen()
+	defer c.Close()
+
+	opts := rpc.DefaultOptions
+	opts.Confirmations = confirm
+
+	fmt.Printf("Running %s on %s as %s\n", name, c.Params.Network, sk.Address())
+	return s.run(ctx, &env{
+		c:    c,
+		sk:   sk,
+		addr: sk.Address(),
+		opts: &opts,
+		args: flags.Args()[1:],
+	})
+}
+
+// send broadcasts op and fails unless it was applied successfully.
+func (e *env) send(ctx context.Context, op *codec.Op) (*rpc.Receipt, error) {
+	rcpt, err := e.c.Send(ctx, op, e.opts)
+	if err != nil {
+		return nil, err
+	}
+	if !rcpt.IsSuccess() {
+		return rcpt, fmt.Errorf("operation %s failed: %v", rcpt.Op.Hash, rcpt.Error())
+	}
+	costs := rcpt.TotalCosts()
+	fmt.Printf("  included %s fee=%d gas=%d burn=%d\n", rcpt.Op.Hash, costs.Fee, costs.GasUsed, costs.StorageBurn+costs.AllocationBurn)
+	return rcpt, nil
+}
+
+func (e *env) balance(ctx context.Context, addr mavryk.Address) (int64, error) {
+	bal, err := e.c.GetContractBalance(ctx, addr, rpc.Head)
+	return bal.Int64(), err
+}
+
+func (e *env) stakedBalance(ctx context.Context) (int64, error) {
+	bal, err := e.c.GetContractStakedBalance(ctx, e.addr, rpc.Head)
+	return bal.Int64(), err
+}
+
+func assertEqual(what string, want, got int64) error {
+	if want != got {
+		return fmt.Errorf("%s: want %d, got %d", what, want, got)
+	}
+	fmt.Printf("  ok %s = %d\n", what, got)
+	return nil
+}
+
+func parseParams(entrypoint, s string) (micheline.Parameters, error) {
+	params := micheline.Parameters{Entrypoint: entrypoint}
+	if err := json.Unmarshal([]byte(s), &params.Value); err != nil {
+		return params, fmt.Errorf("Invalid Micheline params: %v", err)
+	}
+	return params, nil
+}
+
+func runTransfer(ctx context.Context, e *env) error {
+	recv, err := mavryk.ParseAddress(e.args[0])
+	if err != nil {
+		return fmt.Errorf("Invalid receiver: %v", err)
+	}
+	amount, err := strconv.ParseInt(e.args[1], 10, 64)
+	if err != nil {
+		return fmt.Errorf("Invalid amount: %v", err)
+	}
+	before, err := e.balance(ctx, recv)
+	if err != nil {
+		return err
+	}
+	if _, err := e.send(ctx, codec.NewOp().WithTransfer(recv, amount)); err != nil {
+		return err
+	}
+	after, err := e.balance(ctx, recv)
+	if err != nil {
+		return err
+	}
+	return assertEqual("receiver balance delta", amount, after-before)
+}
+
+func runFA2Mint(ctx context.Context, e *env) error {
+	addr, err := mavryk.ParseAddress(e.args[0])
+	if err != nil {
+		return fmt.Errorf("Invalid contract: %v", err)
+	}
+	id, err := strconv.ParseInt(e.args[1], 10, 64)
+	if err != nil {
+		return fmt.Errorf("Invalid token id: %v", err)
+	}
+	amount, err := strconv.ParseInt(e.args[2], 10, 64)
+	if err != nil {
+		return fmt.Errorf("Invalid amount: %v", err)
+	}
+	params, err := parseParams("mint", e.args[3])
+	if err != nil {
+		return err
+	}
+	con := contract.NewContract(addr, e.c)
+	if err := con.Resolve(ctx); err != nil {
+		return err
+	}
+	if !con.IsFA2() {
+		return fmt.Errorf("%s is not an FA2 contract", addr)
+	}
+	token := con.AsFA2(id)
+	before, err := token.GetBalance(ctx, e.addr)
+	if err != nil {
+		return err
+	}
+	if _, err := e.send(ctx, codec.NewOp().WithCall(addr, params)); err != nil {
+		return err
+	}
+	after, err := token.GetBalance(ctx, e.addr)
+	if err != nil {
+		return err
+	}
+	return assertEqual("token balance delta", amount, after.Sub(before).Int64())
+}
+
+func runRollupDeposit(ctx context.Context, e *env) error {
+	bridge, err := mavryk.ParseAddress(e.args[0])
+	if err != nil {
+		return fmt.Errorf("Invalid bridge: %v", err)
+	}
+	amount, err := strconv.ParseInt(e.args[1], 10, 64)
+	if err != nil {
+		return fmt.Errorf("Invalid amount: %v", err)
+	}
+	params, err := parseParams("deposit", e.args[2])
+	if err != nil {
+		return err
+	}
+	before, err := e.balance(ctx, e.addr)
+	if err != nil {
+		return err
+	}
+	rcpt, err := e.send(ctx, codec.NewOp().WithCallExt(bridge, params, amount))
+	if err != nil {
+		return err
+	}
+	after, err := e.balance(ctx, e.addr)
+	if err != nil {
+		return err
+	}
+	costs := rcpt.TotalCosts()
+	return assertEqual("sender balance delta", -amount-costs.Fee-costs.StorageBurn-costs.AllocationBurn, after-before)
+}
+
+func runVote(ctx context.Context, e *env) error {
+	vote := mavryk.ParseBallotVote(e.args[0])
+	if !vote.IsValid() {
+		return fmt.Errorf("Invalid ballot %q", e.args[0])
+	}
+	head, err := e.c.GetHeadBlock(ctx)
+	if err != nil {
+		return err
+	}
+	kind := head.GetVotingPeriodKind()
+	if kind != mavryk.VotingPeriodExploration && kind != mavryk.VotingPeriodPromotion {
+		return fmt.Errorf("Cannot vote in %s period", kind)
+	}
+	proposal, err := e.c.GetVoteProposal(ctx, rpc.Head)
+	if err != nil {
+		return err
+	}
+	op := codec.NewOp().WithContents(&codec.Ballot{
+		Source:   e.addr,
+		Period:   int32(head.GetVotingPeriod()),
+		Proposal: proposal,
+		Ballot:   vote,
+	})
+	if _, err := e.send(ctx, op); err != nil {
+		return err
+	}
+	ballots, err := e.c.ListBallots(ctx, rpc.Head)
+	if err != nil {
+		return err
+	}
+	for _, v := range ballots {
+		if v.Delegate.Equal(e.addr) {
+			if v.Ballot != vote {
+				return fmt.Errorf("ballot: want %s, got %s", vote, v.Ballot)
+			}
+			fmt.Printf("  ok ballot %s counted for %s\n", vote, proposal)
+			return nil
+		}
+	}
+	return fmt.Errorf("ballot not found")
+}
+
+func runStake(ctx context.Context, e *env) error {
+	amount, err := strconv.ParseInt(e.args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("Invalid amount: %v", err)
+	}
+	start, err := e.stakedBalance(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Stake")
+	if _, err := e.send(ctx, codec.NewOp().WithStake(amount)); err != nil {
+		return err
+	}
+	staked, err := e.stakedBalance(ctx)
+	if err != nil {
+		return err
+	}
+	if err := assertEqual("staked balance delta", amount, staked-start); err != nil {
+		return err
+	}
+
+	fmt.Println("Unstake")
+	if _, err := e.send(ctx, codec.NewOp().WithUnstake(amount)); err != nil {
+		return err
+	}
+	staked, err = e.stakedBalance(ctx)
+	if err != nil {
+		return err
+	}
+	if err := assertEqual("staked balance delta", 0, staked-start); err != nil {
+		return err
+	}
+
+	// unstaked funds become finalizable after a protocol defined number
+	// of cycles, on a sandbox this is usually a few minutes
+	if !finalize {
+		fmt.Println("  skip finalize, run again with -finalize once unstaked funds are unfrozen")
+		return nil
+	}
+	fmt.Println("Finalize")
+	before, err := e.balance(ctx, e.addr)
+	if err != nil {
+		return err
+	}
+	rcpt, err := e.send(ctx, codec.NewOp().WithFinalizeUnstake())
+	if err != nil {
+		return err
+	}
+	after, err := e.balance(ctx, e.addr)
+	if err != nil {
+		return err
+	}
+	if costs := rcpt.TotalCosts(); after-before+costs.Fee <= 0 {
+		return fmt.Errorf("finalize: spendable balance did not increase")
+	}
+	fmt.Printf("  ok finalized %d\n", after-before)
+	return nil
+}
@@ -10,7 +10,6 @@ import (
 	"github.com/mavryk-network/mvgo/internal/compose"
 	"github.com/mavryk-network/mvgo/internal/compose/alpha"
 	"github.com/mavryk-network/mvgo/rpc"
-	"github.com/mavryk-network/mvgo/signer"
 
 	"github.com/pkg/errors"
 )
@@ -37,8 +36,7 @@ func (t *BatchTask) Build(ctx compose.Context, task alpha.Task) (*codec.Op, *rpc
 	if err := t.parse(ctx, task); err != nil {
 		return nil, nil, errors.Wrap(err, "parse")
 	}
-	opts := rpc.NewCallOptions()
-	opts.Signer = signer.NewFromKey(t.Key)
+	opts := t.Account.Options(rpc.NewCallOptions())
 	op := codec.NewOp().WithSource(t.Source)
 	for i, ct := range task.Contents {
 		// use common source
@@ -9,7 +9,6 @@ import (
 	"github.com/mavryk-network/mvgo/internal/compose/alpha"
 	"github.com/mavryk-network/mvgo/micheline"
 	"github.com/mavryk-network/mvgo/rpc"
-	"github.com/mavryk-network/mvgo/signer"
 
 	"github.com/pkg/errors"
 )
@@ -40,8 +39,7 @@ func (t *CallTask) Build(ctx compose.Context, task alpha.Task) (*codec.Op, *rpc.
 	if err != nil {
 		return nil, nil, errors.Wrap(err, "params")
 	}
-	opts := rpc.NewCallOptions()
-	opts.Signer = signer.NewFromKey(t.Key)
+	opts := t.Account.Options(rpc.NewCallOptions())
 	op := codec.NewOp().
 		WithSource(t.Source).
 		WithCallExt(t.Destination, micheline.Parameters{
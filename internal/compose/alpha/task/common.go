@@ -7,13 +7,14 @@ import (
 	"github.com/mavryk-network/mvgo/internal/compose"
 	"github.com/mavryk-network/mvgo/internal/compose/alpha"
 	"github.com/mavryk-network/mvgo/mavryk"
+	"github.com/mavryk-network/mvgo/rpc"
 
 	"github.com/pkg/errors"
 )
 
 type BaseTask struct {
-	Source mavryk.Address
-	Key    mavryk.PrivateKey
+	Source  mavryk.Address
+	Account *rpc.Account
 }
 
 func (t *BaseTask) parse(ctx compose.Context, task alpha.Task) (err error) {
@@ -22,13 +23,15 @@ func (t *BaseTask) parse(ctx compose.Context, task alpha.Task) (err error) {
 			err = errors.Wrap(err, "source")
 			return
 		}
-		if t.Key, err = ctx.ResolvePrivateKey(task.Source); err != nil {
+		var acc compose.Account
+		if acc, err = ctx.ResolveAccount(task.Source); err != nil {
 			err = errors.Wrap(err, "key")
 			return
 		}
+		t.Account = acc.Account
 	} else {
 		t.Source = ctx.BaseAccount.Address
-		t.Key = ctx.BaseAccount.PrivateKey
+		t.Account = ctx.BaseAccount.Account
 	}
 	return
 }
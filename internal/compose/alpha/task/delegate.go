@@ -9,7 +9,6 @@ import (
 	"github.com/mavryk-network/mvgo/internal/compose/alpha"
 	"github.com/mavryk-network/mvgo/mavryk"
 	"github.com/mavryk-network/mvgo/rpc"
-	"github.com/mavryk-network/mvgo/signer"
 
 	"github.com/pkg/errors"
 )
@@ -36,8 +35,7 @@ func (t *DelegateTask) Build(ctx compose.Context, task alpha.Task) (*codec.Op, *
 	if err := t.parse(ctx, task); err != nil {
 		return nil, nil, errors.Wrap(err, "parse")
 	}
-	opts := rpc.NewCallOptions()
-	opts.Signer = signer.NewFromKey(t.Key)
+	opts := t.Account.Options(rpc.NewCallOptions())
 	opts.IgnoreLimits = true
 	op := codec.NewOp().
 		WithSource(t.Source).
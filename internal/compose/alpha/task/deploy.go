@@ -10,7 +10,6 @@ import (
 	"github.com/mavryk-network/mvgo/internal/compose"
 	"github.com/mavryk-network/mvgo/internal/compose/alpha"
 	"github.com/mavryk-network/mvgo/rpc"
-	"github.com/mavryk-network/mvgo/signer"
 
 	"github.com/pkg/errors"
 )
@@ -42,8 +41,7 @@ func (t *DeployTask) Build(ctx compose.Context, task alpha.Task) (*codec.Op, *rp
 	if err != nil {
 		return nil, nil, errors.Wrap(err, "script")
 	}
-	opts := rpc.NewCallOptions()
-	opts.Signer = signer.NewFromKey(t.Key)
+	opts := t.Account.Options(rpc.NewCallOptions())
 	op := codec.NewOp().
 		WithSource(t.Source).
 		WithOriginationExt(*script, t.Destination, t.Amount)
@@ -13,7 +13,6 @@ import (
 	"github.com/mavryk-network/mvgo/internal/compose/alpha"
 	"github.com/mavryk-network/mvgo/mavryk"
 	"github.com/mavryk-network/mvgo/rpc"
-	"github.com/mavryk-network/mvgo/signer"
 
 	"github.com/pkg/errors"
 )
@@ -26,7 +25,7 @@ func init() {
 
 type DoubleBakeTask struct {
 	TargetTask
-	BakerKey mavryk.PrivateKey
+	Baker *rpc.Account
 }
 
 func NewDoubleBakeTask() alpha.TaskBuilder {
@@ -46,14 +45,14 @@ func (t *DoubleBakeTask) Build(ctx compose.Context, task alpha.Task) (*codec.Op,
 	// actually be baked by this baker, so we look for round 0 only
 	// this means to test this on sandbox the source/baker must be a
 	// sandbox baker
-	ctx.Log.Infof("Waiting for round-0 baking rights for %s...", t.BakerKey.Address())
+	ctx.Log.Infof("Waiting for round-0 baking rights for %s...", t.Baker.Address)
 	var (
 		round int
 		head  *rpc.BlockHeaderLogEntry
 	)
 	done := make(chan struct{})
 	_, err := ctx.SubscribeBlocks(func(h *rpc.BlockHeaderLogEntry, _ int64, _ int, _ int, _ bool) bool {
-		r, ok, err := t.fetchBakingRights(ctx, t.BakerKey.Address(), h.Hash)
+		r, ok, err := t.fetchBakingRights(ctx, t.Baker.Address, h.Hash)
 		if err != nil {
 			ctx.Log.Warnf("fetch baking rights: %v", err)
 		} else if ok && r == 0 {
@@ -98,8 +97,7 @@ func (t *DoubleBakeTask) Build(ctx compose.Context, task alpha.Task) (*codec.Op,
 		return nil, nil, err
 	}
 
-	opts := rpc.NewCallOptions()
-	opts.Signer = signer.NewFromKey(t.Key)
+	opts := t.Account.Options(rpc.NewCallOptions())
 	opts.IgnoreLimits = true
 
 	return op, opts, nil
@@ -113,9 +111,11 @@ func (t *DoubleBakeTask) parse(ctx compose.Context, task alpha.Task) (err error)
 	if err = t.TargetTask.parse(ctx, task); err != nil {
 		return err
 	}
-	if t.BakerKey, err = ctx.ResolvePrivateKey(task.Destination); err != nil {
+	acc, err := ctx.ResolveAccount(task.Destination)
+	if err != nil {
 		return errors.Wrap(err, "destination key")
 	}
+	t.Baker = acc.Account
 	return
 }
 
@@ -145,8 +145,10 @@ func (t *DoubleBakeTask) randomBlock(ctx compose.Context, head *rpc.BlockHeaderL
 	h.WithChainId(ctx.Params().ChainId) // Tenderbake block signing needs chain id
 
 	// sign the block
-	if err := h.Sign(t.BakerKey); err != nil {
+	if sig, err := t.Baker.Signer.SignBlock(ctx, t.Baker.Address, &h); err != nil {
 		ctx.Log.Errorf("signing random block: %v", err)
+	} else {
+		h.WithSignature(sig)
 	}
 	return h
 }
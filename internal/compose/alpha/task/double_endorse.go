@@ -12,7 +12,6 @@ import (
 	"github.com/mavryk-network/mvgo/internal/compose/alpha"
 	"github.com/mavryk-network/mvgo/mavryk"
 	"github.com/mavryk-network/mvgo/rpc"
-	"github.com/mavryk-network/mvgo/signer"
 
 	"github.com/pkg/errors"
 )
@@ -25,7 +24,7 @@ func init() {
 
 type DoubleEndorseTask struct {
 	TargetTask
-	BakerKey mavryk.PrivateKey
+	Baker *rpc.Account
 }
 
 func NewDoubleEndorseTask() alpha.TaskBuilder {
@@ -42,14 +41,14 @@ func (t *DoubleEndorseTask) Build(ctx compose.Context, task alpha.Task) (*codec.
 	}
 
 	// wait for endorsing rights to appear, remember block and slot
-	ctx.Log.Infof("Waiting for endorsing rights for %s...", t.BakerKey.Address())
+	ctx.Log.Infof("Waiting for endorsing rights for %s...", t.Baker.Address)
 	var (
 		slot int
 		head *rpc.BlockHeaderLogEntry
 	)
 	done := make(chan struct{})
 	_, err := ctx.SubscribeBlocks(func(h *rpc.BlockHeaderLogEntry, _ int64, _ int, _ int, _ bool) bool {
-		s, ok, err := t.fetchEndorsingRights(ctx, t.BakerKey.Address(), h.Hash)
+		s, ok, err := t.fetchEndorsingRights(ctx, t.Baker.Address, h.Hash)
 		if err != nil {
 			ctx.Log.Warnf("fetch endorsing rights: %v", err)
 		} else if ok {
@@ -94,8 +93,7 @@ func (t *DoubleEndorseTask) Build(ctx compose.Context, task alpha.Task) (*codec.
 		return nil, nil, err
 	}
 
-	opts := rpc.NewCallOptions()
-	opts.Signer = signer.NewFromKey(t.Key)
+	opts := t.Account.Options(rpc.NewCallOptions())
 	opts.IgnoreLimits = true
 
 	return op, opts, nil
@@ -109,9 +107,11 @@ func (t *DoubleEndorseTask) parse(ctx compose.Context, task alpha.Task) (err err
 	if err = t.TargetTask.parse(ctx, task); err != nil {
 		return err
 	}
-	if t.BakerKey, err = ctx.ResolvePrivateKey(task.Destination); err != nil {
+	acc, err := ctx.ResolveAccount(task.Destination)
+	if err != nil {
 		return errors.Wrap(err, "destination key")
 	}
+	t.Baker = acc.Account
 	return
 }
 
@@ -133,8 +133,10 @@ func (t *DoubleEndorseTask) randomEndorsement(ctx compose.Context, head *rpc.Blo
 		WithBranch(head.Hash)
 
 	// sign the endorsement
-	if err := op.Sign(t.BakerKey); err != nil {
+	if sig, err := t.Baker.Signer.SignOperation(ctx, t.Baker.Address, op); err != nil {
 		ctx.Log.Errorf("signing random endorsement: %v", err)
+	} else {
+		op.WithSignature(sig)
 	}
 
 	// return as inlined endorsement
@@ -8,7 +8,6 @@ import (
 	"github.com/mavryk-network/mvgo/internal/compose"
 	"github.com/mavryk-network/mvgo/internal/compose/alpha"
 	"github.com/mavryk-network/mvgo/rpc"
-	"github.com/mavryk-network/mvgo/signer"
 
 	"github.com/pkg/errors"
 )
@@ -35,8 +34,7 @@ func (t *FinalizeUnstakeTask) Build(ctx compose.Context, task alpha.Task) (*code
 	if err := t.parse(ctx, task); err != nil {
 		return nil, nil, errors.Wrap(err, "parse")
 	}
-	opts := rpc.NewCallOptions()
-	opts.Signer = signer.NewFromKey(t.Key)
+	opts := t.Account.Options(rpc.NewCallOptions())
 	op := codec.NewOp().
 		WithSource(t.Source).
 		WithFinalizeUnstake()
@@ -12,7 +12,6 @@ import (
 	"github.com/mavryk-network/mvgo/internal/compose/alpha"
 	"github.com/mavryk-network/mvgo/mavryk"
 	"github.com/mavryk-network/mvgo/rpc"
-	"github.com/mavryk-network/mvgo/signer"
 
 	"github.com/pkg/errors"
 )
@@ -58,8 +57,7 @@ func (t *TokenRevokeTask) Build(ctx compose.Context, task alpha.Task) (*codec.Op
 			Encode()
 	}
 
-	opts := rpc.NewCallOptions()
-	opts.Signer = signer.NewFromKey(t.Key)
+	opts := t.Account.Options(rpc.NewCallOptions())
 	op := codec.NewOp().WithContents(xfer)
 	return op, opts, nil
 }
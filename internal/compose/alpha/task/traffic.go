@@ -0,0 +1,243 @@
+// Copyright (c) 2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package task
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/mavryk-network/mvgo/codec"
+	"github.com/mavryk-network/mvgo/internal/compose"
+	"github.com/mavryk-network/mvgo/internal/compose/alpha"
+	"github.com/mavryk-network/mvgo/mavryk"
+	"github.com/mavryk-network/mvgo/micheline"
+	"github.com/mavryk-network/mvgo/rpc"
+
+	"github.com/pkg/errors"
+)
+
+var _ alpha.TaskBuilder = (*TrafficTask)(nil)
+
+func init() {
+	alpha.RegisterTask("traffic", NewTrafficTask)
+}
+
+const (
+	defaultTrafficAccounts  = 5
+	defaultTrafficCount     = 10
+	defaultTrafficRate      = 1
+	defaultTrafficMaxAmount = 1_000_000
+	defaultTrafficFunding   = 10_000_000
+)
+
+// TrafficTask generates random but valid operations between a set of
+// generated accounts. Accounts are funded from source first. Afterwards
+// each block up to rate accounts send a transfer of random amount to another
+// generated account. When a destination contract and call params are
+// configured, call_percent of operations call this contract instead.
+type TrafficTask struct {
+	BaseTask
+	Accounts    int            // number of generated accounts
+	Count       int            // total number of operations to send
+	Rate        int            // operations per block
+	MaxAmount   int64          // max transfer amount in mumav
+	Funding     int64          // initial balance for each generated account
+	CallPercent int            // share of contract calls in percent
+	Seed        int64          // random seed, zero for time based
+	Prefix      string         // name prefix for generated accounts
+	Contract    mavryk.Address // optional contract to call
+	Params      micheline.Parameters
+}
+
+func NewTrafficTask() alpha.TaskBuilder {
+	return &TrafficTask{}
+}
+
+func (t *TrafficTask) Type() string {
+	return "traffic"
+}
+
+func (t *TrafficTask) Build(ctx compose.Context, task alpha.Task) (*codec.Op, *rpc.CallOptions, error) {
+	if err := t.parse(ctx, task); err != nil {
+		return nil, nil, errors.Wrap(err, "parse")
+	}
+
+	// create and fund accounts
+	accounts, err := t.makeAccounts(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	fund := codec.NewOp().WithSource(t.Source)
+	for _, acc := range accounts {
+		fund.WithTransfer(acc.Address, t.Funding)
+	}
+	opts := t.Account.Options(rpc.NewCallOptions())
+	if _, err := ctx.Send(fund, opts); err != nil {
+		return nil, nil, errors.Wrap(err, "funding")
+	}
+	ctx.Log.Infof("funded %d traffic accounts with %d mumav each", len(accounts), t.Funding)
+
+	// send random operations at rate per block
+	seed := t.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	rnd := rand.New(rand.NewSource(seed))
+	var sent, failed int
+	for sent < t.Count {
+		if err := ctx.WaitNumBlocks(1); err != nil {
+			return nil, nil, err
+		}
+		n := t.Rate
+		if n > t.Count-sent {
+			n = t.Count - sent
+		}
+		// each account sends at most once per block to avoid counter conflicts
+		senders := rnd.Perm(len(accounts))[:n]
+		ops := make([]*codec.Op, n)
+		for i, from := range senders {
+			ops[i] = t.randomOp(rnd, accounts, from)
+		}
+		errs := make([]error, n)
+		var wg sync.WaitGroup
+		for i, from := range senders {
+			wg.Add(1)
+			go func(i int, acc compose.Account) {
+				defer wg.Done()
+				opts := acc.Options(rpc.NewCallOptions())
+				_, errs[i] = ctx.Send(ops[i], opts)
+			}(i, accounts[from])
+		}
+		wg.Wait()
+		for i, err := range errs {
+			if err != nil {
+				failed++
+				ctx.Log.Warnf("traffic %s: %v", accounts[senders[i]].Address, err)
+			}
+		}
+		sent += n
+		ctx.Log.Infof("traffic sent=%d/%d failed=%d", sent, t.Count, failed)
+	}
+	return nil, nil, compose.ErrSkip
+}
+
+func (t *TrafficTask) Validate(ctx compose.Context, task alpha.Task) error {
+	return t.parse(ctx, task)
+}
+
+// makeAccounts creates or reuses generated accounts. Account names derive
+// from the prefix argument so that traffic tasks can use separate accounts.
+func (t *TrafficTask) makeAccounts(ctx compose.Context) ([]compose.Account, error) {
+	accounts := make([]compose.Account, t.Accounts)
+	for i := range accounts {
+		name := fmt.Sprintf("%s_%d", t.Prefix, i)
+		if acc, err := ctx.ResolveAccount(compose.CreateVariable(name)); err == nil {
+			accounts[i] = acc
+			continue
+		}
+		acc, err := ctx.MakeAccount(-1, name)
+		if err != nil {
+			return nil, errors.Wrap(err, name)
+		}
+		accounts[i] = acc
+	}
+	return accounts, nil
+}
+
+// randomOp creates a transfer or contract call by account from.
+func (t *TrafficTask) randomOp(rnd *rand.Rand, accounts []compose.Account, from int) *codec.Op {
+	src := accounts[from].Address
+	if t.Contract.IsValid() && rnd.Intn(100) < t.CallPercent {
+		return codec.NewOp().WithSource(src).WithCall(t.Contract, t.Params)
+	}
+	to := rnd.Intn(len(accounts) - 1)
+	if to >= from {
+		to++
+	}
+	amount := 1 + rnd.Int63n(t.MaxAmount)
+	return codec.NewOp().WithSource(src).WithTransfer(accounts[to].Address, amount)
+}
+
+func (t *TrafficTask) parse(ctx compose.Context, task alpha.Task) (err error) {
+	if err = t.BaseTask.parse(ctx, task); err != nil {
+		return err
+	}
+	t.Accounts = defaultTrafficAccounts
+	t.Count = defaultTrafficCount
+	t.Rate = defaultTrafficRate
+	t.MaxAmount = defaultTrafficMaxAmount
+	t.Funding = defaultTrafficFunding
+	if task.Amount > 0 {
+		t.Funding = int64(task.Amount)
+	}
+	if t.Prefix, err = ctx.ResolveString(task.Args["prefix"]); err != nil {
+		return errors.Wrap(err, "prefix")
+	}
+	if t.Prefix == "" {
+		t.Prefix = "traffic"
+	}
+	for _, v := range []struct {
+		name string
+		val  *int64
+	}{
+		{"max_amount", &t.MaxAmount},
+		{"seed", &t.Seed},
+	} {
+		if arg, ok := task.Args[v.name]; ok {
+			if *v.val, err = ctx.ResolveInt64(arg); err != nil {
+				return errors.Wrap(err, v.name)
+			}
+		}
+	}
+	for _, v := range []struct {
+		name string
+		val  *int
+	}{
+		{"accounts", &t.Accounts},
+		{"count", &t.Count},
+		{"rate", &t.Rate},
+		{"call_percent", &t.CallPercent},
+	} {
+		if arg, ok := task.Args[v.name]; ok {
+			var i int64
+			if i, err = ctx.ResolveInt64(arg); err != nil {
+				return errors.Wrap(err, v.name)
+			}
+			*v.val = int(i)
+		}
+	}
+	switch {
+	case t.Accounts < 2:
+		return fmt.Errorf("traffic requires at least 2 accounts")
+	case t.Count <= 0:
+		return fmt.Errorf("invalid count %d", t.Count)
+	case t.Rate <= 0:
+		return fmt.Errorf("invalid rate %d", t.Rate)
+	case t.Rate > t.Accounts:
+		return fmt.Errorf("rate %d exceeds number of accounts %d", t.Rate, t.Accounts)
+	case t.MaxAmount <= 0:
+		return fmt.Errorf("invalid max_amount %d", t.MaxAmount)
+	case t.CallPercent < 0 || t.CallPercent > 100:
+		return fmt.Errorf("call_percent %d out of range [0..100]", t.CallPercent)
+	}
+	if task.Destination != "" {
+		if t.Contract, err = ctx.ResolveAddress(task.Destination); err != nil {
+			return errors.Wrap(err, "destination")
+		}
+		if task.Params == nil {
+			return fmt.Errorf("missing params for contract calls")
+		}
+		params, err := alpha.ParseParams(ctx, task)
+		if err != nil {
+			return errors.Wrap(err, "params")
+		}
+		t.Params = micheline.Parameters{
+			Entrypoint: task.Params.Entrypoint,
+			Value:      *params,
+		}
+	}
+	return nil
+}
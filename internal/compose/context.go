@@ -25,10 +25,11 @@ type Engine interface {
 	Run(Context, string) error
 }
 
+// Account is a compose wallet account. Id is the child index used to derive
+// the account key from the base key, -1 for the base account itself.
 type Account struct {
-	Id         int
-	Address    mavryk.Address
-	PrivateKey mavryk.PrivateKey
+	*rpc.Account
+	Id int
 }
 
 type Context struct {
@@ -39,12 +40,13 @@ type Context struct {
 	Contracts    map[mavryk.Address]*micheline.Script
 	Variables    map[string]string
 	Log          log.Logger
-	client       *rpc.Client // RPC client
-	url          string      // RPC service URL
-	apiKey       string      // RPC service API key
-	path         string      // current compose file path
-	resume       bool        // continue pipeline execution were we left off
-	mode         RunMode     // selected engine run mode
+	baseKey      mavryk.PrivateKey // seed for deriving account keys
+	client       *rpc.Client       // RPC client
+	url          string            // RPC service URL
+	apiKey       string            // RPC service API key
+	path         string            // current compose file path
+	resume       bool              // continue pipeline execution were we left off
+	mode         RunMode           // selected engine run mode
 	cache        *PipelineCache
 	savedLoggers [2]log.Logger
 }
@@ -111,9 +113,11 @@ func (c *Context) WithBase(k string) *Context {
 		c.Log.Errorf("base key: %v", err)
 		return c
 	}
-	c.BaseAccount.Id = -1
-	c.BaseAccount.PrivateKey = sk
-	c.BaseAccount.Address = sk.Address()
+	c.baseKey = sk
+	c.BaseAccount = Account{
+		Account: rpc.NewKeyAccount(sk),
+		Id:      -1,
+	}
 	c.AddVariable("base", c.BaseAccount.Address.String())
 	c.AddAccount(c.BaseAccount)
 	return c
@@ -124,7 +128,7 @@ func (c *Context) Cache() *PipelineCache {
 }
 
 func (c *Context) Init() (err error) {
-	if !c.BaseAccount.PrivateKey.IsValid() {
+	if !c.baseKey.IsValid() {
 		err = ErrNoBaseKey
 		return
 	}
@@ -154,7 +158,7 @@ func (c *Context) AddVariable(key, val string) {
 }
 
 func (c *Context) AddAccount(acc Account) {
-	c.Log.Debugf("Add account %d=%s key=%s", acc.Id, acc.Address, acc.PrivateKey)
+	c.Log.Debugf("Add account %d=%s path=%s", acc.Id, acc.Address, acc.Path)
 	c.Accounts[acc.Address] = acc
 }
 
@@ -256,26 +260,25 @@ func (c *Context) ResolveZ(val any) (z mavryk.Z, err error) {
 	return
 }
 
-func (c *Context) ResolvePrivateKey(val any) (sk mavryk.PrivateKey, err error) {
+func (c *Context) ResolveAccount(val any) (acc Account, err error) {
 	v, ok := val.(string)
 	if !ok {
 		err = fmt.Errorf("invalid type %T, expected string", val)
 		return
 	}
 	if v == "" {
-		return c.BaseAccount.PrivateKey, nil
+		return c.BaseAccount, nil
 	}
 	var addr mavryk.Address
 	addr, err = c.ResolveAddress(val)
 	if err != nil {
 		return
 	}
-	acc, ok := c.Accounts[addr]
+	acc, ok = c.Accounts[addr]
 	if !ok {
 		err = ErrNoAccount
 		return
 	}
-	sk = acc.PrivateKey
 	return
 }
 
@@ -294,6 +297,10 @@ func (c *Context) ResolveScript(addr mavryk.Address) (*micheline.Script, error)
 }
 
 func (c *Context) Send(op *codec.Op, opts *rpc.CallOptions) (*rpc.Receipt, error) {
+	// replace mainnet defaults with params for the connected chain
+	if op.Params == nil || op.Params == mavryk.DefaultParams {
+		op.WithParams(c.Params())
+	}
 	if c.mode == RunModeSimulate {
 		key, err := opts.Signer.GetKey(c.Context, op.Source)
 		if err != nil {
@@ -326,7 +333,7 @@ func (c *Context) UnsubscribeBlocks(id int) error {
 }
 
 func (c *Context) Params() *mavryk.Params {
-	return c.client.Params
+	return c.client.ChainParams()
 }
 
 func (c *Context) HeadBlock() *rpc.BlockHeaderLogEntry {
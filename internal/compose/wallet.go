@@ -7,6 +7,7 @@ import (
 	"crypto/ed25519"
 
 	"github.com/mavryk-network/mvgo/mavryk"
+	"github.com/mavryk-network/mvgo/rpc"
 	"github.com/tyler-smith/go-bip32"
 )
 
@@ -23,14 +24,13 @@ func (c *Context) MakeAccount(id int, alias string) (Account, error) {
 	if id < 0 {
 		id = c.MaxId + 1
 	}
-	sk, err := deriveChildKey(c.BaseAccount.PrivateKey, id)
+	sk, err := deriveChildKey(c.baseKey, id)
 	if err != nil {
 		return Account{}, nil
 	}
 	acc := Account{
-		Id:         id,
-		Address:    sk.Address(),
-		PrivateKey: sk,
+		Account: rpc.NewKeyAccount(sk),
+		Id:      id,
 	}
 	c.Log.Debugf("Creating account %d %s %s", id, acc.Address, alias)
 	c.AddVariable(alias, acc.Address.String())
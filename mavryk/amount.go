@@ -0,0 +1,200 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package mavryk
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// RoundingMode defines how token amounts are rounded when precision is lost.
+type RoundingMode byte
+
+const (
+	RoundDown     RoundingMode = iota // truncate towards zero
+	RoundUp                           // round away from zero
+	RoundHalfUp                       // round to nearest, ties away from zero
+	RoundHalfEven                     // round to nearest, ties to even
+)
+
+func (m RoundingMode) String() string {
+	switch m {
+	case RoundDown:
+		return "down"
+	case RoundUp:
+		return "up"
+	case RoundHalfUp:
+		return "half_up"
+	case RoundHalfEven:
+		return "half_even"
+	default:
+		return "invalid"
+	}
+}
+
+// TokenAmount is an integer token amount in base units together with the
+// number of decimals of the token. Arithmetic on amounts with different
+// decimals rescales to the larger precision so that no precision is lost.
+type TokenAmount struct {
+	Value    Z   `json:"value"`
+	Decimals int `json:"decimals"`
+}
+
+// NewTokenAmount creates an amount from a value in base units.
+func NewTokenAmount(v Z, decimals int) TokenAmount {
+	return TokenAmount{Value: v.Clone(), Decimals: decimals}
+}
+
+// ParseTokenAmount parses a decimal string like "12.5" into an amount with
+// the given decimals. It fails when s has more fractional digits than the
+// token supports instead of silently rounding.
+func ParseTokenAmount(s string, decimals int) (TokenAmount, error) {
+	if decimals < 0 {
+		return TokenAmount{}, fmt.Errorf("tezos: negative decimals %d", decimals)
+	}
+	str := strings.TrimSpace(s)
+	var neg bool
+	switch {
+	case strings.HasPrefix(str, "-"):
+		neg, str = true, str[1:]
+	case strings.HasPrefix(str, "+"):
+		str = str[1:]
+	}
+	ip, fp, _ := strings.Cut(str, ".")
+	if ip == "" && fp == "" {
+		return TokenAmount{}, fmt.Errorf("tezos: invalid token amount %q", s)
+	}
+	if len(fp) > decimals {
+		if strings.TrimRight(fp[decimals:], "0") != "" {
+			return TokenAmount{}, fmt.Errorf("tezos: token amount %q exceeds %d decimals", s, decimals)
+		}
+		fp = fp[:decimals]
+	}
+	digits := ip + fp + strings.Repeat("0", decimals-len(fp))
+	if strings.ContainsAny(digits, "+-") {
+		return TokenAmount{}, fmt.Errorf("tezos: invalid token amount %q", s)
+	}
+	b, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return TokenAmount{}, fmt.Errorf("tezos: invalid token amount %q", s)
+	}
+	if neg {
+		b.Neg(b)
+	}
+	return TokenAmount{Value: NewBigZ(b), Decimals: decimals}, nil
+}
+
+// IsZero returns true when the amount is zero.
+func (a TokenAmount) IsZero() bool {
+	return a.Value.IsZero()
+}
+
+// IsNeg returns true when the amount is negative.
+func (a TokenAmount) IsNeg() bool {
+	return a.Value.IsNeg()
+}
+
+// Rescale converts the amount to a different number of decimals. Precision
+// lost when reducing decimals is rounded using mode.
+func (a TokenAmount) Rescale(decimals int, mode RoundingMode) TokenAmount {
+	switch {
+	case decimals == a.Decimals:
+		return a
+	case decimals > a.Decimals:
+		return TokenAmount{Value: a.Value.Scale(decimals - a.Decimals), Decimals: decimals}
+	default:
+		factor := pow10(a.Decimals - decimals)
+		return TokenAmount{Value: NewBigZ(divRound(a.Value.Big(), factor, mode)), Decimals: decimals}
+	}
+}
+
+// align rescales both amounts to the larger number of decimals.
+func (a TokenAmount) align(b TokenAmount) (TokenAmount, TokenAmount) {
+	if a.Decimals > b.Decimals {
+		return a, b.Rescale(a.Decimals, RoundDown)
+	}
+	return a.Rescale(b.Decimals, RoundDown), b
+}
+
+// Add returns a + b.
+func (a TokenAmount) Add(b TokenAmount) TokenAmount {
+	a, b = a.align(b)
+	return TokenAmount{Value: a.Value.Add(b.Value), Decimals: a.Decimals}
+}
+
+// Sub returns a - b.
+func (a TokenAmount) Sub(b TokenAmount) TokenAmount {
+	a, b = a.align(b)
+	return TokenAmount{Value: a.Value.Sub(b.Value), Decimals: a.Decimals}
+}
+
+// MulDiv returns a * num / den rounded using mode, e.g. to apply a fee
+// rate or exchange price. Like Z.Div, the result is zero when den is zero.
+func (a TokenAmount) MulDiv(num, den Z, mode RoundingMode) TokenAmount {
+	if den.IsZero() {
+		return TokenAmount{Decimals: a.Decimals}
+	}
+	x := new(big.Int).Mul(a.Value.Big(), num.Big())
+	return TokenAmount{Value: NewBigZ(divRound(x, den.Big(), mode)), Decimals: a.Decimals}
+}
+
+// Cmp compares a and b and returns -1, 0 or +1.
+func (a TokenAmount) Cmp(b TokenAmount) int {
+	a, b = a.align(b)
+	return a.Value.Cmp(b.Value)
+}
+
+// Equal returns true when a and b represent the same amount.
+func (a TokenAmount) Equal(b TokenAmount) bool {
+	return a.Cmp(b) == 0
+}
+
+// String returns the amount as decimal string without trailing zeros.
+func (a TokenAmount) String() string {
+	s := a.Value.Decimals(a.Decimals)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return s
+}
+
+// Format returns the amount as decimal string with exactly places fractional
+// digits. Precision beyond places is rounded using mode.
+func (a TokenAmount) Format(places int, mode RoundingMode) string {
+	if places < 0 {
+		places = 0
+	}
+	return a.Rescale(places, mode).Value.Decimals(places)
+}
+
+// Float64 returns the amount as floating point number for display and
+// approximate calculations.
+func (a TokenAmount) Float64() float64 {
+	return a.Value.Float64(-a.Decimals)
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// divRound returns x / y rounded using mode.
+func divRound(x, y *big.Int, mode RoundingMode) *big.Int {
+	q, r := new(big.Int).QuoRem(x, y, new(big.Int))
+	if r.Sign() == 0 {
+		return q
+	}
+	// sign of the exact result
+	sign := int64(x.Sign() * y.Sign())
+	switch mode {
+	case RoundUp:
+		return q.Add(q, big.NewInt(sign))
+	case RoundHalfUp, RoundHalfEven:
+		c := new(big.Int).Lsh(new(big.Int).Abs(r), 1).CmpAbs(y)
+		if c > 0 || c == 0 && (mode == RoundHalfUp || q.Bit(0) == 1) {
+			return q.Add(q, big.NewInt(sign))
+		}
+	}
+	return q
+}
@@ -0,0 +1,169 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package mavryk_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+)
+
+func TestTokenAmountParse(t *testing.T) {
+	cases := []struct {
+		in  string
+		dec int
+		val string
+		str string
+		err bool
+	}{
+		{"1", 6, "1000000", "1", false},
+		{"1.5", 6, "1500000", "1.5", false},
+		{"-0.000001", 6, "-1", "-0.000001", false},
+		{".25", 2, "25", "0.25", false},
+		{"1.2300", 2, "123", "1.23", false},
+		{"1.234", 2, "", "", true},
+		{"1.2.3", 6, "", "", true},
+		{"1.-2", 6, "", "", true},
+		{"abc", 6, "", "", true},
+		{"", 6, "", "", true},
+	}
+	for _, c := range cases {
+		a, err := mavryk.ParseTokenAmount(c.in, c.dec)
+		if c.err {
+			if err == nil {
+				t.Errorf("%q: expected error, got %s", c.in, a.Value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", c.in, err)
+			continue
+		}
+		if got := a.Value.String(); got != c.val {
+			t.Errorf("%q: value mismatch: got=%s want=%s", c.in, got, c.val)
+		}
+		if got := a.String(); got != c.str {
+			t.Errorf("%q: string mismatch: got=%s want=%s", c.in, got, c.str)
+		}
+	}
+}
+
+func TestTokenAmountMath(t *testing.T) {
+	a := mavryk.NewTokenAmount(mavryk.NewZ(1500000), 6) // 1.5
+	b := mavryk.NewTokenAmount(mavryk.NewZ(25), 2)      // 0.25
+
+	if got := a.Add(b); got.String() != "1.75" || got.Decimals != 6 {
+		t.Errorf("add: got %s/%d", got, got.Decimals)
+	}
+	if got := b.Sub(a); got.String() != "-1.25" {
+		t.Errorf("sub: got %s", got)
+	}
+	if a.Cmp(b) != 1 || b.Cmp(a) != -1 {
+		t.Errorf("cmp mismatch")
+	}
+	if !b.Equal(mavryk.NewTokenAmount(mavryk.NewZ(250000), 6)) {
+		t.Errorf("equal mismatch")
+	}
+
+	// 2.5 and 3.5 at zero decimals
+	c := mavryk.NewTokenAmount(mavryk.NewZ(25), 1)
+	d := mavryk.NewTokenAmount(mavryk.NewZ(-35), 1)
+	for _, v := range []struct {
+		amount mavryk.TokenAmount
+		mode   mavryk.RoundingMode
+		want   string
+	}{
+		{c, mavryk.RoundDown, "2"},
+		{c, mavryk.RoundUp, "3"},
+		{c, mavryk.RoundHalfUp, "3"},
+		{c, mavryk.RoundHalfEven, "2"},
+		{d, mavryk.RoundDown, "-3"},
+		{d, mavryk.RoundUp, "-4"},
+		{d, mavryk.RoundHalfUp, "-4"},
+		{d, mavryk.RoundHalfEven, "-4"},
+	} {
+		if got := v.amount.Format(0, v.mode); got != v.want {
+			t.Errorf("format %s %s: got=%s want=%s", v.amount, v.mode, got, v.want)
+		}
+	}
+
+	// 1.5 * 1/3 = 0.5
+	if got := a.MulDiv(mavryk.NewZ(1), mavryk.NewZ(3), mavryk.RoundDown); got.Value.Int64() != 500000 {
+		t.Errorf("muldiv: got %s", got.Value)
+	}
+	// 1 / 3 at 6 decimals
+	e := mavryk.NewTokenAmount(mavryk.NewZ(1000000), 6)
+	if got := e.MulDiv(mavryk.NewZ(2), mavryk.NewZ(3), mavryk.RoundHalfUp); got.Value.Int64() != 666667 {
+		t.Errorf("muldiv round: got %s", got.Value)
+	}
+	if got := e.MulDiv(mavryk.NewZ(1), mavryk.NewZ(0), mavryk.RoundUp); !got.IsZero() {
+		t.Errorf("muldiv zero: got %s", got.Value)
+	}
+}
+
+func TestTokenAmountJSON(t *testing.T) {
+	a := mavryk.NewTokenAmount(mavryk.MustParseZ("123456789012345678901234567890"), 18)
+	buf, err := json.Marshal(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(buf), `{"value":"123456789012345678901234567890","decimals":18}`; got != want {
+		t.Fatalf("json: got=%s want=%s", got, want)
+	}
+	var b mavryk.TokenAmount
+	if err := json.Unmarshal(buf, &b); err != nil {
+		t.Fatal(err)
+	}
+	if !a.Equal(b) || b.Decimals != 18 {
+		t.Errorf("roundtrip mismatch: %s", b)
+	}
+	if got := b.String(); got != "123456789012.34567890123456789" {
+		t.Errorf("string: got %s", got)
+	}
+}
+
+func TestNumberFormat(t *testing.T) {
+	v := mavryk.NewTokenAmount(mavryk.NewZ(1234567500000), 6)
+	round := mavryk.EnglishNumberFormat.WithPlaces(2)
+	round.Mode = mavryk.RoundHalfUp
+	cases := []struct {
+		f    mavryk.NumberFormat
+		a    mavryk.TokenAmount
+		want string
+	}{
+		{mavryk.PlainNumberFormat, v, "1234567.5"},
+		{mavryk.EnglishNumberFormat, v, "1,234,567.5 XTZ"},
+		{mavryk.GermanNumberFormat, v, "1.234.567,5 XTZ"},
+		{mavryk.FrenchNumberFormat, v, "1 234 567,5 XTZ"},
+		{mavryk.SwissNumberFormat, mavryk.NewTokenAmount(mavryk.NewZ(-1234567500000), 6), "-XTZ 1'234'567.5"},
+		{mavryk.EnglishNumberFormat.WithPlaces(2), mavryk.NewTokenAmount(mavryk.NewZ(999995), 6), "0.99 XTZ"},
+		{round, mavryk.NewTokenAmount(mavryk.NewZ(999995), 6), "1.00 XTZ"},
+		{mavryk.EnglishNumberFormat.WithSymbol(""), mavryk.NewTokenAmount(mavryk.NewZ(123), 0), "123"},
+	}
+	for i, c := range cases {
+		if got := c.f.Format(c.a); got != c.want {
+			t.Errorf("case %d: got %q want %q", i, got, c.want)
+		}
+	}
+	if got, want := mavryk.EnglishNumberFormat.FormatNative(-1000000), "-1 XTZ"; got != want {
+		t.Errorf("native: got %q want %q", got, want)
+	}
+}
+
+func TestLookupNumberFormat(t *testing.T) {
+	if f, ok := mavryk.LookupNumberFormat("en-US"); !ok || f.GroupSep != "," {
+		t.Errorf("en-US: expected english fallback, got %v %v", f, ok)
+	}
+	if f, ok := mavryk.LookupNumberFormat("de-CH"); !ok || f.GroupSep != "'" {
+		t.Errorf("de-CH: expected swiss format, got %v %v", f, ok)
+	}
+	if _, ok := mavryk.LookupNumberFormat("xx"); ok {
+		t.Errorf("xx: expected no format")
+	}
+	mavryk.RegisterNumberFormat("EN-GB", mavryk.EnglishNumberFormat.WithSymbol("MVRK"))
+	if f, ok := mavryk.LookupNumberFormat("en-gb"); !ok || f.Symbol != "MVRK" {
+		t.Errorf("en-GB: expected registered format, got %v %v", f, ok)
+	}
+}
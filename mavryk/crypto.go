@@ -5,14 +5,15 @@ package mavryk
 
 import (
 	"fmt"
+	"io"
 	"math/big"
 
 	"crypto/ecdsa"
 	"crypto/elliptic"
-	"crypto/rand"
 	"crypto/sha512"
 
 	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	secpecdsa "github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
 	"golang.org/x/crypto/nacl/secretbox"
 	"golang.org/x/crypto/pbkdf2"
 )
@@ -45,8 +46,21 @@ func ecNormalizeSignature(r, s *big.Int, c elliptic.Curve) (*big.Int, *big.Int)
 	return r, s
 }
 
+// ecSign signs hash with sk. Secp256k1 signatures use RFC 6979 deterministic
+// nonces and constant time arithmetic. P256 signatures use crypto/ecdsa which
+// derives nonces from the private key, the hash and RandReader, so they are
+// safe on weak entropy but not reproducible.
 func ecSign(sk *ecdsa.PrivateKey, hash []byte) ([]byte, error) {
-	r, s, err := ecdsa.Sign(rand.Reader, sk, hash)
+	if sk.D == nil || sk.D.Sign() <= 0 {
+		return nil, fmt.Errorf("tezos: invalid private key for curve %s", sk.Curve.Params().Name)
+	}
+	if sk.Curve == secp256k1.S256() {
+		priv := secp256k1.PrivKeyFromBytes(sk.D.FillBytes(make([]byte, 32)))
+		defer priv.Zero()
+		// compact signatures are low-s normalized, strip the recovery code
+		return secpecdsa.SignCompact(priv, hash, true)[1:], nil
+	}
+	r, s, err := ecdsa.Sign(RandReader, sk, hash)
 	if err != nil {
 		return nil, err
 	}
@@ -175,7 +189,7 @@ func encryptPrivateKey(key []byte, fn PassphraseFunc) ([]byte, error) {
 	}
 
 	salt := make([]byte, 8)
-	_, err = rand.Read(salt)
+	_, err = io.ReadFull(RandReader, salt)
 	if err != nil {
 		return nil, err
 	}
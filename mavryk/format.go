@@ -0,0 +1,189 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package mavryk
+
+import (
+	"strings"
+	"sync"
+)
+
+// NumberFormat controls how amounts are rendered for humans, e.g. in reports,
+// CSV exports and command line tools. Use Format for token amounts and
+// FormatNative for native amounts in base units.
+type NumberFormat struct {
+	Symbol       string       // currency symbol, empty for none
+	SymbolSuffix bool         // place symbol after the number
+	SymbolSpace  bool         // separate symbol and number by a space
+	DecimalSep   string       // decimal separator, defaults to "."
+	GroupSep     string       // thousands separator, empty disables grouping
+	GroupSize    int          // digits per group, defaults to 3
+	Places       int          // fractional digits, negative trims trailing zeros
+	Mode         RoundingMode // rounding when Places reduces precision
+}
+
+// Predefined number formats. Each can be copied and adapted, e.g. to set a
+// different symbol.
+var (
+	// PlainNumberFormat renders numbers without symbol and grouping like
+	// TokenAmount.String. It is suitable for machine readable exports.
+	PlainNumberFormat = NumberFormat{
+		DecimalSep: ".",
+		Places:     -1,
+	}
+
+	// EnglishNumberFormat renders 1,234.5 XTZ.
+	EnglishNumberFormat = NumberFormat{
+		Symbol:       Symbol,
+		SymbolSuffix: true,
+		SymbolSpace:  true,
+		DecimalSep:   ".",
+		GroupSep:     ",",
+		Places:       -1,
+	}
+
+	// GermanNumberFormat renders 1.234,5 XTZ.
+	GermanNumberFormat = NumberFormat{
+		Symbol:       Symbol,
+		SymbolSuffix: true,
+		SymbolSpace:  true,
+		DecimalSep:   ",",
+		GroupSep:     ".",
+		Places:       -1,
+	}
+
+	// FrenchNumberFormat renders 1 234,5 XTZ using narrow no-break spaces
+	// for grouping.
+	FrenchNumberFormat = NumberFormat{
+		Symbol:       Symbol,
+		SymbolSuffix: true,
+		SymbolSpace:  true,
+		DecimalSep:   ",",
+		GroupSep:     "\u202f",
+		Places:       -1,
+	}
+
+	// SwissNumberFormat renders XTZ 1'234.5.
+	SwissNumberFormat = NumberFormat{
+		Symbol:      Symbol,
+		SymbolSpace: true,
+		DecimalSep:  ".",
+		GroupSep:    "'",
+		Places:      -1,
+	}
+)
+
+var (
+	numberFormatMu sync.RWMutex
+	numberFormats  = map[string]NumberFormat{
+		"":      PlainNumberFormat,
+		"en":    EnglishNumberFormat,
+		"de":    GermanNumberFormat,
+		"fr":    FrenchNumberFormat,
+		"de-ch": SwissNumberFormat,
+	}
+)
+
+// RegisterNumberFormat makes format f available under locale name, e.g. to
+// add a locale or to replace the symbol of a predefined locale.
+func RegisterNumberFormat(name string, f NumberFormat) {
+	numberFormatMu.Lock()
+	defer numberFormatMu.Unlock()
+	numberFormats[strings.ToLower(name)] = f
+}
+
+// LookupNumberFormat returns the format registered for locale name. Names are
+// matched case insensitive, names with region like en-US fall back to the
+// language when no format is registered for the region.
+func LookupNumberFormat(name string) (NumberFormat, bool) {
+	numberFormatMu.RLock()
+	defer numberFormatMu.RUnlock()
+	name = strings.ToLower(name)
+	if f, ok := numberFormats[name]; ok {
+		return f, true
+	}
+	if i := strings.IndexAny(name, "-_"); i > 0 {
+		f, ok := numberFormats[name[:i]]
+		return f, ok
+	}
+	return NumberFormat{}, false
+}
+
+// WithSymbol returns a copy of f using currency symbol sym.
+func (f NumberFormat) WithSymbol(sym string) NumberFormat {
+	f.Symbol = sym
+	return f
+}
+
+// WithPlaces returns a copy of f which renders exactly n fractional digits.
+func (f NumberFormat) WithPlaces(n int) NumberFormat {
+	f.Places = n
+	return f
+}
+
+// Format renders token amount a.
+func (f NumberFormat) Format(a TokenAmount) string {
+	var s string
+	if f.Places < 0 {
+		s = a.String()
+	} else {
+		s = a.Format(f.Places, f.Mode)
+	}
+	neg := strings.HasPrefix(s, "-")
+	if neg {
+		s = s[1:]
+	}
+	ip, fp, _ := strings.Cut(s, ".")
+
+	var b strings.Builder
+	if neg {
+		b.WriteByte('-')
+	}
+	if f.Symbol != "" && !f.SymbolSuffix {
+		b.WriteString(f.Symbol)
+		if f.SymbolSpace {
+			b.WriteByte(' ')
+		}
+	}
+	f.writeGrouped(&b, ip)
+	if fp != "" {
+		if f.DecimalSep == "" {
+			b.WriteByte('.')
+		} else {
+			b.WriteString(f.DecimalSep)
+		}
+		b.WriteString(fp)
+	}
+	if f.Symbol != "" && f.SymbolSuffix {
+		if f.SymbolSpace {
+			b.WriteByte(' ')
+		}
+		b.WriteString(f.Symbol)
+	}
+	return b.String()
+}
+
+// FormatNative renders a native amount in base units (mumav).
+func (f NumberFormat) FormatNative(v int64) string {
+	return f.Format(NewTokenAmount(NewZ(v), 6))
+}
+
+func (f NumberFormat) writeGrouped(b *strings.Builder, digits string) {
+	size := f.GroupSize
+	if size <= 0 {
+		size = 3
+	}
+	if f.GroupSep == "" || len(digits) <= size {
+		b.WriteString(digits)
+		return
+	}
+	first := len(digits) % size
+	if first == 0 {
+		first = size
+	}
+	b.WriteString(digits[:first])
+	for i := first; i < len(digits); i += size {
+		b.WriteString(f.GroupSep)
+		b.WriteString(digits[i : i+size])
+	}
+}
@@ -30,6 +30,7 @@ var (
 	ZeroContextHash           = NewContextHash(nil)
 	ZeroSmartRollupStateHash  = NewSmartRollupStateHash(nil)
 	ZeroSmartRollupCommitHash = NewSmartRollupCommitHash(nil)
+	ZeroDalCommitment         = NewDalCommitment(nil)
 )
 
 type HashType struct {
@@ -99,6 +100,7 @@ var (
 	HashTypeSmartRollupStateHash      = HashType{SMART_ROLLUP_STATE_HASH_ID, 32, SMART_ROLLUP_STATE_HASH_PREFIX, 54}
 	HashTypeSmartRollupCommitHash     = HashType{SMART_ROLLUP_COMMITMENT_HASH_ID, 32, SMART_ROLLUP_COMMITMENT_HASH_PREFIX, 54}
 	HashTypeSmartRollupRevealHash     = HashType{SMART_ROLLUP_REVEAL_HASH_ID, 32, SMART_ROLLUP_REVEAL_HASH_PREFIX, 56}
+	HashTypeDalCommitment             = HashType{DAL_COMMITMENT_ID, 48, DAL_COMMITMENT_PREFIX, 74}
 )
 
 func (t HashType) IsValid() bool {
@@ -875,6 +877,74 @@ func (h *SmartRollupStateHash) Set(hash string) (err error) {
 	return
 }
 
+// DalCommitment is a KZG commitment to the content of a DAL slot.
+type DalCommitment [48]byte
+
+func NewDalCommitment(buf []byte) (h DalCommitment) {
+	copy(h[:], buf)
+	return
+}
+
+func (h DalCommitment) IsValid() bool {
+	return !h.Equal(ZeroDalCommitment)
+}
+
+func (h DalCommitment) Equal(h2 DalCommitment) bool {
+	return h == h2
+}
+
+func (h DalCommitment) Clone() DalCommitment {
+	return NewDalCommitment(h[:])
+}
+
+func (h DalCommitment) String() string {
+	return base58.CheckEncode(h[:], HashTypeDalCommitment.Id)
+}
+
+func (h DalCommitment) Bytes() []byte {
+	return h[:]
+}
+
+func (h DalCommitment) MarshalText() ([]byte, error) {
+	return []byte(h.String()), nil
+}
+
+func (h *DalCommitment) UnmarshalText(buf []byte) error {
+	if len(buf) == 0 {
+		return nil
+	}
+	return decodeHash(buf, HashTypeDalCommitment, h[:])
+}
+
+func (h DalCommitment) MarshalBinary() ([]byte, error) {
+	return h[:], nil
+}
+
+func (h *DalCommitment) UnmarshalBinary(buf []byte) error {
+	if l := len(buf); l > 0 && l != HashTypeDalCommitment.Len {
+		return fmt.Errorf("tezos: short dal commitment")
+	}
+	copy(h[:], buf)
+	return nil
+}
+
+func ParseDalCommitment(s string) (h DalCommitment, err error) {
+	err = decodeHashString(s, HashTypeDalCommitment, h[:])
+	return
+}
+
+func MustParseDalCommitment(s string) DalCommitment {
+	b, err := ParseDalCommitment(s)
+	panicOnError(err)
+	return b
+}
+
+// Set implements the flags.Value interface for use in command line argument parsing.
+func (h *DalCommitment) Set(hash string) (err error) {
+	*h, err = ParseDalCommitment(hash)
+	return
+}
+
 // internal decoders
 func decodeHash(src []byte, typ HashType, dst []byte) error {
 	return decodeHashString(string(src), typ, dst)
@@ -91,6 +91,13 @@ func TestHash(t *testing.T) {
 			Type:   HashTypeContext,
 			Val:    &ContextHash{},
 		},
+		// dal commitment
+		{
+			String: "sh1N3zXZGmwD8nUJKASG173ByrJVotnxgh9v6P9hLhuWF4AoPCKR1DqSfhhVE2PkLjdqRYDSpX",
+			Bytes:  MustDecodeString("01060b10151a1f24292e33383d42474c51565b60656a6f74797e83888d92979ca1a6abb0b5babfc4c9ced3d8dde2e7ec"),
+			Type:   HashTypeDalCommitment,
+			Val:    &DalCommitment{},
+		},
 	}
 
 	for i, c := range cases {
@@ -0,0 +1,261 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package mavryk
+
+import (
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// HardenedKeyStart is the index of the first hardened child key.
+	HardenedKeyStart uint32 = 0x80000000
+
+	// BIP44CoinType is the registered coin type used in derivation paths.
+	BIP44CoinType = 1729
+)
+
+var ErrInvalidPath = errors.New("tezos: invalid derivation path")
+
+// MnemonicSeed returns the BIP39 seed for a mnemonic and optional passphrase.
+// The mnemonic checksum is not verified and words must be ASCII since no
+// unicode normalization is performed.
+func MnemonicSeed(mnemonic, passphrase string) []byte {
+	words := strings.Join(strings.Fields(mnemonic), " ")
+	return pbkdf2.Key([]byte(words), []byte("mnemonic"+passphrase), 2048, 64, sha512.New)
+}
+
+// DerivationPath is a BIP32 key derivation path.
+type DerivationPath []uint32
+
+// ParseDerivationPath parses paths like m/44'/1729'/0'/0'. Hardened indices
+// are marked with ' or h.
+func ParseDerivationPath(s string) (DerivationPath, error) {
+	parts := strings.Split(strings.TrimSpace(s), "/")
+	if len(parts) == 0 || parts[0] != "m" {
+		return nil, fmt.Errorf("%w %q", ErrInvalidPath, s)
+	}
+	path := make(DerivationPath, 0, len(parts)-1)
+	for _, v := range parts[1:] {
+		var offset uint32
+		if n := len(v); n > 0 && (v[n-1] == '\'' || v[n-1] == 'h' || v[n-1] == 'H') {
+			offset = HardenedKeyStart
+			v = v[:n-1]
+		}
+		i, err := strconv.ParseUint(v, 10, 32)
+		if err != nil || uint32(i) >= HardenedKeyStart {
+			return nil, fmt.Errorf("%w %q", ErrInvalidPath, s)
+		}
+		path = append(path, uint32(i)+offset)
+	}
+	return path, nil
+}
+
+// MustParseDerivationPath is like ParseDerivationPath but panics on error.
+func MustParseDerivationPath(s string) DerivationPath {
+	p, err := ParseDerivationPath(s)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+func (p DerivationPath) String() string {
+	var b strings.Builder
+	b.WriteString("m")
+	for _, v := range p {
+		b.WriteByte('/')
+		if v >= HardenedKeyStart {
+			b.WriteString(strconv.FormatUint(uint64(v-HardenedKeyStart), 10))
+			b.WriteByte('\'')
+		} else {
+			b.WriteString(strconv.FormatUint(uint64(v), 10))
+		}
+	}
+	return b.String()
+}
+
+// IsHardened returns true when all path elements are hardened.
+func (p DerivationPath) IsHardened() bool {
+	for _, v := range p {
+		if v < HardenedKeyStart {
+			return false
+		}
+	}
+	return true
+}
+
+// DeriveKey derives a private key of type typ from a BIP39 seed along path.
+// Ed25519 and P256 keys use SLIP-0010, Secp256k1 keys use BIP32. Ed25519
+// only supports hardened derivation.
+func DeriveKey(typ KeyType, seed []byte, path DerivationPath) (PrivateKey, error) {
+	switch typ {
+	case KeyTypeEd25519:
+		if !path.IsHardened() {
+			return PrivateKey{}, fmt.Errorf("%w: ed25519 requires hardened path %s", ErrInvalidPath, path)
+		}
+		k, c := hdMaster([]byte("ed25519 seed"), seed)
+		for _, i := range path {
+			k, c = hdChild(c, 0, k, i)
+		}
+		return PrivateKey{
+			Type: KeyTypeEd25519,
+			Data: []byte(ed25519.NewKeyFromSeed(k)),
+		}, nil
+	case KeyTypeSecp256k1, KeyTypeP256:
+		var (
+			curve = typ.Curve()
+			key   = []byte("Bitcoin seed")
+		)
+		if typ == KeyTypeP256 {
+			key = []byte("Nist256p1 seed")
+		}
+		k, c, err := ecMaster(curve, key, seed)
+		if err != nil {
+			return PrivateKey{}, err
+		}
+		for _, i := range path {
+			if k, c, err = ecChild(curve, k, c, i); err != nil {
+				return PrivateKey{}, err
+			}
+		}
+		return PrivateKey{
+			Type: typ,
+			Data: k,
+		}, nil
+	default:
+		return PrivateKey{}, ErrUnknownKeyType
+	}
+}
+
+// DeriveLegacyKey returns the Ed25519 key of fundraiser and early
+// command line wallets which use the first 32 bytes of the BIP39 seed
+// as private key without derivation.
+func DeriveLegacyKey(seed []byte) PrivateKey {
+	return PrivateKey{
+		Type: KeyTypeEd25519,
+		Data: []byte(ed25519.NewKeyFromSeed(seed[:ed25519.SeedSize])),
+	}
+}
+
+// DerivedAddress is an address derived from a seed by a wallet scheme.
+type DerivedAddress struct {
+	Scheme  string         // wallet scheme description
+	Path    DerivationPath // derivation path, nil for legacy keys
+	Key     PrivateKey
+	Address Address
+}
+
+// derivationSchemes lists derivation paths used by common wallets where %d
+// is replaced with the account index.
+var derivationSchemes = []struct {
+	Name string
+	Path string
+	Type KeyType
+}{
+	{"bip44 account (ledger, kukai, temple)", "m/44'/1729'/%d'/0'", KeyTypeEd25519},
+	{"bip44 address (galleon)", "m/44'/1729'/0'/%d'", KeyTypeEd25519},
+	{"bip44 account (ledger)", "m/44'/1729'/%d'/0'", KeyTypeSecp256k1},
+	{"bip44 account (ledger)", "m/44'/1729'/%d'/0'", KeyTypeP256},
+	{"bip44 non-hardened", "m/44'/1729'/%d'/0/0", KeyTypeSecp256k1},
+	{"bip44 non-hardened", "m/44'/1729'/%d'/0/0", KeyTypeP256},
+}
+
+// DeriveAddresses lists addresses derived from seed with common wallet
+// schemes across curves for the first n accounts. Use it to find funds sent
+// to addresses which another wallet derived from the same mnemonic.
+func DeriveAddresses(seed []byte, n int) ([]DerivedAddress, error) {
+	sk := DeriveLegacyKey(seed)
+	list := []DerivedAddress{{
+		Scheme:  "legacy (fundraiser, cli)",
+		Key:     sk,
+		Address: sk.Address(),
+	}}
+	for _, s := range derivationSchemes {
+		for i := 0; i < n; i++ {
+			path := MustParseDerivationPath(fmt.Sprintf(s.Path, i))
+			sk, err := DeriveKey(s.Type, seed, path)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, DerivedAddress{
+				Scheme:  s.Name,
+				Path:    path,
+				Key:     sk,
+				Address: sk.Address(),
+			})
+		}
+	}
+	return list, nil
+}
+
+func hdMaster(key, seed []byte) ([]byte, []byte) {
+	mac := hmac.New(sha512.New, key)
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+	return sum[:32], sum[32:]
+}
+
+func hdChild(chain []byte, prefix byte, data []byte, i uint32) ([]byte, []byte) {
+	mac := hmac.New(sha512.New, chain)
+	mac.Write([]byte{prefix})
+	mac.Write(data)
+	var idx [4]byte
+	binary.BigEndian.PutUint32(idx[:], i)
+	mac.Write(idx[:])
+	sum := mac.Sum(nil)
+	return sum[:32], sum[32:]
+}
+
+func ecMaster(curve elliptic.Curve, key, seed []byte) ([]byte, []byte, error) {
+	n := curve.Params().N
+	k, c := hdMaster(key, seed)
+	for {
+		if v := new(big.Int).SetBytes(k); v.Sign() > 0 && v.Cmp(n) < 0 {
+			return k, c, nil
+		}
+		// SLIP-0010: retry with the full HMAC output as new seed
+		k, c = hdMaster(key, append(append([]byte{}, k...), c...))
+	}
+}
+
+func ecChild(curve elliptic.Curve, k, c []byte, i uint32) ([]byte, []byte, error) {
+	n := curve.Params().N
+	kv := new(big.Int).SetBytes(k)
+	var (
+		prefix byte
+		data   []byte
+	)
+	if i >= HardenedKeyStart {
+		data = k
+	} else {
+		x, y := curve.ScalarBaseMult(k)
+		pk := elliptic.MarshalCompressed(curve, x, y)
+		prefix, data = pk[0], pk[1:]
+	}
+	for {
+		il, ir := hdChild(c, prefix, data, i)
+		v := new(big.Int).SetBytes(il)
+		if v.Cmp(n) < 0 {
+			v.Add(v, kv).Mod(v, n)
+			if v.Sign() > 0 {
+				child := make([]byte, 32)
+				v.FillBytes(child)
+				return child, ir, nil
+			}
+		}
+		// SLIP-0010: retry with 0x01 || IR || i
+		prefix, data = 1, ir
+	}
+}
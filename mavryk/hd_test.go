@@ -0,0 +1,72 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package mavryk
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"testing"
+)
+
+func TestMnemonicSeed(t *testing.T) {
+	m := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	want := "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04"
+	if have := hex.EncodeToString(MnemonicSeed(m, "TREZOR")); have != want {
+		t.Errorf("mismatch seed\n  want=%s\n  have=%s", want, have)
+	}
+}
+
+func TestDerivationPath(t *testing.T) {
+	for _, s := range []string{"m", "m/44'/1729'/0'/0'", "m/0/1/2147483647'"} {
+		p, err := ParseDerivationPath(s)
+		if err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+		if p.String() != s {
+			t.Errorf("mismatch path want=%s have=%s", s, p)
+		}
+	}
+	if p := MustParseDerivationPath("m/1h/2H"); p.String() != "m/1'/2'" {
+		t.Errorf("unexpected path %s", p)
+	}
+	for _, s := range []string{"", "44'/0'", "m/x", "m/2147483648"} {
+		if _, err := ParseDerivationPath(s); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+}
+
+// test vector 1 from BIP32 and SLIP-0010
+func TestDeriveKey(t *testing.T) {
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	cases := []struct {
+		Type KeyType
+		Path string
+		Key  string
+	}{
+		{KeyTypeEd25519, "m", "2b4be7f19ee27bbf30c667b642d5f4aa69fd169872f8fc3059c08ebae2eb19e7"},
+		{KeyTypeEd25519, "m/0'", "68e0fe46dfb67e368c75379acec591dad19df3cde26e63b93a8e704f1dade7a3"},
+		{KeyTypeEd25519, "m/0'/1'", "b1d0bad404bf35da785a64ca1ac54b2617211d2777696fbffaf208f746ae84f2"},
+		{KeyTypeSecp256k1, "m/0'", "edb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea"},
+		{KeyTypeSecp256k1, "m/0'/1", "3c6cb8d0f6a264c91ea8b5030fadaa8e538b020f0a387421a12de9319dc93368"},
+		{KeyTypeP256, "m", "612091aaa12e22dd2abef664f8a01a82cae99ad7441b7ef8110424915c268bc2"},
+		{KeyTypeP256, "m/0'", "6939694369114c67917a182c59ddb8cafc3004e63ca5d3b84403ba8613debc0c"},
+	}
+	for _, c := range cases {
+		sk, err := DeriveKey(c.Type, seed, MustParseDerivationPath(c.Path))
+		if err != nil {
+			t.Fatalf("%s %s: %v", c.Type, c.Path, err)
+		}
+		buf := sk.Data
+		if c.Type == KeyTypeEd25519 {
+			buf = ed25519.PrivateKey(sk.Data).Seed()
+		}
+		if have := hex.EncodeToString(buf); have != c.Key {
+			t.Errorf("%s %s: mismatch key\n  want=%s\n  have=%s", c.Type, c.Path, c.Key, have)
+		}
+	}
+	if _, err := DeriveKey(KeyTypeEd25519, seed, MustParseDerivationPath("m/0")); err == nil {
+		t.Errorf("expected error for non-hardened ed25519 path")
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package mavryk
+
+import (
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+)
+
+// JWK represents a JSON Web Key as defined in RFC 7517 for the curves supported
+// by Tezos. Ed25519 keys use key type OKP (RFC 8037), P256 and Secp256k1 keys
+// use key type EC (RFC 7518, RFC 8812).
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y,omitempty"`
+	D   string `json:"d,omitempty"`
+	Kid string `json:"kid,omitempty"`
+}
+
+const (
+	jwkTypeOKP = "OKP"
+	jwkTypeEC  = "EC"
+)
+
+func jwkCurve(t KeyType) string {
+	switch t {
+	case KeyTypeEd25519:
+		return "Ed25519"
+	case KeyTypeP256:
+		return "P-256"
+	case KeyTypeSecp256k1:
+		return "secp256k1"
+	default:
+		return ""
+	}
+}
+
+func (j JWK) keyType() (KeyType, error) {
+	switch {
+	case j.Kty == jwkTypeOKP && j.Crv == "Ed25519":
+		return KeyTypeEd25519, nil
+	case j.Kty == jwkTypeEC && j.Crv == "P-256":
+		return KeyTypeP256, nil
+	case j.Kty == jwkTypeEC && j.Crv == "secp256k1":
+		return KeyTypeSecp256k1, nil
+	default:
+		return KeyTypeInvalid, fmt.Errorf("tezos: unsupported JWK kty=%q crv=%q", j.Kty, j.Crv)
+	}
+}
+
+func jwkEncode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func jwkDecode(s string, n int) ([]byte, error) {
+	buf, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("tezos: invalid JWK field: %w", err)
+	}
+	if n > 0 && len(buf) != n {
+		return nil, fmt.Errorf("tezos: invalid JWK field length %d", len(buf))
+	}
+	return buf, nil
+}
+
+// JWK returns the public key as JSON Web Key. The key id is set
+// to the key's Tezos address.
+func (k Key) JWK() (*JWK, error) {
+	if !k.IsValid() {
+		return nil, fmt.Errorf("tezos: invalid key")
+	}
+	jwk := &JWK{
+		Crv: jwkCurve(k.Type),
+		Kid: k.Address().String(),
+	}
+	switch k.Type {
+	case KeyTypeEd25519:
+		jwk.Kty = jwkTypeOKP
+		jwk.X = jwkEncode(k.Data)
+	case KeyTypeSecp256k1, KeyTypeP256:
+		curve := k.Type.Curve()
+		pk, err := ecUnmarshalCompressed(curve, k.Data)
+		if err != nil {
+			return nil, err
+		}
+		byteLen := (curve.Params().BitSize + 7) / 8
+		x, y := make([]byte, byteLen), make([]byte, byteLen)
+		pk.X.FillBytes(x)
+		pk.Y.FillBytes(y)
+		jwk.Kty = jwkTypeEC
+		jwk.X = jwkEncode(x)
+		jwk.Y = jwkEncode(y)
+	default:
+		return nil, ErrUnknownKeyType
+	}
+	return jwk, nil
+}
+
+// JWK returns the private key as JSON Web Key including public key fields.
+func (k PrivateKey) JWK() (*JWK, error) {
+	if !k.IsValid() {
+		return nil, fmt.Errorf("tezos: invalid private key")
+	}
+	jwk, err := k.Public().JWK()
+	if err != nil {
+		return nil, err
+	}
+	switch k.Type {
+	case KeyTypeEd25519:
+		jwk.D = jwkEncode(ed25519.PrivateKey(k.Data).Seed())
+	default:
+		jwk.D = jwkEncode(k.Data)
+	}
+	return jwk, nil
+}
+
+// Key decodes the public key from a JSON Web Key.
+func (j JWK) Key() (Key, error) {
+	typ, err := j.keyType()
+	if err != nil {
+		return InvalidKey, err
+	}
+	switch typ {
+	case KeyTypeEd25519:
+		x, err := jwkDecode(j.X, ed25519.PublicKeySize)
+		if err != nil {
+			return InvalidKey, err
+		}
+		return NewKey(typ, x), nil
+	default:
+		curve := typ.Curve()
+		byteLen := (curve.Params().BitSize + 7) / 8
+		x, err := jwkDecode(j.X, byteLen)
+		if err != nil {
+			return InvalidKey, err
+		}
+		y, err := jwkDecode(j.Y, byteLen)
+		if err != nil {
+			return InvalidKey, err
+		}
+		bx, by := new(big.Int).SetBytes(x), new(big.Int).SetBytes(y)
+		if !curve.IsOnCurve(bx, by) {
+			return InvalidKey, fmt.Errorf("tezos: (%s) invalid public key", curve.Params().Name)
+		}
+		return NewKey(typ, elliptic.MarshalCompressed(curve, bx, by)), nil
+	}
+}
+
+// PrivateKey decodes the private key from a JSON Web Key. When public key
+// fields are present they must match the private key.
+func (j JWK) PrivateKey() (PrivateKey, error) {
+	typ, err := j.keyType()
+	if err != nil {
+		return PrivateKey{}, err
+	}
+	if j.D == "" {
+		return PrivateKey{}, fmt.Errorf("tezos: missing JWK private key")
+	}
+	var key PrivateKey
+	switch typ {
+	case KeyTypeEd25519:
+		d, err := jwkDecode(j.D, ed25519.SeedSize)
+		if err != nil {
+			return PrivateKey{}, err
+		}
+		key = PrivateKey{Type: typ, Data: []byte(ed25519.NewKeyFromSeed(d))}
+	default:
+		curve := typ.Curve()
+		d, err := jwkDecode(j.D, (curve.Params().BitSize+7)/8)
+		if err != nil {
+			return PrivateKey{}, err
+		}
+		if _, err := ecPrivateKeyFromBytes(d, curve); err != nil {
+			return PrivateKey{}, err
+		}
+		key = PrivateKey{Type: typ, Data: d}
+	}
+	if j.X != "" {
+		pk, err := j.Key()
+		if err != nil {
+			return PrivateKey{}, err
+		}
+		if !pk.IsEqual(key.Public()) {
+			return PrivateKey{}, fmt.Errorf("tezos: JWK public key mismatch")
+		}
+	}
+	return key, nil
+}
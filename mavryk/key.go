@@ -7,6 +7,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"strings"
 
 	"crypto/ecdsa"
@@ -32,6 +33,11 @@ var (
 
 	// Digest is an alias for blake2b checksum algorithm
 	Digest = blake2b.Sum256
+
+	// RandReader is the entropy source used for key generation, private
+	// key encryption and P256 signing. Defaults to crypto/rand. Platforms without a system
+	// random source (e.g. some js/wasm hosts) may plug in their own reader.
+	RandReader io.Reader = rand.Reader
 )
 
 // PassphraseFunc is a callback used to obtain a passphrase for decrypting a private key
@@ -553,14 +559,14 @@ func GenerateKey(typ KeyType) (PrivateKey, error) {
 	}
 	switch typ {
 	case KeyTypeEd25519:
-		_, sk, err := ed25519.GenerateKey(nil)
+		_, sk, err := ed25519.GenerateKey(RandReader)
 		if err != nil {
 			return key, err
 		}
 		key.Data = []byte(sk)
 	case KeyTypeSecp256k1, KeyTypeP256:
 		curve := typ.Curve()
-		ecKey, err := ecdsa.GenerateKey(curve, rand.Reader)
+		ecKey, err := ecdsa.GenerateKey(curve, RandReader)
 		if err != nil {
 			return key, err
 		}
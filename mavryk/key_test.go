@@ -4,6 +4,11 @@
 package mavryk
 
 import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"math/big"
 	"testing"
 )
 
@@ -152,3 +157,118 @@ func TestSign(t *testing.T) {
 		}
 	}
 }
+
+func TestKeyPEM(t *testing.T) {
+	for _, typ := range []KeyType{KeyTypeEd25519, KeyTypeSecp256k1, KeyTypeP256} {
+		sk, err := GenerateKey(typ)
+		if err != nil {
+			t.Fatalf("%s: generate failed: %v", typ, err)
+		}
+		buf, err := sk.MarshalPEM()
+		if err != nil {
+			t.Fatalf("%s: private key encode failed: %v", typ, err)
+		}
+		sk2, err := ParsePEMPrivateKey(buf)
+		if err != nil {
+			t.Fatalf("%s: private key decode failed: %v", typ, err)
+		}
+		if sk.String() != sk2.String() {
+			t.Errorf("%s: private key mismatch", typ)
+		}
+		buf, err = sk.Public().MarshalPEM()
+		if err != nil {
+			t.Fatalf("%s: public key encode failed: %v", typ, err)
+		}
+		pk, err := ParsePEMKey(buf)
+		if err != nil {
+			t.Fatalf("%s: public key decode failed: %v", typ, err)
+		}
+		if !pk.IsEqual(sk.Public()) {
+			t.Errorf("%s: public key mismatch", typ)
+		}
+		if typ != KeyTypeSecp256k1 {
+			// cross check with stdlib
+			block, _ := pem.Decode(buf)
+			if _, err := x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+				t.Errorf("%s: stdlib public key decode failed: %v", typ, err)
+			}
+		}
+	}
+}
+
+func TestKeyJWK(t *testing.T) {
+	for _, typ := range []KeyType{KeyTypeEd25519, KeyTypeSecp256k1, KeyTypeP256} {
+		sk, err := GenerateKey(typ)
+		if err != nil {
+			t.Fatalf("%s: generate failed: %v", typ, err)
+		}
+		jwk, err := sk.JWK()
+		if err != nil {
+			t.Fatalf("%s: private key encode failed: %v", typ, err)
+		}
+		sk2, err := jwk.PrivateKey()
+		if err != nil {
+			t.Fatalf("%s: private key decode failed: %v", typ, err)
+		}
+		if sk.String() != sk2.String() {
+			t.Errorf("%s: private key mismatch", typ)
+		}
+		pk, err := jwk.Key()
+		if err != nil {
+			t.Fatalf("%s: public key decode failed: %v", typ, err)
+		}
+		if !pk.IsEqual(sk.Public()) {
+			t.Errorf("%s: public key mismatch", typ)
+		}
+		if jwk.Kid != sk.Address().String() {
+			t.Errorf("%s: key id mismatch", typ)
+		}
+	}
+}
+
+func TestSignDeterministic(t *testing.T) {
+	// secp256k1 reference vector used by bitcoin libraries (RFC 6979 nonce
+	// 8f8a276c19f4149656b280621e358cce24f5f52542772691ee69063b74f15d15)
+	d, _ := hex.DecodeString("0000000000000000000000000000000000000000000000000000000000000001")
+	sk := PrivateKey{Type: KeyTypeSecp256k1, Data: d}
+	digest := sha256.Sum256([]byte("Satoshi Nakamoto"))
+	want := "934b1ea10a4b3c1757e2b0c017d0b6143ce3c9a7e6a4a49860d7a6ab210ee3d8" +
+		"2442ce9d2b916064108014783e923ec36b49743e2ffa1c4496f01a512aafd9e5"
+	sig, err := sk.Sign(digest[:])
+	if err != nil {
+		t.Fatalf("sign failed: %v", err)
+	}
+	if have := hex.EncodeToString(sig.Data); have != want {
+		t.Errorf("signature mismatch\n have=%s\n want=%s", have, want)
+	}
+	sig2, _ := sk.Sign(digest[:])
+	if !sig.Equal(sig2) {
+		t.Error("signature not deterministic")
+	}
+	if err := sk.Public().Verify(digest[:], sig); err != nil {
+		t.Errorf("verify failed: %v", err)
+	}
+}
+
+func TestSignLowS(t *testing.T) {
+	for _, typ := range []KeyType{KeyTypeSecp256k1, KeyTypeP256} {
+		sk, err := GenerateKey(typ)
+		if err != nil {
+			t.Fatal(err)
+		}
+		half := new(big.Int).Rsh(typ.Curve().Params().N, 1)
+		for i := 0; i < 16; i++ {
+			digest := sha256.Sum256([]byte{byte(i)})
+			sig, err := sk.Sign(digest[:])
+			if err != nil {
+				t.Fatalf("%s: sign failed: %v", typ, err)
+			}
+			if s := new(big.Int).SetBytes(sig.Data[32:]); s.Cmp(half) > 0 {
+				t.Errorf("%s: signature not low-s normalized", typ)
+			}
+			if err := sk.Public().Verify(digest[:], sig); err != nil {
+				t.Errorf("%s: verify failed: %v", typ, err)
+			}
+		}
+	}
+}
@@ -85,6 +85,7 @@ const (
 	SMART_ROLLUP_STATE_HASH_PREFIX            = "srs1"
 	SMART_ROLLUP_COMMITMENT_HASH_PREFIX       = "src1"
 	SMART_ROLLUP_REVEAL_HASH_PREFIX           = "scrrh1"
+	DAL_COMMITMENT_PREFIX                     = "sh1"
 )
 
 var (
@@ -167,4 +168,5 @@ var (
 	SMART_ROLLUP_STATE_HASH_ID            = []byte{17, 165, 235, 240}       // "\017\165\235\240" srs1(54)
 	SMART_ROLLUP_COMMITMENT_HASH_ID       = []byte{17, 165, 134, 138}       // "\017\165\134\138" (* src1(54) *)
 	SMART_ROLLUP_REVEAL_HASH_ID           = []byte{230, 206, 128, 200, 196} // "\230\206\128\200\196" scrrh1(56)
+	DAL_COMMITMENT_ID                     = []byte{2, 116, 180}             // "\002\116\180" sh1(74) 48
 )
@@ -114,6 +114,8 @@ const (
 	OpTypeSmartRollupRecoverBond                 // 39 v016
 	OpTypeDalAttestation                         // 40 v016+?
 	OpTypeDalPublishSlotHeader                   // 41 v016+?
+	OpTypeAttestationWithDal                     // 42 v019
+	OpTypeDalEntrapmentEvidence                  // 43 v019
 )
 
 var (
@@ -160,6 +162,8 @@ var (
 		OpTypeSmartRollupRecoverBond:          "smart_rollup_recover_bond",
 		OpTypeDalAttestation:                  "dal_attestation",
 		OpTypeDalPublishSlotHeader:            "dal_publish_slot_header",
+		OpTypeAttestationWithDal:              "attestation_with_dal",
+		OpTypeDalEntrapmentEvidence:           "dal_entrapment_evidence",
 
 		// rename: endorsement -> attetstaion
 		// OpTypeDoubleEndorsementEvidence:       "double_attestation_evidence",
@@ -277,7 +281,9 @@ var (
 		OpTypeSmartRollupExecuteOutboxMessage: 206, // v016
 		OpTypeSmartRollupRecoverBond:          207, // v016
 		OpTypeDalPublishSlotHeader:            230, // v016+
-		OpTypeDalAttestation:                  22,  // v016+
+		OpTypeDalAttestation:                  22,  // v016+ (replaced in v019)
+		OpTypeAttestationWithDal:              23,  // v019
+		OpTypeDalEntrapmentEvidence:           24,  // v019
 	}
 )
 
@@ -373,6 +379,8 @@ var (
 		207: 26 + 41,                  // OpTypeSmartRollupRecoverBond // v016
 		230: 26 + 101,                 // OpTypeDalPublishSlotHeader // v016+
 		22:  1 + 21 + 1 + 4,           // OpTypeDalAttestation  // v016+
+		23:  43 + 1,                   // OpTypeAttestationWithDal // v019
+		24:  1 + 4 + 139 + 3 + 56,     // OpTypeDalEntrapmentEvidence // v019
 	}
 )
 
@@ -393,7 +401,7 @@ func (t OpType) MinSize() int {
 
 func (t OpType) ListId() int {
 	switch t {
-	case OpTypeEndorsement, OpTypeEndorsementWithSlot, OpTypePreendorsement:
+	case OpTypeEndorsement, OpTypeEndorsementWithSlot, OpTypePreendorsement, OpTypeAttestationWithDal:
 		return 0
 	case OpTypeProposals, OpTypeBallot:
 		return 1
@@ -404,7 +412,8 @@ func (t OpType) ListId() int {
 		OpTypeDoublePreendorsementEvidence,
 		OpTypeVdfRevelation,
 		OpTypeDrainDelegate,
-		OpTypeDalAttestation:
+		OpTypeDalAttestation,
+		OpTypeDalEntrapmentEvidence:
 		return 2
 	case OpTypeTransaction, // generic user operations
 		OpTypeOrigination,
@@ -469,6 +478,10 @@ func ParseOpTag(t byte) OpType {
 		return OpTypeEndorsement
 	case 22:
 		return OpTypeDalAttestation
+	case 23:
+		return OpTypeAttestationWithDal
+	case 24:
+		return OpTypeDalEntrapmentEvidence
 	case 107:
 		return OpTypeReveal
 	case 108:
@@ -225,3 +225,69 @@ var paramBlocks = []BlockMetadata{
 		VotingPeriodInfo: &VotingPeriodInfo{},
 	},
 }
+
+func TestParamsFor(t *testing.T) {
+	for _, v := range []struct {
+		id mavryk.ChainIdHash
+		p  *mavryk.Params
+	}{
+		{mavryk.Mainnet, mavryk.DefaultParams},
+		{mavryk.Basenet, mavryk.BasenetParams},
+		{mavryk.Atlasnet, mavryk.AtlasnetParams},
+		{mavryk.ZeroChainIdHash, mavryk.DefaultParams},
+	} {
+		if p := mavryk.ParamsFor(v.id); p != v.p {
+			t.Errorf("%s: unexpected params for network %s", v.id, p.Network)
+		}
+	}
+}
+
+func TestParamsDiff(t *testing.T) {
+	p1 := mavryk.DefaultParams.Clone()
+	p2 := p1.Clone()
+	if d := p1.Diff(p2); len(d) != 0 {
+		t.Fatalf("expected no changes, got %v", d)
+	}
+	p2.BlocksPerCycle = p1.BlocksPerCycle * 2
+	p2.MinimalBlockDelay = p1.MinimalBlockDelay / 2
+	p2.StartHeight = p1.StartHeight + 1
+	d := p1.Diff(p2)
+	if len(d) != 2 {
+		t.Fatalf("expected 2 changes, got %v", d)
+	}
+	if d[0].Name != "minimal_block_delay" || d[1].Name != "blocks_per_cycle" {
+		t.Errorf("unexpected change order %v", d)
+	}
+	if d[1].Old.(int64) != p1.BlocksPerCycle || d[1].New.(int64) != p2.BlocksPerCycle {
+		t.Errorf("unexpected values %v", d[1])
+	}
+}
+
+func TestCycleFunctions(t *testing.T) {
+	h := Deployments[mavryk.Atlasnet]
+	p := NewParams().WithChainId(mavryk.Atlasnet).WithDeployment(h.Last())
+	for _, level := range []int64{16385, 16386, 24576, 24577, 100000} {
+		if got, want := mavryk.LevelToCycle(h, level), p.CycleFromHeight(level); got != want {
+			t.Errorf("level %d: cycle got=%d want=%d", level, got, want)
+		}
+		if got, want := mavryk.CyclePositionAt(h, level), p.CyclePosition(level); got != want {
+			t.Errorf("level %d: position got=%d want=%d", level, got, want)
+		}
+		if got, want := mavryk.SnapshotIndexAt(h, level), p.SnapshotIndex(level); got != want {
+			t.Errorf("level %d: snapshot got=%d want=%d", level, got, want)
+		}
+	}
+	for _, cycle := range []int64{2, 3, 10} {
+		first, last := mavryk.CycleToFirstLevel(h, cycle), mavryk.CycleToLastLevel(h, cycle)
+		if first != p.CycleStartHeight(cycle) || last != p.CycleEndHeight(cycle) {
+			t.Errorf("cycle %d: range got=[%d,%d] want=[%d,%d]", cycle, first, last,
+				p.CycleStartHeight(cycle), p.CycleEndHeight(cycle))
+		}
+		if mavryk.LevelToCycle(h, first) != cycle || mavryk.LevelToCycle(h, last) != cycle {
+			t.Errorf("cycle %d: roundtrip mismatch", cycle)
+		}
+		if got := mavryk.SnapshotIndexAt(h, mavryk.SnapshotLevel(h, cycle, 3)); got != 3 {
+			t.Errorf("cycle %d: snapshot level index got=%d want=3", cycle, got)
+		}
+	}
+}
@@ -4,6 +4,9 @@
 package mavryk
 
 import (
+	"fmt"
+	"reflect"
+	"strings"
 	"time"
 )
 
@@ -91,6 +94,20 @@ type Params struct {
 	StartCycle           int64 `json:"start_cycle"`                      // correction cycle length
 }
 
+// ParamsFor returns the default params for a well-known chain id. Unknown
+// chain ids fall back to DefaultParams. Use this to select compliant params
+// for encoding operations on the network a client is connected to.
+func ParamsFor(id ChainIdHash) *Params {
+	switch {
+	case id.Equal(Basenet):
+		return BasenetParams
+	case id.Equal(Atlasnet):
+		return AtlasnetParams
+	default:
+		return DefaultParams
+	}
+}
+
 func NewParams() *Params {
 	return &Params{
 		Network:     "unknown",
@@ -284,3 +301,55 @@ func (p *Params) SnapshotIndex(height int64) int {
 	at := p.AtBlock(height)
 	return int((at.CyclePosition(height)+1)/at.BlocksPerSnapshot) - 1
 }
+
+// ParamsChange describes a single changed field between two params.
+type ParamsChange struct {
+	Name string      `json:"name"` // JSON field name, e.g. blocks_per_cycle
+	Old  interface{} `json:"old"`
+	New  interface{} `json:"new"`
+}
+
+func (c ParamsChange) String() string {
+	return fmt.Sprintf("%s: %v -> %v", c.Name, c.Old, c.New)
+}
+
+// diffSkipFields are bookkeeping fields which change with every protocol
+// deployment and are not part of a semantic params diff.
+var diffSkipFields = map[string]bool{
+	"start_height": true,
+	"end_height":   true,
+	"start_offset": true,
+	"start_cycle":  true,
+}
+
+// Diff returns all fields which differ between p and other in struct field
+// order. Old values are taken from p, new values from other. Deployment
+// bookkeeping fields (start/end height and cycle offsets) are ignored.
+func (p *Params) Diff(other *Params) []ParamsChange {
+	if p == nil || other == nil {
+		return nil
+	}
+	var (
+		changes []ParamsChange
+		v1      = reflect.ValueOf(p).Elem()
+		v2      = reflect.ValueOf(other).Elem()
+		typ     = v1.Type()
+	)
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" || diffSkipFields[name] {
+			continue
+		}
+		a, b := v1.Field(i).Interface(), v2.Field(i).Interface()
+		if reflect.DeepEqual(a, b) {
+			continue
+		}
+		changes = append(changes, ParamsChange{
+			Name: name,
+			Old:  a,
+			New:  b,
+		})
+	}
+	return changes
+}
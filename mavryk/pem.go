@@ -0,0 +1,253 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package mavryk
+
+import (
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+)
+
+const (
+	pemTypePublicKey    = "PUBLIC KEY"
+	pemTypePrivateKey   = "PRIVATE KEY"
+	pemTypeECPrivateKey = "EC PRIVATE KEY"
+)
+
+var (
+	oidEd25519     = asn1.ObjectIdentifier{1, 3, 101, 112}
+	oidEcPublicKey = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	oidP256        = asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7}
+	oidSecp256k1   = asn1.ObjectIdentifier{1, 3, 132, 0, 10}
+)
+
+// ASN.1 structures from RFC 5280 (SubjectPublicKeyInfo), RFC 5208 (PKCS #8)
+// and RFC 5915 (EC private keys).
+type pkixAlgorithm struct {
+	Algorithm  asn1.ObjectIdentifier
+	Parameters asn1.ObjectIdentifier `asn1:"optional"`
+}
+
+type pkixPublicKey struct {
+	Algorithm pkixAlgorithm
+	PublicKey asn1.BitString
+}
+
+type pkcs8PrivateKey struct {
+	Version    int
+	Algorithm  pkixAlgorithm
+	PrivateKey []byte
+}
+
+type ecPrivateKey struct {
+	Version    int
+	PrivateKey []byte
+	Curve      asn1.ObjectIdentifier `asn1:"optional,explicit,tag:0"`
+	PublicKey  asn1.BitString        `asn1:"optional,explicit,tag:1"`
+}
+
+func curveOid(t KeyType) asn1.ObjectIdentifier {
+	switch t {
+	case KeyTypeP256:
+		return oidP256
+	case KeyTypeSecp256k1:
+		return oidSecp256k1
+	default:
+		return nil
+	}
+}
+
+func keyTypeFromOid(algo pkixAlgorithm) (KeyType, error) {
+	switch {
+	case algo.Algorithm.Equal(oidEd25519):
+		return KeyTypeEd25519, nil
+	case algo.Algorithm.Equal(oidEcPublicKey) && algo.Parameters.Equal(oidP256):
+		return KeyTypeP256, nil
+	case algo.Algorithm.Equal(oidEcPublicKey) && algo.Parameters.Equal(oidSecp256k1):
+		return KeyTypeSecp256k1, nil
+	default:
+		return KeyTypeInvalid, ErrUnknownKeyType
+	}
+}
+
+// MarshalPEM encodes the public key as PEM encoded PKIX (SubjectPublicKeyInfo)
+// structure. Supports Ed25519, Secp256k1 and P256 keys.
+func (k Key) MarshalPEM() ([]byte, error) {
+	if !k.IsValid() {
+		return nil, fmt.Errorf("tezos: invalid key")
+	}
+	var spki pkixPublicKey
+	switch k.Type {
+	case KeyTypeEd25519:
+		spki.Algorithm.Algorithm = oidEd25519
+		spki.PublicKey = asn1.BitString{Bytes: k.Data, BitLength: 8 * len(k.Data)}
+	case KeyTypeSecp256k1, KeyTypeP256:
+		curve := k.Type.Curve()
+		pk, err := ecUnmarshalCompressed(curve, k.Data)
+		if err != nil {
+			return nil, err
+		}
+		buf := elliptic.Marshal(curve, pk.X, pk.Y)
+		spki.Algorithm.Algorithm = oidEcPublicKey
+		spki.Algorithm.Parameters = curveOid(k.Type)
+		spki.PublicKey = asn1.BitString{Bytes: buf, BitLength: 8 * len(buf)}
+	default:
+		return nil, ErrUnknownKeyType
+	}
+	der, err := asn1.Marshal(spki)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: pemTypePublicKey, Bytes: der}), nil
+}
+
+// ParsePEMKey decodes a PEM encoded PKIX public key.
+func ParsePEMKey(data []byte) (Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return InvalidKey, fmt.Errorf("tezos: missing PEM data")
+	}
+	if block.Type != pemTypePublicKey {
+		return InvalidKey, fmt.Errorf("tezos: unsupported PEM type %q", block.Type)
+	}
+	var spki pkixPublicKey
+	if rest, err := asn1.Unmarshal(block.Bytes, &spki); err != nil {
+		return InvalidKey, fmt.Errorf("tezos: invalid PEM public key: %w", err)
+	} else if len(rest) > 0 {
+		return InvalidKey, fmt.Errorf("tezos: trailing data after PEM public key")
+	}
+	typ, err := keyTypeFromOid(spki.Algorithm)
+	if err != nil {
+		return InvalidKey, err
+	}
+	buf := spki.PublicKey.RightAlign()
+	key := Key{Type: typ}
+	switch typ {
+	case KeyTypeEd25519:
+		key.Data = buf
+	default:
+		curve := typ.Curve()
+		x, y := elliptic.Unmarshal(curve, buf)
+		if x == nil {
+			return InvalidKey, fmt.Errorf("tezos: (%s) invalid public key", curve.Params().Name)
+		}
+		key.Data = elliptic.MarshalCompressed(curve, x, y)
+	}
+	if !key.IsValid() {
+		return InvalidKey, fmt.Errorf("tezos: invalid PEM public key length %d", len(key.Data))
+	}
+	return key, nil
+}
+
+// MarshalPEM encodes the private key as PEM encoded PKCS #8 structure.
+// Supports Ed25519, Secp256k1 and P256 keys.
+func (k PrivateKey) MarshalPEM() ([]byte, error) {
+	if !k.IsValid() {
+		return nil, fmt.Errorf("tezos: invalid private key")
+	}
+	var (
+		p8  pkcs8PrivateKey
+		err error
+	)
+	switch k.Type {
+	case KeyTypeEd25519:
+		p8.Algorithm.Algorithm = oidEd25519
+		p8.PrivateKey, err = asn1.Marshal(ed25519.PrivateKey(k.Data).Seed())
+	case KeyTypeSecp256k1, KeyTypeP256:
+		curve := k.Type.Curve()
+		ecKey, kerr := ecPrivateKeyFromBytes(k.Data, curve)
+		if kerr != nil {
+			return nil, kerr
+		}
+		buf := elliptic.Marshal(curve, ecKey.PublicKey.X, ecKey.PublicKey.Y)
+		p8.Algorithm.Algorithm = oidEcPublicKey
+		p8.Algorithm.Parameters = curveOid(k.Type)
+		p8.PrivateKey, err = asn1.Marshal(ecPrivateKey{
+			Version:    1,
+			PrivateKey: k.Data,
+			PublicKey:  asn1.BitString{Bytes: buf, BitLength: 8 * len(buf)},
+		})
+	default:
+		return nil, ErrUnknownKeyType
+	}
+	if err != nil {
+		return nil, err
+	}
+	der, err := asn1.Marshal(p8)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: pemTypePrivateKey, Bytes: der}), nil
+}
+
+// ParsePEMPrivateKey decodes a PEM encoded PKCS #8 or SEC 1 (EC PRIVATE KEY)
+// private key.
+func ParsePEMPrivateKey(data []byte) (PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return PrivateKey{}, fmt.Errorf("tezos: missing PEM data")
+	}
+	switch block.Type {
+	case pemTypePrivateKey:
+		var p8 pkcs8PrivateKey
+		if _, err := asn1.Unmarshal(block.Bytes, &p8); err != nil {
+			return PrivateKey{}, fmt.Errorf("tezos: invalid PEM private key: %w", err)
+		}
+		typ, err := keyTypeFromOid(p8.Algorithm)
+		if err != nil {
+			return PrivateKey{}, err
+		}
+		if typ == KeyTypeEd25519 {
+			var seed []byte
+			if _, err := asn1.Unmarshal(p8.PrivateKey, &seed); err != nil {
+				return PrivateKey{}, fmt.Errorf("tezos: invalid PEM private key: %w", err)
+			}
+			if len(seed) != ed25519.SeedSize {
+				return PrivateKey{}, fmt.Errorf("tezos: invalid PEM private key length %d", len(seed))
+			}
+			return PrivateKey{
+				Type: KeyTypeEd25519,
+				Data: []byte(ed25519.NewKeyFromSeed(seed)),
+			}, nil
+		}
+		return parseECPrivateKey(p8.PrivateKey, typ)
+	case pemTypeECPrivateKey:
+		return parseECPrivateKey(block.Bytes, KeyTypeInvalid)
+	default:
+		return PrivateKey{}, fmt.Errorf("tezos: unsupported PEM type %q", block.Type)
+	}
+}
+
+// parseECPrivateKey decodes a SEC 1 EC private key. When typ is invalid the
+// curve is detected from the embedded curve identifier.
+func parseECPrivateKey(der []byte, typ KeyType) (PrivateKey, error) {
+	var ec ecPrivateKey
+	if _, err := asn1.Unmarshal(der, &ec); err != nil {
+		return PrivateKey{}, fmt.Errorf("tezos: invalid PEM private key: %w", err)
+	}
+	if !typ.IsValid() {
+		var err error
+		typ, err = keyTypeFromOid(pkixAlgorithm{Algorithm: oidEcPublicKey, Parameters: ec.Curve})
+		if err != nil {
+			return PrivateKey{}, err
+		}
+	}
+	curve := typ.Curve()
+	byteLen := (curve.Params().BitSize + 7) / 8
+	if len(ec.PrivateKey) > byteLen {
+		return PrivateKey{}, fmt.Errorf("tezos: invalid PEM private key length %d", len(ec.PrivateKey))
+	}
+	if _, err := ecPrivateKeyFromBytes(ec.PrivateKey, curve); err != nil {
+		return PrivateKey{}, err
+	}
+	key := PrivateKey{
+		Type: typ,
+		Data: make([]byte, byteLen),
+	}
+	new(big.Int).SetBytes(ec.PrivateKey).FillBytes(key.Data)
+	return key, nil
+}
@@ -1,4 +1,4 @@
-// Copyright (c) 2020-2023 Blockwatch Data Inc.
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
 // Author: alex@blockwatch.cc
 
 package mavryk
@@ -105,3 +105,56 @@ func (h ProtocolHistory) Last() (d Deployment) {
 	}
 	return
 }
+
+// The functions below compute cycles and snapshots from a protocol history
+// alone. Unlike their Params counterparts they need no chain id or protocol
+// constants, so libraries which only know activation heights can use them.
+
+// LevelToCycle returns the cycle containing block level.
+func LevelToCycle(h ProtocolHistory, level int64) int64 {
+	d := h.AtBlock(level)
+	if d.BlocksPerCycle <= 0 {
+		return 0
+	}
+	return d.StartCycle + (level-(d.StartHeight-d.StartOffset))/d.BlocksPerCycle
+}
+
+// CycleToFirstLevel returns the first block level in cycle.
+func CycleToFirstLevel(h ProtocolHistory, cycle int64) int64 {
+	d := h.AtCycle(cycle)
+	return d.StartHeight - d.StartOffset + (cycle-d.StartCycle)*d.BlocksPerCycle
+}
+
+// CycleToLastLevel returns the last block level in cycle.
+func CycleToLastLevel(h ProtocolHistory, cycle int64) int64 {
+	return CycleToFirstLevel(h, cycle) + h.AtCycle(cycle).BlocksPerCycle - 1
+}
+
+// CyclePositionAt returns the position of block level inside its cycle.
+func CyclePositionAt(h ProtocolHistory, level int64) int64 {
+	d := h.AtBlock(level)
+	if d.BlocksPerCycle <= 0 {
+		return 0
+	}
+	pos := (level - (d.StartHeight - d.StartOffset)) % d.BlocksPerCycle
+	if pos < 0 {
+		pos += d.BlocksPerCycle
+	}
+	return pos
+}
+
+// SnapshotIndexAt returns the index of the last stake snapshot taken in the
+// cycle of block level at or before level, or -1 when no snapshot was taken
+// yet.
+func SnapshotIndexAt(h ProtocolHistory, level int64) int {
+	d := h.AtBlock(level)
+	if d.BlocksPerSnapshot <= 0 {
+		return -1
+	}
+	return int((CyclePositionAt(h, level)+1)/d.BlocksPerSnapshot) - 1
+}
+
+// SnapshotLevel returns the block level of snapshot index in cycle.
+func SnapshotLevel(h ProtocolHistory, cycle int64, index int) int64 {
+	return CycleToFirstLevel(h, cycle) + int64(index+1)*h.AtCycle(cycle).BlocksPerSnapshot - 1
+}
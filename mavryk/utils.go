@@ -1,9 +1,10 @@
-// Copyright (c) 2020-2022 Blockwatch Data Inc.
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
 // Author: alex@blockwatch.cc
 
 package mavryk
 
 import (
+	"encoding/base64"
 	"encoding/hex"
 	"fmt"
 	"io"
@@ -13,6 +14,96 @@ import (
 // HexBytes represents bytes as a JSON string of hexadecimal digits
 type HexBytes []byte
 
+// MarshalJSON encodes h as JSON hex string. The result is allocated once
+// which avoids intermediate copies for large blobs.
+func (h HexBytes) MarshalJSON() ([]byte, error) {
+	n := hex.EncodedLen(len(h))
+	buf := make([]byte, n+2)
+	hex.Encode(buf[1:], h)
+	buf[0], buf[n+1] = '"', '"'
+	return buf, nil
+}
+
+// UnmarshalJSON decodes a JSON hex string into newly allocated memory.
+func (h *HexBytes) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	if len(data) < 2 || data[0] != '"' || data[len(data)-1] != '"' {
+		return fmt.Errorf("tezos: invalid bytes string")
+	}
+	data = data[1 : len(data)-1]
+	dst := make([]byte, hex.DecodedLen(len(data)))
+	if _, err := hex.Decode(dst, data); err != nil {
+		return err
+	}
+	*h = dst
+	return nil
+}
+
+// Base64Bytes represents bytes as a JSON string in standard base64 encoding
+// which is 33% smaller than hex. Use it for large blobs like rollup kernels
+// and proofs in local storage and caches. Node RPCs require hex encoding, so
+// convert to HexBytes before sending JSON to a node.
+type Base64Bytes []byte
+
+// MarshalJSON encodes b as JSON base64 string. The result is allocated once
+// which avoids intermediate copies for large blobs.
+func (b Base64Bytes) MarshalJSON() ([]byte, error) {
+	n := base64.StdEncoding.EncodedLen(len(b))
+	buf := make([]byte, n+2)
+	base64.StdEncoding.Encode(buf[1:], b)
+	buf[0], buf[n+1] = '"', '"'
+	return buf, nil
+}
+
+// UnmarshalJSON decodes a JSON base64 string into newly allocated memory.
+func (b *Base64Bytes) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	if len(data) < 2 || data[0] != '"' || data[len(data)-1] != '"' {
+		return fmt.Errorf("tezos: invalid bytes string")
+	}
+	data = data[1 : len(data)-1]
+	dst := make([]byte, base64.StdEncoding.DecodedLen(len(data)))
+	n, err := base64.StdEncoding.Decode(dst, data)
+	if err != nil {
+		return err
+	}
+	*b = dst[:n]
+	return nil
+}
+
+// WriteTo streams the hex encoding of h to w without allocating the full
+// encoded string. It implements the io.WriterTo interface.
+func (h HexBytes) WriteTo(w io.Writer) (int64, error) {
+	n, err := hex.NewEncoder(w).Write(h)
+	return int64(hex.EncodedLen(n)), err
+}
+
+// Chunks splits h into consecutive slices of at most size bytes. Chunks
+// share memory with h.
+func (h HexBytes) Chunks(size int) []HexBytes {
+	if size <= 0 || len(h) == 0 {
+		return nil
+	}
+	list := make([]HexBytes, 0, (len(h)+size-1)/size)
+	for len(h) > size {
+		list = append(list, h[:size:size])
+		h = h[size:]
+	}
+	return append(list, h)
+}
+
+// Clone returns a copy of h which does not share memory with h.
+func (h HexBytes) Clone() HexBytes {
+	if h == nil {
+		return nil
+	}
+	return append(HexBytes{}, h...)
+}
+
 // UnmarshalText umarshals a hex string to bytes. It implements the
 // encoding.TextUnmarshaler interface, so that HexBytes can be used in Go
 // structs in combination with the standard JSON library.
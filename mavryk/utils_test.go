@@ -0,0 +1,112 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package mavryk
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestHexBytesJSON(t *testing.T) {
+	type blob struct {
+		Data HexBytes `json:"data"`
+	}
+	in := blob{Data: HexBytes{0x00, 0x01, 0xfe, 0xff}}
+
+	buf, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(buf), `{"data":"0001feff"}`; got != want {
+		t.Errorf("hex marshal: got %s want %s", got, want)
+	}
+	var out blob
+	if err := json.Unmarshal(buf, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Data, in.Data) {
+		t.Errorf("hex roundtrip: got %x want %x", out.Data, in.Data)
+	}
+
+	// decoding never reuses the destination's memory
+	shared := HexBytes{9, 9, 9, 9, 9}
+	out = blob{Data: shared[:0]}
+	if err := json.Unmarshal(buf, &out); err != nil {
+		t.Fatal(err)
+	}
+	if shared[0] != 9 {
+		t.Errorf("unmarshal overwrote shared memory %x", shared)
+	}
+}
+
+func TestBase64BytesJSON(t *testing.T) {
+	type blob struct {
+		Data Base64Bytes `json:"data"`
+	}
+	in := blob{Data: Base64Bytes{0x00, 0x01, 0xfe, 0xff}}
+	buf, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(buf), `{"data":"AAH+/w=="}`; got != want {
+		t.Errorf("base64 marshal: got %s want %s", got, want)
+	}
+	var out blob
+	if err := json.Unmarshal(buf, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Data, in.Data) {
+		t.Errorf("base64 roundtrip: got %x want %x", out.Data, in.Data)
+	}
+	if err := json.Unmarshal([]byte(`{"data":"0"}`), &out); err == nil {
+		t.Errorf("expected error for invalid input")
+	}
+}
+
+func TestHexBytesJSONInvalid(t *testing.T) {
+	var h HexBytes
+	for _, v := range []string{`"0"`, `"zz"`, `1`} {
+		if err := json.Unmarshal([]byte(v), &h); err == nil {
+			t.Errorf("%s: expected error", v)
+		}
+	}
+	h = HexBytes{1}
+	if err := json.Unmarshal([]byte(`null`), &h); err != nil || len(h) != 1 {
+		t.Errorf("null: unexpected change %x %v", h, err)
+	}
+}
+
+func TestHexBytesWriteTo(t *testing.T) {
+	h := HexBytes(bytes.Repeat([]byte{0xab}, 5000))
+	var b strings.Builder
+	n, err := h.WriteTo(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 10000 || b.String() != h.String() {
+		t.Errorf("unexpected output n=%d len=%d", n, b.Len())
+	}
+}
+
+func TestHexBytesChunks(t *testing.T) {
+	h := HexBytes{1, 2, 3, 4, 5}
+	c := h.Chunks(2)
+	if len(c) != 3 || len(c[2]) != 1 || c[2][0] != 5 {
+		t.Fatalf("unexpected chunks %x", c)
+	}
+	// chunks share memory but can't overwrite each other on append
+	c[0][0] = 9
+	if h[0] != 9 {
+		t.Errorf("chunk is not a slice of the original")
+	}
+	_ = append(c[0], 7)
+	if h[2] != 3 {
+		t.Errorf("append to chunk overwrote original")
+	}
+	if h.Chunks(0) != nil {
+		t.Errorf("expected nil for zero size")
+	}
+}
@@ -299,3 +299,31 @@ func (v *FeatureVote) UnmarshalBinary(data []byte) error {
 	*v = vv
 	return nil
 }
+
+// EncodePerBlockVotes packs liquidity baking and adaptive issuance votes into
+// the single per_block_votes byte used in block headers. The liquidity baking
+// vote occupies bits 0-1 and the adaptive issuance vote bits 2-3. Invalid
+// (unset) votes are encoded as pass which is the octez default.
+func EncodePerBlockVotes(lb, ai FeatureVote) byte {
+	if !lb.IsValid() {
+		lb = FeatureVotePass
+	}
+	if !ai.IsValid() {
+		ai = FeatureVotePass
+	}
+	return lb.Tag() | ai.Tag()<<2
+}
+
+// DecodePerBlockVotes unpacks a per_block_votes byte into liquidity baking
+// and adaptive issuance votes. Returns an error for out of range values.
+func DecodePerBlockVotes(b byte) (lb, ai FeatureVote, err error) {
+	if b > 0xf {
+		err = fmt.Errorf("tezos: invalid per block votes %d", b)
+		return
+	}
+	lb, ai = ParseFeatureVoteTag(b&3), ParseFeatureVoteTag(b>>2)
+	if !lb.IsValid() || !ai.IsValid() {
+		err = fmt.Errorf("tezos: invalid per block votes %d", b)
+	}
+	return
+}
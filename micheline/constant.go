@@ -1,9 +1,14 @@
-// Copyright (c) 2020-2021 Blockwatch Data Inc.
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
 // Author: alex@blockwatch.cc
 
 package micheline
 
-import "github.com/mavryk-network/mvgo/mavryk"
+import (
+	"fmt"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+	"golang.org/x/crypto/blake2b"
+)
 
 type ConstantDict map[string]Prim
 
@@ -50,3 +55,85 @@ func (p Prim) Constants() []mavryk.ExprHash {
 	})
 	return c
 }
+
+// NewConstant returns a reference to the global constant with hash h.
+func NewConstant(h mavryk.ExprHash) Prim {
+	return NewCode(H_CONSTANT, NewString(h.String()))
+}
+
+// ConstantHash returns the global constant address of p, i.e. the
+// expression hash under which p is stored when registered.
+func (p Prim) ConstantHash() mavryk.ExprHash {
+	buf, _ := p.MarshalBinary()
+	h, _ := blake2b.New(32, nil)
+	h.Write(buf)
+	return mavryk.NewExprHash(h.Sum(nil))
+}
+
+// ExtractConstants replaces subtrees of prims with global constant references
+// until the total binary size shrinks by at least excess bytes. In each step
+// the largest subtree which serializes to at most maxSize bytes is chosen.
+// Roots and script sections (parameter, storage, code, view) are never
+// replaced. Prims are rewritten in place.
+//
+// The returned constants must be registered in order since later constants
+// may reference earlier ones. Identical subtrees share a single constant.
+func ExtractConstants(excess, maxSize int, prims ...*Prim) ([]Prim, error) {
+	var (
+		consts = make([]Prim, 0)
+		seen   = make(map[string]struct{})
+		ref, _ = NewConstant(mavryk.ZeroExprHash).MarshalBinary()
+		refSz  = len(ref)
+	)
+	for excess > 0 {
+		var (
+			best   *Prim
+			bestSz int
+		)
+		for _, root := range prims {
+			_ = root.Visit(func(p *Prim) error {
+				if p == root {
+					return nil
+				}
+				switch p.Type {
+				case PrimInt, PrimString, PrimBytes, PrimSequence:
+				default:
+					switch p.OpCode {
+					case K_PARAMETER, K_STORAGE, K_CODE, K_VIEW:
+						return nil
+					case H_CONSTANT:
+						return PrimSkip
+					}
+				}
+				buf, _ := p.MarshalBinary()
+				sz := len(buf)
+				if sz > maxSize {
+					return nil
+				}
+				if sz > bestSz {
+					best, bestSz = p, sz
+				}
+				return PrimSkip
+			})
+		}
+		if best == nil || bestSz <= refSz {
+			return nil, fmt.Errorf("micheline: cannot extract constants, %d bytes left to reduce", excess)
+		}
+		val := best.Clone()
+		h := val.ConstantHash()
+		if _, ok := seen[h.String()]; !ok {
+			consts = append(consts, val)
+			seen[h.String()] = struct{}{}
+		}
+		*best = NewConstant(h)
+		excess -= bestSz - refSz
+	}
+	return consts, nil
+}
+
+// ExtractConstants replaces parts of the script code with global constant
+// references until the binary size of the code shrinks by at least excess
+// bytes. See ExtractConstants for details.
+func (s *Script) ExtractConstants(excess, maxSize int) ([]Prim, error) {
+	return ExtractConstants(excess, maxSize, &s.Code.Param, &s.Code.Storage, &s.Code.Code, &s.Code.View)
+}
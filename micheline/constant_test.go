@@ -0,0 +1,70 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package micheline
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestExtractConstants(t *testing.T) {
+	// build a script with large code
+	body := make([]Prim, 0)
+	for i := 0; i < 40; i++ {
+		body = append(body, NewSeq(
+			NewCode(I_PUSH, NewCode(T_STRING), NewString(strings.Repeat("x", 100+i))),
+			NewCode(I_DROP),
+		))
+	}
+	body = append(body, NewCode(I_CDR), NewCode(I_NIL, NewCode(T_OPERATION)), NewCode(I_PAIR))
+	s := NewScript()
+	s.Code.Param = NewCode(K_PARAMETER, NewCode(T_UNIT))
+	s.Code.Storage = NewCode(K_STORAGE, NewCode(T_UNIT))
+	s.Code.Code = NewCode(K_CODE, NewSeq(body...))
+	s.Storage = Unit
+
+	orig, _ := s.Code.MarshalBinary()
+	want := s.Code.Code.Clone()
+
+	const excess, maxSize = 2000, 1024
+	consts, err := s.ExtractConstants(excess, maxSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(consts) == 0 {
+		t.Fatal("expected constants")
+	}
+	dict := make(ConstantDict)
+	for _, v := range consts {
+		buf, _ := v.MarshalBinary()
+		if len(buf) > maxSize {
+			t.Errorf("constant size %d exceeds max %d", len(buf), maxSize)
+		}
+		dict.Add(v.ConstantHash(), v)
+	}
+	res, _ := s.Code.MarshalBinary()
+	if len(orig)-len(res) < excess {
+		t.Errorf("expected size reduction >= %d, got %d", excess, len(orig)-len(res))
+	}
+	if s.Code.Code.OpCode != K_CODE || s.Code.Param.OpCode != K_PARAMETER {
+		t.Error("script sections must not be replaced")
+	}
+	if n := len(s.Constants()); n < len(consts) {
+		t.Errorf("expected at least %d constant references, got %d", len(consts), n)
+	}
+
+	// expanding constants must restore the original code
+	s.ExpandConstants(dict)
+	b1, _ := want.MarshalBinary()
+	b2, _ := s.Code.Code.MarshalBinary()
+	if !bytes.Equal(b1, b2) {
+		t.Error("expanded code differs from original")
+	}
+
+	// impossible reductions fail
+	if _, err := s.ExtractConstants(len(orig)*2, maxSize); err == nil {
+		t.Error("expected error")
+	}
+}
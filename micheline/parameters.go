@@ -25,6 +25,12 @@ func (p Parameters) MarshalJSON() ([]byte, error) {
 	return json.Marshal(alias(p))
 }
 
+// NormalizePairs returns parameters with all Pair values re-encoded using mode.
+func (p Parameters) NormalizePairs(mode PairMode) Parameters {
+	p.Value = p.Value.NormalizePairs(mode)
+	return p
+}
+
 func (p Parameters) MapEntrypoint(typ Type) (Entrypoint, Prim, error) {
 	var ep Entrypoint
 	var ok bool
@@ -233,9 +233,17 @@ func (p Prim) IsEqualWithAnno(p2 Prim) bool {
 	return IsEqualPrim(p, p2, true)
 }
 
+// Compare orders comparable values like the Michelson COMPARE instruction.
+// Pairs compare lexicographically, None < Some, Left < Right and False < True.
+// Sequences compare like comb pairs. Values of different or non-comparable
+// types compare equal. Addresses, keys and timestamps only order correctly
+// in their optimized (binary) form.
 func (p Prim) Compare(p2 Prim) int {
-	if p.Type != p2.Type {
-		return 0
+	switch p.Type {
+	case PrimString, PrimBytes, PrimInt:
+		if p.Type != p2.Type {
+			return 0
+		}
 	}
 	switch p.Type {
 	case PrimString:
@@ -245,7 +253,58 @@ func (p Prim) Compare(p2 Prim) int {
 	case PrimInt:
 		return p.Int.Cmp(p2.Int)
 	default:
-		return 0
+		if p.OpCode != p2.OpCode {
+			r1, ok1 := compareRank(p.OpCode)
+			r2, ok2 := compareRank(p2.OpCode)
+			if !ok1 || !ok2 || r1/2 != r2/2 {
+				return 0
+			}
+			return r1 - r2
+		}
+		switch {
+		case p.Type == PrimSequence && p2.Type == PrimSequence:
+			// comb pair
+		case p.Type == PrimSequence || p2.Type == PrimSequence:
+			return 0
+		case p.OpCode == D_PAIR, p.OpCode == D_SOME, p.OpCode == D_LEFT, p.OpCode == D_RIGHT:
+		default:
+			// no arguments to order or not a comparable value
+			return 0
+		}
+		for i := 0; i < len(p.Args) && i < len(p2.Args); i++ {
+			if c := p.Args[i].Compare(p2.Args[i]); c != 0 {
+				return c
+			}
+		}
+		switch {
+		case len(p.Args) < len(p2.Args):
+			return -1
+		case len(p.Args) > len(p2.Args):
+			return 1
+		default:
+			return 0
+		}
+	}
+}
+
+// compareRank returns the sort rank of data constructors. Ranks of
+// constructors of the same type differ only in the lowest bit.
+func compareRank(c OpCode) (int, bool) {
+	switch c {
+	case D_FALSE:
+		return 0, true
+	case D_TRUE:
+		return 1, true
+	case D_NONE:
+		return 2, true
+	case D_SOME:
+		return 3, true
+	case D_LEFT:
+		return 4, true
+	case D_RIGHT:
+		return 5, true
+	default:
+		return 0, false
 	}
 }
 
@@ -680,6 +739,67 @@ func (p Prim) FoldPair() Prim {
 	}
 }
 
+// PairMode defines how right-hand pair trees in values are encoded.
+type PairMode byte
+
+const (
+	PairModeAsIs   PairMode = iota // keep pairs unchanged
+	PairModeNested                 // nested binary pairs, i.e. Pair a (Pair b c)
+	PairModeComb                   // right-comb pairs, i.e. Pair a b c
+)
+
+func (m PairMode) String() string {
+	switch m {
+	case PairModeAsIs:
+		return "as-is"
+	case PairModeNested:
+		return "nested"
+	case PairModeComb:
+		return "comb"
+	default:
+		return "invalid"
+	}
+}
+
+// NormalizePairs re-encodes all Pair values in a value tree using mode.
+// Nested and comb pairs are semantically equal, but produce different
+// binary encodings. Pairs with annotations are never merged and naked
+// comb sequences are left unchanged since they cannot be distinguished
+// from lists without a type.
+func (p Prim) NormalizePairs(mode PairMode) Prim {
+	if mode == PairModeAsIs || len(p.Args) == 0 {
+		return p
+	}
+	args := make([]Prim, len(p.Args))
+	for i, v := range p.Args {
+		args[i] = v.NormalizePairs(mode)
+	}
+	p.Args = args
+	if p.OpCode != D_PAIR || p.IsSequence() {
+		return p
+	}
+	switch mode {
+	case PairModeNested:
+		if len(p.Args) > 2 {
+			right := Prim{Type: PrimVariadicAnno, OpCode: D_PAIR, Args: p.Args[1:]}
+			p.Args = []Prim{p.Args[0], right.NormalizePairs(mode)}
+		}
+		p.Type = PrimBinary
+		if len(p.Anno) > 0 {
+			p.Type = PrimBinaryAnno
+		}
+	case PairModeComb:
+		if n := len(p.Args); n >= 2 {
+			last := p.Args[n-1]
+			if last.OpCode == D_PAIR && !last.IsSequence() && len(last.Anno) == 0 {
+				p.Args = append(p.Args[:n-1:n-1], last.Args...)
+				p.Type = PrimVariadicAnno
+			}
+		}
+	}
+	return p
+}
+
 // Checks if a primitve contains a packed value such as a byte sequence
 // generated with PACK (starting with 0x05), an address or ascii/utf string.
 func (p Prim) IsPacked() bool {
@@ -0,0 +1,39 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package micheline
+
+import (
+	"testing"
+)
+
+func TestPrimCompare(t *testing.T) {
+	tests := []struct {
+		Name string
+		A    Prim
+		B    Prim
+		Want int
+	}{
+		{"int", NewInt64(1), NewInt64(2), -1},
+		{"string", NewString("b"), NewString("a"), 1},
+		{"bytes", NewBytes([]byte{1}), NewBytes([]byte{1}), 0},
+		{"bool", NewCode(D_FALSE), NewCode(D_TRUE), -1},
+		{"option_none", NewCode(D_NONE), NewCode(D_SOME, NewInt64(0)), -1},
+		{"option_some", NewCode(D_SOME, NewInt64(2)), NewCode(D_SOME, NewInt64(1)), 1},
+		{"or", NewCode(D_RIGHT, NewInt64(0)), NewCode(D_LEFT, NewInt64(1)), 1},
+		{"or_left", NewCode(D_LEFT, NewInt64(0)), NewCode(D_LEFT, NewInt64(1)), -1},
+		{"pair_first", NewPair(NewInt64(1), NewString("b")), NewPair(NewInt64(2), NewString("a")), -1},
+		{"pair_second", NewPair(NewInt64(1), NewString("b")), NewPair(NewInt64(1), NewString("a")), 1},
+		{"pair_equal", NewPair(NewInt64(1), NewString("a")), NewPair(NewInt64(1), NewString("a")), 0},
+		{"comb_pair", NewCombPair(NewInt64(1), NewInt64(1), NewInt64(1)), NewCombPair(NewInt64(1), NewInt64(1), NewInt64(2)), -1},
+		{"mixed_types", NewInt64(1), NewString("a"), 0},
+		{"mixed_constructors", NewCode(D_TRUE), NewCode(D_NONE), 0},
+		{"elt", NewCode(D_ELT, NewInt64(1), NewInt64(1)), NewCode(D_ELT, NewInt64(2), NewInt64(1)), 0},
+		{"unit", NewCode(D_UNIT), NewCode(D_UNIT), 0},
+	}
+	for _, test := range tests {
+		if have := test.A.Compare(test.B); have != test.Want {
+			t.Errorf("%s: compare mismatch, want=%d have=%d", test.Name, test.Want, have)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package micheline
+
+import (
+	"fmt"
+	"math/big"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+)
+
+// Generator produces random well-typed values for Michelson types. It is
+// meant for property-based tests, e.g. round-trips through PACK/UNPACK,
+// binding marshalers or comparable ordering. Keys, signatures and addresses
+// are random bytes of the correct length and do not verify.
+//
+// Sets and map keys are sorted and unique in Michelson order. By default
+// values use readable forms (strings for addresses, keys, signatures,
+// chain ids and RFC3339 timestamps), set Optimized to produce bytes and
+// integers instead.
+//
+// Generator is not safe for concurrent use.
+type Generator struct {
+	MaxDepth   int  // max nesting of lists, sets, maps and lambdas
+	MaxItems   int  // max number of list, set and map elements
+	MaxBytes   int  // max length of strings and bytes
+	MaxIntBits int  // max bit length of int and nat values
+	Optimized  bool // produce optimized values
+
+	rnd *rand.Rand
+}
+
+// NewGenerator returns a value generator with default size bounds. Equal
+// seeds produce equal sequences of values.
+func NewGenerator(seed int64) *Generator {
+	return &Generator{
+		MaxDepth:   3,
+		MaxItems:   5,
+		MaxBytes:   32,
+		MaxIntBits: 128,
+		rnd:        rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Value returns a random value of type typ.
+func (g *Generator) Value(typ Type) (Prim, error) {
+	v, err := g.value(typ.Prim, 0)
+	if err != nil {
+		return InvalidPrim, err
+	}
+	if !g.Optimized {
+		v = readable(typ.Prim, v)
+	}
+	return v, nil
+}
+
+// Values returns n random values of type typ.
+func (g *Generator) Values(typ Type, n int) ([]Prim, error) {
+	vals := make([]Prim, n)
+	for i := range vals {
+		v, err := g.Value(typ)
+		if err != nil {
+			return nil, err
+		}
+		vals[i] = v
+	}
+	return vals, nil
+}
+
+// value generates optimized values only, so that set elements and map keys
+// sort in protocol order. Readable forms are produced by a separate pass.
+func (g *Generator) value(typ Prim, depth int) (Prim, error) {
+	switch typ.OpCode {
+	case T_UNIT:
+		return NewCode(D_UNIT), nil
+	case T_BOOL:
+		if g.rnd.Intn(2) == 0 {
+			return NewCode(D_FALSE), nil
+		}
+		return NewCode(D_TRUE), nil
+	case T_INT:
+		n := g.bigint()
+		if g.rnd.Intn(2) == 0 {
+			n.Neg(n)
+		}
+		return NewBig(n), nil
+	case T_NAT:
+		return NewNat(g.bigint()), nil
+	case T_MUMAV:
+		return NewInt64(g.rnd.Int63()), nil
+	case T_TIMESTAMP:
+		// stay within 4-digit years so RFC3339 strings are valid
+		return NewInt64(g.rnd.Int63n(253402300800)), nil
+	case T_STRING:
+		buf := make([]byte, g.rnd.Intn(g.MaxBytes+1))
+		for i := range buf {
+			buf[i] = byte(32 + g.rnd.Intn(95)) // printable ascii
+		}
+		return NewString(string(buf)), nil
+	case T_BYTES:
+		return NewBytes(g.bytes(g.rnd.Intn(g.MaxBytes + 1))), nil
+	case T_CHAIN_ID:
+		return NewBytes(g.bytes(4)), nil
+	case T_KEY_HASH:
+		typ := mavryk.AddressType(1 + g.rnd.Intn(3)) // tz1..tz3
+		return NewKeyHash(mavryk.NewAddress(typ, g.bytes(20))), nil
+	case T_ADDRESS, T_CONTRACT:
+		typ := mavryk.AddressType(1 + g.rnd.Intn(4)) // tz1..tz3, KT1
+		return NewAddress(mavryk.NewAddress(typ, g.bytes(20))), nil
+	case T_KEY:
+		return NewBytes(mavryk.NewKey(mavryk.KeyTypeEd25519, g.bytes(32)).Bytes()), nil
+	case T_SIGNATURE:
+		return NewBytes(g.bytes(64)), nil
+	case T_OPTION:
+		if g.rnd.Intn(3) == 0 {
+			return NewOption(), nil
+		}
+		v, err := g.value(typ.Args[0], depth)
+		if err != nil {
+			return InvalidPrim, err
+		}
+		return NewOption(v), nil
+	case T_OR:
+		if g.rnd.Intn(2) == 0 {
+			v, err := g.value(typ.Args[0], depth)
+			if err != nil {
+				return InvalidPrim, err
+			}
+			return NewCode(D_LEFT, v), nil
+		}
+		v, err := g.value(typ.Args[1], depth)
+		if err != nil {
+			return InvalidPrim, err
+		}
+		return NewCode(D_RIGHT, v), nil
+	case T_PAIR:
+		l, r := pairTypes(typ)
+		lv, err := g.value(l, depth)
+		if err != nil {
+			return InvalidPrim, err
+		}
+		rv, err := g.value(r, depth)
+		if err != nil {
+			return InvalidPrim, err
+		}
+		return NewPair(lv, rv), nil
+	case T_LIST:
+		n := g.items(depth)
+		list := make([]Prim, n)
+		for i := range list {
+			v, err := g.value(typ.Args[0], depth+1)
+			if err != nil {
+				return InvalidPrim, err
+			}
+			list[i] = v
+		}
+		return NewSeq(list...), nil
+	case T_SET:
+		n := g.items(depth)
+		set := make([]Prim, n)
+		for i := range set {
+			v, err := g.value(typ.Args[0], depth+1)
+			if err != nil {
+				return InvalidPrim, err
+			}
+			set[i] = v
+		}
+		return NewSeq(sortUnique(set, func(p Prim) Prim { return p })...), nil
+	case T_MAP, T_BIG_MAP:
+		n := g.items(depth)
+		elts := make([]Prim, n)
+		for i := range elts {
+			k, err := g.value(typ.Args[0], depth+1)
+			if err != nil {
+				return InvalidPrim, err
+			}
+			v, err := g.value(typ.Args[1], depth+1)
+			if err != nil {
+				return InvalidPrim, err
+			}
+			elts[i] = NewMapElem(k, v)
+		}
+		return NewSeq(sortUnique(elts, func(p Prim) Prim { return p.Args[0] })...), nil
+	case T_LAMBDA:
+		// a constant function { DROP ; PUSH ret val }
+		ret := typ.Args[1]
+		if !isPushable(ret) {
+			return InvalidPrim, fmt.Errorf("micheline: cannot generate lambda returning %s", ret.OpCode)
+		}
+		v, err := g.value(ret, depth+1)
+		if err != nil {
+			return InvalidPrim, err
+		}
+		return NewSeq(NewCode(I_DROP), NewCode(I_PUSH, stripAnnos(ret), v)), nil
+	default:
+		return InvalidPrim, fmt.Errorf("micheline: cannot generate value for type %s", typ.OpCode)
+	}
+}
+
+func (g *Generator) items(depth int) int {
+	if depth >= g.MaxDepth {
+		return 0
+	}
+	return g.rnd.Intn(g.MaxItems + 1)
+}
+
+func (g *Generator) bytes(n int) []byte {
+	buf := make([]byte, n)
+	g.rnd.Read(buf)
+	return buf
+}
+
+func (g *Generator) bigint() *big.Int {
+	buf := g.bytes((g.rnd.Intn(g.MaxIntBits) + 8) / 8)
+	return new(big.Int).SetBytes(buf)
+}
+
+// pairTypes splits a (comb) pair type into its left and right types.
+func pairTypes(typ Prim) (Prim, Prim) {
+	if len(typ.Args) > 2 {
+		return typ.Args[0], NewCode(T_PAIR, typ.Args[1:]...)
+	}
+	return typ.Args[0], typ.Args[1]
+}
+
+// sortUnique sorts prims by key in Michelson order and removes duplicate keys.
+func sortUnique(list []Prim, key func(Prim) Prim) []Prim {
+	sort.SliceStable(list, func(i, j int) bool {
+		return key(list[i]).Compare(key(list[j])) < 0
+	})
+	res := list[:0]
+	for _, v := range list {
+		if len(res) > 0 && key(res[len(res)-1]).Compare(key(v)) == 0 {
+			continue
+		}
+		res = append(res, v)
+	}
+	return res
+}
+
+func isPushable(typ Prim) bool {
+	switch typ.OpCode {
+	case T_BIG_MAP, T_OPERATION, T_CONTRACT, T_TICKET, T_SAPLING_STATE:
+		return false
+	case T_LAMBDA:
+		return true
+	}
+	for _, v := range typ.Args {
+		if !isPushable(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func stripAnnos(typ Prim) Prim {
+	args := make([]Prim, len(typ.Args))
+	for i, v := range typ.Args {
+		args[i] = stripAnnos(v)
+	}
+	return NewCode(typ.OpCode, args...)
+}
+
+// readable converts an optimized value of type typ into its readable form.
+func readable(typ, v Prim) Prim {
+	switch typ.OpCode {
+	case T_TIMESTAMP:
+		return NewString(time.Unix(v.Int.Int64(), 0).UTC().Format(time.RFC3339))
+	case T_CHAIN_ID:
+		return NewString(mavryk.NewChainIdHash(v.Bytes).String())
+	case T_KEY_HASH, T_ADDRESS, T_CONTRACT:
+		var a mavryk.Address
+		_ = a.Decode(v.Bytes)
+		return NewString(a.String())
+	case T_KEY:
+		var k mavryk.Key
+		_ = k.UnmarshalBinary(v.Bytes)
+		return NewString(k.String())
+	case T_SIGNATURE:
+		var s mavryk.Signature
+		_ = s.UnmarshalBinary(v.Bytes)
+		return NewString(s.String())
+	case T_OPTION:
+		if v.OpCode == D_SOME {
+			return NewOption(readable(typ.Args[0], v.Args[0]))
+		}
+	case T_OR:
+		if v.OpCode == D_LEFT {
+			return NewCode(D_LEFT, readable(typ.Args[0], v.Args[0]))
+		}
+		return NewCode(D_RIGHT, readable(typ.Args[1], v.Args[0]))
+	case T_PAIR:
+		l, r := pairTypes(typ)
+		return NewPair(readable(l, v.Args[0]), readable(r, v.Args[1]))
+	case T_LIST, T_SET:
+		list := make([]Prim, len(v.Args))
+		for i, e := range v.Args {
+			list[i] = readable(typ.Args[0], e)
+		}
+		return NewSeq(list...)
+	case T_MAP, T_BIG_MAP:
+		elts := make([]Prim, len(v.Args))
+		for i, e := range v.Args {
+			elts[i] = NewMapElem(readable(typ.Args[0], e.Args[0]), readable(typ.Args[1], e.Args[1]))
+		}
+		return NewSeq(elts...)
+	case T_LAMBDA:
+		push := v.Args[1]
+		return NewSeq(v.Args[0], NewCode(I_PUSH, push.Args[0], readable(typ.Args[1], push.Args[1])))
+	}
+	return v
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package micheline
+
+import (
+	"testing"
+)
+
+var generatorTypes = []struct {
+	Name       string
+	Type       Prim
+	Comparable bool
+}{
+	{"unit", NewCode(T_UNIT), true},
+	{"bool", NewCode(T_BOOL), true},
+	{"int", NewCode(T_INT), true},
+	{"nat", NewCode(T_NAT), true},
+	{"mumav", NewCode(T_MUMAV), true},
+	{"string", NewCode(T_STRING), true},
+	{"bytes", NewCode(T_BYTES), true},
+	{"timestamp", NewCode(T_TIMESTAMP), true},
+	{"address", NewCode(T_ADDRESS), true},
+	{"key_hash", NewCode(T_KEY_HASH), true},
+	{"key", NewCode(T_KEY), true},
+	{"signature", NewCode(T_SIGNATURE), true},
+	{"chain_id", NewCode(T_CHAIN_ID), true},
+	{"option", NewCode(T_OPTION, NewCode(T_NAT)), true},
+	{"or", NewCode(T_OR, NewCode(T_STRING), NewCode(T_BOOL)), true},
+	{"pair", NewPairType(NewCode(T_ADDRESS), NewCode(T_INT)), true},
+	{"comb", NewCode(T_PAIR, NewCode(T_NAT), NewCode(T_STRING), NewCode(T_BYTES)), true},
+	{"list", NewCode(T_LIST, NewCode(T_INT)), false},
+	{"set", NewSetType(NewPairType(NewCode(T_NAT), NewCode(T_KEY_HASH))), false},
+	{"map", NewMapType(NewCode(T_STRING), NewCode(T_LIST, NewCode(T_MUMAV))), false},
+	{"big_map", NewCode(T_BIG_MAP, NewCode(T_ADDRESS), NewCode(T_NAT)), false},
+	{"lambda", NewCode(T_LAMBDA, NewCode(T_UNIT), NewCode(T_OPTION, NewCode(T_TIMESTAMP))), false},
+	{"nested", NewMapType(NewCode(T_NAT), NewCode(T_MAP, NewCode(T_STRING), NewCode(T_SET, NewCode(T_INT)))), false},
+}
+
+func TestGeneratorPack(t *testing.T) {
+	for _, optimized := range []bool{false, true} {
+		g := NewGenerator(1)
+		g.Optimized = optimized
+		for _, test := range generatorTypes {
+			typ := NewType(test.Type)
+			vals, err := g.Values(typ, 50)
+			if err != nil {
+				t.Fatalf("%s: %v", test.Name, err)
+			}
+			for _, v := range vals {
+				if !v.Implements(typ) {
+					t.Fatalf("%s: value %s does not implement type", test.Name, v.Dump())
+				}
+				buf := v.Pack()
+				var p Prim
+				if err := p.UnmarshalBinary(buf[1:]); err != nil {
+					t.Fatalf("%s: unpack %x: %v", test.Name, buf, err)
+				}
+				if !p.IsEqual(v) {
+					t.Errorf("%s: unpack mismatch\n  want=%s\n  have=%s", test.Name, v.Dump(), p.Dump())
+				}
+			}
+		}
+	}
+}
+
+func TestGeneratorCompare(t *testing.T) {
+	sign := func(n int) int {
+		switch {
+		case n < 0:
+			return -1
+		case n > 0:
+			return 1
+		default:
+			return 0
+		}
+	}
+	g := NewGenerator(2)
+	g.Optimized = true
+	for _, test := range generatorTypes {
+		if !test.Comparable {
+			continue
+		}
+		typ := NewType(test.Type)
+		vals, err := g.Values(typ, 30)
+		if err != nil {
+			t.Fatalf("%s: %v", test.Name, err)
+		}
+		for _, a := range vals {
+			if c := a.Compare(a.Clone()); c != 0 {
+				t.Fatalf("%s: %s not equal to itself", test.Name, a.Dump())
+			}
+			for _, b := range vals {
+				ab, ba := sign(a.Compare(b)), sign(b.Compare(a))
+				if ab != -ba {
+					t.Fatalf("%s: compare not antisymmetric for %s and %s", test.Name, a.Dump(), b.Dump())
+				}
+				if ab == 0 && !a.IsEqual(b) {
+					t.Fatalf("%s: distinct values %s and %s compare equal", test.Name, a.Dump(), b.Dump())
+				}
+				for _, c := range vals {
+					if ab <= 0 && sign(b.Compare(c)) <= 0 && sign(a.Compare(c)) > 0 {
+						t.Fatalf("%s: compare not transitive for %s, %s, %s", test.Name, a.Dump(), b.Dump(), c.Dump())
+					}
+				}
+			}
+		}
+
+		// sets must be strictly ordered
+		set, err := g.Value(NewType(NewSetType(test.Type)))
+		if err != nil {
+			t.Fatalf("%s: %v", test.Name, err)
+		}
+		for i := 1; i < len(set.Args); i++ {
+			if set.Args[i-1].Compare(set.Args[i]) >= 0 {
+				t.Errorf("%s: set not ordered at %d: %s", test.Name, i, set.Dump())
+			}
+		}
+	}
+}
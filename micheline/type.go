@@ -404,10 +404,11 @@ func (p Prim) ImplementsType(t Typedef) bool {
 			// walk left tree by clipping off right handled types
 			if t.Type == TypeUnion {
 				// fmt.Println("> UNION left")
+				if len(t.Args) > 1 {
+					t.Args = t.Args[:len(t.Args)-1]
+				}
 				if len(t.Args) == 1 {
 					t = t.Args[0]
-				} else {
-					t.Args = t.Args[:len(t.Args)-1]
 				}
 				if p.Args[0].ImplementsType(t) {
 					// fmt.Println("> OK union left")
@@ -428,7 +429,7 @@ func (p Prim) ImplementsType(t Typedef) bool {
 			switch p.Type {
 			case PrimSequence:
 				switch oc {
-				case T_MAP:
+				case T_MAP, T_BIG_MAP:
 					for _, v := range p.Args {
 						if !v.ImplementsType(t) {
 							// fmt.Println("> BAD map elem")
@@ -438,7 +439,7 @@ func (p Prim) ImplementsType(t Typedef) bool {
 					return PrimSkip
 				case T_SET:
 					for _, v := range p.Args {
-						if !v.ImplementsType(t) {
+						if !v.ImplementsType(t.Args[0]) {
 							// fmt.Println("> BAD set elem")
 							return ErrTypeMismatch
 						}
@@ -639,3 +639,29 @@ func TestBigmapTypeCompare(t *testing.T) {
 		})
 	}
 }
+
+func TestImplementsType(t *testing.T) {
+	nat := NewCode(T_NAT)
+	str := NewCode(T_STRING)
+	tests := []struct {
+		Name string
+		Type Prim
+		Val  Prim
+		Want bool
+	}{
+		{"set", NewSetType(nat), NewSeq(NewInt64(1), NewInt64(2)), true},
+		{"set_bad_elem", NewSetType(nat), NewSeq(NewString("a")), false},
+		{"big_map", NewCode(T_BIG_MAP, nat, str), NewSeq(NewMapElem(NewInt64(1), NewString("a"))), true},
+		{"big_map_ptr", NewCode(T_BIG_MAP, nat, str), NewInt64(5), true},
+		{"or_left", NewCode(T_OR, nat, str), NewCode(D_LEFT, NewInt64(1)), true},
+		{"or_right", NewCode(T_OR, nat, str), NewCode(D_RIGHT, NewString("a")), true},
+		{"or_left_bad", NewCode(T_OR, nat, str), NewCode(D_LEFT, NewString("a")), false},
+		{"nested_or_left", NewCode(T_OR, NewCode(T_OR, nat, str), NewCode(T_BOOL)), NewCode(D_LEFT, NewCode(D_RIGHT, NewString("a"))), true},
+	}
+	for _, test := range tests {
+		td := NewType(test.Type).Typedef("")
+		if have := test.Val.ImplementsType(td); have != test.Want {
+			t.Errorf("%s: implements mismatch, want=%t have=%t", test.Name, test.Want, have)
+		}
+	}
+}
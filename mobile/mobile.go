@@ -0,0 +1,226 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+// Package mobile is a small facade over mvgo for use with gomobile bind.
+// It only exposes types gomobile can translate (strings, int64, bool, byte
+// slices, errors and pointers to structs) so iOS and Android apps can create
+// keys, build, sign and inject transfers and query balances.
+//
+//	gomobile bind -target=android github.com/mavryk-network/mvgo/mobile
+//
+// All amounts are in mumav. Keys, addresses and hashes are base58 strings.
+package mobile
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mavryk-network/mvgo/codec"
+	"github.com/mavryk-network/mvgo/mavryk"
+	"github.com/mavryk-network/mvgo/rpc"
+)
+
+// Key holds a private key together with its public key and address.
+type Key struct {
+	PrivateKey string
+	PublicKey  string
+	Address    string
+}
+
+func newKey(sk mavryk.PrivateKey) *Key {
+	return &Key{
+		PrivateKey: sk.String(),
+		PublicKey:  sk.Public().String(),
+		Address:    sk.Address().String(),
+	}
+}
+
+// GenerateKey creates a new random private key. Key type is one of ed25519,
+// secp256k1 or p256.
+func GenerateKey(keyType string) (*Key, error) {
+	var typ mavryk.KeyType
+	switch strings.ToLower(keyType) {
+	case "", "ed25519":
+		typ = mavryk.KeyTypeEd25519
+	case "secp256k1":
+		typ = mavryk.KeyTypeSecp256k1
+	case "p256":
+		typ = mavryk.KeyTypeP256
+	default:
+		return nil, fmt.Errorf("mobile: unsupported key type %q", keyType)
+	}
+	sk, err := mavryk.GenerateKey(typ)
+	if err != nil {
+		return nil, err
+	}
+	return newKey(sk), nil
+}
+
+// ImportKey parses an unencrypted base58 private key.
+func ImportKey(privateKey string) (*Key, error) {
+	sk, err := mavryk.ParsePrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return newKey(sk), nil
+}
+
+// IsValidAddress returns true when addr is a valid address.
+func IsValidAddress(addr string) bool {
+	a, err := mavryk.ParseAddress(addr)
+	return err == nil && a.IsValid()
+}
+
+// Operation is an operation built by a Client. Sign it and pass it
+// to Client.Inject.
+type Operation struct {
+	op *codec.Op
+}
+
+// Digest returns the hash which is signed.
+func (o *Operation) Digest() []byte {
+	return o.op.Digest()
+}
+
+// Bytes returns the binary encoded operation including signature.
+func (o *Operation) Bytes() []byte {
+	return o.op.Bytes()
+}
+
+// JSON returns the JSON encoded operation for display.
+func (o *Operation) JSON() (string, error) {
+	buf, err := o.op.MarshalJSON()
+	if err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// Fee returns the total fee in mumav.
+func (o *Operation) Fee() int64 {
+	return o.op.Limits().Fee
+}
+
+// Hash returns the operation hash. Only valid after signing.
+func (o *Operation) Hash() string {
+	return o.op.Hash().String()
+}
+
+// IsSigned returns true when the operation has a signature.
+func (o *Operation) IsSigned() bool {
+	return o.op.Signature.IsValid()
+}
+
+// Sign signs the operation with an unencrypted base58 private key.
+func (o *Operation) Sign(privateKey string) error {
+	sk, err := mavryk.ParsePrivateKey(privateKey)
+	if err != nil {
+		return err
+	}
+	return o.op.Sign(sk)
+}
+
+// AddSignature attaches an externally created base58 signature, e.g. from
+// a hardware wallet or secure enclave.
+func (o *Operation) AddSignature(signature string) error {
+	sig, err := mavryk.ParseSignature(signature)
+	if err != nil {
+		return err
+	}
+	o.op.WithSignature(sig)
+	return nil
+}
+
+// Client is a connection to a Tezos RPC node.
+type Client struct {
+	c       *rpc.Client
+	timeout time.Duration
+}
+
+// NewClient connects to the node at url and loads its chain config.
+func NewClient(url string) (*Client, error) {
+	c, err := rpc.NewClient(url, nil)
+	if err != nil {
+		return nil, err
+	}
+	mc := &Client{
+		c:       c,
+		timeout: 30 * time.Second,
+	}
+	ctx, cancel := mc.context()
+	defer cancel()
+	if err := c.Init(ctx); err != nil {
+		return nil, err
+	}
+	return mc, nil
+}
+
+// SetTimeout sets the timeout for each call in seconds.
+func (c *Client) SetTimeout(seconds int64) {
+	c.timeout = time.Duration(seconds) * time.Second
+}
+
+func (c *Client) context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), c.timeout)
+}
+
+// GetBalance returns the spendable balance of addr in mumav.
+func (c *Client) GetBalance(addr string) (int64, error) {
+	a, err := mavryk.ParseAddress(addr)
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := c.context()
+	defer cancel()
+	bal, err := c.c.GetContractBalance(ctx, a, rpc.Head)
+	if err != nil {
+		return 0, err
+	}
+	return bal.Int64(), nil
+}
+
+// BuildTransfer creates a transfer of amount mumav from the account of
+// publicKey to a destination address. The operation is completed with
+// branch, counter and a reveal if required and its limits and fees are
+// set from simulation.
+func (c *Client) BuildTransfer(publicKey, to string, amount int64) (*Operation, error) {
+	key, err := mavryk.ParseKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	dst, err := mavryk.ParseAddress(to)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := c.context()
+	defer cancel()
+	op := codec.NewOp().
+		WithTransfer(dst, amount).
+		WithSource(key.Address()).
+		WithParams(c.c.ChainParams())
+	if err := c.c.Complete(ctx, op, key); err != nil {
+		return nil, err
+	}
+	sim, err := c.c.Simulate(ctx, op, nil)
+	if err != nil {
+		return nil, err
+	}
+	op.WithLimits(sim.MinLimits(), rpc.ExtraSafetyMargin)
+	return &Operation{op: op}, nil
+}
+
+// Inject broadcasts a signed operation and returns its hash.
+func (c *Client) Inject(op *Operation) (string, error) {
+	if op == nil || !op.IsSigned() {
+		return "", fmt.Errorf("mobile: operation is not signed")
+	}
+	ctx, cancel := c.context()
+	defer cancel()
+	hash, err := c.c.Broadcast(ctx, op.op)
+	if err != nil {
+		return "", err
+	}
+	return hash.String(), nil
+}
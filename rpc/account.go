@@ -0,0 +1,142 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+	"github.com/mavryk-network/mvgo/signer"
+)
+
+// Account is a re-usable operation source. It bundles an address with an
+// optional signer and key derivation path, caches the account's counter
+// and tracks its reveal status so that repeated operations from the same
+// source don't need to refetch state. Accounts without signer are watch-only.
+//
+// Account is safe for concurrent use.
+type Account struct {
+	Address mavryk.Address
+	Signer  signer.Signer // optional, nil for watch-only accounts
+	Path    string        // optional derivation path of the account key, e.g. m/44'/1729'/0'/0'
+
+	mu       sync.Mutex
+	counter  int64 // last used counter
+	revealed bool
+	synced   bool // counter and reveal status are known
+}
+
+// NewAccount returns an account for addr which signs with s.
+func NewAccount(addr mavryk.Address, s signer.Signer) *Account {
+	return &Account{
+		Address: addr,
+		Signer:  s,
+	}
+}
+
+// NewKeyAccount returns an account which signs with private key sk.
+func NewKeyAccount(sk mavryk.PrivateKey) *Account {
+	return NewAccount(sk.Address(), signer.NewFromKey(sk))
+}
+
+// WithPath sets the derivation path of the account key.
+func (a *Account) WithPath(path string) *Account {
+	a.Path = path
+	return a
+}
+
+// CanSign returns true when the account has a signer.
+func (a *Account) CanSign() bool {
+	return a.Signer != nil
+}
+
+// Key returns the account's public key from its signer.
+func (a *Account) Key(ctx context.Context) (mavryk.Key, error) {
+	if a.Signer == nil {
+		return mavryk.InvalidKey, fmt.Errorf("rpc: account %s is watch-only", a.Address)
+	}
+	return a.Signer.GetKey(ctx, a.Address)
+}
+
+// IsSynced returns true when counter and reveal status are cached.
+func (a *Account) IsSynced() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.synced
+}
+
+// Counter returns the last used counter and whether it is known.
+func (a *Account) Counter() (int64, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.counter, a.synced
+}
+
+// NextCounter reserves and returns the next counter. The account must be
+// synced before.
+func (a *Account) NextCounter() int64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.counter++
+	return a.counter
+}
+
+// IsRevealed returns the cached reveal status.
+func (a *Account) IsRevealed() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.revealed
+}
+
+// setRevealed marks the account as revealed after a reveal was added to
+// an operation.
+func (a *Account) setRevealed() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.revealed = true
+}
+
+// Update sets the cached counter and reveal status.
+func (a *Account) Update(counter int64, revealed bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.counter = counter
+	a.revealed = revealed
+	a.synced = true
+}
+
+// Reset drops cached state, e.g. after an operation failed or was dropped
+// from the mempool and reserved counters are no longer valid.
+func (a *Account) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.counter = 0
+	a.revealed = false
+	a.synced = false
+}
+
+// Options returns a copy of opts which signs with this account and uses its
+// cached counter and reveal status when sending.
+func (a *Account) Options(opts *CallOptions) *CallOptions {
+	o := DefaultOptions
+	if opts != nil {
+		o = *opts
+	}
+	o.Signer = a.Signer
+	o.Sender = a.Address
+	o.Account = a
+	return &o
+}
+
+// SyncAccount fetches counter and reveal status of a from the node.
+func (c *Client) SyncAccount(ctx context.Context, a *Account) error {
+	state, err := c.GetContractExt(ctx, a.Address, Head)
+	if err != nil {
+		return err
+	}
+	a.Update(state.Counter, state.IsRevealed())
+	return nil
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc_test
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mavryk-network/mvgo/codec"
+	"github.com/mavryk-network/mvgo/mavryk"
+	"github.com/mavryk-network/mvgo/rpc"
+)
+
+func TestAccountSend(t *testing.T) {
+	node, c, sk := newTestNode(t)
+	acc := rpc.NewKeyAccount(sk)
+
+	// count account state requests
+	var (
+		mu    sync.Mutex
+		syncs int
+	)
+	node.Handle(http.MethodGet, "/chains/main/blocks/head/context/raw/json/contracts/index/"+sk.Address().String(), func(w http.ResponseWriter, _ *http.Request) {
+		mu.Lock()
+		syncs++
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"balance":"1000000000","counter":"0"}`))
+	})
+	numSyncs := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return syncs
+	}
+
+	node.AutoBake(10 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	opts := rpc.NewCallOptions()
+	opts.Confirmations = 0
+
+	// the first operation syncs the account and reveals its key
+	if _, err := c.Send(ctx, codec.NewOp().WithTransfer(testReceiver, 1), acc.Options(opts)); err != nil {
+		t.Fatal(err)
+	}
+	// later operations use cached state
+	if _, err := c.Send(ctx, codec.NewOp().WithTransfer(testReceiver, 2), acc.Options(opts)); err != nil {
+		t.Fatal(err)
+	}
+	if n := numSyncs(); n != 1 {
+		t.Errorf("want 1 account sync, have %d", n)
+	}
+	if n, ok := acc.Counter(); !ok || n != 3 {
+		t.Errorf("want counter 3, have %d (synced %t)", n, ok)
+	}
+	inj := node.Injected()
+	if len(inj) != 2 {
+		t.Fatalf("want 2 injections, have %d", len(inj))
+	}
+	if k := inj[0].Op.Contents[0].Kind(); k != mavryk.OpTypeReveal {
+		t.Errorf("want reveal first, have %s", k)
+	}
+	if n := len(inj[1].Op.Contents); n != 1 {
+		t.Errorf("want single transfer without reveal, have %d contents", n)
+	}
+	if n := inj[1].Op.Contents[0].GetCounter(); n != 3 {
+		t.Errorf("want counter 3, have %d", n)
+	}
+
+	// failed sends drop cached state
+	node.RejectInjections("proto.alpha.node.rejected")
+	if _, err := c.Send(ctx, codec.NewOp().WithTransfer(testReceiver, 3), acc.Options(opts)); err == nil {
+		t.Fatal("expected injection error")
+	}
+	if acc.IsSynced() {
+		t.Error("account state not reset after failed send")
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+	"github.com/mavryk-network/mvgo/micheline"
+)
+
+// DefaultBigmapPageSize is the number of values fetched per request when
+// no page size is given.
+const DefaultBigmapPageSize = 1000
+
+// BigmapIterator walks all values in a bigmap page by page using offset
+// and length parameters on the node's big_maps endpoint. Only one page is
+// held in memory at a time, so iterators are suitable for very large
+// bigmaps such as token ledgers.
+//
+// On first use the iterator pins block id to its hash so all pages are
+// read from the same context even when new blocks arrive in between.
+// Note that the node returns values only. Use ListBigmapKeys or an
+// indexer when key pre-images are required.
+type BigmapIterator struct {
+	client   *Client
+	bigmap   int64
+	block    BlockID
+	pageSize int
+	offset   int
+	pinned   bool
+	done     bool
+}
+
+// NewBigmapIterator returns an iterator over all values in bigmap at block id
+// which fetches pageSize values per request.
+func (c *Client) NewBigmapIterator(bigmap int64, id BlockID, pageSize int) *BigmapIterator {
+	if pageSize <= 0 {
+		pageSize = DefaultBigmapPageSize
+	}
+	return &BigmapIterator{
+		client:   c,
+		bigmap:   bigmap,
+		block:    id,
+		pageSize: pageSize,
+	}
+}
+
+// Block returns the block the iterator reads from. After the first call
+// to Next this is the pinned block hash.
+func (it *BigmapIterator) Block() BlockID {
+	return it.block
+}
+
+// Offset returns the number of values read so far.
+func (it *BigmapIterator) Offset() int {
+	return it.offset
+}
+
+// Next returns the next page of decoded values. It returns io.EOF when
+// all values have been read and ctx.Err() when ctx was canceled.
+func (it *BigmapIterator) Next(ctx context.Context) ([]micheline.Prim, error) {
+	if it.done {
+		return nil, io.EOF
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if !it.pinned {
+		if _, ok := it.block.(mavryk.BlockHash); !ok {
+			hash, err := it.client.GetBlockHash(ctx, it.block)
+			if err != nil {
+				return nil, err
+			}
+			it.block = hash
+		}
+		it.pinned = true
+	}
+	vals, err := it.client.ListBigmapValuesExt(ctx, it.bigmap, it.block, it.offset, it.pageSize)
+	if err != nil {
+		return nil, err
+	}
+	it.offset += len(vals)
+	if len(vals) < it.pageSize {
+		it.done = true
+	}
+	if len(vals) == 0 {
+		return nil, io.EOF
+	}
+	return vals, nil
+}
+
+// Reset restarts iteration from the first value. The pinned block is kept.
+func (it *BigmapIterator) Reset() {
+	it.offset = 0
+	it.done = false
+}
+
+// WalkBigmapValues calls fn for each value in bigmap at block id, fetching
+// pageSize values per request. Walking stops when fn returns an error or
+// ctx is canceled.
+func (c *Client) WalkBigmapValues(ctx context.Context, bigmap int64, id BlockID, pageSize int, fn func(micheline.Prim) error) error {
+	it := c.NewBigmapIterator(bigmap, id, pageSize)
+	for {
+		vals, err := it.Next(ctx)
+		switch err {
+		case nil:
+		case io.EOF:
+			return nil
+		default:
+			return fmt.Errorf("rpc: bigmap %d offset %d: %w", bigmap, it.Offset(), err)
+		}
+		for _, v := range vals {
+			if err := fn(v); err != nil {
+				return err
+			}
+		}
+	}
+}
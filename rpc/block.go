@@ -7,9 +7,11 @@ import (
 	"bytes"
 	"context"
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"time"
 
+	"github.com/mavryk-network/mvgo/codec"
 	"github.com/mavryk-network/mvgo/mavryk"
 )
 
@@ -21,6 +23,7 @@ type Block struct {
 	Header     BlockHeader         `json:"header"`
 	Metadata   BlockMetadata       `json:"metadata"`
 	Operations [][]*Operation      `json:"operations"`
+	Raw        json.RawMessage     `json:"-"` // optional, see Client.RetainRawJSON
 }
 
 func (b Block) GetLevel() int64 {
@@ -131,6 +134,8 @@ type BlockHeader struct {
 	Hash     mavryk.BlockHash    `json:"hash"`
 	Protocol mavryk.ProtocolHash `json:"protocol"`
 	ChainId  mavryk.ChainIdHash  `json:"chain_id"`
+
+	Raw json.RawMessage `json:"-"` // optional, see Client.RetainRawJSON
 }
 
 func (h BlockHeader) LbVote() mavryk.FeatureVote {
@@ -167,7 +172,7 @@ func (h BlockHeader) AiVote() mavryk.FeatureVote {
 // +---------------------------------------+----------+-------------------------------------+
 // | per_block_votes                       | 1 byte   | signed 8-bit integer                |
 // +---------------------------------------+----------+-------------------------------------+
-// | signature                             | 64 bytes | bytes                               |
+// | signature                             | Variable | bytes                               |
 // +---------------------------------------+----------+-------------------------------------+
 
 func (h BlockHeader) ProtocolData() []byte {
@@ -181,14 +186,38 @@ func (h BlockHeader) ProtocolData() []byte {
 	} else {
 		buf.WriteByte(0x0)
 	}
-	// broken, how to merge multiple flags is undocumented
-	buf.WriteByte(h.LbVote().Tag() | (h.AiVote().Tag() << 2))
+	buf.WriteByte(mavryk.EncodePerBlockVotes(h.LbVote(), h.AiVote()))
 	if h.Signature.IsValid() {
 		buf.Write(h.Signature.Data) // raw, no tag!
 	}
 	return buf.Bytes()
 }
 
+// CodecHeader converts the header into its binary codec representation which
+// can be encoded, signed or embedded into double baking evidence operations.
+func (h BlockHeader) CodecHeader() codec.BlockHeader {
+	bh := codec.BlockHeader{
+		Level:            int32(h.Level),
+		Proto:            byte(h.Proto),
+		Predecessor:      h.Predecessor,
+		Timestamp:        h.Timestamp,
+		ValidationPass:   byte(h.ValidationPass),
+		OperationsHash:   h.OperationsHash,
+		Fitness:          h.Fitness,
+		Context:          h.Context,
+		PayloadHash:      h.PayloadHash,
+		PayloadRound:     h.PayloadRound,
+		ProofOfWorkNonce: h.ProofOfWorkNonce,
+		LbVote:           h.LbVote(),
+		AiVote:           h.AiVote(),
+		Signature:        h.Signature,
+	}
+	if h.SeedNonceHash != nil {
+		bh.SeedNonceHash = *h.SeedNonceHash
+	}
+	return bh
+}
+
 // BlockContent is part of block 1 header that seeds the initial context
 type BlockContent struct {
 	Command    string              `json:"command"`
@@ -258,6 +287,8 @@ type BlockMetadata struct {
 	// v015+
 	ProposerConsensusKey mavryk.Address `json:"proposer_consensus_key"`
 	BakerConsensusKey    mavryk.Address `json:"baker_consensus_key"`
+
+	Raw json.RawMessage `json:"-"` // optional, see Client.RetainRawJSON
 }
 
 func (m *BlockMetadata) GetLevel() int64 {
@@ -272,6 +303,10 @@ func (m *BlockMetadata) GetLevel() int64 {
 
 // GetBlock returns information about a Tezos block
 // https://tezos.gitlab.io/mainnet/api/rpc.html#get-block-id
+//
+// When RecoverMetadata is enabled, stripped operation metadata is refetched.
+// If recovery fails for some operations, the block is returned together
+// with a MetadataStrippedError.
 func (c *Client) GetBlock(ctx context.Context, id BlockID) (*Block, error) {
 	var block Block
 	u := fmt.Sprintf("chains/main/blocks/%s", id)
@@ -281,6 +316,11 @@ func (c *Client) GetBlock(ctx context.Context, id BlockID) (*Block, error) {
 	if err := c.Get(ctx, u, &block); err != nil {
 		return nil, err
 	}
+	if c.RecoverMetadata && block.HasStrippedMetadata() {
+		if err := c.RecoverBlockMetadata(ctx, &block); err != nil {
+			return &block, err
+		}
+	}
 	return &block, nil
 }
 
@@ -366,6 +406,13 @@ func (c *Client) GetBlockHash(ctx context.Context, id BlockID) (hash mavryk.Bloc
 	return
 }
 
+// ResolveBranch returns the hash of block head~offset. It implements the
+// codec.BranchResolver interface so that operations can resolve their branch
+// from TTL via Op.WithBranchResolver(client).
+func (c *Client) ResolveBranch(ctx context.Context, offset int64) (mavryk.BlockHash, error) {
+	return c.GetBlockHash(ctx, NewBlockOffset(Head, -offset))
+}
+
 // GetBlockPredHashes returns count parent blocks before block with given hash.
 // https://tezos.gitlab.io/mainnet/api/rpc.html#get-chains-chain-id-blocks
 func (c *Client) GetBlockPredHashes(ctx context.Context, hash mavryk.BlockHash, count int) ([]mavryk.BlockHash, error) {
@@ -0,0 +1,137 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/mavryk-network/mvgo/codec"
+	"github.com/mavryk-network/mvgo/mavryk"
+)
+
+// SigningBundle contains a completed and simulated operation together with all
+// data required to sign it in a separate trust zone (e.g. an offline machine
+// or HSM). Bundles are JSON serializable so they can be exported, signed
+// elsewhere and later finalized with the detached signature.
+type SigningBundle struct {
+	Source      mavryk.Address      `json:"source"`
+	Key         mavryk.Key          `json:"public_key"`
+	ChainId     mavryk.ChainIdHash  `json:"chain_id"`
+	Protocol    mavryk.ProtocolHash `json:"protocol"`
+	Branch      mavryk.BlockHash    `json:"branch"`
+	TTL         int64               `json:"ttl"`
+	Fee         int64               `json:"fee"`
+	GasLimit    int64               `json:"gas_limit"`
+	Payload     mavryk.HexBytes     `json:"payload"`     // unsigned binary operation
+	Watermarked mavryk.HexBytes     `json:"watermarked"` // watermarked payload, input for remote signers
+	Digest      mavryk.HexBytes     `json:"digest"`      // blake2b digest to sign
+}
+
+// Verify checks that sig is a valid signature of the bundle payload by the
+// bundle's public key. The digest is recomputed from watermark and payload,
+// so a bundle where payload, watermarked bytes or digest were altered after
+// Prepare is rejected. Watermarks other than the operation watermark are
+// rejected as well.
+func (b SigningBundle) Verify(sig mavryk.Signature) error {
+	if !sig.IsValid() {
+		return fmt.Errorf("rpc: invalid signature")
+	}
+	if !b.Key.Address().Equal(b.Source) {
+		return fmt.Errorf("rpc: bundle key %s does not match source %s", b.Key, b.Source)
+	}
+	// only operations can be signed, a block or consensus watermark would
+	// turn the signature into a baking signature
+	if len(b.Payload) == 0 || len(b.Watermarked) != len(b.Payload)+1 || !bytes.HasSuffix(b.Watermarked, b.Payload) {
+		return fmt.Errorf("rpc: bundle payload does not match watermarked bytes")
+	}
+	if w := b.Watermarked[0]; w != codec.OperationWatermark {
+		return fmt.Errorf("rpc: invalid bundle watermark 0x%02x", w)
+	}
+	digest := mavryk.Digest(b.Watermarked)
+	if !bytes.Equal(digest[:], b.Digest) {
+		return fmt.Errorf("rpc: bundle digest mismatch")
+	}
+	return b.Key.Verify(digest[:], sig)
+}
+
+// SignedBytes returns the binary operation with signature sig appended
+// ready for broadcast.
+func (b SigningBundle) SignedBytes(sig mavryk.Signature) []byte {
+	buf := make([]byte, 0, len(b.Payload)+len(sig.Data))
+	buf = append(buf, b.Payload...)
+	return append(buf, sig.Data...) // raw, without type
+}
+
+// Prepare completes and simulates an operation for a watch-only account and
+// returns a signing bundle. Only the sender's public key is required, either
+// from key or, when key is invalid, from the signer set in opts or the client.
+// A watch-only signer (see signer.NewWatchOnly) is sufficient for this purpose.
+// Sign the bundle digest elsewhere and call Finalize to inject the operation.
+func (c *Client) Prepare(ctx context.Context, op *codec.Op, key mavryk.Key, opts *CallOptions) (*SigningBundle, error) {
+	if opts == nil {
+		opts = &DefaultOptions
+	}
+	ctx = WithRequestHeaders(ctx, opts.Headers)
+
+	// identify the sender key when not provided
+	if !key.IsValid() {
+		var err error
+		_, _, key, err = c.resolveSigner(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// complete, simulate and check the operation
+	if err := c.prepare(ctx, op, key, opts); err != nil {
+		return nil, err
+	}
+
+	limits := op.Limits()
+	return &SigningBundle{
+		Source:      key.Address(),
+		Key:         key,
+		ChainId:     c.ChainId,
+		Protocol:    op.Params.Protocol,
+		Branch:      op.Branch,
+		TTL:         op.TTL,
+		Fee:         limits.Fee,
+		GasLimit:    limits.GasLimit,
+		Payload:     op.Bytes(),
+		Watermarked: op.WatermarkedBytes(),
+		Digest:      op.Digest(),
+	}, nil
+}
+
+// Finalize verifies a detached signature for a signing bundle created by
+// Prepare, broadcasts the signed operation and waits for confirmations.
+func (c *Client) Finalize(ctx context.Context, b *SigningBundle, sig mavryk.Signature, opts *CallOptions) (*Receipt, error) {
+	if opts == nil {
+		opts = &DefaultOptions
+	}
+	ctx = WithRequestHeaders(ctx, opts.Headers)
+	if err := b.Verify(sig); err != nil {
+		return nil, err
+	}
+
+	// use custom observer when provided
+	mon := c.BlockObserver
+	if opts.Observer != nil {
+		mon = opts.Observer
+	}
+
+	// ensure block observer is running
+	mon.Listen(c)
+
+	// broadcast
+	hash, err := c.BroadcastOperation(ctx, b.SignedBytes(sig))
+	if err != nil {
+		return nil, err
+	}
+
+	// wait for confirmations and return receipt
+	return c.waitReceipt(ctx, hash, b.TTL, mon, opts)
+}
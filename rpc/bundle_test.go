@@ -0,0 +1,94 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mavryk-network/mvgo/codec"
+	"github.com/mavryk-network/mvgo/mavryk"
+	"github.com/mavryk-network/mvgo/rpc"
+)
+
+func TestSigningBundle(t *testing.T) {
+	node, c, sk := newTestNode(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	opts := rpc.NewCallOptions()
+	opts.Confirmations = 0
+
+	b, err := c.Prepare(ctx, codec.NewOp().WithTransfer(testReceiver, 1000), sk.Public(), opts)
+	if err != nil {
+		t.Fatalf("prepare: %v", err)
+	}
+	sig, err := sk.Sign(b.Digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Verify(sig); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	// tampered bundles
+	for name, fn := range map[string]func(b *rpc.SigningBundle){
+		"payload": func(b *rpc.SigningBundle) {
+			b.Payload = append(mavryk.HexBytes{}, b.Payload...)
+			b.Payload[len(b.Payload)-1]++
+		},
+		"watermarked": func(b *rpc.SigningBundle) {
+			b.Watermarked = append(mavryk.HexBytes{}, b.Watermarked...)
+			b.Watermarked[len(b.Watermarked)-1]++
+		},
+		"digest": func(b *rpc.SigningBundle) {
+			b.Digest = append(mavryk.HexBytes{}, b.Digest...)
+			b.Digest[0]++
+		},
+		"source": func(b *rpc.SigningBundle) {
+			b.Source = testReceiver
+		},
+	} {
+		x := *b
+		fn(&x)
+		if err := x.Verify(sig); err == nil {
+			t.Errorf("%s: expected verify error", name)
+		}
+	}
+
+	// non-operation watermarks are rejected even when digest and signature match
+	x := *b
+	x.Watermarked = append(mavryk.HexBytes{codec.TenderbakeBlockWatermark}, b.Payload...)
+	digest := mavryk.Digest(x.Watermarked)
+	x.Digest = digest[:]
+	xsig, err := sk.Sign(x.Digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := x.Verify(xsig); err == nil {
+		t.Errorf("watermark: expected verify error")
+	}
+
+	// wrong signer
+	other, err := mavryk.GenerateKey(mavryk.KeyTypeEd25519)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bad, err := other.Sign(b.Digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Verify(bad); err == nil {
+		t.Errorf("expected signature error")
+	}
+
+	node.AutoBake(10 * time.Millisecond)
+	rcpt, err := c.Finalize(ctx, b, sig, opts)
+	if err != nil {
+		t.Fatalf("finalize: %v", err)
+	}
+	if !rcpt.IsSuccess() {
+		t.Errorf("expected success, have %v", rcpt.Error())
+	}
+}
@@ -0,0 +1,221 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+)
+
+// CacheStore is a pluggable key/value store for cached RPC responses. A
+// zero ttl means the value never expires. Implementations must be safe for
+// concurrent use.
+type CacheStore interface {
+	Get(key string) ([]byte, bool)
+	Add(key string, val []byte, ttl time.Duration)
+}
+
+// Cache enables caching of GET responses on a client. Responses for blocks
+// addressed by hash never change and are cached without expiry. Block data
+// (header, metadata, operations, etc) for blocks addressed relative to head
+// or by level is cached for HeadTTL or, when zero, for the chain's minimal
+// block delay. Context state of such blocks (counters, balances, storage)
+// and all other paths (mempool, monitors, etc) are never cached because
+// they change with every new block and stale values break operation
+// completion.
+type Cache struct {
+	Store   CacheStore
+	HeadTTL time.Duration
+}
+
+// NewCache returns a cache backed by an in-memory LRU store of size entries.
+func NewCache(size int) *Cache {
+	return &Cache{
+		Store: NewLRUCache(size),
+	}
+}
+
+const (
+	cacheNever     = -1
+	cacheImmutable = 0
+)
+
+// ttl returns the cache lifetime for a request path, cacheNever when the
+// path must not be cached and cacheImmutable when the response never changes.
+func (c *Cache) ttl(urlpath string, p *mavryk.Params) time.Duration {
+	path, _, _ := strings.Cut(urlpath, "?")
+	path, ok := cutPrefix(path, "chains/")
+	if !ok {
+		return cacheNever
+	}
+	_, path, _ = strings.Cut(path, "/")
+	if path == "chain_id" {
+		return cacheImmutable
+	}
+	rest, ok := cutPrefix(path, "blocks/")
+	if !ok {
+		return cacheNever
+	}
+	id, sub, _ := strings.Cut(rest, "/")
+	// ancestors of a block hash never change
+	base, _, _ := strings.Cut(id, "~")
+	if _, err := mavryk.ParseBlockHash(base); err == nil {
+		return cacheImmutable
+	}
+	if !isHeadRelative(id) || isMutable(sub) {
+		return cacheNever
+	}
+	if c.HeadTTL > 0 {
+		return c.HeadTTL
+	}
+	if p != nil && p.MinimalBlockDelay > 0 {
+		return p.MinimalBlockDelay
+	}
+	return mavryk.DefaultParams.MinimalBlockDelay
+}
+
+func cutPrefix(s, prefix string) (string, bool) {
+	if !strings.HasPrefix(s, prefix) {
+		return s, false
+	}
+	return s[len(prefix):], true
+}
+
+// isMutable returns true for block sub-paths which depend on chain state
+// rather than on the block alone, e.g. context/contracts/{id}/counter.
+func isMutable(sub string) bool {
+	for _, prefix := range []string{"context/", "helpers/", "votes/"} {
+		if strings.HasPrefix(sub, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isHeadRelative returns true for block ids like head, head~2 or a level.
+func isHeadRelative(id string) bool {
+	if id == "head" {
+		return true
+	}
+	if n, ok := cutPrefix(id, "head~"); ok {
+		id = n
+	}
+	_, err := strconv.ParseInt(id, 10, 64)
+	return err == nil
+}
+
+// getCached serves a GET request from cache or fetches and caches the response.
+func (c *Client) getCached(ctx context.Context, urlpath string, result interface{}) error {
+	ttl := c.Cache.ttl(urlpath, c.params())
+	if ttl == cacheNever {
+		return c.get(ctx, urlpath, result)
+	}
+	if buf, ok := c.Cache.Store.Get(urlpath); ok {
+		if result == nil {
+			return nil
+		}
+		return c.unmarshal(buf, result)
+	}
+	var raw json.RawMessage
+	if err := c.get(ctx, urlpath, &raw); err != nil {
+		return err
+	}
+	if len(raw) == 0 {
+		return nil
+	}
+	c.Cache.Store.Add(urlpath, raw, ttl)
+	if result == nil {
+		return nil
+	}
+	return c.unmarshal(raw, result)
+}
+
+// LRUCache is an in-memory CacheStore which evicts the least recently used
+// entries when full.
+type LRUCache struct {
+	mu    sync.Mutex
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type lruEntry struct {
+	key     string
+	val     []byte
+	expires time.Time
+}
+
+// make sure LRUCache implements CacheStore interface
+var _ CacheStore = (*LRUCache)(nil)
+
+func NewLRUCache(size int) *LRUCache {
+	if size <= 0 {
+		size = 1
+	}
+	return &LRUCache{
+		size:  size,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+func (c *LRUCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*lruEntry)
+	if !e.expires.IsZero() && time.Now().After(e.expires) {
+		c.ll.Remove(el)
+		delete(c.items, key)
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return e.val, true
+}
+
+func (c *LRUCache) Add(key string, val []byte, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var exp time.Time
+	if ttl > 0 {
+		exp = time.Now().Add(ttl)
+	}
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*lruEntry)
+		e.val, e.expires = val, exp
+		c.ll.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.ll.PushFront(&lruEntry{key: key, val: val, expires: exp})
+	for c.ll.Len() > c.size {
+		el := c.ll.Back()
+		c.ll.Remove(el)
+		delete(c.items, el.Value.(*lruEntry).key)
+	}
+}
+
+// Len returns the number of cached entries.
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+// Purge removes all entries.
+func (c *LRUCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ll.Init()
+	c.items = make(map[string]*list.Element)
+}
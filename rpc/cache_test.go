@@ -0,0 +1,58 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc_test
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/mavryk-network/mvgo/rpc"
+)
+
+func TestCacheSkipsMutablePaths(t *testing.T) {
+	node, c, sk := newTestNode(t, rpc.WithCache(rpc.NewCache(16)))
+	ctx := context.Background()
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	for _, p := range []string{
+		"/chains/main/blocks/head/header",
+		"/chains/main/blocks/head/context/contracts/" + sk.Address().String() + "/counter",
+		"/chains/main/blocks/head/context/contracts/" + sk.Address().String() + "/balance",
+	} {
+		path := p
+		node.Handle(http.MethodGet, path, func(w http.ResponseWriter, _ *http.Request) {
+			mu.Lock()
+			hits[path]++
+			mu.Unlock()
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`"1"`))
+		})
+		for i := 0; i < 2; i++ {
+			var v interface{}
+			if err := c.Get(ctx, path[1:], &v); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for path, want := range map[string]int{
+		"/chains/main/blocks/head/header":                                                  1,
+		"/chains/main/blocks/head/context/contracts/" + sk.Address().String() + "/counter": 2,
+		"/chains/main/blocks/head/context/contracts/" + sk.Address().String() + "/balance": 2,
+	} {
+		if have := hits[path]; have != want {
+			t.Errorf("%s: want %d requests, have %d", path, want, have)
+		}
+	}
+}
+
+func TestCacheSend(t *testing.T) {
+	// completion must see fresh counters across consecutive sends
+	node, c, _ := newTestNode(t, rpc.WithCache(rpc.NewCache(16)))
+	sendTransfer(t, node, c, 1)
+	sendTransfer(t, node, c, 1)
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc
+
+import (
+	"context"
+	"fmt"
+)
+
+// NodeFeature identifies an RPC feature which is only available on recent
+// node versions.
+type NodeFeature byte
+
+const (
+	// FeatureMetadataQuery is the metadata query argument on block and
+	// operation RPCs, see MetadataMode.
+	FeatureMetadataQuery NodeFeature = iota
+	// FeatureSimulateOperation is the simulate_operation helper used by
+	// Simulate. Older nodes only support run_operation.
+	FeatureSimulateOperation
+)
+
+// minVersion lists the first node major version supporting each feature.
+var minVersion = map[NodeFeature]int{
+	FeatureMetadataQuery:     13,
+	FeatureSimulateOperation: 14,
+}
+
+func (f NodeFeature) String() string {
+	switch f {
+	case FeatureMetadataQuery:
+		return "metadata_query"
+	case FeatureSimulateOperation:
+		return "simulate_operation"
+	default:
+		return fmt.Sprintf("feature_%d", byte(f))
+	}
+}
+
+// NodeCapabilities describes features supported by a node version.
+type NodeCapabilities struct {
+	Version NodeVersion
+}
+
+// Supports returns true when the node version supports feature f. Unknown
+// versions (e.g. from proxies which hide the version) are assumed to
+// support all features.
+func (n NodeCapabilities) Supports(f NodeFeature) bool {
+	if n.Version.Major == 0 {
+		return true
+	}
+	return n.Version.Major >= minVersion[f]
+}
+
+// Supports returns true when the connected node supports feature f. Before
+// DetectCapabilities was called all features are assumed to be available.
+func (c *Client) Supports(f NodeFeature) bool {
+	return c.Capabilities == nil || c.Capabilities.Supports(f)
+}
+
+// DetectCapabilities reads the node version and adapts client settings to
+// features the node lacks. On nodes without metadata query support the
+// MetadataMode is cleared. It is called by Init.
+func (c *Client) DetectCapabilities(ctx context.Context) error {
+	v, err := c.GetNodeVersion(ctx)
+	if err != nil {
+		return err
+	}
+	c.Capabilities = &NodeCapabilities{Version: v}
+	if c.MetadataMode != "" && !c.Supports(FeatureMetadataQuery) {
+		c.Log.Warnf("rpc: node v%d.%d does not support metadata mode %s", v.Major, v.Minor, c.MetadataMode)
+		c.MetadataMode = ""
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/mavryk-network/mvgo/rpc"
+)
+
+func TestInitCapabilities(t *testing.T) {
+	node, c, _ := newTestNode(t)
+	ctx := context.Background()
+	serveConstants(node, "head")
+	node.Version = rpc.NodeVersion{Major: 18}
+
+	if err := c.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if c.Capabilities == nil || c.Capabilities.Version.Major != 18 {
+		t.Errorf("want capabilities of node v18, have %+v", c.Capabilities)
+	}
+
+	// nodes without version RPC are assumed to support all features
+	node.Handle(http.MethodGet, "/version", func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	c, err := node.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.Init(ctx); err != nil {
+		t.Fatalf("init without version: %v", err)
+	}
+	if c.Capabilities != nil {
+		t.Errorf("want nil capabilities, have %+v", c.Capabilities)
+	}
+	if !c.Supports(rpc.FeatureMetadataQuery) {
+		t.Error("want all features supported")
+	}
+}
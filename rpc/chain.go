@@ -62,3 +62,38 @@ func (c *Client) GetVersionInfo(ctx context.Context) (VersionInfo, error) {
 	err := c.Get(ctx, "version", &v)
 	return v, err
 }
+
+// GetNodeVersion returns the node's software version.
+func (c *Client) GetNodeVersion(ctx context.Context) (NodeVersion, error) {
+	v, err := c.GetVersionInfo(ctx)
+	return v.NodeVersion, err
+}
+
+// GetProtocols returns hashes of all protocols known to the node.
+// https://tezos.gitlab.io/shell/rpc.html#get-protocols
+func (c *Client) GetProtocols(ctx context.Context) ([]mavryk.ProtocolHash, error) {
+	var p []mavryk.ProtocolHash
+	err := c.Get(ctx, "protocols", &p)
+	return p, err
+}
+
+type ProtocolComponent struct {
+	Name           string `json:"name"`
+	Interface      string `json:"interface,omitempty"`
+	Implementation string `json:"implementation"`
+}
+
+type ProtocolData struct {
+	ExpectedEnvVersion int                 `json:"expected_env_version"`
+	Components         []ProtocolComponent `json:"components"`
+}
+
+// GetProtocolData returns environment version and source code of protocol p.
+// https://tezos.gitlab.io/shell/rpc.html#get-protocols-protocol-hash
+func (c *Client) GetProtocolData(ctx context.Context, p mavryk.ProtocolHash) (*ProtocolData, error) {
+	var d ProtocolData
+	if err := c.Get(ctx, "protocols/"+p.String(), &d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
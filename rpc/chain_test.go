@@ -0,0 +1,48 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mavryk-network/mvgo/rpc"
+)
+
+func TestWithChain(t *testing.T) {
+	node, c, _ := newTestNode(t, rpc.WithChain("test"))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	head := node.Head()
+
+	// streamed block operations
+	node.Handle(http.MethodGet, "/chains/test/blocks/head/operations", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[[],[],[],[]]`))
+	})
+	if err := c.StreamBlockOperations(ctx, rpc.Head, func(_, _ int, _ *rpc.Operation) error { return nil }); err != nil {
+		t.Errorf("stream: %v", err)
+	}
+
+	// heads monitor
+	node.Handle(http.MethodGet, "/monitor/heads/test", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(head.LogEntry())
+	})
+	mon := rpc.NewBlockHeaderMonitor()
+	defer mon.Close()
+	if err := c.MonitorBlockHeader(ctx, mon); err != nil {
+		t.Fatalf("monitor: %v", err)
+	}
+	h, err := mon.Recv(ctx)
+	if err != nil {
+		t.Fatalf("recv: %v", err)
+	}
+	if !h.Hash.Equal(head.Hash) {
+		t.Errorf("want head %s, have %s", head.Hash, h.Hash)
+	}
+}
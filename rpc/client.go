@@ -1,4 +1,4 @@
-// Copyright (c) 2020-2022 Blockwatch Data Inc.
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
 // Author: alex@blockwatch.cc
 
 package rpc
@@ -13,7 +13,9 @@ import (
 	"net/http/httputil"
 	"net/url"
 	"os"
+	"reflect"
 	"strings"
+	"sync"
 
 	"github.com/mavryk-network/mvgo/mavryk"
 	"github.com/mavryk-network/mvgo/signer"
@@ -32,6 +34,8 @@ const (
 type Client struct {
 	// HTTP client used to communicate with the Tezos node API.
 	client *http.Client
+	// private copy of the HTTP transport once configured by options
+	transport *http.Transport
 	// Base URL for API requests.
 	BaseURL *url.URL
 	// Base URL for IPFS requests.
@@ -42,8 +46,16 @@ type Client struct {
 	ApiKey string
 	// The chain the client will query.
 	ChainId mavryk.ChainIdHash
-	// The current chain configuration.
+	// Chain is the chain alias or chain id used in chains/{chain} RPC paths.
+	// Empty defaults to main, see WithChain.
+	Chain string
+	// The current chain configuration. Params may be replaced in the
+	// background after protocol upgrades, use ChainParams to read and
+	// SetParams to replace params once the client is in use.
 	Params *mavryk.Params
+	// Capabilities of the connected node, see DetectCapabilities. Nil
+	// assumes all features are supported.
+	Capabilities *NodeCapabilities
 	// An active event observer to watch for operation inclusion
 	BlockObserver *Observer
 	// An active event observer to watch for operation posting to the mempool
@@ -54,18 +66,86 @@ type Client struct {
 	// block and operation receipts. Set this mode to `always` if an RPC node prunes
 	// metadata (i.e. you see metadata too large in certain operations)
 	MetadataMode MetadataMode
+	// RecoverMetadata refetches operations whose metadata was stripped by
+	// the node ("too large") with metadata mode always, see
+	// RecoverBlockMetadata.
+	RecoverMetadata bool
+	// RetainRawJSON keeps a copy of the JSON that blocks, headers, metadata,
+	// operations, operation contents, contracts, delegates and constants
+	// were decoded from in their Raw field. This is useful for lossless
+	// archival and for debugging fields which are not modelled yet. Responses
+	// are decoded twice when enabled.
+	RetainRawJSON bool
+	// StrictJSON fails decoding of responses and monitor messages which
+	// contain fields the target type does not model. Use it in tests and CI
+	// to detect new protocol fields early. Nodes add fields in new protocol
+	// versions, so keep it disabled in production.
+	StrictJSON bool
+	// ReportUnknownFields collects fields which are not modelled by the
+	// target type while decoding, see UnknownFields. Responses are decoded
+	// twice when enabled.
+	ReportUnknownFields bool
 	// Close connections. This may help with EOF errors from unexpected
 	// connection close by Tezos RPC.
 	CloseConns bool
+	// Compression requests gzip or deflate compressed responses and
+	// decompresses them transparently. This cuts bandwidth for large blocks
+	// fetched from remote providers.
+	Compression bool
+	// CompressRequestSize gzips request bodies of at least this many bytes.
+	// Zero disables request compression. Nodes do not accept compressed
+	// bodies, enable this only for gateways that do.
+	CompressRequestSize int
+	// Retry enables automatic retries of Get and Put requests and of
+	// idempotent POST helpers like run, simulate and forge on transient
+	// errors. Post and operation injection are never retried because a
+	// lost response does not mean the node did not process the request.
+	// Nil disables retries.
+	Retry *RetryPolicy
+	// Cache enables caching of block related GET responses. Nil disables
+	// caching.
+	Cache *Cache
+	// Metrics receives request and monitor reconnect events. Nil disables
+	// metrics.
+	Metrics Metrics
+	// RateLimit throttles requests globally and per endpoint class. Nil
+	// disables rate limiting.
+	RateLimit *RateLimiter
+	// AutoRefreshParams refreshes Params before operations are sent when the
+	// next protocol of the node changed, so services running across a
+	// protocol upgrade don't forge with stale operation tags and limits. It
+	// costs an extra request per send when the protocol cache expired, see
+	// RefreshParams. Services which already run a ProtocolWatcher don't need
+	// it. Disabled by default.
+	AutoRefreshParams bool
 	// Log is the logger implementation used by this client
 	Log log.Logger
+	// RequestLog receives structured start and finish events for each
+	// request. Nil disables request logging.
+	RequestLog RequestLogger
+	// request interceptors, see Use
+	interceptors []Interceptor
+	// cached protocol of the connected node, see CheckParams
+	protoCache protocolCache
+	// guards Params
+	paramsMu sync.RWMutex
+	// unknown fields collected in report mode
+	unknownMu     sync.Mutex
+	unknownFields map[string]int
 }
 
-// NewClient returns a new Tezos RPC client.
-func NewClient(baseURL string, httpClient *http.Client) (*Client, error) {
+// NewClient returns a new Tezos RPC client. Options are applied after
+// defaults were set, see ClientOption. Use a unix:// URL with an absolute
+// socket path like unix:///var/run/node.sock to connect over a unix domain
+// socket.
+func NewClient(baseURL string, httpClient *http.Client, opts ...ClientOption) (*Client, error) {
 	if httpClient == nil {
 		httpClient = http.DefaultClient
 	}
+	if path, ok := cutPrefix(baseURL, UnixScheme); ok {
+		httpClient = unixClient(path, httpClient)
+		baseURL = unixBaseURL
+	}
 	if !strings.HasPrefix(baseURL, "http") {
 		baseURL = "http://" + baseURL
 	}
@@ -93,11 +173,38 @@ func NewClient(baseURL string, httpClient *http.Client) (*Client, error) {
 		MetadataMode:    MetadataModeAlways,
 		Log:             logger,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
 	return c, nil
 }
 
+// Init resolves chain id and params and detects node capabilities. Nodes
+// which do not serve their version are assumed to support all features,
+// so capability detection errors are logged and leave Capabilities nil.
 func (c *Client) Init(ctx context.Context) error {
-	return c.ResolveChainConfig(ctx)
+	if err := c.ResolveChainConfig(ctx); err != nil {
+		return err
+	}
+	if err := c.DetectCapabilities(ctx); err != nil {
+		c.Log.Warnf("rpc: cannot detect node capabilities: %v", err)
+	}
+	return nil
+}
+
+// chainPath replaces the default chain in chain RPC paths and in the heads
+// monitor path with the client's chain. All requests are routed through
+// chainPath by NewRequest.
+func (c *Client) chainPath(urlpath string) string {
+	if c.Chain == "" || c.Chain == "main" {
+		return urlpath
+	}
+	for _, prefix := range []string{"chains/", "monitor/heads/"} {
+		if rest, ok := cutPrefix(urlpath, prefix+"main"); ok && (rest == "" || rest[0] == '/' || rest[0] == '?') {
+			return prefix + c.Chain + rest
+		}
+	}
+	return urlpath
 }
 
 func (c *Client) UseIpfsUrl(uri string) error {
@@ -119,9 +226,14 @@ func (c *Client) Listen() {
 	c.MempoolObserver.ListenMempool(c)
 }
 
+// Close stops observers and closes idle connections of the client's private
+// transport. Shared transports passed to NewClient are left untouched.
 func (c *Client) Close() {
 	c.BlockObserver.Close()
 	c.MempoolObserver.Close()
+	if c.transport != nil {
+		c.transport.CloseIdleConnections()
+	}
 }
 
 func (c *Client) ResolveChainConfig(ctx context.Context) error {
@@ -134,16 +246,51 @@ func (c *Client) ResolveChainConfig(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-	c.Params = p
+	c.SetParams(p)
 	return nil
 }
 
+// ChainParams returns the params resolved from the connected node. Before
+// params are resolved it falls back to well-known defaults for the client's
+// chain id.
+func (c *Client) ChainParams() *mavryk.Params {
+	if p := c.params(); p != nil {
+		return p
+	}
+	return mavryk.ParamsFor(c.ChainId)
+}
+
+// SetParams replaces the client's params. It is safe for concurrent use
+// with ChainParams.
+func (c *Client) SetParams(p *mavryk.Params) {
+	c.paramsMu.Lock()
+	c.Params = p
+	c.paramsMu.Unlock()
+}
+
+// params returns the resolved params or nil.
+func (c *Client) params() *mavryk.Params {
+	c.paramsMu.RLock()
+	defer c.paramsMu.RUnlock()
+	return c.Params
+}
+
 func (c *Client) Get(ctx context.Context, urlpath string, result interface{}) error {
-	req, err := c.NewRequest(ctx, http.MethodGet, urlpath, nil)
-	if err != nil {
-		return err
+	urlpath = c.chainPath(urlpath)
+	if c.Cache != nil {
+		return c.getCached(ctx, urlpath, result)
 	}
-	return c.Do(req, result)
+	return c.get(ctx, urlpath, result)
+}
+
+func (c *Client) get(ctx context.Context, urlpath string, result interface{}) error {
+	return c.withRetry(ctx, func() error {
+		req, err := c.NewRequest(ctx, http.MethodGet, urlpath, nil)
+		if err != nil {
+			return err
+		}
+		return c.Do(req, result)
+	})
 }
 
 func (c *Client) GetAsync(ctx context.Context, urlpath string, mon Monitor) error {
@@ -155,13 +302,17 @@ func (c *Client) GetAsync(ctx context.Context, urlpath string, mon Monitor) erro
 }
 
 func (c *Client) Put(ctx context.Context, urlpath string, body, result interface{}) error {
-	req, err := c.NewRequest(ctx, http.MethodPut, urlpath, body)
-	if err != nil {
-		return err
-	}
-	return c.Do(req, result)
+	return c.withRetry(ctx, func() error {
+		req, err := c.NewRequest(ctx, http.MethodPut, urlpath, body)
+		if err != nil {
+			return err
+		}
+		return c.Do(req, result)
+	})
 }
 
+// Post sends a POST request without retries or pool failover. Use it for
+// requests with side effects like operation injection.
 func (c *Client) Post(ctx context.Context, urlpath string, body, result interface{}) error {
 	req, err := c.NewRequest(ctx, http.MethodPost, urlpath, body)
 	if err != nil {
@@ -170,9 +321,23 @@ func (c *Client) Post(ctx context.Context, urlpath string, body, result interfac
 	return c.Do(req, result)
 }
 
+// postIdempotent sends a POST request which is safe to repeat, e.g. a
+// simulation. Such requests are retried and may fail over to other pool
+// nodes.
+func (c *Client) postIdempotent(ctx context.Context, urlpath string, body, result interface{}) error {
+	ctx = withIdempotent(ctx)
+	return c.withRetry(ctx, func() error {
+		req, err := c.NewRequest(ctx, http.MethodPost, urlpath, body)
+		if err != nil {
+			return err
+		}
+		return c.Do(req, result)
+	})
+}
+
 // NewRequest creates a Tezos RPC request.
 func (c *Client) NewRequest(ctx context.Context, method, urlStr string, body interface{}) (*http.Request, error) {
-	rel, err := url.Parse(urlStr)
+	rel, err := url.Parse(c.chainPath(urlStr))
 	if err != nil {
 		return nil, err
 	}
@@ -187,6 +352,15 @@ func (c *Client) NewRequest(ctx context.Context, method, urlStr string, body int
 		}
 	}
 
+	var encoding string
+	if c.CompressRequestSize > 0 && buf.Len() >= c.CompressRequestSize {
+		buf, err = gzipBody(buf.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		encoding = "gzip"
+	}
+
 	req, err := http.NewRequest(method, u.String(), buf)
 	if err != nil {
 		return nil, err
@@ -197,9 +371,16 @@ func (c *Client) NewRequest(ctx context.Context, method, urlStr string, body int
 	req.Header.Add("Content-Type", mediaType)
 	req.Header.Add("Accept", mediaType)
 	req.Header.Add("User-Agent", c.UserAgent)
+	if encoding != "" {
+		req.Header.Add("Content-Encoding", encoding)
+	}
+	if c.Compression {
+		req.Header.Add("Accept-Encoding", "gzip, deflate")
+	}
 	if c.ApiKey != "" {
 		req.Header.Add("X-Api-Key", c.ApiKey)
 	}
+	applyHeaders(ctx, req)
 
 	c.logDebugOnly(func() {
 		c.Log.Debugf("%s %s %s", req.Method, req.URL, req.Proto)
@@ -213,7 +394,48 @@ func (c *Client) NewRequest(ctx context.Context, method, urlStr string, body int
 }
 
 func (c *Client) handleResponse(resp *http.Response, v interface{}) error {
-	return json.NewDecoder(resp.Body).Decode(v)
+	if !c.isBuffered() {
+		return json.NewDecoder(resp.Body).Decode(v)
+	}
+	buf, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return c.unmarshal(buf, v)
+}
+
+// isBuffered returns true when responses must be decoded from a buffer to
+// retain raw JSON or check for unknown fields.
+func (c *Client) isBuffered() bool {
+	return c.RetainRawJSON || c.isFieldCheckEnabled()
+}
+
+// unmarshal decodes buffered JSON like handleResponse.
+func (c *Client) unmarshal(buf []byte, v interface{}) error {
+	if err := json.Unmarshal(buf, v); err != nil {
+		return err
+	}
+	c.retainRaw(buf, v)
+	return c.checkFields(buf, v)
+}
+
+// decodeNext decodes the next JSON value from stream dec like unmarshal.
+func (c *Client) decodeNext(dec *json.Decoder, v interface{}) error {
+	if !c.isBuffered() {
+		return dec.Decode(v)
+	}
+	var buf json.RawMessage
+	if err := dec.Decode(&buf); err != nil {
+		return err
+	}
+	return c.unmarshal(buf, v)
+}
+
+// retainRaw stores raw JSON in v when enabled, see RetainRawJSON.
+func (c *Client) retainRaw(buf []byte, v interface{}) {
+	if c.RetainRawJSON {
+		retainRaw(buf, reflect.ValueOf(v))
+	}
 }
 
 func (c *Client) handleResponseMonitor(ctx context.Context, resp *http.Response, mon Monitor) {
@@ -228,7 +450,7 @@ func (c *Client) handleResponseMonitor(ctx context.Context, resp *http.Response,
 
 	for {
 		chunkVal := mon.New()
-		if err := dec.Decode(chunkVal); err != nil {
+		if err := c.decodeNext(dec, chunkVal); err != nil {
 			select {
 			case <-mon.Closed():
 				return
@@ -256,7 +478,7 @@ func (c *Client) handleResponseMonitor(ctx context.Context, resp *http.Response,
 
 // Do retrieves values from the API and marshals them into the provided interface.
 func (c *Client) Do(req *http.Request, v interface{}) error {
-	resp, err := c.client.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		if e, ok := err.(*url.Error); ok {
 			return e.Err
@@ -292,7 +514,7 @@ func (c *Client) Do(req *http.Request, v interface{}) error {
 // DoAsync retrieves values from the API and sends responses using the provided monitor.
 func (c *Client) DoAsync(req *http.Request, mon Monitor) error {
 	//nolint:bodyclose
-	resp, err := c.client.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		if e, ok := err.(*url.Error); ok {
 			return e.Err
@@ -333,6 +555,7 @@ func (c *Client) handleError(resp *http.Response) error {
 		status:     resp.Status,
 		statusCode: resp.StatusCode,
 		body:       bytes.ReplaceAll(body, []byte("\n"), []byte{}),
+		retryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
 	}
 
 	if resp.StatusCode < 500 || !strings.Contains(resp.Header.Get("Content-Type"), "application/json") {
@@ -0,0 +1,259 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+)
+
+// LinkKind names the heuristic which relates two addresses.
+type LinkKind string
+
+const (
+	LinkFunding      LinkKind = "funding"       // transfer allocated a new implicit account
+	LinkManager      LinkKind = "manager"       // source originated a contract
+	LinkConsensusKey LinkKind = "consensus_key" // baker registered a consensus key
+	LinkDrain        LinkKind = "drain"         // consensus key drained a baker to a destination
+)
+
+// AllLinkKinds lists all supported clustering heuristics.
+var AllLinkKinds = []LinkKind{LinkFunding, LinkManager, LinkConsensusKey, LinkDrain}
+
+// Link is a heuristic relationship between two addresses found in an
+// operation.
+type Link struct {
+	From  mavryk.Address `json:"from"`
+	To    mavryk.Address `json:"to"`
+	Kind  LinkKind       `json:"kind"`
+	Level int64          `json:"level"`
+	Hash  mavryk.OpHash  `json:"hash"`
+}
+
+// ClusterGraph groups addresses by heuristic relationships found in
+// operation history. Feed blocks in any order with AddBlock, then query
+// clusters. Only successful operations create links.
+//
+// Heuristics are hints and not proof of common ownership. Funding links for
+// example also connect exchange hot wallets to all their customers, so
+// callers should exclude such addresses with Ignore. ClusterGraph is not
+// safe for concurrent use.
+type ClusterGraph struct {
+	Links  []Link
+	kinds  map[LinkKind]bool
+	ignore map[mavryk.Address]bool
+	parent map[mavryk.Address]mavryk.Address
+}
+
+// NewClusterGraph creates a cluster graph which uses heuristics kinds or
+// all heuristics when kinds is empty.
+func NewClusterGraph(kinds ...LinkKind) *ClusterGraph {
+	if len(kinds) == 0 {
+		kinds = AllLinkKinds
+	}
+	g := &ClusterGraph{
+		Links:  make([]Link, 0),
+		kinds:  make(map[LinkKind]bool),
+		ignore: make(map[mavryk.Address]bool),
+		parent: make(map[mavryk.Address]mavryk.Address),
+	}
+	for _, k := range kinds {
+		g.kinds[k] = true
+	}
+	return g
+}
+
+// Ignore excludes addresses like exchange wallets or faucets from linking.
+// Links added before are kept.
+func (g *ClusterGraph) Ignore(addrs ...mavryk.Address) *ClusterGraph {
+	for _, a := range addrs {
+		g.ignore[a] = true
+	}
+	return g
+}
+
+// AddBlock adds links from all operations in block b.
+func (g *ClusterGraph) AddBlock(b *Block) {
+	for _, list := range b.Operations {
+		for _, op := range list {
+			g.AddOperation(b.GetLevel(), op)
+		}
+	}
+}
+
+// AddOperation adds links from operation op included at level.
+func (g *ClusterGraph) AddOperation(level int64, op *Operation) {
+	link := func(from, to mavryk.Address, kind LinkKind) {
+		g.AddLink(Link{From: from, To: to, Kind: kind, Level: level, Hash: op.Hash})
+	}
+	for _, v := range op.Contents {
+		switch o := v.(type) {
+		case *Transaction:
+			if !o.Result().IsSuccess() {
+				continue
+			}
+			if o.Result().Allocated && o.Destination.IsEOA() {
+				link(o.Source, o.Destination, LinkFunding)
+			}
+			g.addInternal(o.Meta().InternalResults, link)
+		case *Origination:
+			if !o.Result().IsSuccess() {
+				continue
+			}
+			for _, c := range o.Result().OriginatedContracts {
+				link(o.Source, c, LinkManager)
+			}
+			g.addInternal(o.Meta().InternalResults, link)
+		case *UpdateConsensusKey:
+			if !o.Result().IsSuccess() || !o.Pk.IsValid() {
+				continue
+			}
+			link(o.Source, o.Pk.Address(), LinkConsensusKey)
+		case *DrainDelegate:
+			link(o.ConsensusKey, o.Delegate, LinkConsensusKey)
+			link(o.Delegate, o.Destination, LinkDrain)
+		}
+	}
+}
+
+func (g *ClusterGraph) addInternal(list []*InternalResult, link func(from, to mavryk.Address, kind LinkKind)) {
+	for _, v := range list {
+		if !v.Result.IsSuccess() {
+			continue
+		}
+		switch v.Kind {
+		case mavryk.OpTypeTransaction:
+			if v.Destination != nil && v.Result.Allocated && v.Destination.IsEOA() {
+				link(v.Source, *v.Destination, LinkFunding)
+			}
+		case mavryk.OpTypeOrigination:
+			for _, c := range v.Result.OriginatedContracts {
+				link(v.Source, c, LinkManager)
+			}
+		}
+	}
+}
+
+// AddLink adds link l unless its kind is disabled, it links an address to
+// itself or one of the addresses is ignored.
+func (g *ClusterGraph) AddLink(l Link) {
+	if !g.kinds[l.Kind] || !l.From.IsValid() || !l.To.IsValid() || l.From.Equal(l.To) {
+		return
+	}
+	if g.ignore[l.From] || g.ignore[l.To] {
+		return
+	}
+	g.Links = append(g.Links, l)
+	g.union(l.From, l.To)
+}
+
+// Related returns true when a and b are in the same cluster.
+func (g *ClusterGraph) Related(a, b mavryk.Address) bool {
+	return g.find(a).Equal(g.find(b))
+}
+
+// Cluster returns all addresses in the cluster of addr sorted by address,
+// or nil when addr has no links.
+func (g *ClusterGraph) Cluster(addr mavryk.Address) []mavryk.Address {
+	if _, ok := g.parent[addr]; !ok {
+		return nil
+	}
+	root := g.find(addr)
+	var res []mavryk.Address
+	for a := range g.parent {
+		if g.find(a).Equal(root) {
+			res = append(res, a)
+		}
+	}
+	sortAddresses(res)
+	return res
+}
+
+// Clusters returns all clusters of linked addresses, largest first.
+func (g *ClusterGraph) Clusters() [][]mavryk.Address {
+	groups := make(map[mavryk.Address][]mavryk.Address)
+	for a := range g.parent {
+		root := g.find(a)
+		groups[root] = append(groups[root], a)
+	}
+	res := make([][]mavryk.Address, 0, len(groups))
+	for _, v := range groups {
+		sortAddresses(v)
+		res = append(res, v)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if len(res[i]) != len(res[j]) {
+			return len(res[i]) > len(res[j])
+		}
+		return res[i][0].String() < res[j][0].String()
+	})
+	return res
+}
+
+// LinksOf returns all links which touch addr.
+func (g *ClusterGraph) LinksOf(addr mavryk.Address) []Link {
+	var res []Link
+	for _, l := range g.Links {
+		if l.From.Equal(addr) || l.To.Equal(addr) {
+			res = append(res, l)
+		}
+	}
+	return res
+}
+
+func (g *ClusterGraph) find(a mavryk.Address) mavryk.Address {
+	p, ok := g.parent[a]
+	if !ok {
+		return a
+	}
+	if p.Equal(a) {
+		return a
+	}
+	root := g.find(p)
+	g.parent[a] = root
+	return root
+}
+
+func (g *ClusterGraph) union(a, b mavryk.Address) {
+	if _, ok := g.parent[a]; !ok {
+		g.parent[a] = a
+	}
+	if _, ok := g.parent[b]; !ok {
+		g.parent[b] = b
+	}
+	ra, rb := g.find(a), g.find(b)
+	if !ra.Equal(rb) {
+		g.parent[rb] = ra
+	}
+}
+
+func sortAddresses(list []mavryk.Address) {
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].String() < list[j].String()
+	})
+}
+
+// BuildClusterGraph scans blocks from level from to level to (inclusive)
+// and returns the resulting cluster graph. Use heuristics kinds or all
+// heuristics when kinds is empty. Scanning long ranges requires many block
+// requests, prefer feeding blocks from an existing indexer via AddBlock.
+func (c *Client) BuildClusterGraph(ctx context.Context, from, to int64, kinds ...LinkKind) (*ClusterGraph, error) {
+	g := NewClusterGraph(kinds...)
+	p := NewBlockPrefetcher(ctx, c, from, to)
+	defer p.Close()
+	for {
+		b, err := p.Next(ctx)
+		if errors.Is(err, io.EOF) {
+			return g, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		g.AddBlock(b)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// send sends req with the HTTP client and decompresses the response when
+// compression is enabled. Setting Accept-Encoding explicitly disables the
+// transparent gzip support of http.Transport, so decoding happens here.
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	resp, err := c.client.Do(req)
+	if err != nil || !c.Compression {
+		return resp, err
+	}
+	var open func(io.Reader) (io.ReadCloser, error)
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "gzip", "x-gzip":
+		open = func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) }
+	case "deflate":
+		open = zlib.NewReader
+	default:
+		return resp, nil
+	}
+	resp.Body = &decompressBody{body: resp.Body, open: open}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return resp, nil
+}
+
+// decompressBody lazily opens a decompressor on first read, so empty
+// bodies and streams which have not sent data yet do not fail.
+type decompressBody struct {
+	body io.ReadCloser
+	open func(io.Reader) (io.ReadCloser, error)
+	r    io.ReadCloser
+	err  error
+}
+
+func (b *decompressBody) Read(p []byte) (int, error) {
+	if b.r == nil && b.err == nil {
+		b.r, b.err = b.open(b.body)
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	return b.r.Read(p)
+}
+
+func (b *decompressBody) Close() error {
+	if b.r != nil {
+		b.r.Close()
+	}
+	return b.body.Close()
+}
+
+// gzipBody compresses a request body.
+func gzipBody(buf []byte) (*bytes.Buffer, error) {
+	out := new(bytes.Buffer)
+	zw := gzip.NewWriter(out)
+	if _, err := zw.Write(buf); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
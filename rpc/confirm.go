@@ -0,0 +1,74 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc
+
+import (
+	"context"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+)
+
+// WaitConfirmations waits until operation hash is included in the canonical
+// chain with n confirmations and returns its receipt. The inclusion block
+// counts as first confirmation. Branch is the block hash the operation was
+// forged against, blocks since branch are searched first, so the operation
+// is found even when it was included before the call.
+//
+// Reorgs are tolerated. When the inclusion block is reverted, the operation
+// is searched again in the new branch. Fails with TTLExceeded when the
+// operation was not included before its branch expired.
+func (c *Client) WaitConfirmations(ctx context.Context, hash mavryk.OpHash, branch mavryk.BlockHash, n int64) (*Receipt, error) {
+	if n <= 0 {
+		n = 1
+	}
+	head, err := c.GetBlockHeader(ctx, branch)
+	if err != nil {
+		return nil, err
+	}
+	maxLevel := head.Level + c.ChainParams().MaxOperationsTTL
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	f := c.NewBlockFollower(ctx).WithTip(head.LogEntry())
+	defer f.Close()
+
+	var rec *Receipt
+	for {
+		e, err := f.Recv(ctx)
+		if err != nil {
+			return nil, err
+		}
+		switch e.Kind {
+		case FollowRollback:
+			if rec != nil && rec.Height > e.Block.Level {
+				c.Log.Debugf("rpc: op %s inclusion block %s reverted", hash, rec.Block)
+				rec = nil
+			}
+			continue
+		case FollowApply:
+			if rec == nil {
+				rec, err = c.findBlockOperation(ctx, e.Block.Hash, hash)
+				if err != nil {
+					return nil, err
+				}
+				if rec != nil {
+					rec.Block, rec.Height = e.Block.Hash, e.Block.Level
+				}
+			}
+		}
+		if rec == nil {
+			if e.Block.Level >= maxLevel {
+				return nil, TTLExceeded
+			}
+			continue
+		}
+		if e.Block.Level-rec.Height+1 >= n {
+			rec.Op, err = c.GetBlockOperation(ctx, rec.Block, rec.List, rec.Pos)
+			if err != nil {
+				return nil, err
+			}
+			return rec, nil
+		}
+	}
+}
@@ -1,10 +1,11 @@
-// Copyright (c) 2020-2023 Blockwatch Data Inc.
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
 // Author: alex@blockwatch.cc
 
 package rpc
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strconv"
 	"time"
@@ -12,9 +13,9 @@ import (
 	"github.com/mavryk-network/mvgo/mavryk"
 )
 
-// Constants represents only a limited subset of Tezos chain configuration params
-// which are required by MvGo. Users must define custom structs to read other
-// constants as needed.
+// Constants represents Tezos chain configuration params. Fields which are not
+// modelled are kept in Extra, users may decode them from there or define custom
+// structs to read other constants as needed.
 type Constants struct {
 	PreservedCycles              int64    `json:"preserved_cycles"`
 	BlocksPerCycle               int64    `json:"blocks_per_cycle"`
@@ -35,6 +36,95 @@ type Constants struct {
 	// New in v12
 	MaxOperationsTimeToLive int64 `json:"max_operations_time_to_live"`
 	BlocksPerStakeSnapshot  int64 `json:"blocks_per_stake_snapshot"`
+	ConsensusCommitteeSize  int   `json:"consensus_committee_size"`
+	ConsensusThreshold      int   `json:"consensus_threshold"`
+
+	// v019+
+	ConsensusRightsDelay              int64            `json:"consensus_rights_delay"`
+	BlocksPreservationCycles          int64            `json:"blocks_preservation_cycles"`
+	DelegateParametersActivationDelay int64            `json:"delegate_parameters_activation_delay"`
+	BlocksPerCommitment               int64            `json:"blocks_per_commitment"`
+	NonceRevelationThreshold          int64            `json:"nonce_revelation_threshold"`
+	CyclesPerVotingPeriod             int64            `json:"cycles_per_voting_period"`
+	MinimalStake                      int64            `json:"minimal_stake,string"`
+	MinimalFrozenStake                int64            `json:"minimal_frozen_stake,string"`
+	DelayIncrementPerRound            int              `json:"delay_increment_per_round,string"`
+	MinimalParticipationRatio         Ratio            `json:"minimal_participation_ratio"`
+	LimitOfDelegationOverBaking       int64            `json:"limit_of_delegation_over_baking"`
+	MaxSlashingPeriod                 int64            `json:"max_slashing_period"`
+	QuorumMin                         int64            `json:"quorum_min"`
+	QuorumMax                         int64            `json:"quorum_max"`
+	MinProposalQuorum                 int64            `json:"min_proposal_quorum"`
+	IssuanceWeights                   *IssuanceWeights `json:"issuance_weights,omitempty"`
+	AdaptiveRewardsParams             *AdaptiveRewards `json:"adaptive_rewards_params,omitempty"`
+	Dal                               *DalParams       `json:"dal_parametric,omitempty"`
+	AdaptiveIssuanceActivationVote    bool             `json:"adaptive_issuance_activation_vote_enable"`
+	AutostakingEnable                 bool             `json:"autostaking_enable"`
+	AdaptiveIssuanceForceActivation   bool             `json:"adaptive_issuance_force_activation"`
+	NsEnable                          bool             `json:"ns_enable"`
+	DirectTicketSpendingEnable        bool             `json:"direct_ticket_spending_enable"`
+
+	// per-block votes
+	LiquidityBakingToggleEmaThreshold  int64 `json:"liquidity_baking_toggle_ema_threshold"`
+	AdaptiveIssuanceLaunchEmaThreshold int64 `json:"adaptive_issuance_launch_ema_threshold"`
+
+	// Extra holds all constants which are not modelled above.
+	Extra map[string]json.RawMessage `json:"-"`
+
+	Raw json.RawMessage `json:"-"` // optional, see Client.RetainRawJSON
+}
+
+// Ratio is an exact fraction used in protocol constants.
+type Ratio struct {
+	Numerator   Int64orString `json:"numerator"`
+	Denominator Int64orString `json:"denominator"`
+}
+
+// Float64 returns the ratio as floating point number.
+func (r Ratio) Float64() float64 {
+	if r.Denominator == 0 {
+		return 0
+	}
+	return float64(r.Numerator) / float64(r.Denominator)
+}
+
+// IssuanceWeights controls how issuance is split among reward kinds (v019+).
+type IssuanceWeights struct {
+	BaseTotalIssuedPerMinute       int64 `json:"base_total_issued_per_minute,string"`
+	BakingRewardFixedPortionWeight int64 `json:"baking_reward_fixed_portion_weight"`
+	BakingRewardBonusWeight        int64 `json:"baking_reward_bonus_weight"`
+	AttestingRewardWeight          int64 `json:"attesting_reward_weight"`
+	SeedNonceRevelationTipWeight   int64 `json:"seed_nonce_revelation_tip_weight"`
+	VdfRevelationTipWeight         int64 `json:"vdf_revelation_tip_weight"`
+}
+
+// AdaptiveRewards holds adaptive issuance coefficients (v019+).
+type AdaptiveRewards struct {
+	IssuanceRatioFinalMin   Ratio         `json:"issuance_ratio_final_min"`
+	IssuanceRatioFinalMax   Ratio         `json:"issuance_ratio_final_max"`
+	IssuanceRatioInitialMin Ratio         `json:"issuance_ratio_initial_min"`
+	IssuanceRatioInitialMax Ratio         `json:"issuance_ratio_initial_max"`
+	InitialPeriod           int64         `json:"initial_period"`
+	TransitionPeriod        int64         `json:"transition_period"`
+	MaxBonus                Int64orString `json:"max_bonus"`
+	GrowthRate              Ratio         `json:"growth_rate"`
+	CenterDz                Ratio         `json:"center_dz"`
+	RadiusDz                Ratio         `json:"radius_dz"`
+}
+
+// DalParams holds data availability layer parameters (v019+).
+type DalParams struct {
+	FeatureEnable        bool  `json:"feature_enable"`
+	IncentivesEnable     bool  `json:"incentives_enable"`
+	NumberOfSlots        int   `json:"number_of_slots"`
+	AttestationLag       int   `json:"attestation_lag"`
+	AttestationThreshold int   `json:"attestation_threshold"`
+	RedundancyFactor     int   `json:"redundancy_factor"`
+	PageSize             int   `json:"page_size"`
+	SlotSize             int   `json:"slot_size"`
+	NumberOfShards       int   `json:"number_of_shards"`
+	MinimalParticipation Ratio `json:"minimal_participation_ratio"`
+	RewardsRatio         Ratio `json:"rewards_ratio"`
 }
 
 // GetConstants returns chain configuration constants at block id
@@ -71,15 +161,17 @@ func (c *Client) GetParams(ctx context.Context, id BlockID) (*mavryk.Params, err
 	if err != nil {
 		return nil, err
 	}
-	ver, err := c.GetVersionInfo(ctx)
-	if err != nil {
-		return nil, err
-	}
 	p := con.MapToChainParams().
 		WithChainId(c.ChainId).
 		WithProtocol(meta.Protocol).
-		WithNetwork(ver.NetworkVersion.ChainName).
 		WithBlock(meta.GetLevel())
+	// the network name is informational, nodes without version RPC
+	// (e.g. behind restrictive proxies) keep it unknown
+	if ver, err := c.GetVersionInfo(ctx); err != nil {
+		c.Log.Warnf("rpc: cannot read network name: %v", err)
+	} else {
+		p.WithNetwork(ver.NetworkVersion.ChainName)
+	}
 	return p, nil
 }
 
@@ -98,6 +190,11 @@ func (c Constants) MapToChainParams() *mavryk.Params {
 		MinimalBlockDelay:            time.Duration(c.MinimalBlockDelay) * time.Second,
 	}
 
+	// v019+ replaced preserved cycles
+	if p.PreservedCycles == 0 {
+		p.PreservedCycles = c.ConsensusRightsDelay
+	}
+
 	// default for old protocols
 	if p.MaxOperationsTTL == 0 {
 		p.MaxOperationsTTL = 120
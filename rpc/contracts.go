@@ -61,6 +61,8 @@ type ContractInfo struct {
 		} `json:"requests"`
 	} `json:"unstake_requests"`
 	UnstakedFrozenDeposits []UnstakedDeposit `json:"unstaked_frozen_deposits"`
+
+	Raw json.RawMessage `json:"-"` // optional, see Client.RetainRawJSON
 }
 
 type UnstakedDeposit struct {
@@ -170,7 +172,7 @@ func (c *Client) GetNormalizedScript(ctx context.Context, addr mavryk.Address, m
 	}{
 		Mode: mode,
 	}
-	err := c.Post(ctx, u, &postData, s)
+	err := c.postIdempotent(ctx, u, &postData, s)
 	if err != nil {
 		return nil, err
 	}
@@ -200,7 +202,7 @@ func (c *Client) GetContractStorageNormalized(ctx context.Context, addr mavryk.A
 		Mode: mode,
 	}
 	prim := micheline.Prim{}
-	err := c.Post(ctx, u, &postData, &prim)
+	err := c.postIdempotent(ctx, u, &postData, &prim)
 	if err != nil {
 		return micheline.InvalidPrim, err
 	}
@@ -0,0 +1,187 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/mavryk-network/mvgo/codec"
+	"github.com/mavryk-network/mvgo/mavryk"
+	"github.com/mavryk-network/mvgo/signer"
+)
+
+// ErrSkipped is returned for jobs which were not sent because an earlier job
+// of the same source failed and the coordinator runs in fail fast mode.
+var ErrSkipped = errors.New("rpc: skipped after previous error")
+
+// SendJob is a single operation sent from Source by a Coordinator.
+type SendJob struct {
+	Source mavryk.Address
+	Op     *codec.Op
+}
+
+// SendResult is the outcome of a SendJob. Index refers to the position of
+// the job in the list passed to Coordinator.Run.
+type SendResult struct {
+	Index   int
+	Source  mavryk.Address
+	Receipt *Receipt
+	Err     error
+}
+
+// Coordinator sends operations from many source accounts concurrently.
+// Jobs of the same source are sent strictly in order, each one after the
+// previous one was included, since a source may only have a single manager
+// operation in the mempool. Different sources run in parallel up to
+// Concurrency and injections are globally rate limited to Rate per second.
+//
+// This pattern is used by payout engines and airdrop tools which split
+// large volumes of transfers across multiple funding accounts.
+type Coordinator struct {
+	Client      *Client
+	Signer      signer.Signer // signer for all sources, defaults to client signer
+	Options     *CallOptions  // options for all jobs, defaults to DefaultOptions
+	Concurrency int           // max number of sources sending in parallel, 0 = unlimited
+	Rate        float64       // max injections per second across all sources, 0 = unlimited
+	FailFast    bool          // skip remaining jobs of a source after an error
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+func NewCoordinator(c *Client, s signer.Signer) *Coordinator {
+	return &Coordinator{
+		Client: c,
+		Signer: s,
+	}
+}
+
+func (c *Coordinator) WithConcurrency(n int) *Coordinator {
+	c.Concurrency = n
+	return c
+}
+
+func (c *Coordinator) WithRate(perSecond float64) *Coordinator {
+	c.Rate = perSecond
+	return c
+}
+
+func (c *Coordinator) WithOptions(opts *CallOptions) *Coordinator {
+	c.Options = opts
+	return c
+}
+
+// Run sends all jobs and returns one result per job in input order. Run
+// returns when all jobs are processed or ctx is canceled. Jobs not sent due
+// to cancelation report the context error.
+func (c *Coordinator) Run(ctx context.Context, jobs []SendJob) []SendResult {
+	res := make([]SendResult, len(jobs))
+
+	// group jobs by source, keep order
+	var (
+		order  []string
+		groups = make(map[string][]int)
+	)
+	for i, job := range jobs {
+		res[i] = SendResult{Index: i, Source: job.Source}
+		key := job.Source.String()
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], i)
+	}
+
+	var (
+		wg  sync.WaitGroup
+		sem chan struct{}
+	)
+	if c.Concurrency > 0 {
+		sem = make(chan struct{}, c.Concurrency)
+	}
+	for _, key := range order {
+		wg.Add(1)
+		go func(idx []int) {
+			defer wg.Done()
+			if sem != nil {
+				select {
+				case sem <- struct{}{}:
+					defer func() { <-sem }()
+				case <-ctx.Done():
+					for _, i := range idx {
+						res[i].Err = ctx.Err()
+					}
+					return
+				}
+			}
+			c.runSource(ctx, jobs, idx, res)
+		}(groups[key])
+	}
+	wg.Wait()
+	return res
+}
+
+// runSource sends jobs of a single source sequentially.
+func (c *Coordinator) runSource(ctx context.Context, jobs []SendJob, idx []int, res []SendResult) {
+	var failed bool
+	for _, i := range idx {
+		switch {
+		case ctx.Err() != nil:
+			res[i].Err = ctx.Err()
+			continue
+		case failed && c.FailFast:
+			res[i].Err = ErrSkipped
+			continue
+		}
+		if err := c.wait(ctx); err != nil {
+			res[i].Err = err
+			continue
+		}
+		opts := DefaultOptions
+		if c.Options != nil {
+			opts = *c.Options
+		}
+		if c.Signer != nil {
+			opts.Signer = c.Signer
+		}
+		opts.Sender = jobs[i].Source
+		// next operation of this source needs an updated counter
+		if opts.Confirmations < 1 {
+			opts.Confirmations = 1
+		}
+		res[i].Receipt, res[i].Err = c.Client.Send(ctx, jobs[i].Op, &opts)
+		failed = failed || res[i].Err != nil
+	}
+}
+
+// wait blocks until the global rate limit allows the next injection.
+func (c *Coordinator) wait(ctx context.Context) error {
+	if c.Rate <= 0 {
+		return nil
+	}
+	interval := time.Duration(float64(time.Second) / c.Rate)
+	c.mu.Lock()
+	now := time.Now()
+	at := c.next
+	if at.Before(now) {
+		at = now
+	}
+	c.next = at.Add(interval)
+	c.mu.Unlock()
+
+	d := time.Until(at)
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
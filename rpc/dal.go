@@ -9,6 +9,7 @@ import "github.com/mavryk-network/mvgo/mavryk"
 var (
 	_ TypedOperation = (*DalPublishSlotHeader)(nil)
 	_ TypedOperation = (*DalAttestation)(nil)
+	_ TypedOperation = (*DalEntrapmentEvidence)(nil)
 )
 
 type DalPublishSlotHeader struct {
@@ -27,3 +28,17 @@ type DalAttestation struct {
 	Attestation mavryk.Z       `json:"attestation"`
 	Level       int64          `json:"level"`
 }
+
+type DalEntrapmentEvidence struct {
+	Generic
+	Attestation    InlinedEndorsement `json:"attestation"`
+	ConsensusSlot  int                `json:"consensus_slot"`
+	SlotIndex      int                `json:"slot_index"`
+	ShardWithProof struct {
+		Shard struct {
+			Index int               `json:"index"`
+			Share []mavryk.HexBytes `json:"share"`
+		} `json:"shard"`
+		Proof mavryk.HexBytes `json:"proof"`
+	} `json:"shard_with_proof"`
+}
@@ -0,0 +1,219 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"unicode/utf8"
+
+	"github.com/mavryk-network/mvgo/codec"
+	"github.com/mavryk-network/mvgo/mavryk"
+)
+
+// DalSlotStatus is the attestation status of a published slot.
+type DalSlotStatus string
+
+const (
+	DalSlotWaitingAttestation DalSlotStatus = "waiting_attestation"
+	DalSlotAttested           DalSlotStatus = "attested"
+	DalSlotUnattested         DalSlotStatus = "unattested"
+	DalSlotUnpublished        DalSlotStatus = "unpublished"
+)
+
+// DalSlotCommitment is returned by the DAL node after posting slot data.
+type DalSlotCommitment struct {
+	Commitment mavryk.DalCommitment `json:"commitment"`
+	Proof      mavryk.HexBytes      `json:"commitment_proof"`
+}
+
+// PublishOp returns an operation which publishes the commitment for slot
+// index at level on-chain. Source, counter and limits are set when the
+// operation is sent.
+func (s DalSlotCommitment) PublishOp(level int64, index int) *codec.Op {
+	return codec.NewOp().WithContents(&codec.DalPublishSlotHeader{
+		Level:      int32(level),
+		Index:      byte(index),
+		Commitment: s.Commitment.Bytes(),
+		Proof:      s.Proof,
+	})
+}
+
+// DalShard is a single shard of a slot with its shard index.
+type DalShard struct {
+	Index int               `json:"index"`
+	Share []mavryk.HexBytes `json:"share"`
+}
+
+// DalAttestableSlots lists which slots published at PublishedLevel an
+// attester can attest. InCommittee is false when the attester has no
+// shards assigned at this level.
+type DalAttestableSlots struct {
+	Kind           string `json:"kind"`
+	Slots          []bool `json:"attestable_slots_set"`
+	PublishedLevel int64  `json:"published_level"`
+}
+
+// InCommittee returns true when the attester is part of the DAL committee.
+func (s DalAttestableSlots) InCommittee() bool {
+	return s.Kind != "not_in_committee"
+}
+
+// DalHealth is the health status of a DAL node and its components.
+type DalHealth struct {
+	Status string `json:"status"`
+	Checks []struct {
+		Name   string `json:"name"`
+		Status string `json:"status"`
+	} `json:"checks"`
+}
+
+// dalBytes is the JSON encoding the DAL node uses for slot content. Valid
+// UTF-8 is sent as string, other data as list of bytes.
+type dalBytes []byte
+
+func (b dalBytes) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+	list := make([]int, len(b))
+	for i, v := range b {
+		list[i] = int(v)
+	}
+	return json.Marshal(struct {
+		Bytes []int `json:"invalid_utf8_string"`
+	}{list})
+}
+
+func (b *dalBytes) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*b = dalBytes(s)
+		return nil
+	}
+	var v struct {
+		Bytes []int `json:"invalid_utf8_string"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	buf := make([]byte, len(v.Bytes))
+	for i, x := range v.Bytes {
+		buf[i] = byte(x)
+	}
+	*b = buf
+	return nil
+}
+
+// DalClient is a client for the REST API of a DAL node. Requests are sent
+// through an internal RPC client, so client options like WithRetry,
+// WithRateLimit, WithMetrics and request interceptors apply to DAL requests
+// as well. Node specific settings like chain, params and caching are not
+// used.
+type DalClient struct {
+	c *Client
+}
+
+// NewDalClient returns a new DAL node client. Options are applied as in
+// NewClient.
+func NewDalClient(baseURL string, httpClient *http.Client, opts ...ClientOption) (*DalClient, error) {
+	c, err := NewClient(baseURL, httpClient, opts...)
+	if err != nil {
+		return nil, err
+	}
+	c.Cache = nil
+	return &DalClient{c: c}, nil
+}
+
+// BaseURL returns the DAL node's base URL.
+func (c *DalClient) BaseURL() *url.URL {
+	return c.c.BaseURL
+}
+
+// Close closes idle connections.
+func (c *DalClient) Close() {
+	c.c.client.CloseIdleConnections()
+}
+
+// GetHealth returns the DAL node's health status.
+func (c *DalClient) GetHealth(ctx context.Context) (*DalHealth, error) {
+	h := &DalHealth{}
+	if err := c.c.Get(ctx, "health", h); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// PostSlot sends slot data to the DAL node which computes its commitment,
+// commitment proof and shards. Use the result to publish the slot header
+// on-chain, e.g. with PublishOp. Data shorter than the slot size is padded
+// by the node.
+func (c *DalClient) PostSlot(ctx context.Context, data []byte, index int) (*DalSlotCommitment, error) {
+	u := fmt.Sprintf("slots?slot_index=%d", index)
+	res := &DalSlotCommitment{}
+	if err := c.c.Post(ctx, u, dalBytes(data), res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// GetSlotCommitment returns the commitment of the slot published at level and index.
+func (c *DalClient) GetSlotCommitment(ctx context.Context, level int64, index int) (mavryk.DalCommitment, error) {
+	u := fmt.Sprintf("levels/%d/slots/%d/commitment", level, index)
+	var cm mavryk.DalCommitment
+	err := c.c.Get(ctx, u, &cm)
+	return cm, err
+}
+
+// GetSlotStatus returns the attestation status of the slot published at level and index.
+func (c *DalClient) GetSlotStatus(ctx context.Context, level int64, index int) (DalSlotStatus, error) {
+	u := fmt.Sprintf("levels/%d/slots/%d/status", level, index)
+	var s DalSlotStatus
+	err := c.c.Get(ctx, u, &s)
+	return s, err
+}
+
+// GetSlotContent returns the content of the slot published at level and index.
+func (c *DalClient) GetSlotContent(ctx context.Context, level int64, index int) ([]byte, error) {
+	u := fmt.Sprintf("levels/%d/slots/%d/content", level, index)
+	var b dalBytes
+	if err := c.c.Get(ctx, u, &b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// GetSlotShard returns a single shard of the slot published at level and index.
+func (c *DalClient) GetSlotShard(ctx context.Context, level int64, index, shard int) (*DalShard, error) {
+	u := fmt.Sprintf("levels/%d/slots/%d/shards/%d/content", level, index, shard)
+	s := &DalShard{}
+	if err := c.c.Get(ctx, u, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// GetCommitmentProof returns the proof for a commitment known to the DAL node.
+func (c *DalClient) GetCommitmentProof(ctx context.Context, cm mavryk.DalCommitment) (mavryk.HexBytes, error) {
+	u := fmt.Sprintf("commitments/%s/proof", cm)
+	var p mavryk.HexBytes
+	err := c.c.Get(ctx, u, &p)
+	return p, err
+}
+
+// GetAttestableSlots returns which slots attester can attest at level.
+func (c *DalClient) GetAttestableSlots(ctx context.Context, attester mavryk.Address, level int64) (*DalAttestableSlots, error) {
+	u := fmt.Sprintf("profiles/%s/attested_levels/%d/attestable_slots", attester, level)
+	s := &DalAttestableSlots{}
+	if err := c.c.Get(ctx, u, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mavryk-network/mvgo/codec"
+	"github.com/mavryk-network/mvgo/mavryk"
+	"github.com/mavryk-network/mvgo/rpc"
+)
+
+var testDalCommitment = mavryk.MustParseDalCommitment("sh1N3zXZGmwD8nUJKASG173ByrJVotnxgh9v6P9hLhuWF4AoPCKR1DqSfhhVE2PkLjdqRYDSpX")
+
+// newTestDalNode returns a DAL node simulator which stores posted slots
+// and serves them at level 10.
+func newTestDalNode(t *testing.T) *rpc.DalClient {
+	t.Helper()
+	slots := make(map[string][]byte)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slots", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		slots[r.URL.Query().Get("slot_index")] = body
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"commitment":       testDalCommitment,
+			"commitment_proof": "0a0b",
+		})
+	})
+	mux.HandleFunc("/levels/10/slots/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/levels/10/slots/1/commitment":
+			json.NewEncoder(w).Encode(testDalCommitment)
+		case "/levels/10/slots/1/status":
+			w.Write([]byte(`"attested"`))
+		case "/levels/10/slots/1/content":
+			w.Write(slots["1"])
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	mux.HandleFunc("/profiles/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"kind":"attestable_slots_set","attestable_slots_set":[false,true],"published_level":10}`))
+	})
+	srv := httptest.NewServer(mux)
+	c, err := rpc.NewDalClient(srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		c.Close()
+		srv.Close()
+	})
+	return c
+}
+
+func TestDalClient(t *testing.T) {
+	c := newTestDalNode(t)
+	ctx := context.Background()
+
+	// binary data round-trips through the invalid UTF-8 encoding
+	data := []byte{0xff, 0x00, 0x01}
+	res, err := c.PostSlot(ctx, data, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Commitment.Equal(testDalCommitment) || !bytes.Equal(res.Proof, []byte{0xa, 0xb}) {
+		t.Errorf("bad slot commitment %#v", res)
+	}
+	content, err := c.GetSlotContent(ctx, 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(content, data) {
+		t.Errorf("want content %x, have %x", data, content)
+	}
+
+	// text data is sent as string
+	if _, err := c.PostSlot(ctx, []byte("hello"), 1); err != nil {
+		t.Fatal(err)
+	}
+	if content, err = c.GetSlotContent(ctx, 10, 1); err != nil || string(content) != "hello" {
+		t.Errorf("want content hello, have %q (%v)", content, err)
+	}
+
+	cm, err := c.GetSlotCommitment(ctx, 10, 1)
+	if err != nil || !cm.Equal(testDalCommitment) {
+		t.Errorf("want commitment %s, have %s (%v)", testDalCommitment, cm, err)
+	}
+	status, err := c.GetSlotStatus(ctx, 10, 1)
+	if err != nil || status != rpc.DalSlotAttested {
+		t.Errorf("want status attested, have %q (%v)", status, err)
+	}
+	if _, err := c.GetSlotStatus(ctx, 10, 2); rpc.ErrorStatus(err) != http.StatusNotFound {
+		t.Errorf("want not found, have %v", err)
+	}
+	slots, err := c.GetAttestableSlots(ctx, testReceiver, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slots.InCommittee() || len(slots.Slots) != 2 || !slots.Slots[1] {
+		t.Errorf("bad attestable slots %#v", slots)
+	}
+
+	// publish operation
+	op := res.PublishOp(10, 1)
+	pub, ok := op.Contents[0].(*codec.DalPublishSlotHeader)
+	if !ok {
+		t.Fatalf("unexpected op %T", op.Contents[0])
+	}
+	if pub.Level != 10 || pub.Index != 1 || !bytes.Equal(pub.Commitment, testDalCommitment.Bytes()) {
+		t.Errorf("bad publish op %#v", pub)
+	}
+}
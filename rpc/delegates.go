@@ -1,10 +1,11 @@
-// Copyright (c) 2020-2022 Blockwatch Data Inc.
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
 // Author: alex@blockwatch.cc
 
 package rpc
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strconv"
 
@@ -38,6 +39,131 @@ type Delegate struct {
 	// v015+
 	ActiveConsensusKey   mavryk.Address `json:"active_consensus_key"`
 	PendingConsensusKeys []CycleKey     `json:"pending_consensus_keys"`
+
+	// v019+
+	MinDelegatedInCurrentCycle *MinDelegated          `json:"min_delegated_in_current_cycle,omitempty"`
+	PendingDenunciations       bool                   `json:"pending_denunciations"`
+	TotalDelegatedStake        int64                  `json:"total_delegated_stake,string"`
+	StakingDenominator         int64                  `json:"staking_denominator,string"`
+	CurrentVotingPower         int64                  `json:"current_voting_power,string"`
+	VotingInfo                 *VotingInfo            `json:"voting_info,omitempty"`
+	Participation              *DelegateParticipation `json:"participation,omitempty"`
+	DalParticipation           *DalParticipation      `json:"dal_participation,omitempty"`
+	ActiveStakingParameters    *StakingParameters     `json:"active_staking_parameters,omitempty"`
+	BakingPower                int64                  `json:"baking_power,string"`
+	OwnFullBalance             int64                  `json:"own_full_balance,string"`
+	OwnStaked                  int64                  `json:"own_staked,string"`
+	OwnDelegated               int64                  `json:"own_delegated,string"`
+	ExternalStaked             int64                  `json:"external_staked,string"`
+	ExternalDelegated          int64                  `json:"external_delegated,string"`
+	IsForbidden                bool                   `json:"is_forbidden"`
+
+	Raw json.RawMessage `json:"-"` // optional, see Client.RetainRawJSON
+}
+
+// MinDelegated is the lowest delegated balance of a delegate in the current
+// cycle and the level at which it was reached.
+type MinDelegated struct {
+	Amount int64      `json:"amount,string"`
+	Level  *LevelInfo `json:"level,omitempty"`
+}
+
+// DelegateParticipation reports a delegate's attestation activity in the
+// current cycle. Attesting rewards are lost when missed slots exceed the
+// difference between expected and minimal cycle activity.
+type DelegateParticipation struct {
+	ExpectedCycleActivity       int64 `json:"expected_cycle_activity"`
+	MinimalCycleActivity        int64 `json:"minimal_cycle_activity"`
+	MissedSlots                 int64 `json:"missed_slots"`
+	MissedLevels                int64 `json:"missed_levels"`
+	RemainingAllowedMissedSlots int64 `json:"remaining_allowed_missed_slots"`
+	ExpectedAttestingRewards    int64 `json:"expected_attesting_rewards,string"`
+
+	// <v019
+	ExpectedEndorsingRewards int64 `json:"expected_endorsing_rewards,string"`
+}
+
+// AllowedMissedSlots returns the total number of slots a delegate may miss
+// in a cycle without losing attesting rewards.
+func (p DelegateParticipation) AllowedMissedSlots() int64 {
+	if n := p.ExpectedCycleActivity - p.MinimalCycleActivity; n > 0 {
+		return n
+	}
+	return 0
+}
+
+// MissedRate returns the percentage of expected slots missed so far.
+func (p DelegateParticipation) MissedRate() float64 {
+	if p.ExpectedCycleActivity == 0 {
+		return 0
+	}
+	return float64(p.MissedSlots) * 100 / float64(p.ExpectedCycleActivity)
+}
+
+// Risk returns the share of allowed missed slots already used in range
+// [0..1]. At 1 any further missed slot costs the attesting rewards for this
+// cycle.
+func (p DelegateParticipation) Risk() float64 {
+	allowed := p.AllowedMissedSlots()
+	switch {
+	case p.ExpectedCycleActivity == 0:
+		return 0
+	case p.MissedSlots >= allowed:
+		return 1
+	default:
+		return float64(p.MissedSlots) / float64(allowed)
+	}
+}
+
+// IsAtRisk returns true when a delegate has used at least threshold
+// (in range [0..1]) of its allowed missed slots.
+func (p DelegateParticipation) IsAtRisk(threshold float64) bool {
+	return p.ExpectedCycleActivity > 0 && p.Risk() >= threshold
+}
+
+// HasLostRewards returns true when a delegate missed too many slots to
+// receive attesting rewards for the current cycle.
+func (p DelegateParticipation) HasLostRewards() bool {
+	return p.ExpectedCycleActivity > 0 && p.MissedSlots > p.AllowedMissedSlots()
+}
+
+// DalParticipation reports a delegate's DAL attestation activity in the
+// current cycle.
+type DalParticipation struct {
+	ExpectedAssignedShardsPerSlot int   `json:"expected_assigned_shards_per_slot"`
+	DelegateAttestedDalSlots      int   `json:"delegate_attested_dal_slots"`
+	DelegateAttestableDalSlots    int   `json:"delegate_attestable_dal_slots"`
+	ExpectedDalRewards            int64 `json:"expected_dal_rewards,string"`
+	SufficientDalParticipation    bool  `json:"sufficient_dal_participation"`
+	Denounced                     bool  `json:"denounced"`
+}
+
+// Rate returns the percentage of attestable DAL slots a delegate attested.
+func (p DalParticipation) Rate() float64 {
+	if p.DelegateAttestableDalSlots == 0 {
+		return 0
+	}
+	return float64(p.DelegateAttestedDalSlots) * 100 / float64(p.DelegateAttestableDalSlots)
+}
+
+// IsActive returns true when the delegate is active and not forbidden from
+// baking.
+func (d Delegate) IsActive() bool {
+	return !d.Deactivated && !d.IsForbidden
+}
+
+// DeactivationRisk returns the number of cycles left before delegate d is
+// deactivated for inactivity when cycle is the current cycle. The result is
+// negative when the grace period has passed.
+func (d Delegate) DeactivationRisk(cycle int64) int64 {
+	return d.GracePeriod - cycle
+}
+
+// IsAtRisk returns true when delegate d has used at least threshold
+// (in range [0..1]) of its allowed missed attestation slots in the current
+// cycle. Delegates without participation info are never at risk.
+func (d Delegate) IsAtRisk(threshold float64) bool {
+	return d.Participation != nil && d.Participation.IsAtRisk(threshold)
 }
 
 type CycleKey struct {
@@ -87,6 +213,31 @@ func (c *Client) GetDelegate(ctx context.Context, addr mavryk.Address, id BlockI
 	return delegate, nil
 }
 
+// GetDelegateParticipation returns a delegate's attestation activity in the
+// current cycle.
+func (c *Client) GetDelegateParticipation(ctx context.Context, addr mavryk.Address, id BlockID) (*DelegateParticipation, error) {
+	u := fmt.Sprintf("chains/main/blocks/%s/context/delegates/%s/participation", id, addr)
+	p := &DelegateParticipation{}
+	if err := c.Get(ctx, u, p); err != nil {
+		return nil, err
+	}
+	if p.ExpectedAttestingRewards == 0 {
+		p.ExpectedAttestingRewards = p.ExpectedEndorsingRewards
+	}
+	return p, nil
+}
+
+// GetDelegateDalParticipation returns a delegate's DAL attestation activity
+// in the current cycle.
+func (c *Client) GetDelegateDalParticipation(ctx context.Context, addr mavryk.Address, id BlockID) (*DalParticipation, error) {
+	u := fmt.Sprintf("chains/main/blocks/%s/context/delegates/%s/dal_participation", id, addr)
+	p := &DalParticipation{}
+	if err := c.Get(ctx, u, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
 // GetDelegateBalance returns a delegate's balance
 func (c *Client) GetDelegateBalance(ctx context.Context, addr mavryk.Address, id BlockID) (int64, error) {
 	u := fmt.Sprintf("chains/main/blocks/%s/context/delegates/%s/balance", id, addr)
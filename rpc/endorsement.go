@@ -16,6 +16,7 @@ type Endorsement struct {
 	Slot        int                 `json:"slot"`                  // v009+
 	Round       int                 `json:"round"`                 // v012+
 	PayloadHash mavryk.PayloadHash  `json:"block_payload_hash"`    // v012+
+	DalContent  *mavryk.Z           `json:"dal_attestation"`       // v019+
 }
 
 func (e Endorsement) GetLevel() int64 {
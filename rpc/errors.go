@@ -5,7 +5,9 @@ package rpc
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/mavryk-network/mvgo/micheline"
 )
@@ -86,16 +88,19 @@ type Errors []Error
 
 // UnmarshalJSON implements json.Unmarshaler
 func (e *Errors) UnmarshalJSON(data []byte) error {
-	var errs []*GenericError
+	var raw []json.RawMessage
 
-	if err := json.Unmarshal(data, &errs); err != nil {
+	if err := json.Unmarshal(data, &raw); err != nil {
 		return err
 	}
 
-	*e = make(Errors, len(errs))
-	for i, g := range errs {
-		// TODO: handle different kinds
-		(*e)[i] = g
+	*e = make(Errors, len(raw))
+	for i, v := range raw {
+		err, decodeErr := decodeError(v)
+		if decodeErr != nil {
+			return decodeErr
+		}
+		(*e)[i] = err
 	}
 
 	return nil
@@ -124,11 +129,32 @@ func (e Errors) ErrorKind() string {
 	return e[0].ErrorKind()
 }
 
+// Is reports whether any error in the list matches target.
+func (e Errors) Is(target error) bool {
+	for _, v := range e {
+		if errors.Is(v, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first error in the list that matches target.
+func (e Errors) As(target any) bool {
+	for _, v := range e {
+		if errors.As(v, target) {
+			return true
+		}
+	}
+	return false
+}
+
 type httpError struct {
 	request    string
 	status     string
 	statusCode int
 	body       []byte
+	retryAfter time.Duration
 }
 
 func (e *httpError) Error() string {
@@ -172,6 +198,14 @@ func (e *rpcError) Errors() []Error {
 	return e.errors
 }
 
+func (e *rpcError) Is(target error) bool {
+	return e.errors.Is(target)
+}
+
+func (e *rpcError) As(target any) bool {
+	return e.errors.As(target)
+}
+
 type plainError struct {
 	*httpError
 	msg string
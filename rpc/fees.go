@@ -0,0 +1,295 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+)
+
+// DefaultFeeHistorySize is the number of blocks kept in a fee history.
+const DefaultFeeHistorySize = 120
+
+// BlockFeeStats summarizes gas usage and fees paid by manager operations
+// in a single block. Fee rates are in mumav per gas unit.
+type BlockFeeStats struct {
+	Level       int64     `json:"level"`
+	Time        time.Time `json:"time"`
+	NumOps      int       `json:"n_ops"`
+	GasUsed     int64     `json:"gas_used"`
+	GasLimit    int64     `json:"gas_limit"`   // block gas limit
+	Utilization float64   `json:"utilization"` // gas used / block gas limit
+	FeeRateP10  float64   `json:"fee_rate_p10"`
+	FeeRateP50  float64   `json:"fee_rate_p50"`
+	FeeRateP90  float64   `json:"fee_rate_p90"`
+}
+
+// NewBlockFeeStats computes fee statistics for block b. The block gas limit
+// is taken from params p.
+func NewBlockFeeStats(b *Block, p *mavryk.Params) BlockFeeStats {
+	s := BlockFeeStats{
+		Level: b.GetLevel(),
+		Time:  b.GetTimestamp(),
+	}
+	if p != nil {
+		s.GasLimit = p.HardGasLimitPerBlock
+	}
+	var rates []float64
+	if len(b.Operations) > 3 {
+		for _, op := range b.Operations[3] {
+			for _, c := range op.Costs() {
+				s.NumOps++
+				s.GasUsed += c.GasUsed
+				if c.GasUsed > 0 {
+					rates = append(rates, float64(c.Fee)/float64(c.GasUsed))
+				}
+			}
+		}
+	}
+	if s.GasLimit > 0 {
+		s.Utilization = float64(s.GasUsed) / float64(s.GasLimit)
+	}
+	sort.Float64s(rates)
+	s.FeeRateP10 = percentile(rates, 0.1)
+	s.FeeRateP50 = percentile(rates, 0.5)
+	s.FeeRateP90 = percentile(rates, 0.9)
+	return s
+}
+
+// percentile returns the nearest-rank percentile q in [0..1] of sorted list.
+func percentile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(q*float64(len(sorted)) + 0.5)
+	if i > 0 {
+		i--
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
+
+// GetBlockFeeStats returns fee statistics for block id.
+func (c *Client) GetBlockFeeStats(ctx context.Context, id BlockID) (*BlockFeeStats, error) {
+	b, err := c.GetBlock(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s := NewBlockFeeStats(b, c.ChainParams())
+	return &s, nil
+}
+
+// FeeHistoryStore persists fee history across restarts.
+type FeeHistoryStore interface {
+	Load() ([]BlockFeeStats, error)
+	Save([]BlockFeeStats) error
+}
+
+// FeeHistory keeps fee statistics of recent blocks and derives a congestion
+// score which services can use for admission decisions, e.g. to delay low
+// priority batches while blocks are full. FeeHistory is safe for concurrent
+// use.
+type FeeHistory struct {
+	mu     sync.RWMutex
+	size   int
+	blocks []BlockFeeStats
+	store  FeeHistoryStore
+}
+
+// NewFeeHistory creates a fee history which keeps the last size blocks.
+func NewFeeHistory(size int) *FeeHistory {
+	if size <= 0 {
+		size = DefaultFeeHistorySize
+	}
+	return &FeeHistory{
+		size:   size,
+		blocks: make([]BlockFeeStats, 0, size),
+	}
+}
+
+// WithStore loads existing history from store s and saves the history to s
+// after each update.
+func (h *FeeHistory) WithStore(s FeeHistoryStore) (*FeeHistory, error) {
+	list, err := s.Load()
+	if err != nil {
+		return nil, err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.store = s
+	for _, v := range list {
+		h.add(v)
+	}
+	return h, nil
+}
+
+// Add inserts block statistics s. Blocks may arrive out of order, statistics
+// for a known level replace the old entry (e.g. after a reorg).
+func (h *FeeHistory) Add(s BlockFeeStats) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.add(s)
+	if h.store != nil {
+		return h.store.Save(h.blocks)
+	}
+	return nil
+}
+
+func (h *FeeHistory) add(s BlockFeeStats) {
+	i := sort.Search(len(h.blocks), func(i int) bool { return h.blocks[i].Level >= s.Level })
+	switch {
+	case i < len(h.blocks) && h.blocks[i].Level == s.Level:
+		h.blocks[i] = s
+	default:
+		h.blocks = append(h.blocks, BlockFeeStats{})
+		copy(h.blocks[i+1:], h.blocks[i:])
+		h.blocks[i] = s
+	}
+	if n := len(h.blocks) - h.size; n > 0 {
+		h.blocks = append(h.blocks[:0], h.blocks[n:]...)
+	}
+}
+
+// Update fetches statistics for block id and adds them to the history.
+func (h *FeeHistory) Update(ctx context.Context, c *Client, id BlockID) (*BlockFeeStats, error) {
+	s, err := c.GetBlockFeeStats(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s, h.Add(*s)
+}
+
+// Blocks returns a copy of the history in level order.
+func (h *FeeHistory) Blocks() []BlockFeeStats {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	list := make([]BlockFeeStats, len(h.blocks))
+	copy(list, h.blocks)
+	return list
+}
+
+// Len returns the number of blocks in the history.
+func (h *FeeHistory) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.blocks)
+}
+
+// Congestion returns a score in range [0..1] for recent chain load. The
+// score is the block gas utilization averaged over the last n blocks with
+// exponentially more weight on recent blocks. Use n <= 0 for all blocks.
+func (h *FeeHistory) Congestion(n int) float64 {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	list := h.blocks
+	if n > 0 && n < len(list) {
+		list = list[len(list)-n:]
+	}
+	if len(list) == 0 {
+		return 0
+	}
+	const decay = 0.9
+	var sum, weights float64
+	w := 1.0
+	for i := len(list) - 1; i >= 0; i-- {
+		u := list[i].Utilization
+		if u > 1 {
+			u = 1
+		}
+		sum += w * u
+		weights += w
+		w *= decay
+	}
+	return sum / weights
+}
+
+// IsCongested returns true when the congestion score over the last n blocks
+// is at or above threshold.
+func (h *FeeHistory) IsCongested(n int, threshold float64) bool {
+	return h.Len() > 0 && h.Congestion(n) >= threshold
+}
+
+// FeeRate returns the median of per block fee rate percentile q (0.1, 0.5
+// or 0.9) over the last n blocks in mumav per gas unit.
+func (h *FeeHistory) FeeRate(n int, q float64) float64 {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	list := h.blocks
+	if n > 0 && n < len(list) {
+		list = list[len(list)-n:]
+	}
+	rates := make([]float64, 0, len(list))
+	for _, v := range list {
+		if v.NumOps == 0 {
+			continue
+		}
+		switch {
+		case q <= 0.1:
+			rates = append(rates, v.FeeRateP10)
+		case q <= 0.5:
+			rates = append(rates, v.FeeRateP50)
+		default:
+			rates = append(rates, v.FeeRateP90)
+		}
+	}
+	sort.Float64s(rates)
+	return percentile(rates, 0.5)
+}
+
+// FileFeeHistoryStore keeps fee history in a JSON file. The file is replaced
+// atomically on each save.
+type FileFeeHistoryStore struct {
+	Path string
+}
+
+// NewFileFeeHistoryStore creates a store backed by file path.
+func NewFileFeeHistoryStore(path string) *FileFeeHistoryStore {
+	return &FileFeeHistoryStore{Path: path}
+}
+
+func (s *FileFeeHistoryStore) Load() ([]BlockFeeStats, error) {
+	buf, err := os.ReadFile(s.Path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+	var list []BlockFeeStats
+	if err := json.Unmarshal(buf, &list); err != nil {
+		return nil, fmt.Errorf("rpc: fee history %s: %w", s.Path, err)
+	}
+	return list, nil
+}
+
+func (s *FileFeeHistoryStore) Save(list []BlockFeeStats) error {
+	buf, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.Path), filepath.Base(s.Path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.Path)
+}
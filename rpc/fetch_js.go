@@ -0,0 +1,52 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+//go:build js && wasm
+// +build js,wasm
+
+package rpc
+
+import (
+	"net/http"
+)
+
+// FetchOptions control the browser Fetch API used by the Go HTTP transport
+// under js/wasm. Empty values keep browser defaults. See
+// https://developer.mozilla.org/en-US/docs/Web/API/fetch#parameters
+type FetchOptions struct {
+	Mode        string // cors, no-cors, same-origin
+	Credentials string // omit, same-origin, include
+	Redirect    string // follow, error, manual
+}
+
+// fetchTransport sets fetch options as special request headers which are
+// interpreted by the js/wasm http.RoundTripper.
+type fetchTransport struct {
+	next http.RoundTripper
+	opts FetchOptions
+}
+
+func (t fetchTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if t.opts.Mode != "" {
+		req.Header.Set("js.fetch:mode", t.opts.Mode)
+	}
+	if t.opts.Credentials != "" {
+		req.Header.Set("js.fetch:credentials", t.opts.Credentials)
+	}
+	if t.opts.Redirect != "" {
+		req.Header.Set("js.fetch:redirect", t.opts.Redirect)
+	}
+	return t.next.RoundTrip(req)
+}
+
+// NewFetchClient returns an HTTP client which uses the browser Fetch API with
+// the given options. Pass it to NewClient when running inside a browser.
+func NewFetchClient(opts FetchOptions) *http.Client {
+	return &http.Client{
+		Transport: fetchTransport{
+			next: http.DefaultTransport,
+			opts: opts,
+		},
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+)
+
+// FinalityConfirmations is the number of blocks on top of a block after
+// which the block is final under Tenderbake.
+const FinalityConfirmations = 2
+
+// CorrectionKind describes how a tracked receipt changed.
+type CorrectionKind byte
+
+const (
+	CorrectionFinal    CorrectionKind = iota // receipt is final and won't change
+	CorrectionReverted                       // receipt's block was replaced by a reorg
+)
+
+func (k CorrectionKind) String() string {
+	switch k {
+	case CorrectionFinal:
+		return "final"
+	case CorrectionReverted:
+		return "reverted"
+	default:
+		return "invalid"
+	}
+}
+
+// Correction reports a finality change of a tracked receipt. Downstream
+// databases should mark final receipts as immutable and apply compensating
+// updates for reverted receipts. A reverted operation may be included again
+// in a later block, in which case it is tracked again with a new receipt.
+type Correction struct {
+	Kind    CorrectionKind
+	Receipt *Receipt
+}
+
+// ReceiptTracker keeps receipts of recently included operations together with
+// their block hash and level until they become final. Feed new chain heads
+// into Update to learn which receipts became final and which were reverted by
+// a reorg. ReceiptTracker is safe for concurrent use.
+type ReceiptTracker struct {
+	Confirmations int64 // confirmations until final, defaults to FinalityConfirmations
+
+	c       *Client
+	mu      sync.Mutex
+	pending map[mavryk.OpHash]*Receipt
+	chain   map[int64]mavryk.BlockHash // canonical hashes learned from heads
+}
+
+// NewReceiptTracker creates a receipt tracker which uses client c to look
+// up canonical block hashes at levels not covered by recent heads. Client
+// c may be nil when all heads are passed to Update without gaps.
+func NewReceiptTracker(c *Client) *ReceiptTracker {
+	return &ReceiptTracker{
+		Confirmations: FinalityConfirmations,
+		c:             c,
+		pending:       make(map[mavryk.OpHash]*Receipt),
+		chain:         make(map[int64]mavryk.BlockHash),
+	}
+}
+
+// Add tracks receipt r. Receipts for the same operation replace each other.
+func (t *ReceiptTracker) Add(r *Receipt) {
+	if r == nil || r.Op == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending[r.Op.Hash] = r
+}
+
+// Len returns the number of receipts which are not final yet.
+func (t *ReceiptTracker) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.pending)
+}
+
+// Pending returns all receipts which are not final yet ordered by level.
+func (t *ReceiptTracker) Pending() []*Receipt {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	list := make([]*Receipt, 0, len(t.pending))
+	for _, r := range t.pending {
+		list = append(list, r)
+	}
+	sortReceipts(list)
+	return list
+}
+
+// Update checks all tracked receipts against the chain ending in head and
+// returns corrections ordered by level. Final and reverted receipts are no
+// longer tracked. On lookup errors, receipts which could not be checked stay
+// tracked and the error is returned together with all corrections found.
+func (t *ReceiptTracker) Update(ctx context.Context, head *BlockHeaderLogEntry) ([]Correction, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	confirmations := t.Confirmations
+	if confirmations <= 0 {
+		confirmations = FinalityConfirmations
+	}
+
+	// the head identifies canonical blocks at its own and the previous level,
+	// blocks at lower levels are final and remain canonical
+	canonical := t.chain
+	canonical[head.Level] = head.Hash
+	canonical[head.Level-1] = head.Predecessor
+	for l := range canonical {
+		if l > head.Level || l < head.Level-2*confirmations {
+			delete(canonical, l)
+		}
+	}
+
+	list := make([]*Receipt, 0, len(t.pending))
+	for _, r := range t.pending {
+		list = append(list, r)
+	}
+	sortReceipts(list)
+
+	var (
+		corr = make([]Correction, 0)
+		err  error
+	)
+	for _, r := range list {
+		if r.Height > head.Level {
+			// head is behind the receipt, wait for the chain to catch up
+			continue
+		}
+		hash, ok := canonical[r.Height]
+		if !ok {
+			if t.c == nil {
+				continue
+			}
+			var e error
+			hash, e = t.c.GetBlockHash(ctx, BlockLevel(r.Height))
+			if e != nil {
+				err = e
+				continue
+			}
+			canonical[r.Height] = hash
+		}
+		switch {
+		case !hash.Equal(r.Block):
+			corr = append(corr, Correction{Kind: CorrectionReverted, Receipt: r})
+			delete(t.pending, r.Op.Hash)
+		case head.Level-r.Height >= confirmations:
+			corr = append(corr, Correction{Kind: CorrectionFinal, Receipt: r})
+			delete(t.pending, r.Op.Hash)
+		}
+	}
+	return corr, err
+}
+
+func sortReceipts(list []*Receipt) {
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Height == list[j].Height {
+			if list[i].List == list[j].List {
+				return list[i].Pos < list[j].Pos
+			}
+			return list[i].List < list[j].List
+		}
+		return list[i].Height < list[j].Height
+	})
+}
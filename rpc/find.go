@@ -0,0 +1,135 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+)
+
+var ErrOperationNotFound = errors.New("rpc: operation not found")
+
+// FindOperationOptions controls the block window searched by FindOperationExt.
+type FindOperationOptions struct {
+	Since       int64 // first level to search, defaults to max operations ttl below Until
+	Until       int64 // last level to search, defaults to the current head
+	Concurrency int   // number of blocks searched in parallel, defaults to 1
+}
+
+// FindOperation searches all blocks from level since to the current head for
+// operation hash and returns its receipt. The node has no index by operation
+// hash, so each block in the window is fetched, newest first. Fails with
+// ErrOperationNotFound when the window does not contain the operation.
+func (c *Client) FindOperation(ctx context.Context, hash mavryk.OpHash, since int64) (*Receipt, error) {
+	return c.FindOperationExt(ctx, hash, FindOperationOptions{Since: since})
+}
+
+// FindOperationExt searches the block window selected by opts for operation
+// hash and returns its receipt.
+func (c *Client) FindOperationExt(ctx context.Context, hash mavryk.OpHash, opts FindOperationOptions) (*Receipt, error) {
+	until := opts.Until
+	if until <= 0 {
+		head, err := c.GetBlockHeader(ctx, Head)
+		if err != nil {
+			return nil, err
+		}
+		until = head.Level
+	}
+	since := opts.Since
+	if since <= 0 {
+		since = until - c.ChainParams().MaxOperationsTTL
+	}
+	if since < 1 {
+		since = 1
+	}
+	workers := opts.Concurrency
+	if workers <= 0 {
+		workers = 1
+	}
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		next  = until
+		found *Receipt
+		ferr  error
+	)
+	sctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				mu.Lock()
+				if found != nil || ferr != nil || next < since {
+					mu.Unlock()
+					return
+				}
+				level := next
+				next--
+				mu.Unlock()
+
+				rec, err := c.findBlockOperation(sctx, BlockLevel(level), hash)
+				mu.Lock()
+				switch {
+				case err != nil:
+					if ferr == nil && sctx.Err() == nil {
+						ferr = err
+					}
+					cancel()
+				case rec != nil:
+					rec.Height = level
+					found = rec
+					cancel()
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if found == nil {
+		if ferr != nil {
+			return nil, ferr
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return nil, ErrOperationNotFound
+	}
+	var err error
+	found.Block, err = c.GetBlockHash(ctx, BlockLevel(found.Height))
+	if err != nil {
+		return nil, err
+	}
+	found.Op, err = c.GetBlockOperation(ctx, found.Block, found.List, found.Pos)
+	if err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// findBlockOperation returns the position of operation hash in block id or
+// nil when the block does not contain the operation.
+func (c *Client) findBlockOperation(ctx context.Context, id BlockID, hash mavryk.OpHash) (*Receipt, error) {
+	lists, err := c.GetBlockOperationHashes(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	for l, list := range lists {
+		for p, v := range list {
+			if v.Equal(hash) {
+				return &Receipt{
+					List: l,
+					Pos:  p,
+				}, nil
+			}
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,221 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+)
+
+// DefaultFollowDepth is the number of recent blocks a block follower keeps
+// to find common ancestors on reorg.
+const DefaultFollowDepth = 64
+
+var ErrReorgTooDeep = errors.New("rpc: reorg exceeds follower depth")
+
+// FollowEventKind describes a chain update emitted by a block follower.
+type FollowEventKind byte
+
+const (
+	FollowApply    FollowEventKind = iota // block was appended to the chain
+	FollowRollback                        // blocks above the common ancestor were reverted
+)
+
+func (k FollowEventKind) String() string {
+	switch k {
+	case FollowApply:
+		return "apply"
+	case FollowRollback:
+		return "rollback"
+	default:
+		return "invalid"
+	}
+}
+
+// FollowEvent is a single chain update. On apply Block is the new block. On
+// rollback Block is the common ancestor which becomes the new chain tip and
+// Reverted lists all removed blocks, newest first. Apply events for blocks
+// of the new branch follow each rollback in chain order.
+type FollowEvent struct {
+	Kind     FollowEventKind
+	Block    *BlockHeaderLogEntry
+	Reverted []*BlockHeaderLogEntry
+}
+
+// BlockFollower turns a stream of chain heads into an ordered sequence of
+// apply and rollback events. It tracks predecessor links of recent blocks,
+// fetches headers the head stream skipped and detects reorgs by walking the
+// new branch back to the common ancestor. Indexers can apply events in order
+// without handling reorgs or gaps themselves.
+//
+// BlockFollower is not safe for concurrent use.
+type BlockFollower struct {
+	Depth int // number of recent blocks kept, defaults to DefaultFollowDepth
+
+	c     *Client
+	mon   MonitorStream[*BlockHeaderLogEntry]
+	chain []*BlockHeaderLogEntry // recent canonical blocks, oldest first
+	queue []FollowEvent
+}
+
+// NewBlockFollower creates a block follower which reads heads from mon and
+// uses client c to fetch missing headers.
+func NewBlockFollower(c *Client, mon MonitorStream[*BlockHeaderLogEntry]) *BlockFollower {
+	return &BlockFollower{
+		Depth: DefaultFollowDepth,
+		c:     c,
+		mon:   mon,
+	}
+}
+
+// NewBlockFollower returns a block follower on top of a reconnecting head
+// monitor. The follower stays active until ctx is cancelled or Close is called.
+func (c *Client) NewBlockFollower(ctx context.Context) *BlockFollower {
+	return NewBlockFollower(c, c.NewReconnectingBlockMonitor(ctx))
+}
+
+// WithDepth sets the number of recent blocks kept for reorg detection.
+func (f *BlockFollower) WithDepth(n int) *BlockFollower {
+	f.Depth = n
+	return f
+}
+
+// WithTip sets the last processed block, e.g. when an indexer restarts. All
+// blocks between tip and the first head are replayed. When tip is no longer
+// canonical the follower fetches its ancestors to find the fork point and
+// emits a rollback to the common ancestor. The fork point must be less than
+// Depth blocks below tip, otherwise Recv fails with ErrReorgTooDeep.
+func (f *BlockFollower) WithTip(tip *BlockHeaderLogEntry) *BlockFollower {
+	f.chain = []*BlockHeaderLogEntry{tip}
+	return f
+}
+
+// Tip returns the most recent canonical block or nil before the first head.
+func (f *BlockFollower) Tip() *BlockHeaderLogEntry {
+	if len(f.chain) == 0 {
+		return nil
+	}
+	return f.chain[len(f.chain)-1]
+}
+
+// Recv returns the next chain update.
+func (f *BlockFollower) Recv(ctx context.Context) (FollowEvent, error) {
+	for len(f.queue) == 0 {
+		head, err := f.mon.Recv(ctx)
+		if err != nil {
+			return FollowEvent{}, err
+		}
+		events, err := f.Update(ctx, head)
+		if err != nil {
+			return FollowEvent{}, err
+		}
+		f.queue = events
+	}
+	e := f.queue[0]
+	f.queue = f.queue[1:]
+	return e, nil
+}
+
+// Close closes the underlying head monitor.
+func (f *BlockFollower) Close() {
+	f.mon.Close()
+}
+
+// Update processes a new head and returns the resulting chain updates. Heads
+// which are already part of the chain produce no events. On error the
+// follower state is unchanged and the head may be passed again.
+func (f *BlockFollower) Update(ctx context.Context, head *BlockHeaderLogEntry) ([]FollowEvent, error) {
+	if head == nil {
+		return nil, nil
+	}
+	if len(f.chain) == 0 {
+		f.chain = append(f.chain, head)
+		return []FollowEvent{{Kind: FollowApply, Block: head}}, nil
+	}
+	if f.find(head.Hash) >= 0 {
+		return nil, nil
+	}
+
+	depth := f.Depth
+	if depth <= 0 {
+		depth = DefaultFollowDepth
+	}
+
+	// walk the new branch back until it connects to a known block, extend
+	// the known chain backwards when the new branch falls below its start
+	chain := f.chain
+	branch := []*BlockHeaderLogEntry{head}
+	pos := findBlock(chain, head.Predecessor)
+	for pos < 0 {
+		cur := branch[len(branch)-1]
+		if cur.Level <= chain[0].Level {
+			if len(chain) >= depth || chain[0].Level <= 0 {
+				return nil, fmt.Errorf("%w: no common ancestor above level %d", ErrReorgTooDeep, chain[0].Level)
+			}
+			h, err := f.header(ctx, chain[0].Predecessor)
+			if err != nil {
+				return nil, err
+			}
+			chain = append([]*BlockHeaderLogEntry{h}, chain...)
+		} else {
+			h, err := f.header(ctx, cur.Predecessor)
+			if err != nil {
+				return nil, err
+			}
+			branch = append(branch, h)
+		}
+		pos = findBlock(chain, branch[len(branch)-1].Predecessor)
+	}
+	f.chain = chain
+
+	events := make([]FollowEvent, 0, len(branch)+1)
+	if pos < len(f.chain)-1 {
+		reverted := make([]*BlockHeaderLogEntry, 0, len(f.chain)-pos-1)
+		for i := len(f.chain) - 1; i > pos; i-- {
+			reverted = append(reverted, f.chain[i])
+		}
+		events = append(events, FollowEvent{
+			Kind:     FollowRollback,
+			Block:    f.chain[pos],
+			Reverted: reverted,
+		})
+		f.chain = f.chain[:pos+1]
+	}
+	for i := len(branch) - 1; i >= 0; i-- {
+		f.chain = append(f.chain, branch[i])
+		events = append(events, FollowEvent{Kind: FollowApply, Block: branch[i]})
+	}
+
+	if n := len(f.chain) - depth; n > 0 {
+		f.chain = append(f.chain[:0:0], f.chain[n:]...)
+	}
+	return events, nil
+}
+
+func (f *BlockFollower) find(hash mavryk.BlockHash) int {
+	return findBlock(f.chain, hash)
+}
+
+func (f *BlockFollower) header(ctx context.Context, hash mavryk.BlockHash) (*BlockHeaderLogEntry, error) {
+	if f.c == nil {
+		return nil, fmt.Errorf("rpc: missing block %s", hash)
+	}
+	h, err := f.c.GetBlockHeader(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	return h.LogEntry(), nil
+}
+
+func findBlock(chain []*BlockHeaderLogEntry, hash mavryk.BlockHash) int {
+	for i := len(chain) - 1; i >= 0; i-- {
+		if chain[i].Hash.Equal(hash) {
+			return i
+		}
+	}
+	return -1
+}
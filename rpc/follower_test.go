@@ -0,0 +1,177 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+	"github.com/mavryk-network/mvgo/rpc"
+)
+
+// testChain builds block headers for a main chain and forks and serves them
+// to a block follower.
+type testChain struct {
+	blocks map[string]*rpc.BlockHeaderLogEntry
+	c      *rpc.Client
+}
+
+func newTestChain(t *testing.T) *testChain {
+	t.Helper()
+	tc := &testChain{blocks: make(map[string]*rpc.BlockHeaderLogEntry)}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/chains/main/blocks/"), "/header")
+		b, ok := tc.blocks[id]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(b)
+	}))
+	c, err := rpc.NewClient(srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		c.Close()
+		srv.Close()
+	})
+	tc.c = c
+	return tc
+}
+
+// branch appends n blocks to parent. Fork id distinguishes block hashes of
+// different branches at the same level.
+func (tc *testChain) branch(parent *rpc.BlockHeaderLogEntry, fork byte, n int) []*rpc.BlockHeaderLogEntry {
+	var level int64
+	var pred mavryk.BlockHash
+	if parent != nil {
+		level, pred = parent.Level+1, parent.Hash
+	}
+	res := make([]*rpc.BlockHeaderLogEntry, n)
+	for i := range res {
+		buf := make([]byte, 32)
+		buf[0], buf[1] = fork, byte(level)
+		b := &rpc.BlockHeaderLogEntry{
+			Hash:        mavryk.NewBlockHash(buf),
+			Level:       level,
+			Predecessor: pred,
+		}
+		tc.blocks[b.Hash.String()] = b
+		res[i] = b
+		level, pred = level+1, b.Hash
+	}
+	return res
+}
+
+func checkFollowEvents(t *testing.T, have []rpc.FollowEvent, want ...string) {
+	t.Helper()
+	s := make([]string, len(have))
+	for i, e := range have {
+		s[i] = ev(e.Kind, e.Block, e.Reverted...)
+	}
+	if strings.Join(s, " ") != strings.Join(want, " ") {
+		t.Errorf("want events %v, have %v", want, s)
+	}
+}
+
+func ev(kind rpc.FollowEventKind, b *rpc.BlockHeaderLogEntry, reverted ...*rpc.BlockHeaderLogEntry) string {
+	s := kind.String() + "@" + b.Hash.String()
+	for _, v := range reverted {
+		s += "-" + v.Hash.String()
+	}
+	return s
+}
+
+func TestBlockFollower(t *testing.T) {
+	tc := newTestChain(t)
+	ctx := context.Background()
+	main := tc.branch(nil, 1, 8)
+	fork := tc.branch(main[3], 2, 3)
+
+	// gaps are filled
+	f := rpc.NewBlockFollower(tc.c, nil)
+	events, err := f.Update(ctx, main[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFollowEvents(t, events, ev(rpc.FollowApply, main[0]))
+	events, err = f.Update(ctx, fork[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFollowEvents(t, events,
+		ev(rpc.FollowApply, main[1]),
+		ev(rpc.FollowApply, main[2]),
+		ev(rpc.FollowApply, main[3]),
+		ev(rpc.FollowApply, fork[0]),
+		ev(rpc.FollowApply, fork[1]),
+	)
+
+	// known heads are ignored
+	if events, err = f.Update(ctx, fork[0]); err != nil || len(events) > 0 {
+		t.Errorf("unexpected events %v (%v)", events, err)
+	}
+
+	// reorgs roll back to the common ancestor
+	events, err = f.Update(ctx, main[6])
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFollowEvents(t, events,
+		ev(rpc.FollowRollback, main[3], fork[1], fork[0]),
+		ev(rpc.FollowApply, main[4]),
+		ev(rpc.FollowApply, main[5]),
+		ev(rpc.FollowApply, main[6]),
+	)
+	if !f.Tip().Hash.Equal(main[6].Hash) {
+		t.Errorf("want tip %s, have %s", main[6].Hash, f.Tip().Hash)
+	}
+}
+
+func TestBlockFollowerWithTip(t *testing.T) {
+	tc := newTestChain(t)
+	ctx := context.Background()
+	main := tc.branch(nil, 1, 8)
+	fork := tc.branch(main[3], 2, 3)
+
+	// canonical tips replay missing blocks
+	f := rpc.NewBlockFollower(tc.c, nil).WithTip(main[3])
+	events, err := f.Update(ctx, main[5])
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFollowEvents(t, events,
+		ev(rpc.FollowApply, main[4]),
+		ev(rpc.FollowApply, main[5]),
+	)
+
+	// orphaned tips roll back to the fork point
+	f = rpc.NewBlockFollower(tc.c, nil).WithTip(fork[2])
+	events, err = f.Update(ctx, main[5])
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFollowEvents(t, events,
+		ev(rpc.FollowRollback, main[3], fork[2], fork[1], fork[0]),
+		ev(rpc.FollowApply, main[4]),
+		ev(rpc.FollowApply, main[5]),
+	)
+
+	// the fork point must be within depth
+	f = rpc.NewBlockFollower(tc.c, nil).WithDepth(3).WithTip(fork[2])
+	if _, err := f.Update(ctx, main[7]); !errors.Is(err, rpc.ErrReorgTooDeep) {
+		t.Errorf("want ErrReorgTooDeep, have %v", err)
+	}
+	if !f.Tip().Hash.Equal(fork[2].Hash) {
+		t.Errorf("state changed on error, tip %s", f.Tip().Hash)
+	}
+}
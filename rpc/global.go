@@ -20,7 +20,10 @@ type ConstantRegistration struct {
 // Costs returns operation cost to implement TypedOperation interface.
 func (c ConstantRegistration) Costs() mavryk.Costs {
 	res := c.Metadata.Result
-	burn := res.BalanceUpdates[0].Amount()
+	var burn int64
+	if len(res.BalanceUpdates) > 0 {
+		burn = res.BalanceUpdates[0].Amount()
+	}
 	return mavryk.Costs{
 		Fee:         c.Manager.Fee,
 		GasUsed:     res.Gas(),
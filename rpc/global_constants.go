@@ -0,0 +1,66 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/mavryk-network/mvgo/codec"
+	"github.com/mavryk-network/mvgo/mavryk"
+	"github.com/mavryk-network/mvgo/micheline"
+)
+
+// GetGlobalConstant returns the value of a registered global constant at block id.
+func (c *Client) GetGlobalConstant(ctx context.Context, hash mavryk.ExprHash, id BlockID) (micheline.Prim, error) {
+	u := fmt.Sprintf("chains/main/blocks/%s/context/constants/%s", id, hash)
+	prim := micheline.Prim{}
+	if err := c.Get(ctx, u, &prim); err != nil {
+		return micheline.InvalidPrim, err
+	}
+	return prim, nil
+}
+
+// SendWithConstants is like Send but supports originations whose script
+// exceeds the maximum operation data length. Large script subtrees are
+// moved into global constants which are registered one by one before a
+// rewritten copy of op is sent. Op itself is not modified. Constants which
+// already exist on-chain are reused. Receipts are returned in send order
+// with the receipt for op last.
+func (c *Client) SendWithConstants(ctx context.Context, op *codec.Op, opts *CallOptions) ([]*Receipt, error) {
+	if opts == nil {
+		opts = &DefaultOptions
+	}
+	op.WithParams(c.ChainParams())
+	res, consts, err := op.ExtractConstants()
+	if err != nil {
+		return nil, err
+	}
+	rcpts := make([]*Receipt, 0, len(consts)+1)
+	for _, v := range consts {
+		hash := v.ConstantHash()
+		_, err := c.GetGlobalConstant(ctx, hash, Head)
+		switch {
+		case err == nil:
+			c.Log.Debugf("Reusing global constant %s", hash)
+			continue
+		case ErrorStatus(err) == http.StatusNotFound, errors.Is(err, ErrNonexistentGlobal):
+			// not registered yet
+		default:
+			return rcpts, err
+		}
+		rcpt, err := c.Send(ctx, codec.NewOp().WithTTL(opts.TTL).WithRegisterConstant(v), opts)
+		if err != nil {
+			return rcpts, fmt.Errorf("rpc: registering constant %s: %w", hash, err)
+		}
+		rcpts = append(rcpts, rcpt)
+	}
+	rcpt, err := c.Send(ctx, res, opts)
+	if err != nil {
+		return rcpts, err
+	}
+	return append(rcpts, rcpt), nil
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mavryk-network/mvgo/codec"
+	"github.com/mavryk-network/mvgo/mavryk"
+	"github.com/mavryk-network/mvgo/micheline"
+	"github.com/mavryk-network/mvgo/rpc"
+)
+
+// newLargeOrigination returns an origination whose script code is larger
+// than 4k.
+func newLargeOrigination() *codec.Op {
+	body := make([]micheline.Prim, 0)
+	for i := 0; i < 40; i++ {
+		body = append(body, micheline.NewSeq(
+			micheline.NewCode(micheline.I_PUSH, micheline.NewCode(micheline.T_STRING), micheline.NewString(strings.Repeat("x", 100+i))),
+			micheline.NewCode(micheline.I_DROP),
+		))
+	}
+	body = append(body,
+		micheline.NewCode(micheline.I_CDR),
+		micheline.NewCode(micheline.I_NIL, micheline.NewCode(micheline.T_OPERATION)),
+		micheline.NewCode(micheline.I_PAIR),
+	)
+	s := micheline.NewScript()
+	s.Code.Param = micheline.NewCode(micheline.K_PARAMETER, micheline.NewCode(micheline.T_UNIT))
+	s.Code.Storage = micheline.NewCode(micheline.K_STORAGE, micheline.NewCode(micheline.T_UNIT))
+	s.Code.Code = micheline.NewCode(micheline.K_CODE, micheline.NewSeq(body...))
+	s.Storage = micheline.Unit
+	return codec.NewOp().WithOrigination(*s)
+}
+
+func TestSendWithConstants(t *testing.T) {
+	node, c, sk := newTestNode(t)
+	p := c.ChainParams().Clone()
+	p.MaxOperationDataLength = 3072
+	c.SetParams(p)
+
+	op := newLargeOrigination()
+	orig, _ := op.Contents[0].(*codec.Origination).Script.Code.MarshalBinary()
+	res, consts, err := op.WithSource(sk.Address()).WithParams(p).ExtractConstants()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(consts) == 0 || res == op {
+		t.Fatal("expected constants")
+	}
+
+	// unregistered constants fail with a nonexistent global error
+	for _, v := range consts {
+		node.Handle(http.MethodGet, "/chains/main/blocks/head/context/constants/"+v.ConstantHash().String(), func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`[{"kind":"branch","id":"proto.alpha.Nonexistent_global"}]`))
+		})
+	}
+
+	node.AutoBake(10 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	opts := rpc.NewCallOptions()
+	opts.Confirmations = 0
+
+	// failed registrations leave op unchanged
+	node.RejectInjections("proto.alpha.node.rejected")
+	if _, err := c.SendWithConstants(ctx, op, opts); err == nil {
+		t.Fatal("expected registration error")
+	}
+	if buf, _ := op.Contents[0].(*codec.Origination).Script.Code.MarshalBinary(); !bytes.Equal(buf, orig) {
+		t.Error("op was modified")
+	}
+
+	node.RejectInjections("")
+	rcpts, err := c.SendWithConstants(ctx, op, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := len(consts) + 1; len(rcpts) != want {
+		t.Fatalf("want %d receipts, have %d", want, len(rcpts))
+	}
+	inj := node.Injected()
+	if len(inj) != len(consts)+1 {
+		t.Fatalf("want %d injections, have %d", len(consts)+1, len(inj))
+	}
+	for i, v := range inj[:len(consts)] {
+		if k := v.Op.Contents[len(v.Op.Contents)-1].Kind(); k != mavryk.OpTypeRegisterConstant {
+			t.Errorf("injection %d: want register constant, have %s", i, k)
+		}
+	}
+	last := inj[len(consts)].Op
+	sent := last.Contents[len(last.Contents)-1].(*codec.Origination)
+	if n := len(sent.Script.Constants()); n == 0 {
+		t.Error("sent script does not reference constants")
+	}
+}
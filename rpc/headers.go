@@ -0,0 +1,73 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc
+
+import (
+	"context"
+	"net/http"
+)
+
+type headerKey struct{}
+
+// WithRequestHeader returns a context which adds HTTP header key with value
+// to all requests sent with it. Per request headers replace client-wide
+// headers of the same name, e.g. X-Api-Key, so a single client can serve
+// multiple tenants.
+//
+//	ctx = rpc.WithRequestHeader(ctx, "X-Api-Key", tenant.ApiKey)
+//	head, err := c.GetTipHeader(ctx)
+func WithRequestHeader(ctx context.Context, key, value string) context.Context {
+	h := make(http.Header)
+	h.Set(key, value)
+	return WithRequestHeaders(ctx, h)
+}
+
+// WithRequestHeaders returns a context which adds all headers in h to
+// requests sent with it. Headers are merged with headers already attached
+// to ctx, values in h take precedence.
+func WithRequestHeaders(ctx context.Context, h http.Header) context.Context {
+	if len(h) == 0 {
+		return ctx
+	}
+	merged := RequestHeaders(ctx)
+	if merged == nil {
+		merged = make(http.Header, len(h))
+	}
+	for k, v := range h {
+		merged[http.CanonicalHeaderKey(k)] = append([]string(nil), v...)
+	}
+	return context.WithValue(ctx, headerKey{}, merged)
+}
+
+// WithBearerToken returns a context which authenticates requests with an
+// Authorization bearer token.
+func WithBearerToken(ctx context.Context, token string) context.Context {
+	return WithRequestHeader(ctx, "Authorization", "Bearer "+token)
+}
+
+// WithRequestId returns a context which tags requests with an X-Request-Id
+// header for tracing across gateways.
+func WithRequestId(ctx context.Context, id string) context.Context {
+	return WithRequestHeader(ctx, "X-Request-Id", id)
+}
+
+// RequestHeaders returns a copy of the headers attached to ctx or nil.
+func RequestHeaders(ctx context.Context) http.Header {
+	h, ok := ctx.Value(headerKey{}).(http.Header)
+	if !ok {
+		return nil
+	}
+	return h.Clone()
+}
+
+// applyHeaders sets headers attached to ctx on request req.
+func applyHeaders(ctx context.Context, req *http.Request) {
+	h, ok := ctx.Value(headerKey{}).(http.Header)
+	if !ok {
+		return
+	}
+	for k, v := range h {
+		req.Header[k] = append([]string(nil), v...)
+	}
+}
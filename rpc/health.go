@@ -0,0 +1,99 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+)
+
+// HealthOptions configure checks run by HealthCheck. Zero values disable
+// the respective check.
+type HealthOptions struct {
+	MaxDrift time.Duration      // max difference between head time and wall clock
+	ChainId  mavryk.ChainIdHash // expected chain id
+}
+
+// HealthReport is the result of a node health check.
+type HealthReport struct {
+	Healthy      bool               `json:"healthy"`
+	Bootstrapped bool               `json:"bootstrapped"`
+	SyncState    string             `json:"sync_state"`
+	ChainId      mavryk.ChainIdHash `json:"chain_id"`
+	HeadLevel    int64              `json:"head_level"`
+	HeadHash     mavryk.BlockHash   `json:"head_hash"`
+	HeadTime     time.Time          `json:"head_time"`
+	Drift        time.Duration      `json:"drift"`   // wall clock minus head time
+	Latency      time.Duration      `json:"latency"` // duration of all checks
+	Problems     []string           `json:"problems,omitempty"`
+	Time         time.Time          `json:"time"`
+}
+
+// Err returns an error listing all problems or nil when the node is healthy.
+func (r *HealthReport) Err() error {
+	if r.Healthy {
+		return nil
+	}
+	return fmt.Errorf("rpc: unhealthy node: %s", strings.Join(r.Problems, ", "))
+}
+
+func (r *HealthReport) fail(format string, args ...any) {
+	r.Healthy = false
+	r.Problems = append(r.Problems, fmt.Sprintf(format, args...))
+}
+
+// HealthCheck verifies that the node is bootstrapped and synced, serves the
+// expected chain and that its head is not older (or newer) than opts.MaxDrift
+// compared to the local wall clock. Failed checks are listed in the report.
+// An error is only returned when the node could not be queried, in which
+// case the report contains results of checks completed so far.
+func (c *Client) HealthCheck(ctx context.Context, opts HealthOptions) (*HealthReport, error) {
+	start := time.Now()
+	r := &HealthReport{
+		Healthy: true,
+		Time:    start.UTC(),
+	}
+	defer func() {
+		r.Latency = time.Since(start)
+	}()
+
+	status, err := c.GetStatus(ctx)
+	if err != nil {
+		r.fail("status: %v", err)
+		return r, err
+	}
+	r.Bootstrapped, r.SyncState = status.Bootstrapped, status.SyncState
+	if !status.Bootstrapped {
+		r.fail("not bootstrapped")
+	}
+	if status.SyncState != "" && status.SyncState != "synced" {
+		r.fail("sync state %s", status.SyncState)
+	}
+
+	id, err := c.GetChainId(ctx)
+	if err != nil {
+		r.fail("chain id: %v", err)
+		return r, err
+	}
+	r.ChainId = id
+	if opts.ChainId.IsValid() && !opts.ChainId.Equal(id) {
+		r.fail("chain id %s, expected %s", id, opts.ChainId)
+	}
+
+	head, err := c.GetTipHeader(ctx)
+	if err != nil {
+		r.fail("head: %v", err)
+		return r, err
+	}
+	r.HeadLevel, r.HeadHash, r.HeadTime = head.Level, head.Hash, head.Timestamp
+	r.Drift = time.Since(head.Timestamp)
+	if opts.MaxDrift > 0 && (r.Drift > opts.MaxDrift || -r.Drift > opts.MaxDrift) {
+		r.fail("head %d drift %s exceeds %s", head.Level, r.Drift.Truncate(time.Second), opts.MaxDrift)
+	}
+	return r, nil
+}
@@ -0,0 +1,58 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mavryk-network/mvgo/codec"
+	"github.com/mavryk-network/mvgo/mavryk"
+	"github.com/mavryk-network/mvgo/rpc"
+	"github.com/mavryk-network/mvgo/rpc/rpctest"
+	"github.com/mavryk-network/mvgo/signer"
+)
+
+var testReceiver = mavryk.MustParseAddress("mv1C6KuPzs92LTENfXUPXq1oNxvaLjxJ9p8b")
+
+// newTestNode returns a node simulator with a few blocks and a funded
+// account and a client which signs with the account's key.
+func newTestNode(t *testing.T, opts ...rpc.ClientOption) (*rpctest.Node, *rpc.Client, mavryk.PrivateKey) {
+	t.Helper()
+	sk, err := mavryk.GenerateKey(mavryk.KeyTypeEd25519)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := rpctest.NewNode(nil)
+	node.SetAccount(sk.Address(), rpctest.Account{Balance: 1_000_000_000})
+	for i := 0; i < 3; i++ {
+		node.Bake()
+	}
+	c, err := node.Client(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Signer = signer.NewFromKey(sk)
+	t.Cleanup(func() {
+		c.Close()
+		node.Close()
+	})
+	return node, c, sk
+}
+
+// sendTransfer sends a transfer and bakes blocks until it is included.
+func sendTransfer(t *testing.T, node *rpctest.Node, c *rpc.Client, amount int64) *rpc.Receipt {
+	t.Helper()
+	node.AutoBake(10 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	opts := rpc.NewCallOptions()
+	opts.Confirmations = 0
+	rcpt, err := c.Send(ctx, codec.NewOp().WithTransfer(testReceiver, amount), opts)
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	return rcpt
+}
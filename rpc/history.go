@@ -0,0 +1,136 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+)
+
+// History modes
+const (
+	HistoryModeArchive = "archive"
+	HistoryModeFull    = "full"
+	HistoryModeRolling = "rolling"
+)
+
+// ChainLevel identifies a block which marks a history boundary on a node.
+type ChainLevel struct {
+	Hash  mavryk.BlockHash `json:"block_hash"`
+	Level int64            `json:"level"`
+}
+
+// HistoryMode describes how much chain history a node keeps.
+type HistoryMode struct {
+	Mode             string `json:"mode"`
+	AdditionalCycles int64  `json:"additional_cycles"`
+}
+
+// UnmarshalJSON decodes the plain (`"archive"`) and extended
+// (`{"rolling":{"additional_cycles":5}}`) history mode formats.
+func (m *HistoryMode) UnmarshalJSON(buf []byte) error {
+	var s string
+	if err := json.Unmarshal(buf, &s); err == nil {
+		m.Mode = s
+		return nil
+	}
+	var v map[string]struct {
+		AdditionalCycles int64 `json:"additional_cycles"`
+	}
+	if err := json.Unmarshal(buf, &v); err != nil {
+		return fmt.Errorf("rpc: invalid history mode: %v", err)
+	}
+	for k, c := range v {
+		m.Mode = k
+		m.AdditionalCycles = c.AdditionalCycles
+	}
+	return nil
+}
+
+// GetCheckpoint returns the node's checkpoint, the last block which is
+// considered final. The node refuses to switch to chains forking below it.
+// https://tezos.gitlab.io/shell/rpc.html#get-chains-chain-id-levels-checkpoint
+func (c *Client) GetCheckpoint(ctx context.Context) (ChainLevel, error) {
+	var l ChainLevel
+	err := c.Get(ctx, "chains/main/levels/checkpoint", &l)
+	return l, err
+}
+
+// GetSavepoint returns the lowest block for which the node still keeps
+// metadata (receipts).
+// https://tezos.gitlab.io/shell/rpc.html#get-chains-chain-id-levels-savepoint
+func (c *Client) GetSavepoint(ctx context.Context) (ChainLevel, error) {
+	var l ChainLevel
+	err := c.Get(ctx, "chains/main/levels/savepoint", &l)
+	return l, err
+}
+
+// GetCaboose returns the lowest block the node still stores. Blocks between
+// caboose and savepoint are available without metadata.
+// https://tezos.gitlab.io/shell/rpc.html#get-chains-chain-id-levels-caboose
+func (c *Client) GetCaboose(ctx context.Context) (ChainLevel, error) {
+	var l ChainLevel
+	err := c.Get(ctx, "chains/main/levels/caboose", &l)
+	return l, err
+}
+
+// GetHistoryMode returns the history mode the node runs in.
+// https://tezos.gitlab.io/shell/rpc.html#get-config-history-mode
+func (c *Client) GetHistoryMode(ctx context.Context) (HistoryMode, error) {
+	var v struct {
+		Mode HistoryMode `json:"history_mode"`
+	}
+	err := c.Get(ctx, "config/history_mode", &v)
+	return v.Mode, err
+}
+
+// HistoryInfo summarizes the history available on a node.
+type HistoryInfo struct {
+	Mode       HistoryMode `json:"history_mode"`
+	Checkpoint ChainLevel  `json:"checkpoint"`
+	Savepoint  ChainLevel  `json:"savepoint"`
+	Caboose    ChainLevel  `json:"caboose"`
+}
+
+// HasBlock returns true when the node stores the block at level.
+func (h HistoryInfo) HasBlock(level int64) bool {
+	return level >= h.Caboose.Level
+}
+
+// HasMetadata returns true when the node stores the block at level with
+// metadata.
+func (h HistoryInfo) HasMetadata(level int64) bool {
+	return level >= h.Savepoint.Level
+}
+
+// GetHistoryInfo returns history mode and history boundaries of the node.
+// Use it to verify a rolling or full node still has the history depth
+// required before backfilling from level.
+//
+//	h, err := c.GetHistoryInfo(ctx)
+//	if err == nil && !h.HasMetadata(start) {
+//	    // use an archive node
+//	}
+func (c *Client) GetHistoryInfo(ctx context.Context) (*HistoryInfo, error) {
+	var (
+		h   HistoryInfo
+		err error
+	)
+	if h.Mode, err = c.GetHistoryMode(ctx); err != nil {
+		return nil, err
+	}
+	if h.Checkpoint, err = c.GetCheckpoint(ctx); err != nil {
+		return nil, err
+	}
+	if h.Savepoint, err = c.GetSavepoint(ctx); err != nil {
+		return nil, err
+	}
+	if h.Caboose, err = c.GetCaboose(ctx); err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
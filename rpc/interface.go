@@ -13,6 +13,9 @@ import (
 // Ensure Client implements the RpcClient interface
 var _ RpcClient = (*Client)(nil)
 
+// Ensure Client implements the codec.BranchResolver interface
+var _ codec.BranchResolver = (*Client)(nil)
+
 // RpcClient interface for various clients implementations and mocks generation
 type RpcClient interface {
 	Init(ctx context.Context) error
@@ -21,6 +24,7 @@ type RpcClient interface {
 	Listen()
 	Close()
 	ResolveChainConfig(ctx context.Context) error
+	ChainParams() *mavryk.Params
 	Get(ctx context.Context, urlpath string, result interface{}) error
 	GetAsync(ctx context.Context, urlpath string, mon Monitor) error
 	Put(ctx context.Context, urlpath string, body, result interface{}) error
@@ -43,6 +47,9 @@ type RpcClient interface {
 	GetChainId(ctx context.Context) (mavryk.ChainIdHash, error)
 	GetStatus(ctx context.Context) (Status, error)
 	GetVersionInfo(ctx context.Context) (VersionInfo, error)
+	GetNodeVersion(ctx context.Context) (NodeVersion, error)
+	GetProtocols(ctx context.Context) ([]mavryk.ProtocolHash, error)
+	GetProtocolData(ctx context.Context, p mavryk.ProtocolHash) (*ProtocolData, error)
 	GetConstants(ctx context.Context, id BlockID) (con Constants, err error)
 	GetCustomConstants(ctx context.Context, id BlockID, resp any) error
 	GetParams(ctx context.Context, id BlockID) (*mavryk.Params, error)
@@ -70,6 +77,7 @@ type RpcClient interface {
 	GetMempool(ctx context.Context) (*Mempool, error)
 	MonitorBootstrapped(ctx context.Context, monitor *BootstrapMonitor) error
 	MonitorBlockHeader(ctx context.Context, monitor *BlockHeaderMonitor) error
+	MonitorValidatedBlocks(ctx context.Context, monitor *ValidatedBlockMonitor) error
 	MonitorMempool(ctx context.Context, monitor *MempoolMonitor) error
 	MonitorNetworkPointLog(ctx context.Context, address string, monitor *NetworkPointMonitor) error
 	MonitorNetworkPeerLog(ctx context.Context, peerID string, monitor *NetworkPeerMonitor) error
@@ -96,6 +104,7 @@ type RpcClient interface {
 	GetBlockOperations(ctx context.Context, id BlockID) ([][]Operation, error)
 	BroadcastOperation(ctx context.Context, body []byte) (hash mavryk.OpHash, err error)
 	RunOperation(ctx context.Context, id BlockID, body, resp interface{}) error
+	PreapplyOperations(ctx context.Context, id BlockID, ops []*codec.Op, resp interface{}) error
 	ForgeOperation(ctx context.Context, id BlockID, body, resp interface{}) error
 	ListBakingRights(ctx context.Context, id BlockID, max int) ([]BakingRight, error)
 	ListBakingRightsCycle(ctx context.Context, id BlockID, cycle int64, max int) ([]BakingRight, error)
@@ -110,6 +119,8 @@ type RpcClient interface {
 	Validate(ctx context.Context, o *codec.Op) error
 	Broadcast(ctx context.Context, o *codec.Op) (mavryk.OpHash, error)
 	Send(ctx context.Context, op *codec.Op, opts *CallOptions) (*Receipt, error)
+	Prepare(ctx context.Context, op *codec.Op, key mavryk.Key, opts *CallOptions) (*SigningBundle, error)
+	Finalize(ctx context.Context, b *SigningBundle, sig mavryk.Signature, opts *CallOptions) (*Receipt, error)
 	RunCode(ctx context.Context, id BlockID, body, resp interface{}) error
 	RunCallback(ctx context.Context, id BlockID, body, resp interface{}) error
 	RunView(ctx context.Context, id BlockID, body, resp interface{}) error
@@ -1,4 +1,4 @@
-// Copyright (c) 2023 Blockwatch Data Inc.
+// Copyright (c) 2023-2024 Blockwatch Data Inc.
 // Author: alex@blockwatch.cc
 
 package rpc
@@ -6,6 +6,9 @@ package rpc
 import (
 	"context"
 	"fmt"
+	"strconv"
+
+	"github.com/mavryk-network/mvgo/mavryk"
 )
 
 type IssuanceParameters struct {
@@ -22,8 +25,139 @@ type IssuanceParameters struct {
 func (c *Client) GetIssuance(ctx context.Context, id BlockID) ([]IssuanceParameters, error) {
 	u := fmt.Sprintf("chains/main/blocks/%s/context/issuance/expected_issuance", id)
 	p := make([]IssuanceParameters, 0, 5)
-	if err := c.Get(ctx, u, p); err != nil {
+	if err := c.Get(ctx, u, &p); err != nil {
 		return nil, err
 	}
 	return p, nil
 }
+
+// IssuanceRate is an exact yearly issuance rate.
+type IssuanceRate struct {
+	Numerator   mavryk.Z `json:"numerator"`
+	Denominator mavryk.Z `json:"denominator"`
+}
+
+// Float64 returns the rate in percent.
+func (r IssuanceRate) Float64() float64 {
+	if r.Denominator.IsZero() {
+		return 0
+	}
+	return r.Numerator.Float64(0) * 100 / r.Denominator.Float64(0)
+}
+
+// IssuanceRateDetails splits the current yearly rate into its static and
+// dynamic (stake dependent) parts, both in percent.
+type IssuanceRateDetails struct {
+	Static  float64 `json:"static,string"`
+	Dynamic float64 `json:"dynamic,string"`
+}
+
+// IssuanceInfo summarizes the current adaptive issuance state.
+type IssuanceInfo struct {
+	LaunchCycle      int64                `json:"launch_cycle"`       // -1 when AI is not launched
+	YearlyRate       float64              `json:"yearly_rate"`        // in percent
+	YearlyRateExact  IssuanceRate         `json:"yearly_rate_exact"`  // exact rate
+	YearlyRateDetail IssuanceRateDetails  `json:"yearly_rate_detail"` // static/dynamic split
+	PerMinute        int64                `json:"per_minute"`         // mumav
+	TotalSupply      int64                `json:"total_supply"`       // mumav
+	TotalFrozenStake int64                `json:"total_frozen_stake"` // mumav
+	Expected         []IssuanceParameters `json:"expected"`           // per future cycle
+}
+
+// GetIssuanceLaunchCycle returns the cycle in which adaptive issuance was or
+// will be activated. Returns -1 when the launch cycle is not yet known.
+func (c *Client) GetIssuanceLaunchCycle(ctx context.Context, id BlockID) (int64, error) {
+	u := fmt.Sprintf("chains/main/blocks/%s/context/adaptive_issuance_launch_cycle", id)
+	var cycle *int64
+	if err := c.Get(ctx, u, &cycle); err != nil {
+		return -1, err
+	}
+	if cycle == nil {
+		return -1, nil
+	}
+	return *cycle, nil
+}
+
+// GetIssuanceYearlyRate returns the current yearly issuance rate in percent.
+func (c *Client) GetIssuanceYearlyRate(ctx context.Context, id BlockID) (float64, error) {
+	u := fmt.Sprintf("chains/main/blocks/%s/context/issuance/current_yearly_rate", id)
+	var s string
+	if err := c.Get(ctx, u, &s); err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// GetIssuanceYearlyRateExact returns the current yearly issuance rate as fraction.
+func (c *Client) GetIssuanceYearlyRateExact(ctx context.Context, id BlockID) (IssuanceRate, error) {
+	u := fmt.Sprintf("chains/main/blocks/%s/context/issuance/current_yearly_rate_exact", id)
+	var r IssuanceRate
+	err := c.Get(ctx, u, &r)
+	return r, err
+}
+
+// GetIssuanceYearlyRateDetails returns the static and dynamic parts of the
+// current yearly issuance rate.
+func (c *Client) GetIssuanceYearlyRateDetails(ctx context.Context, id BlockID) (IssuanceRateDetails, error) {
+	u := fmt.Sprintf("chains/main/blocks/%s/context/issuance/current_yearly_rate_details", id)
+	var r IssuanceRateDetails
+	err := c.Get(ctx, u, &r)
+	return r, err
+}
+
+// GetIssuancePerMinute returns the current issuance per minute in mumav.
+func (c *Client) GetIssuancePerMinute(ctx context.Context, id BlockID) (int64, error) {
+	u := fmt.Sprintf("chains/main/blocks/%s/context/issuance/issuance_per_minute", id)
+	var z mavryk.Z
+	err := c.Get(ctx, u, &z)
+	return z.Int64(), err
+}
+
+// GetTotalSupply returns the total supply in mumav at block id.
+func (c *Client) GetTotalSupply(ctx context.Context, id BlockID) (int64, error) {
+	u := fmt.Sprintf("chains/main/blocks/%s/context/total_supply", id)
+	var z mavryk.Z
+	err := c.Get(ctx, u, &z)
+	return z.Int64(), err
+}
+
+// GetTotalFrozenStake returns the total frozen stake in mumav at block id.
+func (c *Client) GetTotalFrozenStake(ctx context.Context, id BlockID) (int64, error) {
+	u := fmt.Sprintf("chains/main/blocks/%s/context/total_frozen_stake", id)
+	var z mavryk.Z
+	err := c.Get(ctx, u, &z)
+	return z.Int64(), err
+}
+
+// GetIssuanceInfo collects the current adaptive issuance state at block id.
+func (c *Client) GetIssuanceInfo(ctx context.Context, id BlockID) (*IssuanceInfo, error) {
+	var (
+		info = &IssuanceInfo{}
+		err  error
+	)
+	if info.LaunchCycle, err = c.GetIssuanceLaunchCycle(ctx, id); err != nil {
+		return nil, err
+	}
+	if info.YearlyRate, err = c.GetIssuanceYearlyRate(ctx, id); err != nil {
+		return nil, err
+	}
+	if info.YearlyRateExact, err = c.GetIssuanceYearlyRateExact(ctx, id); err != nil {
+		return nil, err
+	}
+	if info.YearlyRateDetail, err = c.GetIssuanceYearlyRateDetails(ctx, id); err != nil {
+		return nil, err
+	}
+	if info.PerMinute, err = c.GetIssuancePerMinute(ctx, id); err != nil {
+		return nil, err
+	}
+	if info.TotalSupply, err = c.GetTotalSupply(ctx, id); err != nil {
+		return nil, err
+	}
+	if info.TotalFrozenStake, err = c.GetTotalFrozenStake(ctx, id); err != nil {
+		return nil, err
+	}
+	if info.Expected, err = c.GetIssuance(ctx, id); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
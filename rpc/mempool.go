@@ -1,4 +1,4 @@
-// Copyright (c) 2020-2022 Blockwatch Data Inc.
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
 // Author: alex@blockwatch.cc
 
 package rpc
@@ -6,11 +6,74 @@ package rpc
 import (
 	"context"
 	"encoding/json"
+	"net/url"
+	"strconv"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+)
+
+// MempoolState is the validation state of a pending operation.
+type MempoolState string
+
+const (
+	MempoolValidated     MempoolState = "validated"
+	MempoolRefused       MempoolState = "refused"
+	MempoolOutdated      MempoolState = "outdated"
+	MempoolBranchRefused MempoolState = "branch_refused"
+	MempoolBranchDelayed MempoolState = "branch_delayed"
 )
 
-// Mempool represents mempool operations
+// MempoolStates lists all validation states which can be filtered.
+var MempoolStates = []MempoolState{
+	MempoolValidated,
+	MempoolRefused,
+	MempoolOutdated,
+	MempoolBranchRefused,
+	MempoolBranchDelayed,
+}
+
+// MempoolFilter selects the operations returned by GetMempoolExt. Empty
+// fields use node defaults which return operations in all states.
+type MempoolFilter struct {
+	Version          int              // response format version, both 1 and 2 are supported
+	States           []MempoolState   // only return operations in these states
+	ValidationPasses []int            // only return operations for these validation passes
+	Sources          []mavryk.Address // only return operations signed by these sources
+}
+
+// Query returns the URL query parameters for filter f.
+func (f MempoolFilter) Query() url.Values {
+	q := url.Values{}
+	if f.Version > 0 {
+		q.Set("version", strconv.Itoa(f.Version))
+	}
+	if len(f.States) > 0 {
+		for _, s := range MempoolStates {
+			q.Set(string(s), strconv.FormatBool(f.hasState(s)))
+		}
+	}
+	for _, v := range f.ValidationPasses {
+		q.Add("validation_pass", strconv.Itoa(v))
+	}
+	for _, v := range f.Sources {
+		q.Add("sources", v.String())
+	}
+	return q
+}
+
+func (f MempoolFilter) hasState(s MempoolState) bool {
+	for _, v := range f.States {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Mempool represents mempool operations. Operations in all lists except
+// Applied carry the node's classification errors.
 type Mempool struct {
-	Applied       []*Operation `json:"applied"`
+	Applied       []*Operation `json:"applied"` // validated
 	Refused       []*Operation `json:"refused"`
 	Outdated      []*Operation `json:"outdated"` // v012+
 	BranchRefused []*Operation `json:"branch_refused"`
@@ -20,16 +83,61 @@ type Mempool struct {
 
 // GetMempool returns mempool pending operations
 func (c *Client) GetMempool(ctx context.Context) (*Mempool, error) {
+	return c.GetMempoolExt(ctx, MempoolFilter{})
+}
+
+// GetMempoolExt returns mempool pending operations which match filter f.
+func (c *Client) GetMempoolExt(ctx context.Context, f MempoolFilter) (*Mempool, error) {
+	u := url.URL{
+		Path:     "chains/main/mempool/pending_operations",
+		RawQuery: f.Query().Encode(),
+	}
 	var mem Mempool
-	if err := c.Get(ctx, "chains/main/mempool/pending_operations", &mem); err != nil {
+	if err := c.Get(ctx, u.String(), &mem); err != nil {
 		return nil, err
 	}
 	return &mem, nil
 }
 
+// Bucket returns pending operations in state s.
+func (m Mempool) Bucket(s MempoolState) []*Operation {
+	switch s {
+	case MempoolValidated:
+		return m.Applied
+	case MempoolRefused:
+		return m.Refused
+	case MempoolOutdated:
+		return m.Outdated
+	case MempoolBranchRefused:
+		return m.BranchRefused
+	case MempoolBranchDelayed:
+		return m.BranchDelayed
+	default:
+		return nil
+	}
+}
+
+// Find looks up a pending operation by hash and returns its state.
+func (m Mempool) Find(hash mavryk.OpHash) (*Operation, MempoolState, bool) {
+	for _, s := range MempoolStates {
+		for _, op := range m.Bucket(s) {
+			if op.Hash.Equal(hash) {
+				return op, s, true
+			}
+		}
+	}
+	return nil, "", false
+}
+
+// PendingOperation decodes classified mempool operations which version 1
+// of the mempool API encodes as [hash, operation] tuple and version 2 as
+// operation object.
 type PendingOperation Operation
 
 func (o *PendingOperation) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '{' {
+		return json.Unmarshal(data, (*Operation)(o))
+	}
 	return unmarshalMultiTypeJSONArray(data, &o.Hash, (*Operation)(o))
 }
 
@@ -40,6 +148,7 @@ func (o PendingOperation) MarshalJSON() ([]byte, error) {
 func (m *Mempool) UnmarshalJSON(data []byte) error {
 	type mempool struct {
 		Applied       []*Operation        `json:"applied"`
+		Validated     []*Operation        `json:"validated"` // version 2
 		Refused       []*PendingOperation `json:"refused"`
 		Outdated      []*PendingOperation `json:"outdated"`
 		BranchRefused []*PendingOperation `json:"branch_refused"`
@@ -52,7 +161,9 @@ func (m *Mempool) UnmarshalJSON(data []byte) error {
 	}
 	// applied is the correct type
 	m.Applied = mp.Applied
-	mp.Applied = mp.Applied[:0]
+	if len(mp.Validated) > 0 {
+		m.Applied = append(m.Applied, mp.Validated...)
+	}
 
 	// type-convert the rest
 	type convert struct {
@@ -0,0 +1,111 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+)
+
+// ErrMetadataStripped is matched by errors which report operations whose
+// receipts were stripped by the node.
+var ErrMetadataStripped = errors.New("rpc: operation metadata too large")
+
+// OperationPos identifies an operation group inside a block.
+type OperationPos struct {
+	List int           `json:"list"`
+	Pos  int           `json:"pos"`
+	Hash mavryk.OpHash `json:"hash"`
+}
+
+// MetadataStrippedError lists operations in a block which still lack
+// metadata after recovery. Use errors.Is(err, ErrMetadataStripped) to test.
+type MetadataStrippedError struct {
+	Block mavryk.BlockHash
+	Ops   []OperationPos
+}
+
+func (e *MetadataStrippedError) Error() string {
+	return fmt.Sprintf("rpc: metadata too large for %d operations in block %s", len(e.Ops), e.Block)
+}
+
+func (e *MetadataStrippedError) Unwrap() error {
+	return ErrMetadataStripped
+}
+
+// IsMetadataStripped returns true when the node replaced the operation
+// receipts with a "too large" marker. Balance updates, results and internal
+// operations are missing from such operations.
+func (o Operation) IsMetadataStripped() bool {
+	return strings.Contains(o.Metadata, "too large")
+}
+
+// StrippedOperations returns the positions of all operations in block b
+// without metadata.
+func (b Block) StrippedOperations() []OperationPos {
+	var res []OperationPos
+	for l, list := range b.Operations {
+		for n, op := range list {
+			if op != nil && op.IsMetadataStripped() {
+				res = append(res, OperationPos{List: l, Pos: n, Hash: op.Hash})
+			}
+		}
+	}
+	return res
+}
+
+// HasStrippedMetadata returns true when at least one operation in block b
+// lacks metadata.
+func (b Block) HasStrippedMetadata() bool {
+	for _, list := range b.Operations {
+		for _, op := range list {
+			if op != nil && op.IsMetadataStripped() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RecoverBlockMetadata refetches all operations in block b whose metadata
+// was stripped with metadata mode always and replaces them in place. It
+// returns a MetadataStrippedError when the node still refuses to return
+// metadata for some operations, e.g. because it does not keep enough
+// history to reconstruct them.
+func (c *Client) RecoverBlockMetadata(ctx context.Context, b *Block) error {
+	var failed []OperationPos
+	for _, pos := range b.StrippedOperations() {
+		op, err := c.getOperationWithMetadata(ctx, b.Hash, pos.List, pos.Pos)
+		if err != nil {
+			return err
+		}
+		if op.IsMetadataStripped() {
+			failed = append(failed, pos)
+			continue
+		}
+		b.Operations[pos.List][pos.Pos] = op
+	}
+	if len(failed) > 0 {
+		return &MetadataStrippedError{Block: b.Hash, Ops: failed}
+	}
+	return nil
+}
+
+// getOperationWithMetadata fetches a single operation and forces the node
+// to reconstruct its metadata.
+func (c *Client) getOperationWithMetadata(ctx context.Context, id BlockID, l, n int) (*Operation, error) {
+	var op Operation
+	u := fmt.Sprintf("chains/main/blocks/%s/operations/%d/%d", id, l, n)
+	if c.Supports(FeatureMetadataQuery) {
+		u += "?metadata=" + string(MetadataModeAlways)
+	}
+	if err := c.Get(ctx, u, &op); err != nil {
+		return nil, err
+	}
+	return &op, nil
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Metrics receives an event for every RPC request and monitor reconnect.
+// Implementations must be safe for concurrent use. See module rpc/metrics
+// for a Prometheus collector which is kept separate so the Prometheus client
+// is not a dependency of this package.
+type Metrics interface {
+	// ObserveRequest is called when a response was received or the request
+	// failed. Status is zero when no response was received.
+	ObserveRequest(method, class string, status int, d time.Duration, err error)
+	// ObserveReconnect is called when a monitor stream is reconnected.
+	ObserveReconnect(monitor string)
+}
+
+// HeadMetrics is an optional extension of Metrics. Block observers report
+// every new head so implementations can track chain lag.
+type HeadMetrics interface {
+	ObserveHead(level int64, ts time.Time)
+}
+
+// InjectionMetrics is an optional extension of Metrics which receives the
+// outcome of operations broadcast by the client.
+type InjectionMetrics interface {
+	ObserveInjection(outcome string)
+}
+
+// Injection outcomes reported to metrics
+const (
+	InjectionAccepted = "accepted" // node accepted the operation into its mempool
+	InjectionRejected = "rejected" // node refused the operation
+	InjectionApplied  = "applied"  // operation was included and applied
+	InjectionFailed   = "failed"   // operation was included but failed
+	InjectionExpired  = "expired"  // operation was not included before its TTL
+)
+
+// Endpoint classes reported to metrics
+const (
+	EndpointBlock      = "block"
+	EndpointOperations = "operations"
+	EndpointContext    = "context"
+	EndpointHelpers    = "helpers"
+	EndpointMempool    = "mempool"
+	EndpointMonitor    = "monitor"
+	EndpointInjection  = "injection"
+	EndpointChain      = "chain"
+	EndpointNetwork    = "network"
+	EndpointOther      = "other"
+)
+
+// EndpointClass maps an RPC path to a small set of endpoint classes which
+// can be used as low cardinality metrics label.
+func EndpointClass(urlpath string) string {
+	path, _, _ := strings.Cut(strings.TrimPrefix(urlpath, "/"), "?")
+	switch {
+	case strings.HasPrefix(path, "monitor/"), strings.Contains(path, "/monitor_operations"):
+		return EndpointMonitor
+	case strings.HasPrefix(path, "injection/"):
+		return EndpointInjection
+	case strings.HasPrefix(path, "network/"):
+		return EndpointNetwork
+	case strings.HasPrefix(path, "chains/"):
+		parts := strings.SplitN(path, "/", 6)
+		switch {
+		case len(parts) > 2 && parts[2] == "mempool":
+			return EndpointMempool
+		case len(parts) > 2 && parts[2] == "blocks":
+			if len(parts) < 5 {
+				return EndpointBlock
+			}
+			switch parts[4] {
+			case "context":
+				return EndpointContext
+			case "helpers":
+				return EndpointHelpers
+			case "operations", "operation_hashes":
+				return EndpointOperations
+			default:
+				return EndpointBlock
+			}
+		default:
+			return EndpointChain
+		}
+	default:
+		return EndpointOther
+	}
+}
+
+// observe reports a finished request to metrics.
+func (c *Client) observe(req *http.Request, resp *http.Response, start time.Time, err error) {
+	if c.Metrics == nil {
+		return
+	}
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	path := req.URL.Path
+	if c.BaseURL != nil {
+		path = strings.TrimPrefix(path, strings.TrimSuffix(c.BaseURL.Path, "/"))
+	}
+	c.Metrics.ObserveRequest(req.Method, EndpointClass(path), status, time.Since(start), err)
+}
+
+// observeHead reports a new head to metrics.
+func (c *Client) observeHead(head *BlockHeaderLogEntry) {
+	if m, ok := c.Metrics.(HeadMetrics); ok {
+		m.ObserveHead(head.Level, head.Timestamp)
+	}
+}
+
+// observeInjection reports an injection outcome to metrics.
+func (c *Client) observeInjection(outcome string) {
+	if m, ok := c.Metrics.(InjectionMetrics); ok {
+		m.ObserveInjection(outcome)
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+// Package metrics provides a Prometheus collector for RPC client metrics. It
+// is a separate module, so only users of this package depend on Prometheus.
+//
+//	go get github.com/mavryk-network/mvgo/rpc/metrics
+//
+//	m, err := metrics.Register(prometheus.DefaultRegisterer, "myapp", client)
+//
+// or, to set up the collector manually
+//
+//	m := metrics.NewCollector("myapp")
+//	prometheus.MustRegister(m)
+//	client.Metrics = m
+package metrics
+
+import (
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/mavryk-network/mvgo/rpc"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector records RPC request counts, latencies, errors, monitor
+// reconnects, head lag and injection outcomes. It implements rpc.Metrics,
+// its optional extensions and prometheus.Collector.
+type Collector struct {
+	requests   *prometheus.CounterVec
+	errors     *prometheus.CounterVec
+	latency    *prometheus.HistogramVec
+	reconnects *prometheus.CounterVec
+	injections *prometheus.CounterVec
+	headLevel  prometheus.Gauge
+	headLag    prometheus.GaugeFunc
+	headTime   int64 // unix nanoseconds, atomic
+}
+
+var (
+	_ rpc.Metrics          = (*Collector)(nil)
+	_ rpc.HeadMetrics      = (*Collector)(nil)
+	_ rpc.InjectionMetrics = (*Collector)(nil)
+	_ prometheus.Collector = (*Collector)(nil)
+)
+
+// Register creates a collector, registers it with reg and installs it as
+// metrics receiver on all clients. Use prometheus.DefaultRegisterer to
+// register with the global registry.
+func Register(reg prometheus.Registerer, namespace string, clients ...*rpc.Client) (*Collector, error) {
+	c := NewCollector(namespace)
+	if err := reg.Register(c); err != nil {
+		return nil, err
+	}
+	for _, v := range clients {
+		v.Metrics = c
+	}
+	return c, nil
+}
+
+// NewCollector creates a collector with metrics in namespace (may be empty)
+// and subsystem mvgo_rpc.
+func NewCollector(namespace string) *Collector {
+	const subsystem = "mvgo_rpc"
+	c := &Collector{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "requests_total",
+			Help:      "Number of RPC requests by method, endpoint class and status code.",
+		}, []string{"method", "class", "code"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "errors_total",
+			Help:      "Number of failed RPC requests by endpoint class and reason.",
+		}, []string{"class", "reason"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "request_duration_seconds",
+			Help:      "RPC request latency by endpoint class.",
+			Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+		}, []string{"class"}),
+		reconnects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "monitor_reconnects_total",
+			Help:      "Number of monitor stream reconnects.",
+		}, []string{"monitor"}),
+		injections: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "injections_total",
+			Help:      "Number of injected operations by outcome.",
+		}, []string{"outcome"}),
+		headLevel: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "head_level",
+			Help:      "Level of the latest block seen by block observers.",
+		}),
+	}
+	c.headLag = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "head_lag_seconds",
+		Help:      "Time since the timestamp of the latest block seen by block observers.",
+	}, c.lag)
+	return c
+}
+
+func (c *Collector) ObserveRequest(method, class string, status int, d time.Duration, err error) {
+	code := "none"
+	if status > 0 {
+		code = strconv.Itoa(status)
+	}
+	c.requests.WithLabelValues(method, class, code).Inc()
+	c.latency.WithLabelValues(class).Observe(d.Seconds())
+	switch {
+	case err != nil:
+		c.errors.WithLabelValues(class, "network").Inc()
+	case status >= 500:
+		c.errors.WithLabelValues(class, "server").Inc()
+	case status == 429:
+		c.errors.WithLabelValues(class, "rate_limit").Inc()
+	case status >= 400:
+		c.errors.WithLabelValues(class, "client").Inc()
+	}
+}
+
+func (c *Collector) ObserveReconnect(monitor string) {
+	c.reconnects.WithLabelValues(monitor).Inc()
+}
+
+func (c *Collector) ObserveHead(level int64, ts time.Time) {
+	c.headLevel.Set(float64(level))
+	atomic.StoreInt64(&c.headTime, ts.UnixNano())
+}
+
+func (c *Collector) ObserveInjection(outcome string) {
+	c.injections.WithLabelValues(outcome).Inc()
+}
+
+// lag returns seconds since the last head or zero before the first head.
+func (c *Collector) lag() float64 {
+	ts := atomic.LoadInt64(&c.headTime)
+	if ts == 0 {
+		return 0
+	}
+	return time.Since(time.Unix(0, ts)).Seconds()
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.requests.Describe(ch)
+	c.errors.Describe(ch)
+	c.latency.Describe(ch)
+	c.reconnects.Describe(ch)
+	c.injections.Describe(ch)
+	c.headLevel.Describe(ch)
+	c.headLag.Describe(ch)
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.requests.Collect(ch)
+	c.errors.Collect(ch)
+	c.latency.Collect(ch)
+	c.reconnects.Collect(ch)
+	c.injections.Collect(ch)
+	c.headLevel.Collect(ch)
+	c.headLag.Collect(ch)
+}
@@ -0,0 +1,54 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc
+
+import (
+	"net/http"
+	"time"
+)
+
+// RoundTripFunc sends a request and returns its response.
+type RoundTripFunc func(req *http.Request) (*http.Response, error)
+
+// Interceptor is a middleware which wraps every HTTP request sent by a client.
+// Interceptors may modify the request (e.g. add auth headers or rewrite the
+// URL) before calling next and may inspect or replace the response. To abort
+// a request return an error without calling next. Responses returned by
+// interceptors are processed by the client as usual.
+type Interceptor func(req *http.Request, next RoundTripFunc) (*http.Response, error)
+
+// Use appends interceptors to the client's chain. Interceptors run in the
+// order they were added, i.e. the first interceptor sees the request first
+// and the response last. Use must not be called concurrently with requests.
+func (c *Client) Use(fn ...Interceptor) *Client {
+	c.interceptors = append(c.interceptors, fn...)
+	return c
+}
+
+// do sends req through the rate limiter, the interceptor chain and the
+// HTTP client.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	if err := c.limit(req); err != nil {
+		return nil, err
+	}
+	next := RoundTripFunc(c.send)
+	if c.Metrics != nil {
+		next = func(r *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := c.send(r)
+			c.observe(r, resp, start, err)
+			return resp, err
+		}
+	}
+	if c.RequestLog != nil {
+		next = c.logRequest(next)
+	}
+	for i := len(c.interceptors) - 1; i >= 0; i-- {
+		fn, n := c.interceptors[i], next
+		next = func(r *http.Request) (*http.Response, error) {
+			return fn(r, n)
+		}
+	}
+	return next(req)
+}
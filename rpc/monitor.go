@@ -1,4 +1,4 @@
-// Copyright (c) 2020-2022 Blockwatch Data Inc.
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
 // Author: alex@blockwatch.cc
 
 package rpc
@@ -7,9 +7,12 @@ import (
 	"context"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
+	"net/url"
 	"time"
 
+	"github.com/mavryk-network/mvgo/codec"
 	"github.com/mavryk-network/mvgo/mavryk"
 )
 
@@ -131,18 +134,86 @@ func (h *BlockHeader) LogEntry() *BlockHeaderLogEntry {
 	}
 }
 
-func (l BlockHeaderLogEntry) Round() int {
-	return int(binary.BigEndian.Uint32(l.ProtocolData[32:]))
+// minTenderbakeProtocolDataLen is the size of Tenderbake protocol data without
+// seed nonce hash and signature (payload hash, round, pow nonce, seed nonce flag
+// and per block votes).
+const minTenderbakeProtocolDataLen = 32 + 4 + 8 + 1 + 1
+
+// IsTenderbake returns true when the entry contains a Tenderbake block header.
+// Genesis and Emmy headers use a different fitness and protocol data format.
+func (l BlockHeaderLogEntry) IsTenderbake() bool {
+	return len(l.Fitness) > 0 && len(l.Fitness[0]) == 1 && l.Fitness[0][0] == 2 &&
+		len(l.ProtocolData) >= minTenderbakeProtocolDataLen
 }
 
-func (l BlockHeaderLogEntry) PayloadHash() (h mavryk.PayloadHash) {
-	copy(h[:], l.ProtocolData[:])
-	return
+// ParseRound returns the payload round from protocol data. Returns false
+// when the header is not a Tenderbake header.
+func (l BlockHeaderLogEntry) ParseRound() (int, bool) {
+	if !l.IsTenderbake() {
+		return 0, false
+	}
+	return int(binary.BigEndian.Uint32(l.ProtocolData[32:])), true
+}
+
+// ParsePayloadHash returns the payload hash from protocol data. Returns false
+// when the header is not a Tenderbake header.
+func (l BlockHeaderLogEntry) ParsePayloadHash() (h mavryk.PayloadHash, ok bool) {
+	if !l.IsTenderbake() {
+		return
+	}
+	copy(h[:], l.ProtocolData[:32])
+	return h, true
 }
 
-func (l BlockHeaderLogEntry) Pow() (h mavryk.HexBytes) {
+// ParsePow returns the proof of work nonce from protocol data. Returns false
+// when the header is not a Tenderbake header.
+func (l BlockHeaderLogEntry) ParsePow() (mavryk.HexBytes, bool) {
+	if !l.IsTenderbake() {
+		return nil, false
+	}
+	var h mavryk.HexBytes
 	h.UnmarshalBinary(l.ProtocolData[36:44])
-	return
+	return h, true
+}
+
+// Round returns the payload round or zero for non-Tenderbake headers.
+func (l BlockHeaderLogEntry) Round() int {
+	r, _ := l.ParseRound()
+	return r
+}
+
+// PayloadHash returns the payload hash or a zero hash for non-Tenderbake headers.
+func (l BlockHeaderLogEntry) PayloadHash() mavryk.PayloadHash {
+	h, _ := l.ParsePayloadHash()
+	return h
+}
+
+// Pow returns the proof of work nonce or nil for non-Tenderbake headers.
+func (l BlockHeaderLogEntry) Pow() mavryk.HexBytes {
+	h, _ := l.ParsePow()
+	return h
+}
+
+// Header decodes the entry including all protocol specific fields into a
+// binary codec block header. Only Tenderbake headers are supported.
+func (l BlockHeaderLogEntry) Header() (*codec.BlockHeader, error) {
+	if !l.IsTenderbake() {
+		return nil, fmt.Errorf("rpc: unsupported protocol data format for block %d", l.Level)
+	}
+	h := &codec.BlockHeader{
+		Level:          int32(l.Level),
+		Proto:          byte(l.Proto),
+		Predecessor:    l.Predecessor,
+		Timestamp:      l.Timestamp,
+		ValidationPass: byte(l.ValidationPass),
+		OperationsHash: l.OperationsHash,
+		Fitness:        l.Fitness,
+		Context:        l.Context,
+	}
+	if err := h.UnmarshalProtocolData(l.ProtocolData); err != nil {
+		return nil, err
+	}
+	return h, nil
 }
 
 func (b *Block) LogEntry() *BlockHeaderLogEntry {
@@ -224,6 +295,143 @@ func (m *BlockHeaderMonitor) Closed() <-chan struct{} {
 	return m.closed
 }
 
+// ValidatedOperation is a raw operation in binary form as returned by the
+// validated blocks stream.
+type ValidatedOperation struct {
+	Branch mavryk.BlockHash `json:"branch"`
+	Data   mavryk.HexBytes  `json:"data"`
+}
+
+// Hash returns the operation hash.
+func (o ValidatedOperation) Hash() mavryk.OpHash {
+	buf := make([]byte, 0, 32+len(o.Data))
+	buf = append(buf, o.Branch.Bytes()...)
+	buf = append(buf, o.Data...)
+	h := mavryk.Digest(buf)
+	return mavryk.NewOpHash(h[:])
+}
+
+// ValidatedBlockLogEntry is a log entry returned for a new block when monitoring
+// validated blocks. Blocks are reported after validation, but before they are
+// fully applied which allows consumers to react earlier than on new heads.
+type ValidatedBlockLogEntry struct {
+	ChainId    mavryk.ChainIdHash     `json:"chain_id"`
+	Hash       mavryk.BlockHash       `json:"hash"`
+	Header     BlockHeaderLogEntry    `json:"header"`
+	Operations [][]ValidatedOperation `json:"operations"`
+}
+
+// OpHashes returns hashes for all operations in the block grouped by
+// validation pass.
+func (e ValidatedBlockLogEntry) OpHashes() [][]mavryk.OpHash {
+	hashes := make([][]mavryk.OpHash, len(e.Operations))
+	for i, list := range e.Operations {
+		hashes[i] = make([]mavryk.OpHash, len(list))
+		for j, op := range list {
+			hashes[i][j] = op.Hash()
+		}
+	}
+	return hashes
+}
+
+type ValidatedBlockMonitor struct {
+	result chan *ValidatedBlockLogEntry
+	closed chan struct{}
+	err    error
+}
+
+// make sure ValidatedBlockMonitor implements Monitor interface
+var _ Monitor = (*ValidatedBlockMonitor)(nil)
+
+func NewValidatedBlockMonitor() *ValidatedBlockMonitor {
+	return &ValidatedBlockMonitor{
+		result: make(chan *ValidatedBlockLogEntry),
+		closed: make(chan struct{}),
+	}
+}
+
+func (m *ValidatedBlockMonitor) New() interface{} {
+	return &ValidatedBlockLogEntry{}
+}
+
+func (m *ValidatedBlockMonitor) Send(ctx context.Context, val interface{}) {
+	e := val.(*ValidatedBlockLogEntry)
+	e.Header.Hash = e.Hash
+	select {
+	case <-m.closed:
+		return
+	default:
+	}
+	select {
+	case <-ctx.Done():
+	case <-m.closed:
+	case m.result <- e:
+	}
+}
+
+func (m *ValidatedBlockMonitor) Recv(ctx context.Context) (*ValidatedBlockLogEntry, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-m.closed:
+		err := m.err
+		if err == nil {
+			err = ErrMonitorClosed
+		}
+		return nil, err
+	case res, ok := <-m.result:
+		if !ok {
+			if m.err != nil {
+				return nil, m.err
+			}
+			return nil, io.EOF
+		}
+		return res, nil
+	}
+}
+
+func (m *ValidatedBlockMonitor) Err(err error) {
+	m.err = err
+	m.Close()
+}
+
+func (m *ValidatedBlockMonitor) Close() {
+	select {
+	case <-m.closed:
+		return
+	default:
+	}
+	close(m.closed)
+	close(m.result)
+}
+
+func (m *ValidatedBlockMonitor) Closed() <-chan struct{} {
+	return m.closed
+}
+
+// BlockMonitorFilter restricts validated and applied block streams to blocks
+// of specific chains and protocols. Empty lists match all blocks.
+type BlockMonitorFilter struct {
+	Chains        []mavryk.ChainIdHash
+	Protocols     []mavryk.ProtocolHash
+	NextProtocols []mavryk.ProtocolHash
+}
+
+// Query returns the URL query parameters for filter f.
+func (f BlockMonitorFilter) Query() url.Values {
+	q := url.Values{}
+	for _, v := range f.Chains {
+		q.Add("chain", v.String())
+	}
+	for _, v := range f.Protocols {
+		q.Add("protocol", v.String())
+	}
+	for _, v := range f.NextProtocols {
+		q.Add("next_protocol", v.String())
+	}
+	return q
+}
+
 // MempoolMonitor is a monitor for the Tezos mempool. Note that the connection
 // resets every time a new head is attached to the chain. MempoolMonitor is
 // closed with an error in this case and cannot be reused after close.
@@ -475,6 +683,33 @@ func (c *Client) MonitorBlockHeader(ctx context.Context, monitor *BlockHeaderMon
 	return c.GetAsync(ctx, "monitor/heads/main", monitor)
 }
 
+// MonitorValidatedBlocks reads from the validated blocks stream which reports
+// blocks before they are applied http://tezos.gitlab.io/mainnet/api/rpc.html#get-monitor-validated-blocks
+func (c *Client) MonitorValidatedBlocks(ctx context.Context, monitor *ValidatedBlockMonitor) error {
+	return c.GetAsync(ctx, "monitor/validated_blocks", monitor)
+}
+
+// MonitorValidatedBlocksExt is like MonitorValidatedBlocks but only reports
+// blocks matching filter f.
+func (c *Client) MonitorValidatedBlocksExt(ctx context.Context, f BlockMonitorFilter, monitor *ValidatedBlockMonitor) error {
+	u := url.URL{
+		Path:     "monitor/validated_blocks",
+		RawQuery: f.Query().Encode(),
+	}
+	return c.GetAsync(ctx, u.String(), monitor)
+}
+
+// MonitorAppliedBlocks reads from the applied blocks stream which reports
+// blocks matching filter f after they have been applied. Applied blocks use
+// the same log entry format as validated blocks.
+func (c *Client) MonitorAppliedBlocks(ctx context.Context, f BlockMonitorFilter, monitor *ValidatedBlockMonitor) error {
+	u := url.URL{
+		Path:     "monitor/applied_blocks",
+		RawQuery: f.Query().Encode(),
+	}
+	return c.GetAsync(ctx, u.String(), monitor)
+}
+
 // MonitorMempool reads from the chain heads stream http://tezos.gitlab.io/mainnet/api/rpc.html#get-monitor-heads-chain-id
 func (c *Client) MonitorMempool(ctx context.Context, monitor *MempoolMonitor) error {
 	return c.GetAsync(ctx, "chains/main/mempool/monitor_operations", monitor)
@@ -0,0 +1,124 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+)
+
+// ErrorClass is a sentinel for a group of related node errors. Node errors
+// contain the protocol in their id (e.g. proto.PtAtLas.contract.counter_in_the_past)
+// which makes string matching brittle. Use errors.Is to test RPC errors,
+// error lists and receipt errors against one of the predefined classes.
+//
+//	if errors.Is(err, rpc.ErrCounterInThePast) {
+//	    // refetch counter and retry
+//	}
+type ErrorClass struct {
+	name string
+	kind string   // matches any error of this kind
+	ids  []string // matches error ids ending in one of these suffixes
+}
+
+func (c *ErrorClass) Error() string {
+	return "rpc: " + c.name
+}
+
+// Match returns true when the error id or kind belongs to this class.
+func (c *ErrorClass) Match(e Error) bool {
+	if c.kind != "" && e.ErrorKind() == c.kind {
+		return true
+	}
+	id := e.ErrorID()
+	for _, v := range c.ids {
+		if id == v || strings.HasSuffix(id, "."+v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Node error classes
+var (
+	ErrCounterInThePast    = &ErrorClass{name: "counter in the past", ids: []string{"contract.counter_in_the_past"}}
+	ErrCounterInTheFuture  = &ErrorClass{name: "counter in the future", ids: []string{"contract.counter_in_the_future"}}
+	ErrGasExhausted        = &ErrorClass{name: "gas exhausted", ids: []string{"gas_exhausted.operation", "gas_exhausted.block", "gas_limit_too_high"}}
+	ErrStorageExhausted    = &ErrorClass{name: "storage exhausted", ids: []string{"storage_exhausted.operation", "storage_exhausted.block", "storage_limit_too_high"}}
+	ErrBalanceTooLow       = &ErrorClass{name: "balance too low", ids: []string{"contract.balance_too_low", "contract.cannot_pay_storage_fee"}}
+	ErrFeesTooLow          = &ErrorClass{name: "fees too low", ids: []string{"fees_too_low"}}
+	ErrUnrevealedKey       = &ErrorClass{name: "unrevealed key", ids: []string{"contract.unrevealed_key"}}
+	ErrEmptyAccount        = &ErrorClass{name: "empty implicit contract", ids: []string{"implicit.empty_implicit_contract", "contract.empty_implicit_contract"}}
+	ErrNonExistingContract = &ErrorClass{name: "non existing contract", ids: []string{"contract.non_existing_contract"}}
+	ErrScriptRejected      = &ErrorClass{name: "script rejected", ids: []string{"michelson_v1.script_rejected"}}
+	ErrInvalidSignature    = &ErrorClass{name: "invalid signature", ids: []string{"operation.invalid_signature"}}
+	ErrIllTypedData        = &ErrorClass{name: "ill typed data", ids: []string{"michelson_v1.ill_typed_data", "michelson_v1.invalid_constant"}}
+	ErrIllTypedContract    = &ErrorClass{name: "ill typed contract", ids: []string{"michelson_v1.ill_typed_contract", "michelson_v1.ill_formed_type"}}
+	ErrNonexistentGlobal   = &ErrorClass{name: "nonexistent global constant", ids: []string{"Nonexistent_global", "nonexistent_global"}}
+	ErrBranchRefused       = &ErrorClass{name: "branch refused", kind: ErrorKindBranch}
+)
+
+// Is implements errors.Is for ErrorClass targets.
+func (e GenericError) Is(target error) bool {
+	if c, ok := target.(*ErrorClass); ok {
+		return c.Match(e)
+	}
+	return false
+}
+
+// CounterError is returned when an operation uses a counter other than the
+// next expected counter of its source.
+type CounterError struct {
+	GenericError
+	Contract mavryk.Address `json:"contract"`
+	Expected int64          `json:"expected,string"`
+	Found    int64          `json:"found,string"`
+}
+
+// BalanceError is returned when a source cannot pay for an operation.
+type BalanceError struct {
+	GenericError
+	Contract mavryk.Address `json:"contract"`
+	Balance  int64          `json:"balance,string"`
+	Amount   int64          `json:"amount,string"`
+}
+
+// ScriptRejectedError is returned when a contract call failed with FAILWITH.
+// The rejected value is available in field With.
+type ScriptRejectedError struct {
+	GenericError
+	Location int `json:"location"`
+}
+
+// decodeError decodes a single node error into the most specific Go type.
+func decodeError(data []byte) (Error, error) {
+	var g GenericError
+	if err := json.Unmarshal(data, &g); err != nil {
+		return nil, err
+	}
+	var typed Error
+	switch {
+	case ErrCounterInThePast.Match(g), ErrCounterInTheFuture.Match(g):
+		typed = &CounterError{}
+	case ErrBalanceTooLow.Match(g):
+		typed = &BalanceError{}
+	case ErrScriptRejected.Match(g):
+		typed = &ScriptRejectedError{}
+	default:
+		return &g, nil
+	}
+	// fall back to the generic error when extra fields are malformed
+	if err := json.Unmarshal(data, typed); err != nil {
+		return &g, nil
+	}
+	return typed, nil
+}
+
+var (
+	_ Error = (*CounterError)(nil)
+	_ Error = (*BalanceError)(nil)
+	_ Error = (*ScriptRejectedError)(nil)
+)
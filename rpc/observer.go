@@ -90,6 +90,8 @@ func (m *Observer) Subscribe(oh mavryk.OpHash, cb ObserverCallback) int {
 		m.c.Log.Debugf("monitor: %03d direct match %s", seq, oh)
 		if remove := match.cb(m.head, pos[0], int(pos[1]), int(pos[2]), false); remove {
 			delete(m.subs, match.id)
+		} else {
+			match.matched = true
 		}
 	}
 	m.c.Log.Debugf("monitor: %03d subscribed %s", seq, oh)
@@ -126,8 +128,8 @@ func (m *Observer) removeWatcher(oh mavryk.OpHash, id int) {
 func (m *Observer) Listen(cli *Client) {
 	m.once.Do(func() {
 		m.c = cli
-		if m.c.Params != nil {
-			m.minDelay = m.c.Params.MinimalBlockDelay
+		if p := m.c.params(); p != nil {
+			m.minDelay = p.MinimalBlockDelay
 		}
 		go m.listenBlocks()
 	})
@@ -136,8 +138,8 @@ func (m *Observer) Listen(cli *Client) {
 func (m *Observer) ListenMempool(cli *Client) {
 	m.once.Do(func() {
 		m.c = cli
-		if m.c.Params != nil {
-			m.minDelay = m.c.Params.MinimalBlockDelay
+		if p := m.c.params(); p != nil {
+			m.minDelay = p.MinimalBlockDelay
 		}
 		go m.listenMempool()
 	})
@@ -197,6 +199,9 @@ func (m *Observer) listenBlocks() {
 			if err != nil {
 				mon.Close()
 				mon = nil
+				if m.c.Metrics != nil && m.ctx.Err() == nil {
+					m.c.Metrics.ObserveReconnect("blocks")
+				}
 				continue
 			}
 			// m.c.Log.Debugf("monitor: new head %s", head.Hash)
@@ -229,6 +234,10 @@ func (m *Observer) listenBlocks() {
 			continue
 		}
 		m.c.Log.Debugf("monitor: new block %d %s", head.Level, head.Hash)
+		m.c.observeHead(head)
+
+		// expire cached protocol on upgrades so params are refreshed early
+		m.c.protoCache.observe(head.Proto)
 
 		// TODO: check for reorg and gaps
 
@@ -257,33 +266,24 @@ func (m *Observer) listenBlocks() {
 				}
 			}
 		}
-		// clear recent op hashes
-		for n := range m.recent {
-			delete(m.recent, n)
-		}
-
-		numSubs := len(m.subs)
 		m.mu.Unlock()
 
-		// pull block ops when subs exist
-		var (
-			ohs [][]mavryk.OpHash
-			err error
-		)
-		if numSubs > 0 {
-			ohs, err = m.c.GetBlockOperationHashes(m.ctx, head.Hash)
-			if err != nil {
-				m.c.Log.Warnf("monitor: cannot fetch block ops: %v", err)
-				continue
-			}
+		// pull block ops even without subs because operations may be
+		// subscribed only after they were included; recent op hashes of
+		// the previous block stay valid until this block is matched
+		ohs, err := m.c.GetBlockOperationHashes(m.ctx, head.Hash)
+		if err != nil {
+			m.c.Log.Warnf("monitor: cannot fetch block ops: %v", err)
+			continue
 		}
 
 		// fan-out matches
 		m.mu.Lock()
+		recent := make(map[mavryk.OpHash][3]int64)
 		for l, list := range ohs {
 			for n, h := range list {
 				// keep as recent
-				m.recent[h] = [3]int64{head.Level, int64(l), int64(n)}
+				recent[h] = [3]int64{head.Level, int64(l), int64(n)}
 
 				// match op hash against subs
 				ids, ok := m.watched[h]
@@ -321,6 +321,7 @@ func (m *Observer) listenBlocks() {
 
 		// update monitor state
 		m.head = head
+		m.recent = recent
 		m.mu.Unlock()
 
 		// wait in poll mode
@@ -1,4 +1,4 @@
-// Copyright (c) 2020-2023 Blockwatch Data Inc.
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
 // Author: alex@blockwatch.cc
 
 package rpc
@@ -31,6 +31,7 @@ type Operation struct {
 	Signature mavryk.Signature    `json:"signature"`
 	Errors    []OperationError    `json:"error,omitempty"`    // mempool only
 	Metadata  string              `json:"metadata,omitempty"` // contains `too large` when stripped, this is BAD!!
+	Raw       json.RawMessage     `json:"-"`                  // optional, see Client.RetainRawJSON
 }
 
 // TotalCosts returns the sum of costs across all batched and internal operations.
@@ -82,6 +83,7 @@ type OperationMetadata struct {
 	Slots               []int          `json:"slots,omitempty"`
 	EndorsementPower    int            `json:"endorsement_power,omitempty"`    // v12+
 	PreendorsementPower int            `json:"preendorsement_power,omitempty"` // v12+
+	ConsensusPower      int            `json:"consensus_power,omitempty"`      // v19+
 
 	// some rollup ops only, FIXME: is this correct here or is this field in result?
 	Level int64 `json:"level"`
@@ -186,6 +188,7 @@ func (o *OperationError) UnmarshalJSON(data []byte) error {
 type Generic struct {
 	OpKind   mavryk.OpType     `json:"kind"`
 	Metadata OperationMetadata `json:"metadata"`
+	Raw      json.RawMessage   `json:"-"` // optional, see Client.RetainRawJSON
 }
 
 // Kind returns the operation's type. Implements TypedOperation interface.
@@ -316,7 +319,8 @@ func (e *OperationList) UnmarshalJSON(data []byte) error {
 		// consensus operations
 		case mavryk.OpTypeEndorsement,
 			mavryk.OpTypeEndorsementWithSlot,
-			mavryk.OpTypePreendorsement:
+			mavryk.OpTypePreendorsement,
+			mavryk.OpTypeAttestationWithDal:
 			op = &Endorsement{}
 
 		// amendment operations
@@ -378,6 +382,8 @@ func (e *OperationList) UnmarshalJSON(data []byte) error {
 			op = &DalAttestation{}
 		case mavryk.OpTypeDalPublishSlotHeader:
 			op = &DalPublishSlotHeader{}
+		case mavryk.OpTypeDalEntrapmentEvidence:
+			op = &DalEntrapmentEvidence{}
 
 		default:
 			return fmt.Errorf("rpc: unsupported op %q", string(data[start:end]))
@@ -427,6 +433,9 @@ func (c *Client) GetBlockOperationListHashes(ctx context.Context, id BlockID, l
 // GetBlockOperation returns information about a single validated Tezos operation group
 // (i.e. a single operation or a batch of operations) at list l and position n
 // https://tezos.gitlab.io/active/rpc.html#get-block-id-operations-list-offset-operation-offset
+//
+// When RecoverMetadata is enabled, stripped metadata is refetched once. Check
+// IsMetadataStripped on the result when recovery matters.
 func (c *Client) GetBlockOperation(ctx context.Context, id BlockID, l, n int) (*Operation, error) {
 	var op Operation
 	u := fmt.Sprintf("chains/main/blocks/%s/operations/%d/%d", id, l, n)
@@ -436,6 +445,9 @@ func (c *Client) GetBlockOperation(ctx context.Context, id BlockID, l, n int) (*
 	if err := c.Get(ctx, u, &op); err != nil {
 		return nil, err
 	}
+	if c.RecoverMetadata && op.IsMetadataStripped() && c.MetadataMode != MetadataModeAlways {
+		return c.getOperationWithMetadata(ctx, id, l, n)
+	}
 	return &op, nil
 }
 
@@ -0,0 +1,310 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/mavryk-network/mvgo/signer"
+
+	"github.com/echa/log"
+)
+
+// ClientOption configures a client created with NewClient. Options are
+// applied in order after defaults were set.
+//
+//	c, err := rpc.NewClient(url, nil,
+//	    rpc.WithChain("test"),
+//	    rpc.WithRateLimit(rpc.NewRateLimiter(10, 20)),
+//	)
+type ClientOption func(*Client)
+
+// WithHTTPClient sets the HTTP client used for all requests.
+func WithHTTPClient(h *http.Client) ClientOption {
+	return func(c *Client) {
+		if h != nil {
+			c.client = h
+		}
+	}
+}
+
+// WithProxy sends all requests through the HTTP(S) proxy at u. Use nil to
+// disable proxies, including those configured in the environment.
+//
+// Transport options like WithProxy and WithRootCAs configure a private copy
+// of the HTTP client's transport. Set a custom HTTP client first.
+func WithProxy(u *url.URL) ClientOption {
+	return func(c *Client) {
+		if t := c.httpTransport(); t != nil {
+			if u == nil {
+				t.Proxy = nil
+			} else {
+				t.Proxy = http.ProxyURL(u)
+			}
+		}
+	}
+}
+
+// WithTLSConfig replaces the TLS configuration with a copy of cfg.
+func WithTLSConfig(cfg *tls.Config) ClientOption {
+	return func(c *Client) {
+		if t := c.httpTransport(); t != nil {
+			t.TLSClientConfig = cfg.Clone()
+		}
+	}
+}
+
+// WithRootCAs verifies server certificates against pool, see LoadCertPool.
+func WithRootCAs(pool *x509.CertPool) ClientOption {
+	return func(c *Client) {
+		if cfg := c.tlsConfig(); cfg != nil {
+			cfg.RootCAs = pool
+		}
+	}
+}
+
+// WithClientCertificate authenticates the client with TLS certificates,
+// see tls.LoadX509KeyPair.
+func WithClientCertificate(certs ...tls.Certificate) ClientOption {
+	return func(c *Client) {
+		if cfg := c.tlsConfig(); cfg != nil {
+			cfg.Certificates = append(cfg.Certificates, certs...)
+		}
+	}
+}
+
+// WithTLSMinVersion sets the minimum TLS version, e.g. tls.VersionTLS13.
+func WithTLSMinVersion(v uint16) ClientOption {
+	return func(c *Client) {
+		if cfg := c.tlsConfig(); cfg != nil {
+			cfg.MinVersion = v
+		}
+	}
+}
+
+// WithMaxIdleConns limits idle connections kept open across all hosts.
+// Zero means no limit.
+func WithMaxIdleConns(n int) ClientOption {
+	return func(c *Client) {
+		if t := c.httpTransport(); t != nil {
+			t.MaxIdleConns = n
+		}
+	}
+}
+
+// WithMaxIdleConnsPerHost limits idle connections kept open per host. The
+// Go default of 2 is too low for indexers which fetch many blocks in
+// parallel from a single node.
+func WithMaxIdleConnsPerHost(n int) ClientOption {
+	return func(c *Client) {
+		if t := c.httpTransport(); t != nil {
+			t.MaxIdleConnsPerHost = n
+		}
+	}
+}
+
+// WithMaxConnsPerHost limits connections per host including active ones.
+// Zero means no limit.
+func WithMaxConnsPerHost(n int) ClientOption {
+	return func(c *Client) {
+		if t := c.httpTransport(); t != nil {
+			t.MaxConnsPerHost = n
+		}
+	}
+}
+
+// WithIdleConnTimeout closes idle connections after d.
+func WithIdleConnTimeout(d time.Duration) ClientOption {
+	return func(c *Client) {
+		if t := c.httpTransport(); t != nil {
+			t.IdleConnTimeout = d
+		}
+	}
+}
+
+// WithHTTP2 enables or disables HTTP/2 for TLS connections. Disabling
+// HTTP/2 spreads parallel requests over multiple connections which can be
+// faster behind load balancers.
+func WithHTTP2(enable bool) ClientOption {
+	return func(c *Client) {
+		if t := c.httpTransport(); t != nil {
+			t.ForceAttemptHTTP2 = enable
+			if enable {
+				t.TLSNextProto = nil
+			} else {
+				t.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+				if cfg := t.TLSClientConfig; cfg != nil {
+					protos := make([]string, 0, len(cfg.NextProtos))
+					for _, v := range cfg.NextProtos {
+						if v != "h2" {
+							protos = append(protos, v)
+						}
+					}
+					cfg.NextProtos = protos
+				}
+			}
+		}
+	}
+}
+
+// WithLogger sets the client logger.
+func WithLogger(l log.Logger) ClientOption {
+	return func(c *Client) {
+		c.Log = l
+	}
+}
+
+// WithChain selects the chain queried by all chain and block related RPCs.
+// Chain may be an alias like main or test or a chain id. This is useful for
+// test chains and nodes which serve multiple chains. Call Init afterwards
+// to resolve the chain id and params of the selected chain.
+func WithChain(chain string) ClientOption {
+	return func(c *Client) {
+		c.Chain = chain
+	}
+}
+
+// WithRequestLogger sets the receiver of structured request events, see
+// NewRequestLogger.
+func WithRequestLogger(l RequestLogger) ClientOption {
+	return func(c *Client) {
+		c.RequestLog = l
+	}
+}
+
+// WithMetadataMode sets the metadata mode for block and operation receipts.
+func WithMetadataMode(m MetadataMode) ClientOption {
+	return func(c *Client) {
+		c.MetadataMode = m
+	}
+}
+
+// WithMetadataRecovery enables refetching of stripped operation metadata.
+func WithMetadataRecovery() ClientOption {
+	return func(c *Client) {
+		c.RecoverMetadata = true
+	}
+}
+
+// WithRawJSON enables raw JSON retention, see Client.RetainRawJSON.
+func WithRawJSON() ClientOption {
+	return func(c *Client) {
+		c.RetainRawJSON = true
+	}
+}
+
+// WithStrictJSON enables strict decoding, see Client.StrictJSON.
+func WithStrictJSON() ClientOption {
+	return func(c *Client) {
+		c.StrictJSON = true
+	}
+}
+
+// WithUnknownFieldReport enables collection of unknown fields, see
+// Client.ReportUnknownFields.
+func WithUnknownFieldReport() ClientOption {
+	return func(c *Client) {
+		c.ReportUnknownFields = true
+	}
+}
+
+// WithCompression enables compressed responses, see Client.Compression.
+func WithCompression() ClientOption {
+	return func(c *Client) {
+		c.Compression = true
+	}
+}
+
+// WithRequestCompression gzips request bodies of at least minSize bytes,
+// see Client.CompressRequestSize.
+func WithRequestCompression(minSize int) ClientOption {
+	return func(c *Client) {
+		c.CompressRequestSize = minSize
+	}
+}
+
+// WithRateLimit throttles requests with limiter l.
+func WithRateLimit(l *RateLimiter) ClientOption {
+	return func(c *Client) {
+		c.RateLimit = l
+	}
+}
+
+// WithRetry enables automatic retries with policy p.
+func WithRetry(p RetryPolicy) ClientOption {
+	return func(c *Client) {
+		c.Retry = &p
+	}
+}
+
+// WithCache enables the response cache.
+func WithCache(cache *Cache) ClientOption {
+	return func(c *Client) {
+		c.Cache = cache
+	}
+}
+
+// WithMetrics sets the metrics receiver.
+func WithMetrics(m Metrics) ClientOption {
+	return func(c *Client) {
+		c.Metrics = m
+	}
+}
+
+// WithSigner sets the default signer used to send operations.
+func WithSigner(s signer.Signer) ClientOption {
+	return func(c *Client) {
+		c.Signer = s
+	}
+}
+
+// WithApiKey sets the API key sent with every request.
+func WithApiKey(key string) ClientOption {
+	return func(c *Client) {
+		c.ApiKey = key
+	}
+}
+
+// WithUserAgent sets the user agent sent with every request.
+func WithUserAgent(ua string) ClientOption {
+	return func(c *Client) {
+		c.UserAgent = ua
+	}
+}
+
+// WithInterceptors appends request interceptors, see Client.Use.
+func WithInterceptors(fn ...Interceptor) ClientOption {
+	return func(c *Client) {
+		c.Use(fn...)
+	}
+}
+
+// MonitorOption configures a monitor created with NewReconnectingMonitor.
+type MonitorOption func(*monitorConfig)
+
+type monitorConfig struct {
+	policy RetryPolicy
+	events int
+}
+
+// WithReconnectPolicy sets the reconnect backoff policy.
+func WithReconnectPolicy(p RetryPolicy) MonitorOption {
+	return func(m *monitorConfig) {
+		m.policy = p
+	}
+}
+
+// WithEventBuffer sets the number of reconnect events buffered for slow
+// readers.
+func WithEventBuffer(n int) MonitorOption {
+	return func(m *monitorConfig) {
+		if n >= 0 {
+			m.events = n
+		}
+	}
+}
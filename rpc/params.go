@@ -0,0 +1,162 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+)
+
+// protocolCacheTTL defines how long the node's next protocol is cached
+// before it is refreshed on the next params check.
+var protocolCacheTTL = time.Minute
+
+// protocolCache keeps the most recently seen next protocol of the connected node.
+type protocolCache struct {
+	sync.Mutex
+	proto   mavryk.ProtocolHash
+	expires time.Time
+	num     int // protocol number of the last observed block header
+}
+
+// observe expires the cached protocol when the protocol number of a new
+// block header differs from the previous header.
+func (c *protocolCache) observe(num int) {
+	c.Lock()
+	defer c.Unlock()
+	if c.num != 0 && c.num != num {
+		c.expires = time.Time{}
+	}
+	c.num = num
+}
+
+// ParamsMismatchError is returned when an operation uses params for a
+// different protocol or operation tag version than the connected node.
+type ParamsMismatchError struct {
+	Local      mavryk.ProtocolHash // protocol of the operation params
+	Remote     mavryk.ProtocolHash // next protocol of the node
+	LocalTags  int                 // operation tag version of the operation params
+	RemoteTags int                 // operation tag version of the node protocol, -1 when unknown
+}
+
+func (e *ParamsMismatchError) Error() string {
+	return fmt.Sprintf("rpc: params mismatch local=%s (tags v%d) remote=%s (tags v%d)",
+		e.Local, e.LocalTags, e.Remote, e.RemoteTags)
+}
+
+// BlockProtocols contains the protocol of a block and the protocol used to
+// validate its successor.
+type BlockProtocols struct {
+	Protocol     mavryk.ProtocolHash `json:"protocol"`
+	NextProtocol mavryk.ProtocolHash `json:"next_protocol"`
+}
+
+// GetBlockProtocols returns protocol and next protocol of block id.
+// https://tezos.gitlab.io/mainnet/api/rpc.html#get-block-id-protocols
+func (c *Client) GetBlockProtocols(ctx context.Context, id BlockID) (*BlockProtocols, error) {
+	var p BlockProtocols
+	u := fmt.Sprintf("chains/main/blocks/%s/protocols", id)
+	if err := c.Get(ctx, u, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// GetNextProtocol returns the next protocol of the current head block which
+// is the protocol new operations are validated with. On a protocol migration
+// block it already differs from the head protocol. The result is cached for
+// a short time to avoid an extra roundtrip per operation.
+func (c *Client) GetNextProtocol(ctx context.Context) (mavryk.ProtocolHash, error) {
+	c.protoCache.Lock()
+	defer c.protoCache.Unlock()
+	if c.protoCache.proto.IsValid() && time.Now().Before(c.protoCache.expires) {
+		return c.protoCache.proto, nil
+	}
+	p, err := c.GetBlockProtocols(ctx, Head)
+	if err != nil {
+		return mavryk.ProtocolHash{}, err
+	}
+	c.protoCache.proto = p.NextProtocol
+	c.protoCache.expires = time.Now().Add(protocolCacheTTL)
+	return p.NextProtocol, nil
+}
+
+// CheckParams compares protocol and operation tag version of params p against
+// the next protocol of the connected node, i.e. the protocol an injected
+// operation is validated with, and returns a ParamsMismatchError when they
+// differ. Forging operations with stale params otherwise leads to hard to
+// debug decoding errors on the node side.
+func (c *Client) CheckParams(ctx context.Context, p *mavryk.Params) error {
+	if p == nil {
+		return nil
+	}
+	remote, err := c.GetNextProtocol(ctx)
+	if err != nil {
+		return err
+	}
+	remoteTags := -1
+	if _, ok := mavryk.Versions[remote]; ok {
+		remoteTags = new(mavryk.Params).WithProtocol(remote).OperationTagsVersion
+	}
+	switch {
+	case p.Protocol.IsValid() && !p.Protocol.Equal(remote):
+	case remoteTags >= 0 && p.OperationTagsVersion != remoteTags:
+	default:
+		return nil
+	}
+	return &ParamsMismatchError{
+		Local:      p.Protocol,
+		Remote:     remote,
+		LocalTags:  p.OperationTagsVersion,
+		RemoteTags: remoteTags,
+	}
+}
+
+// RefreshParams replaces the client's params when the next protocol of the
+// connected node, i.e. the protocol new operations are validated with,
+// differs from the params protocol, e.g. around a protocol upgrade. It
+// returns true when params were replaced. The node protocol is cached for a
+// short time and the cache is expired as soon as the block observer sees a
+// block of a new protocol.
+func (c *Client) RefreshParams(ctx context.Context) (bool, error) {
+	remote, err := c.GetNextProtocol(ctx)
+	if err != nil {
+		return false, err
+	}
+	local := c.ChainParams()
+	if local.Protocol.Equal(remote) {
+		return false, nil
+	}
+	p, err := c.loadParams(ctx, Head, remote)
+	if err != nil {
+		return false, err
+	}
+	c.Log.Infof("rpc: protocol changed from %s to %s, refreshed params", local.Protocol, p.Protocol)
+	return true, nil
+}
+
+// loadParams replaces the client's params and cached next protocol with
+// params at block id for protocol next. On the last block of a protocol the
+// constants of next are not known yet, so params keep the constants of
+// block id with version and operation tag version of next. RefreshParams and
+// ProtocolWatcher both update params this way.
+func (c *Client) loadParams(ctx context.Context, id BlockID, next mavryk.ProtocolHash) (*mavryk.Params, error) {
+	p, err := c.GetParams(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if next.IsValid() && !p.Protocol.Equal(next) {
+		p.WithProtocol(next)
+	}
+	c.SetParams(p)
+	c.protoCache.Lock()
+	c.protoCache.proto = next
+	c.protoCache.expires = time.Now().Add(protocolCacheTTL)
+	c.protoCache.Unlock()
+	return p, nil
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+	"github.com/mavryk-network/mvgo/rpc"
+)
+
+func TestCheckParamsNextProtocol(t *testing.T) {
+	node, c, _ := newTestNode(t)
+	ctx := context.Background()
+	if err := c.CheckParams(ctx, node.Params()); err != nil {
+		t.Fatalf("unexpected mismatch: %v", err)
+	}
+
+	// on a migration block the head still runs the old protocol
+	var next mavryk.ProtocolHash
+	for p := range mavryk.Versions {
+		if !p.Equal(node.Params().Protocol) {
+			next = p
+			break
+		}
+	}
+	node.Handle(http.MethodGet, "/chains/main/blocks/head/protocols", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"protocol":"` + node.Params().Protocol.String() + `","next_protocol":"` + next.String() + `"}`))
+	})
+	c2, err := node.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c2.Close()
+	err = c2.CheckParams(ctx, node.Params())
+	var e *rpc.ParamsMismatchError
+	if !errors.As(err, &e) {
+		t.Fatalf("want params mismatch, have %v", err)
+	}
+	if !e.Remote.Equal(next) {
+		t.Errorf("remote protocol mismatch, want=%s have=%s", next, e.Remote)
+	}
+}
+
+func TestRefreshParamsNextProtocol(t *testing.T) {
+	node, c, _ := newTestNode(t)
+	ctx := context.Background()
+	if c.AutoRefreshParams {
+		t.Error("auto refresh must be disabled by default")
+	}
+	if ok, err := c.RefreshParams(ctx); err != nil || ok {
+		t.Fatalf("unexpected refresh %t %v", ok, err)
+	}
+
+	// on a migration block params switch to the next protocol
+	proto := node.Params().Protocol
+	node.Handle(http.MethodGet, "/chains/main/blocks/head/protocols", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"protocol":"` + proto.String() + `","next_protocol":"` + mavryk.ProtoAlpha.String() + `"}`))
+	})
+	serveConstants(node, "head")
+	c2, err := node.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c2.Close()
+	ok, err := c2.RefreshParams(ctx)
+	if err != nil || !ok {
+		t.Fatalf("want refresh, have %t %v", ok, err)
+	}
+	if p := c2.ChainParams(); !p.Protocol.Equal(mavryk.ProtoAlpha) {
+		t.Errorf("want protocol %s, have %s", mavryk.ProtoAlpha, p.Protocol)
+	}
+	if err := c2.CheckParams(ctx, c2.ChainParams()); err != nil {
+		t.Errorf("refreshed params mismatch: %v", err)
+	}
+	if ok, err := c2.RefreshParams(ctx); err != nil || ok {
+		t.Errorf("unexpected second refresh %t %v", ok, err)
+	}
+}
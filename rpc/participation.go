@@ -0,0 +1,142 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc
+
+import (
+	"context"
+	"sort"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+)
+
+// BakerParticipation is the consensus power a single baker contributed
+// to a block.
+type BakerParticipation struct {
+	Delegate            mavryk.Address `json:"delegate"`
+	EndorsementPower    int            `json:"endorsement_power"`
+	PreendorsementPower int            `json:"preendorsement_power"`
+}
+
+// Participation reports the (pre)endorsement power included in a block
+// relative to the consensus committee size.
+type Participation struct {
+	Level               int64                `json:"level"`
+	Block               mavryk.BlockHash     `json:"block"`
+	CommitteeSize       int                  `json:"committee_size"`
+	Threshold           int                  `json:"threshold"`
+	EndorsementPower    int                  `json:"endorsement_power"`
+	PreendorsementPower int                  `json:"preendorsement_power"`
+	Bakers              []BakerParticipation `json:"bakers"` // sorted by endorsement power
+}
+
+// EndorsementRate returns the percentage of committee slots endorsed.
+func (p Participation) EndorsementRate() float64 {
+	if p.CommitteeSize == 0 {
+		return 0
+	}
+	return float64(p.EndorsementPower) * 100 / float64(p.CommitteeSize)
+}
+
+// PreendorsementRate returns the percentage of committee slots preendorsed.
+func (p Participation) PreendorsementRate() float64 {
+	if p.CommitteeSize == 0 {
+		return 0
+	}
+	return float64(p.PreendorsementPower) * 100 / float64(p.CommitteeSize)
+}
+
+// HasQuorum returns true when endorsement power reached the consensus threshold.
+func (p Participation) HasQuorum() bool {
+	return p.Threshold > 0 && p.EndorsementPower >= p.Threshold
+}
+
+// Rate returns the percentage of committee slots a baker endorsed.
+func (b BakerParticipation) Rate(committeeSize int) float64 {
+	if committeeSize == 0 {
+		return 0
+	}
+	return float64(b.EndorsementPower) * 100 / float64(committeeSize)
+}
+
+// consensusPower returns the endorsement power of a consensus operation
+// from its receipt. Before v12 power equals the number of slots, since v19
+// power is reported as consensus_power.
+func consensusPower(m OperationMetadata, kind mavryk.OpType) int {
+	switch {
+	case m.ConsensusPower > 0:
+		return m.ConsensusPower
+	case kind == mavryk.OpTypePreendorsement:
+		return m.PreendorsementPower
+	case m.EndorsementPower > 0:
+		return m.EndorsementPower
+	default:
+		return len(m.Slots)
+	}
+}
+
+// BlockParticipation sums the (pre)endorsement power of all consensus
+// operations included in block b. Power is taken from operation receipts,
+// so the block must have been fetched with metadata. Committee size and
+// threshold are taken from con.
+//
+// Note that endorsements in a block refer to the block's predecessor.
+func BlockParticipation(b *Block, con Constants) *Participation {
+	p := &Participation{
+		Level:         b.GetLevel(),
+		Block:         b.Hash,
+		CommitteeSize: con.ConsensusCommitteeSize,
+		Threshold:     con.ConsensusThreshold,
+		Bakers:        make([]BakerParticipation, 0),
+	}
+	if len(b.Operations) == 0 {
+		return p
+	}
+	bakers := make(map[string]int)
+	for _, op := range b.Operations[0] {
+		for _, o := range op.Contents {
+			kind := o.Kind()
+			switch kind {
+			case mavryk.OpTypeEndorsement,
+				mavryk.OpTypeEndorsementWithSlot,
+				mavryk.OpTypePreendorsement,
+				mavryk.OpTypeAttestationWithDal:
+			default:
+				continue
+			}
+			meta := o.Meta()
+			power := consensusPower(meta, kind)
+			idx, ok := bakers[meta.Delegate.String()]
+			if !ok {
+				idx = len(p.Bakers)
+				bakers[meta.Delegate.String()] = idx
+				p.Bakers = append(p.Bakers, BakerParticipation{Delegate: meta.Delegate})
+			}
+			if kind == mavryk.OpTypePreendorsement {
+				p.PreendorsementPower += power
+				p.Bakers[idx].PreendorsementPower += power
+			} else {
+				p.EndorsementPower += power
+				p.Bakers[idx].EndorsementPower += power
+			}
+		}
+	}
+	sort.SliceStable(p.Bakers, func(i, j int) bool {
+		return p.Bakers[i].EndorsementPower > p.Bakers[j].EndorsementPower
+	})
+	return p
+}
+
+// GetBlockParticipation fetches block id with metadata and the constants
+// active at this block and returns its consensus participation.
+func (c *Client) GetBlockParticipation(ctx context.Context, id BlockID) (*Participation, error) {
+	b, err := c.GetBlock(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	con, err := c.GetConstants(ctx, b.Hash)
+	if err != nil {
+		return nil, err
+	}
+	return BlockParticipation(b, con), nil
+}
@@ -0,0 +1,344 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PoolStrategy defines how a pool selects the node for the next request.
+type PoolStrategy byte
+
+const (
+	// PoolFailover sends all requests to the first healthy node in list order.
+	PoolFailover PoolStrategy = iota
+	// PoolRoundRobin distributes requests evenly across healthy nodes.
+	PoolRoundRobin
+	// PoolLatency prefers the healthy node with the lowest average latency.
+	PoolLatency
+)
+
+func (s PoolStrategy) String() string {
+	switch s {
+	case PoolFailover:
+		return "failover"
+	case PoolRoundRobin:
+		return "round-robin"
+	case PoolLatency:
+		return "latency"
+	default:
+		return "invalid"
+	}
+}
+
+// latency moving average weight for new samples
+const poolLatencyAlpha = 0.2
+
+// PoolNodeStatus is a snapshot of a pool node's health state.
+type PoolNodeStatus struct {
+	URL       string        `json:"url"`
+	Healthy   bool          `json:"healthy"`
+	Latency   time.Duration `json:"latency"`
+	Failures  int           `json:"failures"`
+	LastError string        `json:"last_error,omitempty"`
+	LastSeen  time.Time     `json:"last_seen"`
+	Health    *HealthReport `json:"health,omitempty"` // last health check
+}
+
+type poolNode struct {
+	url    *url.URL
+	client *Client // for health checks
+	status PoolNodeStatus
+}
+
+// Pool is an http.RoundTripper which distributes Tezos RPC requests across
+// multiple nodes. Idempotent requests fail over to the next node on network
+// errors and on 502, 503 and 504 responses. POST requests other than
+// simulations, e.g. operation injections, are sent to a single node only. Other responses, including RPC errors,
+// are returned as is. Nodes which fail are marked unhealthy and only used
+// when no healthy node is left. They recover on their next successful
+// request or health check.
+//
+// Use NewPoolClient to create an RPC client backed by a pool.
+type Pool struct {
+	mu       sync.Mutex
+	nodes    []*poolNode
+	strategy PoolStrategy
+	next     http.RoundTripper
+	base     *url.URL
+	rr       int
+	health   HealthOptions
+}
+
+// make sure Pool implements http.RoundTripper interface
+var _ http.RoundTripper = (*Pool)(nil)
+
+// NewPool creates a pool over node URLs. Requests are sent through transport
+// or http.DefaultTransport when transport is nil. The first URL serves as
+// base URL for requests which are rewritten to the selected node.
+func NewPool(urls []string, strategy PoolStrategy, transport http.RoundTripper) (*Pool, error) {
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("rpc: empty node list")
+	}
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	p := &Pool{
+		nodes:    make([]*poolNode, len(urls)),
+		strategy: strategy,
+		next:     transport,
+	}
+	for i, v := range urls {
+		if !strings.HasPrefix(v, "http") {
+			v = "http://" + v
+		}
+		u, err := url.Parse(v)
+		if err != nil {
+			return nil, fmt.Errorf("rpc: invalid node url %q: %v", v, err)
+		}
+		u.RawQuery = ""
+		c, err := NewClient(u.String(), &http.Client{Transport: transport})
+		if err != nil {
+			return nil, err
+		}
+		p.nodes[i] = &poolNode{
+			url:    u,
+			client: c,
+			status: PoolNodeStatus{
+				URL:     u.String(),
+				Healthy: true,
+			},
+		}
+	}
+	p.base = p.nodes[0].url
+	return p, nil
+}
+
+// NewPoolClient returns an RPC client which sends requests to a pool of
+// nodes. The first URL is used as the client's BaseURL and for API key
+// detection. The pool is returned so callers can run health checks and
+// inspect node status.
+func NewPoolClient(urls []string, strategy PoolStrategy, httpClient *http.Client) (*Client, *Pool, error) {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	pool, err := NewPool(urls, strategy, httpClient.Transport)
+	if err != nil {
+		return nil, nil, err
+	}
+	hc := *httpClient
+	hc.Transport = pool
+	c, err := NewClient(urls[0], &hc)
+	if err != nil {
+		return nil, nil, err
+	}
+	pool.base = c.BaseURL
+	return c, pool, nil
+}
+
+// WithHealthOptions sets options for health checks run by Check, e.g. to
+// mark nodes with a stale head or a wrong chain id as unhealthy.
+func (p *Pool) WithHealthOptions(opts HealthOptions) *Pool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.health = opts
+	return p
+}
+
+// Status returns the current health state of all nodes in list order.
+func (p *Pool) Status() []PoolNodeStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	res := make([]PoolNodeStatus, len(p.nodes))
+	for i, n := range p.nodes {
+		res[i] = n.status
+	}
+	return res
+}
+
+// Check probes all nodes concurrently and updates their health state. A node
+// is healthy when it passes HealthCheck with the pool's health options.
+// Check returns an error when no node is healthy.
+func (p *Pool) Check(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for _, n := range p.nodes {
+		wg.Add(1)
+		go func(n *poolNode) {
+			defer wg.Done()
+			start := time.Now()
+			err := p.probe(ctx, n)
+			p.update(n, time.Since(start), err)
+		}(n)
+	}
+	wg.Wait()
+	for _, v := range p.Status() {
+		if v.Healthy {
+			return nil
+		}
+	}
+	return fmt.Errorf("rpc: no healthy node")
+}
+
+// Run periodically checks node health in the background until ctx is canceled.
+func (p *Pool) Run(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			_ = p.Check(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (p *Pool) probe(ctx context.Context, n *poolNode) error {
+	p.mu.Lock()
+	opts := p.health
+	p.mu.Unlock()
+	r, err := n.client.HealthCheck(ctx, opts)
+	p.mu.Lock()
+	n.status.Health = r
+	p.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("rpc: health check: %v", err)
+	}
+	return r.Err()
+}
+
+// update records the result of a request or health check.
+func (p *Pool) update(n *poolNode, d time.Duration, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err != nil {
+		n.status.Healthy = false
+		n.status.Failures++
+		n.status.LastError = err.Error()
+		return
+	}
+	n.status.Healthy = true
+	n.status.Failures = 0
+	n.status.LastError = ""
+	n.status.LastSeen = time.Now().UTC()
+	if n.status.Latency == 0 {
+		n.status.Latency = d
+	} else {
+		n.status.Latency = time.Duration(poolLatencyAlpha*float64(d) + (1-poolLatencyAlpha)*float64(n.status.Latency))
+	}
+}
+
+// order returns nodes in the sequence they should be tried. Healthy nodes
+// are ordered by strategy, unhealthy nodes follow in list order.
+func (p *Pool) order() []*poolNode {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	healthy := make([]*poolNode, 0, len(p.nodes))
+	unhealthy := make([]*poolNode, 0)
+	for _, n := range p.nodes {
+		if n.status.Healthy {
+			healthy = append(healthy, n)
+		} else {
+			unhealthy = append(unhealthy, n)
+		}
+	}
+	switch p.strategy {
+	case PoolRoundRobin:
+		if l := len(healthy); l > 1 {
+			k := p.rr % l
+			p.rr++
+			healthy = append(healthy[k:], healthy[:k]...)
+		}
+	case PoolLatency:
+		sort.SliceStable(healthy, func(i, j int) bool {
+			return healthy[i].status.Latency < healthy[j].status.Latency
+		})
+	}
+	return append(healthy, unhealthy...)
+}
+
+// rewrite returns the request URL moved onto node n or nil when the request
+// does not target the pool's base URL (e.g. IPFS requests).
+func (p *Pool) rewrite(u *url.URL, n *poolNode) *url.URL {
+	basePath := strings.TrimSuffix(p.base.Path, "/")
+	if u.Scheme != p.base.Scheme || u.Host != p.base.Host || !strings.HasPrefix(u.Path, basePath) {
+		return nil
+	}
+	res := *u
+	res.Scheme = n.url.Scheme
+	res.Host = n.url.Host
+	res.User = n.url.User
+	res.Path = strings.TrimSuffix(n.url.Path, "/") + strings.TrimPrefix(u.Path, basePath)
+	res.RawPath = ""
+	return &res
+}
+
+func isFailoverStatus(code int) bool {
+	switch code {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// RoundTrip sends the request to the next node selected by the pool strategy
+// and fails over to other nodes on transient errors.
+func (p *Pool) RoundTrip(req *http.Request) (*http.Response, error) {
+	if p.rewrite(req.URL, p.nodes[0]) == nil {
+		return p.next.RoundTrip(req)
+	}
+	// only idempotent requests are repeated and only when their body can be
+	// recreated, a failed injection may still have reached the node
+	canRetry := isIdempotent(req) && (req.Body == nil || req.Body == http.NoBody || req.GetBody != nil)
+	var (
+		resp *http.Response
+		err  error
+	)
+	nodes := p.order()
+	for i, n := range nodes {
+		if i > 0 {
+			if !canRetry {
+				break
+			}
+			if cerr := req.Context().Err(); cerr != nil {
+				return nil, cerr
+			}
+		}
+		r := req.Clone(req.Context())
+		r.URL = p.rewrite(req.URL, n)
+		r.Host = ""
+		if i > 0 && req.GetBody != nil {
+			if r.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+		start := time.Now()
+		resp, err = p.next.RoundTrip(r)
+		switch {
+		case err != nil:
+			p.update(n, 0, err)
+		case isFailoverStatus(resp.StatusCode):
+			p.update(n, 0, fmt.Errorf("rpc: %s", resp.Status))
+		default:
+			p.update(n, time.Since(start), nil)
+			return resp, nil
+		}
+		if i < len(nodes)-1 && canRetry && resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+	}
+	return resp, err
+}
@@ -0,0 +1,403 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultPrefetchWindow is the number of blocks a prefetcher keeps ahead
+	// of the consumer.
+	DefaultPrefetchWindow = 16
+
+	// DefaultPrefetchConcurrency is the max number of concurrent block
+	// requests a prefetcher sends.
+	DefaultPrefetchConcurrency = 4
+)
+
+// BlockFetchFunc loads the block at level.
+type BlockFetchFunc func(ctx context.Context, level int64) (*Block, error)
+
+// PrefetchStats reports the state of a block prefetcher.
+type PrefetchStats struct {
+	Level       int64         // next level returned by Next
+	Buffered    int           // blocks fetched or in flight ahead of the consumer
+	Concurrency int           // current request concurrency limit
+	Latency     time.Duration // smoothed request latency
+	Stalls      int64         // number of times the consumer waited for a block
+}
+
+type prefetchResult struct {
+	block *Block
+	err   error
+}
+
+// BlockPrefetcher loads blocks ahead of a consumer and returns them in level
+// order. At most Window blocks are kept ahead, so a slow consumer throttles
+// requests. Request concurrency adapts to the node. It grows while the
+// consumer waits for blocks and latency stays low. It shrinks when latency
+// rises above twice the best observed latency or requests fail.
+//
+// When the last level is zero the prefetcher follows the chain head and waits
+// for new blocks. It stays Confirmations blocks behind the head, so it only
+// returns final blocks which cannot be replaced by a reorg. Use a
+// BlockFollower to process blocks closer to the head. BlockPrefetcher methods must be called from a single
+// goroutine, except Stats and Close.
+type BlockPrefetcher struct {
+	Window         int         // max blocks ahead of the consumer
+	MaxConcurrency int         // max concurrent requests
+	Policy         RetryPolicy // backoff after transient request errors
+	Confirmations  int64       // distance to the head when following, defaults to FinalityConfirmations
+
+	c        *Client
+	from, to int64
+	fetch    BlockFetchFunc
+	ctx      context.Context
+	cancel   context.CancelFunc
+	once     sync.Once
+	queue    chan chan prefetchResult
+	pending  chan prefetchResult
+	wake     chan struct{}
+	err      error
+	mu       sync.Mutex
+	level    int64
+	limit    int
+	inflight int
+	lat      time.Duration
+	minLat   time.Duration
+	stalls   int64
+}
+
+// NewBlockPrefetcher creates a prefetcher for blocks from level from to level
+// to (inclusive). Set to to zero to follow the chain head. The prefetcher
+// stays active until ctx is cancelled or Close is called.
+func NewBlockPrefetcher(ctx context.Context, c *Client, from, to int64) *BlockPrefetcher {
+	ctx, cancel := context.WithCancel(ctx)
+	p := &BlockPrefetcher{
+		Window:         DefaultPrefetchWindow,
+		MaxConcurrency: DefaultPrefetchConcurrency,
+		Policy:         DefaultReconnectPolicy,
+		Confirmations:  FinalityConfirmations,
+		c:              c,
+		from:           from,
+		to:             to,
+		ctx:            ctx,
+		cancel:         cancel,
+		level:          from,
+		wake:           make(chan struct{}, 1),
+	}
+	if c != nil {
+		p.fetch = func(ctx context.Context, level int64) (*Block, error) {
+			return c.GetBlock(ctx, BlockLevel(level))
+		}
+	}
+	return p
+}
+
+// WithWindow sets the number of blocks kept ahead of the consumer.
+func (p *BlockPrefetcher) WithWindow(n int) *BlockPrefetcher {
+	p.Window = n
+	return p
+}
+
+// WithConcurrency sets the max number of concurrent requests.
+func (p *BlockPrefetcher) WithConcurrency(n int) *BlockPrefetcher {
+	p.MaxConcurrency = n
+	return p
+}
+
+// WithConfirmations sets the distance to the chain head when following.
+func (p *BlockPrefetcher) WithConfirmations(n int64) *BlockPrefetcher {
+	p.Confirmations = n
+	return p
+}
+
+// WithFetch replaces the function used to load blocks.
+func (p *BlockPrefetcher) WithFetch(fn BlockFetchFunc) *BlockPrefetcher {
+	p.fetch = fn
+	return p
+}
+
+// Next returns the next block in level order. It returns io.EOF after the
+// last level and the first request error which could not be resolved by
+// retrying, after which the prefetcher is closed.
+func (p *BlockPrefetcher) Next(ctx context.Context) (*Block, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	p.once.Do(p.start)
+
+	if p.pending == nil {
+		select {
+		case ch, ok := <-p.queue:
+			if !ok {
+				return nil, p.fail(io.EOF)
+			}
+			p.pending = ch
+		default:
+			p.stall()
+			select {
+			case ch, ok := <-p.queue:
+				if !ok {
+					return nil, p.fail(io.EOF)
+				}
+				p.pending = ch
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+	}
+
+	var res prefetchResult
+	select {
+	case res = <-p.pending:
+	default:
+		p.stall()
+		select {
+		case res = <-p.pending:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	p.pending = nil
+	if res.err != nil {
+		return nil, p.fail(res.err)
+	}
+	p.mu.Lock()
+	p.level++
+	p.mu.Unlock()
+	return res.block, nil
+}
+
+// Stats returns the current prefetcher state.
+func (p *BlockPrefetcher) Stats() PrefetchStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := PrefetchStats{
+		Level:       p.level,
+		Concurrency: p.limit,
+		Latency:     p.lat,
+		Stalls:      p.stalls,
+	}
+	if p.queue != nil {
+		s.Buffered = len(p.queue)
+	}
+	return s
+}
+
+// Close stops all requests.
+func (p *BlockPrefetcher) Close() {
+	p.cancel()
+}
+
+func (p *BlockPrefetcher) start() {
+	window := p.Window
+	if window <= 0 {
+		window = DefaultPrefetchWindow
+	}
+	p.mu.Lock()
+	p.queue = make(chan chan prefetchResult, window)
+	p.limit = 1
+	p.mu.Unlock()
+	go p.run()
+}
+
+func (p *BlockPrefetcher) fail(err error) error {
+	p.err = err
+	p.cancel()
+	return err
+}
+
+// run schedules block requests in level order until the last level or
+// until the prefetcher is closed.
+func (p *BlockPrefetcher) run() {
+	defer close(p.queue)
+	if p.fetch == nil {
+		p.push(prefetchResult{err: fmt.Errorf("rpc: prefetcher has no client or fetch function")})
+		return
+	}
+	var head int64
+	for level := p.from; p.to <= 0 || level <= p.to; level++ {
+		if p.to <= 0 && level > head {
+			h, err := p.waitHead(level)
+			if err != nil {
+				p.push(prefetchResult{err: err})
+				return
+			}
+			head = h
+		}
+		if !p.acquire() {
+			return
+		}
+		ch := make(chan prefetchResult, 1)
+		select {
+		case p.queue <- ch:
+		case <-p.ctx.Done():
+			p.release()
+			return
+		}
+		go p.load(level, ch)
+	}
+}
+
+func (p *BlockPrefetcher) push(res prefetchResult) {
+	ch := make(chan prefetchResult, 1)
+	ch <- res
+	select {
+	case p.queue <- ch:
+	case <-p.ctx.Done():
+	}
+}
+
+// waitHead polls the chain head until level is final and returns the last
+// final level.
+func (p *BlockPrefetcher) waitHead(level int64) (int64, error) {
+	if p.c == nil {
+		return 0, fmt.Errorf("rpc: prefetcher needs a client to follow the chain head")
+	}
+	delay := p.c.ChainParams().MinimalBlockDelay / 2
+	if delay < time.Second {
+		delay = time.Second
+	}
+	confirmations := p.Confirmations
+	if confirmations <= 0 {
+		confirmations = FinalityConfirmations
+	}
+	var attempt int
+	for {
+		head, err := p.c.GetBlockHeader(p.ctx, Head)
+		switch {
+		case err == nil:
+			attempt = 0
+			if final := head.Level - confirmations; final >= level {
+				return final, nil
+			}
+		case p.ctx.Err() != nil:
+			return 0, p.ctx.Err()
+		case !IsTransient(err):
+			return 0, err
+		default:
+			attempt++
+		}
+		wait := delay
+		if attempt > 0 {
+			wait = p.Policy.Backoff(attempt)
+		}
+		select {
+		case <-p.ctx.Done():
+			return 0, p.ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// load fetches a single block and retries transient errors.
+func (p *BlockPrefetcher) load(level int64, ch chan<- prefetchResult) {
+	defer p.release()
+	var attempt int
+	for {
+		start := time.Now()
+		b, err := p.fetch(p.ctx, level)
+		switch {
+		case err == nil:
+			p.observe(time.Since(start), false)
+			ch <- prefetchResult{block: b}
+			return
+		case p.ctx.Err() != nil:
+			ch <- prefetchResult{err: p.ctx.Err()}
+			return
+		case !IsTransient(err):
+			ch <- prefetchResult{err: err}
+			return
+		}
+		p.observe(0, true)
+		attempt++
+		if p.Policy.MaxAttempts > 0 && attempt >= p.Policy.MaxAttempts {
+			ch <- prefetchResult{err: err}
+			return
+		}
+		select {
+		case <-p.ctx.Done():
+			ch <- prefetchResult{err: p.ctx.Err()}
+			return
+		case <-time.After(p.Policy.Backoff(attempt)):
+		}
+	}
+}
+
+// acquire waits until another request may be sent.
+func (p *BlockPrefetcher) acquire() bool {
+	for {
+		p.mu.Lock()
+		if p.inflight < p.limit {
+			p.inflight++
+			p.mu.Unlock()
+			return true
+		}
+		p.mu.Unlock()
+		select {
+		case <-p.wake:
+		case <-p.ctx.Done():
+			return false
+		}
+	}
+}
+
+func (p *BlockPrefetcher) release() {
+	p.mu.Lock()
+	p.inflight--
+	p.mu.Unlock()
+	p.signal()
+}
+
+func (p *BlockPrefetcher) signal() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// observe adapts concurrency to request latency and errors.
+func (p *BlockPrefetcher) observe(d time.Duration, failed bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if failed {
+		if p.limit > 1 {
+			p.limit /= 2
+		}
+		return
+	}
+	if p.lat == 0 {
+		p.lat = d
+	} else {
+		p.lat = (7*p.lat + d) / 8
+	}
+	if p.minLat == 0 || p.lat < p.minLat {
+		p.minLat = p.lat
+	}
+	if p.lat > 2*p.minLat && p.limit > 1 {
+		p.limit--
+	}
+}
+
+// stall records that the consumer waits and raises concurrency when the
+// node keeps up.
+func (p *BlockPrefetcher) stall() {
+	p.mu.Lock()
+	p.stalls++
+	maxConcurrency := p.MaxConcurrency
+	if maxConcurrency <= 0 {
+		maxConcurrency = DefaultPrefetchConcurrency
+	}
+	if p.limit < maxConcurrency && p.limit < cap(p.queue) && 2*p.lat <= 3*p.minLat {
+		p.limit++
+	}
+	p.mu.Unlock()
+	p.signal()
+}
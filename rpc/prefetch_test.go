@@ -0,0 +1,51 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mavryk-network/mvgo/rpc"
+)
+
+func TestBlockPrefetcherFollow(t *testing.T) {
+	node, c, _ := newTestNode(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	head := node.Head().Level
+	params := c.ChainParams().Clone()
+	params.MinimalBlockDelay = time.Second
+	c.SetParams(params)
+
+	p := rpc.NewBlockPrefetcher(ctx, c, 1, 0)
+	defer p.Close()
+
+	// only final blocks are returned
+	for level := int64(1); level <= head-rpc.FinalityConfirmations; level++ {
+		b, err := p.Next(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.GetLevel() != level {
+			t.Fatalf("want level %d, have %d", level, b.GetLevel())
+		}
+	}
+	wctx, wcancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer wcancel()
+	if b, err := p.Next(wctx); err == nil {
+		t.Fatalf("unexpected non-final block %d", b.GetLevel())
+	}
+
+	// new heads finalize the next level
+	node.Bake()
+	b, err := p.Next(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := head - rpc.FinalityConfirmations + 1; b.GetLevel() != want {
+		t.Errorf("want level %d, have %d", want, b.GetLevel())
+	}
+}
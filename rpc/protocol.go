@@ -0,0 +1,150 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc
+
+import (
+	"context"
+	"sync"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+)
+
+// ProtocolChange describes an upcoming or completed protocol migration.
+type ProtocolChange struct {
+	Level     int64               // block level where the change was detected
+	Block     mavryk.BlockHash    // block hash where the change was detected
+	Old       mavryk.ProtocolHash // protocol before migration
+	New       mavryk.ProtocolHash // protocol after migration
+	Params    *mavryk.Params      // params for the new protocol
+	Activated bool                // false on the last block of Old, true once New is active
+}
+
+// ProtocolCallback is called by a ProtocolWatcher on protocol changes.
+type ProtocolCallback func(ctx context.Context, change ProtocolChange)
+
+// ProtocolWatcher detects protocol migrations in long-running services.
+// It watches block metadata for a next_protocol which differs from the
+// current protocol. On the last block of the old protocol it replaces the
+// client's params with preliminary params for the new protocol (version and
+// operation tag version are updated, constants are not yet known) and fires
+// the callback. When the first block of the new protocol arrives, the watcher
+// loads the new constants and fires the callback again with Activated set.
+// Params are updated like in Client.RefreshParams.
+type ProtocolWatcher struct {
+	c       *Client
+	cb      ProtocolCallback
+	mu      sync.Mutex
+	pending mavryk.ProtocolHash
+	current mavryk.ProtocolHash
+	sub     int
+	heads   chan *BlockHeaderLogEntry
+	cancel  context.CancelFunc
+}
+
+func NewProtocolWatcher(c *Client, cb ProtocolCallback) *ProtocolWatcher {
+	return &ProtocolWatcher{
+		c:     c,
+		cb:    cb,
+		heads: make(chan *BlockHeaderLogEntry, 16),
+	}
+}
+
+// Start subscribes to new blocks from the client's block observer and
+// processes them in the background until ctx is canceled or Stop is called.
+func (w *ProtocolWatcher) Start(ctx context.Context) {
+	ctx, w.cancel = context.WithCancel(ctx)
+	w.c.BlockObserver.Listen(w.c)
+	w.sub = w.c.BlockObserver.Subscribe(mavryk.ZeroOpHash, func(head *BlockHeaderLogEntry, _ int64, _ int, _ int, _ bool) bool {
+		// never block the observer, skip heads when busy
+		select {
+		case w.heads <- head:
+		default:
+		}
+		return false
+	})
+	go w.run(ctx)
+}
+
+// Stop unsubscribes from the block observer and stops the watcher.
+func (w *ProtocolWatcher) Stop() {
+	if w.cancel == nil {
+		return
+	}
+	w.c.BlockObserver.Unsubscribe(w.sub)
+	w.cancel()
+}
+
+func (w *ProtocolWatcher) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case head := <-w.heads:
+			if err := w.Check(ctx, head.Hash, head.Level); err != nil {
+				w.c.Log.Warnf("protocol watcher: block %d: %v", head.Level, err)
+			}
+		}
+	}
+}
+
+// Check inspects metadata of block id and fires the callback on protocol
+// changes. It is called for every new block after Start and may also be
+// called directly when blocks are processed by other means. The callback
+// runs without holding internal locks and may call back into the watcher.
+func (w *ProtocolWatcher) Check(ctx context.Context, id mavryk.BlockHash, level int64) error {
+	meta, err := w.c.GetBlockMetadata(ctx, id)
+	if err != nil {
+		return err
+	}
+	change, ok, err := w.detect(ctx, id, level, meta)
+	if err != nil || !ok {
+		return err
+	}
+	w.cb(ctx, change)
+	return nil
+}
+
+// detect updates the watcher state from block metadata and returns the
+// protocol change to report, if any.
+func (w *ProtocolWatcher) detect(ctx context.Context, id mavryk.BlockHash, level int64, meta *BlockMetadata) (ProtocolChange, bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// migration happened
+	if w.current.IsValid() && !w.current.Equal(meta.Protocol) {
+		p, err := w.c.loadParams(ctx, id, meta.NextProtocol)
+		if err != nil {
+			return ProtocolChange{}, false, err
+		}
+		old := w.current
+		w.current = meta.Protocol
+		w.pending = mavryk.ProtocolHash{}
+		return ProtocolChange{
+			Level:     level,
+			Block:     id,
+			Old:       old,
+			New:       meta.Protocol,
+			Params:    p,
+			Activated: true,
+		}, true, nil
+	}
+	w.current = meta.Protocol
+
+	// migration announced
+	if !meta.NextProtocol.Equal(meta.Protocol) && !w.pending.Equal(meta.NextProtocol) {
+		p, err := w.c.loadParams(ctx, id, meta.NextProtocol)
+		if err != nil {
+			return ProtocolChange{}, false, err
+		}
+		w.pending = meta.NextProtocol
+		return ProtocolChange{
+			Level:  level,
+			Block:  id,
+			Old:    meta.Protocol,
+			New:    meta.NextProtocol,
+			Params: p,
+		}, true, nil
+	}
+	return ProtocolChange{}, false, nil
+}
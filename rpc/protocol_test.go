@@ -0,0 +1,116 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+	"github.com/mavryk-network/mvgo/rpc"
+	"github.com/mavryk-network/mvgo/rpc/rpctest"
+)
+
+// serveProtocols overrides metadata of block hash with protocol and next.
+func serveProtocols(node *rpctest.Node, hash mavryk.BlockHash, proto, next mavryk.ProtocolHash) {
+	node.Handle(http.MethodGet, "/chains/main/blocks/"+hash.String()+"/metadata", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"protocol":      proto,
+			"next_protocol": next,
+			"level_info":    rpc.LevelInfo{Level: node.Head().Level},
+		})
+	})
+}
+
+// serveConstants serves empty constants at block id.
+func serveConstants(node *rpctest.Node, id string) {
+	node.Handle(http.MethodGet, "/chains/main/blocks/"+id+"/context/constants", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	})
+}
+
+func TestProtocolWatcher(t *testing.T) {
+	node, c, _ := newTestNode(t)
+	ctx := context.Background()
+	proto := node.Params().Protocol
+
+	var (
+		w       *rpc.ProtocolWatcher
+		changes []rpc.ProtocolChange
+	)
+	w = rpc.NewProtocolWatcher(c, func(ctx context.Context, change rpc.ProtocolChange) {
+		changes = append(changes, change)
+		// callbacks run unlocked and may use the watcher and client
+		if err := w.Check(ctx, change.Block, change.Level); err != nil {
+			t.Errorf("check from callback: %v", err)
+		}
+		_ = c.ChainParams()
+	})
+
+	// readers must not race with params updates
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				_ = c.ChainParams()
+			}
+		}
+	}()
+	defer func() {
+		close(done)
+		wg.Wait()
+	}()
+
+	head := node.Head()
+	if err := w.Check(ctx, head.Hash, head.Level); err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Fatalf("unexpected change %#v", changes)
+	}
+
+	// last block of the old protocol announces the new protocol
+	serveProtocols(node, head.Hash, proto, mavryk.ProtoAlpha)
+	serveConstants(node, head.Hash.String())
+	if err := w.Check(ctx, head.Hash, head.Level); err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 {
+		t.Fatalf("got %d changes, want 1", len(changes))
+	}
+	if ch := changes[0]; ch.Activated || !ch.Old.Equal(proto) || !ch.New.Equal(mavryk.ProtoAlpha) {
+		t.Errorf("bad announcement %#v", ch)
+	}
+	if p := c.ChainParams(); p != changes[0].Params || !p.Protocol.Equal(mavryk.ProtoAlpha) {
+		t.Errorf("client params not switched to next protocol, got %s", p.Protocol)
+	}
+
+	// first block of the new protocol activates it
+	next := node.Bake()
+	serveProtocols(node, next.Hash, mavryk.ProtoAlpha, mavryk.ProtoAlpha)
+	serveConstants(node, next.Hash.String())
+	if err := w.Check(ctx, next.Hash, next.Level); err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 {
+		t.Fatalf("got %d changes, want 2", len(changes))
+	}
+	if ch := changes[1]; !ch.Activated || !ch.New.Equal(mavryk.ProtoAlpha) {
+		t.Errorf("bad activation %#v", ch)
+	}
+	if p := c.ChainParams(); p != changes[1].Params || !p.Protocol.Equal(mavryk.ProtoAlpha) {
+		t.Errorf("client params not replaced, got protocol %s", p.Protocol)
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// PruneFlags selects which parts of operation and block metadata are dropped
+// while decoding. Pruning shrinks the memory footprint of bulk scans where
+// callers only need operation kinds, amounts and status.
+//
+// Note that costs and burn calculations rely on balance updates, so Costs()
+// reports incomplete values for operations pruned with PruneBalanceUpdates.
+type PruneFlags byte
+
+const (
+	PruneBalanceUpdates  PruneFlags = 1 << iota // drop balance updates from metadata and results
+	PruneInternalResults                        // drop internal operation results
+	PruneStorage                                // drop storage, bigmap and lazy storage diffs from results
+	PruneErrors                                 // drop error details from results
+	PruneImplicitResults                        // drop implicit operation results from block metadata
+
+	PruneNone PruneFlags = 0
+	PruneAll  PruneFlags = 0xff
+)
+
+// Contains returns true when all flags in x are set.
+func (f PruneFlags) Contains(x PruneFlags) bool {
+	return f&x == x
+}
+
+// pruner is implemented by all operation types which carry metadata.
+type pruner interface {
+	prune(PruneFlags)
+}
+
+func (r *OperationResult) prune(f PruneFlags) {
+	if f.Contains(PruneBalanceUpdates) {
+		r.BalanceUpdates = nil
+	}
+	if f.Contains(PruneStorage) {
+		r.Storage = nil
+		r.BigmapDiff = nil
+		r.LazyStorageDiff = nil
+	}
+	if f.Contains(PruneErrors) {
+		r.Errors = nil
+	}
+}
+
+func (m *OperationMetadata) prune(f PruneFlags) {
+	if f.Contains(PruneBalanceUpdates) {
+		m.BalanceUpdates = nil
+	}
+	m.Result.prune(f)
+	if f.Contains(PruneInternalResults) {
+		m.InternalResults = nil
+	} else {
+		for _, v := range m.InternalResults {
+			v.Result.prune(f)
+		}
+	}
+}
+
+func (e *Generic) prune(f PruneFlags) {
+	e.Metadata.prune(f)
+}
+
+// Prune drops metadata selected by flags f from all operation contents.
+func (o *Operation) Prune(f PruneFlags) {
+	if f == PruneNone {
+		return
+	}
+	for _, v := range o.Contents {
+		if p, ok := v.(pruner); ok {
+			p.prune(f)
+		}
+	}
+}
+
+func (m *BlockMetadata) prune(f PruneFlags) {
+	if f.Contains(PruneBalanceUpdates) {
+		m.BalanceUpdates = nil
+	}
+	if f.Contains(PruneImplicitResults) {
+		m.ImplicitOperationsResults = nil
+	}
+}
+
+// Prune drops metadata selected by flags f from the block and all
+// contained operations.
+func (b *Block) Prune(f PruneFlags) {
+	if f == PruneNone {
+		return
+	}
+	b.Metadata.prune(f)
+	for _, list := range b.Operations {
+		for _, op := range list {
+			op.Prune(f)
+		}
+	}
+}
+
+// GetBlockPruned returns information about a Tezos block like GetBlock, but
+// drops metadata selected by flags f while decoding. Operations are decoded
+// and pruned one by one from the response stream, so peak memory stays close
+// to the size of the pruned block.
+func (c *Client) GetBlockPruned(ctx context.Context, id BlockID, f PruneFlags) (*Block, error) {
+	if f == PruneNone {
+		return c.GetBlock(ctx, id)
+	}
+	u := fmt.Sprintf("chains/main/blocks/%s", id)
+	if c.MetadataMode != "" {
+		u += "?metadata=" + string(c.MetadataMode)
+	}
+	body, err := c.getStream(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		io.Copy(io.Discard, body)
+		body.Close()
+	}()
+
+	var (
+		block Block
+		rest  = make(map[string]json.RawMessage)
+	)
+	dec := json.NewDecoder(body)
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, err
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("rpc: %w", err)
+		}
+		switch key, _ := tok.(string); key {
+		case "operations":
+			err = c.decodeOperations(ctx, dec, func(int) {
+				block.Operations = append(block.Operations, make([]*Operation, 0))
+			}, func(l, _ int, op *Operation) error {
+				op.Prune(f)
+				block.Operations[l] = append(block.Operations[l], op)
+				return nil
+			})
+		case "metadata":
+			if err = c.decodeNext(dec, &block.Metadata); err == nil {
+				block.Metadata.prune(f)
+			}
+		default:
+			var v json.RawMessage
+			if err = dec.Decode(&v); err == nil {
+				rest[key] = v
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := expectDelim(dec, '}'); err != nil {
+		return nil, err
+	}
+
+	// decode remaining small fields like hash and header
+	buf, err := json.Marshal(rest)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.unmarshal(buf, &block); err != nil {
+		return nil, err
+	}
+	return &block, nil
+}
+
+// GetBlockOperationsPruned returns all operations in a block like GetBlockOperations,
+// but drops metadata selected by flags f while decoding.
+func (c *Client) GetBlockOperationsPruned(ctx context.Context, id BlockID, f PruneFlags) ([][]Operation, error) {
+	ops := make([][]Operation, 0, 4)
+	err := c.streamBlockOperations(ctx, id, func(int) {
+		ops = append(ops, make([]Operation, 0))
+	}, func(l, _ int, op *Operation) error {
+		op.Prune(f)
+		ops[l] = append(ops[l], *op)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ops, nil
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+	"github.com/mavryk-network/mvgo/rpc"
+	"github.com/mavryk-network/mvgo/rpc/rpctest"
+)
+
+// withBalanceUpdates serves block hash with a balance update in every
+// operation receipt.
+func withBalanceUpdates(t *testing.T, node *rpctest.Node, c *rpc.Client, hash mavryk.BlockHash) {
+	t.Helper()
+	for _, p := range []string{"", "/operations"} {
+		path := "/chains/main/blocks/" + hash.String() + p
+		var buf json.RawMessage
+		if err := c.Get(context.Background(), path[1:], &buf); err != nil {
+			t.Fatal(err)
+		}
+		upd := `"balance_updates":[{"kind":"contract","contract":"` + testReceiver.String() + `","change":"1000","origin":"block"}]`
+		buf = bytes.ReplaceAll(buf, []byte(`"balance_updates":[]`), []byte(upd))
+		node.Handle(http.MethodGet, path, func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(buf)
+		})
+	}
+}
+
+func TestGetBlockPruned(t *testing.T) {
+	node, c, _ := newTestNode(t)
+	rcpt := sendTransfer(t, node, c, 1000)
+	withBalanceUpdates(t, node, c, rcpt.Block)
+	ctx := context.Background()
+
+	full, err := c.GetBlock(ctx, rcpt.Block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := c.GetBlockPruned(ctx, rcpt.Block, rpc.PruneBalanceUpdates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Hash != full.Hash || b.Header.Level != full.Header.Level || b.Protocol != full.Protocol {
+		t.Errorf("block mismatch, want=%s/%d have=%s/%d", full.Hash, full.Header.Level, b.Hash, b.Header.Level)
+	}
+	if len(b.Operations) != len(full.Operations) {
+		t.Fatalf("list mismatch, want=%d have=%d", len(full.Operations), len(b.Operations))
+	}
+	op := b.Operations[rcpt.List][rcpt.Pos]
+	if op.Hash != full.Operations[rcpt.List][rcpt.Pos].Hash {
+		t.Errorf("operation mismatch, want=%s have=%s", full.Operations[rcpt.List][rcpt.Pos].Hash, op.Hash)
+	}
+	for _, v := range full.Operations[rcpt.List][rcpt.Pos].Contents {
+		if len(v.Meta().BalanceUpdates) == 0 {
+			t.Fatal("test block has no balance updates")
+		}
+	}
+	for _, v := range op.Contents {
+		if n := len(v.Meta().BalanceUpdates); n > 0 {
+			t.Errorf("%s: unexpected %d balance updates", v.Kind(), n)
+		}
+		if v.Result().Status != mavryk.OpStatusApplied {
+			t.Errorf("%s: status mismatch, want=applied have=%s", v.Kind(), v.Result().Status)
+		}
+	}
+}
+
+func TestGetBlockOperationsPruned(t *testing.T) {
+	node, c, _ := newTestNode(t)
+	rcpt := sendTransfer(t, node, c, 1000)
+	withBalanceUpdates(t, node, c, rcpt.Block)
+
+	ops, err := c.GetBlockOperationsPruned(context.Background(), rcpt.Block, rpc.PruneBalanceUpdates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range ops[rcpt.List][rcpt.Pos].Contents {
+		if n := len(v.Meta().BalanceUpdates); n > 0 {
+			t.Errorf("%s: unexpected %d balance updates", v.Kind(), n)
+		}
+	}
+}
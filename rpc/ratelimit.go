@@ -0,0 +1,135 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenBucket is a token bucket rate limiter. It allows Rate requests per
+// second on average with bursts of up to Burst requests.
+type TokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucket creates a rate limiter which starts with a full bucket.
+// Burst values below 1 are set to 1.
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &TokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// reserve takes a token and returns how long the caller must wait before
+// the token becomes valid.
+func (b *TokenBucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// cancel returns a reserved token, e.g. when the caller stopped waiting.
+func (b *TokenBucket) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens++
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
+
+// Wait blocks until a token is available or ctx is done.
+func (b *TokenBucket) Wait(ctx context.Context) error {
+	if b == nil || b.rate <= 0 {
+		return nil
+	}
+	d := b.reserve()
+	if d == 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		b.cancel()
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// RateLimiter limits requests sent by a client globally and per endpoint
+// class (see EndpointClass). Requests wait for both the global and their
+// class limit. Waiting respects the request context.
+type RateLimiter struct {
+	Global  *TokenBucket
+	Classes map[string]*TokenBucket
+}
+
+// NewRateLimiter creates a limiter with a global limit of rate requests
+// per second and the given burst size.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		Global:  NewTokenBucket(rate, burst),
+		Classes: make(map[string]*TokenBucket),
+	}
+}
+
+// WithClass adds a limit for an endpoint class such as EndpointContext.
+func (l *RateLimiter) WithClass(class string, rate float64, burst int) *RateLimiter {
+	if l.Classes == nil {
+		l.Classes = make(map[string]*TokenBucket)
+	}
+	l.Classes[class] = NewTokenBucket(rate, burst)
+	return l
+}
+
+// Wait blocks until a request to urlpath is allowed or ctx is done.
+func (l *RateLimiter) Wait(ctx context.Context, urlpath string) error {
+	if l == nil {
+		return nil
+	}
+	if b, ok := l.Classes[EndpointClass(urlpath)]; ok {
+		if err := b.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	return l.Global.Wait(ctx)
+}
+
+// limit waits for the client's rate limiter before sending req.
+func (c *Client) limit(req *http.Request) error {
+	if c.RateLimit == nil {
+		return nil
+	}
+	path := req.URL.Path
+	if c.BaseURL != nil {
+		path = strings.TrimPrefix(path, strings.TrimSuffix(c.BaseURL.Path, "/"))
+	}
+	return c.RateLimit.Wait(req.Context(), path)
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+var (
+	rpcPkgPath     = reflect.TypeOf(Block{}).PkgPath()
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// retainRaw sets the Raw field of v and of all nested rpc types which have
+// one to a copy of the JSON they were decoded from, see Client.RetainRawJSON.
+func retainRaw(data []byte, v reflect.Value) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		if t.PkgPath() != rpcPkgPath {
+			return
+		}
+		var obj map[string]json.RawMessage
+		if json.Unmarshal(data, &obj) != nil {
+			return
+		}
+		if f := v.FieldByName("Raw"); f.IsValid() && f.CanSet() && f.Type() == rawMessageType {
+			raw := make(json.RawMessage, len(data))
+			copy(raw, data)
+			f.Set(reflect.ValueOf(raw))
+		}
+		fields := jsonFields(t)
+		for k, buf := range obj {
+			sf, ok := fields[strings.ToLower(k)]
+			if !ok {
+				continue
+			}
+			if f, err := v.FieldByIndexErr(sf.Index); err == nil {
+				retainRaw(buf, f)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return
+		}
+		var arr []json.RawMessage
+		if json.Unmarshal(data, &arr) != nil {
+			return
+		}
+		for i := 0; i < len(arr) && i < v.Len(); i++ {
+			retainRaw(arr[i], v.Index(i))
+		}
+	}
+}
+
+func (c *Constants) UnmarshalJSON(data []byte) error {
+	type alias Constants
+	if err := json.Unmarshal(data, (*alias)(c)); err != nil {
+		return err
+	}
+	c.Extra = extraFields(data, c)
+	return nil
+}
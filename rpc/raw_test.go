@@ -0,0 +1,59 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mavryk-network/mvgo/rpc"
+)
+
+func TestRetainRawJSON(t *testing.T) {
+	node, c, _ := newTestNode(t, rpc.WithRawJSON())
+	rcpt := sendTransfer(t, node, c, 1000)
+
+	b, err := c.GetBlock(context.Background(), rcpt.Block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Raw) == 0 {
+		t.Error("missing block raw json")
+	}
+	if len(b.Header.Raw) == 0 {
+		t.Error("missing header raw json")
+	}
+	if len(b.Metadata.Raw) == 0 {
+		t.Error("missing metadata raw json")
+	}
+	op := b.Operations[rcpt.List][rcpt.Pos]
+	if len(op.Raw) == 0 {
+		t.Error("missing operation raw json")
+	}
+	var n int
+	for _, v := range op.Contents {
+		if tx, ok := v.(*rpc.Transaction); ok {
+			n++
+			if len(tx.Raw) == 0 {
+				t.Error("missing transaction raw json")
+			}
+		}
+	}
+	if n != 1 {
+		t.Errorf("transaction mismatch, want=1 have=%d", n)
+	}
+}
+
+func TestRetainRawJSONDisabled(t *testing.T) {
+	node, c, _ := newTestNode(t)
+	rcpt := sendTransfer(t, node, c, 1000)
+
+	b, err := c.GetBlock(context.Background(), rcpt.Block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Raw != nil || b.Header.Raw != nil || b.Operations[rcpt.List][rcpt.Pos].Raw != nil {
+		t.Error("unexpected raw json")
+	}
+}
@@ -119,6 +119,7 @@ type Result struct {
 	subId  int              // monitor subscription id
 	done   chan struct{}    // channel used to signal completion
 	once   sync.Once        // ensures only one completion state exists
+	mu     sync.Mutex       // protects fields above against observer callbacks
 }
 
 func NewResult(oh mavryk.OpHash) *Result {
@@ -133,35 +134,65 @@ func (r *Result) Hash() mavryk.OpHash {
 	return r.oh
 }
 
+// Listen subscribes to observer o. The observer may call back before
+// Subscribe returns, so the subscription id is only kept while the result
+// is still pending.
 func (r *Result) Listen(o *Observer) {
-	if o != nil {
-		r.obs = o
-		r.subId = r.obs.Subscribe(r.oh, r.callback)
+	if o == nil {
+		return
+	}
+	r.mu.Lock()
+	r.obs = o
+	r.mu.Unlock()
+	id := o.Subscribe(r.oh, r.callback)
+	r.mu.Lock()
+	select {
+	case <-r.done:
+		r.mu.Unlock()
+		o.Unsubscribe(id)
+	default:
+		r.subId = id
+		r.mu.Unlock()
 	}
 }
 
 func (r *Result) Cancel() {
+	// unsubscribe outside the lock because the observer calls back while
+	// holding its own lock
+	r.mu.Lock()
+	id := r.subId
+	r.subId = 0
+	r.mu.Unlock()
+	if id > 0 {
+		r.obs.Unsubscribe(id)
+	}
 	r.once.Do(func() {
-		if r.subId > 0 {
-			r.obs.Unsubscribe(r.subId)
+		if id > 0 {
+			r.mu.Lock()
 			r.err = Canceled
-			r.subId = 0
+			r.mu.Unlock()
 		}
 		close(r.done)
 	})
 }
 
 func (r *Result) WithConfirmations(n int64) *Result {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	r.wait = n
 	return r
 }
 
 func (r *Result) WithTTL(n int64) *Result {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	r.ttl = n
 	return r
 }
 
 func (r *Result) Confirmations() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	return r.blocks
 }
 
@@ -170,11 +201,15 @@ func (r *Result) Done() <-chan struct{} {
 }
 
 func (r *Result) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	return r.err
 }
 
 func (r *Result) GetReceipt(ctx context.Context) (*Receipt, error) {
+	r.mu.Lock()
 	if r.err != nil {
+		defer r.mu.Unlock()
 		return nil, r.err
 	}
 	rec := &Receipt{
@@ -183,8 +218,10 @@ func (r *Result) GetReceipt(ctx context.Context) (*Receipt, error) {
 		Pos:    r.pos,
 		List:   r.list,
 	}
-	if r.obs != nil {
-		op, err := r.obs.c.GetBlockOperation(ctx, r.block, r.list, r.pos)
+	obs := r.obs
+	r.mu.Unlock()
+	if obs != nil {
+		op, err := obs.c.GetBlockOperation(ctx, rec.Block, rec.List, rec.Pos)
 		if err != nil {
 			return rec, err
 		}
@@ -200,7 +237,9 @@ func (r *Result) Wait() {
 func (r *Result) WaitContext(ctx context.Context) bool {
 	select {
 	case <-ctx.Done():
+		r.mu.Lock()
 		r.err = context.Canceled
+		r.mu.Unlock()
 		return false
 	case <-r.done:
 		return true
@@ -208,34 +247,39 @@ func (r *Result) WaitContext(ctx context.Context) bool {
 }
 
 func (r *Result) callback(block *BlockHeaderLogEntry, height int64, list, pos int, force bool) bool {
-	if force {
+	r.mu.Lock()
+	if force || !r.block.IsValid() {
 		r.block = block.Hash
 		r.height = height
 		r.list = list
 		r.pos = pos
-		return false
 	}
-	if !r.block.IsValid() {
-		r.block = block.Hash
-		r.height = height
-		r.list = list
-		r.pos = pos
+	if force {
+		r.mu.Unlock()
+		return false
 	}
 	r.blocks++
-	if r.ttl > 0 && r.blocks >= r.ttl {
-		r.once.Do(func() {
-			r.err = TTLExceeded
-			r.subId = 0
-			close(r.done)
-		})
-		return true
+	var (
+		done bool
+		err  error
+	)
+	switch {
+	case r.ttl > 0 && r.blocks >= r.ttl:
+		done, err = true, TTLExceeded
+	case r.blocks >= r.wait:
+		done = true
 	}
-	if r.blocks >= r.wait {
+	r.mu.Unlock()
+	if done {
 		r.once.Do(func() {
+			r.mu.Lock()
+			if err != nil {
+				r.err = err
+			}
 			r.subId = 0
+			r.mu.Unlock()
 			close(r.done)
 		})
-		return true
 	}
-	return false
+	return done
 }
@@ -0,0 +1,308 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+)
+
+// DefaultReconnectPolicy controls reconnect backoff of monitors. Reconnects
+// are attempted forever.
+var DefaultReconnectPolicy = RetryPolicy{
+	MaxAttempts: 0,
+	MinBackoff:  500 * time.Millisecond,
+	MaxBackoff:  30 * time.Second,
+	Jitter:      0.2,
+}
+
+// DefaultDedupSize is the number of recent item ids reconnecting monitors
+// remember to suppress items which the node replays after reconnect.
+const DefaultDedupSize = 4096
+
+// MonitorStream is a single monitor connection as implemented by all
+// monitor types in this package.
+type MonitorStream[T any] interface {
+	Recv(context.Context) (T, error)
+	Close()
+}
+
+// OpenFunc opens a new monitor connection.
+type OpenFunc[T any] func(context.Context) (MonitorStream[T], error)
+
+// DedupFunc filters items which have already been delivered. It returns
+// the filtered item and false when nothing remains to be delivered.
+type DedupFunc[T any] func(T) (T, bool)
+
+// ReconnectEvent is sent when a monitor connection drops or is restored.
+type ReconnectEvent struct {
+	Monitor   string    // monitor name
+	Attempt   int       // consecutive failed attempts, zero after success
+	Connected bool      // true when the connection was restored
+	Err       error     // error that caused the reconnect
+	Time      time.Time // event time
+}
+
+// ReconnectingMonitor wraps a monitor stream and transparently reconnects
+// with backoff when the stream drops. Items which the node replays after
+// reconnect are suppressed when a dedup function is set. Reconnect events
+// are available from Events, slow readers miss events.
+//
+// Recv returns an error only when the monitor is closed, the context is
+// cancelled, the node rejects the request permanently or when the number
+// of consecutive failed connects exceeds the policy's MaxAttempts.
+type ReconnectingMonitor[T any] struct {
+	Policy RetryPolicy
+
+	c       *Client
+	name    string
+	open    OpenFunc[T]
+	dedup   DedupFunc[T]
+	ctx     context.Context
+	cancel  context.CancelFunc
+	events  chan ReconnectEvent
+	mu      sync.Mutex
+	mon     MonitorStream[T]
+	attempt int
+	wasUp   bool
+}
+
+// NewReconnectingMonitor creates a monitor which uses open to (re)connect.
+// The monitor stays active until ctx is cancelled or Close is called.
+func NewReconnectingMonitor[T any](ctx context.Context, c *Client, name string, open OpenFunc[T], opts ...MonitorOption) *ReconnectingMonitor[T] {
+	cfg := monitorConfig{
+		policy: DefaultReconnectPolicy,
+		events: 16,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	return &ReconnectingMonitor[T]{
+		Policy: cfg.policy,
+		c:      c,
+		name:   name,
+		open:   open,
+		ctx:    ctx,
+		cancel: cancel,
+		events: make(chan ReconnectEvent, cfg.events),
+	}
+}
+
+// WithDedup sets a function to suppress replayed items.
+func (m *ReconnectingMonitor[T]) WithDedup(fn DedupFunc[T]) *ReconnectingMonitor[T] {
+	m.dedup = fn
+	return m
+}
+
+// WithPolicy sets the reconnect backoff policy.
+func (m *ReconnectingMonitor[T]) WithPolicy(p RetryPolicy) *ReconnectingMonitor[T] {
+	m.Policy = p
+	return m
+}
+
+// Events returns a channel of reconnect events.
+func (m *ReconnectingMonitor[T]) Events() <-chan ReconnectEvent {
+	return m.events
+}
+
+// Recv returns the next item, reconnecting as needed.
+func (m *ReconnectingMonitor[T]) Recv(ctx context.Context) (T, error) {
+	var null T
+	for {
+		if err := m.err(ctx); err != nil {
+			return null, err
+		}
+		mon, err := m.connect(ctx)
+		if err != nil {
+			return null, err
+		}
+		if mon == nil {
+			continue
+		}
+		v, err := mon.Recv(ctx)
+		if err != nil {
+			if e := m.err(ctx); e != nil {
+				return null, e
+			}
+			m.drop(err)
+			continue
+		}
+		if m.dedup != nil {
+			var ok bool
+			if v, ok = m.dedup(v); !ok {
+				continue
+			}
+		}
+		return v, nil
+	}
+}
+
+// Close stops the monitor and closes the active connection.
+func (m *ReconnectingMonitor[T]) Close() {
+	m.cancel()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.mon != nil {
+		m.mon.Close()
+		m.mon = nil
+	}
+}
+
+func (m *ReconnectingMonitor[T]) err(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-m.ctx.Done():
+		return ErrMonitorClosed
+	default:
+		return nil
+	}
+}
+
+// connect returns the active connection or opens a new one. It returns nil
+// without error after a failed attempt to let the caller check for close.
+func (m *ReconnectingMonitor[T]) connect(ctx context.Context) (MonitorStream[T], error) {
+	m.mu.Lock()
+	mon := m.mon
+	m.mu.Unlock()
+	if mon != nil {
+		return mon, nil
+	}
+
+	if m.attempt > 0 {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-m.ctx.Done():
+			return nil, ErrMonitorClosed
+		case <-time.After(m.Policy.Backoff(m.attempt)):
+		}
+	}
+
+	mon, err := m.open(m.ctx)
+	if err != nil {
+		if m.ctx.Err() != nil {
+			return nil, ErrMonitorClosed
+		}
+		if !IsTransient(err) {
+			return nil, err
+		}
+		m.attempt++
+		if m.Policy.MaxAttempts > 0 && m.attempt >= m.Policy.MaxAttempts {
+			return nil, err
+		}
+		m.emit(ReconnectEvent{Attempt: m.attempt, Err: err})
+		return nil, nil
+	}
+
+	m.mu.Lock()
+	m.mon = mon
+	m.mu.Unlock()
+	if m.wasUp {
+		m.emit(ReconnectEvent{Connected: true})
+		if m.c != nil && m.c.Metrics != nil {
+			m.c.Metrics.ObserveReconnect(m.name)
+		}
+	}
+	m.attempt = 0
+	m.wasUp = true
+	return mon, nil
+}
+
+func (m *ReconnectingMonitor[T]) drop(err error) {
+	m.mu.Lock()
+	if m.mon != nil {
+		m.mon.Close()
+		m.mon = nil
+	}
+	m.mu.Unlock()
+	if m.c != nil {
+		m.c.Log.Debugf("monitor: %s connection dropped: %v", m.name, err)
+	}
+	m.emit(ReconnectEvent{Err: err})
+}
+
+func (m *ReconnectingMonitor[T]) emit(e ReconnectEvent) {
+	e.Monitor = m.name
+	e.Time = time.Now()
+	select {
+	case m.events <- e:
+	default:
+	}
+}
+
+// dedupSet remembers a bounded number of recently seen ids.
+type dedupSet[K comparable] struct {
+	seen map[K]struct{}
+	ring []K
+	pos  int
+}
+
+func newDedupSet[K comparable](n int) *dedupSet[K] {
+	return &dedupSet[K]{
+		seen: make(map[K]struct{}, n),
+		ring: make([]K, 0, n),
+	}
+}
+
+// Add returns false when k was seen before.
+func (s *dedupSet[K]) Add(k K) bool {
+	if _, ok := s.seen[k]; ok {
+		return false
+	}
+	if len(s.ring) < cap(s.ring) {
+		s.ring = append(s.ring, k)
+	} else {
+		delete(s.seen, s.ring[s.pos])
+		s.ring[s.pos] = k
+		s.pos = (s.pos + 1) % len(s.ring)
+	}
+	s.seen[k] = struct{}{}
+	return true
+}
+
+// NewReconnectingBlockMonitor returns a reconnecting monitor for new chain
+// heads. Heads which were already delivered are suppressed.
+func (c *Client) NewReconnectingBlockMonitor(ctx context.Context, opts ...MonitorOption) *ReconnectingMonitor[*BlockHeaderLogEntry] {
+	seen := newDedupSet[mavryk.BlockHash](DefaultDedupSize)
+	return NewReconnectingMonitor(ctx, c, "blocks", func(ctx context.Context) (MonitorStream[*BlockHeaderLogEntry], error) {
+		mon := NewBlockHeaderMonitor()
+		if err := c.MonitorBlockHeader(ctx, mon); err != nil {
+			mon.Close()
+			return nil, err
+		}
+		return mon, nil
+	}, opts...).WithDedup(func(h *BlockHeaderLogEntry) (*BlockHeaderLogEntry, bool) {
+		return h, seen.Add(h.Hash)
+	})
+}
+
+// NewReconnectingMempoolMonitor returns a reconnecting monitor for mempool
+// operations. The node replays all pending operations on each connect,
+// operations which were already delivered are removed from the result.
+// Note that this also suppresses operations which re-enter the mempool
+// after a reorg.
+func (c *Client) NewReconnectingMempoolMonitor(ctx context.Context, opts ...MonitorOption) *ReconnectingMonitor[[]*Operation] {
+	seen := newDedupSet[mavryk.OpHash](DefaultDedupSize)
+	return NewReconnectingMonitor(ctx, c, "mempool", func(ctx context.Context) (MonitorStream[[]*Operation], error) {
+		mon := NewMempoolMonitor()
+		if err := c.MonitorMempool(ctx, mon); err != nil {
+			mon.Close()
+			return nil, err
+		}
+		return mon, nil
+	}, opts...).WithDedup(func(ops []*Operation) ([]*Operation, bool) {
+		res := ops[:0]
+		for _, op := range ops {
+			if seen.Add(op.Hash) {
+				res = append(res, op)
+			}
+		}
+		return res, len(res) > 0
+	})
+}
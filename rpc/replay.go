@@ -0,0 +1,211 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+)
+
+// Divergence describes a receipt field which differs between the recorded
+// and replayed execution of an operation.
+type Divergence struct {
+	Index    int    `json:"index"`    // position in operation contents
+	Internal int    `json:"internal"` // position in internal results, -1 for the main result
+	Field    string `json:"field"`
+	Recorded string `json:"recorded"`
+	Replayed string `json:"replayed"`
+}
+
+func (d Divergence) String() string {
+	pos := strconv.Itoa(d.Index)
+	if d.Internal >= 0 {
+		pos += "/" + strconv.Itoa(d.Internal)
+	}
+	return fmt.Sprintf("op %s %s: recorded=%s replayed=%s", pos, d.Field, d.Recorded, d.Replayed)
+}
+
+// ReplayResult contains the recorded and replayed receipts of a historic
+// operation and all detected divergences.
+type ReplayResult struct {
+	Hash        mavryk.OpHash    `json:"hash"`
+	Block       mavryk.BlockHash `json:"block"`
+	Recorded    *Operation       `json:"recorded"`
+	Replayed    *Operation       `json:"replayed"`
+	Divergences []Divergence     `json:"divergences"`
+}
+
+// Match returns true when the replayed receipt equals the recorded receipt.
+func (r ReplayResult) Match() bool {
+	return len(r.Divergences) == 0
+}
+
+// ReplayOperation re-executes the operation at list l and position n of
+// block id with run_operation against the block's predecessor context and
+// compares the produced receipt with the receipt recorded on-chain. This
+// requires an archive node (or a rolling node which still has the
+// predecessor context).
+//
+// Run_operation does not check signatures and starts from the state at the
+// end of the predecessor block. Operations earlier in the same block which
+// touched the same contracts are not applied, so divergences may be caused
+// by such operations rather than by node or indexer bugs.
+func (c *Client) ReplayOperation(ctx context.Context, id BlockID, l, n int) (*ReplayResult, error) {
+	block, err := c.GetBlockHash(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	rec, err := c.GetBlockOperation(ctx, block, l, n)
+	if err != nil {
+		return nil, err
+	}
+
+	// fetch the operation as JSON and strip receipts
+	var raw struct {
+		Branch    mavryk.BlockHash             `json:"branch"`
+		Contents  []map[string]json.RawMessage `json:"contents"`
+		Signature mavryk.Signature             `json:"signature"`
+	}
+	u := fmt.Sprintf("chains/main/blocks/%s/operations/%d/%d", block, l, n)
+	if err := c.Get(ctx, u, &raw); err != nil {
+		return nil, err
+	}
+	for _, v := range raw.Contents {
+		delete(v, "metadata")
+	}
+	req := struct {
+		Operation interface{}        `json:"operation"`
+		ChainId   mavryk.ChainIdHash `json:"chain_id"`
+	}{
+		Operation: raw,
+		ChainId:   rec.ChainID,
+	}
+	rep := &Operation{}
+	if err := c.RunOperation(ctx, BlockBefore(block, 1), req, rep); err != nil {
+		return nil, err
+	}
+	rep.Hash = rec.Hash
+
+	return &ReplayResult{
+		Hash:        rec.Hash,
+		Block:       block,
+		Recorded:    rec,
+		Replayed:    rep,
+		Divergences: CompareReceipts(rec, rep),
+	}, nil
+}
+
+// ReplayOperationHash is like ReplayOperation but looks up the operation
+// by hash in block id.
+func (c *Client) ReplayOperationHash(ctx context.Context, id BlockID, hash mavryk.OpHash) (*ReplayResult, error) {
+	block, err := c.GetBlockHash(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	hashes, err := c.GetBlockOperationHashes(ctx, block)
+	if err != nil {
+		return nil, err
+	}
+	for l, list := range hashes {
+		for n, h := range list {
+			if h.Equal(hash) {
+				return c.ReplayOperation(ctx, block, l, n)
+			}
+		}
+	}
+	return nil, fmt.Errorf("rpc: operation %s not found in block %s", hash, block)
+}
+
+// CompareReceipts compares execution receipts of two versions of the same
+// operation and returns all fields which differ.
+func CompareReceipts(a, b *Operation) []Divergence {
+	div := make([]Divergence, 0)
+	if len(a.Contents) != len(b.Contents) {
+		return append(div, Divergence{
+			Index:    -1,
+			Internal: -1,
+			Field:    "contents",
+			Recorded: strconv.Itoa(len(a.Contents)),
+			Replayed: strconv.Itoa(len(b.Contents)),
+		})
+	}
+	for i := range a.Contents {
+		ma, mb := a.Contents[i].Meta(), b.Contents[i].Meta()
+		div = compareResult(div, i, -1, ma.Result, mb.Result)
+		div = compareField(div, i, -1, "balance_updates", formatBalanceUpdates(ma.BalanceUpdates), formatBalanceUpdates(mb.BalanceUpdates))
+		div = compareField(div, i, -1, "internal_results", strconv.Itoa(len(ma.InternalResults)), strconv.Itoa(len(mb.InternalResults)))
+		if len(ma.InternalResults) != len(mb.InternalResults) {
+			continue
+		}
+		for j := range ma.InternalResults {
+			ia, ib := ma.InternalResults[j], mb.InternalResults[j]
+			div = compareField(div, i, j, "kind", ia.Kind.String(), ib.Kind.String())
+			div = compareResult(div, i, j, ia.Result, ib.Result)
+		}
+	}
+	return div
+}
+
+func compareResult(div []Divergence, i, j int, a, b OperationResult) []Divergence {
+	div = compareField(div, i, j, "status", a.Status.String(), b.Status.String())
+	div = compareField(div, i, j, "consumed_milligas", strconv.FormatInt(a.MilliGas(), 10), strconv.FormatInt(b.MilliGas(), 10))
+	div = compareField(div, i, j, "storage_size", strconv.FormatInt(a.StorageSize, 10), strconv.FormatInt(b.StorageSize, 10))
+	div = compareField(div, i, j, "paid_storage_size_diff", strconv.FormatInt(a.PaidStorageSizeDiff, 10), strconv.FormatInt(b.PaidStorageSizeDiff, 10))
+	div = compareField(div, i, j, "allocated", strconv.FormatBool(a.Allocated), strconv.FormatBool(b.Allocated))
+	div = compareField(div, i, j, "originated_contracts", formatAddresses(a.OriginatedContracts), formatAddresses(b.OriginatedContracts))
+	div = compareField(div, i, j, "balance_updates", formatBalanceUpdates(a.BalanceUpdates), formatBalanceUpdates(b.BalanceUpdates))
+	div = compareField(div, i, j, "errors", formatErrors(a.Errors), formatErrors(b.Errors))
+	var sa, sb string
+	if a.Storage != nil {
+		sa = a.Storage.Dump()
+	}
+	if b.Storage != nil {
+		sb = b.Storage.Dump()
+	}
+	return compareField(div, i, j, "storage", sa, sb)
+}
+
+func compareField(div []Divergence, i, j int, field, a, b string) []Divergence {
+	if a == b {
+		return div
+	}
+	return append(div, Divergence{
+		Index:    i,
+		Internal: j,
+		Field:    field,
+		Recorded: a,
+		Replayed: b,
+	})
+}
+
+func formatBalanceUpdates(list BalanceUpdates) string {
+	s := make([]string, len(list))
+	for i, v := range list {
+		s[i] = fmt.Sprintf("%s:%s:%s:%d", v.Kind, v.Category, v.Address(), v.Change)
+	}
+	sort.Strings(s)
+	return strings.Join(s, ",")
+}
+
+func formatAddresses(list []mavryk.Address) string {
+	s := make([]string, len(list))
+	for i, v := range list {
+		s[i] = v.String()
+	}
+	return strings.Join(s, ",")
+}
+
+func formatErrors(list []OperationError) string {
+	s := make([]string, len(list))
+	for i, v := range list {
+		s[i] = v.ErrorID()
+	}
+	return strings.Join(s, ",")
+}
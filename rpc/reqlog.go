@@ -0,0 +1,118 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/echa/log"
+)
+
+// RequestEvent describes an HTTP request sent by a client.
+type RequestEvent struct {
+	Method    string
+	Path      string        // request path relative to the base URL
+	Status    int           // response status, zero on start and transport errors
+	Duration  time.Duration // time until response headers arrived, zero on start
+	Err       error         // transport error
+	ErrorKind string        // kind of the last node error, e.g. temporary
+	ErrorId   string        // id of the last node error
+}
+
+// Failed returns true when the request failed with a transport error or an
+// error status.
+func (e RequestEvent) Failed() bool {
+	return e.Err != nil || e.Status >= 400
+}
+
+// RequestLogger receives structured events for each HTTP request, including
+// retries and monitor connections. Calls happen synchronously on the request
+// path, implementations must be safe for concurrent use and return quickly.
+type RequestLogger interface {
+	RequestStarted(ctx context.Context, e RequestEvent)
+	RequestFinished(ctx context.Context, e RequestEvent)
+}
+
+// NewRequestLogger returns a RequestLogger which writes events to l.
+// Successful requests are logged at debug level, failed requests at warn
+// level and start events at trace level.
+func NewRequestLogger(l log.Logger) RequestLogger {
+	return &requestLogger{l}
+}
+
+type requestLogger struct {
+	log log.Logger
+}
+
+func (l *requestLogger) RequestStarted(_ context.Context, e RequestEvent) {
+	if l.log.Level() <= log.LevelTrace {
+		l.log.Tracef("rpc: start %s %s", e.Method, e.Path)
+	}
+}
+
+func (l *requestLogger) RequestFinished(_ context.Context, e RequestEvent) {
+	switch {
+	case e.Err != nil:
+		l.log.Warnf("rpc: %s %s failed after %s: %v", e.Method, e.Path, e.Duration, e.Err)
+	case e.ErrorId != "":
+		l.log.Warnf("rpc: %s %s status %d in %s: %s %s", e.Method, e.Path, e.Status, e.Duration, e.ErrorKind, e.ErrorId)
+	case e.Failed():
+		l.log.Warnf("rpc: %s %s status %d in %s", e.Method, e.Path, e.Status, e.Duration)
+	default:
+		l.log.Debugf("rpc: %s %s status %d in %s", e.Method, e.Path, e.Status, e.Duration)
+	}
+}
+
+// logRequest wraps next to report request events to the request logger.
+func (c *Client) logRequest(next RoundTripFunc) RoundTripFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		ctx := req.Context()
+		ev := RequestEvent{
+			Method: req.Method,
+			Path:   req.URL.Path,
+		}
+		if c.BaseURL != nil {
+			ev.Path = strings.TrimPrefix(ev.Path, strings.TrimSuffix(c.BaseURL.Path, "/"))
+		}
+		c.RequestLog.RequestStarted(ctx, ev)
+		start := time.Now()
+		resp, err := next(req)
+		ev.Duration = time.Since(start)
+		ev.Err = err
+		if resp != nil {
+			ev.Status = resp.StatusCode
+			if resp.StatusCode >= 400 {
+				ev.ErrorKind, ev.ErrorId = peekNodeError(resp)
+			}
+		}
+		c.RequestLog.RequestFinished(ctx, ev)
+		return resp, err
+	}
+}
+
+// peekNodeError decodes the last node error from an error response and
+// restores the response body for later processing.
+func peekNodeError(resp *http.Response) (kind, id string) {
+	if !strings.Contains(resp.Header.Get("Content-Type"), "application/json") {
+		return
+	}
+	buf, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(buf))
+	if err != nil {
+		return
+	}
+	var errs Errors
+	if json.Unmarshal(buf, &errs) != nil || len(errs) == 0 {
+		return
+	}
+	last := errs[len(errs)-1]
+	return last.ErrorKind(), last.ErrorID()
+}
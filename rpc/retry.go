@@ -0,0 +1,172 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy controls automatic retries of RPC requests on transient errors.
+// Backoff starts at MinBackoff and doubles with each attempt up to MaxBackoff.
+// A random jitter of up to Jitter times the backoff is added or subtracted
+// to spread retries from concurrent clients. A Retry-After header sent by
+// the server replaces shorter backoff intervals.
+type RetryPolicy struct {
+	MaxAttempts int           // total number of attempts, values <= 1 disable retries
+	MinBackoff  time.Duration // backoff before the first retry
+	MaxBackoff  time.Duration // upper bound for backoff and Retry-After
+	Jitter      float64       // random backoff fraction in [0, 1]
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	MinBackoff:  250 * time.Millisecond,
+	MaxBackoff:  10 * time.Second,
+	Jitter:      0.2,
+}
+
+func NewRetryPolicy() *RetryPolicy {
+	p := DefaultRetryPolicy
+	return &p
+}
+
+// Backoff returns the wait time before retry n (starting at 1).
+func (p RetryPolicy) Backoff(n int) time.Duration {
+	d := p.MinBackoff
+	for i := 1; i < n && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		j := p.Jitter
+		if j > 1 {
+			j = 1
+		}
+		d += time.Duration((rand.Float64()*2 - 1) * j * float64(d))
+	}
+	return d
+}
+
+// IsTransient returns true for errors which may succeed when retried. These
+// are network errors, unexpected connection close, HTTP 429 and HTTP 5xx
+// responses which do not contain Tezos RPC errors.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	switch e := err.(type) {
+	case *rpcError:
+		return false
+	case *plainError:
+		return isTransientStatus(e.statusCode)
+	case *httpError:
+		return isTransientStatus(e.statusCode)
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var nerr net.Error
+	return errors.As(err, &nerr)
+}
+
+func isTransientStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
+
+// retryAfter returns the server requested wait time for err, if any.
+func retryAfter(err error) time.Duration {
+	switch e := err.(type) {
+	case *plainError:
+		return e.retryAfter
+	case *httpError:
+		return e.retryAfter
+	default:
+		return 0
+	}
+}
+
+// parseRetryAfter parses a Retry-After header value in seconds or
+// HTTP date format.
+func parseRetryAfter(s string) time.Duration {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if n <= 0 {
+			return 0
+		}
+		return time.Duration(n) * time.Second
+	}
+	if t, err := http.ParseTime(s); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+type idempotentKey struct{}
+
+// withIdempotent marks requests sent with ctx as safe to repeat.
+func withIdempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentKey{}, true)
+}
+
+// isIdempotent returns true when req may be sent more than once without
+// changing the result. This holds for GET, HEAD, OPTIONS and PUT requests
+// and for POST requests marked by withIdempotent.
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut:
+		return true
+	}
+	ok, _ := req.Context().Value(idempotentKey{}).(bool)
+	return ok
+}
+
+// withRetry calls fn until it succeeds, fails with a permanent error, the
+// retry policy is exhausted or ctx is done.
+func (c *Client) withRetry(ctx context.Context, fn func() error) error {
+	p := c.Retry
+	if p == nil || p.MaxAttempts <= 1 {
+		return fn()
+	}
+	for n := 1; ; n++ {
+		err := fn()
+		if err == nil || n >= p.MaxAttempts || !IsTransient(err) {
+			return err
+		}
+		wait := p.Backoff(n)
+		if ra := retryAfter(err); ra > wait {
+			wait = ra
+			if p.MaxBackoff > 0 && wait > p.MaxBackoff {
+				wait = p.MaxBackoff
+			}
+		}
+		c.logDebug(func() {
+			c.Log.Debugf("rpc: attempt %d/%d failed, retrying in %s: %v", n, p.MaxAttempts, wait, err)
+		})
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,232 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mavryk-network/mvgo/rpc"
+)
+
+// failingServer fails the first n requests with status code and counts all
+// requests it receives.
+func failingServer(t *testing.T, n int32, code int, header http.Header) (*httptest.Server, *int32) {
+	t.Helper()
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= n {
+			for k, v := range header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(code)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`"ok"`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func newRetryClient(t *testing.T, url string, p rpc.RetryPolicy) *rpc.Client {
+	t.Helper()
+	c, err := rpc.NewClient(url, nil, rpc.WithRetry(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Close)
+	return c
+}
+
+var testRetryPolicy = rpc.RetryPolicy{
+	MaxAttempts: 3,
+	MinBackoff:  time.Millisecond,
+	MaxBackoff:  5 * time.Millisecond,
+}
+
+func TestRetryBackoff(t *testing.T) {
+	p := rpc.RetryPolicy{
+		MinBackoff: 100 * time.Millisecond,
+		MaxBackoff: time.Second,
+	}
+	for n, want := range map[int]time.Duration{
+		1: 100 * time.Millisecond,
+		2: 200 * time.Millisecond,
+		3: 400 * time.Millisecond,
+		4: 800 * time.Millisecond,
+		5: time.Second,
+		9: time.Second,
+	} {
+		if have := p.Backoff(n); have != want {
+			t.Errorf("retry %d: want backoff %s, have %s", n, want, have)
+		}
+	}
+
+	// jitter stays within bounds
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if d := p.Backoff(1); d < 50*time.Millisecond || d > 150*time.Millisecond {
+			t.Fatalf("backoff %s out of jitter range", d)
+		}
+	}
+}
+
+func TestRetryTransient(t *testing.T) {
+	ctx := context.Background()
+	for _, code := range []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable} {
+		srv, calls := failingServer(t, 2, code, nil)
+		c := newRetryClient(t, srv.URL, testRetryPolicy)
+		var res string
+		if err := c.Get(ctx, "test", &res); err != nil {
+			t.Fatalf("%d: %v", code, err)
+		}
+		if n := atomic.LoadInt32(calls); n != 3 {
+			t.Errorf("%d: want 3 attempts, have %d", code, n)
+		}
+	}
+
+	// attempts are limited by the policy
+	srv, calls := failingServer(t, 5, http.StatusServiceUnavailable, nil)
+	c := newRetryClient(t, srv.URL, testRetryPolicy)
+	if err := c.Get(ctx, "test", nil); err == nil {
+		t.Error("want error after last attempt")
+	}
+	if n := atomic.LoadInt32(calls); n != 3 {
+		t.Errorf("want 3 attempts, have %d", n)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	ctx := context.Background()
+	p := testRetryPolicy
+	p.MaxBackoff = 200 * time.Millisecond
+	srv, calls := failingServer(t, 1, http.StatusTooManyRequests, http.Header{"Retry-After": []string{"1"}})
+	c := newRetryClient(t, srv.URL, p)
+
+	// the server's wait time replaces the shorter backoff but is capped
+	// at the maximum backoff
+	start := time.Now()
+	if err := c.Get(ctx, "test", nil); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < p.MaxBackoff || d >= time.Second {
+		t.Errorf("want wait of %s, have %s", p.MaxBackoff, d)
+	}
+	if n := atomic.LoadInt32(calls); n != 2 {
+		t.Errorf("want 2 attempts, have %d", n)
+	}
+
+	// canceled contexts end the wait
+	srv, _ = failingServer(t, 5, http.StatusTooManyRequests, http.Header{"Retry-After": []string{"10"}})
+	c = newRetryClient(t, srv.URL, rpc.RetryPolicy{MaxAttempts: 3, MaxBackoff: 10 * time.Second})
+	ctx2, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	if err := c.Get(ctx2, "test", nil); err == nil {
+		t.Error("want error on canceled wait")
+	}
+	if d := time.Since(start); d >= time.Second {
+		t.Errorf("wait was not canceled after %s", d)
+	}
+}
+
+func TestRetryPermanent(t *testing.T) {
+	ctx := context.Background()
+	for _, code := range []int{http.StatusBadRequest, http.StatusNotFound, http.StatusForbidden} {
+		srv, calls := failingServer(t, 1, code, nil)
+		c := newRetryClient(t, srv.URL, testRetryPolicy)
+		if err := c.Get(ctx, "test", nil); err == nil {
+			t.Errorf("%d: want error", code)
+		}
+		if n := atomic.LoadInt32(calls); n != 1 {
+			t.Errorf("%d: want 1 attempt, have %d", code, n)
+		}
+	}
+
+	// node errors are permanent even with a 5xx status
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`[{"kind":"permanent","id":"proto.alpha.contract.counter_in_the_past"}]`))
+	}))
+	defer srv.Close()
+	c := newRetryClient(t, srv.URL, testRetryPolicy)
+	if err := c.Get(ctx, "test", nil); err == nil {
+		t.Error("want node error")
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("want 1 attempt on node error, have %d", n)
+	}
+}
+
+func TestRetryInjection(t *testing.T) {
+	ctx := context.Background()
+
+	// injections are sent once
+	srv, calls := failingServer(t, 1, http.StatusServiceUnavailable, nil)
+	c := newRetryClient(t, srv.URL, testRetryPolicy)
+	if _, err := c.BroadcastOperation(ctx, []byte{0}); err == nil {
+		t.Error("want injection error")
+	}
+	if n := atomic.LoadInt32(calls); n != 1 {
+		t.Errorf("want 1 injection attempt, have %d", n)
+	}
+
+	// simulations are retried
+	srv, calls = failingServer(t, 1, http.StatusServiceUnavailable, nil)
+	c = newRetryClient(t, srv.URL, testRetryPolicy)
+	var res string
+	if err := c.RunOperation(ctx, rpc.Head, struct{}{}, &res); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(calls); n != 2 {
+		t.Errorf("want 2 simulation attempts, have %d", n)
+	}
+}
+
+func TestPoolFailover(t *testing.T) {
+	ctx := context.Background()
+	bad, badCalls := failingServer(t, 100, http.StatusServiceUnavailable, nil)
+	good, goodCalls := failingServer(t, 0, 0, nil)
+	c, _, err := rpc.NewPoolClient([]string{bad.URL, good.URL}, rpc.PoolFailover, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// reads and simulations fail over
+	if err := c.Get(ctx, "test", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RunOperation(ctx, rpc.Head, struct{}{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(goodCalls); n != 2 {
+		t.Errorf("want 2 requests on healthy node, have %d", n)
+	}
+
+	// injections stay on the first node of a fresh pool
+	atomic.StoreInt32(badCalls, 0)
+	c2, _, err := rpc.NewPoolClient([]string{bad.URL, good.URL}, rpc.PoolFailover, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c2.Close()
+	if _, err := c2.BroadcastOperation(ctx, []byte{0}); err == nil {
+		t.Error("want injection error")
+	}
+	if n := atomic.LoadInt32(goodCalls); n != 2 {
+		t.Errorf("injection failed over to second node")
+	}
+	if n := atomic.LoadInt32(badCalls); n != 1 {
+		t.Errorf("want 1 injection attempt, have %d", n)
+	}
+}
@@ -1,4 +1,4 @@
-// Copyright (c) 2020-2021 Blockwatch Data Inc.
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
 // Author: alex@blockwatch.cc
 
 package rpc
@@ -8,6 +8,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/url"
 	"strconv"
 	"time"
 
@@ -21,6 +23,7 @@ type BakingRight struct {
 	Priority      int            `json:"priority"` // until v011
 	Round         int            `json:"round"`    // v012+
 	EstimatedTime time.Time      `json:"estimated_time"`
+	ConsensusKey  mavryk.Address `json:"consensus_key"` // v015+
 }
 
 func (r BakingRight) Address() mavryk.Address {
@@ -29,12 +32,14 @@ func (r BakingRight) Address() mavryk.Address {
 
 // EndorsingRight holds information about the right to endorse a specific Tezos block.
 type EndorsingRight struct {
-	Delegate       mavryk.Address `json:"delegate"`
-	Level          int64          `json:"level"`
-	EstimatedTime  time.Time      `json:"estimated_time"`
-	Slots          []int          `json:"slots,omitempty"` // until v011
-	FirstSlot      int            `json:"first_slot"`      // v012+
-	EndorsingPower int            `json:"endorsing_power"` // v012+
+	Delegate         mavryk.Address `json:"delegate"`
+	Level            int64          `json:"level"`
+	EstimatedTime    time.Time      `json:"estimated_time"`
+	Slots            []int          `json:"slots,omitempty"`   // until v011
+	FirstSlot        int            `json:"first_slot"`        // v012+
+	EndorsingPower   int            `json:"endorsing_power"`   // v012+
+	AttestationPower int            `json:"attestation_power"` // v018+
+	ConsensusKey     mavryk.Address `json:"consensus_key"`     // v015+
 }
 
 func (r EndorsingRight) Address() mavryk.Address {
@@ -42,7 +47,186 @@ func (r EndorsingRight) Address() mavryk.Address {
 }
 
 func (r EndorsingRight) Power() int {
-	return r.EndorsingPower + len(r.Slots)
+	return r.EndorsingPower + r.AttestationPower + len(r.Slots)
+}
+
+// RightsFilter selects baking and attestation rights. Empty fields use node
+// defaults which return rights for the next level only.
+type RightsFilter struct {
+	Levels    []int64          // only return rights at these levels
+	Cycles    []int64          // only return rights in these cycles
+	Delegates []mavryk.Address // only return rights of these delegates
+	MaxRound  int              // max baking round, zero uses the node default
+	All       bool             // return all rounds up to MaxRound
+}
+
+// Query returns the URL query parameters for filter f.
+func (f RightsFilter) Query() url.Values {
+	q := url.Values{}
+	for _, v := range f.Levels {
+		q.Add("level", strconv.FormatInt(v, 10))
+	}
+	for _, v := range f.Cycles {
+		q.Add("cycle", strconv.FormatInt(v, 10))
+	}
+	for _, v := range f.Delegates {
+		q.Add("delegate", v.String())
+	}
+	if f.MaxRound > 0 {
+		q.Set("max_round", strconv.Itoa(f.MaxRound))
+	}
+	if f.All {
+		q.Set("all", "true")
+	}
+	return q
+}
+
+// GetBakingRights returns baking rights at block id which match filter f.
+func (c *Client) GetBakingRights(ctx context.Context, id BlockID, f RightsFilter) ([]BakingRight, error) {
+	u := url.URL{
+		Path:     fmt.Sprintf("chains/main/blocks/%s/helpers/baking_rights", id),
+		RawQuery: f.Query().Encode(),
+	}
+	rights := make([]BakingRight, 0)
+	if err := c.Get(ctx, u.String(), &rights); err != nil {
+		return nil, err
+	}
+	return rights, nil
+}
+
+// GetAttestationRights returns attestation rights at block id which match
+// filter f. Nodes without the attestation_rights endpoint are queried for
+// endorsing rights instead. MaxRound and All are ignored.
+func (c *Client) GetAttestationRights(ctx context.Context, id BlockID, f RightsFilter) ([]EndorsingRight, error) {
+	f.MaxRound, f.All = 0, false
+	type levelRights struct {
+		Level         int64            `json:"level"`
+		Delegates     []EndorsingRight `json:"delegates"`
+		EstimatedTime time.Time        `json:"estimated_time"`
+	}
+	list := make([]levelRights, 0)
+	u := url.URL{
+		Path:     fmt.Sprintf("chains/main/blocks/%s/helpers/attestation_rights", id),
+		RawQuery: f.Query().Encode(),
+	}
+	err := c.Get(ctx, u.String(), &list)
+	if ErrorStatus(err) == http.StatusNotFound {
+		u.Path = fmt.Sprintf("chains/main/blocks/%s/helpers/endorsing_rights", id)
+		err = c.Get(ctx, u.String(), &list)
+	}
+	if err != nil {
+		return nil, err
+	}
+	rights := make([]EndorsingRight, 0)
+	for _, v := range list {
+		for _, r := range v.Delegates {
+			r.Level = v.Level
+			r.EstimatedTime = v.EstimatedTime
+			rights = append(rights, r)
+		}
+	}
+	return rights, nil
+}
+
+// DefaultRightsBatchSize is the number of levels a rights iterator requests
+// at once.
+const DefaultRightsBatchSize = 128
+
+// LevelRights contains all baking and attestation rights at a single level.
+type LevelRights struct {
+	Level       int64
+	Baking      []BakingRight    // ordered by round
+	Attestation []EndorsingRight // ordered by first slot
+}
+
+// RightsIterator pages through baking and attestation rights of a cycle in
+// level order. Each page covers BatchSize levels.
+type RightsIterator struct {
+	BatchSize int // number of levels per request
+	MaxRound  int // max baking round, zero uses the node default
+
+	c      *Client
+	id     BlockID
+	next   int64
+	last   int64
+	filter []mavryk.Address
+	page   []LevelRights
+}
+
+// NewRightsIterator returns an iterator over all rights in cycle as seen
+// from block id. Block and cycle must be no further than preserved cycles
+// apart. Optional delegates restrict the result.
+func (c *Client) NewRightsIterator(ctx context.Context, id BlockID, cycle int64, delegates ...mavryk.Address) (*RightsIterator, error) {
+	p, err := c.GetParams(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	p = p.AtCycle(cycle)
+	return &RightsIterator{
+		BatchSize: DefaultRightsBatchSize,
+		c:         c,
+		id:        id,
+		next:      p.CycleStartHeight(cycle),
+		last:      p.CycleEndHeight(cycle),
+		filter:    delegates,
+	}, nil
+}
+
+// Next returns rights at the next level of the cycle and false after the
+// last level.
+func (it *RightsIterator) Next(ctx context.Context) (*LevelRights, bool, error) {
+	if len(it.page) == 0 {
+		if it.next > it.last {
+			return nil, false, nil
+		}
+		if err := it.fetch(ctx); err != nil {
+			return nil, false, err
+		}
+	}
+	r := it.page[0]
+	it.page = it.page[1:]
+	return &r, true, nil
+}
+
+func (it *RightsIterator) fetch(ctx context.Context) error {
+	n := int64(it.BatchSize)
+	if n <= 0 {
+		n = DefaultRightsBatchSize
+	}
+	if it.next+n-1 > it.last {
+		n = it.last - it.next + 1
+	}
+	f := RightsFilter{
+		Levels:    make([]int64, n),
+		Delegates: it.filter,
+		MaxRound:  it.MaxRound,
+	}
+	page := make([]LevelRights, n)
+	for i := range page {
+		page[i].Level = it.next + int64(i)
+		f.Levels[i] = page[i].Level
+	}
+	bake, err := it.c.GetBakingRights(ctx, it.id, f)
+	if err != nil {
+		return err
+	}
+	attest, err := it.c.GetAttestationRights(ctx, it.id, f)
+	if err != nil {
+		return err
+	}
+	for _, r := range bake {
+		if i := r.Level - it.next; i >= 0 && i < n {
+			page[i].Baking = append(page[i].Baking, r)
+		}
+	}
+	for _, r := range attest {
+		if i := r.Level - it.next; i >= 0 && i < n {
+			page[i].Attestation = append(page[i].Attestation, r)
+		}
+	}
+	it.next += n
+	it.page = page
+	return nil
 }
 
 type RollSnapshotInfo struct {
@@ -55,6 +239,43 @@ type RollSnapshotInfo struct {
 type StakeInfo struct {
 	ActiveStake int64          `json:"active_stake,string"`
 	Baker       mavryk.Address `json:"baker"`
+
+	// v019+
+	Frozen    int64 `json:"-"`
+	Delegated int64 `json:"-"`
+}
+
+// UnmarshalJSON decodes active stake either as single number or, since
+// v019, as frozen and delegated parts.
+func (s *StakeInfo) UnmarshalJSON(data []byte) error {
+	type stake struct {
+		Frozen    int64 `json:"frozen,string"`
+		Delegated int64 `json:"delegated,string"`
+	}
+	var v struct {
+		ActiveStake json.RawMessage `json:"active_stake"`
+		Baker       mavryk.Address  `json:"baker"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	s.Baker = v.Baker
+	s.Frozen, s.Delegated = 0, 0
+	if len(v.ActiveStake) > 0 && v.ActiveStake[0] == '{' {
+		var st stake
+		if err := json.Unmarshal(v.ActiveStake, &st); err != nil {
+			return err
+		}
+		s.Frozen, s.Delegated = st.Frozen, st.Delegated
+		s.ActiveStake = st.Frozen + st.Delegated
+	} else if len(v.ActiveStake) > 0 {
+		var n Int64orString
+		if err := json.Unmarshal(v.ActiveStake, &n); err != nil {
+			return err
+		}
+		s.ActiveStake = n.Int64()
+	}
+	return nil
 }
 
 // v012+
@@ -70,6 +291,7 @@ type SnapshotIndex struct {
 	Cycle int64 // the requested cycle that contains rights from the snapshot
 	Base  int64 // the cycle where the snapshot happened
 	Index int   // the index inside base where snapshot happened
+	Level int64 // the block level where snapshot happened, zero when unknown
 }
 
 type SnapshotRoll struct {
@@ -306,6 +528,9 @@ func (c *Client) GetSnapshotIndexCycle(ctx context.Context, id BlockID, cycle in
 		idx.Base = p.SnapshotBaseCycle(cycle)
 		idx.Index = info.RollSnapshot
 	}
+	if idx.Index >= 0 {
+		idx.Level = p.SnapshotBlock(cycle, idx.Index)
+	}
 	return idx, nil
 }
 
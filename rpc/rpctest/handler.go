@@ -0,0 +1,354 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpctest
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+	"github.com/mavryk-network/mvgo/rpc"
+)
+
+// ServeHTTP serves node RPC requests.
+func (n *Node) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n.mu.Lock()
+	h, ok := n.handlers[r.Method+" "+r.URL.Path]
+	n.mu.Unlock()
+	if ok {
+		h(w, r)
+		return
+	}
+
+	path := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case match(path, "version"):
+		n.serveVersion(w)
+	case match(path, "injection", "operation") && r.Method == http.MethodPost:
+		n.serveInjection(w, r)
+	case match(path, "monitor", "heads", "*"):
+		n.serveHeads(w, r)
+	case match(path, "chains", "*", "chain_id"):
+		writeJSON(w, n.params.ChainId)
+	case match(path, "chains", "*", "is_bootstrapped"):
+		writeJSON(w, rpc.Status{Bootstrapped: true, SyncState: "synced"})
+	case match(path, "protocols"):
+		writeJSON(w, []mavryk.ProtocolHash{n.params.Protocol})
+	case match(path, "protocols", "*"):
+		if path[1] != n.params.Protocol.String() {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, rpc.ProtocolData{
+			Components: []rpc.ProtocolComponent{},
+		})
+	case match(path, "config", "history_mode"):
+		writeJSON(w, map[string]string{"history_mode": rpc.HistoryModeArchive})
+	case match(path, "chains", "*", "levels", "*"):
+		n.serveLevel(w, r, path[3])
+	case match(path, "chains", "*", "mempool", "pending_operations"):
+		n.serveMempool(w)
+	case len(path) >= 4 && match(path[:3], "chains", "*", "blocks"):
+		n.serveBlock(w, r, path[3], path[4:])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// match compares path segments against pattern where * matches any segment.
+func match(path []string, pattern ...string) bool {
+	if len(path) != len(pattern) {
+		return false
+	}
+	for i, v := range pattern {
+		if v != "*" && v != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(buf)
+}
+
+// writeError writes a node error response with error id.
+func writeError(w http.ResponseWriter, status int, id string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode([]rpc.GenericError{{Kind: "permanent", ID: id}})
+}
+
+func (n *Node) serveVersion(w http.ResponseWriter) {
+	writeJSON(w, rpc.VersionInfo{
+		NodeVersion: n.Version,
+		NetworkVersion: rpc.NetworkVersion{
+			ChainName: n.params.Network,
+		},
+	})
+}
+
+func (n *Node) serveInjection(w http.ResponseWriter, r *http.Request) {
+	n.mu.Lock()
+	reject := n.reject
+	n.mu.Unlock()
+	if reject != "" {
+		writeError(w, http.StatusInternalServerError, reject)
+		return
+	}
+	var s string
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	buf, err := hex.DecodeString(s)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	hash, err := n.inject(buf)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "node.prevalidation.parse_error")
+		return
+	}
+	writeJSON(w, hash)
+}
+
+// serveLevel serves history boundaries of an archive node. All blocks are
+// final.
+func (n *Node) serveLevel(w http.ResponseWriter, r *http.Request, name string) {
+	n.mu.Lock()
+	var b *block
+	switch name {
+	case "checkpoint":
+		b = n.head()
+	case "savepoint", "caboose":
+		b = n.blocks[0]
+	}
+	n.mu.Unlock()
+	if b == nil {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, rpc.ChainLevel{Hash: b.hash, Level: b.header.Level})
+}
+
+func (n *Node) serveMempool(w http.ResponseWriter) {
+	n.mu.Lock()
+	list := make([]json.RawMessage, len(n.mempool))
+	for i, v := range n.mempool {
+		list[i] = v.op
+	}
+	n.mu.Unlock()
+	writeJSON(w, map[string]any{
+		"applied":        list,
+		"refused":        []any{},
+		"outdated":       []any{},
+		"branch_refused": []any{},
+		"branch_delayed": []any{},
+		"unprocessed":    []any{},
+	})
+}
+
+// serveHeads streams new head blocks until the client disconnects or the
+// node is closed. Unlike a real node the current head is not sent.
+func (n *Node) serveHeads(w http.ResponseWriter, r *http.Request) {
+	id, ch := n.subscribe()
+	defer n.unsubscribe(id)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	flush(w)
+	enc := json.NewEncoder(w)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-n.ctx.Done():
+			return
+		case b := <-ch:
+			if err := enc.Encode(b.header.LogEntry()); err != nil {
+				return
+			}
+			flush(w)
+		}
+	}
+}
+
+func flush(w http.ResponseWriter) {
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (n *Node) serveBlock(w http.ResponseWriter, r *http.Request, id string, path []string) {
+	n.mu.Lock()
+	b, ok := n.resolve(id)
+	n.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	switch {
+	case len(path) == 0:
+		if b.raw != nil {
+			writeJSON(w, b.raw)
+			return
+		}
+		writeJSON(w, map[string]any{
+			"protocol":   n.params.Protocol,
+			"chain_id":   n.params.ChainId,
+			"hash":       b.hash,
+			"header":     b.hdr,
+			"metadata":   n.metadata(b),
+			"operations": b.ops,
+		})
+	case match(path, "header"):
+		writeJSON(w, b.hdr)
+	case match(path, "hash"):
+		writeJSON(w, b.hash)
+	case match(path, "metadata"):
+		writeJSON(w, n.metadata(b))
+	case match(path, "protocols"):
+		writeJSON(w, map[string]any{
+			"protocol":      n.params.Protocol,
+			"next_protocol": n.params.Protocol,
+		})
+	case match(path, "operation_hashes"):
+		writeJSON(w, b.hashes)
+	case match(path, "operations"):
+		writeJSON(w, b.ops)
+	case match(path, "operations", "*"):
+		l, err := strconv.Atoi(path[1])
+		if err != nil || l < 0 || l >= len(b.ops) {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, b.ops[l])
+	case match(path, "operations", "*", "*"):
+		l, err1 := strconv.Atoi(path[1])
+		p, err2 := strconv.Atoi(path[2])
+		if err1 != nil || err2 != nil || l < 0 || l >= len(b.ops) || p < 0 || p >= len(b.ops[l]) {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, b.ops[l][p])
+	case len(path) >= 3 && match(path[:2], "context", "contracts"):
+		n.serveContract(w, r, path[2], path[3:])
+	case match(path, "context", "raw", "json", "contracts", "index", "*"):
+		n.serveContract(w, r, path[5], []string{"raw"})
+	case match(path, "helpers", "scripts", "simulate_operation"),
+		match(path, "helpers", "scripts", "run_operation"):
+		n.serveSimulation(w, r)
+	case match(path, "helpers", "preapply", "operations"):
+		n.servePreapply(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// metadata returns the metadata of block b.
+func (n *Node) metadata(b *block) any {
+	if b.raw != nil {
+		var v struct {
+			Metadata json.RawMessage `json:"metadata"`
+		}
+		json.Unmarshal(b.raw, &v)
+		return v.Metadata
+	}
+	info := rpc.LevelInfo{
+		Level: b.header.Level,
+	}
+	if p := n.params.BlocksPerCycle; p > 0 {
+		info.Cycle = b.header.Level / p
+		info.CyclePosition = b.header.Level % p
+	}
+	return map[string]any{
+		"protocol":           n.params.Protocol,
+		"next_protocol":      n.params.Protocol,
+		"max_operations_ttl": n.params.MaxOperationsTTL,
+		"level_info":         info,
+	}
+}
+
+// serveContract serves account state. State is not versioned, so all
+// blocks return the current state.
+func (n *Node) serveContract(w http.ResponseWriter, r *http.Request, s string, path []string) {
+	addr, err := mavryk.ParseAddress(s)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	acc := n.Account(addr)
+	var key any
+	if acc.Key.IsValid() {
+		key = acc.Key
+	}
+	switch {
+	case len(path) == 0:
+		writeJSON(w, map[string]any{
+			"balance": strconv.FormatInt(acc.Balance, 10),
+			"counter": strconv.FormatInt(acc.Counter, 10),
+		})
+	case match(path, "raw"):
+		info := map[string]any{
+			"balance": strconv.FormatInt(acc.Balance, 10),
+			"counter": strconv.FormatInt(acc.Counter, 10),
+		}
+		if key != nil {
+			info["manager"] = acc.Key.String()
+		}
+		writeJSON(w, info)
+	case match(path, "balance"):
+		writeJSON(w, strconv.FormatInt(acc.Balance, 10))
+	case match(path, "counter"):
+		writeJSON(w, strconv.FormatInt(acc.Counter, 10))
+	case match(path, "manager_key"):
+		writeJSON(w, key)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveSimulation returns the simulated operation with successful results.
+func (n *Node) serveSimulation(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Operation operation `json:"operation"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusInternalServerError, "node.decode_error")
+		return
+	}
+	op := &req.Operation
+	op.Protocol = n.params.Protocol
+	op.ChainId = n.params.ChainId
+	n.apply(op, mavryk.OpHash{})
+	writeJSON(w, op)
+}
+
+// servePreapply returns preapplied operations with successful results.
+func (n *Node) servePreapply(w http.ResponseWriter, r *http.Request) {
+	var ops []*operation
+	buf, err := io.ReadAll(r.Body)
+	if err == nil {
+		err = json.Unmarshal(buf, &ops)
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "node.decode_error")
+		return
+	}
+	for _, op := range ops {
+		n.apply(op, mavryk.OpHash{})
+	}
+	writeJSON(w, ops)
+}
@@ -0,0 +1,544 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+// Package rpctest provides an in-memory node simulator for unit-testing
+// applications built on package rpc without a live node.
+//
+// A Node keeps a chain of canned or generated blocks, account state, a
+// mempool and a list of captured injections. It serves the subset of the
+// node RPC API that clients use for forging, simulation, injection and
+// confirmation tracking. Clients talk to a node through an in-memory
+// transport (see Node.Client) or via HTTP since Node is an http.Handler:
+//
+//	node := rpctest.NewNode(nil)
+//	defer node.Close()
+//	node.SetAccount(addr, rpctest.Account{Balance: 1_000_000})
+//	node.AutoBake(100 * time.Millisecond)
+//
+//	c, _ := node.Client(rpc.WithSigner(sig))
+//	rcpt, err := c.Send(ctx, op, nil)
+//
+//	srv := httptest.NewServer(node) // or serve over HTTP
+//
+// Close the node before closing an HTTP server because open monitor streams
+// block server shutdown.
+//
+// Simulated operations always succeed with a fixed gas cost. Use Handle to
+// replace individual endpoints and RejectInjections to test error paths.
+//
+// For tests against real node data use a Recorder which records responses
+// of a live node once and replays them from disk in later runs.
+package rpctest
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mavryk-network/mvgo/codec"
+	"github.com/mavryk-network/mvgo/mavryk"
+	"github.com/mavryk-network/mvgo/rpc"
+)
+
+// DefaultGasUsed is the gas reported for each simulated or applied manager
+// operation.
+const DefaultGasUsed = 1000
+
+// Account is the simulated state of an implicit account. Accounts without
+// key are unrevealed.
+type Account struct {
+	Balance int64
+	Counter int64
+	Key     mavryk.Key
+}
+
+// Injection is an operation captured by the injection endpoint.
+type Injection struct {
+	Hash  mavryk.OpHash
+	Bytes []byte
+	Op    *codec.Op
+	Time  time.Time
+}
+
+// block is a stored block with pre-encoded operations.
+type block struct {
+	hash   mavryk.BlockHash
+	header rpc.BlockHeader
+	hdr    json.RawMessage     // header as served by the header endpoint
+	raw    json.RawMessage     // full block, nil for generated blocks
+	ops    [][]json.RawMessage // operations with metadata
+	hashes [][]mavryk.OpHash
+}
+
+// pending is an injected operation waiting in the mempool.
+type pending struct {
+	hash mavryk.OpHash
+	op   json.RawMessage // mempool encoding without metadata
+	rcpt json.RawMessage // block encoding with metadata
+}
+
+// Node is an in-memory node simulator. It is safe for concurrent use.
+type Node struct {
+	// GasUsed is the gas reported for each manager operation.
+	GasUsed int64
+	// Version is the node version reported by the version RPC. The zero
+	// value reports an unknown version which supports all features.
+	Version rpc.NodeVersion
+
+	mu       sync.Mutex
+	params   *mavryk.Params
+	blocks   []*block
+	byHash   map[mavryk.BlockHash]*block
+	mempool  []pending
+	injected []Injection
+	accounts map[mavryk.Address]*Account
+	handlers map[string]http.HandlerFunc
+	reject   string
+	subs     map[int]chan *block
+	seq      int
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+// NewNode creates a node simulator for params p with a genesis block at
+// level zero. Use nil for mavryk.DefaultParams.
+func NewNode(p *mavryk.Params) *Node {
+	if p == nil {
+		p = mavryk.DefaultParams
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	n := &Node{
+		GasUsed:  DefaultGasUsed,
+		params:   p,
+		byHash:   make(map[mavryk.BlockHash]*block),
+		accounts: make(map[mavryk.Address]*Account),
+		handlers: make(map[string]http.HandlerFunc),
+		subs:     make(map[int]chan *block),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	n.bake(time.Now().UTC().Truncate(time.Second), nil)
+	return n
+}
+
+// Close stops auto baking and ends all monitor streams.
+func (n *Node) Close() {
+	n.cancel()
+}
+
+// Params returns the chain params served by the node.
+func (n *Node) Params() *mavryk.Params {
+	return n.params
+}
+
+// ChainId returns the chain id served by the node.
+func (n *Node) ChainId() mavryk.ChainIdHash {
+	return n.params.ChainId
+}
+
+// SetAccount sets the state of account addr.
+func (n *Node) SetAccount(addr mavryk.Address, acc Account) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.accounts[addr] = &acc
+}
+
+// Account returns the current state of account addr.
+func (n *Node) Account(addr mavryk.Address) Account {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if acc, ok := n.accounts[addr]; ok {
+		return *acc
+	}
+	return Account{}
+}
+
+// Handle replaces the endpoint for method and path with h. Path is the
+// request path without query, e.g. /chains/main/blocks/head/header.
+func (n *Node) Handle(method, path string, h http.HandlerFunc) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.handlers[method+" "+path] = h
+}
+
+// RejectInjections makes the injection endpoint fail with node error id
+// until called with an empty id.
+func (n *Node) RejectInjections(id string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.reject = id
+}
+
+// Injected returns all operations injected so far.
+func (n *Node) Injected() []Injection {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	list := make([]Injection, len(n.injected))
+	copy(list, n.injected)
+	return list
+}
+
+// Pending returns hashes of injected operations which are not yet included
+// in a block.
+func (n *Node) Pending() []mavryk.OpHash {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	list := make([]mavryk.OpHash, len(n.mempool))
+	for i, v := range n.mempool {
+		list[i] = v.hash
+	}
+	return list
+}
+
+// Head returns the header of the current head block.
+func (n *Node) Head() rpc.BlockHeader {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.head().header
+}
+
+// AddBlock appends a canned block in node JSON format, e.g. as returned by
+// /chains/main/blocks/{id}. The block level must be above the current head.
+// Account state is not updated from canned blocks.
+func (n *Node) AddBlock(buf []byte) error {
+	var b struct {
+		Protocol   mavryk.ProtocolHash        `json:"protocol"`
+		ChainId    mavryk.ChainIdHash         `json:"chain_id"`
+		Hash       mavryk.BlockHash           `json:"hash"`
+		Header     map[string]json.RawMessage `json:"header"`
+		Operations [][]json.RawMessage        `json:"operations"`
+	}
+	if err := json.Unmarshal(buf, &b); err != nil {
+		return fmt.Errorf("rpctest: decoding block: %w", err)
+	}
+	b.Header["hash"], _ = json.Marshal(b.Hash)
+	b.Header["protocol"], _ = json.Marshal(b.Protocol)
+	b.Header["chain_id"], _ = json.Marshal(b.ChainId)
+	hdr, err := json.Marshal(b.Header)
+	if err != nil {
+		return err
+	}
+	var header rpc.BlockHeader
+	if err := json.Unmarshal(hdr, &header); err != nil {
+		return fmt.Errorf("rpctest: decoding block header: %w", err)
+	}
+	blk := &block{
+		hash:   b.Hash,
+		header: header,
+		hdr:    hdr,
+		raw:    json.RawMessage(buf),
+		ops:    b.Operations,
+		hashes: make([][]mavryk.OpHash, len(b.Operations)),
+	}
+	for l, list := range b.Operations {
+		blk.hashes[l] = make([]mavryk.OpHash, len(list))
+		for i, op := range list {
+			var o struct {
+				Hash mavryk.OpHash `json:"hash"`
+			}
+			if err := json.Unmarshal(op, &o); err != nil {
+				return fmt.Errorf("rpctest: decoding operation %d/%d: %w", l, i, err)
+			}
+			blk.hashes[l][i] = o.Hash
+		}
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if head := n.head(); blk.header.Level <= head.header.Level {
+		return fmt.Errorf("rpctest: block level %d not above head %d", blk.header.Level, head.header.Level)
+	}
+	n.push(blk)
+	return nil
+}
+
+// Bake produces a new head block which includes all pending operations and
+// notifies head monitors.
+func (n *Node) Bake() rpc.BlockHeader {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	head := n.head()
+	ops := make([]json.RawMessage, len(n.mempool))
+	hashes := make([]mavryk.OpHash, len(n.mempool))
+	for i, v := range n.mempool {
+		ops[i], hashes[i] = v.rcpt, v.hash
+	}
+	n.mempool = n.mempool[:0]
+	blk := n.bake(head.header.Timestamp.Add(n.params.MinimalBlockDelay), &block{
+		ops:    [][]json.RawMessage{{}, {}, {}, ops},
+		hashes: [][]mavryk.OpHash{{}, {}, {}, hashes},
+	})
+	return blk.header
+}
+
+// AutoBake bakes a new block every d until the node is closed.
+func (n *Node) AutoBake(d time.Duration) {
+	go func() {
+		t := time.NewTicker(d)
+		defer t.Stop()
+		for {
+			select {
+			case <-n.ctx.Done():
+				return
+			case <-t.C:
+				n.Bake()
+			}
+		}
+	}()
+}
+
+// bake creates the next block from template b. Callers must hold the lock
+// except during construction.
+func (n *Node) bake(ts time.Time, b *block) *block {
+	if b == nil {
+		b = &block{
+			ops:    [][]json.RawMessage{{}, {}, {}, {}},
+			hashes: [][]mavryk.OpHash{{}, {}, {}, {}},
+		}
+	}
+	var level int64
+	var pred mavryk.BlockHash
+	if len(n.blocks) > 0 {
+		head := n.head()
+		level, pred = head.header.Level+1, head.hash
+	}
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(level))
+	d := mavryk.Digest(append(pred.Bytes(), buf[:]...))
+	b.hash = mavryk.NewBlockHash(d[:])
+	b.header = rpc.BlockHeader{
+		Level:          level,
+		Proto:          1,
+		Predecessor:    pred,
+		Timestamp:      ts,
+		ValidationPass: 4,
+		Hash:           b.hash,
+		Protocol:       n.params.Protocol,
+		ChainId:        n.params.ChainId,
+	}
+	// encode only fields set above, zero hashes and signatures do not
+	// round-trip
+	b.hdr, _ = json.Marshal(map[string]any{
+		"level":           level,
+		"proto":           b.header.Proto,
+		"predecessor":     pred,
+		"timestamp":       ts,
+		"validation_pass": b.header.ValidationPass,
+		"fitness":         []string{},
+		"hash":            b.hash,
+		"protocol":        b.header.Protocol,
+		"chain_id":        b.header.ChainId,
+	})
+	n.push(b)
+	return b
+}
+
+// push appends block b as new head and notifies monitors.
+func (n *Node) push(b *block) {
+	n.blocks = append(n.blocks, b)
+	n.byHash[b.hash] = b
+	for _, ch := range n.subs {
+		select {
+		case ch <- b:
+		default:
+		}
+	}
+}
+
+func (n *Node) head() *block {
+	return n.blocks[len(n.blocks)-1]
+}
+
+// subscribe registers a head monitor.
+func (n *Node) subscribe() (int, <-chan *block) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.seq++
+	ch := make(chan *block, 16)
+	n.subs[n.seq] = ch
+	return n.seq, ch
+}
+
+func (n *Node) unsubscribe(id int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.subs, id)
+}
+
+// resolve finds the block for an id in node syntax. Callers must hold the
+// lock.
+func (n *Node) resolve(s string) (*block, bool) {
+	id, err := rpc.ParseBlockID(s)
+	if err != nil {
+		return nil, false
+	}
+	var (
+		base *block
+		ofs  int64
+	)
+	if o, ok := id.(rpc.BlockOffset); ok {
+		id, ofs = o.Base, o.Offset
+	}
+	switch v := id.(type) {
+	case rpc.BlockAlias:
+		switch v {
+		case rpc.Head:
+			base = n.head()
+		case rpc.Genesis, rpc.Caboose, rpc.Savepoint:
+			base = n.blocks[0]
+		}
+	case rpc.BlockLevel:
+		base = n.level(int64(v))
+	case mavryk.BlockHash:
+		base = n.byHash[v]
+	}
+	if base == nil {
+		return nil, false
+	}
+	if ofs == 0 {
+		return base, true
+	}
+	b := n.level(base.header.Level + ofs)
+	return b, b != nil
+}
+
+func (n *Node) level(l int64) *block {
+	// canned blocks may leave gaps
+	if i := l - n.blocks[0].header.Level; i >= 0 && i < int64(len(n.blocks)) && n.blocks[i].header.Level == l {
+		return n.blocks[i]
+	}
+	for _, b := range n.blocks {
+		if b.header.Level == l {
+			return b
+		}
+	}
+	return nil
+}
+
+// inject captures an injected operation, updates account state and adds
+// the operation to the mempool.
+func (n *Node) inject(buf []byte) (mavryk.OpHash, error) {
+	op, err := codec.DecodeOp(buf)
+	if err != nil {
+		return mavryk.OpHash{}, err
+	}
+	d := mavryk.Digest(buf)
+	hash := mavryk.NewOpHash(d[:])
+	o, err := n.encode(op, hash)
+	if err != nil {
+		return hash, err
+	}
+	mem, err := json.Marshal(o)
+	if err != nil {
+		return hash, err
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.apply(o, hash)
+	rcpt, err := json.Marshal(o)
+	if err != nil {
+		return hash, err
+	}
+	n.injected = append(n.injected, Injection{
+		Hash:  hash,
+		Bytes: buf,
+		Op:    op,
+		Time:  time.Now(),
+	})
+	n.mempool = append(n.mempool, pending{hash: hash, op: mem, rcpt: rcpt})
+	return hash, nil
+}
+
+// operation is the generic JSON encoding of an operation group. Contents
+// are kept as raw JSON because clients expect kind as first field.
+type operation struct {
+	Protocol  mavryk.ProtocolHash `json:"protocol"`
+	ChainId   mavryk.ChainIdHash  `json:"chain_id"`
+	Hash      mavryk.OpHash       `json:"hash"`
+	Branch    mavryk.BlockHash    `json:"branch"`
+	Contents  []json.RawMessage   `json:"contents"`
+	Signature string              `json:"signature,omitempty"`
+}
+
+// content holds the fields of an operation used to update account state.
+type content struct {
+	Kind      string `json:"kind"`
+	Source    string `json:"source"`
+	Counter   string `json:"counter"`
+	PublicKey string `json:"public_key"`
+}
+
+// encode converts op into its generic JSON encoding.
+func (n *Node) encode(op *codec.Op, hash mavryk.OpHash) (*operation, error) {
+	buf, err := op.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	o := &operation{}
+	if err := json.Unmarshal(buf, o); err != nil {
+		return nil, err
+	}
+	o.Protocol = n.params.Protocol
+	o.ChainId = n.params.ChainId
+	o.Hash = hash
+	return o, nil
+}
+
+// apply adds successful results to all contents of o and, when hash is
+// valid, updates counters and reveals in account state. Callers must hold
+// the lock when updating state.
+func (n *Node) apply(o *operation, hash mavryk.OpHash) {
+	for i, buf := range o.Contents {
+		var c content
+		if err := json.Unmarshal(buf, &c); err != nil || len(buf) < 2 {
+			continue
+		}
+		res := map[string]any{
+			"status": "applied",
+		}
+		if c.Counter != "" {
+			res["consumed_milligas"] = strconv.FormatInt(n.GasUsed*1000, 10)
+		}
+		if c.Kind == "origination" {
+			var b [8]byte
+			binary.BigEndian.PutUint64(b[:], uint64(i))
+			d := mavryk.Digest(append(hash.Bytes(), b[:]...))
+			res["originated_contracts"] = []string{mavryk.NewAddress(mavryk.AddressTypeContract, d[:20]).String()}
+		}
+		meta, _ := json.Marshal(map[string]any{
+			"balance_updates":  []any{},
+			"operation_result": res,
+		})
+		// append metadata as last field
+		ext := make([]byte, 0, len(buf)+len(meta)+13)
+		ext = append(ext, buf[:len(buf)-1]...)
+		ext = append(ext, `,"metadata":`...)
+		ext = append(ext, meta...)
+		o.Contents[i] = append(ext, '}')
+
+		if !hash.IsValid() {
+			continue
+		}
+		src, err := mavryk.ParseAddress(c.Source)
+		if err != nil {
+			continue
+		}
+		acc, ok := n.accounts[src]
+		if !ok {
+			acc = &Account{}
+			n.accounts[src] = acc
+		}
+		if v, err := strconv.ParseInt(c.Counter, 10, 64); err == nil && v > acc.Counter {
+			acc.Counter = v
+		}
+		if c.Kind == "reveal" {
+			if k, err := mavryk.ParseKey(c.PublicKey); err == nil {
+				acc.Key = k
+			}
+		}
+	}
+}
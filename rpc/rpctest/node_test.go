@@ -0,0 +1,188 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpctest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mavryk-network/mvgo/codec"
+	"github.com/mavryk-network/mvgo/mavryk"
+	"github.com/mavryk-network/mvgo/rpc"
+	"github.com/mavryk-network/mvgo/rpc/rpctest"
+	"github.com/mavryk-network/mvgo/signer"
+)
+
+var testReceiver = mavryk.MustParseAddress("mv1C6KuPzs92LTENfXUPXq1oNxvaLjxJ9p8b")
+
+// newTestNode returns a node with a few blocks and a funded account and a
+// client connected through the in-memory transport.
+func newTestNode(t *testing.T) (*rpctest.Node, *rpc.Client, mavryk.PrivateKey) {
+	t.Helper()
+	sk, err := mavryk.GenerateKey(mavryk.KeyTypeEd25519)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := rpctest.NewNode(nil)
+	node.SetAccount(sk.Address(), rpctest.Account{Balance: 1_000_000})
+	for i := 0; i < 3; i++ {
+		node.Bake()
+	}
+	c, err := node.Client(rpc.WithSigner(signer.NewFromKey(sk)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		c.Close()
+		node.Close()
+	})
+	return node, c, sk
+}
+
+func TestBake(t *testing.T) {
+	node, c, _ := newTestNode(t)
+	ctx := context.Background()
+
+	parent := node.Head()
+	head := node.Bake()
+	if head.Level != parent.Level+1 || !head.Predecessor.Equal(parent.Hash) {
+		t.Errorf("want level %d on top of %s, have level %d on %s", parent.Level+1, parent.Hash, head.Level, head.Predecessor)
+	}
+	if want := parent.Timestamp.Add(node.Params().MinimalBlockDelay); !head.Timestamp.Equal(want) {
+		t.Errorf("want timestamp %s, have %s", want, head.Timestamp)
+	}
+
+	// baked blocks are served by hash, level and head alias
+	for _, id := range []rpc.BlockID{rpc.Head, head.Hash, rpc.BlockLevel(head.Level)} {
+		h, err := c.GetBlockHeader(ctx, id)
+		if err != nil {
+			t.Fatalf("%s: %v", id, err)
+		}
+		if !h.Hash.Equal(head.Hash) {
+			t.Errorf("%s: want %s, have %s", id, head.Hash, h.Hash)
+		}
+	}
+}
+
+func TestInject(t *testing.T) {
+	node, c, sk := newTestNode(t)
+	ctx := context.Background()
+
+	op := codec.NewOp().WithSource(sk.Address()).WithTransfer(testReceiver, 10).WithParams(c.ChainParams())
+	if err := c.Complete(ctx, op, sk.Public()); err != nil {
+		t.Fatal(err)
+	}
+	if err := op.Sign(sk); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := c.Broadcast(ctx, op)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// injections are captured and kept in the mempool until baked
+	inj := node.Injected()
+	if len(inj) != 1 || !inj[0].Hash.Equal(hash) {
+		t.Fatalf("want injection %s, have %v", hash, inj)
+	}
+	if k := inj[0].Op.Contents[0].Kind(); k != mavryk.OpTypeReveal {
+		t.Errorf("want reveal first, have %s", k)
+	}
+	if p := node.Pending(); len(p) != 1 || !p[0].Equal(hash) {
+		t.Errorf("want pending %s, have %v", hash, p)
+	}
+	if acc := node.Account(sk.Address()); !acc.Key.IsValid() || acc.Counter != 2 {
+		t.Errorf("want revealed account with counter 2, have %+v", acc)
+	}
+	node.Bake()
+	if p := node.Pending(); len(p) > 0 {
+		t.Errorf("want empty mempool after bake, have %v", p)
+	}
+	hashes, err := c.GetBlockOperationHashes(ctx, rpc.Head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hashes[3]) != 1 || !hashes[3][0].Equal(hash) {
+		t.Errorf("want %s in head block, have %v", hash, hashes[3])
+	}
+
+	// rejected injections return node errors
+	node.RejectInjections("proto.alpha.node.rejected")
+	_, err = c.Broadcast(ctx, op)
+	var e rpc.RPCError
+	if !errors.As(err, &e) || e.ErrorID() != "proto.alpha.node.rejected" {
+		t.Errorf("want rejection error, have %v", err)
+	}
+	if n := len(node.Injected()); n != 1 {
+		t.Errorf("rejected operation was captured, have %d injections", n)
+	}
+}
+
+func TestMonitor(t *testing.T) {
+	node, c, _ := newTestNode(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	mon := rpc.NewBlockHeaderMonitor()
+	defer mon.Close()
+	if err := c.MonitorBlockHeader(ctx, mon); err != nil {
+		t.Fatal(err)
+	}
+
+	// the monitor streams every new head
+	for i := 0; i < 3; i++ {
+		head := node.Bake()
+		entry, err := mon.Recv(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !entry.Hash.Equal(head.Hash) || entry.Level != head.Level {
+			t.Errorf("want head %d %s, have %d %s", head.Level, head.Hash, entry.Level, entry.Hash)
+		}
+	}
+
+	// closing the node ends the stream
+	node.Close()
+	if _, err := mon.Recv(ctx); err == nil {
+		t.Error("want error after node close")
+	}
+}
+
+func TestSend(t *testing.T) {
+	node, c, sk := newTestNode(t)
+	node.AutoBake(10 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := rpc.NewCallOptions()
+	opts.Confirmations = 0
+	rcpt, err := c.Send(ctx, codec.NewOp().WithTransfer(testReceiver, 10), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rcpt.IsSuccess() {
+		t.Fatalf("send failed: %v", rcpt.Error())
+	}
+
+	// the receipt points at the including block
+	inj := node.Injected()
+	if len(inj) != 1 || !rcpt.Op.Hash.Equal(inj[0].Hash) {
+		t.Fatalf("want receipt for injected operation, have %v", inj)
+	}
+	hashes, err := c.GetBlockOperationHashes(ctx, rpc.BlockLevel(rcpt.Height))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hashes[rcpt.List]) <= rcpt.Pos || !hashes[rcpt.List][rcpt.Pos].Equal(rcpt.Op.Hash) {
+		t.Errorf("want %s at %d/%d, have %v", rcpt.Op.Hash, rcpt.List, rcpt.Pos, hashes[rcpt.List])
+	}
+	if n := rcpt.TotalCosts().GasUsed; n != 2*rpctest.DefaultGasUsed {
+		t.Errorf("want gas used %d, have %d", 2*rpctest.DefaultGasUsed, n)
+	}
+	if acc := node.Account(sk.Address()); acc.Counter != 2 {
+		t.Errorf("want counter 2, have %d", acc.Counter)
+	}
+}
@@ -0,0 +1,347 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpctest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mode selects whether a Recorder records or replays responses.
+type Mode int
+
+const (
+	// ModeReplay serves responses from the fixture file only.
+	ModeReplay Mode = iota
+	// ModeRecord forwards requests to a real node and records responses.
+	ModeRecord
+	// ModeAuto replays when the fixture file exists and records otherwise.
+	ModeAuto
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ModeReplay:
+		return "replay"
+	case ModeRecord:
+		return "record"
+	case ModeAuto:
+		return "auto"
+	default:
+		return strconv.Itoa(int(m))
+	}
+}
+
+// ParseMode parses a mode name, e.g. from an environment variable in CI.
+// Empty strings select ModeReplay.
+func ParseMode(s string) (Mode, error) {
+	switch s {
+	case "", "replay":
+		return ModeReplay, nil
+	case "record":
+		return ModeRecord, nil
+	case "auto":
+		return ModeAuto, nil
+	default:
+		return ModeReplay, fmt.Errorf("rpctest: invalid mode %q", s)
+	}
+}
+
+// Interaction is a recorded request and response pair. Responses with
+// a content encoding are stored base64 encoded because compressed bodies
+// are binary.
+type Interaction struct {
+	Method          string    `json:"method"`
+	URL             string    `json:"url"`
+	Body            string    `json:"body,omitempty"`
+	Status          int       `json:"status"`
+	ContentType     string    `json:"content_type,omitempty"`
+	ContentEncoding string    `json:"content_encoding,omitempty"`
+	Response        string    `json:"response"`
+	Time            time.Time `json:"time"`
+}
+
+// Fixture is the on-disk format of recorded interactions.
+type Fixture struct {
+	RecordedAt   time.Time      `json:"recorded_at"`
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Recorder is an http.RoundTripper which records node responses to a
+// fixture file and replays them deterministically in later runs. Requests
+// are matched by method, URL (path and query) and body. Repeated requests
+// replay their responses in recorded order and the last response is
+// repeated once all are used. Request headers are never recorded, so API
+// keys do not leak into fixtures.
+//
+// Streamed responses (e.g. monitor endpoints) are recorded up to the point
+// where the client closes the response body. Compressed responses are
+// recorded and replayed as sent by the node together with their
+// Content-Encoding header. Their timestamps are not normalized.
+//
+//	rec, err := rpctest.NewRecorder("testdata/send.json", rpctest.ModeAuto, nil)
+//	c, _ := rpc.NewClient(url, &http.Client{Transport: rec})
+//	...
+//	err = rec.Save()
+type Recorder struct {
+	path      string
+	mode      Mode
+	next      http.RoundTripper
+	normalize bool
+	start     time.Time
+
+	mu      sync.Mutex
+	fixture Fixture
+	used    map[string]int
+}
+
+// NewRecorder creates a recorder for fixture file path. In record mode
+// requests are sent with transport next, nil selects
+// http.DefaultTransport. Replay mode fails when the fixture file is missing.
+func NewRecorder(path string, mode Mode, next http.RoundTripper) (*Recorder, error) {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	r := &Recorder{
+		path:      path,
+		mode:      mode,
+		next:      next,
+		normalize: true,
+		start:     time.Now().UTC(),
+		used:      make(map[string]int),
+	}
+	buf, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		if mode == ModeReplay {
+			return nil, fmt.Errorf("rpctest: missing fixture %s", path)
+		}
+		r.mode = ModeRecord
+	case err != nil:
+		return nil, err
+	case mode == ModeRecord:
+		// re-record from scratch
+	default:
+		if err := json.Unmarshal(buf, &r.fixture); err != nil {
+			return nil, fmt.Errorf("rpctest: fixture %s: %w", path, err)
+		}
+		r.mode = ModeReplay
+	}
+	if r.mode == ModeRecord {
+		r.fixture = Fixture{RecordedAt: r.start}
+	}
+	return r, nil
+}
+
+// WithNormalizeTime enables or disables head time normalization. When
+// enabled (default) all timestamp fields in replayed responses are shifted
+// by the time passed since recording, so blocks appear as recent as they
+// were during recording. This keeps head lag and drift checks stable.
+func (r *Recorder) WithNormalizeTime(enable bool) *Recorder {
+	r.normalize = enable
+	return r
+}
+
+// Mode returns the effective mode after checking for an existing fixture.
+func (r *Recorder) Mode() Mode {
+	return r.mode
+}
+
+// Interactions returns a copy of all recorded or loaded interactions.
+func (r *Recorder) Interactions() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := make([]Interaction, len(r.fixture.Interactions))
+	for i, v := range r.fixture.Interactions {
+		list[i] = *v
+	}
+	return list
+}
+
+// Save writes recorded interactions to the fixture file. Save does nothing
+// in replay mode. The file is replaced atomically.
+func (r *Recorder) Save() error {
+	if r.mode != ModeRecord {
+		return nil
+	}
+	r.mu.Lock()
+	buf, err := json.MarshalIndent(r.fixture, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), filepath.Base(r.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), r.path)
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if r.mode == ModeRecord {
+		return r.record(req, body)
+	}
+	return r.replay(req, body)
+}
+
+func (r *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	if body != nil {
+		out.Body = io.NopCloser(bytes.NewReader(body))
+		out.ContentLength = int64(len(body))
+	}
+	resp, err := r.next.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	it := &Interaction{
+		Method:          req.Method,
+		URL:             req.URL.RequestURI(),
+		Body:            string(body),
+		Status:          resp.StatusCode,
+		ContentType:     resp.Header.Get("Content-Type"),
+		ContentEncoding: resp.Header.Get("Content-Encoding"),
+		Time:            time.Now().UTC(),
+	}
+	resp.Body = &recordBody{
+		ReadCloser: resp.Body,
+		done: func(buf []byte) {
+			if it.ContentEncoding != "" {
+				it.Response = base64.StdEncoding.EncodeToString(buf)
+			} else {
+				it.Response = string(buf)
+			}
+			r.mu.Lock()
+			r.fixture.Interactions = append(r.fixture.Interactions, it)
+			r.mu.Unlock()
+		},
+	}
+	return resp, nil
+}
+
+func (r *Recorder) replay(req *http.Request, body []byte) (*http.Response, error) {
+	uri := req.URL.RequestURI()
+	key := req.Method + " " + uri + " " + string(body)
+	r.mu.Lock()
+	var (
+		match []*Interaction
+		it    *Interaction
+	)
+	for _, v := range r.fixture.Interactions {
+		if v.Method == req.Method && v.URL == uri && v.Body == string(body) {
+			match = append(match, v)
+		}
+	}
+	if n := len(match); n > 0 {
+		i := r.used[key]
+		if i >= n {
+			i = n - 1
+		}
+		it = match[i]
+		r.used[key] = i + 1
+	}
+	r.mu.Unlock()
+	if it == nil {
+		return nil, fmt.Errorf("rpctest: no recorded response for %s %s", req.Method, uri)
+	}
+
+	resp := it.Response
+	h := make(http.Header)
+	if it.ContentType != "" {
+		h.Set("Content-Type", it.ContentType)
+	}
+	switch {
+	case it.ContentEncoding != "":
+		buf, err := base64.StdEncoding.DecodeString(resp)
+		if err != nil {
+			return nil, fmt.Errorf("rpctest: decoding %s response for %s %s: %w", it.ContentEncoding, req.Method, uri, err)
+		}
+		resp = string(buf)
+		h.Set("Content-Encoding", it.ContentEncoding)
+	case r.normalize && !r.fixture.RecordedAt.IsZero():
+		resp = shiftTimestamps(resp, r.start.Sub(r.fixture.RecordedAt))
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", it.Status, http.StatusText(it.Status)),
+		StatusCode:    it.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        h,
+		Body:          io.NopCloser(strings.NewReader(resp)),
+		ContentLength: int64(len(resp)),
+		Request:       req,
+	}, nil
+}
+
+// recordBody captures a response body while the client reads it.
+type recordBody struct {
+	io.ReadCloser
+	buf  bytes.Buffer
+	once sync.Once
+	done func([]byte)
+}
+
+func (b *recordBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.buf.Write(p[:n])
+	if err == io.EOF {
+		b.once.Do(func() { b.done(b.buf.Bytes()) })
+	}
+	return n, err
+}
+
+func (b *recordBody) Close() error {
+	b.once.Do(func() { b.done(b.buf.Bytes()) })
+	return b.ReadCloser.Close()
+}
+
+var timestampRegexp = regexp.MustCompile(`"timestamp":"([^"]+)"`)
+
+// shiftTimestamps adds d to all timestamp fields in a JSON document.
+func shiftTimestamps(s string, d time.Duration) string {
+	if d == 0 {
+		return s
+	}
+	return timestampRegexp.ReplaceAllStringFunc(s, func(m string) string {
+		v := m[len(`"timestamp":"`) : len(m)-1]
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return m
+		}
+		return `"timestamp":"` + t.Add(d).UTC().Format(time.RFC3339) + `"`
+	})
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpctest_test
+
+import (
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mavryk-network/mvgo/rpc"
+	"github.com/mavryk-network/mvgo/rpc/rpctest"
+)
+
+// gzipWriter compresses everything written to a response.
+type gzipWriter struct {
+	http.ResponseWriter
+	zw *gzip.Writer
+}
+
+func (w *gzipWriter) Write(buf []byte) (int, error) {
+	return w.zw.Write(buf)
+}
+
+// gzipHandler serves gzip compressed responses when clients accept them.
+func gzipHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			h.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		defer zw.Close()
+		h.ServeHTTP(&gzipWriter{ResponseWriter: w, zw: zw}, r)
+	})
+}
+
+func TestRecorderCompression(t *testing.T) {
+	node := rpctest.NewNode(nil)
+	defer node.Close()
+	head := node.Bake()
+	srv := httptest.NewServer(gzipHandler(node))
+	defer srv.Close()
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "fixture.json")
+
+	// record compressed responses
+	rec, err := rpctest.NewRecorder(path, rpctest.ModeRecord, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := rpc.NewClient(srv.URL, &http.Client{Transport: rec}, rpc.WithCompression())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetBlockHeader(ctx, rpc.Head); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	list := rec.Interactions()
+	if len(list) != 1 || list[0].ContentEncoding != "gzip" {
+		t.Fatalf("want gzip encoded interaction, have %+v", list)
+	}
+	if err := rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// replay restores the encoding
+	rec, err = rpctest.NewRecorder(path, rpctest.ModeReplay, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err = rpc.NewClient("http://localhost", &http.Client{Transport: rec}, rpc.WithCompression())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	h, err := c.GetBlockHeader(ctx, rpc.Head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !h.Hash.Equal(head.Hash) {
+		t.Errorf("want head %s, have %s", head.Hash, h.Hash)
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpctest
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/mavryk-network/mvgo/rpc"
+)
+
+// BaseURL is the URL used by clients connected through the in-memory
+// transport.
+const BaseURL = "http://rpctest.local"
+
+// Client returns a client connected to node n through an in-memory
+// transport. Chain id and params are preset, so calling Init is not
+// required. Options are applied after the transport was set.
+func (n *Node) Client(opts ...rpc.ClientOption) (*rpc.Client, error) {
+	c, err := rpc.NewClient(BaseURL, &http.Client{Transport: n.Transport()}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	c.ChainId = n.params.ChainId
+	c.Params = n.params
+	return c, nil
+}
+
+// Transport returns an http.RoundTripper which serves requests from node n
+// in memory. Responses are streamed, so monitor endpoints work as with a
+// real connection.
+func (n *Node) Transport() http.RoundTripper {
+	return &transport{h: n}
+}
+
+type transport struct {
+	h http.Handler
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	sreq := req.Clone(req.Context())
+	sreq.RequestURI = req.URL.RequestURI()
+	if sreq.Body == nil {
+		sreq.Body = http.NoBody
+	}
+	pr, pw := io.Pipe()
+	w := &pipeWriter{
+		header: make(http.Header),
+		pw:     pw,
+		ready:  make(chan struct{}),
+	}
+	go func() {
+		defer func() {
+			w.WriteHeader(http.StatusOK)
+			pw.Close()
+		}()
+		t.h.ServeHTTP(w, sreq)
+	}()
+	select {
+	case <-w.ready:
+	case <-req.Context().Done():
+		pr.Close()
+		return nil, req.Context().Err()
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", w.code, http.StatusText(w.code)),
+		StatusCode:    w.code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        w.sent,
+		Body:          pr,
+		ContentLength: -1,
+		Request:       req,
+	}, nil
+}
+
+// pipeWriter is a streaming http.ResponseWriter. The response is handed to
+// the client as soon as the header is written.
+type pipeWriter struct {
+	once   sync.Once
+	header http.Header
+	sent   http.Header
+	code   int
+	pw     *io.PipeWriter
+	ready  chan struct{}
+}
+
+func (w *pipeWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *pipeWriter) WriteHeader(code int) {
+	w.once.Do(func() {
+		w.code = code
+		w.sent = w.header.Clone()
+		close(w.ready)
+	})
+}
+
+func (w *pipeWriter) Write(buf []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.pw.Write(buf)
+}
+
+func (w *pipeWriter) Flush() {
+	w.WriteHeader(http.StatusOK)
+}
@@ -1,4 +1,4 @@
-// Copyright (c) 2020-2022 Blockwatch Data Inc.
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
 // Author: alex@blockwatch.cc
 
 package rpc
@@ -8,6 +8,8 @@ import (
 	"context"
 	"encoding/hex"
 	"fmt"
+	"net/http"
+	"net/url"
 
 	"github.com/mavryk-network/mvgo/codec"
 	"github.com/mavryk-network/mvgo/mavryk"
@@ -19,10 +21,7 @@ const ExtraSafetyMargin int64 = 100 // used to adjust gas and storage estimation
 
 var (
 	// for reveal
-	DefaultRevealLimits = mavryk.Limits{
-		Fee:      1000,
-		GasLimit: 1000,
-	}
+	DefaultRevealLimits = codec.DefaultRevealLimits
 	// for transfers to mv1/2/3
 	DefaultTransferLimitsEOA = mavryk.Limits{
 		Fee:      1000,
@@ -62,6 +61,8 @@ type CallOptions struct {
 	Signer            signer.Signer  // optional signer interface to use for signing the transaction
 	Sender            mavryk.Address // optional address to sign for (use when signer manages multiple addresses)
 	Observer          *Observer      // optional custom block observer for waiting on confirmations
+	Headers           http.Header    // optional HTTP headers sent with all requests of this call
+	Account           *Account       // optional source with cached counter and reveal status, see Account.Options
 }
 
 var DefaultOptions = CallOptions{
@@ -112,6 +113,8 @@ type RunCodeRequest struct {
 	Payer      *mavryk.Address    `json:"payer,omitempty"`
 	Gas        *mavryk.N          `json:"gas,omitempty"`
 	Entrypoint string             `json:"entrypoint,omitempty"`
+	Mode       UnparsingMode      `json:"unparsing_mode,omitempty"`
+	Now        string             `json:"now,omitempty"`
 }
 
 // RunCodeResponse -
@@ -126,6 +129,12 @@ type RunCodeResponse struct {
 // on-chain state. Sets branch for TTL control, replay counters, and reveals
 // the sender's pubkey if not published yet.
 func (c *Client) Complete(ctx context.Context, o *codec.Op, key mavryk.Key) error {
+	return c.complete(ctx, o, key, nil)
+}
+
+// complete is like Complete but takes counter and reveal status from acc
+// when acc is the operation source. Counters are reserved in acc.
+func (c *Client) complete(ctx context.Context, o *codec.Op, key mavryk.Key, acc *Account) error {
 	needBranch := !o.Branch.IsValid()
 	needCounter := o.NeedCounter()
 	mayNeedReveal := len(o.Contents) > 0 && o.Contents[0].Kind() != mavryk.OpTypeReveal
@@ -135,7 +144,11 @@ func (c *Client) Complete(ctx context.Context, o *codec.Op, key mavryk.Key) erro
 	}
 
 	// add branch for TTL control
-	if needBranch {
+	if needBranch && o.Resolver != nil {
+		if err := o.ResolveBranch(ctx); err != nil {
+			return err
+		}
+	} else if needBranch {
 		ofs := o.Params.MaxOperationsTTL - o.TTL
 		hash, err := c.GetBlockHash(ctx, NewBlockOffset(Head, -ofs))
 		if err != nil {
@@ -145,14 +158,31 @@ func (c *Client) Complete(ctx context.Context, o *codec.Op, key mavryk.Key) erro
 	}
 
 	if needCounter || mayNeedReveal {
-		// fetch current state
-		state, err := c.GetContractExt(ctx, key.Address(), Head)
-		if err != nil {
-			return err
+		// use cached account state or fetch current state
+		if acc != nil && !acc.Address.Equal(key.Address()) {
+			acc = nil
+		}
+		var (
+			counter  int64
+			revealed bool
+		)
+		if acc != nil {
+			if !acc.IsSynced() {
+				if err := c.SyncAccount(ctx, acc); err != nil {
+					return err
+				}
+			}
+			revealed = acc.IsRevealed()
+		} else {
+			state, err := c.GetContractExt(ctx, key.Address(), Head)
+			if err != nil {
+				return err
+			}
+			counter, revealed = state.Counter, state.IsRevealed()
 		}
 
 		// add reveal if necessary
-		if mayNeedReveal && !state.IsRevealed() {
+		if mayNeedReveal && !revealed {
 			reveal := &codec.Reveal{
 				Manager: codec.Manager{
 					Source: key.Address(),
@@ -162,18 +192,24 @@ func (c *Client) Complete(ctx context.Context, o *codec.Op, key mavryk.Key) erro
 			reveal.WithLimits(DefaultRevealLimits)
 			o.WithContentsFront(reveal)
 			needCounter = true
+			if acc != nil {
+				acc.setRevealed()
+			}
 		}
 
 		// add counters
 		if needCounter {
-			nextCounter := state.Counter + 1
 			for _, op := range o.Contents {
 				// skip non-manager ops
 				if op.GetCounter() < 0 {
 					continue
 				}
-				op.WithCounter(nextCounter)
-				nextCounter++
+				if acc != nil {
+					op.WithCounter(acc.NextCounter())
+				} else {
+					counter++
+					op.WithCounter(counter)
+				}
 			}
 		}
 	}
@@ -188,12 +224,13 @@ func (c *Client) Simulate(ctx context.Context, o *codec.Op, opts *CallOptions) (
 		Contents:  o.Contents,
 		Signature: mavryk.ZeroSignature,
 		TTL:       o.TTL,
-		Params:    c.Params,
+		Params:    c.ChainParams(),
 	}
 
 	if opts == nil {
 		opts = &DefaultOptions
 	}
+	ctx = WithRequestHeaders(ctx, opts.Headers)
 
 	if sim.TTL == 0 && opts != nil {
 		sim.TTL = opts.TTL
@@ -230,10 +267,14 @@ func (c *Client) Simulate(ctx context.Context, o *codec.Op, opts *CallOptions) (
 	resp := &Operation{}
 
 	// select simulation method based on requested block
-	if opts.SimulationBlockID != nil {
+	switch {
+	case opts.SimulationBlockID != nil:
 		// simulate in the past
 		err = c.RunOperation(ctx, opts.SimulationBlockID, req, resp)
-	} else {
+	case !c.Supports(FeatureSimulateOperation):
+		// old nodes can only run at head
+		err = c.RunOperation(ctx, Head, req, resp)
+	default:
 		// simulate in the future
 		req.Latency = opts.SimulationOffset
 		err = c.SimulateOperation(ctx, Head, req, resp)
@@ -282,28 +323,25 @@ func (c *Client) Broadcast(ctx context.Context, o *codec.Op) (mavryk.OpHash, err
 
 // Send is a convenience wrapper for sending operations. It auto-completes gas and storage limit,
 // ensures minimum fees are set, protects against fee overpayment, signs and broadcasts the final
-// operation and waits for a defined number of confirmations.
-func (c *Client) Send(ctx context.Context, op *codec.Op, opts *CallOptions) (*Receipt, error) {
+// operation and waits for a defined number of confirmations. When opts contain an Account, its
+// cached counter and reveal status are used and reset when sending fails.
+func (c *Client) Send(ctx context.Context, op *codec.Op, opts *CallOptions) (rcpt *Receipt, err error) {
 	if opts == nil {
 		opts = &DefaultOptions
 	}
+	ctx = WithRequestHeaders(ctx, opts.Headers)
 
-	signer := c.Signer
-	if opts.Signer != nil {
-		signer = opts.Signer
-	}
-
-	// identify the sender address for signing the message
-	addr := opts.Sender
-	if !addr.IsValid() {
-		addrs, err := signer.ListAddresses(ctx)
-		if err != nil {
-			return nil, err
-		}
-		addr = addrs[0]
+	// reserved counters are invalid when the operation was not included
+	if opts.Account != nil {
+		defer func() {
+			if err != nil {
+				opts.Account.Reset()
+			}
+		}()
 	}
 
-	key, err := signer.GetKey(ctx, addr)
+	// identify signer, sender address and key for signing the message
+	signer, addr, key, err := c.resolveSigner(ctx, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -317,24 +355,92 @@ func (c *Client) Send(ctx context.Context, op *codec.Op, opts *CallOptions) (*Re
 	// ensure block observer is running
 	mon.Listen(c)
 
+	// complete, simulate and check the operation
+	if err := c.prepare(ctx, op, key, opts); err != nil {
+		return nil, err
+	}
+
+	// sign digest
+	sig, err := signer.SignOperation(ctx, addr, op)
+	if err != nil {
+		return nil, err
+	}
+	op.WithSignature(sig)
+
+	// trace what we'll broadcast
+	c.logTrace(func() {
+		buf, _ := op.MarshalJSON()
+		c.Log.Tracef("Broadcast: %s", string(buf))
+	})
+
+	// broadcast
+	hash, err := c.Broadcast(ctx, op)
+	if err != nil {
+		return nil, err
+	}
+
+	// wait for confirmations and return receipt
+	return c.waitReceipt(ctx, hash, op.TTL, mon, opts)
+}
+
+// resolveSigner returns the signer, sender address and public key
+// selected by opts or client defaults.
+func (c *Client) resolveSigner(ctx context.Context, opts *CallOptions) (signer.Signer, mavryk.Address, mavryk.Key, error) {
+	s := c.Signer
+	if opts.Signer != nil {
+		s = opts.Signer
+	}
+	if s == nil {
+		return nil, mavryk.Address{}, mavryk.InvalidKey, fmt.Errorf("rpc: missing signer")
+	}
+
+	addr := opts.Sender
+	if !addr.IsValid() {
+		addrs, err := s.ListAddresses(ctx)
+		if err != nil {
+			return nil, addr, mavryk.InvalidKey, err
+		}
+		if len(addrs) == 0 {
+			return nil, addr, mavryk.InvalidKey, fmt.Errorf("rpc: signer has no addresses")
+		}
+		addr = addrs[0]
+	}
+
+	key, err := s.GetKey(ctx, addr)
+	if err != nil {
+		return nil, addr, mavryk.InvalidKey, err
+	}
+	return s, addr, key, nil
+}
+
+// prepare completes, simulates and applies limits to op so it is ready
+// for signing.
+func (c *Client) prepare(ctx context.Context, op *codec.Op, key mavryk.Key, opts *CallOptions) error {
+	// pick up new params after protocol upgrades
+	if c.AutoRefreshParams && c.params() != nil {
+		if _, err := c.RefreshParams(ctx); err != nil {
+			return err
+		}
+	}
+
 	// set source and params on all ops
-	op.WithSource(key.Address()).WithParams(c.Params)
+	op.WithSource(key.Address()).WithParams(c.ChainParams())
 
 	// auto-complete op with branch/ttl, source counter, reveal
-	err = c.Complete(ctx, op, key)
+	err := c.complete(ctx, op, key, opts.Account)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	// simulate to check tx validity and estimate cost
 	sim, err := c.Simulate(ctx, op, opts)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	// fail with Tezos error when simulation failed
 	if !sim.IsSuccess() {
-		return nil, sim.Error()
+		return sim.Error()
 	}
 
 	// apply simulated cost as limits to tx list
@@ -361,80 +467,136 @@ func (c *Client) Send(ctx context.Context, op *codec.Op, opts *CallOptions) (*Re
 	// check minFee calc against maxFee if set
 	if opts.MaxFee > 0 {
 		if l := op.Limits(); l.Fee > opts.MaxFee {
-			return nil, fmt.Errorf("estimated cost %d > max %d", l.Fee, opts.MaxFee)
+			return fmt.Errorf("estimated cost %d > max %d", l.Fee, opts.MaxFee)
 		}
 	}
 
-	// sign digest
-	sig, err := signer.SignOperation(ctx, addr, op)
-	if err != nil {
-		return nil, err
-	}
-	op.WithSignature(sig)
-
-	// trace what we'll broadcast
-	c.logTrace(func() {
-		buf, _ := op.MarshalJSON()
-		c.Log.Tracef("Broadcast: %s", string(buf))
-	})
-
-	// broadcast
-	hash, err := c.Broadcast(ctx, op)
-	if err != nil {
-		return nil, err
-	}
+	// ensure op params match the node's current protocol
+	return c.CheckParams(ctx, op.Params)
+}
 
-	// wait for confirmations
-	res := NewResult(hash).WithTTL(op.TTL).WithConfirmations(opts.Confirmations)
+// waitReceipt waits for confirmations of an injected operation and
+// returns its receipt.
+func (c *Client) waitReceipt(ctx context.Context, hash mavryk.OpHash, ttl int64, mon *Observer, opts *CallOptions) (*Receipt, error) {
+	res := NewResult(hash).WithTTL(ttl).WithConfirmations(opts.Confirmations)
 
 	// wait for confirmations
 	res.Listen(mon)
 	res.WaitContext(ctx)
 	if err := res.Err(); err != nil {
+		if err == TTLExceeded {
+			c.observeInjection(InjectionExpired)
+		}
 		return nil, err
 	}
 
 	// return receipt
-	return res.GetReceipt(ctx)
+	rec, err := res.GetReceipt(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if rec.IsSuccess() {
+		c.observeInjection(InjectionApplied)
+	} else {
+		c.observeInjection(InjectionFailed)
+	}
+	return rec, nil
 }
 
 // RunOperation simulates executing an operation without requiring a valid signature.
 // The call returns the execution result as regular operation receipt.
 func (c *Client) RunOperation(ctx context.Context, id BlockID, body, resp interface{}) error {
 	u := fmt.Sprintf("chains/main/blocks/%s/helpers/scripts/run_operation", id)
-	return c.Post(ctx, u, body, resp)
+	return c.postIdempotent(ctx, u, body, resp)
+}
+
+// PreapplyOperations simulates the validation of signed operations at block id.
+// Operations must contain a valid protocol hash in their params.
+func (c *Client) PreapplyOperations(ctx context.Context, id BlockID, ops []*codec.Op, resp interface{}) error {
+	req := make([]codec.RPCOp, len(ops))
+	for i, v := range ops {
+		req[i] = v.Preapply()
+	}
+	u := fmt.Sprintf("chains/main/blocks/%s/helpers/preapply/operations", id)
+	return c.postIdempotent(ctx, u, req, resp)
 }
 
 // RunCode simulates executing of provided code on the context of a contract at selected block.
 func (c *Client) RunCode(ctx context.Context, id BlockID, body, resp interface{}) error {
 	u := fmt.Sprintf("chains/main/blocks/%s/helpers/scripts/run_code", id)
-	return c.Post(ctx, u, body, resp)
+	return c.postIdempotent(ctx, u, body, resp)
 }
 
 // RunCallback simulates executing of TZip4 view on the context of a contract at selected block.
 func (c *Client) RunCallback(ctx context.Context, id BlockID, body, resp interface{}) error {
 	u := fmt.Sprintf("chains/main/blocks/%s/helpers/scripts/run_view", id)
-	return c.Post(ctx, u, body, resp)
+	return c.postIdempotent(ctx, u, body, resp)
 }
 
 // RunView simulates executing of on on-chain view on the context of a contract at selected block.
 func (c *Client) RunView(ctx context.Context, id BlockID, body, resp interface{}) error {
 	u := fmt.Sprintf("chains/main/blocks/%s/helpers/scripts/run_script_view", id)
-	return c.Post(ctx, u, body, resp)
+	return c.postIdempotent(ctx, u, body, resp)
 }
 
 // TraceCode simulates executing of code on the context of a contract at selected block and
 // returns a full execution trace.
 func (c *Client) TraceCode(ctx context.Context, id BlockID, body, resp interface{}) error {
 	u := fmt.Sprintf("chains/main/blocks/%s/helpers/scripts/trace_code", id)
-	return c.Post(ctx, u, body, resp)
+	return c.postIdempotent(ctx, u, body, resp)
+}
+
+// InjectionOptions control how a node injects an operation. The zero value
+// uses node defaults which inject into the client's chain after successful
+// prevalidation.
+type InjectionOptions struct {
+	Async bool   // return immediately without waiting for prevalidation
+	Force bool   // inject even when prevalidation fails
+	Chain string // chain alias or chain id, defaults to the client's chain
+}
+
+// Query returns the URL query parameters for injection options o.
+func (o InjectionOptions) Query() url.Values {
+	q := url.Values{}
+	if o.Async {
+		q.Set("async", "true")
+	}
+	if o.Force {
+		q.Set("force", "true")
+	}
+	if o.Chain != "" {
+		q.Set("chain", o.Chain)
+	}
+	return q
 }
 
 // BroadcastOperation sends a signed operation to the network (injection).
 // The call returns the operation hash on success. If theoperation was rejected
 // by the node error is of type RPCError.
 func (c *Client) BroadcastOperation(ctx context.Context, body []byte) (hash mavryk.OpHash, err error) {
-	err = c.Post(ctx, "injection/operation", hex.EncodeToString(body), &hash)
+	return c.BroadcastOperationExt(ctx, body, InjectionOptions{})
+}
+
+// BroadcastOperationExt injects a signed operation like BroadcastOperation
+// using injection options opts. Forced injection skips prevalidation so the
+// node may accept operations that never get included, e.g. operations with
+// a future branch or invalid counter.
+func (c *Client) BroadcastOperationExt(ctx context.Context, body []byte, opts InjectionOptions) (hash mavryk.OpHash, err error) {
+	if opts.Chain == "" && c.Chain != "" && c.Chain != "main" {
+		opts.Chain = c.Chain
+	}
+	u := url.URL{
+		Path:     "injection/operation",
+		RawQuery: opts.Query().Encode(),
+	}
+	err = c.Post(ctx, u.String(), hex.EncodeToString(body), &hash)
+	if err != nil {
+		if _, ok := err.(RPCError); ok {
+			c.observeInjection(InjectionRejected)
+		}
+	} else {
+		c.observeInjection(InjectionAccepted)
+	}
 	return
 }
 
@@ -443,7 +605,7 @@ func (c *Client) BroadcastOperation(ctx context.Context, body []byte) (hash mavr
 // meant for validating the locally generated serialized output.
 func (c *Client) ForgeOperation(ctx context.Context, id BlockID, body, resp interface{}) error {
 	u := fmt.Sprintf("chains/main/blocks/%s/helpers/forge/operations", id)
-	return c.Post(ctx, u, body, resp)
+	return c.postIdempotent(ctx, u, body, resp)
 }
 
 // SimulateOperation simulates executing an operation without requiring a valid signature.
@@ -460,5 +622,5 @@ func (c *Client) ForgeOperation(ctx context.Context, id BlockID, body, resp inte
 // future simulation point via RunOperationRequest.Latency (in blocks).
 func (c *Client) SimulateOperation(ctx context.Context, id BlockID, body, resp interface{}) error {
 	u := fmt.Sprintf("chains/main/blocks/%s/helpers/scripts/simulate_operation", id)
-	return c.Post(ctx, u, body, resp)
+	return c.postIdempotent(ctx, u, body, resp)
 }
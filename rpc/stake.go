@@ -0,0 +1,116 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+)
+
+// StakeDistribution lists the active stake of all bakers selected for rights
+// in a cycle.
+type StakeDistribution struct {
+	Cycle      int64       `json:"cycle"`
+	TotalStake int64       `json:"total_stake"`
+	Bakers     []StakeInfo `json:"bakers"`
+}
+
+// Find returns the stake of baker addr.
+func (d StakeDistribution) Find(addr mavryk.Address) (StakeInfo, bool) {
+	for _, v := range d.Bakers {
+		if v.Baker.Equal(addr) {
+			return v, true
+		}
+	}
+	return StakeInfo{}, false
+}
+
+// Share returns the share of total stake held by baker addr in range [0..1].
+func (d StakeDistribution) Share(addr mavryk.Address) float64 {
+	s, ok := d.Find(addr)
+	if !ok || d.TotalStake == 0 {
+		return 0
+	}
+	return float64(s.ActiveStake) / float64(d.TotalStake)
+}
+
+// DelegatorStake is the stake a single delegator contributes to its baker.
+type DelegatorStake struct {
+	Address mavryk.Address `json:"address"`
+	Balance int64          `json:"balance"` // spendable, counts as delegated stake
+	Staked  int64          `json:"staked"`  // v018+, counts as frozen stake
+}
+
+// Total returns the full stake contributed by the delegator.
+func (s DelegatorStake) Total() int64 {
+	return s.Balance + s.Staked
+}
+
+// GetStakeDistribution returns the stake distribution selected for rights
+// in cycle as seen from block id. Requires v012+. Note block and cycle must
+// be no further than preserved cycles away.
+func (c *Client) GetStakeDistribution(ctx context.Context, id BlockID, cycle int64) (*StakeDistribution, error) {
+	u := fmt.Sprintf("chains/main/blocks/%s/context/raw/json/cycle/%d/selected_stake_distribution", id, cycle)
+	dist := &StakeDistribution{
+		Cycle:  cycle,
+		Bakers: make([]StakeInfo, 0),
+	}
+	if err := c.Get(ctx, u, &dist.Bakers); err != nil {
+		return nil, err
+	}
+	for _, v := range dist.Bakers {
+		dist.TotalStake += v.ActiveStake
+	}
+	return dist, nil
+}
+
+// GetSnapshotIndexes returns the snapshots which produced rights for cycles
+// from to to (inclusive) as seen from block id.
+func (c *Client) GetSnapshotIndexes(ctx context.Context, id BlockID, from, to int64) ([]SnapshotIndex, error) {
+	list := make([]SnapshotIndex, 0, to-from+1)
+	for cycle := from; cycle <= to; cycle++ {
+		idx, err := c.GetSnapshotIndexCycle(ctx, id, cycle)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *idx)
+	}
+	return list, nil
+}
+
+// GetDelegatorStakes returns the balance and staked balance of all contracts
+// delegating to baker addr at block id. Reward-splitting tools can use the
+// result together with the snapshot level from GetSnapshotIndexCycle to map
+// delegator stake. Sends one request per delegator and balance kind.
+func (c *Client) GetDelegatorStakes(ctx context.Context, addr mavryk.Address, id BlockID) ([]DelegatorStake, error) {
+	delegate, err := c.GetDelegate(ctx, addr, id)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]DelegatorStake, 0, len(delegate.DelegatedContracts))
+	for _, v := range delegate.DelegatedContracts {
+		bal, err := c.GetContractBalance(ctx, v, id)
+		if err != nil {
+			return nil, err
+		}
+		stake := DelegatorStake{
+			Address: v,
+			Balance: bal.Int64(),
+		}
+		if v.IsEOA() {
+			staked, err := c.GetContractStakedBalance(ctx, v, id)
+			switch {
+			case err == nil:
+				stake.Staked = staked.Int64()
+			case ErrorStatus(err) != http.StatusNotFound:
+				return nil, err
+			}
+		}
+		list = append(list, stake)
+	}
+	return list, nil
+}
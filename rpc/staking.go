@@ -1,4 +1,4 @@
-// Copyright (c) 2023 Blockwatch Data Inc.
+// Copyright (c) 2023-2024 Blockwatch Data Inc.
 // Author: alex@blockwatch.cc
 
 package rpc
@@ -16,6 +16,18 @@ type StakingParameters struct {
 	Edge  int64 `json:"edge_of_baking_over_staking_billionth"`
 }
 
+// LimitOfStakingOverBaking returns the maximum external stake a delegate
+// accepts as multiple of its own stake.
+func (p StakingParameters) LimitOfStakingOverBaking() float64 {
+	return float64(p.Limit) / 1_000_000
+}
+
+// EdgeOfBakingOverStaking returns the share of staker rewards a delegate
+// keeps for itself in range [0..1].
+func (p StakingParameters) EdgeOfBakingOverStaking() float64 {
+	return float64(p.Edge) / 1_000_000_000
+}
+
 // GetDelegateStakingParams returns a delegate's current staking setup
 func (c *Client) GetDelegateStakingParams(ctx context.Context, addr mavryk.Address, id BlockID) (*StakingParameters, error) {
 	u := fmt.Sprintf("chains/main/blocks/%s/context/delegates/%s/active_staking_parameters", id, addr)
@@ -50,3 +62,81 @@ func (c *Client) GetUnstakedFrozenDeposits(ctx context.Context, addr mavryk.Addr
 	}
 	return list, nil
 }
+
+// UnstakeRequest is a pending or finalizable request to unstake funds
+// from a delegate.
+type UnstakeRequest struct {
+	Delegate mavryk.Address `json:"delegate"`
+	Cycle    int64          `json:"cycle"`
+	Amount   int64          `json:"amount,string"`
+}
+
+// UnstakeRequests lists a staker's unstake requests. Finalizable requests
+// can be withdrawn with a finalize_unstake operation, unfinalizable requests
+// are still frozen.
+type UnstakeRequests struct {
+	Finalizable   []UnstakeRequest `json:"finalizable"`
+	Unfinalizable struct {
+		Delegate mavryk.Address `json:"delegate"`
+		Requests []struct {
+			Cycle  int64 `json:"cycle"`
+			Amount int64 `json:"amount,string"`
+		} `json:"requests"`
+	} `json:"unfinalizable"`
+}
+
+// FinalizableAmount returns the sum of all finalizable requests.
+func (r UnstakeRequests) FinalizableAmount() int64 {
+	var sum int64
+	for _, v := range r.Finalizable {
+		sum += v.Amount
+	}
+	return sum
+}
+
+// UnfinalizableAmount returns the sum of all frozen requests.
+func (r UnstakeRequests) UnfinalizableAmount() int64 {
+	var sum int64
+	for _, v := range r.Unfinalizable.Requests {
+		sum += v.Amount
+	}
+	return sum
+}
+
+// GetContractStakedBalance returns the amount an account has staked with its
+// delegate at block id.
+func (c *Client) GetContractStakedBalance(ctx context.Context, addr mavryk.Address, id BlockID) (mavryk.Z, error) {
+	u := fmt.Sprintf("chains/main/blocks/%s/context/contracts/%s/staked_balance", id, addr)
+	var bal mavryk.Z
+	err := c.Get(ctx, u, &bal)
+	return bal, err
+}
+
+// GetContractUnstakedFrozenBalance returns the amount an account has requested
+// to unstake which is still frozen at block id.
+func (c *Client) GetContractUnstakedFrozenBalance(ctx context.Context, addr mavryk.Address, id BlockID) (mavryk.Z, error) {
+	u := fmt.Sprintf("chains/main/blocks/%s/context/contracts/%s/unstaked_frozen_balance", id, addr)
+	var bal mavryk.Z
+	err := c.Get(ctx, u, &bal)
+	return bal, err
+}
+
+// GetContractUnstakedFinalizableBalance returns the amount an account can
+// withdraw with finalize_unstake at block id.
+func (c *Client) GetContractUnstakedFinalizableBalance(ctx context.Context, addr mavryk.Address, id BlockID) (mavryk.Z, error) {
+	u := fmt.Sprintf("chains/main/blocks/%s/context/contracts/%s/unstaked_finalizable_balance", id, addr)
+	var bal mavryk.Z
+	err := c.Get(ctx, u, &bal)
+	return bal, err
+}
+
+// GetContractUnstakeRequests returns an account's unstake requests at block id.
+// The result is empty when the account has no pending requests.
+func (c *Client) GetContractUnstakeRequests(ctx context.Context, addr mavryk.Address, id BlockID) (*UnstakeRequests, error) {
+	u := fmt.Sprintf("chains/main/blocks/%s/context/contracts/%s/unstake_requests", id, addr)
+	r := &UnstakeRequests{}
+	if err := c.Get(ctx, u, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// OperationFunc is called for every streamed operation group at list l and
+// position n. Returning an error stops decoding.
+type OperationFunc func(l, n int, op *Operation) error
+
+// StreamBlockOperations decodes all operation groups of block id one by one
+// from the response stream and calls fn for each group. Operations are not
+// retained, so peak memory stays at the size of a single group which helps
+// indexers processing large blocks. Errors returned by fn are passed through.
+//
+// Unlike Get, the response cache is not used and requests are only retried
+// until the response starts streaming. Strict field checks and raw message
+// retention apply to each group separately.
+func (c *Client) StreamBlockOperations(ctx context.Context, id BlockID, fn OperationFunc) error {
+	return c.streamBlockOperations(ctx, id, nil, fn)
+}
+
+// streamBlockOperations calls list at the start of every operation list and
+// fn for every operation group.
+func (c *Client) streamBlockOperations(ctx context.Context, id BlockID, list func(l int), fn OperationFunc) error {
+	u := fmt.Sprintf("chains/main/blocks/%s/operations", id)
+	if c.MetadataMode != "" {
+		u += "?metadata=" + string(c.MetadataMode)
+	}
+	body, err := c.getStream(ctx, u)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		io.Copy(io.Discard, body)
+		body.Close()
+	}()
+
+	return c.decodeOperations(ctx, json.NewDecoder(body), list, fn)
+}
+
+// decodeOperations decodes a JSON array of operation lists from dec and
+// calls list at the start of every operation list and fn for every
+// operation group.
+func (c *Client) decodeOperations(ctx context.Context, dec *json.Decoder, list func(l int), fn OperationFunc) error {
+	if err := expectDelim(dec, '['); err != nil {
+		return err
+	}
+	for l := 0; dec.More(); l++ {
+		if err := expectDelim(dec, '['); err != nil {
+			return err
+		}
+		if list != nil {
+			list(l)
+		}
+		for n := 0; dec.More(); n++ {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			op := &Operation{}
+			if err := c.decodeNext(dec, op); err != nil {
+				return fmt.Errorf("rpc: decoding operation %d/%d: %w", l, n, err)
+			}
+			if err := fn(l, n, op); err != nil {
+				return err
+			}
+		}
+		if err := expectDelim(dec, ']'); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, ']')
+}
+
+// GetBlockOperationsStream returns all operation groups of block id like
+// GetBlockOperations, but decodes the response while it streams in instead
+// of buffering it first.
+func (c *Client) GetBlockOperationsStream(ctx context.Context, id BlockID) ([][]Operation, error) {
+	ops := make([][]Operation, 0, 4)
+	err := c.streamBlockOperations(ctx, id, func(int) {
+		ops = append(ops, make([]Operation, 0))
+	}, func(l, _ int, op *Operation) error {
+		ops[l] = append(ops[l], *op)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ops, nil
+}
+
+// getStream sends a GET request and returns the response body of a
+// successful response. Callers must close the body.
+func (c *Client) getStream(ctx context.Context, urlpath string) (io.ReadCloser, error) {
+	var body io.ReadCloser
+	err := c.withRetry(ctx, func() error {
+		req, err := c.NewRequest(ctx, http.MethodGet, urlpath, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := c.do(req)
+		if err != nil {
+			if e, ok := err.(*url.Error); ok {
+				return e.Err
+			}
+			return err
+		}
+		if resp.StatusCode/100 != 2 {
+			defer func() {
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+			}()
+			return c.handleError(resp)
+		}
+		body = resp.Body
+		return nil
+	})
+	return body, err
+}
+
+func expectDelim(dec *json.Decoder, d json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("rpc: %w", err)
+	}
+	if tok != d {
+		return fmt.Errorf("rpc: unexpected token %v, expected %v", tok, d)
+	}
+	return nil
+}
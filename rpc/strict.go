@@ -0,0 +1,222 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	fieldCache sync.Map // reflect.Type -> map[string]reflect.StructField
+
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+	// rpc types whose custom decoders map JSON members onto struct fields,
+	// so field checks descend into them
+	checkedTypes = map[reflect.Type]bool{
+		reflect.TypeOf(Constants{}):     true,
+		reflect.TypeOf(StakeInfo{}):     true,
+		reflect.TypeOf(OperationList{}): true,
+	}
+)
+
+// UnknownFields returns the fields which were skipped while decoding since
+// the last reset together with how often they were seen, see
+// ReportUnknownFields. Fields are named by Go type and JSON path, e.g.
+// BlockMetadata.new_field.
+func (c *Client) UnknownFields() map[string]int {
+	c.unknownMu.Lock()
+	defer c.unknownMu.Unlock()
+	res := make(map[string]int, len(c.unknownFields))
+	for k, v := range c.unknownFields {
+		res[k] = v
+	}
+	return res
+}
+
+// ResetUnknownFields clears all collected unknown fields.
+func (c *Client) ResetUnknownFields() {
+	c.unknownMu.Lock()
+	defer c.unknownMu.Unlock()
+	c.unknownFields = nil
+}
+
+func (c *Client) isFieldCheckEnabled() bool {
+	return c.StrictJSON || c.ReportUnknownFields
+}
+
+// checkFields compares JSON object keys in data against the fields of v and
+// all nested values. Nested types with custom decoders are skipped unless
+// they decode into their own fields. In strict mode unknown fields are
+// returned as error, in report mode they are collected.
+func (c *Client) checkFields(data []byte, v any) error {
+	if !c.isFieldCheckEnabled() || v == nil {
+		return nil
+	}
+	val := reflect.ValueOf(v)
+	for val.Kind() == reflect.Pointer || val.Kind() == reflect.Interface {
+		if val.IsNil() {
+			return nil
+		}
+		val = val.Elem()
+	}
+	var missing []string
+	walkFields(data, val, "", func(path string) {
+		missing = append(missing, path)
+	})
+	if len(missing) == 0 {
+		return nil
+	}
+	if c.StrictJSON {
+		sort.Strings(missing)
+		return fmt.Errorf("rpc: unknown fields %s", strings.Join(missing, ", "))
+	}
+	c.unknownMu.Lock()
+	defer c.unknownMu.Unlock()
+	if c.unknownFields == nil {
+		c.unknownFields = make(map[string]int)
+	}
+	for _, v := range missing {
+		c.unknownFields[v]++
+	}
+	return nil
+}
+
+// walkFields calls fn with the path of each JSON object member in data which
+// is not mapped to a field of v. Paths start at the closest named struct.
+func walkFields(data []byte, v reflect.Value, path string, fn func(string)) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	t := v.Type()
+	if !checkedTypes[t] {
+		pt := reflect.PointerTo(t)
+		if pt.Implements(jsonUnmarshalerType) || pt.Implements(textUnmarshalerType) {
+			return
+		}
+	}
+	if t.Name() != "" && t.Kind() == reflect.Struct {
+		path = t.Name()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		var obj map[string]json.RawMessage
+		if json.Unmarshal(data, &obj) != nil {
+			return
+		}
+		fields := jsonFields(t)
+		for k, buf := range obj {
+			sf, ok := fields[strings.ToLower(k)]
+			if !ok {
+				fn(path + "." + k)
+				continue
+			}
+			if f, err := v.FieldByIndexErr(sf.Index); err == nil {
+				walkFields(buf, f, path+"."+k, fn)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return
+		}
+		var arr []json.RawMessage
+		if json.Unmarshal(data, &arr) != nil {
+			return
+		}
+		for i := 0; i < len(arr) && i < v.Len(); i++ {
+			walkFields(arr[i], v.Index(i), path+"[]", fn)
+		}
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return
+		}
+		var obj map[string]json.RawMessage
+		if json.Unmarshal(data, &obj) != nil {
+			return
+		}
+		for k, buf := range obj {
+			if e := v.MapIndex(reflect.ValueOf(k).Convert(t.Key())); e.IsValid() {
+				walkFields(buf, e, path+"[]", fn)
+			}
+		}
+	}
+}
+
+// extraFields returns the top-level JSON object members in data which are
+// not mapped to a field of struct v or nil when all fields are known.
+func extraFields(data []byte, v any) map[string]json.RawMessage {
+	var obj map[string]json.RawMessage
+	if json.Unmarshal(data, &obj) != nil {
+		return nil
+	}
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	fields := jsonFields(t)
+	for k := range obj {
+		if _, ok := fields[strings.ToLower(k)]; ok {
+			delete(obj, k)
+		}
+	}
+	if len(obj) == 0 {
+		return nil
+	}
+	return obj
+}
+
+// jsonFields returns the lower case JSON names of all fields the JSON decoder
+// sets on struct type t, including embedded fields. Field indexes are paths
+// from t, see reflect.Value.FieldByIndex.
+func jsonFields(t reflect.Type) map[string]reflect.StructField {
+	if m, ok := fieldCache.Load(t); ok {
+		return m.(map[string]reflect.StructField)
+	}
+	m := make(map[string]reflect.StructField)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() && !f.Anonymous {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				for k, v := range jsonFields(ft) {
+					if _, ok := m[k]; !ok {
+						v.Index = append([]int{i}, v.Index...)
+						m[k] = v
+					}
+				}
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		m[strings.ToLower(name)] = f
+	}
+	fieldCache.Store(t, m)
+	return m
+}
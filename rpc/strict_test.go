@@ -0,0 +1,125 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mavryk-network/mvgo/rpc"
+	"github.com/mavryk-network/mvgo/rpc/rpctest"
+)
+
+// withUnknownField returns JSON object buf with an extra member.
+func withUnknownField(t *testing.T, buf []byte) []byte {
+	t.Helper()
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(buf, &obj); err != nil {
+		t.Fatal(err)
+	}
+	obj["new_field"] = json.RawMessage(`1`)
+	buf, _ = json.Marshal(obj)
+	return buf
+}
+
+func newStrictTestNode(t *testing.T) *rpctest.Node {
+	t.Helper()
+	node, c, _ := newTestNode(t)
+	var raw json.RawMessage
+	if err := c.Get(context.Background(), "chains/main/blocks/head/header", &raw); err != nil {
+		t.Fatal(err)
+	}
+	header := withUnknownField(t, raw)
+	head := node.Head()
+	raw, _ = json.Marshal(head.LogEntry())
+	entry := withUnknownField(t, raw)
+	node.Handle(http.MethodGet, "/chains/main/blocks/head/header", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(header)
+	})
+	node.Handle(http.MethodGet, "/monitor/heads/main", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(entry)
+	})
+	node.Handle(http.MethodGet, "/chains/main/blocks/head/operations/3", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[{"contents":[{"kind":"transaction","source":"` + testReceiver.String() + `",` +
+			`"fee":"0","counter":"1","gas_limit":"0","storage_limit":"0","amount":"0",` +
+			`"destination":"` + testReceiver.String() + `","new_field":1}]}]`))
+	})
+	return node
+}
+
+func TestStrictJSON(t *testing.T) {
+	node := newStrictTestNode(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	strict, err := node.Client(rpc.WithStrictJSON())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer strict.Close()
+	lenient, err := node.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lenient.Close()
+
+	// strict mode is a per client setting
+	if _, err := strict.GetBlockHeader(ctx, rpc.Head); err == nil || !strings.Contains(err.Error(), "BlockHeader.new_field") {
+		t.Errorf("want unknown field error, have %v", err)
+	}
+	if _, err := lenient.GetBlockHeader(ctx, rpc.Head); err != nil {
+		t.Errorf("lenient: %v", err)
+	}
+
+	// nested operation contents are checked
+	if _, err := strict.GetBlockOperationList(ctx, rpc.Head, 3); err == nil || !strings.Contains(err.Error(), "Transaction.new_field") {
+		t.Errorf("want unknown content field error, have %v", err)
+	}
+
+	// monitors are checked
+	mon := rpc.NewBlockHeaderMonitor()
+	defer mon.Close()
+	if err := strict.MonitorBlockHeader(ctx, mon); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mon.Recv(ctx); err == nil || !strings.Contains(err.Error(), "BlockHeaderLogEntry.new_field") {
+		t.Errorf("want unknown monitor field error, have %v", err)
+	}
+}
+
+func TestReportUnknownFields(t *testing.T) {
+	node := newStrictTestNode(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	c, err := node.Client(rpc.WithUnknownFieldReport())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.GetBlockHeader(ctx, rpc.Head); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetBlockOperationList(ctx, rpc.Head, 3); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetBlockHeader(ctx, rpc.Head); err != nil {
+		t.Fatal(err)
+	}
+	have := c.UnknownFields()
+	if len(have) != 2 || have["BlockHeader.new_field"] != 2 || have["Transaction.new_field"] != 1 {
+		t.Errorf("unexpected report %v", have)
+	}
+	c.ResetUnknownFields()
+	if n := len(c.UnknownFields()); n > 0 {
+		t.Errorf("want empty report after reset, have %d", n)
+	}
+}
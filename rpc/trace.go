@@ -0,0 +1,172 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+	"github.com/mavryk-network/mvgo/micheline"
+)
+
+// TraceFrame is a single execution step of a traced Michelson script. Frames
+// are recorded after each instruction.
+type TraceFrame struct {
+	Location int              // canonical node location of the instruction in the script
+	Gas      ScriptGas        // remaining gas
+	Stack    []micheline.Prim // stack contents, top element first
+}
+
+// Instruction returns the instruction at the frame's location in script code.
+func (f TraceFrame) Instruction(code micheline.Code) (micheline.Prim, bool) {
+	return LocateInstruction(code, f.Location)
+}
+
+func (f *TraceFrame) UnmarshalJSON(data []byte) error {
+	var frame struct {
+		Location int               `json:"location"`
+		Gas      ScriptGas         `json:"gas"`
+		Stack    []json.RawMessage `json:"stack"`
+	}
+	if err := json.Unmarshal(data, &frame); err != nil {
+		return err
+	}
+	f.Location = frame.Location
+	f.Gas = frame.Gas
+	f.Stack = make([]micheline.Prim, len(frame.Stack))
+	for i, v := range frame.Stack {
+		// older protocols wrap stack items as {"item": expr, "annot": string}
+		var item struct {
+			Item *micheline.Prim `json:"item"`
+		}
+		if len(v) > 0 && v[0] == '{' {
+			if err := json.Unmarshal(v, &item); err != nil {
+				return err
+			}
+		}
+		if item.Item != nil {
+			f.Stack[i] = *item.Item
+			continue
+		}
+		if err := json.Unmarshal(v, &f.Stack[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TraceCodeResponse is the result of a traced script run.
+type TraceCodeResponse struct {
+	RunCodeResponse
+	Trace []TraceFrame `json:"trace"`
+}
+
+// GasUsed returns the gas consumed by each frame. Frames without gas
+// accounting report zero.
+func (r TraceCodeResponse) GasUsed() []int64 {
+	used := make([]int64, len(r.Trace))
+	for i := 1; i < len(r.Trace); i++ {
+		prev, cur := r.Trace[i-1].Gas, r.Trace[i].Gas
+		if prev.IsAccounted() && cur.IsAccounted() {
+			used[i] = int64(prev - cur)
+		}
+	}
+	return used
+}
+
+// LocateInstruction returns the node at canonical location loc in script
+// code. Locations count all nodes in prefix order starting with the root
+// sequence of parameter, storage, code and views.
+func LocateInstruction(code micheline.Code, loc int) (micheline.Prim, bool) {
+	root := micheline.Prim{
+		Type: micheline.PrimSequence,
+		Args: []micheline.Prim{code.Param, code.Storage, code.Code},
+	}
+	if len(code.View.Args) > 0 {
+		root.Args = append(root.Args, code.View.Args...)
+	}
+	if code.BadCode.IsValid() {
+		root = code.BadCode
+	}
+	var (
+		n     int
+		found micheline.Prim
+		ok    bool
+		stop  = errors.New("found")
+	)
+	_ = root.Walk(func(p micheline.Prim) error {
+		if n == loc {
+			found, ok = p, true
+			return stop
+		}
+		n++
+		return nil
+	})
+	return found, ok
+}
+
+// RunCodeTrace runs a script like RunCode and returns the result together
+// with a step-by-step execution trace. Fails when the node does not expose
+// the tracing endpoint.
+func (c *Client) RunCodeTrace(ctx context.Context, id BlockID, req RunCodeRequest) (*TraceCodeResponse, error) {
+	if !req.ChainId.IsValid() {
+		req.ChainId = c.ChainId
+	}
+	var resp TraceCodeResponse
+	if err := c.TraceCode(ctx, id, req, &resp); err != nil {
+		if ErrorStatus(err) == http.StatusNotFound {
+			return nil, fmt.Errorf("rpc: node does not support script tracing: %v", err)
+		}
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// TraceCallRequest describes a contract call to trace.
+type TraceCallRequest struct {
+	Destination mavryk.Address  // called contract
+	Entrypoint  string          // entrypoint, defaults to default
+	Params      micheline.Prim  // call parameters, defaults to Unit
+	Amount      mavryk.N        // transferred amount
+	Source      *mavryk.Address // optional sender
+	Gas         *mavryk.N       // optional gas limit
+}
+
+// TraceCall traces a call to a deployed contract with the contract's script,
+// storage and balance at block id. Nodes only support tracing the called
+// contract, internal operations are returned but not executed.
+func (c *Client) TraceCall(ctx context.Context, id BlockID, call TraceCallRequest) (*TraceCodeResponse, error) {
+	script, err := c.GetContractScript(ctx, call.Destination)
+	if err != nil {
+		return nil, err
+	}
+	storage, err := c.GetContractStorage(ctx, call.Destination, id)
+	if err != nil {
+		return nil, err
+	}
+	balance, err := c.GetContractBalance(ctx, call.Destination, id)
+	if err != nil {
+		return nil, err
+	}
+	params := call.Params
+	if !params.IsValid() {
+		params = micheline.NewCode(micheline.D_UNIT)
+	}
+	return c.RunCodeTrace(ctx, id, RunCodeRequest{
+		ChainId:    c.ChainId,
+		Script:     script.Code,
+		Storage:    storage,
+		Input:      params,
+		Amount:     call.Amount,
+		Balance:    mavryk.N(balance.Int64()),
+		Source:     call.Source,
+		Payer:      call.Source,
+		Gas:        call.Gas,
+		Entrypoint: call.Entrypoint,
+	})
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// httpTransport returns the client's transport for configuration. The HTTP
+// client and transport are copied on first use, so shared defaults like
+// http.DefaultClient are never modified. It returns nil when the client
+// uses a custom round tripper which is not a *http.Transport.
+func (c *Client) httpTransport() *http.Transport {
+	if c.transport != nil && c.client.Transport == c.transport {
+		return c.transport
+	}
+	var base *http.Transport
+	switch t := c.client.Transport.(type) {
+	case nil:
+		base = http.DefaultTransport.(*http.Transport)
+	case *http.Transport:
+		base = t
+	default:
+		c.Log.Warnf("rpc: cannot configure custom transport %T", t)
+		return nil
+	}
+	t := base.Clone()
+	hc := *c.client
+	hc.Transport = t
+	c.client = &hc
+	c.transport = t
+	return t
+}
+
+// tlsConfig returns the TLS config of the client's transport.
+func (c *Client) tlsConfig() *tls.Config {
+	t := c.httpTransport()
+	if t == nil {
+		return nil
+	}
+	if t.TLSClientConfig == nil {
+		t.TLSClientConfig = &tls.Config{}
+	}
+	return t.TLSClientConfig
+}
+
+// LoadCertPool returns the system certificate pool extended by all PEM
+// encoded certificates in files, e.g. a private CA bundle.
+func LoadCertPool(files ...string) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	for _, f := range files {
+		buf, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(buf) {
+			return nil, fmt.Errorf("rpc: no certificates found in %s", f)
+		}
+	}
+	return pool, nil
+}
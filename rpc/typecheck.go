@@ -0,0 +1,145 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+	"github.com/mavryk-network/mvgo/micheline"
+)
+
+// ScriptGas is the remaining gas reported by script helper RPCs. Nodes
+// report "unaccounted" when no gas limit was requested which decodes
+// as -1. Fractional milligas is truncated.
+type ScriptGas int64
+
+const GasUnaccounted ScriptGas = -1
+
+// IsAccounted returns true when the node has tracked gas consumption.
+func (g ScriptGas) IsAccounted() bool {
+	return g >= 0
+}
+
+func (g ScriptGas) MarshalText() ([]byte, error) {
+	if !g.IsAccounted() {
+		return []byte("unaccounted"), nil
+	}
+	return []byte(strconv.FormatInt(int64(g), 10)), nil
+}
+
+func (g *ScriptGas) UnmarshalText(data []byte) error {
+	if string(data) == "unaccounted" {
+		*g = GasUnaccounted
+		return nil
+	}
+	s, _, _ := strings.Cut(string(data), ".")
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("rpc: invalid gas %q", string(data))
+	}
+	*g = ScriptGas(n)
+	return nil
+}
+
+// PackDataResponse contains the packed binary value and remaining gas.
+type PackDataResponse struct {
+	Packed mavryk.HexBytes `json:"packed"`
+	Gas    ScriptGas       `json:"gas"`
+}
+
+// TypecheckDataResponse contains the remaining gas after typechecking data.
+type TypecheckDataResponse struct {
+	Gas ScriptGas `json:"gas"`
+}
+
+// TypeMapEntry lists the stack types before and after the instruction at
+// a script location.
+type TypeMapEntry struct {
+	Location    int              `json:"location"`
+	StackBefore []micheline.Prim `json:"stack_before"`
+	StackAfter  []micheline.Prim `json:"stack_after"`
+}
+
+// TypecheckCodeResponse contains the type map of a typechecked script and
+// the remaining gas.
+type TypecheckCodeResponse struct {
+	TypeMap []TypeMapEntry `json:"type_map"`
+	Gas     ScriptGas      `json:"gas"`
+}
+
+type scriptDataRequest struct {
+	Data   micheline.Prim `json:"data"`
+	Type   micheline.Prim `json:"type"`
+	Gas    *ScriptGas     `json:"gas,omitempty"`
+	Legacy bool           `json:"legacy,omitempty"`
+}
+
+type typecheckCodeRequest struct {
+	Program   micheline.Code `json:"program"`
+	Gas       *ScriptGas     `json:"gas,omitempty"`
+	Legacy    bool           `json:"legacy,omitempty"`
+	ShowTypes bool           `json:"show_types"`
+}
+
+func gasLimit(gas int64) *ScriptGas {
+	if gas <= 0 {
+		return nil
+	}
+	g := ScriptGas(gas)
+	return &g
+}
+
+// PackData serializes data of type typ with the node's PACK implementation.
+// Use gas > 0 to limit and account gas. Ill-typed data fails with an error
+// matching ErrIllTypedData.
+func (c *Client) PackData(ctx context.Context, id BlockID, data, typ micheline.Prim, gas int64) (*PackDataResponse, error) {
+	u := fmt.Sprintf("chains/main/blocks/%s/helpers/scripts/pack_data", id)
+	req := scriptDataRequest{
+		Data: data,
+		Type: typ,
+		Gas:  gasLimit(gas),
+	}
+	resp := &PackDataResponse{}
+	if err := c.postIdempotent(ctx, u, &req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// TypecheckData checks data against type typ. Use gas > 0 to limit and account
+// gas. Ill-typed data fails with an error matching ErrIllTypedData.
+func (c *Client) TypecheckData(ctx context.Context, id BlockID, data, typ micheline.Prim, gas int64) (*TypecheckDataResponse, error) {
+	u := fmt.Sprintf("chains/main/blocks/%s/helpers/scripts/typecheck_data", id)
+	req := scriptDataRequest{
+		Data: data,
+		Type: typ,
+		Gas:  gasLimit(gas),
+	}
+	resp := &TypecheckDataResponse{}
+	if err := c.postIdempotent(ctx, u, &req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// TypecheckCode typechecks a script and returns the stack types at each
+// instruction. Use gas > 0 to limit and account gas. Ill-typed scripts fail
+// with an error matching ErrIllTypedContract.
+func (c *Client) TypecheckCode(ctx context.Context, id BlockID, code micheline.Code, gas int64) (*TypecheckCodeResponse, error) {
+	u := fmt.Sprintf("chains/main/blocks/%s/helpers/scripts/typecheck_code", id)
+	req := typecheckCodeRequest{
+		Program:   code,
+		Gas:       gasLimit(gas),
+		ShowTypes: true,
+	}
+	resp := &TypecheckCodeResponse{}
+	if err := c.postIdempotent(ctx, u, &req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
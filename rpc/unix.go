@@ -0,0 +1,46 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc
+
+import (
+	"context"
+	"net"
+	"net/http"
+)
+
+// UnixScheme is the URL scheme prefix which selects a unix domain socket
+// connection in NewClient, e.g. unix:///var/run/mavkit-node.sock.
+const UnixScheme = "unix://"
+
+// unixBaseURL is the HTTP base URL used for requests over unix sockets. The
+// host is ignored when dialing.
+const unixBaseURL = "http://localhost"
+
+// NewUnixTransport returns a transport which connects to the unix domain
+// socket at path for all requests regardless of the request host.
+func NewUnixTransport(path string) *http.Transport {
+	return unixTransport(path, nil)
+}
+
+func unixTransport(path string, base *http.Transport) *http.Transport {
+	if base == nil {
+		base = http.DefaultTransport.(*http.Transport)
+	}
+	t := base.Clone()
+	t.Proxy = nil
+	t.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "unix", path)
+	}
+	return t
+}
+
+// unixClient returns a copy of client h which connects to the unix domain
+// socket at path. Settings of a custom *http.Transport are kept.
+func unixClient(path string, h *http.Client) *http.Client {
+	c := *h
+	base, _ := h.Transport.(*http.Transport)
+	c.Transport = unixTransport(path, base)
+	return &c
+}
@@ -11,7 +11,8 @@ var _ TypedOperation = (*UpdateConsensusKey)(nil)
 // UpdateConsensusKey represents a transaction operation
 type UpdateConsensusKey struct {
 	Manager
-	Pk mavryk.Key `json:"pk"`
+	Pk    mavryk.Key        `json:"pk"`
+	Proof *mavryk.Signature `json:"proof,omitempty"` // BLS proof of possession
 }
 
 // Costs returns operation cost to implement TypedOperation interface.
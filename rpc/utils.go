@@ -8,6 +8,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"strconv"
+	"strings"
+
+	"github.com/mavryk-network/mvgo/mavryk"
 )
 
 // private constants strings
@@ -38,8 +41,10 @@ func (b BlockLevel) Int64() int64 {
 type BlockAlias string
 
 const (
-	Genesis BlockAlias = "genesis"
-	Head    BlockAlias = "head"
+	Genesis   BlockAlias = "genesis"
+	Head      BlockAlias = "head"
+	Caboose   BlockAlias = "caboose"   // oldest stored block
+	Savepoint BlockAlias = "savepoint" // oldest block with metadata
 )
 
 func (b BlockAlias) String() string {
@@ -60,13 +65,72 @@ type BlockOffset struct {
 	Offset int64
 }
 
+// NewBlockOffset returns a block id n blocks after (n > 0) or before (n < 0)
+// block id. Offsets of offsets are merged since the node supports a single
+// offset only.
 func NewBlockOffset(id BlockID, n int64) BlockOffset {
+	if o, ok := id.(BlockOffset); ok {
+		return BlockOffset{
+			Base:   o.Base,
+			Offset: o.Offset + n,
+		}
+	}
 	return BlockOffset{
 		Base:   id,
 		Offset: n,
 	}
 }
 
+// BlockBefore returns a block id n blocks before id, e.g. head~n.
+func BlockBefore(id BlockID, n int64) BlockOffset {
+	return NewBlockOffset(id, -n)
+}
+
+// BlockAfter returns a block id n blocks after id, e.g. hash+n.
+func BlockAfter(id BlockID, n int64) BlockOffset {
+	return NewBlockOffset(id, n)
+}
+
+// HeadBefore returns a block id n blocks before the current head (head~n).
+func HeadBefore(n int64) BlockOffset {
+	return NewBlockOffset(Head, -n)
+}
+
+// ParseBlockID parses the node's block id syntax which is a block hash, a
+// level or an alias (head, genesis, caboose, savepoint), optionally
+// followed by ~N or +N to address blocks relative to the base.
+func ParseBlockID(s string) (BlockID, error) {
+	base, n := s, int64(0)
+	if i := strings.LastIndexAny(s, "~+"); i >= 0 {
+		var err error
+		n, err = strconv.ParseInt(s[i+1:], 10, 64)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("rpc: invalid block id %q", s)
+		}
+		if s[i] == '~' {
+			n = -n
+		}
+		base = s[:i]
+	}
+	var id BlockID
+	switch base {
+	case "head", "genesis", "caboose", "savepoint":
+		id = BlockAlias(base)
+	default:
+		if l, err := strconv.ParseInt(base, 10, 64); err == nil && l >= 0 {
+			id = BlockLevel(l)
+		} else if h, err := mavryk.ParseBlockHash(base); err == nil {
+			id = h
+		} else {
+			return nil, fmt.Errorf("rpc: invalid block id %q", s)
+		}
+	}
+	if n == 0 && base == s {
+		return id, nil
+	}
+	return NewBlockOffset(id, n), nil
+}
+
 func (o BlockOffset) String() string {
 	ref := o.Base.String()
 	if o.Offset > 0 {
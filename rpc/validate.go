@@ -0,0 +1,189 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mavryk-network/mvgo/codec"
+)
+
+// ValidationStage identifies the step at which operation validation failed.
+type ValidationStage string
+
+const (
+	ValidationStageSimulate ValidationStage = "simulate" // dry-run without signature
+	ValidationStagePreapply ValidationStage = "preapply" // node validation with signature
+	ValidationStageCompare  ValidationStage = "compare"  // preapply result differs from simulation
+)
+
+// ValidationError is returned by ValidateOperation. Node errors are wrapped,
+// so errors.Is works with node error classes like ErrCounterInThePast.
+type ValidationError struct {
+	Stage ValidationStage
+	Err   error         // node error, nil for compare failures
+	Diffs []ReceiptDiff // mismatches between simulation and preapply
+}
+
+func (e *ValidationError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("rpc: %s failed: %v", e.Stage, e.Err)
+	}
+	s := make([]string, len(e.Diffs))
+	for i, v := range e.Diffs {
+		s[i] = v.String()
+	}
+	return fmt.Sprintf("rpc: %s failed: %s", e.Stage, strings.Join(s, ", "))
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// ReceiptDiff describes a difference between the simulated and preapplied
+// result of the operation at position Index in the operation list.
+type ReceiptDiff struct {
+	Index      int
+	Field      string // kind, status, gas_used, storage_used
+	Simulated  string
+	Preapplied string
+	Fatal      bool // difference makes the operation fail
+}
+
+func (d ReceiptDiff) String() string {
+	return fmt.Sprintf("op %d %s simulated=%s preapplied=%s", d.Index, d.Field, d.Simulated, d.Preapplied)
+}
+
+// ValidationResult contains the receipts of a successful validation and
+// non-fatal differences like changed gas usage.
+type ValidationResult struct {
+	Simulated  *Receipt
+	Preapplied *Receipt
+	Diffs      []ReceiptDiff
+}
+
+// ValidateOperation checks a completed and signed operation before broadcast.
+// It first simulates the operation with its current limits, then preapplies
+// it with the real signature at head and compares both results. Validation
+// fails with a ValidationError when either step returns an error, when an
+// operation did not apply or when preapply used more gas or storage than
+// the operation's limits allow.
+//
+// Use this to catch counter, limit, branch and signature errors without
+// broadcasting. The operation is not changed.
+func (c *Client) ValidateOperation(ctx context.Context, op *codec.Op, opts *CallOptions) (*ValidationResult, error) {
+	if !op.Signature.IsValid() {
+		return nil, fmt.Errorf("rpc: validate unsigned operation")
+	}
+	if opts == nil {
+		opts = &DefaultOptions
+	}
+	ctx = WithRequestHeaders(ctx, opts.Headers)
+	// simulate the operation as is
+	simOpts := *opts
+	simOpts.IgnoreLimits = true
+	sim, err := c.Simulate(ctx, op, &simOpts)
+	if err != nil {
+		return nil, &ValidationError{Stage: ValidationStageSimulate, Err: err}
+	}
+
+	// preapply with signature, the node requires a protocol hash
+	pre := *op
+	if pre.Params == nil || !pre.Params.Protocol.IsValid() {
+		pre.Params = c.ChainParams()
+	}
+	resp := make([]Operation, 0, 1)
+	if err := c.PreapplyOperations(ctx, Head, []*codec.Op{&pre}, &resp); err != nil {
+		return nil, &ValidationError{Stage: ValidationStagePreapply, Err: err}
+	}
+	if len(resp) != 1 {
+		return nil, &ValidationError{
+			Stage: ValidationStagePreapply,
+			Err:   fmt.Errorf("rpc: unexpected preapply result with %d operations", len(resp)),
+		}
+	}
+	res := &ValidationResult{
+		Simulated:  sim,
+		Preapplied: &Receipt{Op: &resp[0]},
+	}
+	if !res.Preapplied.IsSuccess() {
+		return res, &ValidationError{Stage: ValidationStagePreapply, Err: res.Preapplied.Error()}
+	}
+
+	// compare results
+	res.Diffs = compareReceipts(op, sim, res.Preapplied)
+	var fatal []ReceiptDiff
+	for _, v := range res.Diffs {
+		if v.Fatal {
+			fatal = append(fatal, v)
+		}
+	}
+	if len(fatal) > 0 {
+		return res, &ValidationError{Stage: ValidationStageCompare, Diffs: fatal}
+	}
+	return res, nil
+}
+
+// compareReceipts lists differences between simulated and preapplied
+// results. Differences are fatal when they would make op fail.
+func compareReceipts(op *codec.Op, sim, pre *Receipt) []ReceiptDiff {
+	var diffs []ReceiptDiff
+	if len(sim.Op.Contents) != len(pre.Op.Contents) {
+		return append(diffs, ReceiptDiff{
+			Index:      -1,
+			Field:      "contents",
+			Simulated:  fmt.Sprint(len(sim.Op.Contents)),
+			Preapplied: fmt.Sprint(len(pre.Op.Contents)),
+			Fatal:      true,
+		})
+	}
+	simCosts, preCosts := sim.Costs(), pre.Costs()
+	for i := range sim.Op.Contents {
+		s, p := sim.Op.Contents[i], pre.Op.Contents[i]
+		if s.Kind() != p.Kind() {
+			diffs = append(diffs, ReceiptDiff{
+				Index:      i,
+				Field:      "kind",
+				Simulated:  s.Kind().String(),
+				Preapplied: p.Kind().String(),
+				Fatal:      true,
+			})
+			continue
+		}
+		if ss, ps := s.Result().Status, p.Result().Status; ss != ps {
+			diffs = append(diffs, ReceiptDiff{
+				Index:      i,
+				Field:      "status",
+				Simulated:  ss.String(),
+				Preapplied: ps.String(),
+				Fatal:      true,
+			})
+		}
+		if i >= len(op.Contents) {
+			continue
+		}
+		limits := op.Contents[i].Limits()
+		if sc, pc := simCosts[i].GasUsed, preCosts[i].GasUsed; sc != pc {
+			diffs = append(diffs, ReceiptDiff{
+				Index:      i,
+				Field:      "gas_used",
+				Simulated:  fmt.Sprint(sc),
+				Preapplied: fmt.Sprint(pc),
+				Fatal:      limits.GasLimit > 0 && pc > limits.GasLimit,
+			})
+		}
+		if sc, pc := simCosts[i].StorageUsed, preCosts[i].StorageUsed; sc != pc {
+			diffs = append(diffs, ReceiptDiff{
+				Index:      i,
+				Field:      "storage_used",
+				Simulated:  fmt.Sprint(sc),
+				Preapplied: fmt.Sprint(pc),
+				Fatal:      pc > limits.StorageLimit,
+			})
+		}
+	}
+	return diffs
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package rpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/mavryk-network/mvgo/mavryk"
+	"github.com/mavryk-network/mvgo/micheline"
+)
+
+// ScriptOptions control how views and scripts are executed by the node's
+// script helper RPCs.
+type ScriptOptions struct {
+	Mode   UnparsingMode  // result encoding, defaults to Readable
+	Gas    int64          // gas limit, zero for unlimited gas (views) or the node's default
+	Source mavryk.Address // optional sender, defaults to the zero address for views
+	Payer  mavryk.Address // optional payer, defaults to source
+	Now    time.Time      // optional block time seen by the script
+}
+
+// NewScriptOptions returns default script options.
+func NewScriptOptions() ScriptOptions {
+	return ScriptOptions{
+		Mode: UnparsingModeReadable,
+	}
+}
+
+// WithMode sets the unparsing mode for results.
+func (o ScriptOptions) WithMode(m UnparsingMode) ScriptOptions {
+	o.Mode = m
+	return o
+}
+
+// WithGas sets the gas limit.
+func (o ScriptOptions) WithGas(gas int64) ScriptOptions {
+	o.Gas = gas
+	return o
+}
+
+// WithSource sets the sender and payer.
+func (o ScriptOptions) WithSource(addr mavryk.Address) ScriptOptions {
+	o.Source = addr
+	o.Payer = addr
+	return o
+}
+
+// WithPayer sets the payer.
+func (o ScriptOptions) WithPayer(addr mavryk.Address) ScriptOptions {
+	o.Payer = addr
+	return o
+}
+
+// WithNow sets the block time seen by the script.
+func (o ScriptOptions) WithNow(t time.Time) ScriptOptions {
+	o.Now = t
+	return o
+}
+
+func (o ScriptOptions) mode() UnparsingMode {
+	if o.Mode == UnparsingModeInvalid {
+		return UnparsingModeReadable
+	}
+	return o.Mode
+}
+
+func (o ScriptOptions) now() string {
+	if o.Now.IsZero() {
+		return ""
+	}
+	return o.Now.UTC().Format(time.RFC3339)
+}
+
+// RunScriptView executes on-chain view name of contract addr with input
+// argument at block id and returns the view's result. Use Unit as input for
+// views without parameters.
+func (c *Client) RunScriptView(ctx context.Context, id BlockID, addr mavryk.Address, name string, input micheline.Prim, opts ScriptOptions) (micheline.Prim, error) {
+	if !input.IsValid() {
+		input = micheline.NewCode(micheline.D_UNIT)
+	}
+	req := RunViewRequest{
+		Contract:     addr,
+		View:         name,
+		Input:        input,
+		ChainId:      c.ChainId,
+		Source:       opts.Source,
+		Payer:        opts.Payer,
+		Gas:          mavryk.N(opts.Gas),
+		Mode:         string(opts.mode()),
+		UnlimitedGas: opts.Gas <= 0,
+		Now:          opts.now(),
+	}
+	if !req.Source.IsValid() {
+		req.Source = mavryk.ZeroAddress
+	}
+	if !req.Payer.IsValid() {
+		req.Payer = req.Source
+	}
+	var resp RunViewResponse
+	if err := c.RunView(ctx, id, &req, &resp); err != nil {
+		return micheline.InvalidPrim, err
+	}
+	return resp.Data, nil
+}
+
+// RunScriptCode executes script with storage and parameter input at block id
+// and returns the resulting storage, internal operations and big map diffs.
+// Options override the request's unparsing mode, gas, sender, payer and time
+// when set.
+func (c *Client) RunScriptCode(ctx context.Context, id BlockID, req RunCodeRequest, opts ScriptOptions) (*RunCodeResponse, error) {
+	if !req.ChainId.IsValid() {
+		req.ChainId = c.ChainId
+	}
+	if !req.Input.IsValid() {
+		req.Input = micheline.NewCode(micheline.D_UNIT)
+	}
+	req.Mode = opts.mode()
+	if opts.Gas > 0 {
+		gas := mavryk.N(opts.Gas)
+		req.Gas = &gas
+	}
+	if opts.Source.IsValid() {
+		req.Source = &opts.Source
+	}
+	if opts.Payer.IsValid() {
+		req.Payer = &opts.Payer
+	} else if req.Payer == nil {
+		req.Payer = req.Source
+	}
+	if now := opts.now(); now != "" {
+		req.Now = now
+	}
+	resp := &RunCodeResponse{}
+	if err := c.RunCode(ctx, id, &req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
@@ -1,4 +1,4 @@
-// Copyright (c) 2020-2021 Blockwatch Data Inc.
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
 // Author: alex@blockwatch.cc
 
 package rpc
@@ -80,6 +80,30 @@ func (p *Proposal) UnmarshalJSON(data []byte) error {
 // ProposalList contains a list of voters
 type ProposalList []Proposal
 
+// VotingInfo holds the voting state of a single delegate
+type VotingInfo struct {
+	Power              int64                 `json:"voting_power,string"`
+	Ballot             *mavryk.BallotVote    `json:"current_ballot,omitempty"`
+	Proposals          []mavryk.ProtocolHash `json:"current_proposals"`
+	RemainingProposals int                   `json:"remaining_proposals"`
+}
+
+// HasVoted returns true when the delegate has cast a ballot in the current
+// exploration or promotion period.
+func (v VotingInfo) HasVoted() bool {
+	return v.Ballot != nil && v.Ballot.IsValid()
+}
+
+// VoteState summarizes the state of the current voting period
+type VoteState struct {
+	Period     VotingPeriodInfo
+	Quorum     int                 // percent * 10000
+	Proposal   mavryk.ProtocolHash // empty outside of exploration, cooldown, promotion and adoption
+	TotalPower int64
+	Ballots    BallotSummary // only valid during exploration and promotion
+	Proposals  ProposalList  // only valid during the proposal period
+}
+
 // ListVoters returns information about all eligible voters for an election
 // at block id.
 func (c *Client) ListVoters(ctx context.Context, id BlockID) (VoterList, error) {
@@ -138,3 +162,80 @@ func (c *Client) ListProposals(ctx context.Context, id BlockID) (ProposalList, e
 	}
 	return proposals, nil
 }
+
+// GetVotingPeriod returns information about the current voting period at block id.
+func (c *Client) GetVotingPeriod(ctx context.Context, id BlockID) (*VotingPeriodInfo, error) {
+	var info VotingPeriodInfo
+	u := fmt.Sprintf("chains/main/blocks/%s/votes/current_period", id)
+	if err := c.Get(ctx, u, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// GetSuccessorVotingPeriod returns information about the voting period of the
+// block following block id.
+func (c *Client) GetSuccessorVotingPeriod(ctx context.Context, id BlockID) (*VotingPeriodInfo, error) {
+	var info VotingPeriodInfo
+	u := fmt.Sprintf("chains/main/blocks/%s/votes/successor_period", id)
+	if err := c.Get(ctx, u, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// GetTotalVotingPower returns the sum of voting power of all listed voters
+// at block id.
+func (c *Client) GetTotalVotingPower(ctx context.Context, id BlockID) (int64, error) {
+	var power Int64orString
+	u := fmt.Sprintf("chains/main/blocks/%s/votes/total_voting_power", id)
+	if err := c.Get(ctx, u, &power); err != nil {
+		return 0, err
+	}
+	return power.Int64(), nil
+}
+
+// GetVotingInfo returns the voting power, ballot and proposal upvotes of
+// delegate addr at block id.
+func (c *Client) GetVotingInfo(ctx context.Context, addr mavryk.Address, id BlockID) (*VotingInfo, error) {
+	var info VotingInfo
+	u := fmt.Sprintf("chains/main/blocks/%s/context/delegates/%s/voting_info", id, addr)
+	if err := c.Get(ctx, u, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// GetVoteState returns a summary of the current voting period at block id
+// with all period specific results.
+func (c *Client) GetVoteState(ctx context.Context, id BlockID) (*VoteState, error) {
+	period, err := c.GetVotingPeriod(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	state := &VoteState{
+		Period: *period,
+	}
+	if state.Quorum, err = c.GetVoteQuorum(ctx, id); err != nil {
+		return nil, err
+	}
+	if state.TotalPower, err = c.GetTotalVotingPower(ctx, id); err != nil {
+		return nil, err
+	}
+	switch period.VotingPeriod.Kind {
+	case mavryk.VotingPeriodProposal:
+		if state.Proposals, err = c.ListProposals(ctx, id); err != nil {
+			return nil, err
+		}
+	case mavryk.VotingPeriodExploration, mavryk.VotingPeriodPromotion:
+		if state.Ballots, err = c.GetVoteResult(ctx, id); err != nil {
+			return nil, err
+		}
+		fallthrough
+	default:
+		if state.Proposal, err = c.GetVoteProposal(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+	return state, nil
+}
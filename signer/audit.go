@@ -0,0 +1,226 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package signer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/mavryk-network/mvgo/codec"
+	"github.com/mavryk-network/mvgo/mavryk"
+)
+
+// AuditRecord is a single entry in a signer audit log. Records are hash
+// chained, i.e. each record contains the hash of its predecessor and its
+// own hash is computed over the predecessor hash and its contents.
+type AuditRecord struct {
+	Seq       int64             `json:"seq"`
+	Time      time.Time         `json:"time"`
+	Address   mavryk.Address    `json:"address"`
+	Kind      string            `json:"kind"`
+	Watermark byte              `json:"watermark"`
+	Level     int64             `json:"level"`
+	Round     int               `json:"round"`
+	Digest    mavryk.HexBytes   `json:"digest"`
+	Signature *mavryk.Signature `json:"signature,omitempty"`
+	Error     string            `json:"error,omitempty"`
+	Prev      mavryk.HexBytes   `json:"prev"`
+	Hash      mavryk.HexBytes   `json:"hash"`
+}
+
+// ComputeHash returns the chained hash for the record.
+func (r AuditRecord) ComputeHash() ([]byte, error) {
+	r.Hash = nil
+	buf, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	pre := make([]byte, 0, len(r.Prev)+len(buf))
+	pre = append(pre, r.Prev...)
+	h := mavryk.Digest(append(pre, buf...))
+	return h[:], nil
+}
+
+// AuditSigner wraps a signer and writes an audit record for every signing
+// request to an append-only log. Records are written as one JSON object per
+// line after signing. When writing the record fails, the signature is
+// withheld and an error is returned.
+type AuditSigner struct {
+	mu   sync.Mutex
+	s    Signer
+	w    io.Writer
+	seq  int64
+	prev []byte
+}
+
+// make sure AuditSigner implements Signer interface
+var _ Signer = (*AuditSigner)(nil)
+
+// AuditOption configures an audit signer created with NewAudit.
+type AuditOption func(*AuditSigner)
+
+// WithAuditChain continues the hash chain from the last record of an
+// existing log.
+func WithAuditChain(last AuditRecord) AuditOption {
+	return func(s *AuditSigner) {
+		s.seq = last.Seq
+		s.prev = last.Hash.Bytes()
+	}
+}
+
+// NewAudit creates an audit signer which logs to w. To continue an existing
+// log, use WithAuditChain to set the last record.
+func NewAudit(s Signer, w io.Writer, opts ...AuditOption) *AuditSigner {
+	a := &AuditSigner{
+		s: s,
+		w: w,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+func (s *AuditSigner) ListAddresses(ctx context.Context) ([]mavryk.Address, error) {
+	return s.s.ListAddresses(ctx)
+}
+
+func (s *AuditSigner) GetKey(ctx context.Context, addr mavryk.Address) (mavryk.Key, error) {
+	return s.s.GetKey(ctx, addr)
+}
+
+func (s *AuditSigner) SignMessage(ctx context.Context, addr mavryk.Address, msg string) (mavryk.Signature, error) {
+	op := codec.NewOp().
+		WithBranch(mavryk.ZeroBlockHash).
+		WithContents(&codec.FailingNoop{
+			Arbitrary: msg,
+		})
+	rec := AuditRecord{
+		Address:   addr,
+		Kind:      "message",
+		Watermark: codec.OperationWatermark,
+		Round:     -1,
+		Digest:    op.Digest(),
+	}
+	sig, err := s.s.SignMessage(ctx, addr, msg)
+	return s.log(rec, sig, err)
+}
+
+func (s *AuditSigner) SignOperation(ctx context.Context, addr mavryk.Address, op *codec.Op) (mavryk.Signature, error) {
+	rec := AuditRecord{
+		Address: addr,
+		Kind:    "operation",
+		Round:   -1,
+		Digest:  op.Digest(),
+	}
+	if buf := op.WatermarkedBytes(); len(buf) > 0 {
+		rec.Watermark = buf[0]
+	}
+	if len(op.Contents) > 0 {
+		rec.Kind = op.Contents[0].Kind().String()
+		switch v := op.Contents[0].(type) {
+		case *codec.TenderbakeEndorsement:
+			rec.Level, rec.Round = int64(v.Level), int(v.Round)
+		case *codec.TenderbakePreendorsement:
+			rec.Level, rec.Round = int64(v.Level), int(v.Round)
+		case *codec.Endorsement:
+			rec.Level = int64(v.Level)
+		}
+	}
+	sig, err := s.s.SignOperation(ctx, addr, op)
+	return s.log(rec, sig, err)
+}
+
+func (s *AuditSigner) SignBlock(ctx context.Context, addr mavryk.Address, head *codec.BlockHeader) (mavryk.Signature, error) {
+	rec := AuditRecord{
+		Address: addr,
+		Kind:    "block",
+		Level:   int64(head.Level),
+		Round:   head.Round(),
+		Digest:  head.Digest(),
+	}
+	if buf := head.WatermarkedBytes(); len(buf) > 0 {
+		rec.Watermark = buf[0]
+	}
+	sig, err := s.s.SignBlock(ctx, addr, head)
+	return s.log(rec, sig, err)
+}
+
+// log completes and appends a record for a signing result. Sequence numbers
+// and hashes are assigned under lock so the chain stays consistent across
+// concurrent calls.
+func (s *AuditSigner) log(rec AuditRecord, sig mavryk.Signature, err error) (mavryk.Signature, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec.Seq = s.seq + 1
+	rec.Time = time.Now().UTC()
+	if err != nil {
+		rec.Error = err.Error()
+	} else {
+		rec.Signature = &sig
+	}
+	rec.Prev = s.prev
+	hash, herr := rec.ComputeHash()
+	if herr != nil {
+		return mavryk.InvalidSignature, fmt.Errorf("signer: audit log: %w", herr)
+	}
+	rec.Hash = hash
+	buf, herr := json.Marshal(rec)
+	if herr != nil {
+		return mavryk.InvalidSignature, fmt.Errorf("signer: audit log: %w", herr)
+	}
+	if _, herr := s.w.Write(append(buf, '\n')); herr != nil {
+		return mavryk.InvalidSignature, fmt.Errorf("signer: audit log: %w", herr)
+	}
+	s.seq = rec.Seq
+	s.prev = hash
+	return sig, err
+}
+
+// VerifyAuditLog reads an audit log from r and checks sequence numbers and
+// the hash chain of all records. It returns the last record on success.
+func VerifyAuditLog(r io.Reader) (*AuditRecord, error) {
+	var (
+		last *AuditRecord
+		prev []byte
+		seq  int64
+	)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		rec := &AuditRecord{}
+		if err := json.Unmarshal(line, rec); err != nil {
+			return last, fmt.Errorf("signer: audit record %d: %w", seq+1, err)
+		}
+		if last != nil {
+			if rec.Seq != seq+1 {
+				return last, fmt.Errorf("signer: audit record %d: unexpected sequence %d", seq+1, rec.Seq)
+			}
+			if !bytes.Equal(rec.Prev, prev) {
+				return last, fmt.Errorf("signer: audit record %d: broken hash chain", rec.Seq)
+			}
+		}
+		hash, err := rec.ComputeHash()
+		if err != nil {
+			return last, fmt.Errorf("signer: audit record %d: %w", rec.Seq, err)
+		}
+		if !bytes.Equal(rec.Hash, hash) {
+			return last, fmt.Errorf("signer: audit record %d: hash mismatch", rec.Seq)
+		}
+		last, prev, seq = rec, hash, rec.Seq
+	}
+	if err := scanner.Err(); err != nil {
+		return last, fmt.Errorf("signer: audit log: %w", err)
+	}
+	return last, nil
+}
@@ -0,0 +1,196 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package signer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mavryk-network/mvgo/codec"
+	"github.com/mavryk-network/mvgo/mavryk"
+)
+
+// Failure reasons reported to metrics
+const (
+	FailureAddressMismatch = "address_mismatch"
+	FailureWatchOnly       = "watch_only"
+	FailureCanceled        = "canceled"
+	FailureTimeout         = "timeout"
+	FailureOther           = "other"
+)
+
+// FailureReason classifies a signing error for metrics.
+func FailureReason(err error) string {
+	switch {
+	case errors.Is(err, ErrAddressMismatch):
+		return FailureAddressMismatch
+	case errors.Is(err, ErrWatchOnly):
+		return FailureWatchOnly
+	case errors.Is(err, context.Canceled):
+		return FailureCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return FailureTimeout
+	default:
+		return FailureOther
+	}
+}
+
+// Metrics receives an event for every signing request. Implementations must
+// be safe for concurrent use. Kind is one of message, operation or block.
+type Metrics interface {
+	ObserveSign(kind string, d time.Duration, err error)
+}
+
+// LatencyStats contains request counts and latencies for a signing kind.
+type LatencyStats struct {
+	Count int64         `json:"count"`
+	Total time.Duration `json:"total"`
+	Max   time.Duration `json:"max"`
+}
+
+// Mean returns the average signing latency.
+func (l LatencyStats) Mean() time.Duration {
+	if l.Count == 0 {
+		return 0
+	}
+	return l.Total / time.Duration(l.Count)
+}
+
+// StatsSnapshot is a copy of the counters collected by Stats.
+type StatsSnapshot struct {
+	Requests  map[string]LatencyStats `json:"requests"`
+	Failures  map[string]int64        `json:"failures"`
+	LastSign  time.Time               `json:"last_sign"`
+	LastError string                  `json:"last_error,omitempty"`
+}
+
+// Stats is an in-memory Metrics implementation which counts requests by kind
+// and failures by reason.
+type Stats struct {
+	mu   sync.Mutex
+	snap StatsSnapshot
+}
+
+// make sure Stats implements Metrics interface
+var _ Metrics = (*Stats)(nil)
+
+func NewStats() *Stats {
+	return &Stats{
+		snap: StatsSnapshot{
+			Requests: make(map[string]LatencyStats),
+			Failures: make(map[string]int64),
+		},
+	}
+}
+
+func (s *Stats) ObserveSign(kind string, d time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l := s.snap.Requests[kind]
+	l.Count++
+	l.Total += d
+	if d > l.Max {
+		l.Max = d
+	}
+	s.snap.Requests[kind] = l
+	if err != nil {
+		s.snap.Failures[FailureReason(err)]++
+		s.snap.LastError = err.Error()
+	} else {
+		s.snap.LastSign = time.Now().UTC()
+	}
+}
+
+// Snapshot returns a copy of all counters.
+func (s *Stats) Snapshot() StatsSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snap := StatsSnapshot{
+		Requests:  make(map[string]LatencyStats, len(s.snap.Requests)),
+		Failures:  make(map[string]int64, len(s.snap.Failures)),
+		LastSign:  s.snap.LastSign,
+		LastError: s.snap.LastError,
+	}
+	for k, v := range s.snap.Requests {
+		snap.Requests[k] = v
+	}
+	for k, v := range s.snap.Failures {
+		snap.Failures[k] = v
+	}
+	return snap
+}
+
+// MetricsSigner wraps a signer and reports latency and result of every
+// signing request to a Metrics implementation.
+type MetricsSigner struct {
+	s Signer
+	m Metrics
+}
+
+// make sure MetricsSigner implements Signer interface
+var _ Signer = (*MetricsSigner)(nil)
+
+func NewWithMetrics(s Signer, m Metrics) *MetricsSigner {
+	return &MetricsSigner{
+		s: s,
+		m: m,
+	}
+}
+
+func (s *MetricsSigner) ListAddresses(ctx context.Context) ([]mavryk.Address, error) {
+	return s.s.ListAddresses(ctx)
+}
+
+func (s *MetricsSigner) GetKey(ctx context.Context, addr mavryk.Address) (mavryk.Key, error) {
+	return s.s.GetKey(ctx, addr)
+}
+
+func (s *MetricsSigner) SignMessage(ctx context.Context, addr mavryk.Address, msg string) (mavryk.Signature, error) {
+	start := time.Now()
+	sig, err := s.s.SignMessage(ctx, addr, msg)
+	s.m.ObserveSign("message", time.Since(start), err)
+	return sig, err
+}
+
+func (s *MetricsSigner) SignOperation(ctx context.Context, addr mavryk.Address, op *codec.Op) (mavryk.Signature, error) {
+	start := time.Now()
+	sig, err := s.s.SignOperation(ctx, addr, op)
+	s.m.ObserveSign("operation", time.Since(start), err)
+	return sig, err
+}
+
+func (s *MetricsSigner) SignBlock(ctx context.Context, addr mavryk.Address, head *codec.BlockHeader) (mavryk.Signature, error) {
+	start := time.Now()
+	sig, err := s.s.SignBlock(ctx, addr, head)
+	s.m.ObserveSign("block", time.Since(start), err)
+	return sig, err
+}
+
+// NewHealthHandler returns an HTTP handler which serves /health and /metrics.
+// Health checks list the signer's addresses with a short timeout and respond
+// with 503 on failure. Metrics respond with a JSON stats snapshot and are
+// only served when stats is not nil.
+func NewHealthHandler(s Signer, stats *Stats) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+		if _, err := s.ListAddresses(ctx); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	if stats != nil {
+		mux.HandleFunc("/metrics", func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(stats.Snapshot())
+		})
+	}
+	return mux
+}
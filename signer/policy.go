@@ -0,0 +1,601 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package signer
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mavryk-network/mvgo/codec"
+	"github.com/mavryk-network/mvgo/mavryk"
+	"github.com/mavryk-network/mvgo/micheline"
+)
+
+var ErrPolicyViolation = errors.New("signer: policy violation")
+
+// NativeToken is the policy key for native mumav amounts. Token amounts use
+// the token's string representation as key, e.g. KT1..._0, tickets use
+// TicketToken.
+const NativeToken = ""
+
+// TicketToken returns the policy key for tickets issued by ticketer.
+func TicketToken(ticketer mavryk.Address) string {
+	return "ticket:" + ticketer.String()
+}
+
+// Spend is a single outgoing transfer of native currency or tokens or a
+// grant which allows a third party to transfer tokens. Destination is invalid
+// for origination balances because the contract address is only known after
+// signing.
+type Spend struct {
+	Token       string         // NativeToken or token key
+	Destination mavryk.Address // receiver, spender or operator
+	Amount      mavryk.Z       // in base units
+	Unlimited   bool           // operator grants allow to spend any amount
+}
+
+// Spends returns all value transfers contained in operation op. These are
+// native transaction amounts, origination balances, storage paid by
+// increase_paid_storage, ticket transfers, FA1.2 and FA2 token transfers,
+// FA1.2 allowances and FA2 operator grants. Token calls are detected by the
+// transfer, approve and update_operators entrypoints and their parameter
+// structure. Fees are not counted.
+func Spends(op *codec.Op) []Spend {
+	var spends []Spend
+	for _, v := range op.Contents {
+		switch o := v.(type) {
+		case *codec.Transaction:
+			if !o.Amount.IsZero() {
+				spends = append(spends, Spend{
+					Token:       NativeToken,
+					Destination: o.Destination,
+					Amount:      mavryk.NewZ(int64(o.Amount)),
+				})
+			}
+			if o.Parameters == nil {
+				continue
+			}
+			switch o.Parameters.Entrypoint {
+			case "transfer":
+				spends = append(spends, tokenSpends(o.Destination, o.Parameters.Value)...)
+			case "approve":
+				spends = append(spends, allowanceSpends(o.Destination, o.Parameters.Value)...)
+			case "update_operators":
+				spends = append(spends, operatorSpends(o.Destination, o.Parameters.Value)...)
+			}
+		case *codec.Origination:
+			if !o.Balance.IsZero() {
+				spends = append(spends, Spend{
+					Token:  NativeToken,
+					Amount: mavryk.NewZ(int64(o.Balance)),
+				})
+			}
+		case *codec.IncreasePaidStorage:
+			if o.Amount.IsZero() {
+				continue
+			}
+			p := op.Params
+			if p == nil {
+				p = mavryk.DefaultParams
+			}
+			spends = append(spends, Spend{
+				Token:       NativeToken,
+				Destination: o.Destination,
+				Amount:      o.Amount.Mul64(p.CostPerByte),
+			})
+		case *codec.TransferTicket:
+			if !o.Amount.IsZero() {
+				spends = append(spends, Spend{
+					Token:       TicketToken(o.Ticketer),
+					Destination: o.Destination,
+					Amount:      mavryk.NewZ(int64(o.Amount)),
+				})
+			}
+		}
+	}
+	return spends
+}
+
+// tokenSpends decodes FA1.2 (from, to, amount) and FA2 list(from,
+// list(to, token_id, amount)) transfer parameters. Pairs may be nested or
+// in comb sequence form.
+func tokenSpends(ledger mavryk.Address, val micheline.Prim) []Spend {
+	if args := flattenComb(val); len(args) == 3 && args[2].Int != nil {
+		to, ok := primAddress(args[1])
+		if !ok {
+			return nil
+		}
+		return []Spend{{
+			Token:       mavryk.NewToken(ledger, mavryk.Zero).String(),
+			Destination: to,
+			Amount:      mavryk.NewBigZ(args[2].Int),
+		}}
+	}
+	if !val.IsSequence() {
+		return nil
+	}
+	var spends []Spend
+	for _, v := range val.Args {
+		args := flattenComb(v)
+		if len(args) != 2 || !args[1].IsSequence() {
+			continue
+		}
+		for _, tx := range args[1].Args {
+			targs := flattenComb(tx)
+			if len(targs) != 3 || targs[1].Int == nil || targs[2].Int == nil {
+				continue
+			}
+			to, ok := primAddress(targs[0])
+			if !ok {
+				continue
+			}
+			spends = append(spends, Spend{
+				Token:       mavryk.NewToken(ledger, mavryk.NewBigZ(targs[1].Int)).String(),
+				Destination: to,
+				Amount:      mavryk.NewBigZ(targs[2].Int),
+			})
+		}
+	}
+	return spends
+}
+
+// allowanceSpends decodes FA1.2 approve (spender, value) parameters. The
+// allowance counts as spent because the spender may transfer it any time.
+func allowanceSpends(ledger mavryk.Address, val micheline.Prim) []Spend {
+	args := flattenComb(val)
+	if len(args) != 2 || args[1].Int == nil {
+		return nil
+	}
+	spender, ok := primAddress(args[0])
+	if !ok || args[1].Int.Sign() == 0 {
+		return nil
+	}
+	return []Spend{{
+		Token:       mavryk.NewToken(ledger, mavryk.Zero).String(),
+		Destination: spender,
+		Amount:      mavryk.NewBigZ(args[1].Int),
+	}}
+}
+
+// operatorSpends decodes FA2 update_operators list(or(add_operator(owner,
+// operator, token_id), remove_operator(..))) parameters. Each added operator
+// is an unlimited grant, removals are ignored.
+func operatorSpends(ledger mavryk.Address, val micheline.Prim) []Spend {
+	if !val.IsSequence() {
+		return nil
+	}
+	var spends []Spend
+	for _, v := range val.Args {
+		if v.OpCode != micheline.D_LEFT || len(v.Args) != 1 {
+			continue
+		}
+		args := flattenComb(v.Args[0])
+		if len(args) != 3 || args[2].Int == nil {
+			continue
+		}
+		operator, ok := primAddress(args[1])
+		if !ok {
+			continue
+		}
+		spends = append(spends, Spend{
+			Token:       mavryk.NewToken(ledger, mavryk.NewBigZ(args[2].Int)).String(),
+			Destination: operator,
+			Unlimited:   true,
+		})
+	}
+	return spends
+}
+
+// flattenComb returns the fields of a nested pair or comb sequence.
+func flattenComb(p micheline.Prim) []micheline.Prim {
+	if !p.IsSequence() {
+		return flattenPair(p)
+	}
+	flat := make([]micheline.Prim, 0, len(p.Args))
+	for _, v := range p.Args {
+		flat = append(flat, flattenPair(v)...)
+	}
+	return flat
+}
+
+func flattenPair(p micheline.Prim) []micheline.Prim {
+	if p.OpCode != micheline.D_PAIR || p.IsSequence() {
+		return []micheline.Prim{p}
+	}
+	flat := make([]micheline.Prim, 0, len(p.Args))
+	for _, v := range p.Args {
+		flat = append(flat, flattenPair(v)...)
+	}
+	return flat
+}
+
+func primAddress(p micheline.Prim) (a mavryk.Address, ok bool) {
+	switch {
+	case p.String != "":
+		var err error
+		a, err = mavryk.ParseAddress(p.String)
+		return a, err == nil
+	case len(p.Bytes) > 0:
+		return a, a.Decode(p.Bytes) == nil
+	}
+	return a, false
+}
+
+// Policy defines spending rules for automated wallets. Amounts are keyed by
+// token, use NativeToken for mumav.
+//
+// The allowlist applies to all contract calls and to all receivers,
+// spenders and operators of token spends. Originated contracts have no known
+// address before signing, so originations are rejected when an allowlist is
+// set unless AllowOrigination is true. Unlimited operator grants violate
+// daily limits and require approval when a cosign threshold is set for the
+// token.
+type Policy struct {
+	DailyLimits      map[string]mavryk.Z // max amount spent per UTC day
+	Allowlist        []mavryk.Address    // allowed destinations, empty allows all
+	AllowOrigination bool                // allow originations with an allowlist
+	CosignAbove      map[string]mavryk.Z // single transfers above require approval
+}
+
+// IsAllowed returns true when transfers to addr are allowed.
+func (p Policy) IsAllowed(addr mavryk.Address) bool {
+	if len(p.Allowlist) == 0 {
+		return true
+	}
+	for _, v := range p.Allowlist {
+		if v.Equal(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// SpendStore persists amounts spent per day and token.
+type SpendStore interface {
+	Spent(ctx context.Context, day string, token string) (mavryk.Z, error)
+	AddSpent(ctx context.Context, day string, token string, amount mavryk.Z) error
+}
+
+// Approver approves operations which exceed a policy's cosign threshold,
+// e.g. by asking a second signer or a human operator.
+type Approver interface {
+	Approve(ctx context.Context, addr mavryk.Address, op *codec.Op, spends []Spend) error
+}
+
+// PolicySigner wraps a signer and evaluates a spending policy before each
+// operation is signed. Operations which violate the policy are rejected with
+// an error wrapping ErrPolicyViolation. Spent amounts are recorded in a store
+// after signing succeeded. Note that amounts count as spent even when the
+// signed operation is never included.
+type PolicySigner struct {
+	mu       sync.Mutex
+	s        Signer
+	policy   Policy
+	store    SpendStore
+	approver Approver
+	now      func() time.Time
+}
+
+// make sure PolicySigner implements Signer interface
+var _ Signer = (*PolicySigner)(nil)
+
+// PolicyOption configures a policy signer created with NewPolicy.
+type PolicyOption func(*PolicySigner)
+
+// WithSpendStore sets the store for spent amounts.
+func WithSpendStore(store SpendStore) PolicyOption {
+	return func(s *PolicySigner) {
+		s.store = store
+	}
+}
+
+// WithApprover sets the approver for transfers above cosign thresholds.
+// Without approver such transfers are rejected.
+func WithApprover(a Approver) PolicyOption {
+	return func(s *PolicySigner) {
+		s.approver = a
+	}
+}
+
+// NewPolicy creates a policy signer which keeps spent amounts in memory.
+// Use WithSpendStore to persist spent amounts across restarts.
+func NewPolicy(s Signer, p Policy, opts ...PolicyOption) *PolicySigner {
+	ps := &PolicySigner{
+		s:      s,
+		policy: p,
+		store:  NewMemorySpendStore(),
+		now:    time.Now,
+	}
+	for _, opt := range opts {
+		opt(ps)
+	}
+	return ps
+}
+
+func (s *PolicySigner) ListAddresses(ctx context.Context) ([]mavryk.Address, error) {
+	return s.s.ListAddresses(ctx)
+}
+
+func (s *PolicySigner) GetKey(ctx context.Context, addr mavryk.Address) (mavryk.Key, error) {
+	return s.s.GetKey(ctx, addr)
+}
+
+func (s *PolicySigner) SignMessage(ctx context.Context, addr mavryk.Address, msg string) (mavryk.Signature, error) {
+	return s.s.SignMessage(ctx, addr, msg)
+}
+
+func (s *PolicySigner) SignBlock(ctx context.Context, addr mavryk.Address, head *codec.BlockHeader) (mavryk.Signature, error) {
+	return s.s.SignBlock(ctx, addr, head)
+}
+
+func (s *PolicySigner) SignOperation(ctx context.Context, addr mavryk.Address, op *codec.Op) (mavryk.Signature, error) {
+	if err := s.checkDestinations(op); err != nil {
+		return mavryk.InvalidSignature, err
+	}
+	spends := Spends(op)
+	if len(spends) == 0 {
+		return s.s.SignOperation(ctx, addr, op)
+	}
+	totals, needApproval, err := s.evaluate(spends)
+	if err != nil {
+		return mavryk.InvalidSignature, err
+	}
+	day := s.now().UTC().Format("2006-01-02")
+
+	// approvals may wait for a human operator, so they run unlocked to not
+	// block unrelated requests; limits are checked again afterwards
+	if needApproval {
+		if s.approver == nil {
+			return mavryk.InvalidSignature, fmt.Errorf("%w: approval required", ErrPolicyViolation)
+		}
+		s.mu.Lock()
+		err := s.checkLimits(ctx, day, totals)
+		s.mu.Unlock()
+		if err != nil {
+			return mavryk.InvalidSignature, err
+		}
+		if err := s.approver.Approve(ctx, addr, op, spends); err != nil {
+			return mavryk.InvalidSignature, fmt.Errorf("%w: approval failed: %v", ErrPolicyViolation, err)
+		}
+	}
+
+	// serialize checks and updates so concurrent requests can't overspend
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkLimits(ctx, day, totals); err != nil {
+		return mavryk.InvalidSignature, err
+	}
+	sig, err := s.s.SignOperation(ctx, addr, op)
+	if err != nil {
+		return sig, err
+	}
+	for token, amount := range totals {
+		if _, ok := s.policy.DailyLimits[token]; !ok {
+			continue
+		}
+		if err := s.store.AddSpent(ctx, day, token, amount); err != nil {
+			return mavryk.InvalidSignature, err
+		}
+	}
+	return sig, nil
+}
+
+// checkDestinations checks all called contracts and receivers of op against
+// the allowlist, including calls which do not transfer value.
+func (s *PolicySigner) checkDestinations(op *codec.Op) error {
+	if len(s.policy.Allowlist) == 0 {
+		return nil
+	}
+	for _, v := range op.Contents {
+		var dest mavryk.Address
+		switch o := v.(type) {
+		case *codec.Transaction:
+			dest = o.Destination
+		case *codec.TransferTicket:
+			dest = o.Destination
+		case *codec.IncreasePaidStorage:
+			dest = o.Destination
+		case *codec.Origination:
+			if !s.policy.AllowOrigination {
+				return fmt.Errorf("%w: originations not allowed", ErrPolicyViolation)
+			}
+			continue
+		default:
+			continue
+		}
+		if !s.policy.IsAllowed(dest) {
+			return fmt.Errorf("%w: destination %s not allowed", ErrPolicyViolation, dest)
+		}
+	}
+	return nil
+}
+
+// evaluate checks spend receivers against the allowlist and returns the
+// total amount per token and whether spends exceed a cosign threshold.
+func (s *PolicySigner) evaluate(spends []Spend) (map[string]mavryk.Z, bool, error) {
+	totals := make(map[string]mavryk.Z)
+	var needApproval bool
+	for _, v := range spends {
+		// origination balances are checked in checkDestinations
+		if v.Destination.IsValid() && !s.policy.IsAllowed(v.Destination) {
+			return nil, false, fmt.Errorf("%w: destination %s not allowed", ErrPolicyViolation, v.Destination)
+		}
+		if v.Unlimited {
+			if _, ok := s.policy.DailyLimits[v.Token]; ok {
+				return nil, false, fmt.Errorf("%w: unlimited grant for %s exceeds daily limit", ErrPolicyViolation, tokenName(v.Token))
+			}
+		}
+		if limit, ok := s.policy.CosignAbove[v.Token]; ok && (v.Unlimited || limit.IsLess(v.Amount)) {
+			needApproval = true
+		}
+		totals[v.Token] = totals[v.Token].Add(v.Amount)
+	}
+	return totals, needApproval, nil
+}
+
+// checkLimits checks totals against daily limits. Callers must hold the lock.
+func (s *PolicySigner) checkLimits(ctx context.Context, day string, totals map[string]mavryk.Z) error {
+	for token, amount := range totals {
+		limit, ok := s.policy.DailyLimits[token]
+		if !ok {
+			continue
+		}
+		spent, err := s.store.Spent(ctx, day, token)
+		if err != nil {
+			return err
+		}
+		if limit.IsLess(spent.Add(amount)) {
+			return fmt.Errorf("%w: daily limit %s for %s exceeded (spent %s, requested %s)",
+				ErrPolicyViolation, limit, tokenName(token), spent, amount)
+		}
+	}
+	return nil
+}
+
+func tokenName(token string) string {
+	if token == NativeToken {
+		return mavryk.Symbol
+	}
+	return token
+}
+
+// SignerApprover approves operations with a second signer. The second signer
+// signs the operation digest as message and the signature is verified
+// against its key.
+type SignerApprover struct {
+	Signer  Signer
+	Address mavryk.Address
+}
+
+// NewSignerApprover creates an approver which requires a signature by addr
+// from signer s.
+func NewSignerApprover(s Signer, addr mavryk.Address) *SignerApprover {
+	return &SignerApprover{
+		Signer:  s,
+		Address: addr,
+	}
+}
+
+func (a *SignerApprover) Approve(ctx context.Context, _ mavryk.Address, op *codec.Op, _ []Spend) error {
+	key, err := a.Signer.GetKey(ctx, a.Address)
+	if err != nil {
+		return err
+	}
+	msg := hex.EncodeToString(op.Digest())
+	sig, err := a.Signer.SignMessage(ctx, a.Address, msg)
+	if err != nil {
+		return err
+	}
+	noop := codec.NewOp().
+		WithBranch(mavryk.ZeroBlockHash).
+		WithContents(&codec.FailingNoop{
+			Arbitrary: msg,
+		})
+	digest := mavryk.Digest(noop.Bytes())
+	return key.Verify(digest[:], sig)
+}
+
+// MemorySpendStore keeps spent amounts in memory.
+type MemorySpendStore struct {
+	mu   sync.Mutex
+	day  string
+	used map[string]mavryk.Z
+}
+
+func NewMemorySpendStore() *MemorySpendStore {
+	return &MemorySpendStore{
+		used: make(map[string]mavryk.Z),
+	}
+}
+
+func (m *MemorySpendStore) Spent(_ context.Context, day string, token string) (mavryk.Z, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.day != day {
+		return mavryk.Zero, nil
+	}
+	return m.used[token], nil
+}
+
+func (m *MemorySpendStore) AddSpent(_ context.Context, day string, token string, amount mavryk.Z) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.day != day {
+		m.day = day
+		m.used = make(map[string]mavryk.Z)
+	}
+	m.used[token] = m.used[token].Add(amount)
+	return nil
+}
+
+// FileSpendStore persists spent amounts of the current day in a JSON file.
+// The file is replaced atomically on each update.
+type FileSpendStore struct {
+	MemorySpendStore
+	path string
+}
+
+type spendFile struct {
+	Day  string              `json:"day"`
+	Used map[string]mavryk.Z `json:"used"`
+}
+
+// NewFileSpendStore creates a store backed by file path and loads existing
+// amounts from it.
+func NewFileSpendStore(path string) (*FileSpendStore, error) {
+	s := &FileSpendStore{
+		MemorySpendStore: MemorySpendStore{
+			used: make(map[string]mavryk.Z),
+		},
+		path: path,
+	}
+	buf, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return s, nil
+	case err != nil:
+		return nil, err
+	}
+	var f spendFile
+	if err := json.Unmarshal(buf, &f); err != nil {
+		return nil, fmt.Errorf("signer: spend store %s: %w", path, err)
+	}
+	s.day = f.Day
+	if f.Used != nil {
+		s.used = f.Used
+	}
+	return s, nil
+}
+
+func (s *FileSpendStore) AddSpent(ctx context.Context, day string, token string, amount mavryk.Z) error {
+	if err := s.MemorySpendStore.AddSpent(ctx, day, token, amount); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	buf, err := json.Marshal(spendFile{Day: s.day, Used: s.used})
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,277 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package signer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mavryk-network/mvgo/codec"
+	"github.com/mavryk-network/mvgo/mavryk"
+	"github.com/mavryk-network/mvgo/micheline"
+)
+
+var (
+	testBranch   = mavryk.MustParseBlockHash("BKnYk1T5a49bb8me4WfQeugyFnMEH9h8cm6jqvL3BxRwE23EVBJ")
+	testAllowed  = mavryk.MustParseAddress("mv1C6KuPzs92LTENfXUPXq1oNxvaLjxJ9p8b")
+	testContract = mavryk.MustParseAddress("KT1Puc9St8wdNoGtLiD2WXaHbWU7styaxYhD")
+	testOther    = mavryk.MustParseAddress("mv1RcVGZ46tRpYh2pnkSPiQA7r72LRpQpBUE")
+)
+
+func newTestPolicy(t *testing.T, p Policy, opts ...PolicyOption) (*PolicySigner, mavryk.Address) {
+	t.Helper()
+	sk, err := mavryk.GenerateKey(mavryk.KeyTypeEd25519)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewPolicy(NewFromKey(sk), p, opts...), sk.Address()
+}
+
+func testOp(contents ...codec.Operation) *codec.Op {
+	op := codec.NewOp().WithBranch(testBranch)
+	for _, v := range contents {
+		op.WithContents(v)
+	}
+	return op
+}
+
+// testOrigination returns an origination of a unit contract with balance.
+func testOrigination(balance mavryk.N) *codec.Origination {
+	s := micheline.NewScript()
+	s.Code.Param = micheline.NewCode(micheline.K_PARAMETER, micheline.NewCode(micheline.T_UNIT))
+	s.Code.Storage = micheline.NewCode(micheline.K_STORAGE, micheline.NewCode(micheline.T_UNIT))
+	s.Code.Code = micheline.NewCode(micheline.K_CODE, micheline.NewSeq(
+		micheline.NewCode(micheline.I_CDR),
+		micheline.NewCode(micheline.I_NIL, micheline.NewCode(micheline.T_OPERATION)),
+		micheline.NewCode(micheline.I_PAIR),
+	))
+	s.Storage = micheline.Unit
+	return &codec.Origination{Balance: balance, Script: *s}
+}
+
+// testCall returns a zero amount call of entrypoint on testContract.
+func testCall(entrypoint string, val micheline.Prim) *codec.Transaction {
+	return &codec.Transaction{
+		Destination: testContract,
+		Parameters:  &micheline.Parameters{Entrypoint: entrypoint, Value: val},
+	}
+}
+
+// testApprove returns FA1.2 approve parameters.
+func testApprove(spender mavryk.Address, value int64) micheline.Prim {
+	return micheline.NewPair(micheline.NewString(spender.String()), micheline.NewInt64(value))
+}
+
+// testOperators returns FA2 update_operators parameters which add operator
+// for token id and remove testContract.
+func testOperators(operator mavryk.Address, id int64) micheline.Prim {
+	op := func(a mavryk.Address) micheline.Prim {
+		return micheline.NewPair(
+			micheline.NewString(testContract.String()),
+			micheline.NewPair(micheline.NewString(a.String()), micheline.NewInt64(id)),
+		)
+	}
+	return micheline.NewSeq(
+		micheline.NewCode(micheline.D_LEFT, op(operator)),
+		micheline.NewCode(micheline.D_RIGHT, op(testContract)),
+	)
+}
+
+func TestSpends(t *testing.T) {
+	// FA1.2 transfer (from, (to, amount)) on ledger testContract
+	fa12 := micheline.NewPair(
+		micheline.NewString(testContract.String()),
+		micheline.NewPair(micheline.NewString(testAllowed.String()), micheline.NewInt64(5)),
+	)
+	op := testOp(
+		&codec.Transaction{Amount: 10, Destination: testAllowed},
+		&codec.Transaction{Destination: testContract, Parameters: &micheline.Parameters{Entrypoint: "transfer", Value: fa12}},
+		testOrigination(20),
+		&codec.IncreasePaidStorage{Amount: mavryk.NewZ(4), Destination: testContract},
+		&codec.TransferTicket{Ticketer: testContract, Amount: 3, Destination: testAllowed},
+		&codec.Delegation{Delegate: testAllowed},
+		testCall("approve", testApprove(testAllowed, 7)),
+		testCall("update_operators", testOperators(testAllowed, 2)),
+	)
+	have := Spends(op)
+	fa12Token := mavryk.NewToken(testContract, mavryk.Zero).String()
+	want := []Spend{
+		{Token: NativeToken, Destination: testAllowed, Amount: mavryk.NewZ(10)},
+		{Token: fa12Token, Destination: testAllowed, Amount: mavryk.NewZ(5)},
+		{Token: NativeToken, Amount: mavryk.NewZ(20)},
+		{Token: NativeToken, Destination: testContract, Amount: mavryk.NewZ(4 * mavryk.DefaultParams.CostPerByte)},
+		{Token: TicketToken(testContract), Destination: testAllowed, Amount: mavryk.NewZ(3)},
+		{Token: fa12Token, Destination: testAllowed, Amount: mavryk.NewZ(7)},
+		{Token: mavryk.NewToken(testContract, mavryk.NewZ(2)).String(), Destination: testAllowed, Unlimited: true},
+	}
+	if len(have) != len(want) {
+		t.Fatalf("want %d spends, have %d: %v", len(want), len(have), have)
+	}
+	for i := range want {
+		if have[i].Token != want[i].Token || !have[i].Destination.Equal(want[i].Destination) ||
+			!have[i].Amount.Equal(want[i].Amount) || have[i].Unlimited != want[i].Unlimited {
+			t.Errorf("spend %d: want %v, have %v", i, want[i], have[i])
+		}
+	}
+}
+
+func TestPolicyAllowlist(t *testing.T) {
+	s, addr := newTestPolicy(t, Policy{Allowlist: []mavryk.Address{testAllowed}})
+	ctx := context.Background()
+
+	if _, err := s.SignOperation(ctx, addr, testOp(&codec.Transaction{Amount: 1, Destination: testAllowed})); err != nil {
+		t.Errorf("allowed transfer: %v", err)
+	}
+	for name, v := range map[string]codec.Operation{
+		"transfer":    &codec.Transaction{Amount: 1, Destination: testContract},
+		"origination": testOrigination(1),
+		"ticket":      &codec.TransferTicket{Ticketer: testContract, Amount: 1, Destination: testContract},
+		"call":        testCall("default", micheline.Unit),
+		"approve":     testCall("approve", testApprove(testAllowed, 1)),
+	} {
+		if _, err := s.SignOperation(ctx, addr, testOp(v)); !errors.Is(err, ErrPolicyViolation) {
+			t.Errorf("%s: want policy violation, have %v", name, err)
+		}
+	}
+	// operations without value transfer are not restricted
+	if _, err := s.SignOperation(ctx, addr, testOp(&codec.Delegation{Delegate: testContract})); err != nil {
+		t.Errorf("delegation: %v", err)
+	}
+
+	// grants to third parties on allowed contracts are checked
+	s, addr = newTestPolicy(t, Policy{Allowlist: []mavryk.Address{testAllowed, testContract}})
+	if _, err := s.SignOperation(ctx, addr, testOp(testCall("approve", testApprove(testAllowed, 1)))); err != nil {
+		t.Errorf("allowed approve: %v", err)
+	}
+	for name, v := range map[string]*codec.Transaction{
+		"approve":   testCall("approve", testApprove(testOther, 1)),
+		"operators": testCall("update_operators", testOperators(testOther, 0)),
+	} {
+		if _, err := s.SignOperation(ctx, addr, testOp(v)); !errors.Is(err, ErrPolicyViolation) {
+			t.Errorf("%s: want policy violation, have %v", name, err)
+		}
+	}
+
+	// originations need explicit permission
+	s, addr = newTestPolicy(t, Policy{Allowlist: []mavryk.Address{testAllowed}, AllowOrigination: true})
+	if _, err := s.SignOperation(ctx, addr, testOp(testOrigination(1))); err != nil {
+		t.Errorf("allowed origination: %v", err)
+	}
+}
+
+func TestPolicyDailyLimits(t *testing.T) {
+	s, addr := newTestPolicy(t, Policy{DailyLimits: map[string]mavryk.Z{
+		NativeToken:               mavryk.NewZ(100),
+		TicketToken(testContract): mavryk.NewZ(5),
+	}})
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	// all native spends in an operation count towards the cap
+	if _, err := s.SignOperation(ctx, addr, testOp(
+		&codec.Transaction{Amount: 40, Destination: testAllowed},
+		testOrigination(40),
+	)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SignOperation(ctx, addr, testOp(testOrigination(21))); !errors.Is(err, ErrPolicyViolation) {
+		t.Errorf("want daily limit violation, have %v", err)
+	}
+	if _, err := s.SignOperation(ctx, addr, testOp(&codec.Transaction{Amount: 20, Destination: testAllowed})); err != nil {
+		t.Errorf("transfer within limit: %v", err)
+	}
+
+	// tickets have separate caps
+	if _, err := s.SignOperation(ctx, addr, testOp(&codec.TransferTicket{Ticketer: testContract, Amount: 6, Destination: testAllowed})); !errors.Is(err, ErrPolicyViolation) {
+		t.Errorf("want ticket limit violation, have %v", err)
+	}
+
+	// limits reset on the next day
+	now = now.Add(24 * time.Hour)
+	if _, err := s.SignOperation(ctx, addr, testOp(&codec.Transaction{Amount: 100, Destination: testAllowed})); err != nil {
+		t.Errorf("transfer on next day: %v", err)
+	}
+}
+
+func TestPolicyCosign(t *testing.T) {
+	policy := Policy{CosignAbove: map[string]mavryk.Z{NativeToken: mavryk.NewZ(10)}}
+	s, addr := newTestPolicy(t, policy)
+	ctx := context.Background()
+
+	if _, err := s.SignOperation(ctx, addr, testOp(&codec.Transaction{Amount: 10, Destination: testAllowed})); err != nil {
+		t.Errorf("transfer below threshold: %v", err)
+	}
+	if _, err := s.SignOperation(ctx, addr, testOp(testOrigination(11))); !errors.Is(err, ErrPolicyViolation) {
+		t.Errorf("want approval violation, have %v", err)
+	}
+
+	sk, _ := mavryk.GenerateKey(mavryk.KeyTypeEd25519)
+	s, addr = newTestPolicy(t, policy, WithApprover(NewSignerApprover(NewFromKey(sk), sk.Address())))
+	if _, err := s.SignOperation(ctx, addr, testOp(testOrigination(11))); err != nil {
+		t.Errorf("approved origination: %v", err)
+	}
+
+	// operator grants are unlimited
+	token := mavryk.NewToken(testContract, mavryk.Zero).String()
+	s, addr = newTestPolicy(t, Policy{CosignAbove: map[string]mavryk.Z{token: mavryk.NewZ(100)}})
+	if _, err := s.SignOperation(ctx, addr, testOp(testCall("update_operators", testOperators(testOther, 0)))); !errors.Is(err, ErrPolicyViolation) {
+		t.Errorf("want approval violation for operator grant, have %v", err)
+	}
+	s, addr = newTestPolicy(t, Policy{DailyLimits: map[string]mavryk.Z{token: mavryk.NewZ(100)}})
+	if _, err := s.SignOperation(ctx, addr, testOp(testCall("update_operators", testOperators(testOther, 0)))); !errors.Is(err, ErrPolicyViolation) {
+		t.Errorf("want limit violation for operator grant, have %v", err)
+	}
+}
+
+// blockingApprover waits until released.
+type blockingApprover struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (a *blockingApprover) Approve(ctx context.Context, _ mavryk.Address, _ *codec.Op, _ []Spend) error {
+	close(a.started)
+	select {
+	case <-a.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestPolicyPendingApproval(t *testing.T) {
+	a := &blockingApprover{started: make(chan struct{}), release: make(chan struct{})}
+	s, addr := newTestPolicy(t, Policy{
+		DailyLimits: map[string]mavryk.Z{NativeToken: mavryk.NewZ(70)},
+		CosignAbove: map[string]mavryk.Z{NativeToken: mavryk.NewZ(10)},
+	}, WithApprover(a))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := s.SignOperation(ctx, addr, testOp(&codec.Transaction{Amount: 50, Destination: testAllowed}))
+		errc <- err
+	}()
+	<-a.started
+
+	// pending approvals do not block other requests
+	if _, err := s.SignOperation(ctx, addr, testOp(&codec.Transaction{Amount: 10, Destination: testAllowed})); err != nil {
+		t.Fatal(err)
+	}
+	// limits are checked again after approval
+	if _, err := s.SignOperation(ctx, addr, testOp(&codec.Transaction{Amount: 10, Destination: testAllowed})); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SignOperation(ctx, addr, testOp(&codec.Transaction{Amount: 10, Destination: testAllowed})); err != nil {
+		t.Fatal(err)
+	}
+	close(a.release)
+	if err := <-errc; !errors.Is(err, ErrPolicyViolation) {
+		t.Errorf("want limit violation after approval, have %v", err)
+	}
+}
@@ -21,15 +21,45 @@ type RemoteSigner struct {
 	auth  mavryk.PrivateKey
 }
 
+// Option configures a remote signer created with New.
+type Option func(*RemoteSigner)
+
+// WithAddress adds an address the remote signer can sign for.
+func WithAddress(addr mavryk.Address) Option {
+	return func(s *RemoteSigner) {
+		s.addrs = append(s.addrs, addr)
+	}
+}
+
+// WithAuthKey sets the key used to authenticate signing requests.
+func WithAuthKey(sk mavryk.PrivateKey) Option {
+	return func(s *RemoteSigner) {
+		s.auth = sk
+	}
+}
+
+// WithClientOptions configures the RPC client used to talk to the signer.
+func WithClientOptions(opts ...rpc.ClientOption) Option {
+	return func(s *RemoteSigner) {
+		for _, opt := range opts {
+			opt(s.c)
+		}
+	}
+}
+
 // New creates a new remote signer client and initializes it with the remote url.
 // Users may pass an optional http client with a custom configuration, otherwise
 // the http.DefaultClient is used.
-func New(url string, client *http.Client) (*RemoteSigner, error) {
+func New(url string, client *http.Client, opts ...Option) (*RemoteSigner, error) {
 	c, err := rpc.NewClient(url, client)
 	if err != nil {
 		return nil, err
 	}
-	return &RemoteSigner{c: c}, nil
+	s := &RemoteSigner{c: c}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s, nil
 }
 
 func (s *RemoteSigner) WithAddress(addr mavryk.Address) *RemoteSigner {
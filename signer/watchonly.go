@@ -0,0 +1,56 @@
+// Copyright (c) 2020-2024 Blockwatch Data Inc.
+// Author: alex@blockwatch.cc
+
+package signer
+
+import (
+	"context"
+	"errors"
+
+	"github.com/mavryk-network/mvgo/codec"
+	"github.com/mavryk-network/mvgo/mavryk"
+)
+
+var ErrWatchOnly = errors.New("signer: watch-only key cannot sign")
+
+// WatchOnlySigner manages public keys only. It can be used to build and simulate
+// operations when signing happens in a separate trust zone. All signing
+// methods return ErrWatchOnly.
+type WatchOnlySigner struct {
+	keys []mavryk.Key
+}
+
+func NewWatchOnly(keys ...mavryk.Key) *WatchOnlySigner {
+	return &WatchOnlySigner{
+		keys: keys,
+	}
+}
+
+func (s WatchOnlySigner) ListAddresses(_ context.Context) ([]mavryk.Address, error) {
+	addrs := make([]mavryk.Address, len(s.keys))
+	for i, k := range s.keys {
+		addrs[i] = k.Address()
+	}
+	return addrs, nil
+}
+
+func (s WatchOnlySigner) GetKey(_ context.Context, addr mavryk.Address) (mavryk.Key, error) {
+	for _, k := range s.keys {
+		if k.Address().Equal(addr) {
+			return k, nil
+		}
+	}
+	return mavryk.InvalidKey, ErrAddressMismatch
+}
+
+func (s WatchOnlySigner) SignMessage(_ context.Context, _ mavryk.Address, _ string) (mavryk.Signature, error) {
+	return mavryk.InvalidSignature, ErrWatchOnly
+}
+
+func (s WatchOnlySigner) SignOperation(_ context.Context, _ mavryk.Address, _ *codec.Op) (mavryk.Signature, error) {
+	return mavryk.InvalidSignature, ErrWatchOnly
+}
+
+func (s WatchOnlySigner) SignBlock(_ context.Context, _ mavryk.Address, _ *codec.BlockHeader) (mavryk.Signature, error) {
+	return mavryk.InvalidSignature, ErrWatchOnly
+}